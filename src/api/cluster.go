@@ -115,15 +115,19 @@ func tokenCheck(c echo.Context) error {
 		})
 	}
 
-	if token == common.Config.Token {
-		return c.JSON(http.StatusOK, map[string]string{
-			"status": "Authentication successful",
-		})
-	} else {
+	identity, err := authenticateToken(token)
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"status": "Authentication failed",
+			"error":  err.Error(),
 		})
 	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"status": "Authentication successful",
+		"user":   identity.User,
+		"role":   identity.Role,
+	})
 }
 
 func leaderConfig(c echo.Context) error {
@@ -3,14 +3,17 @@ package api
 import (
 	"AgentSmith-HUB/cluster"
 	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/gitops"
 	"AgentSmith-HUB/input"
 	"AgentSmith-HUB/local_plugin"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/lookup"
 	"AgentSmith-HUB/output"
 	"AgentSmith-HUB/plugin"
 	"AgentSmith-HUB/project"
 	"AgentSmith-HUB/rules_engine"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -107,6 +110,13 @@ const NewRulesetData = `<root author="name">
 const NewProjectData = `content: |
   INPUT.demo -> OUTPUT.demo`
 
+const NewLookupData = `format: csv
+key: ip
+match: exact
+content: |
+  ip,owner,note
+  10.0.0.1,netops,demo entry`
+
 // Utility functions
 func GetExt(componentType string, new bool) string {
 	if componentType == "ruleset" {
@@ -178,6 +188,8 @@ func WriteComponentFile(path string, content string) error {
 				project.SetProjectNew(id, content)
 			case "plugin":
 				plugin.SetPluginNew(id, content)
+			case "lookup":
+				lookup.SetLookupNew(id, content)
 			}
 		}
 	}
@@ -1122,6 +1134,8 @@ func createComponent(componentType string, c echo.Context) error {
 			request.Raw = NewRulesetData
 		case "project":
 			request.Raw = NewProjectData
+		case "lookup":
+			request.Raw = NewLookupData
 		}
 	}
 
@@ -1142,6 +1156,8 @@ func createComponent(componentType string, c echo.Context) error {
 		project.SetRulesetNew(request.ID, request.Raw)
 	case "project":
 		project.SetProjectNew(request.ID, request.Raw)
+	case "lookup":
+		lookup.SetLookupNew(request.ID, request.Raw)
 	}
 
 	// Publish instruction for component creation (NEW - this was missing!)
@@ -1151,7 +1167,7 @@ func createComponent(componentType string, c echo.Context) error {
 			// Don't fail the request, but log the error
 		}
 		// Record component creation operation history (for leader visibility)
-		common.RecordComponentAdd(componentType, request.ID, request.Raw, "success", "")
+		common.RecordComponentAdd(componentType, request.ID, request.Raw, "success", "", operatorFromContext(c))
 	}
 
 	// Create enhanced response with deployment guidance
@@ -1197,9 +1213,44 @@ func createPlugin(c echo.Context) error {
 	return createComponent("plugin", c)
 }
 
+func createLookup(c echo.Context) error {
+	return createComponent("lookup", c)
+}
+
+var (
+	errInvalidComponentType = errors.New("invalid component type")
+	errComponentNotFound    = errors.New("component not found")
+)
+
 func deleteComponent(componentType string, c echo.Context) error {
 	id := c.Param("id")
 
+	operator := operatorFromContext(c)
+	if err := removeComponent(componentType, id, operator); err != nil {
+		switch {
+		case errors.Is(err, errInvalidComponentType):
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid component type",
+			})
+		case errors.Is(err, errComponentNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("%s not found", componentType),
+			})
+		default:
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
+	}
+	gitops.CommitComponentDelete(componentType, id, operator)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("%s deleted successfully", componentType),
+	})
+}
+
+// removeComponent deletes a component, its files and, on the leader, publishes the deletion to followers
+func removeComponent(componentType, id, operator string) error {
 	var suffix string
 	var dir string
 
@@ -1219,10 +1270,11 @@ func deleteComponent(componentType string, c echo.Context) error {
 	case "plugin":
 		suffix = ".go"
 		dir = "plugin"
+	case "lookup":
+		suffix = ".yaml"
+		dir = "lookup"
 	default:
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid component type",
-		})
+		return errInvalidComponentType
 	}
 
 	configRoot := common.Config.ConfigRoot
@@ -1236,9 +1288,7 @@ func deleteComponent(componentType string, c echo.Context) error {
 	tempExists := tempErr == nil
 
 	if !formalExists && !tempExists {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": fmt.Sprintf("%s not found", componentType),
-		})
+		return errComponentNotFound
 	}
 
 	// Safely delete component using encapsulated functions
@@ -1256,16 +1306,16 @@ func deleteComponent(componentType string, c echo.Context) error {
 		affectedProjects, deletionErr = project.SafeDeleteProject(id)
 	case "plugin":
 		affectedProjects, deletionErr = plugin.SafeDeletePlugin(id)
+	case "lookup":
+		affectedProjects, deletionErr = lookup.SafeDeleteLookup(id)
 	default:
 		deletionErr = fmt.Errorf("unsupported component type: %s", componentType)
 	}
 
 	if deletionErr != nil {
 		// Record failed deletion operation
-		RecordComponentDelete(componentType, id, "failed", deletionErr.Error(), []string{})
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": deletionErr.Error(),
-		})
+		RecordComponentDelete(componentType, id, "failed", deletionErr.Error(), operator, []string{})
+		return deletionErr
 	}
 
 	// Get the affected projects from the safe delete operation for cluster notification
@@ -1288,6 +1338,13 @@ func deleteComponent(componentType string, c echo.Context) error {
 			}
 		}
 
+		// Test cases are versioned with their ruleset
+		if componentType == "ruleset" {
+			if err := os.Remove(rules_engine.TestCasePath(id)); err != nil && !os.IsNotExist(err) {
+				logger.Error("failed to delete ruleset test cases", "ruleset", id, "error", err)
+			}
+		}
+
 		// Publish deletion instruction regardless of which files existed
 		// This ensures followers are notified of the deletion
 		if componentType == "project" {
@@ -1315,12 +1372,10 @@ func deleteComponent(componentType string, c echo.Context) error {
 		}
 
 		// Record successful deletion operation
-		RecordComponentDelete(componentType, id, "success", "", affectedProjects)
+		RecordComponentDelete(componentType, id, "success", "", operator, affectedProjects)
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"message": fmt.Sprintf("%s deleted successfully", componentType),
-	})
+	return nil
 }
 
 func deleteRuleset(c echo.Context) error {
@@ -1351,6 +1406,10 @@ func deletePlugin(c echo.Context) error {
 	return deleteComponent("plugin", c)
 }
 
+func deleteLookup(c echo.Context) error {
+	return deleteComponent("lookup", c)
+}
+
 func updateRuleset(c echo.Context) error {
 	return updateComponent("ruleset", c)
 }
@@ -1371,6 +1430,10 @@ func updateProject(c echo.Context) error {
 	return updateComponent("project", c)
 }
 
+func updateLookup(c echo.Context) error {
+	return updateComponent("lookup", c)
+}
+
 func updateComponent(componentType string, c echo.Context) error {
 	id := c.Param("id")
 	var req struct {
@@ -1501,6 +1564,31 @@ func updateComponent(componentType string, c echo.Context) error {
 			formalPath, formalExists := GetComponentPath(componentType, id, false)
 			tempPath, tempExists = GetComponentPath(componentType, id, true)
 
+			if formalExists {
+				originalContent, err = ReadComponent(formalPath)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read original file: " + err.Error()})
+				}
+			} else if tempExists {
+				originalContent, err = ReadComponent(tempPath)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read temporary file: " + err.Error()})
+				}
+			} else {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "component config not found"})
+			}
+		}
+	case "lookup":
+		// Check if lookup exists in memory
+		if l, exists := lookup.Get(id); exists && l.Config != nil {
+			originalContent = l.Config.RawConfig
+		} else if l_raw, ok := lookup.GetLookupNew(id); ok {
+			originalContent = l_raw
+		} else {
+			// Fall back to file system check
+			formalPath, formalExists := GetComponentPath(componentType, id, false)
+			tempPath, tempExists = GetComponentPath(componentType, id, true)
+
 			if formalExists {
 				originalContent, err = ReadComponent(formalPath)
 				if err != nil {
@@ -1545,11 +1633,13 @@ func updateComponent(componentType string, c echo.Context) error {
 		project.SetProjectNew(id, req.Raw)
 	case "plugin":
 		plugin.SetPluginNew(id, req.Raw)
+	case "lookup":
+		lookup.SetLookupNew(id, req.Raw)
 	}
 
 	// Record component update operation history (for leader visibility)
 	if common.IsCurrentNodeLeader() {
-		common.RecordComponentUpdate(componentType, id, req.Raw, "success", "")
+		common.RecordComponentUpdate(componentType, id, req.Raw, "success", "", operatorFromContext(c))
 	}
 
 	// Create enhanced response with deployment guidance
@@ -1601,6 +1691,8 @@ func verifyComponent(c echo.Context) error {
 		singularType = "project"
 	case "plugins":
 		singularType = "plugin"
+	case "lookups":
+		singularType = "lookup"
 	}
 
 	// If no raw content provided in request, try to read from temporary or formal files
@@ -1715,6 +1807,14 @@ func verifyComponent(c echo.Context) error {
 			"errors":   result.Errors,
 			"warnings": result.Warnings,
 		})
+	case "lookup":
+		err := lookup.Verify("", req.Raw)
+		result := createSimpleResult(err)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":    result.IsValid,
+			"errors":   result.Errors,
+			"warnings": result.Warnings,
+		})
 	default:
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported component type"})
 	}
@@ -2311,7 +2411,7 @@ func searchComponentsConfig(c echo.Context) error {
 	}
 
 	// Component types to search
-	componentTypes := []string{"input", "output", "ruleset", "project", "plugin"}
+	componentTypes := []string{"input", "output", "ruleset", "project", "plugin", "lookup"}
 	var allResults []SearchResult
 
 	for _, componentType := range componentTypes {
@@ -2362,6 +2462,8 @@ func searchInComponentType(componentType, query string, isTemporary bool) []Sear
 			componentMap = project.GetAllProjectsNew()
 		case "plugin":
 			componentMap = plugin.PluginsNew
+		case "lookup":
+			componentMap = lookup.GetAllLookupsNew()
 		}
 	} else {
 		// For formal files, we need to read from the actual component instances
@@ -2398,6 +2500,13 @@ func searchInComponentType(componentType, query string, isTemporary bool) []Sear
 					}
 				}
 			}
+		case "lookup":
+			lookup.ForEachLookup(func(id string, comp *lookup.Lookup) bool {
+				if comp.Config != nil {
+					componentMap[id] = comp.Config.RawConfig
+				}
+				return true
+			})
 		}
 	}
 
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"AgentSmith-HUB/cluster"
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/project"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Every node spools the undeliverable messages of its own output instances to a local file. The
+// dead letter endpoints serve the spool of the node given by the node query parameter, requests
+// for another node are forwarded to its API with the cluster token once the caller is authorized.
+
+var errOutputNotFound = errors.New("output not found")
+
+// nodeAPIResolver finds the API of another node, replaced in tests
+var nodeAPIResolver = cluster.GetNodeAPI
+
+// getOutputDeadLetterQueue resolves the dead letter spool of an output on this node
+func getOutputDeadLetterQueue(id string) (*common.DeadLetterQueue, error) {
+	out, ok := project.GetOutput(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errOutputNotFound, id)
+	}
+	return out.DeadLetterQueue()
+}
+
+func deadLetterLookupError(c echo.Context, err error) error {
+	status := http.StatusBadRequest
+	if errors.Is(err, errOutputNotFound) {
+		status = http.StatusNotFound
+	}
+	return c.JSON(status, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+// forwardDeadLetterRequest sends the request to the node owning the spool, handled reports
+// whether it was forwarded (or failed to be) instead of being served locally
+func forwardDeadLetterRequest(c echo.Context) (handled bool, err error) {
+	node := c.QueryParam("node")
+	if node == "" || node == common.GetNodeID() {
+		return false, nil
+	}
+	api, ok := nodeAPIResolver(node)
+	if !ok {
+		return true, c.JSON(http.StatusNotFound, map[string]string{
+			"error": "node not found or its API address is unknown: " + node,
+		})
+	}
+	target, err := url.Parse(api)
+	if err != nil {
+		return true, c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "invalid API address of node " + node + ": " + api,
+		})
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Warn("Failed to forward dead letter request", "node", node, "uri", r.RequestURI, "error", err)
+		w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"error":"node not reachable"}`))
+	}
+	// The caller was authorized here, the owning node accepts the cluster token as admin
+	c.Request().Header.Set("token", common.Config.Token)
+	proxy.ServeHTTP(c.Response(), c.Request())
+	return true, nil
+}
+
+// getDeadLetters lists spooled records of an output.
+// Query: node (default this node), offset (default 0), limit (default 100)
+func getDeadLetters(c echo.Context) error {
+	if forwarded, err := forwardDeadLetterRequest(c); forwarded {
+		return err
+	}
+	dlq, err := getOutputDeadLetterQueue(c.Param("id"))
+	if err != nil {
+		return deadLetterLookupError(c, err)
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	limit := 100
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	records, total, err := dlq.List(offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to read dead letter queue: " + err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"output_id": dlq.OutputID,
+		"node":      common.GetNodeID(),
+		"path":      dlq.Path,
+		"total":     total,
+		"offset":    offset,
+		"limit":     limit,
+		"records":   records,
+	})
+}
+
+// replayDeadLetters sends spooled records through a running instance of the output again.
+// Query: node (default this node), limit (default 0, all records)
+func replayDeadLetters(c echo.Context) error {
+	if forwarded, err := forwardDeadLetterRequest(c); forwarded {
+		return err
+	}
+	dlq, err := getOutputDeadLetterQueue(c.Param("id"))
+	if err != nil {
+		return deadLetterLookupError(c, err)
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	// Any running instance will do, they all deliver to the same destination
+	for _, out := range project.GetPNSOutputsByID(dlq.OutputID) {
+		if out.Status != common.StatusRunning {
+			continue
+		}
+		replayed, err := out.ReplayDeadLetters(limit)
+		_, remaining, _ := dlq.List(0, 1)
+		if err != nil {
+			logger.Error("Dead letter replay failed", "output", dlq.OutputID, "replayed", replayed, "error", err)
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":     err.Error(),
+				"replayed":  replayed,
+				"remaining": remaining,
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"output_id": dlq.OutputID,
+			"node":      common.GetNodeID(),
+			"replayed":  replayed,
+			"remaining": remaining,
+		})
+	}
+
+	return c.JSON(http.StatusConflict, map[string]string{
+		"error": "output is not running on this node, start a project using it before replaying",
+	})
+}
+
+// purgeDeadLetters deletes all spooled records of an output.
+// Query: node (default this node)
+func purgeDeadLetters(c echo.Context) error {
+	if forwarded, err := forwardDeadLetterRequest(c); forwarded {
+		return err
+	}
+	dlq, err := getOutputDeadLetterQueue(c.Param("id"))
+	if err != nil {
+		return deadLetterLookupError(c, err)
+	}
+
+	purged, err := dlq.Purge()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to purge dead letter queue: " + err.Error(),
+		})
+	}
+	logger.Info("Dead letter queue purged", "output", dlq.OutputID, "count", purged)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"output_id": dlq.OutputID,
+		"node":      common.GetNodeID(),
+		"purged":    purged,
+	})
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/output"
+	"AgentSmith-HUB/project"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func serveDeadLetter(t *testing.T, method, target string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(method, target, nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues("dl_out")
+	if err := handler(c); err != nil {
+		t.Fatalf("handler returned %v", err)
+	}
+	return rec
+}
+
+func setupDeadLetterTest(t *testing.T) *common.DeadLetterQueue {
+	t.Helper()
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{Token: "cluster-token"}
+	common.SetClusterState(true, "node-a")
+	t.Cleanup(func() { common.Config = prevConfig })
+
+	out := &output.Output{Id: "dl_out", Config: &output.OutputConfig{
+		DeadLetter: &output.DeadLetterConfig{Path: filepath.Join(t.TempDir(), "dl_out.jsonl")},
+	}}
+	project.SetOutput("dl_out", out)
+	dlq, err := out.DeadLetterQueue()
+	if err != nil {
+		t.Fatalf("DeadLetterQueue failed: %v", err)
+	}
+	return dlq
+}
+
+func TestDeadLetterLocalNode(t *testing.T) {
+	dlq := setupDeadLetterTest(t)
+	dlq.Write([]map[string]interface{}{{"a": 1}, {"a": 2}}, errors.New("connection refused"))
+
+	for _, target := range []string{"/outputs/dl_out/dead-letter", "/outputs/dl_out/dead-letter?node=node-a"} {
+		rec := serveDeadLetter(t, http.MethodGet, target, getDeadLetters)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", target, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Node  string `json:"node"`
+			Total int    `json:"total"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if body.Node != "node-a" || body.Total != 2 {
+			t.Errorf("GET %s = %s, expected 2 records of node-a", target, rec.Body.String())
+		}
+	}
+
+	rec := serveDeadLetter(t, http.MethodDelete, "/outputs/dl_out/dead-letter", purgeDeadLetters)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, total, _ := dlq.List(0, 1); total != 0 {
+		t.Errorf("%d records left after purge", total)
+	}
+}
+
+func TestDeadLetterLookupErrors(t *testing.T) {
+	setupDeadLetterTest(t)
+	project.SetOutput("no_dl", &output.Output{Id: "no_dl", Config: &output.OutputConfig{}})
+
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{"missing", http.StatusNotFound},
+		{"no_dl", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			for _, handler := range []echo.HandlerFunc{getDeadLetters, replayDeadLetters, purgeDeadLetters} {
+				rec := httptest.NewRecorder()
+				c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+				c.SetParamNames("id")
+				c.SetParamValues(test.id)
+				if err := handler(c); err != nil {
+					t.Fatalf("handler returned %v, the response must be written instead", err)
+				}
+				if rec.Code != test.status {
+					t.Errorf("status = %d, expected %d: %s", rec.Code, test.status, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDeadLetterForwardedToOwningNode(t *testing.T) {
+	setupDeadLetterTest(t)
+
+	var got *http.Request
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"node":"node-b","total":7}`))
+	}))
+	defer node.Close()
+
+	prevResolver := nodeAPIResolver
+	nodeAPIResolver = func(id string) (string, bool) {
+		if id == "node-b" {
+			return node.URL, true
+		}
+		return "", false
+	}
+	defer func() { nodeAPIResolver = prevResolver }()
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/outputs/dl_out/dead-letter/replay?node=node-b&limit=5", nil)
+	req.Header.Set("token", "hub_user_token")
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("dl_out")
+	if err := replayDeadLetters(c); err != nil {
+		t.Fatalf("replayDeadLetters returned %v", err)
+	}
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"node":"node-b","total":7}` {
+		t.Fatalf("forwarded response = %d %s", rec.Code, rec.Body.String())
+	}
+	if got == nil {
+		t.Fatal("request was not forwarded")
+	}
+	if got.Method != http.MethodPost || got.URL.Path != "/outputs/dl_out/dead-letter/replay" || got.URL.Query().Get("limit") != "5" {
+		t.Errorf("forwarded %s %s", got.Method, got.URL)
+	}
+	if token := got.Header.Get("token"); token != "cluster-token" {
+		t.Errorf("forwarded token = %q, expected the cluster token", token)
+	}
+
+	rec = serveDeadLetter(t, http.MethodGet, "/outputs/dl_out/dead-letter?node=node-c", getDeadLetters)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown node = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+}
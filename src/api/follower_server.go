@@ -1,16 +1,26 @@
 package api
 
 import (
+	"AgentSmith-HUB/cluster"
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/metrics"
+	"context"
 	"errors"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-var followerToken string // Store token read at startup
+var (
+	followerServer   *echo.Echo
+	followerServerMu sync.Mutex
+)
 
 // ServerStartFollower starts the follower API server with read-only endpoints
 func ServerStartFollower(listenAddr string) error {
@@ -19,13 +29,13 @@ func ServerStartFollower(listenAddr string) error {
 		return nil
 	}
 
-	// Read token from Redis once at startup
-	var err error
-	followerToken, err = ReadTokenFromRedis()
+	// Read the cluster token from Redis once at startup, forwarded cluster calls carry it
+	token, err := ReadTokenFromRedis()
 	if err != nil {
 		logger.Error("Failed to read token from Redis, follower server will not start: %v", err)
 		return err
 	}
+	common.Config.Token = token
 
 	e := echo.New()
 	e.HideBanner = true
@@ -33,7 +43,7 @@ func ServerStartFollower(listenAddr string) error {
 	// Add CORS middleware
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodOptions},
+		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete, http.MethodOptions},
 		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "token"},
 	}))
 
@@ -45,26 +55,6 @@ func ServerStartFollower(listenAddr string) error {
 	// Recovery middleware
 	e.Use(middleware.Recover())
 
-	// Authentication middleware for protected endpoints
-	authMiddleware := func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			token := c.Request().Header.Get("token")
-			if token == "" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Token required",
-				})
-			}
-
-			if token != followerToken {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid token",
-				})
-			}
-
-			return next(c)
-		}
-	}
-
 	// Public endpoints (no authentication required)
 	e.GET("/ping", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{
@@ -77,61 +67,73 @@ func ServerStartFollower(listenAddr string) error {
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"role":    "follower",
 			"address": listenAddr,
-			"leader":  common.Config.Leader,
+			"leader":  common.GetLeaderID(),
 		})
 	})
 
-	// Protected endpoints (require authentication)
-	auth := e.Group("", authMiddleware)
+	// Prometheus metrics of this node
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+
+	// Push ingest for http inputs, authenticated per input
+	e.POST("/ingest/:id", ingestHTTPInput)
+
+	// Protected endpoints, authenticated like on the leader: shared, SSO session or per-user tokens
+	viewer := e.Group("", requireRole(RoleViewer))
+	operator := e.Group("", requireRole(RoleOperator))
 
 	// Read-only project endpoints
-	auth.GET("/projects", getProjects)
-	auth.GET("/projects/:id", getProject)
-	auth.GET("/project-error/:id", getProjectError)
-	auth.GET("/project-inputs/:id", getProjectInputs)
-	auth.GET("/project-components/:id", getProjectComponents)
-	auth.GET("/project-component-sequences/:id", getProjectComponentSequences)
+	viewer.GET("/projects", getProjects)
+	viewer.GET("/projects/:id", getProject)
+	viewer.GET("/project-error/:id", getProjectError)
+	viewer.GET("/project-inputs/:id", getProjectInputs)
+	viewer.GET("/project-components/:id", getProjectComponents)
+	viewer.GET("/project-component-sequences/:id", getProjectComponentSequences)
 
 	// Read-only component endpoints
-	auth.GET("/rulesets", getRulesets)
-	auth.GET("/rulesets/:id", getRuleset)
-	auth.GET("/inputs", getInputs)
-	auth.GET("/inputs/:id", getInput)
-	auth.GET("/outputs", getOutputs)
-	auth.GET("/outputs/:id", getOutput)
-	auth.GET("/plugins", getPlugins)
-	auth.GET("/plugins/:id", getPlugin)
-	auth.GET("/available-plugins", getPlugins) // Use same handler with different default params
+	viewer.GET("/rulesets", getRulesets)
+	viewer.GET("/rulesets/:id", getRuleset)
+	viewer.GET("/inputs", getInputs)
+	viewer.GET("/inputs/:id", getInput)
+	viewer.GET("/outputs", getOutputs)
+	viewer.GET("/outputs/:id", getOutput)
+	viewer.GET("/plugins", getPlugins)
+	viewer.GET("/plugins/:id", getPlugin)
+	viewer.GET("/available-plugins", getPlugins) // Use same handler with different default params
 
 	// Read-only testing endpoints
-	auth.GET("/connect-check/:type/:id", connectCheck)
-	auth.GET("/plugin-parameters/:id", GetPluginParameters)
-	auth.GET("/plugin-parameters", GetBatchPluginParameters)
-	auth.GET("/plugins/:id/usage", getPluginUsage)
+	viewer.GET("/connect-check/:type/:id", connectCheck)
+	viewer.GET("/plugin-parameters/:id", GetPluginParameters)
+	viewer.GET("/plugin-parameters", GetBatchPluginParameters)
+	viewer.GET("/plugins/:id/usage", getPluginUsage)
 
 	// Read-only configuration endpoints
-	auth.GET("/samplers/data", GetSamplerData)
-	auth.GET("/ruleset-fields/:id", GetRulesetFields)
-	auth.GET("/ruleset-fields", GetBatchRulesetFields)
+	viewer.GET("/samplers/data", GetSamplerData)
+	viewer.GET("/ruleset-fields/:id", GetRulesetFields)
+	viewer.GET("/ruleset-fields", GetBatchRulesetFields)
 
 	// Read-only analysis endpoints
-	auth.GET("/component-usage/:type/:id", GetComponentUsage)
-	auth.GET("/search-components", searchComponentsConfig)
-
-	// Block all write operations with helpful error messages
-	blockWriteOperation := func(c echo.Context) error {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error":   "Write operations not allowed on follower node",
-			"message": "Please use the leader node for write operations",
-			"leader":  common.Config.Leader,
-		})
-	}
+	viewer.GET("/component-usage/:type/:id", GetComponentUsage)
+	viewer.GET("/search-components", searchComponentsConfig)
 
-	// Block all POST, PUT, DELETE operations
-	e.POST("/*", blockWriteOperation)
-	e.PUT("/*", blockWriteOperation)
-	e.DELETE("/*", blockWriteOperation)
-	e.PATCH("/*", blockWriteOperation)
+	// Dead letter spools are local to each node, the leader forwards requests for this node here
+	viewer.GET("/outputs/:id/dead-letter", getDeadLetters)
+	operator.POST("/outputs/:id/dead-letter/replay", replayDeadLetters)
+	operator.DELETE("/outputs/:id/dead-letter", purgeDeadLetters)
+
+	// Forward all POST, PUT, DELETE operations to the leader, which checks the token and role
+	e.POST("/*", proxyToLeader)
+	e.PUT("/*", proxyToLeader)
+	e.DELETE("/*", proxyToLeader)
+	e.PATCH("/*", proxyToLeader)
+
+	followerServerMu.Lock()
+	if common.IsCurrentNodeLeader() {
+		// Promoted while starting up
+		followerServerMu.Unlock()
+		return nil
+	}
+	followerServer = e
+	followerServerMu.Unlock()
 
 	logger.Info("Starting follower API server on %s", listenAddr)
 
@@ -141,3 +143,50 @@ func ServerStartFollower(listenAddr string) error {
 	}
 	return nil
 }
+
+// StopFollowerServer shuts the follower API server down, when the node takes over as leader
+func StopFollowerServer() {
+	followerServerMu.Lock()
+	e := followerServer
+	followerServer = nil
+	followerServerMu.Unlock()
+
+	if e == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		logger.Warn("Failed to shut down follower API server", "error", err)
+	}
+}
+
+// proxyToLeader forwards a write operation to the API of the current leader
+func proxyToLeader(c echo.Context) error {
+	leader := cluster.GetLeaderInfo()
+	if leader == nil || leader.API == "" || leader.NodeID == common.GetNodeID() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"error":   "No leader available",
+			"message": "Write operations are forwarded to the leader node, please retry once a leader is elected",
+			"leader":  common.GetLeaderID(),
+		})
+	}
+	target, err := url.Parse(leader.API)
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]interface{}{
+			"error":  "Invalid leader API address: " + leader.API,
+			"leader": leader.NodeID,
+		})
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Warn("Failed to forward write operation to leader", "leader", leader.NodeID, "uri", r.RequestURI, "error", err)
+		w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"error":"Leader node not reachable"}`))
+	}
+	c.Response().Header().Set("X-Hub-Leader", leader.NodeID)
+	proxy.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/geoip"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// getGeoIPDatabases returns the GeoIP databases loaded on this node
+func getGeoIPDatabases(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id":   common.GetNodeID(),
+		"databases": geoip.Databases(),
+	})
+}
+
+// lookupGeoIP returns the geoInfo result of an IP
+// Query params: ip (required), lang (optional)
+func lookupGeoIP(c echo.Context) error {
+	ip := c.QueryParam("ip")
+	if ip == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ip is required"})
+	}
+	info, ok, err := geoip.Lookup(ip, c.QueryParam("lang"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusOK, map[string]interface{}{"found": false})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"found": true, "info": info})
+}
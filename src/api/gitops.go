@@ -0,0 +1,254 @@
+package api
+
+import (
+	"AgentSmith-HUB/cluster"
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/gitops"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/project"
+	"AgentSmith-HUB/rules_engine"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxWebhookBodySize limits the payload read to check a webhook signature
+const maxWebhookBodySize = 10 << 20
+
+// ApplyGitRevision applies the components of a GitOps revision that differ from the running ones.
+// Every added or modified component is verified first, rulesets against the test cases of the
+// revision, and a single failure rejects the whole revision. The changes are then applied like
+// pushed changes, which publishes the instructions to the followers.
+func ApplyGitRevision(rev *gitops.Revision, prune bool) (*gitops.Result, error) {
+	if err := common.RequireLeader(); err != nil {
+		return nil, err
+	}
+	rollbackMu.Lock()
+	defer rollbackMu.Unlock()
+
+	running := make(map[string]map[string]string, len(cluster.SnapshotComponentTypes))
+	for _, componentType := range cluster.SnapshotComponentTypes {
+		configs := make(map[string]string)
+		common.ForEachRawConfig(componentType, func(id, config string) bool {
+			configs[id] = config
+			return true
+		})
+		running[componentType] = configs
+	}
+
+	changes := gitops.ChangedComponents(rev, running, cluster.SnapshotComponentTypes, prune)
+	result := &gitops.Result{Commit: rev.Commit, Changes: changes}
+
+	// Validate everything before touching anything
+	for i := range changes {
+		ch := &changes[i]
+		if ch.Change == "removed" {
+			continue
+		}
+		content := rev.Components[ch.Type][ch.ID]
+		err := verifyComponentConfig(ch.Type, ch.ID, content)
+		if err == nil && ch.Type == "ruleset" {
+			err = verifyGitTestCases(ch.ID, content, rev.TestCases[ch.ID])
+		}
+		if err != nil {
+			ch.Error = err.Error()
+			result.Rejected = true
+		}
+	}
+	// Unchanged rulesets must still pass test cases changed in the repository
+	for id, content := range rev.Components["ruleset"] {
+		if testCasesChanged(id, rev.TestCases[id]) && !hasChange(changes, "ruleset", id) {
+			if err := verifyGitTestCases(id, content, rev.TestCases[id]); err != nil {
+				result.Changes = append(result.Changes, gitops.Change{Type: "ruleset", ID: id, Change: "test_cases", Error: err.Error()})
+				result.Rejected = true
+			}
+		}
+	}
+	if result.Rejected {
+		logger.Error("GitOps revision rejected, a component failed verification", "commit", rev.Commit, "author", rev.Author)
+		return result, nil
+	}
+
+	for id := range rev.Components["ruleset"] {
+		if !testCasesChanged(id, rev.TestCases[id]) {
+			continue
+		}
+		path := rules_engine.TestCasePath(id)
+		var err error
+		if rev.TestCases[id] == "" {
+			err = os.Remove(path)
+		} else {
+			err = os.WriteFile(path, []byte(rev.TestCases[id]), 0644)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			logger.Error("Failed to sync ruleset test cases", "ruleset", id, "error", err)
+		}
+	}
+
+	operator := "git:" + rev.Author
+	allAffectedProjects := make(map[string]bool)
+	for i := range changes {
+		ch := &changes[i]
+		if ch.Change == "removed" {
+			continue
+		}
+		affectedProjects, err := reloadComponentUnified(&ComponentReloadRequest{
+			Type:        ch.Type,
+			ID:          ch.ID,
+			NewContent:  rev.Components[ch.Type][ch.ID],
+			OldContent:  running[ch.Type][ch.ID],
+			Source:      SourceGitSync,
+			SkipVerify:  true,
+			WriteToFile: true,
+			Operator:    operator,
+		})
+		if err != nil {
+			logger.Error("Failed to apply component from git", "type", ch.Type, "id", ch.ID, "error", err)
+			ch.Error = err.Error()
+			continue
+		}
+		for _, id := range affectedProjects {
+			allAffectedProjects[id] = true
+		}
+	}
+	// Removals come last and in reverse order, so nothing removed is still referenced
+	for i := range changes {
+		ch := &changes[i]
+		if ch.Change != "removed" {
+			continue
+		}
+		if err := removeComponent(ch.Type, ch.ID, operator); err != nil {
+			logger.Error("Failed to remove component missing from git", "type", ch.Type, "id", ch.ID, "error", err)
+			ch.Error = err.Error()
+			continue
+		}
+		if ch.Type == "ruleset" {
+			if err := os.Remove(rules_engine.TestCasePath(ch.ID)); err != nil && !os.IsNotExist(err) {
+				logger.Error("Failed to remove ruleset test cases", "ruleset", ch.ID, "error", err)
+			}
+		}
+		if ch.Type == "project" {
+			delete(allAffectedProjects, ch.ID)
+		}
+	}
+
+	projectsToRestart := make([]string, 0, len(allAffectedProjects))
+	for id := range allAffectedProjects {
+		projectsToRestart = append(projectsToRestart, id)
+	}
+	sort.Strings(projectsToRestart)
+	if len(projectsToRestart) > 0 {
+		go func() {
+			for _, id := range projectsToRestart {
+				if p, ok := project.GetProject(id); ok {
+					if err := p.Restart(true, "git_sync"); err != nil {
+						logger.Error("Failed to restart project after git sync", "project_id", id, "error", err)
+					}
+				}
+			}
+		}()
+	}
+
+	logger.Info("GitOps revision applied", "commit", rev.Commit, "author", rev.Author, "changes", len(changes), "projects_to_restart", len(projectsToRestart))
+	return result, nil
+}
+
+// verifyGitTestCases runs the test cases of a revision against a ruleset, no test cases pass
+func verifyGitTestCases(id, content, testCases string) error {
+	cases, err := rules_engine.ParseTestCases([]byte(testCases))
+	if err != nil || len(cases) == 0 {
+		return err
+	}
+	report, err := rules_engine.RunTestCases(id, content, cases)
+	if err != nil {
+		return err
+	}
+	return report.Error()
+}
+
+// testCasesChanged reports whether the test case file of a ruleset differs from the repository
+func testCasesChanged(id, testCases string) bool {
+	data, err := os.ReadFile(rules_engine.TestCasePath(id))
+	if err != nil {
+		return testCases != ""
+	}
+	return strings.TrimSpace(string(data)) != strings.TrimSpace(testCases)
+}
+
+func hasChange(changes []gitops.Change, componentType, id string) bool {
+	for _, ch := range changes {
+		if ch.Type == componentType && ch.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// getGitOpsStatus returns the GitOps sync status
+func getGitOpsStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, gitops.GetStatus())
+}
+
+// syncGitOps pulls and applies the repository now
+func syncGitOps(c echo.Context) error {
+	if !gitops.Enabled() {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "GitOps is not enabled"})
+	}
+	logger.Info("GitOps sync requested", "operator", operatorFromContext(c))
+	status, err := gitops.Sync()
+	if err != nil {
+		return c.JSON(http.StatusConflict, map[string]interface{}{"error": err.Error(), "status": status})
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// gitopsWebhook triggers a sync on a push notification. The request is authenticated with the
+// webhook secret: a GitHub X-Hub-Signature-256 signature, or the secret itself in X-Gitlab-Token
+// or X-GitOps-Token.
+func gitopsWebhook(c echo.Context) error {
+	secret := common.Config.GitOps.WebhookSecret
+	if secret == "" || !gitops.Enabled() {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "GitOps webhook is not enabled"})
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBodySize))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+	}
+	if !validWebhookRequest(c.Request().Header, body, secret) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid webhook signature"})
+	}
+	// GitHub sends a ping when the webhook is created
+	if c.Request().Header.Get("X-GitHub-Event") == "ping" {
+		return c.JSON(http.StatusOK, map[string]string{"message": "pong"})
+	}
+
+	gitops.Trigger()
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "sync triggered"})
+}
+
+func validWebhookRequest(header http.Header, body []byte, secret string) bool {
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+	for _, name := range []string{"X-Gitlab-Token", "X-GitOps-Token"} {
+		if token := header.Get(name); token != "" {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+		}
+	}
+	return false
+}
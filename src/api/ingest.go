@@ -0,0 +1,60 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ingestHTTPInput receives pushed data for an http input.
+// Authentication is done by the input itself (token or HMAC), not by the hub token.
+func ingestHTTPInput(c echo.Context) error {
+	id := c.Param("id")
+
+	recv, ok := common.GetHTTPReceiver(id)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "http input not found or not running",
+		})
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, recv.MaxBodySize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "failed to read request body",
+		})
+	}
+	if int64(len(body)) > recv.MaxBodySize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error": "request body too large",
+		})
+	}
+
+	accepted, err := recv.Push(c.Request().Context(), c.Request().Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, common.ErrHTTPReceiverUnauthorized):
+			logger.Warn("HTTP input authentication failed", "input", id, "remote_ip", c.RealIP())
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": err.Error(),
+			})
+		case errors.Is(err, common.ErrHTTPReceiverBadRequest):
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		default:
+			return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+				"error":    err.Error(),
+				"accepted": accepted,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"accepted": accepted,
+	})
+}
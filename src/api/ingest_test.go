@@ -0,0 +1,55 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIngestHTTPInput(t *testing.T) {
+	msgChan := make(chan map[string]interface{}, 10)
+	recv, err := common.NewHTTPReceiver("ingest_test", common.HTTPAuthConfig{Type: common.HTTPAuthToken, Token: "t"}, 32, msgChan)
+	if err != nil {
+		t.Fatalf("NewHTTPReceiver failed: %v", err)
+	}
+	defer recv.Close()
+
+	tests := []struct {
+		name  string
+		id    string
+		token string
+		body  string
+		code  int
+	}{
+		{"accepted", "ingest_test", "Bearer t", `[{"a":1},{"a":2}]`, http.StatusAccepted},
+		{"unknown input", "missing", "t", `{}`, http.StatusNotFound},
+		{"wrong token", "ingest_test", "x", `{}`, http.StatusUnauthorized},
+		{"invalid body", "ingest_test", "t", `not json`, http.StatusBadRequest},
+		{"too large", "ingest_test", "t", `{"a":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/ingest/"+test.id, strings.NewReader(test.body))
+			req.Header.Set("Authorization", test.token)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(test.id)
+			if err := ingestHTTPInput(c); err != nil {
+				t.Fatalf("ingestHTTPInput returned %v", err)
+			}
+			if rec.Code != test.code {
+				t.Errorf("POST /ingest/%s = %d: %s, expected %d", test.id, rec.Code, rec.Body.String(), test.code)
+			}
+		})
+	}
+	if len(msgChan) != 2 {
+		t.Errorf("%d messages forwarded, expected 2", len(msgChan))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/input"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/lookup"
 	"AgentSmith-HUB/output"
 	"AgentSmith-HUB/plugin"
 	"AgentSmith-HUB/project"
@@ -224,6 +225,41 @@ func getLocalChangesCount(c echo.Context) error {
 		}
 	}
 
+	// Check lookups
+	lookupDir := filepath.Join(configRoot, "lookup")
+	if _, err := os.Stat(lookupDir); err == nil {
+		if err := filepath.WalkDir(lookupDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".yaml") {
+				return nil
+			}
+
+			filename := d.Name()
+			id := strings.TrimSuffix(filename, ".yaml")
+
+			memoryContent, exists := getLookupMemoryContent(id)
+			if !exists {
+				count++
+				return nil
+			}
+
+			fileContent, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+
+			if strings.TrimSpace(string(fileContent)) != strings.TrimSpace(memoryContent) {
+				count++
+			}
+
+			return nil
+		}); err != nil {
+			// Continue even if there's an error
+		}
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"count": count,
 	})
@@ -501,6 +537,53 @@ func getLocalChanges(c echo.Context) error {
 		}
 	}
 
+	// Check lookups
+	lookupDir := filepath.Join(configRoot, "lookup")
+	if _, err := os.Stat(lookupDir); err == nil {
+		if err := filepath.WalkDir(lookupDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".yaml") {
+				return nil
+			}
+
+			filename := d.Name()
+			id := strings.TrimSuffix(filename, ".yaml")
+
+			fileContent, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+
+			memoryContent, exists := getLookupMemoryContent(id)
+
+			if !exists || strings.TrimSpace(string(fileContent)) != strings.TrimSpace(memoryContent) {
+				changeType := "modified"
+				if !exists {
+					changeType = "new"
+				}
+
+				changes = append(changes, map[string]interface{}{
+					"type":           "lookup",
+					"id":             id,
+					"change_type":    changeType,
+					"file_path":      path,
+					"file_size":      len(fileContent),
+					"checksum":       fmt.Sprintf("%x", md5.Sum(fileContent)),
+					"local_content":  string(fileContent),
+					"memory_content": memoryContent,
+					"has_local":      true,
+					"has_memory":     exists,
+				})
+			}
+
+			return nil
+		}); err != nil {
+			// Continue
+		}
+	}
+
 	// Check for components that exist in memory but not in local files (deleted locally)
 	// configRoot is already defined above
 
@@ -607,6 +690,30 @@ func getLocalChanges(c echo.Context) error {
 		}
 	}
 
+	// Check for deleted lookups
+	lookup.ForEachLookup(func(id string, l *lookup.Lookup) bool {
+		lookupPath := filepath.Join(configRoot, "lookup", id+".yaml")
+		if _, err := os.Stat(lookupPath); os.IsNotExist(err) {
+			memoryContent := ""
+			if l.Config != nil {
+				memoryContent = l.Config.RawConfig
+			}
+			changes = append(changes, map[string]interface{}{
+				"type":           "lookup",
+				"id":             id,
+				"change_type":    "deleted",
+				"file_path":      lookupPath,
+				"file_size":      0,
+				"checksum":       "",
+				"local_content":  "",
+				"memory_content": memoryContent,
+				"has_local":      false,
+				"has_memory":     true,
+			})
+		}
+		return true
+	})
+
 	return c.JSON(http.StatusOK, changes)
 }
 
@@ -784,6 +891,34 @@ func loadLocalChanges(c echo.Context) error {
 		return nil
 	})
 
+	// Check lookups
+	lookupDir := filepath.Join(configRoot, "lookup")
+	filepath.WalkDir(lookupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".yaml") {
+			return nil
+		}
+
+		filename := d.Name()
+		id := strings.TrimSuffix(filename, ".yaml")
+
+		fileContent, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		memoryContent, exists := getLookupMemoryContent(id)
+
+		if !exists || strings.TrimSpace(string(fileContent)) != strings.TrimSpace(memoryContent) {
+			changes = append(changes, map[string]interface{}{
+				"type":         "lookup",
+				"id":           id,
+				"file_path":    path,
+				"file_content": string(fileContent),
+			})
+		}
+		return nil
+	})
+
 	// Load all changes directly into official memory (bypassing temporary storage)
 	results := make([]map[string]interface{}, 0)
 	successfullyLoaded := make([]map[string]string, 0)
@@ -797,15 +932,15 @@ func loadLocalChanges(c echo.Context) error {
 		message := "loaded successfully"
 
 		// Load directly into official component storage
-		err := loadComponentDirectly(componentType, id, content)
+		err := loadComponentDirectly(componentType, id, content, operatorFromContext(c))
 		if err != nil {
 			success = false
 			message = "failed to load component: " + err.Error()
 			// Record failed operation
-			RecordLocalPush(componentType, id, content, "failed", err.Error())
+			RecordLocalPush(componentType, id, content, "failed", err.Error(), operatorFromContext(c))
 		} else {
 			// Record successful operation
-			RecordLocalPush(componentType, id, content, "success", "")
+			RecordLocalPush(componentType, id, content, "success", "", operatorFromContext(c))
 
 			// Track successfully loaded components for project restart
 			if componentType != "project" {
@@ -876,6 +1011,8 @@ func loadSingleLocalChange(c echo.Context) error {
 		filePath = filepath.Join(configRoot, "project", req.ID+".yaml")
 	case "plugin":
 		filePath = filepath.Join(configRoot, "plugin", req.ID+".go")
+	case "lookup":
+		filePath = filepath.Join(configRoot, "lookup", req.ID+".yaml")
 	default:
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported component type"})
 	}
@@ -894,15 +1031,15 @@ func loadSingleLocalChange(c echo.Context) error {
 	content := string(fileContent)
 
 	// Load directly into official component storage
-	err = loadComponentDirectly(req.Type, req.ID, content)
+	err = loadComponentDirectly(req.Type, req.ID, content, operatorFromContext(c))
 	if err != nil {
 		// Record failed operation
-		RecordLocalPush(req.Type, req.ID, content, "failed", err.Error())
+		RecordLocalPush(req.Type, req.ID, content, "failed", err.Error(), operatorFromContext(c))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to load component: " + err.Error()})
 	}
 
 	// Record successful operation
-	RecordLocalPush(req.Type, req.ID, content, "success", "")
+	RecordLocalPush(req.Type, req.ID, content, "success", "", operatorFromContext(c))
 
 	affectedProjects := project.GetAffectedProjects(req.Type, req.ID)
 
@@ -930,7 +1067,7 @@ func loadSingleLocalChange(c echo.Context) error {
 
 // loadComponentDirectly loads a component directly into official storage using unified reload logic
 // This bypasses the temporary file system and *New mappings
-func loadComponentDirectly(componentType, id, content string) error {
+func loadComponentDirectly(componentType, id, content, operator string) error {
 	// Use unified reload logic with local file source
 	_, err := reloadComponentUnified(&ComponentReloadRequest{
 		Type:        componentType,
@@ -940,6 +1077,18 @@ func loadComponentDirectly(componentType, id, content string) error {
 		Source:      SourceLocalFile,
 		SkipVerify:  false, // Local file changes should be verified
 		WriteToFile: false, // Local file changes read from file, don't write
+		Operator:    operator,
 	})
 	return err
 }
+
+// getLookupMemoryContent returns the lookup content in memory, the pending version if there is one
+func getLookupMemoryContent(id string) (string, bool) {
+	if tempContent, existsInTemp := lookup.GetLookupNew(id); existsInTemp {
+		return tempContent, true
+	}
+	if l, exists := lookup.Get(id); exists && l.Config != nil {
+		return l.Config.RawConfig, true
+	}
+	return "", false
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/lookup"
+	"AgentSmith-HUB/project"
+	"AgentSmith-HUB/rules_engine"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+)
+
+// findRulesetsUsingLookup returns rulesets that reference a lookup in a check or an append
+func findRulesetsUsingLookup(id string) []string {
+	quoted := regexp.QuoteMeta(id)
+	checkRef := regexp.MustCompile(`type="(?:NOT_)?IN_LOOKUP"[^>]*>\s*` + quoted + `\s*<`)
+	appendRef := regexp.MustCompile(`lookup="` + quoted + `"`)
+
+	rulesets := make([]string, 0)
+	project.ForEachRuleset(func(rulesetID string, rs *rules_engine.Ruleset) bool {
+		if checkRef.MatchString(rs.RawConfig) || appendRef.MatchString(rs.RawConfig) {
+			rulesets = append(rulesets, rulesetID)
+		}
+		return true
+	})
+	sort.Strings(rulesets)
+	return rulesets
+}
+
+func getLookups(c echo.Context) error {
+	lookups := make([]map[string]interface{}, 0)
+	processedIDs := make(map[string]bool)
+
+	lookup.ForEachLookup(func(id string, l *lookup.Lookup) bool {
+		tempRaw, hasTemp := lookup.GetLookupNew(id)
+
+		rawConfig := ""
+		if l.Config != nil {
+			rawConfig = l.Config.RawConfig
+		}
+		if hasTemp {
+			rawConfig = tempRaw
+		}
+
+		usedByRulesets := findRulesetsUsingLookup(id)
+		hits, misses := l.Stats()
+		lookupData := map[string]interface{}{
+			"id":               id,
+			"hasTemp":          hasTemp,
+			"raw":              rawConfig,
+			"rows":             l.RowCount(),
+			"columns":          l.Columns(),
+			"hits":             hits,
+			"misses":           misses,
+			"used_by_rulesets": usedByRulesets,
+			"ruleset_count":    len(usedByRulesets),
+			"status":           string(l.Status),
+		}
+		if l.Config != nil {
+			lookupData["format"] = l.Config.Format
+			lookupData["match"] = l.Config.Match
+		}
+
+		// Include error information if component has errors
+		if l.Status == common.StatusError && l.Err != nil {
+			lookupData["errorMessage"] = l.Err.Error()
+		}
+
+		if l.Path != "" {
+			lookupData["path"] = l.Path
+		}
+
+		lookups = append(lookups, lookupData)
+		processedIDs[id] = true
+		return true
+	})
+
+	// Add components that only exist in temporary files
+	for id, rawConfig := range lookup.GetAllLookupsNew() {
+		if !processedIDs[id] {
+			lookups = append(lookups, map[string]interface{}{
+				"id":               id,
+				"hasTemp":          true,
+				"raw":              rawConfig,
+				"used_by_rulesets": []string{}, // No rulesets can use temp lookups
+				"ruleset_count":    0,
+			})
+		}
+	}
+
+	sort.Slice(lookups, func(i, j int) bool {
+		return lookups[i]["id"].(string) < lookups[j]["id"].(string)
+	})
+	return c.JSON(http.StatusOK, lookups)
+}
+
+func getLookup(c echo.Context) error {
+	id := c.Param("id")
+
+	if raw, ok := lookup.GetLookupNew(id); ok {
+		tempPath, _ := GetComponentPath("lookup", id, true)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"id":   id,
+			"raw":  raw,
+			"path": tempPath,
+		})
+	}
+
+	if l, ok := lookup.Get(id); ok && l.Config != nil {
+		formalPath, _ := GetComponentPath("lookup", id, false)
+		hits, misses := l.Stats()
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"id":               id,
+			"raw":              l.Config.RawConfig,
+			"path":             formalPath,
+			"format":           l.Config.Format,
+			"match":            l.Config.Match,
+			"rows":             l.RowCount(),
+			"columns":          l.Columns(),
+			"hits":             hits,
+			"misses":           misses,
+			"used_by_rulesets": findRulesetsUsingLookup(id),
+		})
+	}
+
+	return c.JSON(http.StatusNotFound, map[string]string{"error": "lookup not found"})
+}
+
+func cancelLookupUpgrade(c echo.Context) error {
+	id := c.Param("id")
+
+	// Use safe accessor for memory operations
+	lookup.DeleteLookupNew(id)
+
+	// Delete temp file if exists
+	tempPath, tempExists := GetComponentPath("lookup", id, true)
+	if tempExists {
+		_ = os.Remove(tempPath)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "lookup upgrade cancelled"})
+}
@@ -275,8 +275,8 @@ func handleMCPPost(c echo.Context) error {
 		})
 	}
 
-	if token != common.Config.Token {
-		logger.Error("MCP request with invalid token")
+	if _, err := authenticateToken(token); err != nil {
+		logger.Error("MCP request with invalid token", "error", err)
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": "Authentication failed",
 		})
@@ -375,10 +375,10 @@ func getMCPBaseURL(c echo.Context) string {
 	return fmt.Sprintf("%s://%s", scheme, host)
 }
 
-// handleMCPJSONRPC handles a JSON-RPC request using the StandardMCPServer
-func handleMCPJSONRPC(requestData []byte) ([]byte, error) {
-	// Use the StandardMCPServer to handle the request
-	return mcpServer.HandleJSONRPCRequest(requestData)
+// handleMCPJSONRPC handles a JSON-RPC request using the StandardMCPServer,
+// making API calls with the session's own token
+func handleMCPJSONRPC(requestData []byte, baseURL string, session *MCPSession) ([]byte, error) {
+	return mcpServer.HandleJSONRPCRequestAs(requestData, baseURL, session.Token)
 }
 
 // handleSingleMessage processes a single JSON-RPC message
@@ -400,12 +400,9 @@ func handleSingleMessage(c echo.Context, messageData map[string]interface{}, ses
 		})
 	}
 
-	// Update MCP server configuration
-	baseURL := getMCPBaseURL(c)
-	mcpServer.UpdateConfig(baseURL, session.Token)
-
 	// Handle the MCP message using StandardMCPServer
-	responseBytes, err := handleMCPJSONRPC(messageBytes)
+	baseURL := getMCPBaseURL(c)
+	responseBytes, err := handleMCPJSONRPC(messageBytes, baseURL, session)
 	if err != nil {
 		logger.Error("MCP server error", "error", err)
 		// Return error response in JSON-RPC format
@@ -438,9 +435,7 @@ func handleSingleMessage(c echo.Context, messageData map[string]interface{}, ses
 func handleBatchMessages(c echo.Context, messagesData []interface{}, session *MCPSession) error {
 	responses := make([]*common.MCPMessage, 0, len(messagesData))
 
-	// Update MCP server configuration
 	baseURL := getMCPBaseURL(c)
-	mcpServer.UpdateConfig(baseURL, session.Token)
 
 	for _, messageData := range messagesData {
 		messageMap, ok := messageData.(map[string]interface{})
@@ -507,7 +502,7 @@ func handleBatchMessages(c echo.Context, messagesData []interface{}, session *MC
 			continue
 		}
 
-		responseBytes, err := handleMCPJSONRPC(messageBytes)
+		responseBytes, err := handleMCPJSONRPC(messageBytes, baseURL, session)
 		if err != nil {
 			logger.Error("MCP server error in batch", "error", err)
 			errorResponse := &common.MCPMessage{
@@ -562,8 +557,8 @@ func handleMCPSSE(c echo.Context) error {
 		})
 	}
 
-	if token != common.Config.Token {
-		logger.Error("MCP SSE request with invalid token")
+	if _, err := authenticateToken(token); err != nil {
+		logger.Error("MCP SSE request with invalid token", "error", err)
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": "Authentication failed",
 		})
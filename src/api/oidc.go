@@ -0,0 +1,682 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// OpenID Connect authorization code flow:
+//
+//	GET /auth/oidc/login     redirect the browser to the IdP
+//	GET /auth/oidc/callback  exchange the code, verify the ID token, map groups to a role
+//	                         and redirect to ui_redirect with #sso_token=<session token>
+//
+// Session tokens are accepted by requireRole like any other token and expire after session_ttl.
+
+const (
+	oidcStateKeyPrefix = "cluster:oidc:state:"
+	oidcStateTTL       = 600 // seconds the user has to complete the IdP login
+	oidcStateCookie    = "hub_oidc_state"
+
+	rbacSessionKeyPrefix = "cluster:rbac:session:"
+	sessionTokenPrefix   = "hubs_"
+
+	UserKindSSO = "sso"
+
+	defaultOIDCSessionTTL = 12 * time.Hour
+	oidcClockSkew         = time.Minute
+	oidcJWKSRefreshMin    = time.Minute
+)
+
+var defaultOIDCScopes = []string{"openid", "profile", "email", "groups"}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	client      *http.Client
+}
+
+var oidcClient = &oidcProvider{
+	client: &http.Client{Timeout: 10 * time.Second},
+}
+
+// oidcSession is stored in Redis for each SSO login
+type oidcSession struct {
+	Identity
+	Groups    []string  `json:"groups,omitempty"`
+	Managed   bool      `json:"managed,omitempty"` // the user had a record at login, deleting it ends the session
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func oidcEnabled() bool {
+	cfg := common.Config.OIDC
+	return cfg.Enabled && cfg.Issuer != "" && cfg.ClientID != "" && cfg.RedirectURL != ""
+}
+
+func oidcSessionTTL() time.Duration {
+	if ttl := common.Config.OIDC.SessionTTL; ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			return d
+		}
+		logger.Warn("Invalid oidc.session_ttl, using default", "value", ttl, "default", defaultOIDCSessionTTL)
+	}
+	return defaultOIDCSessionTTL
+}
+
+// getDiscovery loads the provider metadata once, failed loads are retried on the next login
+func (p *oidcProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(common.Config.OIDC.Issuer, "/")
+	var d oidcDiscovery
+	if err := p.getJSON(issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC discovery document: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC issuer mismatch: configured %s, provider reports %s", issuer, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing authorization, token or jwks endpoint")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// getKey returns the signing key for kid, refreshing the JWKS when the key is unknown (key rotation)
+func (p *oidcProvider) getKey(jwksURI, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < oidcJWKSRefreshMin {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	p.keysFetched = time.Now()
+	if err := p.getJSON(jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, raw := range jwks.Keys {
+		id, key, err := parseJWK(raw)
+		if err != nil {
+			logger.Warn("Skipping unsupported OIDC signing key", "error", err)
+			continue
+		}
+		keys[id] = key
+	}
+	p.keys = keys
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+// lookupKey finds a cached key, a token without kid is accepted when the provider has a single key
+func (p *oidcProvider) lookupKey(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+func (p *oidcProvider) getJSON(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// exchangeCode redeems an authorization code and returns the raw ID token
+func (p *oidcProvider) exchangeCode(tokenEndpoint, code, codeVerifier string) (string, error) {
+	cfg := common.Config.OIDC
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return "", errors.New("token response has no id_token, is the openid scope allowed?")
+	}
+	return tokenResp.IDToken, nil
+}
+
+// verifyIDToken checks signature, issuer, audience, expiry and nonce and returns the claims
+func (p *oidcProvider) verifyIDToken(d *oidcDiscovery, rawToken, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid ID token header: %w", err)
+	}
+	key, err := p.getKey(d.JWKSURI, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid ID token signature encoding")
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(d.Issuer, "/") {
+		return nil, fmt.Errorf("ID token issuer mismatch: %s", iss)
+	}
+	if !claimContains(claims["aud"], common.Config.OIDC.ClientID) {
+		return nil, errors.New("ID token audience does not include client_id")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Now().Add(-oidcClockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("ID token expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signingInput string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported ID token algorithm: %s", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, sig); err != nil {
+			return errors.New("invalid ID token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %s does not match EC key", alg)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid ID token signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid ID token signature")
+		}
+	default:
+		return errors.New("unsupported signing key type")
+	}
+	return nil
+}
+
+// parseJWK converts an RSA or EC JSON Web Key into a public key
+func parseJWK(raw json.RawMessage) (string, crypto.PublicKey, error) {
+	var jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, fmt.Errorf("key %s is not a signing key", jwk.Kid)
+	}
+
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err1 := decode(jwk.N)
+		e, err2 := decode(jwk.E)
+		if err1 != nil || err2 != nil || !e.IsInt64() {
+			return "", nil, fmt.Errorf("invalid RSA key %s", jwk.Kid)
+		}
+		return jwk.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return "", nil, fmt.Errorf("unsupported EC curve %s", jwk.Crv)
+		}
+		x, err1 := decode(jwk.X)
+		y, err2 := decode(jwk.Y)
+		if err1 != nil || err2 != nil {
+			return "", nil, fmt.Errorf("invalid EC key %s", jwk.Kid)
+		}
+		return jwk.Kid, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+// claimContains reports whether a string or string array claim contains value
+func claimContains(claim interface{}, value string) bool {
+	for _, v := range claimStrings(claim) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// oidcIdentity extracts the user name and maps the group claim to the highest configured role
+func oidcIdentity(claims map[string]interface{}) (*oidcSession, error) {
+	cfg := common.Config.OIDC
+
+	var name string
+	usernameClaims := []string{"preferred_username", "email", "sub"}
+	if cfg.UsernameClaim != "" {
+		usernameClaims = append([]string{cfg.UsernameClaim}, usernameClaims...)
+	}
+	for _, claim := range usernameClaims {
+		if v, ok := claims[claim].(string); ok && v != "" {
+			name = v
+			break
+		}
+	}
+	if name == "" {
+		return nil, errors.New("ID token has no user name claim")
+	}
+
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	groups := claimStrings(claims[groupsClaim])
+
+	role := cfg.DefaultRole
+	for _, group := range groups {
+		if mapped, ok := cfg.RoleMapping[group]; ok && isValidRole(mapped) && roleLevels[mapped] > roleLevels[role] {
+			role = mapped
+		}
+	}
+	if !isValidRole(role) {
+		return nil, fmt.Errorf("user %s has no group mapped to a hub role", name)
+	}
+
+	return &oidcSession{
+		Identity:  Identity{User: name, Role: role, Kind: UserKindSSO},
+		Groups:    groups,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// createSSOSession stores an SSO session and returns its token
+func createSSOSession(session *oidcSession) (string, error) {
+	random, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	token := sessionTokenPrefix + random
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	if _, err := common.RedisSet(rbacSessionKeyPrefix+hashAPIToken(token), string(data), int(oidcSessionTTL().Seconds())); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// lookupSSOSession resolves a session token, expired sessions are removed by Redis TTL.
+// The user is checked on every lookup, so disabling or deleting a user ends its sessions.
+func lookupSSOSession(token string) (*Identity, error) {
+	key := rbacSessionKeyPrefix + hashAPIToken(token)
+	raw, err := common.RedisGet(key)
+	if err != nil || raw == "" {
+		return nil, errSessionExpired
+	}
+	var session oidcSession
+	if err := json.Unmarshal([]byte(raw), &session); err != nil {
+		return nil, errSessionExpired
+	}
+
+	// SSO users only have a record once an admin created or changed them
+	user, err := getUser(session.User)
+	switch {
+	case errors.Is(err, errUserNotFound):
+		if session.Managed {
+			_ = common.RedisDel(key)
+			return nil, errSessionExpired
+		}
+	case err != nil:
+		return nil, errTokenInvalid
+	case user.Disabled:
+		_ = common.RedisDel(key)
+		return nil, errUserDisabled
+	}
+	return &session.Identity, nil
+}
+
+// getOIDCConfig tells the web UI whether SSO login is available
+func getOIDCConfig(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"enabled":   oidcEnabled(),
+		"login_url": "/auth/oidc/login",
+	})
+}
+
+// oidcLogin redirects the browser to the IdP authorization endpoint
+func oidcLogin(c echo.Context) error {
+	if !oidcEnabled() {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "OIDC login is not configured",
+		})
+	}
+	d, err := oidcClient.getDiscovery()
+	if err != nil {
+		logger.Error("OIDC discovery failed", "error", err)
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	state, err1 := randomHex(16)
+	nonce, err2 := randomHex(16)
+	verifier, err3 := randomHex(32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to generate login state",
+		})
+	}
+	pending, _ := json.Marshal(oidcPendingLogin{Nonce: nonce, CodeVerifier: verifier})
+	if _, err := common.RedisSet(oidcStateKeyPrefix+state, string(pending), oidcStateTTL); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to store login state: " + err.Error(),
+		})
+	}
+
+	cfg := common.Config.OIDC
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOIDCScopes
+	}
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	// Bind the state to this browser, a callback carrying a state started elsewhere is rejected
+	setOIDCStateCookie(c, state, oidcStateTTL)
+	return c.Redirect(http.StatusFound, d.AuthorizationEndpoint+sep+params.Encode())
+}
+
+// oidcCallback completes the login and hands the session token to the web UI in the URL fragment
+func oidcCallback(c echo.Context) error {
+	if !oidcEnabled() {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "OIDC login is not configured",
+		})
+	}
+
+	if idpErr := c.QueryParam("error"); idpErr != "" {
+		return oidcRedirectUI(c, "sso_error", idpErr+": "+c.QueryParam("error_description"))
+	}
+
+	state := c.QueryParam("state")
+	code := c.QueryParam("code")
+	if state == "" || code == "" {
+		return oidcRedirectUI(c, "sso_error", "missing state or code")
+	}
+	cookie, err := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return oidcRedirectUI(c, "sso_error", "login was not started in this browser, please try again")
+	}
+	// A state can only be used once
+	raw, err := common.RedisGetDel(oidcStateKeyPrefix + state)
+	if err != nil || raw == "" {
+		return oidcRedirectUI(c, "sso_error", "login expired or invalid state, please try again")
+	}
+	var pending oidcPendingLogin
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil || pending.Nonce == "" || pending.CodeVerifier == "" {
+		return oidcRedirectUI(c, "sso_error", "login expired or invalid state, please try again")
+	}
+
+	d, err := oidcClient.getDiscovery()
+	if err != nil {
+		logger.Error("OIDC discovery failed", "error", err)
+		return oidcRedirectUI(c, "sso_error", "identity provider unavailable")
+	}
+	rawIDToken, err := oidcClient.exchangeCode(d.TokenEndpoint, code, pending.CodeVerifier)
+	if err != nil {
+		logger.Error("OIDC code exchange failed", "error", err)
+		return oidcRedirectUI(c, "sso_error", "code exchange failed")
+	}
+	claims, err := oidcClient.verifyIDToken(d, rawIDToken, pending.Nonce)
+	if err != nil {
+		logger.Error("OIDC ID token verification failed", "error", err)
+		return oidcRedirectUI(c, "sso_error", "ID token verification failed")
+	}
+	session, err := oidcIdentity(claims)
+	if err != nil {
+		logger.Warn("OIDC login denied", "error", err)
+		return oidcRedirectUI(c, "sso_error", err.Error())
+	}
+	if user, err := getUser(session.User); err == nil {
+		if user.Disabled {
+			logger.Warn("OIDC login denied for disabled user", "user", session.User)
+			return oidcRedirectUI(c, "sso_error", "user disabled")
+		}
+		session.Managed = true
+	}
+
+	token, err := createSSOSession(session)
+	if err != nil {
+		logger.Error("Failed to create SSO session", "user", session.User, "error", err)
+		return oidcRedirectUI(c, "sso_error", "failed to create session")
+	}
+
+	logger.Info("SSO login", "user", session.User, "role", session.Role, "groups", session.Groups)
+	return oidcRedirectUI(c, "sso_token", token)
+}
+
+// oidcPendingLogin is what oidcLogin keeps under the state until the callback
+type oidcPendingLogin struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// pkceChallenge derives the S256 code challenge (RFC 7636) sent with the authorization request
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// setOIDCStateCookie stores the login state in the browser, a negative maxAge removes it.
+// The cookie is scoped to the directory of the callback as the browser sees it, which may sit behind a proxy prefix.
+func setOIDCStateCookie(c echo.Context, state string, maxAge int) {
+	cookiePath := "/"
+	if u, err := url.Parse(common.Config.OIDC.RedirectURL); err == nil && strings.HasPrefix(u.Path, "/") {
+		cookiePath = path.Dir(u.Path)
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     cookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcRedirectUI sends the browser back to the web UI, the value travels in the fragment so it never reaches server logs
+func oidcRedirectUI(c echo.Context, key, value string) error {
+	target := common.Config.OIDC.UIRedirect
+	if target == "" {
+		target = "/"
+	}
+	return c.Redirect(http.StatusFound, target+"#"+url.Values{key: {value}}.Encode())
+}
+
+// logout ends an SSO session, per-user API tokens are revoked through the user API instead
+func logout(c echo.Context) error {
+	token := c.Request().Header.Get("token")
+	if strings.HasPrefix(token, sessionTokenPrefix) {
+		if err := common.RedisDel(rbacSessionKeyPrefix + hashAPIToken(token)); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "failed to end session: " + err.Error(),
+			})
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "logged out",
+	})
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
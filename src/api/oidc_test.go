@@ -0,0 +1,525 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// mockIdP is a minimal OpenID provider: discovery, JWKS and a token endpoint that returns
+// the ID token prepared for a code once the PKCE verifier matches its challenge
+type mockIdP struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	keys         map[string]crypto.Signer // published signing keys by kid
+	codes        map[string]issuedCode    // code -> ID token and PKCE challenge
+	jwksRequests int
+}
+
+type issuedCode struct {
+	idToken   string
+	challenge string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	idp := &mockIdP{keys: make(map[string]crypto.Signer), codes: make(map[string]issuedCode)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksRequests++
+		var keys []map[string]string
+		for kid, key := range idp.keys {
+			keys = append(keys, publicJWK(kid, key.Public()))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "hub" || secret != "secret" || r.FormValue("redirect_uri") != "https://hub/auth/oidc/callback" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		idp.mu.Lock()
+		issued, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		idp.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != issued.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "x", "id_token": issued.idToken})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) addKey(t *testing.T, kid string, ec bool) crypto.Signer {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	if ec {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	idp.mu.Lock()
+	idp.keys[kid] = key
+	idp.mu.Unlock()
+	return key
+}
+
+func (idp *mockIdP) removeKey(kid string) {
+	idp.mu.Lock()
+	delete(idp.keys, kid)
+	idp.mu.Unlock()
+}
+
+// issueCode prepares the ID token returned for code, bound to the PKCE challenge of the login
+func (idp *mockIdP) issueCode(code, idToken string, login loginAttempt) {
+	idp.mu.Lock()
+	idp.codes[code] = issuedCode{idToken: idToken, challenge: login.challenge}
+	idp.mu.Unlock()
+}
+
+func (idp *mockIdP) claims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                idp.server.URL,
+		"aud":                []string{"hub", "other"},
+		"sub":                "u-1",
+		"preferred_username": "alice",
+		"groups":             []string{"secops"},
+		"nonce":              nonce,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func publicJWK(kid string, pub crypto.PublicKey) map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": enc(k.N.Bytes()), "e": enc(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": enc(k.X.FillBytes(make([]byte, 32))), "y": enc(k.Y.FillBytes(make([]byte, 32)))}
+	}
+	return nil
+}
+
+func signJWT(t *testing.T, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// setupOIDCTest points the hub at the mock IdP and a fresh in-memory Redis
+func setupOIDCTest(t *testing.T) *mockIdP {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	idp := newMockIdP(t)
+
+	prevConfig, prevClient := common.Config, oidcClient
+	common.Config = &common.HubConfig{OIDC: common.OIDCConfig{
+		Enabled:      true,
+		Issuer:       idp.server.URL,
+		ClientID:     "hub",
+		ClientSecret: "secret",
+		RedirectURL:  "https://hub/auth/oidc/callback",
+		RoleMapping:  map[string]string{"secops": RoleOperator, "admins": RoleAdmin},
+		UIRedirect:   "/ui/",
+	}}
+	oidcClient = &oidcProvider{client: idp.server.Client()}
+	t.Cleanup(func() { common.Config, oidcClient = prevConfig, prevClient })
+	return idp
+}
+
+// loginAttempt is what the browser holds after /auth/oidc/login
+type loginAttempt struct {
+	state     string
+	nonce     string
+	challenge string
+	cookie    *http.Cookie
+}
+
+// startLogin calls /auth/oidc/login and returns the authorization redirect parameters and the state cookie
+func startLogin(t *testing.T, idp *mockIdP) loginAttempt {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil), rec)
+	if err := oidcLogin(c); err != nil {
+		t.Fatalf("oidcLogin failed: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("oidcLogin status = %d, expected %d: %s", rec.Code, http.StatusFound, rec.Body.String())
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), idp.server.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization redirect %q", rec.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("client_id") != "hub" || q.Get("response_type") != "code" || q.Get("redirect_uri") != "https://hub/auth/oidc/callback" {
+		t.Errorf("unexpected authorization parameters %v", q)
+	}
+	if q.Get("state") == "" || q.Get("nonce") == "" {
+		t.Fatalf("authorization redirect without state or nonce: %v", q)
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("authorization redirect without S256 code challenge: %v", q)
+	}
+
+	login := loginAttempt{state: q.Get("state"), nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			login.cookie = cookie
+		}
+	}
+	if login.cookie == nil || login.cookie.Value != login.state || login.cookie.Path != "/auth/oidc" ||
+		!login.cookie.HttpOnly || login.cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("oidcLogin state cookie = %+v, expected an HttpOnly SameSite=Lax cookie for the callback holding the state", login.cookie)
+	}
+	return login
+}
+
+// callback calls /auth/oidc/callback with the state cookie, if any, and returns the key and value handed to the web UI
+func callback(t *testing.T, query string, cookie *http.Cookie) (string, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	c := echo.New().NewContext(req, rec)
+	if err := oidcCallback(c); err != nil {
+		t.Fatalf("oidcCallback failed: %v", err)
+	}
+	loc := rec.Header().Get("Location")
+	if rec.Code != http.StatusFound || !strings.HasPrefix(loc, "/ui/#") {
+		t.Fatalf("oidcCallback = %d %q, expected a redirect to the UI", rec.Code, loc)
+	}
+	fragment, _ := url.ParseQuery(strings.TrimPrefix(loc, "/ui/#"))
+	for _, key := range []string{"sso_token", "sso_error"} {
+		if v := fragment.Get(key); v != "" {
+			return key, v
+		}
+	}
+	t.Fatalf("callback redirect %q has neither sso_token nor sso_error", loc)
+	return "", ""
+}
+
+func login(t *testing.T, idp *mockIdP, sign func(nonce string) string) (string, string) {
+	t.Helper()
+	login := startLogin(t, idp)
+	idp.issueCode("code-"+login.state, sign(login.nonce), login)
+	return callback(t, url.Values{"state": {login.state}, "code": {"code-" + login.state}}.Encode(), login.cookie)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := setupOIDCTest(t)
+	key := idp.addKey(t, "k1", false)
+
+	kind, token := login(t, idp, func(nonce string) string {
+		return signJWT(t, "k1", key, idp.claims(nonce))
+	})
+	if kind != "sso_token" || !strings.HasPrefix(token, sessionTokenPrefix) {
+		t.Fatalf("login returned %s=%q, expected a session token", kind, token)
+	}
+
+	identity, err := authenticateToken(token)
+	if err != nil {
+		t.Fatalf("authenticateToken(session) failed: %v", err)
+	}
+	expected := Identity{User: "alice", Role: RoleOperator, Kind: UserKindSSO}
+	if *identity != expected {
+		t.Errorf("identity = %+v, expected %+v", *identity, expected)
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(c map[string]interface{})
+		key    string // kid used to sign, "forged" signs with an unpublished key using kid k1
+	}{
+		{"nonce mismatch", func(c map[string]interface{}) { c["nonce"] = "other" }, "k1"},
+		{"missing nonce", func(c map[string]interface{}) { delete(c, "nonce") }, "k1"},
+		{"audience", func(c map[string]interface{}) { c["aud"] = "someone-else" }, "k1"},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() }, "k1"},
+		{"missing exp", func(c map[string]interface{}) { delete(c, "exp") }, "k1"},
+		{"issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example" }, "k1"},
+		{"forged signature", nil, "forged"},
+		{"no mapped group", func(c map[string]interface{}) { c["groups"] = []string{"sales"} }, "k1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp := setupOIDCTest(t)
+			key := idp.addKey(t, "k1", false)
+			signer := key
+			if test.key == "forged" {
+				forged, _ := rsa.GenerateKey(rand.Reader, 2048)
+				signer = forged
+			}
+
+			kind, value := login(t, idp, func(nonce string) string {
+				claims := idp.claims(nonce)
+				if test.claims != nil {
+					test.claims(claims)
+				}
+				return signJWT(t, "k1", signer, claims)
+			})
+			if kind != "sso_error" {
+				t.Errorf("login returned %s=%q, expected sso_error", kind, value)
+			}
+		})
+	}
+}
+
+func TestOIDCExpiryClockSkew(t *testing.T) {
+	idp := setupOIDCTest(t)
+	key := idp.addKey(t, "k1", false)
+
+	// Within the allowed clock skew
+	kind, value := login(t, idp, func(nonce string) string {
+		claims := idp.claims(nonce)
+		claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+		return signJWT(t, "k1", key, claims)
+	})
+	if kind != "sso_token" {
+		t.Errorf("token expired 30s ago returned %s=%q, expected it within clock skew", kind, value)
+	}
+}
+
+func TestOIDCStateIsSingleUse(t *testing.T) {
+	idp := setupOIDCTest(t)
+	key := idp.addKey(t, "k1", false)
+
+	login := startLogin(t, idp)
+	idp.issueCode("c1", signJWT(t, "k1", key, idp.claims(login.nonce)), login)
+	if kind, value := callback(t, "state="+login.state+"&code=c1", login.cookie); kind != "sso_token" {
+		t.Fatalf("first callback returned %s=%q", kind, value)
+	}
+
+	idp.issueCode("c2", signJWT(t, "k1", key, idp.claims(login.nonce)), login)
+	if kind, _ := callback(t, "state="+login.state+"&code=c2", login.cookie); kind != "sso_error" {
+		t.Errorf("replayed state was accepted")
+	}
+	unknown := &http.Cookie{Name: oidcStateCookie, Value: "unknown"}
+	if kind, _ := callback(t, "state=unknown&code=c2", unknown); kind != "sso_error" {
+		t.Errorf("unknown state was accepted")
+	}
+	if kind, _ := callback(t, "error=access_denied&error_description=denied", nil); kind != "sso_error" {
+		t.Errorf("IdP error was not passed to the UI")
+	}
+}
+
+func TestOIDCCallbackBoundToBrowser(t *testing.T) {
+	idp := setupOIDCTest(t)
+	key := idp.addKey(t, "k1", false)
+
+	victim := startLogin(t, idp)
+	attacker := startLogin(t, idp)
+	tests := []struct {
+		name   string
+		state  string
+		cookie *http.Cookie
+		code   string
+		login  loginAttempt // login whose PKCE challenge the code is bound to
+	}{
+		{"no state cookie", victim.state, nil, "c1", victim},
+		{"cookie of another login", attacker.state, victim.cookie, "c2", attacker},
+		{"code bound to another verifier", victim.state, victim.cookie, "c3", attacker},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp.issueCode(test.code, signJWT(t, "k1", key, idp.claims(test.login.nonce)), test.login)
+			if kind, value := callback(t, "state="+test.state+"&code="+test.code, test.cookie); kind != "sso_error" {
+				t.Errorf("callback returned %s=%q, expected sso_error", kind, value)
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	idp := setupOIDCTest(t)
+	oldKey := idp.addKey(t, "k1", false)
+
+	if kind, value := login(t, idp, func(nonce string) string {
+		return signJWT(t, "k1", oldKey, idp.claims(nonce))
+	}); kind != "sso_token" {
+		t.Fatalf("login with k1 returned %s=%q", kind, value)
+	}
+
+	// The IdP rotates to an EC key, tokens signed with it reference an unknown kid
+	newKey := idp.addKey(t, "k2", true)
+	idp.removeKey("k1")
+
+	// Unknown kids do not refetch the JWKS more than once a minute
+	if kind, _ := login(t, idp, func(nonce string) string {
+		return signJWT(t, "k2", newKey, idp.claims(nonce))
+	}); kind != "sso_error" {
+		t.Fatalf("unknown kid accepted before the JWKS refresh interval")
+	}
+	if idp.jwksRequests != 1 {
+		t.Fatalf("JWKS fetched %d times, expected 1", idp.jwksRequests)
+	}
+
+	oidcClient.mu.Lock()
+	oidcClient.keysFetched = time.Now().Add(-2 * oidcJWKSRefreshMin)
+	oidcClient.mu.Unlock()
+
+	if kind, value := login(t, idp, func(nonce string) string {
+		return signJWT(t, "k2", newKey, idp.claims(nonce))
+	}); kind != "sso_token" {
+		t.Fatalf("login with rotated key returned %s=%q", kind, value)
+	}
+	if idp.jwksRequests != 2 {
+		t.Errorf("JWKS fetched %d times, expected 2", idp.jwksRequests)
+	}
+	// The retired key is gone after the refresh
+	if kind, _ := login(t, idp, func(nonce string) string {
+		return signJWT(t, "k1", oldKey, idp.claims(nonce))
+	}); kind != "sso_error" {
+		t.Errorf("token signed with the retired key was accepted")
+	}
+}
+
+func TestOIDCSessionFollowsUserStatus(t *testing.T) {
+	idp := setupOIDCTest(t)
+	key := idp.addKey(t, "k1", false)
+	sign := func(nonce string) string { return signJWT(t, "k1", key, idp.claims(nonce)) }
+
+	_, token := login(t, idp, sign)
+	if _, err := authenticateToken(token); err != nil {
+		t.Fatalf("authenticateToken failed: %v", err)
+	}
+
+	// Disabling the user ends the running session
+	if err := saveUser(&User{Name: "alice", Role: RoleViewer, Kind: UserKindSSO, Disabled: true}); err != nil {
+		t.Fatalf("saveUser failed: %v", err)
+	}
+	if _, err := authenticateToken(token); err != errUserDisabled {
+		t.Errorf("authenticateToken for a disabled user = %v, expected %v", err, errUserDisabled)
+	}
+	if kind, _ := login(t, idp, sign); kind != "sso_error" {
+		t.Errorf("disabled user could log in")
+	}
+
+	// Enabling the user again does not revive the ended session
+	if err := saveUser(&User{Name: "alice", Role: RoleViewer, Kind: UserKindSSO}); err != nil {
+		t.Fatalf("saveUser failed: %v", err)
+	}
+	if _, err := authenticateToken(token); err != errSessionExpired {
+		t.Errorf("authenticateToken for an ended session = %v, expected %v", err, errSessionExpired)
+	}
+
+	// A session of a user with a record ends when the record is deleted
+	_, token = login(t, idp, sign)
+	if _, err := authenticateToken(token); err != nil {
+		t.Fatalf("authenticateToken failed: %v", err)
+	}
+	if err := common.RedisHDel(rbacUsersKey, "alice"); err != nil {
+		t.Fatalf("RedisHDel failed: %v", err)
+	}
+	if _, err := authenticateToken(token); err != errSessionExpired {
+		t.Errorf("authenticateToken after deleting the user = %v, expected %v", err, errSessionExpired)
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwk     string
+		wantErr bool
+	}{
+		{"encryption key", `{"kty":"RSA","kid":"e","use":"enc","n":"AQAB","e":"AQAB"}`, true},
+		{"unsupported curve", `{"kty":"EC","kid":"c","crv":"secp256k1","x":"AQ","y":"AQ"}`, true},
+		{"unsupported type", `{"kty":"oct","kid":"o","k":"AQ"}`, true},
+		{"invalid base64", `{"kty":"RSA","kid":"b","n":"!!","e":"AQAB"}`, true},
+		{"rsa", `{"kty":"RSA","kid":"r","n":"AQAB","e":"AQAB"}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := parseJWK(json.RawMessage(test.jwk)); (err != nil) != test.wantErr {
+				t.Errorf("parseJWK(%s) error = %v, wantErr %v", test.jwk, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyJWTSignatureAlgorithmMismatch(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := signJWT(t, "k", key, map[string]interface{}{"sub": "x"})
+	parts := strings.Split(token, ".")
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err := verifyJWTSignature("RS256", key.Public(), parts[0]+"."+parts[1], sig); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	for _, alg := range []string{"ES256", "HS256", "none"} {
+		if err := verifyJWTSignature(alg, key.Public(), parts[0]+"."+parts[1], sig); err == nil {
+			t.Errorf("verifyJWTSignature(%s) with an RSA key succeeded", alg)
+		}
+	}
+	if err := verifyJWTSignature("RS256", key.Public(), parts[0]+"."+parts[1]+"x", sig); err == nil {
+		t.Error("signature of modified claims was accepted")
+	}
+}
@@ -65,7 +65,7 @@ func getUnifiedOperationHistory(filter common.OperationHistoryFilter) ([]common.
 }
 
 // RecordChangePush records a change push operation to Redis
-func RecordChangePush(componentType, componentID, oldContent, newContent, diff, status, errorMsg string) {
+func RecordChangePush(componentType, componentID, oldContent, newContent, diff, status, errorMsg, operator string) {
 	// Create details map with execution node information
 	details := map[string]interface{}{
 		"node_id":      common.Config.LocalIP,
@@ -84,6 +84,7 @@ func RecordChangePush(componentType, componentID, oldContent, newContent, diff,
 		Status:        status,
 		Error:         errorMsg,
 		Details:       details,
+		Operator:      operator,
 	}
 
 	// Serialize record to JSON and store to Redis
@@ -103,7 +104,7 @@ func RecordChangePush(componentType, componentID, oldContent, newContent, diff,
 }
 
 // RecordLocalPush records a local push operation to Redis
-func RecordLocalPush(componentType, componentID, content, status, errorMsg string) {
+func RecordLocalPush(componentType, componentID, content, status, errorMsg, operator string) {
 	// Create details map with execution node information
 	details := map[string]interface{}{
 		"node_id":      common.Config.LocalIP,
@@ -120,6 +121,7 @@ func RecordLocalPush(componentType, componentID, content, status, errorMsg strin
 		Status:        status,
 		Error:         errorMsg,
 		Details:       details,
+		Operator:      operator,
 	}
 
 	// Serialize record to JSON and store to Redis
@@ -139,7 +141,7 @@ func RecordLocalPush(componentType, componentID, content, status, errorMsg strin
 }
 
 // RecordComponentDelete records a component deletion operation to Redis
-func RecordComponentDelete(componentType, componentID, status, errorMsg string, affectedProjects []string) {
+func RecordComponentDelete(componentType, componentID, status, errorMsg, operator string, affectedProjects []string) {
 	// Create details map with execution node information
 	details := map[string]interface{}{
 		"node_id":           common.Config.LocalIP,
@@ -156,6 +158,7 @@ func RecordComponentDelete(componentType, componentID, status, errorMsg string,
 		Status:        status,
 		Error:         errorMsg,
 		Details:       details,
+		Operator:      operator,
 	}
 
 	// Serialize record to JSON and store to Redis
@@ -175,9 +178,9 @@ func RecordComponentDelete(componentType, componentID, status, errorMsg string,
 }
 
 // RecordProjectOperation records a project operation
-func RecordProjectOperation(operationType OperationType, projectID, status, errorMsg string, details map[string]interface{}) {
+func RecordProjectOperation(operationType OperationType, projectID, status, errorMsg, operator string, details map[string]interface{}) {
 	// Delegate to common package
-	common.RecordProjectOperation(common.OperationType(operationType), projectID, status, errorMsg, details)
+	common.RecordProjectOperation(common.OperationType(operationType), projectID, status, errorMsg, operator, details)
 }
 
 // GetOperationsHistory handles GET /operations-history - unified endpoint for all nodes
@@ -208,6 +211,7 @@ func GetOperationsHistory(c echo.Context) error {
 	filter.Status = c.QueryParam("status")
 	filter.Keyword = c.QueryParam("keyword")
 	filter.NodeID = c.QueryParam("node_id")
+	filter.Operator = c.QueryParam("operator")
 
 	if limitStr := c.QueryParam("limit"); limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil {
@@ -3,8 +3,10 @@ package api
 import (
 	"AgentSmith-HUB/cluster"
 	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/gitops"
 	"AgentSmith-HUB/input"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/lookup"
 	"AgentSmith-HUB/output"
 	"AgentSmith-HUB/plugin"
 	"AgentSmith-HUB/project"
@@ -13,6 +15,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -101,7 +104,7 @@ func (pcm *PendingChangeManager) AddChange(changeType, id, newContent, oldConten
 
 	// Validate component type
 	validTypes := map[string]bool{
-		"plugin": true, "input": true, "output": true, "ruleset": true, "project": true,
+		"plugin": true, "input": true, "output": true, "ruleset": true, "project": true, "lookup": true,
 	}
 	if !validTypes[changeType] {
 		logger.Error("Invalid component type", "type", changeType)
@@ -190,8 +193,13 @@ func (pcm *PendingChangeManager) VerifyChange(changeType, id string) error {
 		err = output.Verify("", change.NewContent)
 	case "ruleset":
 		err = rules_engine.Verify("", change.NewContent)
+		if err == nil {
+			err = verifyRulesetTestCases(id, change.NewContent)
+		}
 	case "project":
 		err = project.Verify("", change.NewContent)
+	case "lookup":
+		err = lookup.Verify("", change.NewContent)
 	default:
 		err = fmt.Errorf("unsupported component type: %s", changeType)
 	}
@@ -207,7 +215,7 @@ func (pcm *PendingChangeManager) VerifyChange(changeType, id string) error {
 
 // PendingChange represents a component with pending changes
 type PendingChange struct {
-	Type       string `json:"type"`        // Component type (input, output, ruleset, project, plugin)
+	Type       string `json:"type"`        // Component type (input, output, ruleset, project, plugin, lookup)
 	ID         string `json:"id"`          // Component ID
 	IsNew      bool   `json:"is_new"`      // Whether this is a new component
 	OldContent string `json:"old_content"` // Original content
@@ -261,6 +269,7 @@ func syncLegacyToEnhancedManager() {
 	syncOutputsToEnhancedManager()
 	syncRulesetsToEnhancedManager()
 	syncProjectsToEnhancedManager()
+	syncLookupsToEnhancedManager()
 	cleanupObsoleteChanges()
 }
 
@@ -410,6 +419,32 @@ func syncProjectsToEnhancedManager() {
 	}
 }
 
+// syncLookupsToEnhancedManager synchronizes lookup changes using safe accessors
+func syncLookupsToEnhancedManager() {
+	// Sync lookups with pending changes
+	for id, newContent := range lookup.GetAllLookupsNew() {
+		var oldContent string
+		isNew := true
+
+		// Check if this is a modification to an existing lookup
+		if l, ok := lookup.Get(id); ok && l.Config != nil {
+			oldContent = l.Config.RawConfig
+			isNew = false
+		}
+
+		// Always update or add to ensure current state
+		if existingChange, exists := globalPendingChangeManager.GetChange("lookup", id); exists {
+			// Update existing change with current content
+			if existingChange.NewContent != newContent || existingChange.OldContent != oldContent {
+				globalPendingChangeManager.AddChange("lookup", id, newContent, oldContent, isNew)
+			}
+		} else {
+			// Add new change
+			globalPendingChangeManager.AddChange("lookup", id, newContent, oldContent, isNew)
+		}
+	}
+}
+
 // Helper functions for safe access to plugin data
 func getPendingPluginChange(id string) (string, bool) {
 	common.GlobalMu.RLock()
@@ -457,6 +492,10 @@ func cleanupObsoleteChanges() {
 		shouldExist[fmt.Sprintf("project:%s", id)] = true
 	}
 
+	for id := range lookup.GetAllLookupsNew() {
+		shouldExist[fmt.Sprintf("lookup:%s", id)] = true
+	}
+
 	// Clean up obsolete changes that no longer exist in legacy storage
 	for _, change := range existingChanges {
 		key := fmt.Sprintf("%s:%s", change.Type, change.ID)
@@ -561,7 +600,7 @@ func CancelPendingChange(c echo.Context) error {
 
 	// Validate component type
 	validTypes := map[string]bool{
-		"plugin": true, "input": true, "output": true, "ruleset": true, "project": true,
+		"plugin": true, "input": true, "output": true, "ruleset": true, "project": true, "lookup": true,
 	}
 	if !validTypes[changeType] {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -594,6 +633,8 @@ func CancelPendingChange(c echo.Context) error {
 		project.DeleteRulesetNew(id)
 	case "project":
 		project.DeleteProjectNew(id)
+	case "lookup":
+		lookup.DeleteLookupNew(id)
 	}
 
 	// Remove .new file if it exists
@@ -610,6 +651,8 @@ func CancelPendingChange(c echo.Context) error {
 		tempPath = path.Join(configRoot, "ruleset", id+".xml.new")
 	case "project":
 		tempPath = path.Join(configRoot, "project", id+".yaml.new")
+	case "lookup":
+		tempPath = path.Join(configRoot, "lookup", id+".yaml.new")
 	}
 
 	if tempPath != "" {
@@ -654,6 +697,8 @@ func CancelAllPendingChanges(c echo.Context) error {
 			project.DeleteRulesetNew(change.ID)
 		case "project":
 			project.DeleteProjectNew(change.ID)
+		case "lookup":
+			lookup.DeleteLookupNew(change.ID)
 		}
 
 		// Remove .new file if it exists
@@ -670,6 +715,8 @@ func CancelAllPendingChanges(c echo.Context) error {
 			tempPath = path.Join(configRoot, "ruleset", change.ID+".xml.new")
 		case "project":
 			tempPath = path.Join(configRoot, "project", change.ID+".yaml.new")
+		case "lookup":
+			tempPath = path.Join(configRoot, "lookup", change.ID+".yaml.new")
 		}
 
 		if tempPath != "" {
@@ -698,6 +745,7 @@ const (
 	SourceChangePush  ComponentReloadSource = "change_push"
 	SourceLocalFile   ComponentReloadSource = "local_file"
 	SourceClusterSync ComponentReloadSource = "cluster_sync"
+	SourceGitSync     ComponentReloadSource = "git_sync"
 )
 
 // ComponentReloadRequest represents a request to reload a component
@@ -709,6 +757,7 @@ type ComponentReloadRequest struct {
 	Source      ComponentReloadSource `json:"source"`
 	SkipVerify  bool                  `json:"skip_verify,omitempty"`
 	WriteToFile bool                  `json:"write_to_file,omitempty"`
+	Operator    string                `json:"operator,omitempty"` // User recorded in the operations history
 }
 
 // reloadComponentUnified provides unified component reload logic for all sources
@@ -722,21 +771,14 @@ func reloadComponentUnified(req *ComponentReloadRequest) ([]string, error) {
 
 	// Phase 2: Verification (optional based on source)
 	if !req.SkipVerify {
-		var verifyErr error
-		switch req.Type {
-		case "plugin":
-			verifyErr = plugin.Verify("", req.NewContent, req.ID)
-		case "input":
-			verifyErr = input.Verify("", req.NewContent)
-		case "output":
-			verifyErr = output.Verify("", req.NewContent)
-		case "ruleset":
-			verifyErr = rules_engine.Verify("", req.NewContent)
-		case "project":
-			verifyErr = project.Verify("", req.NewContent)
-		default:
+		if _, ok := componentApplyOrder[req.Type]; !ok {
 			return nil, fmt.Errorf("unsupported component type: %s", req.Type)
 		}
+		verifyErr := verifyComponentConfig(req.Type, req.ID, req.NewContent)
+		// Test cases live on the leader, synced content was already tested there
+		if verifyErr == nil && req.Type == "ruleset" && req.Source != SourceClusterSync {
+			verifyErr = verifyRulesetTestCases(req.ID, req.NewContent)
+		}
 
 		if verifyErr != nil {
 			logger.Error("Component verification failed", "type", req.Type, "id", req.ID, "error", verifyErr)
@@ -759,6 +801,8 @@ func reloadComponentUnified(req *ComponentReloadRequest) ([]string, error) {
 			filePath = path.Join(configRoot, "project", req.ID+".yaml")
 		case "plugin":
 			filePath = path.Join(configRoot, "plugin", req.ID+".go")
+		case "lookup":
+			filePath = path.Join(configRoot, "lookup", req.ID+".yaml")
 		default:
 			return nil, fmt.Errorf("unsupported component type for file write: %s", req.Type)
 		}
@@ -782,6 +826,8 @@ func reloadComponentUnified(req *ComponentReloadRequest) ([]string, error) {
 			tempPath = path.Join(configRoot, "ruleset", req.ID+".xml.new")
 		case "project":
 			tempPath = path.Join(configRoot, "project", req.ID+".yaml.new")
+		case "lookup":
+			tempPath = path.Join(configRoot, "lookup", req.ID+".yaml.new")
 		}
 
 		if tempPath != "" {
@@ -893,6 +939,23 @@ func reloadComponentUnified(req *ComponentReloadRequest) ([]string, error) {
 
 		affectedProjects = project.GetAffectedProjects("plugin", req.ID)
 
+	case "lookup":
+		// The new table replaces the old one in place, rulesets resolve lookups on every event
+		var err error
+		if req.WriteToFile && filePath != "" {
+			_, err = lookup.NewLookup(filePath, "", req.ID)
+		} else {
+			_, err = lookup.NewLookup("", req.NewContent, req.ID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lookup: %w", err)
+		}
+
+		lookup.DeleteLookupNew(req.ID)
+
+		// Hot reload, no project needs to restart
+		affectedProjects = []string{}
+
 	default:
 		return nil, fmt.Errorf("unsupported component type: %s", req.Type)
 	}
@@ -910,9 +973,14 @@ func reloadComponentUnified(req *ComponentReloadRequest) ([]string, error) {
 	// Phase 6: Record operation history
 	switch req.Source {
 	case SourceChangePush:
-		RecordChangePush(req.Type, req.ID, req.OldContent, req.NewContent, "", "success", "")
+		RecordChangePush(req.Type, req.ID, req.OldContent, req.NewContent, "", "success", "", req.Operator)
+		gitops.CommitComponent(req.Type, req.ID, req.NewContent, req.Operator)
 	case SourceLocalFile:
-		RecordLocalPush(req.Type, req.ID, req.NewContent, "success", "")
+		RecordLocalPush(req.Type, req.ID, req.NewContent, "success", "", req.Operator)
+		gitops.CommitComponent(req.Type, req.ID, req.NewContent, req.Operator)
+	case SourceGitSync:
+		// Already in the repository, recorded like a pushed change
+		RecordChangePush(req.Type, req.ID, req.OldContent, req.NewContent, "", "success", "", req.Operator)
 	case SourceClusterSync:
 		// Cluster sync doesn't need to record history to avoid loops
 	}
@@ -921,6 +989,26 @@ func reloadComponentUnified(req *ComponentReloadRequest) ([]string, error) {
 	return affectedProjects, nil
 }
 
+// verifyComponentConfig checks a component config with the Verify function of its type
+func verifyComponentConfig(componentType, id, content string) error {
+	switch componentType {
+	case "plugin":
+		return plugin.Verify("", content, id)
+	case "input":
+		return input.Verify("", content)
+	case "output":
+		return output.Verify("", content)
+	case "ruleset":
+		return rules_engine.Verify("", content)
+	case "project":
+		return project.Verify("", content)
+	case "lookup":
+		return lookup.Verify("", content)
+	default:
+		return fmt.Errorf("unsupported component type: %s", componentType)
+	}
+}
+
 // updateGlobalComponentConfigMap updates the global component config map
 func updateGlobalComponentConfigMap(componentType, id, content string) {
 	common.SetRawConfig(componentType, id, content)
@@ -983,6 +1071,13 @@ func ApplySingleChange(c echo.Context) error {
 				oldContent = proj.Config.RawConfig
 			}
 		}
+	case "lookup":
+		content, found = lookup.GetLookupNew(req.ID)
+		if found {
+			if l, exists := lookup.Get(req.ID); exists && l.Config != nil {
+				oldContent = l.Config.RawConfig
+			}
+		}
 	default:
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid component type"})
 	}
@@ -1000,6 +1095,7 @@ func ApplySingleChange(c echo.Context) error {
 		Source:      SourceChangePush,
 		SkipVerify:  false, // Always verify for single changes
 		WriteToFile: true,  // Always write to file for persistence
+		Operator:    operatorFromContext(c),
 	}
 
 	affectedProjects, err := reloadComponentUnified(reloadReq)
@@ -1032,6 +1128,11 @@ func ApplySingleChange(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"message": "Change applied successfully"})
 }
 
+// componentApplyOrder applies plugins and lookups before the rulesets using them and projects last
+var componentApplyOrder = map[string]int{
+	"plugin": 0, "lookup": 0, "input": 1, "output": 1, "ruleset": 2, "project": 3,
+}
+
 // ApplyAllChanges applies all pending changes and returns affected projects
 func ApplyAllChanges(c echo.Context) error {
 	// Add panic recovery
@@ -1060,6 +1161,11 @@ func ApplyAllChanges(c echo.Context) error {
 	failedChanges := []FailedChangeInfo{}
 	allAffectedProjects := make(map[string]bool) // Use map to avoid duplicates
 
+	// Apply components before the components that reference them
+	sort.SliceStable(changes, func(i, j int) bool {
+		return componentApplyOrder[changes[i].Type] < componentApplyOrder[changes[j].Type]
+	})
+
 	// Apply each change
 	for _, change := range changes {
 		reloadReq := &ComponentReloadRequest{
@@ -1070,6 +1176,7 @@ func ApplyAllChanges(c echo.Context) error {
 			Source:      SourceChangePush,
 			SkipVerify:  false, // Always verify
 			WriteToFile: true,  // Always write to file for persistence
+			Operator:    operatorFromContext(c),
 		}
 
 		affectedProjects, err := reloadComponentUnified(reloadReq)
@@ -1203,6 +1310,17 @@ func CreateTempFile(c echo.Context) error {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "plugin not found"})
 		}
 
+	case "lookup":
+		originalPath = path.Join(configRoot, "lookup", id+".yaml")
+		tempPath = originalPath + ".new"
+
+		if l, ok := lookup.Get(id); ok && l.Config != nil {
+			content = l.Config.RawConfig
+		} else {
+			logger.Error("Lookup not found", "id", id)
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "lookup not found"})
+		}
+
 	default:
 		logger.Error("Unsupported component type", "type", componentType)
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported component type"})
@@ -1256,6 +1374,8 @@ func CreateTempFile(c echo.Context) error {
 		project.SetProjectNew(id, content)
 	case "plugin":
 		plugin.SetPluginNew(id, content)
+	case "lookup":
+		lookup.SetLookupNew(id, content)
 	}
 
 	logger.Info("Temp file created successfully", "path", tempPath)
@@ -1335,6 +1455,8 @@ func DeleteTempFile(c echo.Context) error {
 		project.DeleteProjectNew(id)
 	case "plugin":
 		plugin.DeletePluginNew(id)
+	case "lookup":
+		lookup.DeleteLookupNew(id)
 	}
 
 	logger.Info("Temp file deleted successfully", "path", tempPath)
@@ -2,6 +2,7 @@ package api
 
 import (
 	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/plugin"
 	"net/http"
 	"strings"
 	"time"
@@ -15,6 +16,9 @@ import (
 // - plugin (string): filter by plugin name
 // - node_id (string): filter by specific node, "all" for all nodes (default: aggregated across all nodes)
 // - by_node (bool): return results grouped by node instead of aggregated
+//
+// The response also carries "runtime": timeouts, rejections and circuit breaker state of limited plugins on this node,
+// and "cache": result cache hits and misses of plugins called with cache_ttl on this node.
 func GetPluginStats(c echo.Context) error {
 	date := c.QueryParam("date")
 	if date == "" {
@@ -90,6 +94,8 @@ func GetPluginStats(c echo.Context) error {
 			"date":       date,
 			"by_node":    true,
 			"node_stats": nodeStats,
+			"runtime":    pluginRuntimeStats(filterPlugin),
+			"cache":      pluginCacheStats(filterPlugin),
 		})
 	} else {
 		// Return aggregated results across all nodes (default behavior)
@@ -136,8 +142,42 @@ func GetPluginStats(c echo.Context) error {
 		}
 
 		return c.JSON(http.StatusOK, map[string]interface{}{
-			"date":  date,
-			"stats": stats,
+			"date":    date,
+			"stats":   stats,
+			"runtime": pluginRuntimeStats(filterPlugin),
+			"cache":   pluginCacheStats(filterPlugin),
 		})
 	}
 }
+
+// pluginRuntimeStats returns the runtime limit counters of this node, optionally for one plugin
+func pluginRuntimeStats(filterPlugin string) map[string]interface{} {
+	all := plugin.GetAllRuntimeStats()
+	if filterPlugin != "" {
+		for name := range all {
+			if name != filterPlugin {
+				delete(all, name)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"node_id": common.GetNodeID(),
+		"plugins": all,
+	}
+}
+
+// pluginCacheStats returns the result cache counters of this node, optionally for one plugin
+func pluginCacheStats(filterPlugin string) map[string]interface{} {
+	all := plugin.GetAllCacheStats()
+	if filterPlugin != "" {
+		for name := range all {
+			if name != filterPlugin {
+				delete(all, name)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"node_id": common.GetNodeID(),
+		"plugins": all,
+	}
+}
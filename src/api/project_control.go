@@ -71,14 +71,14 @@ func StartProject(c echo.Context) error {
 	// Start the project
 	if err := p.Start(true); err != nil {
 		// Record failed operation
-		RecordProjectOperation(OpTypeProjectStart, req.ProjectID, "failed", err.Error(), nil)
+		RecordProjectOperation(OpTypeProjectStart, req.ProjectID, "failed", err.Error(), operatorFromContext(c), nil)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": fmt.Sprintf("Failed to start project: %v", err),
 		})
 	}
 
 	// Record successful operation
-	RecordProjectOperation(OpTypeProjectStart, req.ProjectID, "success", "", nil)
+	RecordProjectOperation(OpTypeProjectStart, req.ProjectID, "success", "", operatorFromContext(c), nil)
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Project started successfully"})
 }
@@ -105,7 +105,7 @@ func StopProject(c echo.Context) error {
 	// Stop the project
 	if err := p.Stop(true); err != nil {
 		// Record failed operation
-		RecordProjectOperation(OpTypeProjectStop, req.ProjectID, "failed", err.Error(), nil)
+		RecordProjectOperation(OpTypeProjectStop, req.ProjectID, "failed", err.Error(), operatorFromContext(c), nil)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": fmt.Sprintf("Failed to stop project: %v", err),
 		})
@@ -118,7 +118,7 @@ func StopProject(c echo.Context) error {
 	}
 
 	// Record successful operation
-	RecordProjectOperation(OpTypeProjectStop, req.ProjectID, "success", "", nil)
+	RecordProjectOperation(OpTypeProjectStop, req.ProjectID, "success", "", operatorFromContext(c), nil)
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
@@ -149,14 +149,19 @@ func RestartProject(c echo.Context) error {
 	// Sync operation to follower nodes FIRST - ensure cluster consistency regardless of local result
 	syncProjectOperationToFollowers(req.ProjectID, "restart")
 
-	err := p.Restart(true, "api")
+	// Recorded here instead of inside Restart so the history carries the calling user
+	err := p.Restart(false, "api")
+	details := map[string]interface{}{"triggered_by": "api"}
 	if err != nil {
+		RecordProjectOperation(OpTypeProjectRestart, req.ProjectID, "failed", err.Error(), operatorFromContext(c), details)
 		logger.Error("Failed to restart project after component change", "project_id", req.ProjectID, "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": fmt.Sprintf("Failed to restart project: %v", err),
 		})
 	}
 
+	RecordProjectOperation(OpTypeProjectRestart, req.ProjectID, "success", "", operatorFromContext(c), details)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Project restarted successfully",
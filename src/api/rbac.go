@@ -0,0 +1,346 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Roles, from least to most privileged. Each role includes everything the previous one can do.
+//
+//	viewer       read components, projects, history, logs and metrics
+//	rule_author  create, edit, verify and test components, manage pending changes
+//	operator     apply changes, start/stop projects, load local changes, replay dead letters
+//	admin        manage users and tokens, download the full config
+const (
+	RoleViewer     = "viewer"
+	RoleRuleAuthor = "rule_author"
+	RoleOperator   = "operator"
+	RoleAdmin      = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:     1,
+	RoleRuleAuthor: 2,
+	RoleOperator:   3,
+	RoleAdmin:      4,
+}
+
+const (
+	UserKindUser    = "user"
+	UserKindService = "service"
+)
+
+const (
+	rbacUsersKey  = "cluster:rbac:users"
+	rbacTokensKey = "cluster:rbac:tokens"
+
+	// apiTokenPrefix marks per-user tokens so they are easy to spot in configs and secret scanners
+	apiTokenPrefix = "hub_"
+
+	// sharedTokenUser is the identity of the cluster token from config, it always has the admin role
+	sharedTokenUser = "admin"
+
+	identityContextKey = "identity"
+)
+
+var userNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.@-]{0,63}$`)
+
+// User is a named person or service account
+type User struct {
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description,omitempty"`
+	Disabled    bool      `json:"disabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+}
+
+// APIToken is a per-user token, only the SHA-256 of the token is stored
+type APIToken struct {
+	ID        string     `json:"id"`
+	User      string     `json:"user"`
+	Hash      string     `json:"-"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// storedAPIToken keeps the hash in Redis while APIToken hides it from API responses
+type storedAPIToken struct {
+	APIToken
+	Hash string `json:"hash"`
+}
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	User string `json:"user"`
+	Role string `json:"role"`
+	Kind string `json:"kind"`
+}
+
+var (
+	errTokenInvalid   = errors.New("authentication failed")
+	errTokenExpired   = errors.New("token expired")
+	errTokenRevoked   = errors.New("token revoked")
+	errSessionExpired = errors.New("session expired, please log in again")
+	errUserDisabled   = errors.New("user disabled")
+	errUserNotFound   = errors.New("user not found")
+	errTokenNotFound  = errors.New("token not found")
+)
+
+func isValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// roleAllows reports whether role has at least the privileges of required
+func roleAllows(role, required string) bool {
+	return roleLevels[role] >= roleLevels[required]
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// authenticateToken resolves a token (shared, SSO session or per-user) to an identity.
+// The shared cluster token keeps working as the admin identity so existing deployments and scripts are unaffected.
+func authenticateToken(token string) (*Identity, error) {
+	if token == "" {
+		return nil, errTokenInvalid
+	}
+	if common.Config.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(common.Config.Token)) == 1 {
+		return &Identity{User: sharedTokenUser, Role: RoleAdmin, Kind: UserKindService}, nil
+	}
+	if strings.HasPrefix(token, sessionTokenPrefix) {
+		return lookupSSOSession(token)
+	}
+
+	raw, err := common.RedisHGet(rbacTokensKey, hashAPIToken(token))
+	if err != nil || raw == "" {
+		return nil, errTokenInvalid
+	}
+	var t storedAPIToken
+	if err := json.Unmarshal([]byte(raw), &t); err != nil {
+		return nil, errTokenInvalid
+	}
+	if t.RevokedAt != nil {
+		return nil, errTokenRevoked
+	}
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return nil, errTokenExpired
+	}
+
+	user, err := getUser(t.User)
+	if err != nil {
+		return nil, errTokenInvalid
+	}
+	if user.Disabled {
+		return nil, errUserDisabled
+	}
+	return &Identity{User: user.Name, Role: user.Role, Kind: user.Kind}, nil
+}
+
+// requireRole authenticates the request token and rejects callers below the given role
+func requireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.Request().Header.Get("token")
+			if token == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "missing token",
+				})
+			}
+
+			identity, err := authenticateToken(token)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": err.Error(),
+				})
+			}
+
+			if !roleAllows(identity.Role, role) {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": fmt.Sprintf("permission denied: requires role %s, user %s has role %s", role, identity.User, identity.Role),
+				})
+			}
+
+			c.Set(identityContextKey, identity)
+			return next(c)
+		}
+	}
+}
+
+// identityFromContext returns the identity set by requireRole, nil for unauthenticated routes
+func identityFromContext(c echo.Context) *Identity {
+	if identity, ok := c.Get(identityContextKey).(*Identity); ok {
+		return identity
+	}
+	return nil
+}
+
+// operatorFromContext returns the user name recorded in the operations history
+func operatorFromContext(c echo.Context) string {
+	if identity := identityFromContext(c); identity != nil {
+		return identity.User
+	}
+	return ""
+}
+
+// ===================== User and Token Store =====================
+
+func getUser(name string) (*User, error) {
+	raw, err := common.RedisHGet(rbacUsersKey, name)
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, errUserNotFound
+	}
+	var user User
+	if err := json.Unmarshal([]byte(raw), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func saveUser(user *User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return common.RedisHSet(rbacUsersKey, user.Name, string(data))
+}
+
+func listUsers() ([]User, error) {
+	all, err := common.RedisHGetAll(rbacUsersKey)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(all))
+	for _, raw := range all {
+		var user User
+		if err := json.Unmarshal([]byte(raw), &user); err == nil {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// listTokens returns stored tokens, optionally filtered by user
+func listTokens(userName string) ([]storedAPIToken, error) {
+	all, err := common.RedisHGetAll(rbacTokensKey)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]storedAPIToken, 0)
+	for hash, raw := range all {
+		var t storedAPIToken
+		if err := json.Unmarshal([]byte(raw), &t); err != nil {
+			continue
+		}
+		t.Hash = hash
+		if userName == "" || t.User == userName {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// issueToken creates a token for a user and returns the plaintext, which is not stored anywhere
+func issueToken(userName, note, createdBy string, ttl time.Duration) (string, *APIToken, error) {
+	plain, err := generateAPIToken()
+	if err != nil {
+		return "", nil, err
+	}
+	hash := hashAPIToken(plain)
+	t := storedAPIToken{
+		APIToken: APIToken{
+			ID:        hash[:12],
+			User:      userName,
+			Note:      note,
+			CreatedAt: time.Now(),
+			CreatedBy: createdBy,
+		},
+		Hash: hash,
+	}
+	if ttl > 0 {
+		expiresAt := t.CreatedAt.Add(ttl)
+		t.ExpiresAt = &expiresAt
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := common.RedisHSet(rbacTokensKey, hash, string(data)); err != nil {
+		return "", nil, err
+	}
+	return plain, &t.APIToken, nil
+}
+
+// revokeToken marks a token as revoked, revoked tokens are kept for auditing
+func revokeToken(userName, tokenID string) (*APIToken, error) {
+	tokens, err := listTokens(userName)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if t.ID != tokenID {
+			continue
+		}
+		if t.RevokedAt == nil {
+			now := time.Now()
+			t.RevokedAt = &now
+			data, err := json.Marshal(t)
+			if err != nil {
+				return nil, err
+			}
+			if err := common.RedisHSet(rbacTokensKey, t.Hash, string(data)); err != nil {
+				return nil, err
+			}
+		}
+		return &t.APIToken, nil
+	}
+	return nil, errTokenNotFound
+}
+
+// deleteUserTokens removes all tokens of a user
+func deleteUserTokens(userName string) error {
+	tokens, err := listTokens(userName)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if err := common.RedisHDel(rbacTokensKey, t.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// setupRBACTest gives the hub a fresh in-memory Redis and the shared cluster token "cluster-token"
+func setupRBACTest(t *testing.T) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{Token: "cluster-token"}
+	t.Cleanup(func() { common.Config = prevConfig })
+}
+
+// addTestUser saves a user and returns a new token of it
+func addTestUser(t *testing.T, name, role string, ttl time.Duration) (string, *APIToken) {
+	t.Helper()
+	if err := saveUser(&User{Name: name, Role: role, Kind: UserKindUser, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("saveUser failed: %v", err)
+	}
+	plain, token, err := issueToken(name, "", "admin", ttl)
+	if err != nil {
+		t.Fatalf("issueToken failed: %v", err)
+	}
+	return plain, token
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		expected bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleRuleAuthor, false},
+		{RoleRuleAuthor, RoleViewer, true},
+		{RoleRuleAuthor, RoleOperator, false},
+		{RoleOperator, RoleRuleAuthor, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleAdmin, RoleOperator, true},
+		{"", RoleViewer, false},
+		{"root", RoleViewer, false},
+	}
+
+	for _, test := range tests {
+		if res := roleAllows(test.role, test.required); res != test.expected {
+			t.Errorf("roleAllows(%q, %q) = %v, expected %v", test.role, test.required, res, test.expected)
+		}
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	setupRBACTest(t)
+
+	identity, err := authenticateToken("cluster-token")
+	if err != nil || *identity != (Identity{User: sharedTokenUser, Role: RoleAdmin, Kind: UserKindService}) {
+		t.Errorf("authenticateToken(shared token) = %v, %v, expected the admin identity", identity, err)
+	}
+
+	plain, _ := addTestUser(t, "alice", RoleRuleAuthor, 0)
+	if !strings.HasPrefix(plain, apiTokenPrefix) {
+		t.Errorf("issued token %q does not start with %q", plain, apiTokenPrefix)
+	}
+	identity, err = authenticateToken(plain)
+	if err != nil || *identity != (Identity{User: "alice", Role: RoleRuleAuthor, Kind: UserKindUser}) {
+		t.Errorf("authenticateToken(alice) = %v, %v", identity, err)
+	}
+
+	// Only the hash is stored
+	all, err := common.RedisHGetAll(rbacTokensKey)
+	if err != nil {
+		t.Fatalf("RedisHGetAll failed: %v", err)
+	}
+	for hash, raw := range all {
+		if strings.Contains(raw, plain) || hash != hashAPIToken(plain) {
+			t.Errorf("token stored as %s: %s", hash, raw)
+		}
+	}
+
+	for _, token := range []string{"", "cluster-token ", "hub_unknown"} {
+		if _, err := authenticateToken(token); err != errTokenInvalid {
+			t.Errorf("authenticateToken(%q) = %v, expected %v", token, err, errTokenInvalid)
+		}
+	}
+
+	expired, _ := addTestUser(t, "bob", RoleViewer, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := authenticateToken(expired); err != errTokenExpired {
+		t.Errorf("authenticateToken(expired) = %v, expected %v", err, errTokenExpired)
+	}
+
+	// Revoking another user's token by ID does nothing
+	carol, carolToken := addTestUser(t, "carol", RoleOperator, 0)
+	if _, err := revokeToken("alice", carolToken.ID); err != errTokenNotFound {
+		t.Errorf("revokeToken(alice, carol's token) = %v, expected %v", err, errTokenNotFound)
+	}
+	if _, err := authenticateToken(carol); err != nil {
+		t.Errorf("authenticateToken(carol) = %v", err)
+	}
+	revoked, err := revokeToken("carol", carolToken.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("revokeToken(carol) = %v, %v", revoked, err)
+	}
+	if _, err := authenticateToken(carol); err != errTokenRevoked {
+		t.Errorf("authenticateToken(revoked) = %v, expected %v", err, errTokenRevoked)
+	}
+
+	// The role is read on every request, changes and disabling apply to existing tokens
+	user, _ := getUser("alice")
+	user.Role = RoleViewer
+	user.Disabled = true
+	if err := saveUser(user); err != nil {
+		t.Fatalf("saveUser failed: %v", err)
+	}
+	if _, err := authenticateToken(plain); err != errUserDisabled {
+		t.Errorf("authenticateToken(disabled) = %v, expected %v", err, errUserDisabled)
+	}
+	user.Disabled = false
+	if err := saveUser(user); err != nil {
+		t.Fatalf("saveUser failed: %v", err)
+	}
+	if identity, err := authenticateToken(plain); err != nil || identity.Role != RoleViewer {
+		t.Errorf("authenticateToken after the role change = %v, %v, expected role %s", identity, err, RoleViewer)
+	}
+
+	if err := common.RedisHDel(rbacUsersKey, "alice"); err != nil {
+		t.Fatalf("RedisHDel failed: %v", err)
+	}
+	if _, err := authenticateToken(plain); err != errTokenInvalid {
+		t.Errorf("authenticateToken(deleted user) = %v, expected %v", err, errTokenInvalid)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	setupRBACTest(t)
+	viewer, _ := addTestUser(t, "vic", RoleViewer, 0)
+	operator, _ := addTestUser(t, "olga", RoleOperator, 0)
+
+	tests := []struct {
+		name     string
+		token    string
+		required string
+		status   int
+		user     string
+	}{
+		{"missing token", "", RoleViewer, http.StatusUnauthorized, ""},
+		{"invalid token", "nope", RoleViewer, http.StatusUnauthorized, ""},
+		{"viewer reads", viewer, RoleViewer, http.StatusOK, "vic"},
+		{"viewer edits", viewer, RoleRuleAuthor, http.StatusForbidden, ""},
+		{"operator edits", operator, RoleRuleAuthor, http.StatusOK, "olga"},
+		{"operator manages users", operator, RoleAdmin, http.StatusForbidden, ""},
+		{"shared token", "cluster-token", RoleAdmin, http.StatusOK, sharedTokenUser},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.token != "" {
+				req.Header.Set("token", test.token)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			called := false
+			handler := requireRole(test.required)(func(c echo.Context) error {
+				called = true
+				return c.String(http.StatusOK, operatorFromContext(c))
+			})
+			if err := handler(c); err != nil {
+				t.Fatalf("handler failed: %v", err)
+			}
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, expected %d: %s", rec.Code, test.status, rec.Body.String())
+			}
+			if called != (test.status == http.StatusOK) {
+				t.Errorf("handler called = %v with status %d", called, rec.Code)
+			}
+			if test.status == http.StatusOK && rec.Body.String() != test.user {
+				t.Errorf("operator = %q, expected %q", rec.Body.String(), test.user)
+			}
+			if test.status == http.StatusForbidden {
+				var body map[string]string
+				json.Unmarshal(rec.Body.Bytes(), &body)
+				if !strings.Contains(body["error"], "requires role "+test.required) {
+					t.Errorf("error = %q, expected the required role", body["error"])
+				}
+			}
+		})
+	}
+}
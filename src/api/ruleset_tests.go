@@ -0,0 +1,122 @@
+package api
+
+import (
+	"AgentSmith-HUB/gitops"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/project"
+	"AgentSmith-HUB/rules_engine"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+)
+
+// verifyRulesetTestCases runs the test cases of a ruleset against new content,
+// a failing test case blocks the change. Rulesets without test cases pass.
+func verifyRulesetTestCases(id string, content string) error {
+	cases, err := rules_engine.LoadTestCases(id)
+	if err != nil || len(cases) == 0 {
+		return err
+	}
+	report, err := rules_engine.RunTestCases(id, content, cases)
+	if err != nil {
+		return err
+	}
+	if err := report.Error(); err != nil {
+		logger.Warn("Ruleset test cases failed", "ruleset", id, "failed", report.Failed, "total", report.Total)
+		return err
+	}
+	return nil
+}
+
+// rulesetContentForTest returns the pending content of a ruleset, or its current content
+func rulesetContentForTest(id string) (string, bool) {
+	if tempPath, exists := GetComponentPath("ruleset", id, true); exists {
+		if content, err := ReadComponent(tempPath); err == nil {
+			return content, true
+		}
+	}
+	if content, ok := project.GetRulesetNew(id); ok {
+		return content, true
+	}
+	if formalPath, exists := GetComponentPath("ruleset", id, false); exists {
+		if content, err := ReadComponent(formalPath); err == nil {
+			return content, true
+		}
+	}
+	if rs, ok := project.GetRuleset(id); ok && rs.RawConfig != "" {
+		return rs.RawConfig, true
+	}
+	return "", false
+}
+
+// getRulesetTests returns the test case file of a ruleset
+func getRulesetTests(c echo.Context) error {
+	id := c.Param("id")
+	raw, err := os.ReadFile(rules_engine.TestCasePath(id))
+	if err != nil && !os.IsNotExist(err) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read test cases: " + err.Error()})
+	}
+	cases, err := rules_engine.ParseTestCases(raw)
+	if err != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{"ruleset_id": id, "content": string(raw), "tests": []interface{}{}, "error": err.Error()})
+	}
+	if cases == nil {
+		cases = []rules_engine.TestCase{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"ruleset_id": id, "content": string(raw), "tests": cases})
+}
+
+// saveRulesetTests validates and saves the test case file of a ruleset, empty content removes it
+func saveRulesetTests(c echo.Context) error {
+	id := c.Param("id")
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+	}
+	if _, ok := rulesetContentForTest(id); !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "ruleset not found: " + id})
+	}
+
+	path := rules_engine.TestCasePath(id)
+	if req.Content == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to remove test cases: " + err.Error()})
+		}
+		logger.Info("Ruleset test cases removed", "ruleset", id, "operator", operatorFromContext(c))
+		gitops.CommitTestCases(id, "", operatorFromContext(c))
+		return c.JSON(http.StatusOK, map[string]interface{}{"message": "test cases removed"})
+	}
+
+	cases, err := rules_engine.ParseTestCases([]byte(req.Content))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := os.WriteFile(path, []byte(req.Content), 0644); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save test cases: " + err.Error()})
+	}
+	logger.Info("Ruleset test cases saved", "ruleset", id, "tests", len(cases), "operator", operatorFromContext(c))
+	gitops.CommitTestCases(id, req.Content, operatorFromContext(c))
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("%d test cases saved", len(cases)), "tests": len(cases)})
+}
+
+// runRulesetTests runs the test cases of a ruleset against its pending or current content
+func runRulesetTests(c echo.Context) error {
+	id := c.Param("id")
+	content, ok := rulesetContentForTest(id)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "ruleset not found: " + id})
+	}
+	cases, err := rules_engine.LoadTestCases(id)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	report, err := rules_engine.RunTestCases(id, content, cases)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ruleset verification failed: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/rules_engine"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const regressionRuleset = `<root type="DETECTION">
+    <rule id="root_login">
+        <check type="EQU" field="user">root</check>
+    </rule>
+</root>`
+
+const regressionTestCases = `tests:
+  - name: root login
+    data: {user: root}
+    hits: [root_login]
+  - name: other user
+    data: {user: alice}
+    hits: []
+`
+
+func setupRegressionTest(t *testing.T) string {
+	t.Helper()
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir()}
+	t.Cleanup(func() { common.Config = prevConfig })
+	os.MkdirAll(filepath.Join(common.Config.ConfigRoot, "ruleset"), 0755)
+
+	path := filepath.Join(common.Config.ConfigRoot, "ruleset", "auth.xml")
+	if err := os.WriteFile(path, []byte(regressionRuleset), 0644); err != nil {
+		t.Fatalf("failed to write ruleset: %v", err)
+	}
+	if err := os.WriteFile(rules_engine.TestCasePath("auth"), []byte(regressionTestCases), 0644); err != nil {
+		t.Fatalf("failed to write test cases: %v", err)
+	}
+	return path
+}
+
+func TestRegressionBlocksApply(t *testing.T) {
+	path := setupRegressionTest(t)
+	regression := strings.Replace(regressionRuleset, ">root<", ">admin<", 1)
+
+	if err := verifyRulesetTestCases("auth", regressionRuleset); err != nil {
+		t.Fatalf("verifyRulesetTestCases() of the current ruleset = %v", err)
+	}
+	if err := verifyRulesetTestCases("other", regression); err != nil {
+		t.Errorf("verifyRulesetTestCases() of a ruleset without test cases = %v", err)
+	}
+
+	pcm := &PendingChangeManager{changes: make(map[string]*EnhancedPendingChange)}
+	pcm.AddChange("ruleset", "auth", regression, regressionRuleset, false)
+	if err := pcm.VerifyChange("ruleset", "auth"); err == nil || !strings.Contains(err.Error(), "root login") {
+		t.Errorf("VerifyChange() = %v, expected the failed test case", err)
+	}
+	if change, _ := pcm.GetChange("ruleset", "auth"); change.Status != ChangeStatusInvalid {
+		t.Errorf("change status = %v, expected invalid", change.Status)
+	}
+
+	_, err := reloadComponentUnified(&ComponentReloadRequest{
+		Type:        "ruleset",
+		ID:          "auth",
+		NewContent:  regression,
+		OldContent:  regressionRuleset,
+		Source:      SourceChangePush,
+		WriteToFile: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "verification failed") {
+		t.Fatalf("reloadComponentUnified() = %v, expected the regression to block it", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != regressionRuleset {
+		t.Error("blocked ruleset was written")
+	}
+}
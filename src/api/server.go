@@ -1,9 +1,9 @@
 package api
 
 import (
-	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
 	"AgentSmith-HUB/mcp"
+	"AgentSmith-HUB/metrics"
 	"errors"
 	"net/http"
 
@@ -46,31 +46,24 @@ func ServerStart(listener string) error {
 	}))
 	e.Use(middleware.Recover())
 
-	// Authentication middleware function - will be applied selectively
-	authMiddleware := func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			token := c.Request().Header.Get("token")
-			if token == "" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "missing token",
-				})
-			}
-
-			if token != common.Config.Token {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "authentication failed",
-				})
-			}
-
-			return next(c)
-		}
-	}
+	// Authentication and authorization: every management route requires a token and a minimum role.
+	// Roles are cumulative (viewer < rule_author < operator < admin), see rbac.go.
+	// The viewer group is created last so its catch-all route answers unknown paths with 404 instead of 403
+	admin := e.Group("", requireRole(RoleAdmin))
+	operator := e.Group("", requireRole(RoleOperator))
+	author := e.Group("", requireRole(RoleRuleAuthor))
+	viewer := e.Group("", requireRole(RoleViewer))
 
 	// Public endpoints (no authentication required)
 	// Health check and token verification
 	e.GET("/ping", ping)
 	e.GET("/token-check", tokenCheck)
 
+	// OIDC single sign-on, the callback issues a session token accepted by all authenticated routes
+	e.GET("/auth/oidc/config", getOIDCConfig)
+	e.GET("/auth/oidc/login", oidcLogin)
+	e.GET("/auth/oidc/callback", oidcCallback)
+
 	// Statistics and metrics endpoints (public access for monitoring)
 	e.GET("/daily-messages", getDailyMessages)
 	e.GET("/system-metrics", getSystemMetrics)
@@ -79,155 +72,212 @@ func ServerStart(listener string) error {
 	e.GET("/cluster-system-stats", getClusterSystemStats)
 	e.GET("/cluster-status", getClusterStatus)
 	e.GET("/cluster", getCluster)
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
 
-	// Create authenticated group for management endpoints
-	auth := e.Group("", authMiddleware)
+	// Push ingest for http inputs, authenticated per input
+	e.POST("/ingest/:id", ingestHTTPInput)
+
+	// GitOps push webhook, authenticated with the webhook secret
+	e.POST("/gitops/webhook", gitopsWebhook)
 
 	// Project endpoints (use plural form for consistency) - REQUIRE AUTH
-	auth.GET("/projects", getProjects)
-	auth.GET("/projects/:id", getProject)
-	auth.POST("/projects", createProject)
-	auth.DELETE("/projects/:id", deleteProject)
-	auth.PUT("/projects/:id", updateProject)
-	auth.POST("/start-project", StartProject)
-	auth.POST("/stop-project", StopProject)
-	auth.POST("/restart-project", RestartProject)
-	auth.GET("/project-error/:id", getProjectError)
-	auth.GET("/project-inputs/:id", getProjectInputs)
-	auth.GET("/project-components/:id", getProjectComponents)
-	auth.GET("/project-component-sequences/:id", getProjectComponentSequences)
-	auth.GET("/cluster-project-states", getClusterProjectStates)
+	viewer.GET("/projects", getProjects)
+	viewer.GET("/projects/:id", getProject)
+	author.POST("/projects", createProject)
+	author.DELETE("/projects/:id", deleteProject)
+	author.PUT("/projects/:id", updateProject)
+	operator.POST("/start-project", StartProject)
+	operator.POST("/stop-project", StopProject)
+	operator.POST("/restart-project", RestartProject)
+	viewer.GET("/project-error/:id", getProjectError)
+	viewer.GET("/project-inputs/:id", getProjectInputs)
+	viewer.GET("/project-components/:id", getProjectComponents)
+	viewer.GET("/project-component-sequences/:id", getProjectComponentSequences)
+	viewer.GET("/cluster-project-states", getClusterProjectStates)
 
 	// Ruleset endpoints (use plural form for consistency) - REQUIRE AUTH
-	auth.GET("/rulesets", getRulesets)
-	auth.GET("/rulesets/:id", getRuleset)
-	auth.POST("/rulesets", createRuleset)
-	auth.PUT("/rulesets/:id", updateRuleset)
-	auth.DELETE("/rulesets/:id", deleteRuleset)
+	viewer.GET("/rulesets", getRulesets)
+	viewer.GET("/rulesets/:id", getRuleset)
+	author.POST("/rulesets", createRuleset)
+	author.PUT("/rulesets/:id", updateRuleset)
+	author.DELETE("/rulesets/:id", deleteRuleset)
+	viewer.GET("/rulesets/:id/tests", getRulesetTests)
+	author.PUT("/rulesets/:id/tests", saveRulesetTests)
+	author.POST("/rulesets/:id/tests/run", runRulesetTests)
 
 	// Ruleset rule management endpoints - REQUIRE AUTH
-	auth.DELETE("/rulesets/:id/rules/:ruleId", deleteRulesetRule)
-	auth.POST("/rulesets/:id/rules", addRulesetRule)
+	author.DELETE("/rulesets/:id/rules/:ruleId", deleteRulesetRule)
+	author.POST("/rulesets/:id/rules", addRulesetRule)
+
+	// Sigma import: convert and validate only, the result is saved with POST /rulesets - REQUIRE AUTH
+	author.POST("/sigma/convert", importSigmaRuleset)
 
 	// Ruleset templates and documentation - REQUIRE AUTH (Updated to use MCP module)
-	auth.GET("/ruleset-templates", mcp.GetRulesetTemplates)
-	auth.GET("/ruleset-syntax-guide", mcp.GetRulesetSyntaxGuide)
-	auth.GET("/rule-templates", mcp.GetRuleTemplates)
+	viewer.GET("/ruleset-templates", mcp.GetRulesetTemplates)
+	viewer.GET("/ruleset-syntax-guide", mcp.GetRulesetSyntaxGuide)
+	viewer.GET("/rule-templates", mcp.GetRuleTemplates)
 
 	// Input endpoints (use plural form for consistency) - REQUIRE AUTH
-	auth.GET("/inputs", getInputs)
-	auth.GET("/inputs/:id", getInput)
-	auth.POST("/inputs", createInput)
-	auth.PUT("/inputs/:id", updateInput)
-	auth.DELETE("/inputs/:id", deleteInput)
+	viewer.GET("/inputs", getInputs)
+	viewer.GET("/inputs/:id", getInput)
+	author.POST("/inputs", createInput)
+	author.PUT("/inputs/:id", updateInput)
+	author.DELETE("/inputs/:id", deleteInput)
 
 	// Output endpoints (use plural form for consistency) - REQUIRE AUTH
-	auth.GET("/outputs", getOutputs)
-	auth.GET("/outputs/:id", getOutput)
-	auth.POST("/outputs", createOutput)
-	auth.PUT("/outputs/:id", updateOutput)
-	auth.DELETE("/outputs/:id", deleteOutput)
+	viewer.GET("/outputs", getOutputs)
+	viewer.GET("/outputs/:id", getOutput)
+	author.POST("/outputs", createOutput)
+	author.PUT("/outputs/:id", updateOutput)
+	author.DELETE("/outputs/:id", deleteOutput)
+	viewer.GET("/outputs/:id/dead-letter", getDeadLetters)
+	operator.POST("/outputs/:id/dead-letter/replay", replayDeadLetters)
+	operator.DELETE("/outputs/:id/dead-letter", purgeDeadLetters)
 
 	// Plugin endpoints (use plural form and :id for consistency) - REQUIRE AUTH
-	auth.GET("/plugins", getPlugins)
-	auth.GET("/plugins/:id", getPlugin)
-	auth.POST("/plugins", createPlugin)
-	auth.PUT("/plugins/:id", updatePlugin)
-	auth.DELETE("/plugins/:id", deletePlugin)
-	auth.GET("/available-plugins", getPlugins) // Use same handler with different default params
-	auth.GET("/plugin-parameters/:id", GetPluginParameters)
-	auth.GET("/plugin-parameters", GetBatchPluginParameters)
-	auth.GET("/plugins/:id/usage", getPluginUsage)
+	viewer.GET("/plugins", getPlugins)
+	viewer.GET("/plugins/:id", getPlugin)
+	author.POST("/plugins", createPlugin)
+	author.PUT("/plugins/:id", updatePlugin)
+	author.DELETE("/plugins/:id", deletePlugin)
+	viewer.GET("/available-plugins", getPlugins) // Use same handler with different default params
+	viewer.GET("/plugin-parameters/:id", GetPluginParameters)
+	viewer.GET("/plugin-parameters", GetBatchPluginParameters)
+	viewer.GET("/plugins/:id/usage", getPluginUsage)
+
+	// Lookup table endpoints - REQUIRE AUTH
+	viewer.GET("/lookups", getLookups)
+	viewer.GET("/lookups/:id", getLookup)
+	author.POST("/lookups", createLookup)
+	author.PUT("/lookups/:id", updateLookup)
+	author.DELETE("/lookups/:id", deleteLookup)
 
 	// Component verification and testing - REQUIRE AUTH
-	auth.POST("/verify/:type/:id", verifyComponent)
-	auth.GET("/connect-check/:type/:id", connectCheck)
-	auth.POST("/connect-check/:type/:id", connectCheck)
-	auth.POST("/test-plugin/:id", testPlugin)
-	auth.POST("/test-plugin-content", testPlugin)
-	auth.POST("/test-ruleset/:id", testRuleset)
-	auth.POST("/test-ruleset-content", testRuleset)
-	auth.POST("/test-output/:id", testOutput)
-	auth.POST("/test-project/:id", testProject)
-	auth.POST("/test-project-content/:inputNode", testProject)
+	author.POST("/verify/:type/:id", verifyComponent)
+	viewer.GET("/connect-check/:type/:id", connectCheck)
+	author.POST("/connect-check/:type/:id", connectCheck)
+	author.POST("/test-plugin/:id", testPlugin)
+	author.POST("/test-plugin-content", testPlugin)
+	author.POST("/test-ruleset/:id", testRuleset)
+	author.POST("/test-ruleset-content", testRuleset)
+	author.POST("/test-output/:id", testOutput)
+	author.POST("/test-project/:id", testProject)
+	author.POST("/test-project-content/:inputNode", testProject)
 
 	// Cluster management endpoints - REQUIRE AUTH
-	auth.GET("/config_root", leaderConfig)
-	auth.GET("/config/download", downloadConfig)
-	auth.GET("/cluster/instruction-stats", getInstructionStats)
-	auth.GET("/cluster/follower-execution-status", getFollowerExecutionStatus)
+	admin.GET("/config_root", leaderConfig)
+	admin.GET("/config/download", downloadConfig)
+	viewer.GET("/cluster/instruction-stats", getInstructionStats)
+	viewer.GET("/cluster/follower-execution-status", getFollowerExecutionStatus)
 
 	// Pending changes management (enhanced) - REQUIRE AUTH
-	auth.GET("/pending-changes", GetPendingChanges)                  // Legacy endpoint
-	auth.GET("/pending-changes/enhanced", GetEnhancedPendingChanges) // Enhanced endpoint with status info
-	auth.POST("/apply-single-change", ApplySingleChange)             // Legacy endpoint
-	auth.POST("/apply-changes", ApplyAllChanges)                     // Apply all pending changes
-	auth.POST("/verify-changes", VerifyPendingChanges)               // Verify all changes
-	auth.POST("/verify-change/:type/:id", VerifySinglePendingChange) // Verify single change
-	auth.DELETE("/cancel-change/:type/:id", CancelPendingChange)     // Cancel single change
-	auth.DELETE("/cancel-all-changes", CancelAllPendingChanges)      // Cancel all changes
+	viewer.GET("/pending-changes", GetPendingChanges)                  // Legacy endpoint
+	viewer.GET("/pending-changes/enhanced", GetEnhancedPendingChanges) // Enhanced endpoint with status info
+	operator.POST("/apply-single-change", ApplySingleChange)           // Legacy endpoint
+	operator.POST("/apply-changes", ApplyAllChanges)                   // Apply all pending changes
+	author.POST("/verify-changes", VerifyPendingChanges)               // Verify all changes
+	author.POST("/verify-change/:type/:id", VerifySinglePendingChange) // Verify single change
+	author.DELETE("/cancel-change/:type/:id", CancelPendingChange)     // Cancel single change
+	author.DELETE("/cancel-all-changes", CancelAllPendingChanges)      // Cancel all changes
+
+	// Config snapshots and rollback - REQUIRE AUTH
+	viewer.GET("/snapshots", getSnapshots)
+	viewer.GET("/snapshots/diff", diffSnapshots) // ?from=&to=, "current" is the live component set
+	viewer.GET("/snapshots/:name", getSnapshot)
+	operator.POST("/snapshots", createSnapshot)
+	operator.DELETE("/snapshots/:name", deleteSnapshot)
+	operator.POST("/snapshots/:name/rollback", rollbackToSnapshot)
+
+	// GitOps sync - REQUIRE AUTH
+	viewer.GET("/gitops/status", getGitOpsStatus)
+	operator.POST("/gitops/sync", syncGitOps)
 
 	// Temporary file management - REQUIRE AUTH
-	auth.POST("/temp-file/:type/:id", CreateTempFile)
-	auth.GET("/temp-file/:type/:id", CheckTempFile)
-	auth.DELETE("/temp-file/:type/:id", DeleteTempFile)
+	author.POST("/temp-file/:type/:id", CreateTempFile)
+	viewer.GET("/temp-file/:type/:id", CheckTempFile)
+	author.DELETE("/temp-file/:type/:id", DeleteTempFile)
 
 	// Sampler endpoints - REQUIRE AUTH
-	auth.GET("/samplers/data", GetSamplerData)
-	auth.POST("/samplers/data/intelligent", GetSamplersDataIntelligent)
-	auth.GET("/ruleset-fields/:id", GetRulesetFields)
-	auth.GET("/ruleset-fields", GetBatchRulesetFields)
+	viewer.GET("/samplers/data", GetSamplerData)
+	viewer.POST("/samplers/data/intelligent", GetSamplersDataIntelligent)
+	viewer.GET("/ruleset-fields/:id", GetRulesetFields)
+	viewer.GET("/ruleset-fields", GetBatchRulesetFields)
 
 	// Cancel upgrade routes - REQUIRE AUTH
-	auth.POST("/cancel-upgrade/rulesets/:id", cancelRulesetUpgrade)
-	auth.POST("/cancel-upgrade/inputs/:id", cancelInputUpgrade)
-	auth.POST("/cancel-upgrade/outputs/:id", cancelOutputUpgrade)
-	auth.POST("/cancel-upgrade/projects/:id", cancelProjectUpgrade)
-	auth.POST("/cancel-upgrade/plugins/:id", cancelPluginUpgrade)
+	author.POST("/cancel-upgrade/rulesets/:id", cancelRulesetUpgrade)
+	author.POST("/cancel-upgrade/inputs/:id", cancelInputUpgrade)
+	author.POST("/cancel-upgrade/outputs/:id", cancelOutputUpgrade)
+	author.POST("/cancel-upgrade/projects/:id", cancelProjectUpgrade)
+	author.POST("/cancel-upgrade/plugins/:id", cancelPluginUpgrade)
+	author.POST("/cancel-upgrade/lookups/:id", cancelLookupUpgrade)
 
 	// Component usage analysis - REQUIRE AUTH
-	auth.GET("/component-usage/:type/:id", GetComponentUsage)
+	viewer.GET("/component-usage/:type/:id", GetComponentUsage)
 
 	// Component configuration search - REQUIRE AUTH
-	auth.GET("/search-components", searchComponentsConfig)
+	viewer.GET("/search-components", searchComponentsConfig)
 
 	// Load local components routes - REQUIRE AUTH
-	auth.GET("/local-changes", getLocalChanges)
-	auth.GET("/local-changes/count", getLocalChangesCount) // Lightweight count endpoint
-	auth.POST("/load-local-changes", loadLocalChanges)
-	auth.POST("/load-single-local-change", loadSingleLocalChange)
+	viewer.GET("/local-changes", getLocalChanges)
+	viewer.GET("/local-changes/count", getLocalChangesCount) // Lightweight count endpoint
+	operator.POST("/load-local-changes", loadLocalChanges)
+	operator.POST("/load-single-local-change", loadSingleLocalChange)
 
 	// Error log endpoints - REQUIRE AUTH
-	auth.GET("/error-logs", getErrorLogs)
-	auth.GET("/error-logs/nodes", getErrorLogNodes)
-	auth.GET("/cluster-error-logs", getClusterErrorLogs)
+	viewer.GET("/error-logs", getErrorLogs)
+	viewer.GET("/error-logs/nodes", getErrorLogNodes)
+	viewer.GET("/cluster-error-logs", getClusterErrorLogs)
 
 	// Operations history endpoints - REQUIRE AUTH
-	auth.GET("/operations-history", GetOperationsHistory)
-	auth.GET("/operations-history/nodes", GetOperationsHistoryNodes)
-	auth.GET("/cluster-operations-history", GetClusterOperationsHistory)
-	auth.GET("/operations-stats", GetOperationsStats)
+	viewer.GET("/operations-history", GetOperationsHistory)
+	viewer.GET("/operations-history/nodes", GetOperationsHistoryNodes)
+	viewer.GET("/cluster-operations-history", GetClusterOperationsHistory)
+	viewer.GET("/operations-stats", GetOperationsStats)
 
 	// MCP (Model Context Protocol) endpoints - REQUIRE AUTH
-	auth.POST("/mcp", handleMCP)              // Main MCP JSON-RPC endpoint
-	auth.GET("/mcp", handleMCP)               // MCP SSE endpoint (for Cline and similar clients)
-	auth.DELETE("/mcp", handleMCP)            // MCP session termination endpoint
-	auth.POST("/mcp/batch", handleMCPBatch)   // Batch MCP requests
-	auth.GET("/mcp/info", getMCPInfo)         // MCP server information
-	auth.GET("/mcp/manifest", getMCPManifest) // MCP server manifest
-	auth.GET("/mcp/stats", getMCPStats)       // MCP statistics
-	auth.GET("/mcp/health", mcpHealthCheck)   // MCP health check
-	auth.GET("/mcp/ws", handleMCPWebSocket)   // WebSocket endpoint (future)
+	viewer.POST("/mcp", handleMCP)              // Main MCP JSON-RPC endpoint
+	viewer.GET("/mcp", handleMCP)               // MCP SSE endpoint (for Cline and similar clients)
+	viewer.DELETE("/mcp", handleMCP)            // MCP session termination endpoint
+	viewer.POST("/mcp/batch", handleMCPBatch)   // Batch MCP requests
+	viewer.GET("/mcp/info", getMCPInfo)         // MCP server information
+	viewer.GET("/mcp/manifest", getMCPManifest) // MCP server manifest
+	viewer.GET("/mcp/stats", getMCPStats)       // MCP statistics
+	viewer.GET("/mcp/health", mcpHealthCheck)   // MCP health check
+	viewer.GET("/mcp/ws", handleMCPWebSocket)   // WebSocket endpoint (future)
 
 	// MCP Configuration endpoints - REQUIRE AUTH
-	auth.GET("/mcp/prompts", mcp.GetMCPPrompts) // MCP prompts configuration
-	auth.GET("/mcp/configs", mcp.GetMCPConfigs) // All MCP configurations
+	viewer.GET("/mcp/prompts", mcp.GetMCPPrompts) // MCP prompts configuration
+	viewer.GET("/mcp/configs", mcp.GetMCPConfigs) // All MCP configurations
 
 	// MCP Installation endpoints (public access for easy setup)
 	e.GET("/mcp/install", getMCPInstallConfig) // MCP installation configuration
 
 	// Plugin statistics endpoint - REQUIRE AUTH
-	auth.GET("/plugin-stats", GetPluginStats)
+	viewer.GET("/plugin-stats", GetPluginStats)
+
+	// Threat intel feeds of this node - REQUIRE AUTH
+	viewer.GET("/threat-intel/feeds", getThreatIntelFeeds)
+	viewer.GET("/threat-intel/match", matchThreatIntel)
+	operator.POST("/threat-intel/feeds/:name/refresh", refreshThreatIntelFeed)
+
+	// GeoIP databases of this node - REQUIRE AUTH
+	viewer.GET("/geoip", getGeoIPDatabases)
+	viewer.GET("/geoip/lookup", lookupGeoIP)
+
+	// Rule suppressions, shared ones and the local ones of this node - REQUIRE AUTH
+	viewer.GET("/suppressions", getSuppressions)
+	operator.DELETE("/suppressions", clearSuppressions)
+	operator.DELETE("/suppressions/:id", clearSuppressions)
+
+	// User and API token management - REQUIRE ADMIN (except the caller's own identity)
+	viewer.GET("/users/me", getCurrentUser)
+	viewer.POST("/auth/logout", logout)
+	admin.GET("/users", getUsers)
+	admin.POST("/users", createUser)
+	admin.PUT("/users/:name", updateUser)
+	admin.DELETE("/users/:name", deleteUser)
+	admin.POST("/users/:name/tokens", createUserToken)
+	admin.DELETE("/users/:name/tokens/:tokenId", revokeUserToken)
 
 	if err := e.Start(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"AgentSmith-HUB/rules_engine"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// importSigmaRuleset converts Sigma rules into ruleset XML and validates the result.
+// Nothing is saved, create the ruleset from the returned XML with POST /rulesets.
+func importSigmaRuleset(c echo.Context) error {
+	var req struct {
+		Sigma        string            `json:"sigma"`
+		Name         string            `json:"name"`
+		Author       string            `json:"author"`
+		FieldMapping map[string]string `json:"field_mapping"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if strings.TrimSpace(req.Sigma) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "sigma cannot be empty"})
+	}
+
+	result, err := rules_engine.ConvertSigma(req.Sigma, rules_engine.SigmaConvertOptions{
+		Name:         req.Name,
+		Author:       req.Author,
+		FieldMapping: req.FieldMapping,
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	resp := map[string]interface{}{
+		"xml":       result.XML,
+		"rules":     result.Rules,
+		"converted": result.Converted,
+		"skipped":   result.Skipped,
+	}
+	if result.Converted == 0 {
+		resp["valid"] = false
+		resp["errors"] = []rules_engine.ValidationError{{Line: 1, Message: "no Sigma rule could be converted"}}
+		resp["warnings"] = []rules_engine.ValidationWarning{}
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	validation, err := rules_engine.ValidateWithDetails("", result.XML)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to validate converted ruleset: " + err.Error()})
+	}
+	resp["valid"] = validation.IsValid
+	resp["errors"] = validation.Errors
+	resp["warnings"] = validation.Warnings
+	return c.JSON(http.StatusOK, resp)
+}
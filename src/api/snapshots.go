@@ -0,0 +1,310 @@
+package api
+
+import (
+	"AgentSmith-HUB/cluster"
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/gitops"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/project"
+	"AgentSmith-HUB/rules_engine"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rollbackMu allows one rollback or GitOps sync at a time
+var rollbackMu sync.Mutex
+
+// CreateSnapshotRequest names a snapshot of the current component set
+type CreateSnapshotRequest struct {
+	Name    string `json:"name"`
+	Comment string `json:"comment"`
+}
+
+// getSnapshots lists the stored config snapshots
+func getSnapshots(c echo.Context) error {
+	snapshots, err := cluster.ListSnapshots()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshots": snapshots,
+		"total":     len(snapshots),
+		"version":   cluster.GlobalInstructionManager.GetCurrentVersion(),
+	})
+}
+
+// getSnapshot returns a snapshot with the content of its components
+func getSnapshot(c echo.Context) error {
+	s, err := cluster.GetSnapshot(c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, s)
+}
+
+// createSnapshot saves the current component set under a new name
+func createSnapshot(c echo.Context) error {
+	var req CreateSnapshotRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+	if err := cluster.ValidateSnapshotName(req.Name); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if cluster.SnapshotExists(req.Name) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("snapshot %s already exists", req.Name)})
+	}
+
+	s, err := cluster.CaptureSnapshot(req.Name, req.Comment, operatorFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := cluster.SaveSnapshot(s); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.Info("Config snapshot created", "name", s.Name, "version", s.Version, "operator", s.Operator)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Snapshot created successfully",
+		"name":    s.Name,
+		"version": s.Version,
+	})
+}
+
+// deleteSnapshot removes a stored snapshot
+func deleteSnapshot(c echo.Context) error {
+	name := c.Param("name")
+	if err := cluster.DeleteSnapshot(name); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Snapshot deleted successfully"})
+}
+
+// diffSnapshots compares two snapshots, "current" stands for the live component set
+// Query params: from (default: current), to
+func diffSnapshots(c echo.Context) error {
+	fromName := c.QueryParam("from")
+	if fromName == "" {
+		fromName = cluster.CurrentSnapshotName
+	}
+	toName := c.QueryParam("to")
+	if toName == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "to is required"})
+	}
+
+	from, err := cluster.GetSnapshot(fromName)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	to, err := cluster.GetSnapshot(toName)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, cluster.DiffSnapshots(from, to))
+}
+
+// rollbackToSnapshot returns the cluster to a snapshot. The current component set is saved as
+// before-rollback-<time> first. Every component that differs is updated, added or deleted like a
+// pushed change, which publishes the instructions that bring the followers along, and the projects
+// are started and stopped to match the snapshot. When a change fails the others stay applied and the
+// request fails, naming the backup that undoes the rollback.
+func rollbackToSnapshot(c echo.Context) error {
+	name := c.Param("name")
+	operator := operatorFromContext(c)
+
+	if !rollbackMu.TryLock() {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "another rollback is in progress"})
+	}
+	defer rollbackMu.Unlock()
+
+	target, err := cluster.GetSnapshot(name)
+	if err != nil || name == cluster.CurrentSnapshotName {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("snapshot %s not found", name)})
+	}
+
+	backupName := "before-rollback-" + time.Now().Format("20060102-150405")
+	current, err := cluster.CaptureSnapshot(backupName, "Automatic snapshot before rolling back to "+name, operator)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := cluster.SaveSnapshot(current); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	diff := cluster.DiffSnapshots(current, target)
+	logger.Info("Rolling back to config snapshot", "snapshot", name, "version", target.Version, "changes", len(diff.Components), "backup", backupName, "operator", operator)
+
+	targetRunning := make(map[string]bool, len(target.RunningProjects))
+	for _, id := range target.RunningProjects {
+		targetRunning[id] = true
+	}
+
+	// Stop the running projects that change before their components do, they are started again below
+	var toStart []string
+	for _, d := range diff.Components {
+		if d.Type != "project" || d.Change != "modified" || !targetRunning[d.ID] {
+			continue
+		}
+		if p, ok := project.GetProject(d.ID); ok && p.Status == common.StatusRunning {
+			if err := p.Stop(true); err != nil {
+				logger.Error("Failed to stop project before rollback", "project", d.ID, "error", err)
+			}
+			toStart = append(toStart, d.ID)
+		}
+	}
+	for _, id := range diff.ProjectsStopped {
+		stopProjectForRollback(id, operator)
+	}
+
+	applied := 0
+	failedChanges := []FailedChangeInfo{}
+	allAffectedProjects := make(map[string]bool)
+
+	// Test cases first, rulesets are verified against them
+	for _, d := range diff.TestCases {
+		path := rules_engine.TestCasePath(d.ID)
+		if d.Change == "removed" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logger.Error("Failed to remove ruleset test cases", "ruleset", d.ID, "error", err)
+			}
+			gitops.CommitTestCases(d.ID, "", operator)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(target.TestCases[d.ID]), 0644); err != nil {
+			logger.Error("Failed to restore ruleset test cases", "ruleset", d.ID, "error", err)
+			failedChanges = append(failedChanges, FailedChangeInfo{Type: d.Type, ID: d.ID, Error: "failed to restore test cases: " + err.Error()})
+			continue
+		}
+		gitops.CommitTestCases(d.ID, target.TestCases[d.ID], operator)
+	}
+
+	for _, d := range diff.ApplyOrder() {
+		if d.Change == "removed" {
+			if err := removeComponent(d.Type, d.ID, operator); err != nil {
+				logger.Error("Failed to remove component during rollback", "type", d.Type, "id", d.ID, "error", err)
+				failedChanges = append(failedChanges, FailedChangeInfo{Type: d.Type, ID: d.ID, Error: err.Error()})
+				continue
+			}
+			gitops.CommitComponentDelete(d.Type, d.ID, operator)
+			applied++
+			continue
+		}
+
+		affectedProjects, err := reloadComponentUnified(&ComponentReloadRequest{
+			Type:        d.Type,
+			ID:          d.ID,
+			NewContent:  target.Components[d.Type][d.ID],
+			OldContent:  current.Components[d.Type][d.ID],
+			Source:      SourceChangePush,
+			WriteToFile: true,
+			Operator:    operator,
+		})
+		if err != nil {
+			logger.Error("Failed to roll back component", "type", d.Type, "id", d.ID, "error", err)
+			failedChanges = append(failedChanges, FailedChangeInfo{Type: d.Type, ID: d.ID, Error: err.Error()})
+			continue
+		}
+		applied++
+		for _, id := range affectedProjects {
+			allAffectedProjects[id] = true
+		}
+	}
+
+	toStart = append(toStart, diff.ProjectsStarted...)
+	for _, id := range toStart {
+		startProjectForRollback(id, operator)
+		delete(allAffectedProjects, id)
+	}
+
+	// Restart the other running projects using changed components, like applying pending changes does
+	projectsToRestart := make([]string, 0, len(allAffectedProjects))
+	for id := range allAffectedProjects {
+		if targetRunning[id] {
+			projectsToRestart = append(projectsToRestart, id)
+		}
+	}
+	sort.Strings(projectsToRestart)
+	if len(projectsToRestart) > 0 {
+		go func() {
+			for _, id := range projectsToRestart {
+				if p, ok := project.GetProject(id); ok {
+					if err := p.Restart(true, "snapshot_rollback"); err != nil {
+						logger.Error("Failed to restart project after rollback", "project_id", id, "error", err)
+					}
+				}
+			}
+		}()
+	}
+
+	logger.Info("Rollback to config snapshot finished", "snapshot", name, "applied", applied, "failed", len(failedChanges))
+	status := http.StatusOK
+	message := fmt.Sprintf("Rolled back %d/%d changes to snapshot %s", applied, len(diff.Components), name)
+	response := map[string]interface{}{
+		"message":               message,
+		"snapshot":              name,
+		"backup_snapshot":       backupName,
+		"total_changes":         len(diff.Components),
+		"applied_changes":       applied,
+		"failed_changes":        len(failedChanges),
+		"failed_change_details": failedChanges,
+		"projects_started":      toStart,
+		"projects_stopped":      diff.ProjectsStopped,
+		"projects_to_restart":   projectsToRestart,
+		"version":               cluster.GlobalInstructionManager.GetCurrentVersion(),
+	}
+
+	// The cluster is between the two configs now, the backup snapshot brings it back
+	if len(failedChanges) > 0 {
+		status = http.StatusInternalServerError
+		details := make([]string, 0, len(failedChanges))
+		for _, f := range failedChanges {
+			details = append(details, fmt.Sprintf("%s %s: %s", f.Type, f.ID, f.Error))
+		}
+		response["error"] = fmt.Sprintf("%s, %d failed (%s). Roll back to %s to restore the previous config",
+			message, len(failedChanges), strings.Join(details, "; "), backupName)
+	}
+	return c.JSON(status, response)
+}
+
+// startProjectForRollback starts a project on all nodes, see StartProject
+func startProjectForRollback(id, operator string) {
+	p, ok := project.GetProject(id)
+	if !ok {
+		return
+	}
+	if err := common.SetProjectUserIntention(id, true); err != nil {
+		logger.Warn("Failed to persist project user intention to Redis (proj_states)", "project", id, "error", err)
+	}
+	syncProjectOperationToFollowers(id, "start")
+	if err := p.Start(true); err != nil {
+		RecordProjectOperation(OpTypeProjectStart, id, "failed", err.Error(), operator, nil)
+		logger.Error("Failed to start project during rollback", "project", id, "error", err)
+		return
+	}
+	RecordProjectOperation(OpTypeProjectStart, id, "success", "", operator, nil)
+}
+
+// stopProjectForRollback stops a project on all nodes, see StopProject
+func stopProjectForRollback(id, operator string) {
+	p, ok := project.GetProject(id)
+	if !ok {
+		return
+	}
+	syncProjectOperationToFollowers(id, "stop")
+	if err := p.Stop(true); err != nil {
+		RecordProjectOperation(OpTypeProjectStop, id, "failed", err.Error(), operator, nil)
+		logger.Error("Failed to stop project during rollback", "project", id, "error", err)
+		return
+	}
+	if err := common.SetProjectUserIntention(id, false); err != nil {
+		logger.Warn("Failed to update project user intention to Redis (proj_states)", "project", id, "error", err)
+	}
+	RecordProjectOperation(OpTypeProjectStop, id, "success", "", operator, nil)
+}
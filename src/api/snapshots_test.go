@@ -0,0 +1,103 @@
+package api
+
+import (
+	"AgentSmith-HUB/cluster"
+	"AgentSmith-HUB/common"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+)
+
+func setupRollbackTest(t *testing.T) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	prevConfig, prevManager := common.Config, cluster.GlobalInstructionManager
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir(), DataDir: t.TempDir()}
+	for _, dir := range []string{"input", "output", "ruleset", "project", "plugin", "lookup"} {
+		os.MkdirAll(filepath.Join(common.Config.ConfigRoot, dir), 0755)
+	}
+	cluster.GlobalInstructionManager = &cluster.InstructionManager{}
+	common.SetClusterState(true, "node-a")
+	common.ClearAllRawConfigsForAllTypes()
+	t.Cleanup(func() {
+		common.Config, cluster.GlobalInstructionManager = prevConfig, prevManager
+		common.ClearAllRawConfigsForAllTypes()
+	})
+}
+
+func serveRollback(t *testing.T, name string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/snapshots/"+name+"/rollback", nil), rec)
+	c.SetParamNames("name")
+	c.SetParamValues(name)
+	if err := rollbackToSnapshot(c); err != nil {
+		t.Fatalf("rollbackToSnapshot returned %v", err)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+func TestRollbackToSnapshot(t *testing.T) {
+	setupRollbackTest(t)
+
+	if rec, _ := serveRollback(t, "missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("rollback to a missing snapshot = %d, expected 404", rec.Code)
+	}
+	if rec, _ := serveRollback(t, cluster.CurrentSnapshotName); rec.Code != http.StatusNotFound {
+		t.Errorf("rollback to the current config = %d, expected 404", rec.Code)
+	}
+
+	same, err := cluster.CaptureSnapshot("same", "", "tester")
+	if err != nil {
+		t.Fatalf("CaptureSnapshot failed: %v", err)
+	}
+	cluster.SaveSnapshot(same)
+	if rec, body := serveRollback(t, "same"); rec.Code != http.StatusOK || body["total_changes"] != 0.0 {
+		t.Errorf("rollback without changes = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRollbackToSnapshotPartialFailure(t *testing.T) {
+	setupRollbackTest(t)
+
+	broken := &cluster.ConfigSnapshot{
+		Name:            "broken",
+		Components:      map[string]map[string]string{"output": {"bad": "type: nonexistent\n"}},
+		RunningProjects: []string{},
+	}
+	if err := cluster.SaveSnapshot(broken); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	rec, body := serveRollback(t, "broken")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("rollback with a failing change = %d, expected 500: %s", rec.Code, rec.Body.String())
+	}
+	backup, _ := body["backup_snapshot"].(string)
+	errText, _ := body["error"].(string)
+	if body["failed_changes"] != 1.0 || body["applied_changes"] != 0.0 {
+		t.Errorf("failed %v and applied %v changes, expected 1 and 0", body["failed_changes"], body["applied_changes"])
+	}
+	if !strings.Contains(errText, "output bad") || backup == "" || !strings.Contains(errText, backup) {
+		t.Errorf("error = %q, expected the failed change and the backup %q", errText, backup)
+	}
+	if !cluster.SnapshotExists(backup) {
+		t.Errorf("backup snapshot %s was not saved", backup)
+	}
+	if _, err := os.Stat(filepath.Join(common.Config.ConfigRoot, "output", "bad.yaml")); !os.IsNotExist(err) {
+		t.Error("the failed component was written")
+	}
+}
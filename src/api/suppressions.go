@@ -0,0 +1,83 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/project"
+	"AgentSmith-HUB/rules_engine"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+)
+
+// suppressionFilter matches suppressions by the ruleset and rule query params and the id path param
+func suppressionFilter(c echo.Context) func(info *rules_engine.SuppressionInfo) bool {
+	rulesetID := c.QueryParam("ruleset")
+	ruleID := c.QueryParam("rule")
+	id := c.Param("id")
+	return func(info *rules_engine.SuppressionInfo) bool {
+		return (rulesetID == "" || info.RulesetID == rulesetID) &&
+			(ruleID == "" || info.RuleID == ruleID) &&
+			(id == "" || info.ID == id)
+	}
+}
+
+// getSuppressions lists the active rule suppressions
+// Query params: ruleset, rule (optional filters)
+func getSuppressions(c echo.Context) error {
+	shared, err := rules_engine.RedisSuppressions()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read suppressions: " + err.Error()})
+	}
+
+	match := suppressionFilter(c)
+	suppressions := make([]rules_engine.SuppressionInfo, 0, len(shared))
+	for i := range shared {
+		if match(&shared[i]) {
+			suppressions = append(suppressions, shared[i])
+		}
+	}
+	project.ForEachPNSRuleset(func(pns string, rs *rules_engine.Ruleset) bool {
+		for _, info := range rs.LocalSuppressions() {
+			if match(&info) {
+				suppressions = append(suppressions, info)
+			}
+		}
+		return true
+	})
+
+	sort.Slice(suppressions, func(i, j int) bool {
+		return suppressions[i].WindowStart.After(suppressions[j].WindowStart)
+	})
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id":      common.GetNodeID(),
+		"suppressions": suppressions,
+		"total":        len(suppressions),
+	})
+}
+
+// clearSuppressions removes suppressions so the next alert of their keys is emitted right away,
+// alerts suppressed so far are dropped. Either the id path param or the ruleset query param is required.
+// Query params: ruleset, rule
+func clearSuppressions(c echo.Context) error {
+	if c.Param("id") == "" && c.QueryParam("ruleset") == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "suppression id or ruleset is required"})
+	}
+
+	match := suppressionFilter(c)
+	cleared, err := rules_engine.ClearRedisSuppressions(match)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to clear suppressions: " + err.Error()})
+	}
+	project.ForEachPNSRuleset(func(pns string, rs *rules_engine.Ruleset) bool {
+		cleared += rs.ClearLocalSuppressions(match)
+		return true
+	})
+
+	if c.Param("id") != "" && cleared == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "suppression not found"})
+	}
+	logger.Info("Rule suppressions cleared", "ruleset", c.QueryParam("ruleset"), "rule", c.QueryParam("rule"), "id", c.Param("id"), "count", cleared)
+	return c.JSON(http.StatusOK, map[string]interface{}{"cleared": cleared})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/rules_engine"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+)
+
+func callSuppressionHandler(t *testing.T, handler echo.HandlerFunc, method, query, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(method, "/suppressions?"+query, nil), rec)
+	if id != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+	if err := handler(c); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	return rec
+}
+
+func TestSuppressionsAPI(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+
+	rs, err := rules_engine.NewRuleset("", `<root type="DETECTION">
+    <rule id="login" suppress_by="user" suppress_window="10m">
+        <check type="NOTNULL" field="user"></check>
+    </rule>
+</root>`, "suppress_api")
+	if err != nil {
+		t.Fatalf("NewRuleset failed: %v", err)
+	}
+	rs.ProjectNodeSequence = "p.suppress_api"
+	for _, user := range []string{"alice", "alice", "alice", "bob"} {
+		rs.EngineCheck(map[string]interface{}{"user": user})
+	}
+
+	var list struct {
+		Suppressions []rules_engine.SuppressionInfo `json:"suppressions"`
+		Total        int                            `json:"total"`
+	}
+	rec := callSuppressionHandler(t, getSuppressions, http.MethodGet, "ruleset=suppress_api", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("getSuppressions = %d: %s", rec.Code, rec.Body.String())
+	}
+	if list.Total != 2 {
+		t.Fatalf("getSuppressions total = %d, expected 2", list.Total)
+	}
+	var alice string
+	for _, info := range list.Suppressions {
+		if info.Values["user"] == "alice" {
+			alice = info.ID
+			if info.Suppressed != 2 || info.LastSeen == nil {
+				t.Errorf("suppression of alice = %+v, expected 2 suppressed", info)
+			}
+		}
+	}
+	rec = callSuppressionHandler(t, getSuppressions, http.MethodGet, "ruleset=other", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || list.Total != 0 {
+		t.Errorf("getSuppressions(other) = %s, expected none", rec.Body.String())
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		id      string
+		status  int
+		cleared int
+	}{
+		{"no filter", "", "", http.StatusBadRequest, 0},
+		{"unknown id", "", "unknown", http.StatusNotFound, 0},
+		{"by id", "", alice, http.StatusOK, 1},
+		{"by ruleset", "ruleset=suppress_api&rule=login", "", http.StatusOK, 1},
+		{"nothing left", "ruleset=suppress_api", "", http.StatusOK, 0},
+	}
+	for _, test := range tests {
+		rec := callSuppressionHandler(t, clearSuppressions, http.MethodDelete, test.query, test.id)
+		var res struct {
+			Cleared int `json:"cleared"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if rec.Code != test.status || res.Cleared != test.cleared {
+			t.Errorf("%s: clearSuppressions = %d %s, expected %d with %d cleared", test.name, rec.Code, rec.Body.String(), test.status, test.cleared)
+		}
+	}
+
+	// The next alert of a cleared key is emitted
+	if res := rs.EngineCheck(map[string]interface{}{"user": "alice"}); len(res) != 1 {
+		t.Errorf("EngineCheck after clearing = %v, expected the alert", res)
+	}
+}
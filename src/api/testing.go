@@ -354,17 +354,9 @@ func testPlugin(c echo.Context) error {
 			})
 		}
 	case plugin.YAEGI_PLUGIN:
-		// For Yaegi plugins, we need to determine the return type
+		// Temporary plugins are already loaded and initialized by NewTestPlugin, close them when done
 		if isTemporary {
-			// For temporary plugins, we need to load them first since they're not in the global registry
-			err := pluginToTest.YaegiLoad()
-			if err != nil {
-				return c.JSON(http.StatusOK, map[string]interface{}{
-					"success": false,
-					"error":   fmt.Sprintf("Failed to load temporary plugin: %v", err),
-					"result":  nil,
-				})
-			}
+			defer pluginToTest.Close()
 		}
 
 		// Execute plugin based on return type
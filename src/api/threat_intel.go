@@ -0,0 +1,49 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/threat_intel"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// getThreatIntelFeeds returns the threat intel feeds of this node with their indicator counts and last pulls
+func getThreatIntelFeeds(c echo.Context) error {
+	hits, misses := threat_intel.Stats()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id": common.GetNodeID(),
+		"feeds":   threat_intel.Feeds(),
+		"hits":    hits,
+		"misses":  misses,
+	})
+}
+
+// refreshThreatIntelFeed pulls a feed of this node now instead of waiting for its interval
+func refreshThreatIntelFeed(c echo.Context) error {
+	status, err := threat_intel.Refresh(c.Param("name"))
+	if err != nil {
+		if status.Name == "" {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadGateway, map[string]interface{}{"error": err.Error(), "feed": status})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"feed": status})
+}
+
+// matchThreatIntel checks a value against the loaded indicators like the isIOC plugin
+// Query params: value (required), type (ip/domain/url/hash, optional)
+func matchThreatIntel(c echo.Context) error {
+	value := c.QueryParam("value")
+	if value == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "value is required"})
+	}
+	ind, err := threat_intel.Match(value, c.QueryParam("type"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if ind == nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{"match": false})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"match": true, "indicator": ind.Info()})
+}
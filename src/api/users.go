@@ -0,0 +1,264 @@
+package api
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// getCurrentUser returns the identity of the caller
+func getCurrentUser(c echo.Context) error {
+	identity := identityFromContext(c)
+	if identity == nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "not authenticated",
+		})
+	}
+	return c.JSON(http.StatusOK, identity)
+}
+
+// getUsers lists users and their tokens
+func getUsers(c echo.Context) error {
+	users, err := listUsers()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to list users: " + err.Error(),
+		})
+	}
+	tokens, err := listTokens("")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to list tokens: " + err.Error(),
+		})
+	}
+
+	userTokens := make(map[string][]APIToken, len(users))
+	for _, t := range tokens {
+		userTokens[t.User] = append(userTokens[t.User], t.APIToken)
+	}
+
+	result := make([]map[string]interface{}, 0, len(users))
+	for _, u := range users {
+		ts := userTokens[u.Name]
+		if ts == nil {
+			ts = []APIToken{}
+		}
+		result = append(result, map[string]interface{}{
+			"user":   u,
+			"tokens": ts,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"users": result,
+		"total": len(result),
+	})
+}
+
+// createUser adds a user or service account.
+// Body: {"name": "...", "role": "viewer|rule_author|operator|admin", "kind": "user|service", "description": "..."}
+func createUser(c echo.Context) error {
+	var req struct {
+		Name        string `json:"name"`
+		Role        string `json:"role"`
+		Kind        string `json:"kind"`
+		Description string `json:"description"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	if !userNameRegex.MatchString(req.Name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid user name, use letters, digits and _ . @ - (max 64)",
+		})
+	}
+	if req.Name == sharedTokenUser {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "user name '" + sharedTokenUser + "' is reserved for the cluster token",
+		})
+	}
+	if !isValidRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid role, must be one of viewer, rule_author, operator, admin",
+		})
+	}
+	if req.Kind == "" {
+		req.Kind = UserKindUser
+	}
+	if req.Kind != UserKindUser && req.Kind != UserKindService {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid kind, must be user or service",
+		})
+	}
+
+	if _, err := getUser(req.Name); err == nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "user already exists: " + req.Name,
+		})
+	} else if !errors.Is(err, errUserNotFound) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to read user: " + err.Error(),
+		})
+	}
+
+	user := &User{
+		Name:        req.Name,
+		Role:        req.Role,
+		Kind:        req.Kind,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		CreatedBy:   operatorFromContext(c),
+	}
+	if err := saveUser(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to save user: " + err.Error(),
+		})
+	}
+
+	logger.Info("User created", "user", user.Name, "role", user.Role, "kind", user.Kind, "by", user.CreatedBy)
+	return c.JSON(http.StatusCreated, user)
+}
+
+// updateUser changes the role, description or disabled state of a user.
+// Body: {"role": "...", "description": "...", "disabled": true}, omitted fields are unchanged
+func updateUser(c echo.Context) error {
+	var req struct {
+		Role        *string `json:"role"`
+		Description *string `json:"description"`
+		Disabled    *bool   `json:"disabled"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	user, err := getUser(c.Param("name"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if req.Role != nil {
+		if !isValidRole(*req.Role) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid role, must be one of viewer, rule_author, operator, admin",
+			})
+		}
+		user.Role = *req.Role
+	}
+	if req.Description != nil {
+		user.Description = *req.Description
+	}
+	if req.Disabled != nil {
+		user.Disabled = *req.Disabled
+	}
+
+	if err := saveUser(user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to save user: " + err.Error(),
+		})
+	}
+
+	logger.Info("User updated", "user", user.Name, "role", user.Role, "disabled", user.Disabled, "by", operatorFromContext(c))
+	return c.JSON(http.StatusOK, user)
+}
+
+// deleteUser removes a user together with all of its tokens
+func deleteUser(c echo.Context) error {
+	user, err := getUser(c.Param("name"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := deleteUserTokens(user.Name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to delete user tokens: " + err.Error(),
+		})
+	}
+	if err := common.RedisHDel(rbacUsersKey, user.Name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to delete user: " + err.Error(),
+		})
+	}
+
+	logger.Info("User deleted", "user", user.Name, "by", operatorFromContext(c))
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "user deleted: " + user.Name,
+	})
+}
+
+// createUserToken issues a new token for a user, the token is only returned in this response.
+// Body: {"note": "...", "expires_in_days": 90}, expires_in_days 0 means the token does not expire
+func createUserToken(c echo.Context) error {
+	var req struct {
+		Note          string `json:"note"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+	if req.ExpiresInDays < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "expires_in_days cannot be negative",
+		})
+	}
+
+	user, err := getUser(c.Param("name"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	plain, token, err := issueToken(user.Name, req.Note, operatorFromContext(c), ttl)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to create token: " + err.Error(),
+		})
+	}
+
+	logger.Info("API token created", "user", user.Name, "token_id", token.ID, "by", token.CreatedBy)
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"token":   plain,
+		"info":    token,
+		"message": "Store this token now, it cannot be shown again",
+	})
+}
+
+// revokeUserToken revokes a token by its ID
+func revokeUserToken(c echo.Context) error {
+	name := c.Param("name")
+	token, err := revokeToken(name, c.Param("tokenId"))
+	if err != nil {
+		if errors.Is(err, errTokenNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "token not found",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to revoke token: " + err.Error(),
+		})
+	}
+
+	logger.Info("API token revoked", "user", name, "token_id", token.ID, "by", operatorFromContext(c))
+	return c.JSON(http.StatusOK, token)
+}
+
+func userLookupError(c echo.Context, err error) error {
+	if errors.Is(err, errUserNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "user not found: " + c.Param("name"),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": "failed to read user: " + err.Error(),
+	})
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// callUserHandler runs a user management handler as the given identity
+func callUserHandler(t *testing.T, handler echo.HandlerFunc, method, body string, params ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names, values = append(names, params[i]), append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	c.Set(identityContextKey, &Identity{User: "root-admin", Role: RoleAdmin, Kind: UserKindUser})
+	if err := handler(c); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	return rec
+}
+
+func TestCreateUser(t *testing.T) {
+	setupRBACTest(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"user", `{"name": "alice", "role": "rule_author"}`, http.StatusCreated},
+		{"service account", `{"name": "ci.bot", "role": "operator", "kind": "service"}`, http.StatusCreated},
+		{"duplicate", `{"name": "alice", "role": "viewer"}`, http.StatusConflict},
+		{"reserved name", `{"name": "admin", "role": "viewer"}`, http.StatusBadRequest},
+		{"invalid name", `{"name": "-alice", "role": "viewer"}`, http.StatusBadRequest},
+		{"invalid role", `{"name": "bob", "role": "root"}`, http.StatusBadRequest},
+		{"invalid kind", `{"name": "bob", "role": "viewer", "kind": "robot"}`, http.StatusBadRequest},
+		{"invalid body", `{"name": `, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := callUserHandler(t, createUser, http.MethodPost, test.body)
+			if rec.Code != test.status {
+				t.Errorf("createUser(%s) = %d, expected %d: %s", test.body, rec.Code, test.status, rec.Body.String())
+			}
+		})
+	}
+
+	user, err := getUser("alice")
+	if err != nil {
+		t.Fatalf("getUser failed: %v", err)
+	}
+	if user.Role != RoleRuleAuthor || user.Kind != UserKindUser || user.CreatedBy != "root-admin" {
+		t.Errorf("created user = %+v", user)
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	setupRBACTest(t)
+	if rec := callUserHandler(t, createUser, http.MethodPost, `{"name": "alice", "role": "viewer"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("createUser = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := callUserHandler(t, createUserToken, http.MethodPost, `{"note": "laptop", "expires_in_days": 30}`, "name", "alice")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("createUserToken = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Token string            `json:"token"`
+		Info  map[string]string `json:"info"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse token response: %v", err)
+	}
+	if created.Info["expires_at"] == "" || created.Info["hash"] != "" {
+		t.Errorf("token info = %v, expected an expiry and no hash", created.Info)
+	}
+	if identity, err := authenticateToken(created.Token); err != nil || identity.Role != RoleViewer {
+		t.Fatalf("authenticateToken = %v, %v", identity, err)
+	}
+
+	if rec := callUserHandler(t, createUserToken, http.MethodPost, `{"expires_in_days": -1}`, "name", "alice"); rec.Code != http.StatusBadRequest {
+		t.Errorf("createUserToken with a negative expiry = %d, expected %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := callUserHandler(t, createUserToken, http.MethodPost, `{}`, "name", "nobody"); rec.Code != http.StatusNotFound {
+		t.Errorf("createUserToken for an unknown user = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+
+	// Omitted fields are unchanged
+	if rec := callUserHandler(t, updateUser, http.MethodPut, `{"role": "operator"}`, "name", "alice"); rec.Code != http.StatusOK {
+		t.Fatalf("updateUser = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := callUserHandler(t, updateUser, http.MethodPut, `{"role": "root"}`, "name", "alice"); rec.Code != http.StatusBadRequest {
+		t.Errorf("updateUser with an invalid role = %d, expected %d", rec.Code, http.StatusBadRequest)
+	}
+	if identity, err := authenticateToken(created.Token); err != nil || identity.Role != RoleOperator {
+		t.Errorf("authenticateToken after the update = %v, %v, expected role %s", identity, err, RoleOperator)
+	}
+
+	rec = callUserHandler(t, getUsers, http.MethodGet, "")
+	var list struct {
+		Users []struct {
+			User   User       `json:"user"`
+			Tokens []APIToken `json:"tokens"`
+		} `json:"users"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to parse users: %v", err)
+	}
+	if list.Total != 1 || len(list.Users[0].Tokens) != 1 || list.Users[0].Tokens[0].ID != created.Info["id"] {
+		t.Errorf("getUsers = %s", rec.Body.String())
+	}
+
+	if rec := callUserHandler(t, revokeUserToken, http.MethodDelete, "", "name", "alice", "tokenId", "unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("revokeUserToken(unknown) = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := callUserHandler(t, revokeUserToken, http.MethodDelete, "", "name", "alice", "tokenId", created.Info["id"]); rec.Code != http.StatusOK {
+		t.Fatalf("revokeUserToken = %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := authenticateToken(created.Token); err != errTokenRevoked {
+		t.Errorf("authenticateToken after revoking = %v, expected %v", err, errTokenRevoked)
+	}
+
+	// Deleting the user removes its tokens, a new user of the same name does not inherit them
+	if rec := callUserHandler(t, deleteUser, http.MethodDelete, "", "name", "alice"); rec.Code != http.StatusOK {
+		t.Fatalf("deleteUser = %d: %s", rec.Code, rec.Body.String())
+	}
+	if tokens, _ := listTokens("alice"); len(tokens) != 0 {
+		t.Errorf("%d tokens left after deleting the user", len(tokens))
+	}
+	if rec := callUserHandler(t, deleteUser, http.MethodDelete, "", "name", "alice"); rec.Code != http.StatusNotFound {
+		t.Errorf("deleteUser twice = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -3,6 +3,7 @@ package cluster
 import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"sync"
 	"time"
 )
 
@@ -11,16 +12,21 @@ import (
 
 // ClusterManager represents the simplified cluster manager
 type ClusterManager struct {
+	nodeID             string
 	instructionManager *InstructionManager
 	heartbeatManager   *HeartbeatManager
 	syncListener       *SyncListener
 	leaderLocker       *LeaderLocker
+	mu                 sync.Mutex
+	stopChan           chan struct{}
+	lost               chan struct{}
+	lostOnce           sync.Once
 }
 
 var GlobalClusterManager *ClusterManager
 
-// InitCluster initializes the cluster system
-func InitCluster(nodeID string, isLeader bool) {
+// InitCluster initializes the cluster system, a leader passes the leader lock it holds
+func InitCluster(nodeID string, isLeader bool, leaderLocker *LeaderLocker) {
 	// Initialize all components
 	InitInstructionManager()
 	InitHeartbeatManager(nodeID, isLeader)
@@ -28,20 +34,16 @@ func InitCluster(nodeID string, isLeader bool) {
 
 	// Create cluster manager
 	GlobalClusterManager = &ClusterManager{
+		nodeID:             nodeID,
 		instructionManager: GlobalInstructionManager,
 		heartbeatManager:   GlobalHeartbeatManager,
 		syncListener:       GlobalSyncListener,
+		leaderLocker:       leaderLocker,
+		stopChan:           make(chan struct{}),
+		lost:               make(chan struct{}),
 	}
 
-	logger.Info("Cluster initialized", "node_id", nodeID, "is_leader", isLeader)
-}
-func (cm *ClusterManager) ObtainLeaderLocker() error {
-	locker, err := ObtainLeaderLocker()
-	if err != nil {
-		return err
-	}
-	cm.leaderLocker = locker
-	return nil
+	logger.Info("Cluster initialized", "node_id", nodeID, "is_leader", isLeader, "failover", failoverEnabled())
 }
 
 // Start starts the cluster system
@@ -63,12 +65,22 @@ func (cm *ClusterManager) Start() error {
 		cm.syncListener.Start()
 	}
 
+	cm.mu.Lock()
+	locker := cm.leaderLocker
+	cm.mu.Unlock()
+	if locker != nil {
+		go cm.watchLeaderLock(locker)
+	}
+	go cm.watchLeader()
+
 	logger.Info("Cluster started successfully")
 	return nil
 }
 
 // Stop stops the cluster system
 func (cm *ClusterManager) Stop() {
+	close(cm.stopChan)
+
 	if cm.heartbeatManager != nil {
 		cm.heartbeatManager.Stop()
 	}
@@ -81,8 +93,11 @@ func (cm *ClusterManager) Stop() {
 		cm.instructionManager.Stop()
 	}
 
-	if cm.leaderLocker != nil {
-		cm.leaderLocker.Release()
+	cm.mu.Lock()
+	locker := cm.leaderLocker
+	cm.mu.Unlock()
+	if locker != nil {
+		locker.Release()
 	}
 
 	logger.Info("Cluster stopped")
@@ -99,6 +114,7 @@ func GetClusterStatus() map[string]interface{} {
 		"self_id":      common.GetNodeID(),
 		"self_address": common.GetNodeID(),
 		"status":       "follower", // Default to follower
+		"leader_id":    common.GetLeaderID(),
 		"nodes":        make(map[string]interface{}),
 	}
 
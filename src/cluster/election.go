@@ -0,0 +1,286 @@
+package cluster
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Leader election. The leader holds leaderLockerKey and publishes itself under leaderInfoKey, both expire
+// after cluster.lease_ttl once it stops extending them. Every node watches leaderInfoKey to know where to
+// forward writes and to catch up with a new leader's instruction stream. With cluster.failover, followers
+// that have synced the config of a leader also try to take the lock, the first one to get it is promoted:
+// it stops following, writes the synced components to its config_root, continues the instruction stream
+// (or starts a new session when it had not applied all of it) and runs the leader services. A leader that
+// could not extend the lock before it expired steps down, see LeadershipLost.
+
+const (
+	leaderInfoKey       = "cluster:leader"
+	leaderWatchInterval = 5 * time.Second
+)
+
+// LeaderInfo tells the nodes which node leads and where its API is
+type LeaderInfo struct {
+	NodeID string `json:"node_id"`
+	API    string `json:"api,omitempty"`
+	Since  int64  `json:"since"` // When the node became leader
+}
+
+var (
+	currentLeader   atomic.Pointer[LeaderInfo]
+	advertiseAPI    string
+	promoteHandlers []func()
+)
+
+// componentFileSuffixes are the file suffixes of the components in config_root, by type
+var componentFileSuffixes = map[string]string{
+	"input":   ".yaml",
+	"output":  ".yaml",
+	"ruleset": ".xml",
+	"project": ".yaml",
+	"plugin":  ".go",
+	"lookup":  ".yaml",
+}
+
+// SetAdvertiseAPI sets the API URL this node publishes while it leads
+func SetAdvertiseAPI(api string) {
+	advertiseAPI = strings.TrimRight(api, "/")
+}
+
+// OnPromote registers a function run after this node took over as leader, e.g. to start the leader API
+func OnPromote(fn func()) {
+	promoteHandlers = append(promoteHandlers, fn)
+}
+
+// GetLeaderInfo returns the current leader, nil while it is not known
+func GetLeaderInfo() *LeaderInfo {
+	return currentLeader.Load()
+}
+
+// failoverEnabled reports whether followers take over from a dead leader
+func failoverEnabled() bool {
+	return common.Config != nil && common.Config.Cluster.Failover
+}
+
+func (l *LeaderLocker) publishLeaderInfo() {
+	info := &LeaderInfo{NodeID: l.nodeID, API: advertiseAPI, Since: l.since}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return
+	}
+	if _, err := common.RedisSet(leaderInfoKey, string(data), int(l.ttl/time.Second)); err != nil {
+		logger.Warn("Failed to publish leader info", "error", err)
+	}
+	currentLeader.Store(info)
+	common.SetLeaderID(l.nodeID)
+}
+
+func (l *LeaderLocker) clearLeaderInfo() {
+	if info := readLeaderInfo(); info != nil && info.NodeID == l.nodeID && info.Since == l.since {
+		_ = common.RedisDel(leaderInfoKey)
+	}
+}
+
+func readLeaderInfo() *LeaderInfo {
+	data, err := common.RedisGet(leaderInfoKey)
+	if err != nil || data == "" {
+		return nil
+	}
+	var info LeaderInfo
+	if err := json.Unmarshal([]byte(data), &info); err != nil || info.NodeID == "" {
+		return nil
+	}
+	return &info
+}
+
+// parseVersion splits an instruction version "<session>.<number>"
+func parseVersion(version string) (string, int64, bool) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return "", 0, false
+	}
+	n, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[0], n, true
+}
+
+// LeadershipLost is closed when this node lost the leader lock, it must not act as leader any more
+func (cm *ClusterManager) LeadershipLost() <-chan struct{} {
+	return cm.lost
+}
+
+// watchLeaderLock steps down when the leader lock expired
+func (cm *ClusterManager) watchLeaderLock(locker *LeaderLocker) {
+	select {
+	case <-locker.Lost():
+		// Stop publishing right away, another node may already lead
+		common.SetClusterState(false, cm.nodeID)
+		common.SetLeaderState(false, "")
+		common.SetLeaderID("")
+		currentLeader.Store(nil)
+		cm.lostOnce.Do(func() { close(cm.lost) })
+	case <-cm.stopChan:
+	}
+}
+
+// watchLeader follows leader changes and, with failover, takes over when the leader is gone
+func (cm *ClusterManager) watchLeader() {
+	ticker := time.NewTicker(leaderWatchInterval)
+	defer ticker.Stop()
+
+	cm.checkLeader()
+	for {
+		select {
+		case <-ticker.C:
+			cm.checkLeader()
+		case <-cm.stopChan:
+			return
+		case <-cm.lost:
+			return
+		}
+	}
+}
+
+func (cm *ClusterManager) checkLeader() {
+	if common.IsCurrentNodeLeader() {
+		return
+	}
+	cm.followLeader(readLeaderInfo())
+
+	// A node without the cluster config would take over with nothing to lead
+	if !failoverEnabled() || !cm.syncListener.Synced() {
+		return
+	}
+	locker, err := TryObtainLeaderLocker(cm.nodeID)
+	if err != nil {
+		return
+	}
+	cm.promote(locker)
+}
+
+// followLeader records the current leader and catches up with the instruction stream of a new one
+func (cm *ClusterManager) followLeader(info *LeaderInfo) {
+	prev := currentLeader.Swap(info)
+	if info == nil {
+		if prev != nil {
+			logger.Warn("Leader is gone", "leader", prev.NodeID)
+		}
+		return
+	}
+	if prev != nil && prev.NodeID == info.NodeID && prev.Since == info.Since {
+		return
+	}
+
+	logger.Info("Following leader", "leader", info.NodeID, "api", info.API)
+	common.SetLeaderState(false, info.NodeID)
+	common.SetLeaderID(info.NodeID)
+
+	// Don't wait for the heartbeat check of the new leader, skip its compaction (version 0)
+	version, err := common.RedisGet("cluster:leader_version")
+	if err != nil || version == cm.syncListener.GetCurrentVersion() {
+		return
+	}
+	if _, n, ok := parseVersion(version); !ok || n == 0 {
+		return
+	}
+	if err := cm.syncListener.SyncInstructions(version); err != nil {
+		logger.Error("Failed to sync instructions of the new leader", "leader", info.NodeID, "error", err)
+	}
+}
+
+// promote makes this node the leader after it obtained the leader lock
+func (cm *ClusterManager) promote(locker *LeaderLocker) {
+	logger.Warn("Obtained the leader lock, taking over as leader", "node_id", cm.nodeID, "previous_leader", common.GetLeaderID())
+
+	syncedVersion := cm.syncListener.stopFollowing()
+
+	cm.mu.Lock()
+	cm.leaderLocker = locker
+	cm.mu.Unlock()
+
+	common.SetClusterState(true, cm.nodeID)
+	common.SetLeaderState(true, cm.nodeID)
+
+	if err := saveRawConfigs(); err != nil {
+		logger.Error("Failed to write the synced components to config_root", "error", err)
+	}
+	if err := cm.instructionManager.takeOver(syncedVersion); err != nil {
+		logger.Error("Failed to take over the instruction stream", "error", err)
+	}
+	cm.heartbeatManager.switchToLeader()
+
+	for _, fn := range promoteHandlers {
+		fn()
+	}
+
+	go cm.watchLeaderLock(locker)
+	logger.Info("Node promoted to leader", "node_id", cm.nodeID, "version", cm.instructionManager.GetCurrentVersion())
+}
+
+// saveRawConfigs writes the components synced from the previous leader to config_root, which the leader
+// API works on and which the node loads when it restarts as leader. Files that differ are copied to
+// <data_dir>/takeover/<time> before they are overwritten, files of other components are kept and show up
+// as local changes.
+func saveRawConfigs() error {
+	root := common.Config.ConfigRoot
+	backupDir := filepath.Join(common.GetDataDir(), "takeover", time.Now().Format("20060102-150405"))
+	var errs []error
+	for componentType, suffix := range componentFileSuffixes {
+		dir := filepath.Join(root, componentType)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		synced := make(map[string]bool)
+		common.ForEachRawConfig(componentType, func(id, config string) bool {
+			name := id + suffix
+			synced[name] = true
+			path := filepath.Join(dir, name)
+			old, err := os.ReadFile(path)
+			if err == nil && string(old) == config {
+				return true
+			}
+			if err == nil {
+				if err := backupConfigFile(filepath.Join(backupDir, componentType), name, old); err != nil {
+					errs = append(errs, err)
+					return true
+				}
+				logger.Warn("Local component differs from the synced one, overwritten", "type", componentType, "file", name, "backup", backupDir)
+			}
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				errs = append(errs, err)
+			}
+			return true
+		})
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) || synced[entry.Name()] {
+				continue
+			}
+			logger.Warn("Local component is not part of the synced config, kept as a local change", "type", componentType, "file", entry.Name())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func backupConfigFile(dir, name string, content []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), content, 0644)
+}
@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"AgentSmith-HUB/common"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func setupElectionTest(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{
+		ConfigRoot: t.TempDir(),
+		DataDir:    t.TempDir(),
+		Cluster:    common.ClusterConfig{LeaseTTL: "10s"},
+	}
+	t.Cleanup(func() {
+		common.Config = prevConfig
+		currentLeader.Store(nil)
+		common.SetLeaderID("")
+	})
+	return mr
+}
+
+func TestObtainLeaderLocker(t *testing.T) {
+	setupElectionTest(t)
+
+	a, err := ObtainLeaderLocker("node-a")
+	if err != nil {
+		t.Fatalf("ObtainLeaderLocker(node-a) failed: %v", err)
+	}
+	if info := readLeaderInfo(); info == nil || info.NodeID != "node-a" {
+		t.Errorf("leader info = %+v, expected node-a", info)
+	}
+	if id := common.GetLeaderID(); id != "node-a" {
+		t.Errorf("GetLeaderID() = %q on the leader, expected node-a", id)
+	}
+
+	if _, err := TryObtainLeaderLocker("node-b"); err == nil {
+		t.Fatal("TryObtainLeaderLocker(node-b) succeeded while node-a holds the lock")
+	}
+	start := time.Now()
+	if _, err := ObtainLeaderLocker("node-b"); err == nil {
+		t.Fatal("ObtainLeaderLocker(node-b) succeeded while node-a holds the lock")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ObtainLeaderLocker(node-b) took %v, expected it to fail without waiting", elapsed)
+	}
+
+	a.Release()
+	if info := readLeaderInfo(); info != nil {
+		t.Errorf("leader info = %+v after release, expected none", info)
+	}
+	b, err := TryObtainLeaderLocker("node-b")
+	if err != nil {
+		t.Fatalf("TryObtainLeaderLocker(node-b) after release failed: %v", err)
+	}
+	b.Release()
+}
+
+func TestObtainLeaderLockerStaleLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		obtained bool
+	}{
+		{"own lock", "node-a/previous", true},
+		{"lock of another node", "node-b/previous", false},
+		{"node id prefix only", "node-a2/previous", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mr := setupElectionTest(t)
+			mr.Set(leaderLockerKey, test.value)
+			mr.SetTTL(leaderLockerKey, time.Minute)
+
+			locker, err := ObtainLeaderLocker("node-a")
+			if (err == nil) != test.obtained {
+				t.Fatalf("ObtainLeaderLocker() error = %v, expected obtained %v", err, test.obtained)
+			}
+			if locker != nil {
+				locker.Release()
+				return
+			}
+			if value, _ := mr.Get(leaderLockerKey); value != test.value {
+				t.Errorf("lock value = %q, the lock of another node must be kept", value)
+			}
+		})
+	}
+}
+
+func TestWaitLeaderLocker(t *testing.T) {
+	mr := setupElectionTest(t)
+	// Left behind by the previous run of this leader under another node ID
+	mr.Set(leaderLockerKey, "node-old/previous")
+	mr.SetTTL(leaderLockerKey, 10*time.Second)
+
+	obtained := make(chan *LeaderLocker, 1)
+	go func() {
+		locker, err := WaitLeaderLocker("node-a")
+		if err != nil {
+			t.Errorf("WaitLeaderLocker(node-a) failed: %v", err)
+		}
+		obtained <- locker
+	}()
+
+	select {
+	case <-obtained:
+		t.Fatal("WaitLeaderLocker(node-a) returned while the lock of another node was held")
+	case <-time.After(1500 * time.Millisecond):
+	}
+	mr.FastForward(11 * time.Second)
+
+	select {
+	case locker := <-obtained:
+		if locker != nil {
+			locker.Release()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitLeaderLocker(node-a) did not obtain the lock after it expired")
+	}
+}
+
+func TestLeaderLockFailover(t *testing.T) {
+	mr := setupElectionTest(t)
+
+	a, err := ObtainLeaderLocker("node-a")
+	if err != nil {
+		t.Fatalf("ObtainLeaderLocker(node-a) failed: %v", err)
+	}
+	defer a.Release()
+
+	// node-a stops extending the lock, a follower takes over once it expires
+	mr.FastForward(11 * time.Second)
+	if info := readLeaderInfo(); info != nil {
+		t.Errorf("leader info = %+v after the lease expired, expected none", info)
+	}
+	b, err := TryObtainLeaderLocker("node-b")
+	if err != nil {
+		t.Fatalf("TryObtainLeaderLocker(node-b) after expiry failed: %v", err)
+	}
+	defer b.Release()
+
+	if err := a.refreshLock(); err == nil {
+		t.Error("node-a extended the lock held by node-b")
+	}
+	if info := readLeaderInfo(); info == nil || info.NodeID != "node-b" {
+		t.Errorf("leader info = %+v, expected node-b", info)
+	}
+}
+
+func TestFollowLeader(t *testing.T) {
+	setupElectionTest(t)
+	common.SetClusterState(false, "node-c")
+	cm := &ClusterManager{nodeID: "node-c"}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = common.GetLeaderID()
+		}
+	}()
+	cm.followLeader(&LeaderInfo{NodeID: "node-b", Since: 1})
+	<-done
+
+	if leader := common.GetLeaderID(); leader != "node-b" {
+		t.Errorf("GetLeaderID() = %q, expected node-b", leader)
+	}
+	if common.IsCurrentNodeLeader() {
+		t.Error("follower reports itself as leader")
+	}
+
+	cm.followLeader(nil)
+	if info := currentLeader.Load(); info != nil {
+		t.Errorf("current leader = %+v after it is gone, expected none", info)
+	}
+}
+
+func TestSaveRawConfigs(t *testing.T) {
+	setupElectionTest(t)
+	root := common.Config.ConfigRoot
+	common.ClearAllRawConfigsForAllTypes()
+	t.Cleanup(common.ClearAllRawConfigsForAllTypes)
+
+	files := map[string]string{
+		"input/changed.yaml":  "local",
+		"input/unsynced.yaml": "only on this node",
+		"ruleset/same.xml":    "<root/>",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	common.SetRawConfig("input", "changed", "synced")
+	common.SetRawConfig("ruleset", "same", "<root/>")
+	common.SetRawConfig("output", "new", "type: print")
+
+	if err := saveRawConfigs(); err != nil {
+		t.Fatalf("saveRawConfigs failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"input/changed.yaml":  "synced",
+		"input/unsynced.yaml": "only on this node",
+		"ruleset/same.xml":    "<root/>",
+		"output/new.yaml":     "type: print",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q (%v), expected %q", name, data, err, content)
+		}
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(common.GetDataDir(), "takeover", "*", "*", "*"))
+	if len(backups) != 1 || filepath.Base(backups[0]) != "changed.yaml" {
+		t.Fatalf("backups = %v, expected only changed.yaml", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "local" {
+		t.Errorf("backup = %q, expected the local content", data)
+	}
+}
@@ -14,6 +14,7 @@ import (
 // HeartbeatData represents heartbeat information
 type HeartbeatData struct {
 	NodeID         string  `json:"node_id"`
+	API            string  `json:"api,omitempty"` // API URL of the node, the leader forwards node-local requests there
 	Version        string  `json:"version"`
 	Timestamp      int64   `json:"timestamp"`
 	CPUPercent     float64 `json:"cpu_percent"`
@@ -45,27 +46,46 @@ func InitHeartbeatManager(nodeID string, isLeader bool) {
 
 // Start starts the heartbeat manager
 func (hm *HeartbeatManager) Start() {
-	if hm.isLeader {
-		go hm.startLeaderHeartbeat()
+	hm.mu.RLock()
+	isLeader, stop := hm.isLeader, hm.stopChan
+	hm.mu.RUnlock()
+
+	if isLeader {
+		go hm.startLeaderHeartbeat(stop)
 	} else {
-		go hm.startFollowerHeartbeat()
+		go hm.startFollowerHeartbeat(stop)
+	}
+}
+
+// switchToLeader stops the follower heartbeat and starts the leader services
+func (hm *HeartbeatManager) switchToLeader() {
+	hm.mu.Lock()
+	if hm.isLeader {
+		hm.mu.Unlock()
+		return
 	}
+	close(hm.stopChan)
+	hm.stopChan = make(chan struct{})
+	hm.isLeader = true
+	hm.mu.Unlock()
+
+	hm.Start()
 }
 
 // startLeaderHeartbeat starts leader heartbeat services
-func (hm *HeartbeatManager) startLeaderHeartbeat() {
+func (hm *HeartbeatManager) startLeaderHeartbeat(stop <-chan struct{}) {
 	// Listen for follower heartbeats
-	go hm.listenHeartbeats()
+	go hm.listenHeartbeats(stop)
 
 	// Clean up offline nodes
-	go hm.cleanupOfflineNodes()
+	go hm.cleanupOfflineNodes(stop)
 
 	// Update leader's own system metrics
-	go hm.updateLeaderSystemMetrics()
+	go hm.updateLeaderSystemMetrics(stop)
 }
 
 // updateLeaderSystemMetrics periodically updates leader's own system metrics
-func (hm *HeartbeatManager) updateLeaderSystemMetrics() {
+func (hm *HeartbeatManager) updateLeaderSystemMetrics(stop <-chan struct{}) {
 	if !common.IsCurrentNodeLeader() {
 		return
 	}
@@ -82,14 +102,14 @@ func (hm *HeartbeatManager) updateLeaderSystemMetrics() {
 					common.GlobalClusterSystemManager.AddSystemMetrics(metrics)
 				}
 			}
-		case <-hm.stopChan:
+		case <-stop:
 			return
 		}
 	}
 }
 
 // startFollowerHeartbeat starts follower heartbeat services
-func (hm *HeartbeatManager) startFollowerHeartbeat() {
+func (hm *HeartbeatManager) startFollowerHeartbeat(stop <-chan struct{}) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -97,7 +117,7 @@ func (hm *HeartbeatManager) startFollowerHeartbeat() {
 		select {
 		case <-ticker.C:
 			hm.sendHeartbeat()
-		case <-hm.stopChan:
+		case <-stop:
 			return
 		}
 	}
@@ -128,6 +148,7 @@ func (hm *HeartbeatManager) sendHeartbeat() {
 
 	heartbeat := HeartbeatData{
 		NodeID:         hm.nodeID,
+		API:            advertiseAPI,
 		Version:        currentVersion,
 		Timestamp:      time.Now().Unix(),
 		CPUPercent:     cpuPercent,
@@ -149,7 +170,7 @@ func (hm *HeartbeatManager) sendHeartbeat() {
 }
 
 // listenHeartbeats listens for heartbeats and handles version sync (leader only)
-func (hm *HeartbeatManager) listenHeartbeats() {
+func (hm *HeartbeatManager) listenHeartbeats(stop <-chan struct{}) {
 	if !common.IsCurrentNodeLeader() {
 		return
 	}
@@ -210,7 +231,7 @@ func (hm *HeartbeatManager) listenHeartbeats() {
 			// Check version and send sync command if needed
 			hm.checkVersionSync(heartbeat)
 
-		case <-hm.stopChan:
+		case <-stop:
 			return
 		}
 	}
@@ -281,7 +302,7 @@ func (hm *HeartbeatManager) checkVersionSync(heartbeat HeartbeatData) {
 }
 
 // cleanupOfflineNodes removes offline nodes
-func (hm *HeartbeatManager) cleanupOfflineNodes() {
+func (hm *HeartbeatManager) cleanupOfflineNodes(stop <-chan struct{}) {
 	if !common.IsCurrentNodeLeader() {
 		return
 	}
@@ -304,7 +325,7 @@ func (hm *HeartbeatManager) cleanupOfflineNodes() {
 				}
 			}
 			hm.mu.Unlock()
-		case <-hm.stopChan:
+		case <-stop:
 			return
 		}
 	}
@@ -322,7 +343,23 @@ func (hm *HeartbeatManager) GetNodes() map[string]HeartbeatData {
 	return nodes
 }
 
+// GetNodeAPI returns the API URL of a node known from its heartbeats
+func GetNodeAPI(nodeID string) (string, bool) {
+	if GlobalHeartbeatManager == nil {
+		return "", false
+	}
+	GlobalHeartbeatManager.mu.RLock()
+	defer GlobalHeartbeatManager.mu.RUnlock()
+	node, ok := GlobalHeartbeatManager.nodes[nodeID]
+	if !ok || node.API == "" {
+		return "", false
+	}
+	return node.API, true
+}
+
 // Stop stops the heartbeat manager
 func (hm *HeartbeatManager) Stop() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
 	close(hm.stopChan)
 }
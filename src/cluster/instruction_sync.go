@@ -15,7 +15,7 @@ import (
 type Instruction struct {
 	Version         int64                  `json:"version"`
 	ComponentName   string                 `json:"component_name"`
-	ComponentType   string                 `json:"component_type"` // project, input, output, ruleset, plugin
+	ComponentType   string                 `json:"component_type"` // project, input, output, ruleset, plugin, lookup
 	Content         string                 `json:"content"`
 	Operation       string                 `json:"operation"`    // add, delete, start, restart, stop, update, local_push, push_change
 	Dependencies    []string               `json:"dependencies"` // affected projects that need restart
@@ -442,7 +442,15 @@ func (im *InstructionManager) InitializeLeaderInstructions() error {
 		return true
 	})
 
-	// 4. Add all rulesets (projects depend on rulesets)
+	// 4. Add all lookup tables (rulesets may reference lookups)
+	common.ForEachRawConfig("lookup", func(lookupID, config string) bool {
+		if err := publishInstructionDirectly(lookupID, "lookup", config, "add", nil, nil); err != nil {
+			logger.Error("Failed to publish lookup add instruction", "lookup", lookupID, "error", err)
+		}
+		return true
+	})
+
+	// 5. Add all rulesets (projects depend on rulesets)
 	common.ForEachRawConfig("ruleset", func(rulesetID, config string) bool {
 		if err := publishInstructionDirectly(rulesetID, "ruleset", config, "add", nil, nil); err != nil {
 			logger.Error("Failed to publish ruleset add instruction", "ruleset", rulesetID, "error", err)
@@ -450,7 +458,7 @@ func (im *InstructionManager) InitializeLeaderInstructions() error {
 		return true
 	})
 
-	// 5. Add all projects LAST (projects depend on all above components)
+	// 6. Add all projects LAST (projects depend on all above components)
 	common.ForEachRawConfig("project", func(projectID, config string) bool {
 		if err := publishInstructionDirectly(projectID, "project", config, "add", nil, nil); err != nil {
 			logger.Error("Failed to publish project add instruction", "project", projectID, "error", err)
@@ -458,7 +466,7 @@ func (im *InstructionManager) InitializeLeaderInstructions() error {
 		return true
 	})
 
-	// 6. Start running projects
+	// 7. Start running projects
 	logger.Info("Reading project user intentions from Redis to send start instructions...")
 
 	if userIntentions, err := common.GetAllProjectUserIntentions(); err == nil {
@@ -484,6 +492,27 @@ func (im *InstructionManager) InitializeLeaderInstructions() error {
 	return nil
 }
 
+// takeOver continues the instruction stream of the previous leader when this node applied all of it, so
+// followers keep their components. Otherwise a new session is started and followers resync in full.
+func (im *InstructionManager) takeOver(syncedVersion string) error {
+	if leaderVersion, err := common.RedisGet("cluster:leader_version"); err == nil && leaderVersion == syncedVersion {
+		if base, n, ok := parseVersion(syncedVersion); ok && n > 0 {
+			im.mu.Lock()
+			im.baseVersion = base
+			im.currentVersion = n
+			im.mu.Unlock()
+			logger.Info("Continuing the instruction stream of the previous leader", "version", syncedVersion)
+			return nil
+		}
+	}
+
+	im.mu.Lock()
+	im.baseVersion = generateSessionID()
+	im.mu.Unlock()
+	logger.Info("Starting a new instruction session", "synced_version", syncedVersion)
+	return im.InitializeLeaderInstructions()
+}
+
 // GetActiveFollowers returns list of followers currently executing instructions
 func (im *InstructionManager) GetActiveFollowers() ([]string, error) {
 	pattern := "cluster:execution_flag:*"
@@ -542,7 +571,10 @@ func (im *InstructionManager) WaitForAllFollowersIdle(timeout time.Duration) err
 func (im *InstructionManager) Stop() {
 	// Only leader should clean up cluster instructions
 	// Followers should not delete instructions as they are managed by leader
-	if common.IsCurrentNodeLeader() {
+	// With failover the next leader continues from them
+	if common.IsCurrentNodeLeader() && failoverEnabled() {
+		logger.Info("Leader keeping cluster instructions for the next leader")
+	} else if common.IsCurrentNodeLeader() {
 		logger.Info("Leader cleaning up cluster instructions during shutdown")
 		is, _ := im.loadAllInstructions(im.maxInstructions)
 		for i := range is {
@@ -3,6 +3,10 @@ package cluster
 import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,31 +16,117 @@ import (
 
 var leaderLockerKey = "cluster:leader:lock"
 
+const (
+	defaultLeaseTTL      = time.Minute
+	leaderLockRetryDelay = time.Second
+)
+
 type LeaderLocker struct {
-	lock *redsync.Mutex
-	done chan struct{}
-	once sync.Once
+	lock   *redsync.Mutex
+	nodeID string
+	since  int64
+	ttl    time.Duration
+	done   chan struct{}
+	lost   chan struct{}
+	once   sync.Once
+}
+
+// leaseTTL returns the expiry of the leader lock, cluster.lease_ttl of config.yaml
+func leaseTTL() time.Duration {
+	if common.Config == nil || common.Config.Cluster.LeaseTTL == "" {
+		return defaultLeaseTTL
+	}
+	sec, err := common.ParseDurationToSecondsInt(common.Config.Cluster.LeaseTTL)
+	if err != nil || sec < 10 {
+		logger.Warn("Invalid cluster lease_ttl, using the default", "lease_ttl", common.Config.Cluster.LeaseTTL, "default", defaultLeaseTTL)
+		return defaultLeaseTTL
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func newLeaderMutex(nodeID string, ttl time.Duration, options ...redsync.Option) *redsync.Mutex {
+	options = append([]redsync.Option{
+		redsync.WithExpiry(ttl),
+		redsync.WithTries(1),
+		redsync.WithGenValueFunc(func() (string, error) { return leaderLockValue(nodeID) }),
+	}, options...)
+	return redsync.New(goredis.NewPool(common.GetRedisClient())).NewMutex(leaderLockerKey, options...)
+}
+
+// leaderLockValue prefixes the random lock value with the node ID, so that a restarted node recognizes its own lock
+func leaderLockValue(nodeID string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return nodeID + "/" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ObtainLeaderLocker obtains the leader lock without waiting for another node to release it.
+// The lock this node left behind when it was killed is removed instead of waiting for it to expire.
+func ObtainLeaderLocker(nodeID string) (*LeaderLocker, error) {
+	locker, err := TryObtainLeaderLocker(nodeID)
+	if err == nil {
+		return locker, nil
+	}
+
+	value, getErr := common.RedisGet(leaderLockerKey)
+	if getErr != nil || !strings.HasPrefix(value, nodeID+"/") {
+		return nil, err
+	}
+	logger.Warn("Removing the leader lock left behind by the previous run of this node", "node_id", nodeID)
+	// Unlock only deletes the key while it still holds this value
+	if _, unlockErr := newLeaderMutex(nodeID, leaseTTL(), redsync.WithValue(value)).Unlock(); unlockErr != nil {
+		return nil, fmt.Errorf("%w, removing the stale lock failed: %v", err, unlockErr)
+	}
+	return TryObtainLeaderLocker(nodeID)
 }
 
-func ObtainLeaderLocker() (*LeaderLocker, error) {
+// WaitLeaderLocker obtains the leader lock like ObtainLeaderLocker, retrying until a lock held by
+// another node has expired. A leader restarted under a different node ID cannot recognize its own
+// lock and waits for it to time out, as before failover existed.
+func WaitLeaderLocker(nodeID string) (*LeaderLocker, error) {
+	deadline := time.Now().Add(leaseTTL() + leaderLockRetryDelay)
+	for {
+		locker, err := ObtainLeaderLocker(nodeID)
+		if err == nil || time.Now().After(deadline) {
+			return locker, err
+		}
+		logger.Info("Leader lock is held by another node, waiting for it to be released or to expire", "error", err)
+		time.Sleep(leaderLockRetryDelay)
+	}
+}
 
-	lock := redsync.New(goredis.NewPool(common.GetRedisClient())).NewMutex(leaderLockerKey, redsync.WithExpiry(time.Minute), redsync.WithRetryDelay(time.Second), redsync.WithTries(60))
-	err := lock.Lock()
-	if err != nil {
+// TryObtainLeaderLocker obtains the leader lock when no other node holds it
+func TryObtainLeaderLocker(nodeID string) (*LeaderLocker, error) {
+	ttl := leaseTTL()
+	lock := newLeaderMutex(nodeID, ttl)
+	if err := lock.TryLock(); err != nil {
 		return nil, err
 	}
+	return startLeaderLocker(lock, nodeID, ttl), nil
+}
+
+func startLeaderLocker(lock *redsync.Mutex, nodeID string, ttl time.Duration) *LeaderLocker {
 	logger.Debug("Obtained leader locker", "lock_value", lock.Value())
 
-	done := make(chan struct{})
-	locker := &LeaderLocker{lock: lock, done: done}
+	locker := &LeaderLocker{
+		lock:   lock,
+		nodeID: nodeID,
+		since:  time.Now().Unix(),
+		ttl:    ttl,
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+	locker.publishLeaderInfo()
 
 	go locker.startRefreshLoop()
 
-	return locker, nil
+	return locker
 }
 
 func (l *LeaderLocker) startRefreshLoop() {
-	ticker := time.NewTicker(time.Second * 10)
+	ticker := time.NewTicker(l.ttl / 6)
 	defer ticker.Stop()
 
 	for {
@@ -44,7 +134,15 @@ func (l *LeaderLocker) startRefreshLoop() {
 		case <-ticker.C:
 			if err := l.refreshLock(); err != nil {
 				logger.Error("Failed to refresh leader locker", "error", err)
+				// Other nodes may take the lock once it has expired
+				if time.Now().After(l.lock.Until()) {
+					logger.Error("Leader locker expired, leadership lost", "lock_value", l.lock.Value())
+					close(l.lost)
+					return
+				}
+				continue
 			}
+			l.publishLeaderInfo()
 			logger.Debug("Leader locker refreshed", "lock_value", l.lock.Value())
 		case <-l.done:
 			return
@@ -53,14 +151,23 @@ func (l *LeaderLocker) startRefreshLoop() {
 }
 
 func (l *LeaderLocker) refreshLock() error {
-	_, err := l.lock.Extend()
+	ok, err := l.lock.Extend()
+	if err == nil && !ok {
+		err = redsync.ErrExtendFailed
+	}
 	return err
 }
 
+// Lost is closed when the lock could not be extended before it expired
+func (l *LeaderLocker) Lost() <-chan struct{} {
+	return l.lost
+}
+
 func (l *LeaderLocker) Release() {
 	l.once.Do(func() {
 		close(l.done)
 
+		l.clearLeaderInfo()
 		if _, err := l.lock.Unlock(); err != nil {
 			logger.Error("Failed to release leader locker", "error", err)
 		}
@@ -0,0 +1,302 @@
+package cluster
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/rules_engine"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Config snapshots are named copies of the complete component set of the cluster, kept in Redis so that
+// any leader can roll the cluster back to them. A rollback is applied like pushed changes: every component
+// that differs is updated, added or deleted and published as an instruction, see api/snapshots.go.
+
+const snapshotKeyPrefix = "cluster:snapshot:"
+
+// CurrentSnapshotName stands for the live component set when diffing
+const CurrentSnapshotName = "current"
+
+// SnapshotComponentTypes in the order they are restored, components before the ones referencing them
+var SnapshotComponentTypes = []string{"plugin", "lookup", "input", "output", "ruleset", "project"}
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ConfigSnapshot is the complete component set of the cluster at one point in time
+type ConfigSnapshot struct {
+	Name            string                       `json:"name"`
+	Comment         string                       `json:"comment,omitempty"`
+	Operator        string                       `json:"operator,omitempty"`
+	CreatedAt       time.Time                    `json:"created_at"`
+	Version         string                       `json:"version"`              // Instruction version it was taken at
+	Components      map[string]map[string]string `json:"components"`           // Type -> ID -> raw config
+	TestCases       map[string]string            `json:"test_cases,omitempty"` // Ruleset ID -> test case file
+	RunningProjects []string                     `json:"running_projects"`
+}
+
+// SnapshotSummary describes a snapshot without its content
+type SnapshotSummary struct {
+	Name            string         `json:"name"`
+	Comment         string         `json:"comment,omitempty"`
+	Operator        string         `json:"operator,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	Version         string         `json:"version"`
+	Components      map[string]int `json:"components"` // Type -> count
+	RunningProjects int            `json:"running_projects"`
+}
+
+// ComponentDiff is a component that differs between two snapshots
+type ComponentDiff struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Change string `json:"change"`         // added, removed or modified, going from the first snapshot to the second
+	Diff   string `json:"diff,omitempty"` // Unified diff of the raw config
+}
+
+// SnapshotDiff lists what changes going from one snapshot to another
+type SnapshotDiff struct {
+	From            string          `json:"from"`
+	To              string          `json:"to"`
+	Components      []ComponentDiff `json:"components"`
+	TestCases       []ComponentDiff `json:"test_cases"`
+	ProjectsStarted []string        `json:"projects_started"`
+	ProjectsStopped []string        `json:"projects_stopped"`
+}
+
+// ValidateSnapshotName checks the name of a new snapshot
+func ValidateSnapshotName(name string) error {
+	if name == CurrentSnapshotName {
+		return fmt.Errorf("snapshot name %q is reserved", name)
+	}
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q, use up to 64 letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// CaptureSnapshot copies the live component set of the leader, the snapshot is not saved
+func CaptureSnapshot(name, comment, operator string) (*ConfigSnapshot, error) {
+	if err := common.RequireLeader(); err != nil {
+		return nil, err
+	}
+
+	s := &ConfigSnapshot{
+		Name:       name,
+		Comment:    comment,
+		Operator:   operator,
+		CreatedAt:  time.Now(),
+		Components: make(map[string]map[string]string, len(SnapshotComponentTypes)),
+		TestCases:  make(map[string]string),
+	}
+	if GlobalInstructionManager != nil {
+		s.Version = GlobalInstructionManager.GetCurrentVersion()
+	}
+	for _, componentType := range SnapshotComponentTypes {
+		configs := make(map[string]string)
+		common.ForEachRawConfig(componentType, func(id, config string) bool {
+			configs[id] = config
+			return true
+		})
+		s.Components[componentType] = configs
+	}
+	for id := range s.Components["ruleset"] {
+		if data, err := os.ReadFile(rules_engine.TestCasePath(id)); err == nil {
+			s.TestCases[id] = string(data)
+		}
+	}
+
+	intentions, err := common.GetAllProjectUserIntentions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project states: %w", err)
+	}
+	s.RunningProjects = []string{}
+	for id, wantRunning := range intentions {
+		if _, exists := s.Components["project"][id]; exists && wantRunning {
+			s.RunningProjects = append(s.RunningProjects, id)
+		}
+	}
+	sort.Strings(s.RunningProjects)
+	return s, nil
+}
+
+// SaveSnapshot stores a snapshot, replacing one of the same name
+func SaveSnapshot(s *ConfigSnapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if _, err := common.RedisSet(snapshotKeyPrefix+s.Name, string(data), 0); err != nil {
+		return fmt.Errorf("failed to store snapshot %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// GetSnapshot loads a snapshot, CurrentSnapshotName captures the live component set
+func GetSnapshot(name string) (*ConfigSnapshot, error) {
+	if name == CurrentSnapshotName {
+		return CaptureSnapshot(name, "", "")
+	}
+	data, err := common.RedisGet(snapshotKeyPrefix + name)
+	if err != nil || data == "" {
+		return nil, fmt.Errorf("snapshot %s not found", name)
+	}
+	var s ConfigSnapshot
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	return &s, nil
+}
+
+// SnapshotExists reports whether a snapshot of the name is stored
+func SnapshotExists(name string) bool {
+	data, err := common.RedisGet(snapshotKeyPrefix + name)
+	return err == nil && data != ""
+}
+
+// DeleteSnapshot removes a stored snapshot
+func DeleteSnapshot(name string) error {
+	if !SnapshotExists(name) {
+		return fmt.Errorf("snapshot %s not found", name)
+	}
+	return common.RedisDel(snapshotKeyPrefix + name)
+}
+
+// ListSnapshots returns the stored snapshots, newest first
+func ListSnapshots() ([]SnapshotSummary, error) {
+	keys, err := common.RedisKeys(snapshotKeyPrefix + "*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	list := make([]SnapshotSummary, 0, len(keys))
+	for _, key := range keys {
+		s, err := GetSnapshot(key[len(snapshotKeyPrefix):])
+		if err != nil {
+			continue
+		}
+		summary := SnapshotSummary{
+			Name:            s.Name,
+			Comment:         s.Comment,
+			Operator:        s.Operator,
+			CreatedAt:       s.CreatedAt,
+			Version:         s.Version,
+			Components:      make(map[string]int, len(s.Components)),
+			RunningProjects: len(s.RunningProjects),
+		}
+		for componentType, configs := range s.Components {
+			summary.Components[componentType] = len(configs)
+		}
+		list = append(list, summary)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// DiffSnapshots lists the changes that turn from into to, components in restore order
+func DiffSnapshots(from, to *ConfigSnapshot) *SnapshotDiff {
+	d := &SnapshotDiff{
+		From:            from.Name,
+		To:              to.Name,
+		Components:      []ComponentDiff{},
+		ProjectsStarted: []string{},
+		ProjectsStopped: []string{},
+	}
+	for _, componentType := range SnapshotComponentTypes {
+		d.Components = append(d.Components, diffConfigs(componentType, from.Name, to.Name, from.Components[componentType], to.Components[componentType])...)
+	}
+	d.TestCases = diffConfigs("ruleset", from.Name, to.Name, from.TestCases, to.TestCases)
+
+	fromRunning := make(map[string]bool, len(from.RunningProjects))
+	for _, id := range from.RunningProjects {
+		fromRunning[id] = true
+	}
+	toRunning := make(map[string]bool, len(to.RunningProjects))
+	for _, id := range to.RunningProjects {
+		toRunning[id] = true
+		if !fromRunning[id] {
+			d.ProjectsStarted = append(d.ProjectsStarted, id)
+		}
+	}
+	for _, id := range from.RunningProjects {
+		// Removed projects stop with their deletion
+		if _, exists := to.Components["project"][id]; exists && !toRunning[id] {
+			d.ProjectsStopped = append(d.ProjectsStopped, id)
+		}
+	}
+	return d
+}
+
+// ApplyOrder returns the component changes in the order a rollback makes them: additions and
+// modifications in restore order, then removals the other way round so nothing removed is still referenced
+func (d *SnapshotDiff) ApplyOrder() []ComponentDiff {
+	ordered := make([]ComponentDiff, 0, len(d.Components))
+	for _, c := range d.Components {
+		if c.Change != "removed" {
+			ordered = append(ordered, c)
+		}
+	}
+	for i := len(d.Components) - 1; i >= 0; i-- {
+		if d.Components[i].Change == "removed" {
+			ordered = append(ordered, d.Components[i])
+		}
+	}
+	return ordered
+}
+
+func diffConfigs(componentType, fromName, toName string, from, to map[string]string) []ComponentDiff {
+	ids := make([]string, 0, len(from)+len(to))
+	for id := range from {
+		ids = append(ids, id)
+	}
+	for id := range to {
+		if _, exists := from[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var diffs []ComponentDiff
+	for _, id := range ids {
+		oldConfig, inFrom := from[id]
+		newConfig, inTo := to[id]
+		change := "modified"
+		switch {
+		case !inFrom:
+			change = "added"
+		case !inTo:
+			change = "removed"
+		case oldConfig == newConfig:
+			continue
+		}
+		text, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines(oldConfig),
+			B:        diffLines(newConfig),
+			FromFile: fromName + "/" + componentType + "/" + id,
+			ToFile:   toName + "/" + componentType + "/" + id,
+			Context:  3,
+		})
+		diffs = append(diffs, ComponentDiff{Type: componentType, ID: id, Change: change, Diff: text})
+	}
+	return diffs
+}
+
+// diffLines splits a config into newline terminated lines, difflib.SplitLines adds an empty last line
+func diffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	from := &ConfigSnapshot{
+		Name: "from",
+		Components: map[string]map[string]string{
+			"input":   {"kafka": "type: kafka\n", "file": "type: file\n"},
+			"ruleset": {"rs": "<root/>"},
+			"project": {"p1": "content: a", "p2": "content: b", "p3": "content: c"},
+		},
+		TestCases:       map[string]string{"rs": "cases: []"},
+		RunningProjects: []string{"p1", "p3"},
+	}
+	to := &ConfigSnapshot{
+		Name: "to",
+		Components: map[string]map[string]string{
+			"plugin":  {"enrich": "package plugin"},
+			"input":   {"kafka": "type: kafka\ntopic: x\n"},
+			"ruleset": {"rs": "<root/>"},
+			"project": {"p1": "content: a", "p2": "content: b"},
+		},
+		RunningProjects: []string{"p2"},
+	}
+
+	d := DiffSnapshots(from, to)
+	var changes []string
+	for _, c := range d.Components {
+		changes = append(changes, c.Change+" "+c.Type+"/"+c.ID)
+	}
+	expected := []string{"added plugin/enrich", "removed input/file", "modified input/kafka", "removed project/p3"}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("components = %v, expected %v", changes, expected)
+	}
+	if len(d.TestCases) != 1 || d.TestCases[0].ID != "rs" || d.TestCases[0].Change != "removed" {
+		t.Errorf("test cases = %+v, expected rs removed", d.TestCases)
+	}
+	// p3 is removed, it stops with its deletion
+	if !reflect.DeepEqual(d.ProjectsStarted, []string{"p2"}) || !reflect.DeepEqual(d.ProjectsStopped, []string{"p1"}) {
+		t.Errorf("projects started %v and stopped %v, expected [p2] and [p1]", d.ProjectsStarted, d.ProjectsStopped)
+	}
+
+	diff := d.Components[2].Diff
+	if !strings.Contains(diff, "--- from/input/kafka") || !strings.Contains(diff, "+++ to/input/kafka") || !strings.Contains(diff, "+topic: x\n") {
+		t.Errorf("diff of input/kafka = %q", diff)
+	}
+
+	if d := DiffSnapshots(from, from); len(d.Components) != 0 || len(d.TestCases) != 0 || len(d.ProjectsStarted) != 0 || len(d.ProjectsStopped) != 0 {
+		t.Errorf("diff of a snapshot with itself = %+v, expected no changes", d)
+	}
+}
+
+func TestSnapshotDiffApplyOrder(t *testing.T) {
+	from := &ConfigSnapshot{Components: map[string]map[string]string{
+		"plugin":  {"old_plugin": "a"},
+		"input":   {"in": "a", "old_in": "a"},
+		"ruleset": {"rs": "a"},
+		"project": {"old_project": "a"},
+	}}
+	to := &ConfigSnapshot{Components: map[string]map[string]string{
+		"plugin":  {"new_plugin": "b"},
+		"input":   {"in": "b"},
+		"ruleset": {"rs": "b"},
+		"project": {"new_project": "b"},
+	}}
+
+	var order []string
+	for _, c := range DiffSnapshots(from, to).ApplyOrder() {
+		order = append(order, c.Change+" "+c.Type+"/"+c.ID)
+	}
+	// Components are restored before the projects using them, and removed after the projects
+	// that could still reference them
+	expected := []string{
+		"added plugin/new_plugin",
+		"modified input/in",
+		"modified ruleset/rs",
+		"added project/new_project",
+		"removed project/old_project",
+		"removed input/old_in",
+		"removed plugin/old_plugin",
+	}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("apply order = %v, expected %v", order, expected)
+	}
+}
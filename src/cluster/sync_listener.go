@@ -4,6 +4,7 @@ import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/input"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/lookup"
 	"AgentSmith-HUB/output"
 	"AgentSmith-HUB/plugin"
 	"AgentSmith-HUB/project"
@@ -25,6 +26,7 @@ type SyncListener struct {
 	baseVersion      string
 	executionFlagTTL time.Duration // TTL for execution flag, default 5 minutes
 	mu               sync.RWMutex
+	stopOnce         sync.Once
 }
 
 var GlobalSyncListener *SyncListener
@@ -44,6 +46,13 @@ func (sl *SyncListener) GetCurrentVersion() string {
 	return fmt.Sprintf("%s.%d", sl.baseVersion, sl.currentVersion)
 }
 
+// Synced reports whether the listener has applied the instructions of a leader
+func (sl *SyncListener) Synced() bool {
+	sl.mu.RLock()
+	defer sl.mu.RUnlock()
+	return sl.baseVersion != "0"
+}
+
 // Start starts the sync listener (follower only)
 func (sl *SyncListener) Start() {
 	if common.IsCurrentNodeLeader() {
@@ -272,17 +281,17 @@ func (sl *SyncListener) applyInstruction(version int64) error {
 	switch instruction.Operation {
 	case "add":
 		if err := sl.createComponentInstance(instruction.ComponentType, instruction.ComponentName, instruction.Content); err != nil {
-			common.RecordComponentAdd(instruction.ComponentType, instruction.ComponentName, instruction.Content, "failed", err.Error())
+			common.RecordComponentAdd(instruction.ComponentType, instruction.ComponentName, instruction.Content, "failed", err.Error(), "")
 			return err
 		}
-		common.RecordComponentAdd(instruction.ComponentType, instruction.ComponentName, instruction.Content, "success", "")
+		common.RecordComponentAdd(instruction.ComponentType, instruction.ComponentName, instruction.Content, "success", "", "")
 	case "delete":
 		if err := sl.deleteComponentInstance(instruction.ComponentType, instruction.ComponentName); err != nil {
 			return err
 		}
 	case "update":
 		if err := sl.updateComponentInstance(instruction.ComponentType, instruction.ComponentName, instruction.Content); err != nil {
-			common.RecordComponentUpdate(instruction.ComponentType, instruction.ComponentName, instruction.Content, "failed", err.Error())
+			common.RecordComponentUpdate(instruction.ComponentType, instruction.ComponentName, instruction.Content, "failed", err.Error(), "")
 			return err
 		}
 	case "local_push":
@@ -398,10 +407,19 @@ func (sl *SyncListener) createComponentInstance(componentType, componentName, co
 		}
 		logger.Debug("Created plugin instance", "name", componentName)
 
+	case "lookup":
+		// Lookups replace the previous version in place, rulesets pick it up on the next event
+		if _, err := lookup.NewLookup("", content, componentName); err != nil {
+			return fmt.Errorf("failed to create lookup instance %s: %w", componentName, err)
+		}
+		logger.Debug("Created lookup instance", "name", componentName)
+
 	default:
 		return fmt.Errorf("unsupported component type: %s", componentType)
 	}
 
+	// Kept for when this node takes over as leader
+	common.SetRawConfig(componentType, componentName, content)
 	return nil
 }
 
@@ -435,18 +453,28 @@ func (sl *SyncListener) deleteComponentInstance(componentType, componentName str
 		// This might need specific plugin cleanup logic
 		logger.Debug("Deleted plugin instance", "name", componentName)
 
+	case "lookup":
+		if _, err := lookup.SafeDeleteLookup(componentName); err != nil {
+			logger.Debug("Lookup to delete not found", "name", componentName)
+		}
+		logger.Debug("Deleted lookup instance", "name", componentName)
+
 	default:
 		return fmt.Errorf("unsupported component type: %s", componentType)
 	}
 
+	common.DeleteRawConfig(componentType, componentName)
 	return nil
 }
 
 // updateComponentInstance updates existing component instances with new configuration
 func (sl *SyncListener) updateComponentInstance(componentType, componentName, content string) error {
 	// For updates, we delete the old instance and create a new one
-	if err := sl.deleteComponentInstance(componentType, componentName); err != nil {
-		logger.Warn("Failed to delete old component instance during update", "type", componentType, "name", componentName, "error", err)
+	// Lookups are replaced in place, deleting first would let events miss the table meanwhile
+	if componentType != "lookup" {
+		if err := sl.deleteComponentInstance(componentType, componentName); err != nil {
+			logger.Warn("Failed to delete old component instance during update", "type", componentType, "name", componentName, "error", err)
+		}
 	}
 
 	return sl.createComponentInstance(componentType, componentName, content)
@@ -454,6 +482,18 @@ func (sl *SyncListener) updateComponentInstance(componentType, componentName, co
 
 // Stop stops the sync listener
 func (sl *SyncListener) Stop() {
-	close(sl.stopChan)
-	_ = sl.ClearFollowerExecutionFlag(sl.nodeID)
+	sl.stopOnce.Do(func() {
+		close(sl.stopChan)
+		_ = sl.ClearFollowerExecutionFlag(sl.nodeID)
+	})
+}
+
+// stopFollowing stops the listener when the node takes over as leader and returns the version it synced
+func (sl *SyncListener) stopFollowing() string {
+	sl.Stop()
+
+	// A running sync holds the lock, let it finish
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return sl.GetCurrentVersion()
 }
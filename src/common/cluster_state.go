@@ -36,6 +36,20 @@ func IsCurrentNodeLeader() bool {
 	return globalClusterState.isLeader
 }
 
+// SetLeaderID records the node ID of the current leader
+func SetLeaderID(leaderID string) {
+	globalClusterState.mu.Lock()
+	defer globalClusterState.mu.Unlock()
+	globalClusterState.leaderID = leaderID
+}
+
+// GetLeaderID returns the node ID of the current leader, empty while it is not known
+func GetLeaderID() string {
+	globalClusterState.mu.RLock()
+	defer globalClusterState.mu.RUnlock()
+	return globalClusterState.leaderID
+}
+
 // GetNodeID returns the current node ID
 func GetNodeID() string {
 	globalClusterState.mu.RLock()
@@ -40,6 +40,9 @@ func (co *ComponentOperations) CreateComponentDirect(componentType, id, content
 	case "plugin":
 		suffix = ".go"
 		dir = "plugin"
+	case "lookup":
+		suffix = ".yaml"
+		dir = "lookup"
 	default:
 		return fmt.Errorf("invalid component type")
 	}
@@ -107,6 +110,9 @@ func (co *ComponentOperations) UpdateComponentDirect(componentType, id, content
 	case "plugin":
 		suffix = ".go"
 		dir = "plugin"
+	case "lookup":
+		suffix = ".yaml"
+		dir = "lookup"
 	default:
 		return fmt.Errorf("invalid component type")
 	}
@@ -160,6 +166,9 @@ func (co *ComponentOperations) DeleteComponentDirect(componentType, id string) e
 	case "plugin":
 		suffix = ".go"
 		dir = "plugin"
+	case "lookup":
+		suffix = ".yaml"
+		dir = "lookup"
 	default:
 		return fmt.Errorf("invalid component type")
 	}
@@ -0,0 +1,225 @@
+package common
+
+import (
+	"AgentSmith-HUB/logger"
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// DeadLetterFunc receives messages a producer failed to deliver together with the cause
+type DeadLetterFunc func(msgs []map[string]interface{}, err error)
+
+// DeadLetterRecord is one undeliverable message together with why it failed
+type DeadLetterRecord struct {
+	Time     string                 `json:"time"`
+	OutputID string                 `json:"output_id"`
+	Error    string                 `json:"error"`
+	Message  map[string]interface{} `json:"message"`
+}
+
+// DeadLetterQueue spools undeliverable messages to a local JSON-lines file.
+// All instances of an output (one per project node sequence) share the same queue.
+type DeadLetterQueue struct {
+	OutputID string
+	Path     string
+	mu       sync.Mutex
+}
+
+var (
+	deadLetterQueues   = make(map[string]*DeadLetterQueue)
+	deadLetterQueuesMu sync.Mutex
+)
+
+// GetDeadLetterQueue returns the queue of an output, creating it and the spool directory if needed.
+// A queue whose path changed (output config updated) is replaced.
+func GetDeadLetterQueue(outputID, path string) (*DeadLetterQueue, error) {
+	if path == "" {
+		return nil, fmt.Errorf("dead letter path is required")
+	}
+
+	deadLetterQueuesMu.Lock()
+	defer deadLetterQueuesMu.Unlock()
+
+	if q, ok := deadLetterQueues[outputID]; ok && q.Path == path {
+		return q, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	q := &DeadLetterQueue{
+		OutputID: outputID,
+		Path:     path,
+	}
+	deadLetterQueues[outputID] = q
+	return q, nil
+}
+
+// Write appends failed messages to the spool
+func (q *DeadLetterQueue) Write(msgs []map[string]interface{}, cause error) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	errMsg := ""
+	if cause != nil {
+		errMsg = cause.Error()
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	records := make([]DeadLetterRecord, 0, len(msgs))
+	for _, m := range msgs {
+		records = append(records, DeadLetterRecord{
+			Time:     now,
+			OutputID: q.OutputID,
+			Error:    errMsg,
+			Message:  m,
+		})
+	}
+	if q.Requeue(records) {
+		logger.Warn("Messages written to dead letter queue", "output", q.OutputID, "count", len(msgs), "error", errMsg)
+	}
+}
+
+// Requeue appends records as they are, used to put back records that could not be replayed
+func (q *DeadLetterQueue) Requeue(records []DeadLetterRecord) bool {
+	if len(records) == 0 {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Error("Failed to open dead letter file, messages lost", "output", q.OutputID, "path", q.Path, "count", len(records), "error", err)
+		return false
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		line, err := sonic.Marshal(r)
+		if err != nil {
+			logger.Error("Failed to marshal dead letter record", "output", q.OutputID, "error", err)
+			continue
+		}
+		_, _ = w.Write(line)
+		_ = w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		logger.Error("Failed to write dead letter records", "output", q.OutputID, "path", q.Path, "error", err)
+		return false
+	}
+	return true
+}
+
+// List returns up to limit records starting at offset, and the total number of records
+func (q *DeadLetterQueue) List(offset, limit int) ([]DeadLetterRecord, int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	all, err := q.readAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(all)
+	if offset >= total {
+		return []DeadLetterRecord{}, total, nil
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return all[offset:end], total, nil
+}
+
+// Take removes and returns up to limit records from the head of the spool, 0 means all
+func (q *DeadLetterQueue) Take(limit int) ([]DeadLetterRecord, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	all, err := q.readAll()
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+	if limit <= 0 || limit > len(all) {
+		limit = len(all)
+	}
+	if err := q.rewrite(all[limit:]); err != nil {
+		return nil, err
+	}
+	return all[:limit], nil
+}
+
+// Purge removes all spooled records and returns how many were removed
+func (q *DeadLetterQueue) Purge() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	all, err := q.readAll()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(q.Path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	return len(all), nil
+}
+
+// readAll reads the spool, a missing file means an empty queue. Caller holds q.mu.
+func (q *DeadLetterQueue) readAll() ([]DeadLetterRecord, error) {
+	data, err := os.ReadFile(q.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []DeadLetterRecord
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var r DeadLetterRecord
+		if err := sonic.Unmarshal(line, &r); err != nil {
+			logger.Warn("Skipping corrupted dead letter record", "output", q.OutputID, "path", q.Path, "error", err)
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// rewrite atomically replaces the spool with records. Caller holds q.mu.
+func (q *DeadLetterQueue) rewrite(records []DeadLetterRecord) error {
+	if len(records) == 0 {
+		if err := os.Remove(q.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, r := range records {
+		line, err := sonic.Marshal(r)
+		if err != nil {
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := q.Path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.Path)
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	path := t.TempDir() + "/dl/out.jsonl"
+	q, err := GetDeadLetterQueue("dl_test", path)
+	if err != nil {
+		t.Fatalf("GetDeadLetterQueue failed: %v", err)
+	}
+	if same, _ := GetDeadLetterQueue("dl_test", path); same != q {
+		t.Error("second GetDeadLetterQueue returned another queue")
+	}
+
+	q.Write([]map[string]interface{}{{"n": 1.0}, {"n": 2.0}, {"n": 3.0}}, errors.New("connection refused"))
+	records, total, err := q.List(1, 1)
+	if err != nil || total != 3 || len(records) != 1 || records[0].Message["n"] != 2.0 {
+		t.Fatalf("List(1, 1) = %v, %d, %v", records, total, err)
+	}
+	if records[0].Error != "connection refused" || records[0].OutputID != "dl_test" {
+		t.Errorf("record = %+v", records[0])
+	}
+	if records, total, _ := q.List(5, 0); len(records) != 0 || total != 3 {
+		t.Errorf("List(5, 0) = %v, %d, expected no records of 3", records, total)
+	}
+
+	taken, err := q.Take(2)
+	if err != nil || len(taken) != 2 || taken[0].Message["n"] != 1.0 {
+		t.Fatalf("Take(2) = %v, %v", taken, err)
+	}
+	q.Requeue(taken[1:])
+	if records, total, _ := q.List(0, 0); total != 2 || records[0].Message["n"] != 3.0 || records[1].Message["n"] != 2.0 {
+		t.Errorf("records after Requeue = %v", records)
+	}
+
+	if n, err := q.Purge(); n != 2 || err != nil {
+		t.Errorf("Purge() = %d, %v, expected 2", n, err)
+	}
+	if _, total, _ := q.List(0, 0); total != 0 {
+		t.Errorf("%d records after Purge", total)
+	}
+}
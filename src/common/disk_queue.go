@@ -0,0 +1,498 @@
+package common
+
+import (
+	"AgentSmith-HUB/logger"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// DiskQueue connects two project components through a local write-ahead log. Senders write to In,
+// every message is appended to the log before it is fed to Out, where the receiving component reads
+// it. While the receiver keeps up the message itself is fed to Out, only a backlog is read back from
+// the log (and then carries the JSON types, numbers become float64). The log offset of the first
+// message the receiver has not taken from Out is persisted every second and on Close, and the log is
+// replayed from there when the queue is opened again. A crash therefore replays the messages taken
+// since the last save (at-least-once) but loses none that were accepted from In. The log is synced to
+// disk every second, so a power loss can lose up to a second.
+//
+// The log is split into segment files named <Path>.<offset of their first record>, a segment is
+// deleted once the receiver has taken every record in it.
+type DiskQueue struct {
+	Name    string
+	Path    string
+	MaxSize int64 // max size in bytes of the segments kept, senders are blocked when reached; 0 means unlimited
+	In      chan map[string]interface{}
+	Out     chan map[string]interface{}
+
+	segments    []int64 // offsets of the first record of each segment file, oldest first
+	segmentSize int64   // a new segment is started once the last one reaches this size
+	writer      *os.File
+	reader      *os.File
+	rd          *bufio.Reader
+	readBase    int64                  // segment the reader is on
+	rdOffset    int64                  // log offset the reader is at, -1 when it has to seek
+	readOffset  int64                  // end of the last record fed to Out
+	writeOffset int64                  // end of the log
+	ackOffset   int64                  // end of the last record the receiver took from Out
+	savedOffset int64                  // ackOffset as persisted
+	outOffsets  []int64                // end offsets of the records fed to Out and not acknowledged yet
+	next        map[string]interface{} // record at readOffset waiting for room in Out
+	nextSize    int64
+	pending     int64 // records in the log not fed to Out yet
+	stopChan    chan struct{}
+	doneChan    chan struct{}
+}
+
+type diskQueueOffset struct {
+	Offset int64 `json:"offset"`
+}
+
+const (
+	// diskQueueBatchSize limits the messages taken from In for a single log write
+	diskQueueBatchSize = 256
+	// diskQueueSegmentSize is the size at which a new segment is started, smaller for a small MaxSize
+	diskQueueSegmentSize = 64 * 1024 * 1024
+)
+
+// NewDiskQueue opens (or creates) the log at path and starts moving messages from In to Out
+func NewDiskQueue(name, path string, maxSize int64, capacity int) (*DiskQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &DiskQueue{
+		Name:    name,
+		Path:    path,
+		MaxSize: maxSize,
+		// Unbuffered, a message is in the log right after the send returns
+		In:          make(chan map[string]interface{}),
+		Out:         make(chan map[string]interface{}, capacity),
+		segmentSize: diskQueueSegmentSize,
+		rdOffset:    -1,
+		stopChan:    make(chan struct{}),
+		doneChan:    make(chan struct{}),
+	}
+	if maxSize > 0 && maxSize/8 < q.segmentSize {
+		q.segmentSize = max(maxSize/8, 1)
+	}
+
+	segments, err := q.listSegments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queue segments: %w", err)
+	}
+	if len(segments) == 0 {
+		segments = []int64{0}
+	}
+	q.segments = segments
+	last := segments[len(segments)-1]
+	writer, err := os.OpenFile(q.segmentPath(last), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue log: %w", err)
+	}
+	fi, err := writer.Stat()
+	if err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+	q.writer = writer
+	q.writeOffset = last + fi.Size()
+
+	q.readOffset = q.loadOffset()
+	if q.readOffset < q.segments[0] || q.readOffset > q.writeOffset {
+		q.readOffset = q.segments[0]
+	}
+	q.ackOffset = q.readOffset
+	q.savedOffset = q.readOffset
+	if !q.seekReader(q.readOffset) {
+		q.closeFiles()
+		return nil, fmt.Errorf("failed to open queue log at offset %d", q.readOffset)
+	}
+	q.pending = q.countPending()
+	if q.pending > 0 {
+		logger.Info("[DiskQueue] replaying buffered messages", "queue", name, "pending", q.pending)
+	}
+
+	go q.run()
+	return q, nil
+}
+
+func (q *DiskQueue) run() {
+	defer close(q.doneChan)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	in := q.In
+	for {
+		if q.next == nil && q.readOffset < q.writeOffset {
+			q.readNext()
+		}
+
+		var out chan map[string]interface{}
+		if q.next != nil {
+			out = q.Out
+		}
+		accept := in
+		if q.MaxSize > 0 && q.writeOffset-q.segments[0] >= q.MaxSize {
+			accept = nil // full, block senders until the receiver has taken enough to delete a segment
+		}
+
+		select {
+		case out <- q.next:
+			q.readOffset += q.nextSize
+			q.outOffsets = append(q.outOffsets, q.readOffset)
+			q.next = nil
+			atomic.AddInt64(&q.pending, -1)
+			q.deleteAcknowledgedSegments()
+		case msg, ok := <-accept:
+			if !ok {
+				in = nil
+				continue
+			}
+			q.appendBatch(msg, true)
+			q.deleteAcknowledgedSegments()
+		case <-ticker.C:
+			if err := q.writer.Sync(); err != nil {
+				logger.Warn("[DiskQueue] failed to sync log", "queue", q.Name, "error", err)
+			}
+			q.saveOffset()
+			q.deleteAcknowledgedSegments()
+		case <-q.stopChan:
+			return
+		}
+	}
+}
+
+// readNext loads the record at readOffset, corrupted records are skipped
+func (q *DiskQueue) readNext() {
+	for q.readOffset < q.writeOffset {
+		if q.rdOffset != q.readOffset || q.segmentOf(q.readOffset) != q.readBase {
+			if !q.seekReader(q.readOffset) {
+				return
+			}
+		}
+		line, err := q.rd.ReadBytes('\n')
+		if err != nil {
+			// Only complete lines are written, a partial read means the write is not visible yet
+			q.rdOffset = -1
+			return
+		}
+		q.rdOffset += int64(len(line))
+
+		var msg map[string]interface{}
+		if err := sonic.Unmarshal(bytes.TrimSpace(line), &msg); err != nil {
+			logger.Error("[DiskQueue] skipping corrupted record", "queue", q.Name, "offset", q.readOffset, "error", err)
+			q.readOffset += int64(len(line))
+			// Acknowledged together with the record before it
+			if n := len(q.outOffsets); n > 0 {
+				q.outOffsets[n-1] = q.readOffset
+			} else {
+				q.ackOffset = q.readOffset
+			}
+			atomic.AddInt64(&q.pending, -1)
+			continue
+		}
+		q.next = msg
+		q.nextSize = int64(len(line))
+		return
+	}
+}
+
+// appendBatch writes a message and those waiting in In to the end of the log with a single write.
+// With deliver, messages are also fed to Out right away while no older record is waiting for room in it.
+func (q *DiskQueue) appendBatch(first map[string]interface{}, deliver bool) {
+	var buf bytes.Buffer
+	msgs := make([]map[string]interface{}, 0, 1)
+	sizes := make([]int64, 0, 1)
+	add := func(msg map[string]interface{}) {
+		data, err := sonic.Marshal(msg)
+		if err != nil {
+			logger.Error("[DiskQueue] failed to serialize message, dropping", "queue", q.Name, "error", err)
+			return
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+		msgs = append(msgs, msg)
+		sizes = append(sizes, int64(len(data)+1))
+	}
+
+	add(first)
+drain:
+	for len(msgs) < diskQueueBatchSize {
+		select {
+		case msg, ok := <-q.In:
+			if !ok {
+				break drain
+			}
+			add(msg)
+		default:
+			break drain
+		}
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	q.rollSegment()
+	backlog := q.next != nil || q.readOffset < q.writeOffset
+	if _, err := q.writer.Write(buf.Bytes()); err != nil {
+		logger.Error("[DiskQueue] failed to write messages to log, dropping", "queue", q.Name, "count", len(msgs), "error", err)
+		// A partial write leaves a truncated record, start the next one on a new line
+		base := q.segments[len(q.segments)-1]
+		if fi, statErr := q.writer.Stat(); statErr == nil && base+fi.Size() > q.writeOffset {
+			if _, err := q.writer.Write([]byte{'\n'}); err == nil {
+				q.writeOffset = base + fi.Size() + 1
+			}
+		}
+		return
+	}
+	q.writeOffset += int64(buf.Len())
+
+	delivered := 0
+	if deliver && !backlog {
+	feed:
+		for i, msg := range msgs {
+			select {
+			case q.Out <- msg:
+				q.readOffset += sizes[i]
+				q.outOffsets = append(q.outOffsets, q.readOffset)
+				delivered++
+			default:
+				break feed
+			}
+		}
+	}
+	atomic.AddInt64(&q.pending, int64(len(msgs)-delivered))
+}
+
+// rollSegment starts a new segment once the last one is full
+func (q *DiskQueue) rollSegment() {
+	base := q.segments[len(q.segments)-1]
+	if q.writeOffset-base < q.segmentSize {
+		return
+	}
+	writer, err := os.OpenFile(q.segmentPath(q.writeOffset), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Warn("[DiskQueue] failed to start a new segment, appending to the current one", "queue", q.Name, "error", err)
+		return
+	}
+	_ = q.writer.Close()
+	q.writer = writer
+	q.segments = append(q.segments, q.writeOffset)
+}
+
+// acknowledge moves ackOffset past the records the receiver has taken from Out
+func (q *DiskQueue) acknowledge() {
+	taken := len(q.outOffsets) - len(q.Out)
+	if taken <= 0 {
+		return
+	}
+	q.ackOffset = q.outOffsets[taken-1]
+	q.outOffsets = q.outOffsets[taken:]
+}
+
+// deleteAcknowledgedSegments removes the segments the receiver has taken every record of, the last
+// segment is kept for writing
+func (q *DiskQueue) deleteAcknowledgedSegments() {
+	q.acknowledge()
+	deleted := 0
+	for deleted+1 < len(q.segments) && q.segments[deleted+1] <= q.ackOffset {
+		if err := os.Remove(q.segmentPath(q.segments[deleted])); err != nil && !os.IsNotExist(err) {
+			logger.Warn("[DiskQueue] failed to delete segment", "queue", q.Name, "error", err)
+			break
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return
+	}
+	q.segments = q.segments[deleted:]
+	// Segments only go once acknowledged, keep the persisted offset within the remaining ones
+	q.saveOffset()
+}
+
+// segmentOf returns the segment holding offset
+func (q *DiskQueue) segmentOf(offset int64) int64 {
+	i := sort.Search(len(q.segments), func(i int) bool { return q.segments[i] > offset })
+	if i == 0 {
+		return q.segments[0]
+	}
+	return q.segments[i-1]
+}
+
+// seekReader positions the reader at offset, opening its segment
+func (q *DiskQueue) seekReader(offset int64) bool {
+	q.rdOffset = -1
+	base := q.segmentOf(offset)
+	if q.reader == nil || base != q.readBase {
+		reader, err := os.Open(q.segmentPath(base))
+		if err != nil {
+			logger.Warn("[DiskQueue] failed to open segment", "queue", q.Name, "error", err)
+			return false
+		}
+		if q.reader != nil {
+			_ = q.reader.Close()
+		}
+		q.reader = reader
+		q.readBase = base
+	}
+	if _, err := q.reader.Seek(offset-base, io.SeekStart); err != nil {
+		logger.Warn("[DiskQueue] failed to seek log", "queue", q.Name, "error", err)
+		return false
+	}
+	if q.rd == nil {
+		q.rd = bufio.NewReaderSize(q.reader, 64*1024)
+	} else {
+		q.rd.Reset(q.reader)
+	}
+	q.rdOffset = offset
+	return true
+}
+
+func (q *DiskQueue) segmentPath(base int64) string {
+	return fmt.Sprintf("%s.%020d", q.Path, base)
+}
+
+// listSegments returns the offsets of the segment files of the queue, oldest first
+func (q *DiskQueue) listSegments() ([]int64, error) {
+	matches, err := filepath.Glob(q.Path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var segments []int64
+	for _, m := range matches {
+		base, err := strconv.ParseInt(strings.TrimPrefix(m, q.Path+"."), 10, 64)
+		if err != nil || base < 0 {
+			continue
+		}
+		segments = append(segments, base)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (q *DiskQueue) offsetPath() string {
+	return q.Path + ".offset"
+}
+
+func (q *DiskQueue) loadOffset() int64 {
+	data, err := os.ReadFile(q.offsetPath())
+	if err != nil {
+		return 0
+	}
+	var o diskQueueOffset
+	if err := sonic.Unmarshal(data, &o); err != nil {
+		logger.Warn("[DiskQueue] offset file is corrupted, replaying from start", "queue", q.Name, "error", err)
+		return 0
+	}
+	return o.Offset
+}
+
+// saveOffset persists the acknowledged position atomically via a temporary file
+func (q *DiskQueue) saveOffset() {
+	q.acknowledge()
+	if q.ackOffset == q.savedOffset {
+		return
+	}
+	data, _ := sonic.Marshal(diskQueueOffset{Offset: q.ackOffset})
+	tmp := q.offsetPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.Error("[DiskQueue] failed to write offset", "queue", q.Name, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, q.offsetPath()); err != nil {
+		logger.Error("[DiskQueue] failed to save offset", "queue", q.Name, "error", err)
+		return
+	}
+	q.savedOffset = q.ackOffset
+}
+
+// countPending counts the records between readOffset and the end of the log
+func (q *DiskQueue) countPending() int64 {
+	var n int64
+	for i, base := range q.segments {
+		if i+1 < len(q.segments) && q.segments[i+1] <= q.readOffset {
+			continue
+		}
+		f, err := os.Open(q.segmentPath(base))
+		if err != nil {
+			continue
+		}
+		if q.readOffset > base {
+			if _, err := f.Seek(q.readOffset-base, io.SeekStart); err != nil {
+				_ = f.Close()
+				continue
+			}
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for sc.Scan() {
+			if len(bytes.TrimSpace(sc.Bytes())) > 0 {
+				n++
+			}
+		}
+		_ = f.Close()
+	}
+	return n
+}
+
+// Pending returns the number of messages in the log not fed to the receiver yet
+func (q *DiskQueue) Pending() int64 {
+	return atomic.LoadInt64(&q.pending)
+}
+
+// Close stops the queue and persists the position of the first message the receiver has not taken,
+// messages still in Out are in the log and delivered again on the next start. Messages waiting in
+// In are written to the log first. It must be called after the receiving component has stopped;
+// In is not closed.
+func (q *DiskQueue) Close() {
+	close(q.stopChan)
+	<-q.doneChan
+
+drain:
+	for {
+		select {
+		case msg, ok := <-q.In:
+			if !ok {
+				break drain
+			}
+			q.appendBatch(msg, false)
+		default:
+			break drain
+		}
+	}
+	if err := q.writer.Sync(); err != nil {
+		logger.Warn("[DiskQueue] failed to sync log", "queue", q.Name, "error", err)
+	}
+	q.saveOffset()
+	q.closeFiles()
+
+	if q.ackOffset >= q.writeOffset {
+		for _, base := range q.segments {
+			_ = os.Remove(q.segmentPath(base))
+		}
+		_ = os.Remove(q.offsetPath())
+		return
+	}
+	logger.Info("[DiskQueue] buffered messages persisted for next start", "queue", q.Name, "bytes", q.writeOffset-q.ackOffset)
+}
+
+func (q *DiskQueue) closeFiles() {
+	if q.writer != nil {
+		_ = q.writer.Close()
+	}
+	if q.reader != nil {
+		_ = q.reader.Close()
+	}
+}
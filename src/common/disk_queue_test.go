@@ -0,0 +1,309 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sendMessages(t *testing.T, q *DiskQueue, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		select {
+		case q.In <- map[string]interface{}{"n": i}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("send of message %d blocked", i)
+		}
+	}
+}
+
+func receiveMessage(t *testing.T, q *DiskQueue) int {
+	t.Helper()
+	select {
+	case msg := <-q.Out:
+		// Delivered as sent, or read back from the log
+		switch n := msg["n"].(type) {
+		case int:
+			return n
+		case float64:
+			return int(n)
+		}
+		t.Fatalf("unexpected message %v", msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return -1
+}
+
+// logSize returns the total size of the segment files of the queue at path
+func logSize(t *testing.T, path string) int64 {
+	t.Helper()
+	matches, _ := filepath.Glob(path + ".0*")
+	var size int64
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err != nil {
+			t.Fatalf("failed to stat segment: %v", err)
+		}
+		size += fi.Size()
+	}
+	return size
+}
+
+// readLog returns the records of all segment files of the queue at path
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	matches, _ := filepath.Glob(path + ".0*")
+	var log strings.Builder
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatalf("failed to read segment: %v", err)
+		}
+		log.Write(data)
+	}
+	return log.String()
+}
+
+// crash stops the queue without persisting anything, like a killed process
+func crash(q *DiskQueue) {
+	close(q.stopChan)
+	<-q.doneChan
+	q.closeFiles()
+}
+
+func TestDiskQueueDeliversInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 0, 8)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+
+	go func() {
+		for i := 0; i < 1000; i++ {
+			q.In <- map[string]interface{}{"n": i}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+
+	q.Close()
+	if matches, _ := filepath.Glob(path + "*"); len(matches) != 0 {
+		t.Errorf("files of a drained queue should be removed, found %v", matches)
+	}
+}
+
+func TestDiskQueueWritesBeforeDelivery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 0, 8)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+	defer q.Close()
+
+	sendMessages(t, q, 0, 3)
+
+	// Nothing is consumed yet, every sent message must already be in the log
+	deadline := time.Now().Add(time.Second)
+	for {
+		if lines := strings.Count(readLog(t, path), "\n"); lines == 3 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("log holds %d messages, expected 3", lines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+	if q.Pending() != 0 {
+		t.Errorf("pending = %d, expected 0", q.Pending())
+	}
+}
+
+func TestDiskQueueCrashReplaysUnconsumed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 0, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+
+	sendMessages(t, q, 0, 100)
+	for i := 0; i < 10; i++ {
+		receiveMessage(t, q)
+	}
+	// Let the acknowledged offset be saved, then take a few more that are not
+	time.Sleep(1500 * time.Millisecond)
+	for i := 10; i < 13; i++ {
+		receiveMessage(t, q)
+	}
+	crash(q)
+
+	q, err = NewDiskQueue("edge", path, 0, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue after crash failed: %v", err)
+	}
+	defer q.Close()
+
+	// Messages taken after the last save are delivered again, nothing is lost
+	for i := 10; i < 100; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("after crash received %d, expected %d", n, i)
+		}
+	}
+	select {
+	case msg := <-q.Out:
+		t.Errorf("unexpected message after replay: %v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDiskQueueCrashWithoutSavedOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 0, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+
+	sendMessages(t, q, 0, 20)
+	receiveMessage(t, q)
+	crash(q)
+
+	q, err = NewDiskQueue("edge", path, 0, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue after crash failed: %v", err)
+	}
+	defer q.Close()
+
+	// At-least-once: replay starts at or before the first message not taken
+	first := receiveMessage(t, q)
+	if first > 1 {
+		t.Fatalf("replay starts at %d, messages were lost", first)
+	}
+	for i := first + 1; i < 20; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+}
+
+func TestDiskQueueCloseReplaysExactly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 0, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+
+	sendMessages(t, q, 0, 50)
+	for i := 0; i < 10; i++ {
+		receiveMessage(t, q)
+	}
+	q.Close()
+
+	q, err = NewDiskQueue("edge", path, 0, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue after close failed: %v", err)
+	}
+	defer q.Close()
+	if q.Pending() != 40 {
+		t.Errorf("pending after reopen = %d, expected 40", q.Pending())
+	}
+	for i := 10; i < 50; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+}
+
+func TestDiskQueueBlocksWhenFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 64, 1)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+	defer q.Close()
+
+	sent := make(chan int, 100)
+	go func() {
+		for i := 0; i < 20; i++ {
+			q.In <- map[string]interface{}{"n": i}
+			sent <- i
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if len(sent) >= 20 {
+		t.Fatal("senders should block once the log is full")
+	}
+	for i := 0; i < 20; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+}
+
+func TestDiskQueueKeepsMessagesWithoutBacklog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	q, err := NewDiskQueue("edge", path, 0, 8)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+	defer q.Close()
+
+	sent := map[string]interface{}{"n": 1, "port": int64(443), "tags": []string{"a"}}
+	q.In <- sent
+	select {
+	case msg := <-q.Out:
+		if msg["port"] != int64(443) || msg["n"] != 1 {
+			t.Errorf("received %v, expected the message as sent %v", msg, sent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	if lines := strings.Count(readLog(t, path), "\n"); lines != 1 {
+		t.Errorf("log holds %d messages, expected 1", lines)
+	}
+}
+
+func TestDiskQueueDeletesAcknowledgedSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge.wal")
+	const maxSize = 4096
+	q, err := NewDiskQueue("edge", path, maxSize, 4)
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+	defer q.Close()
+
+	// The receiver lags behind and never drains the queue, the log must not keep what it has taken
+	go func() {
+		for i := 0; i < 2000; i++ {
+			q.In <- map[string]interface{}{"n": i}
+		}
+	}()
+	for i := 0; i < 1996; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for logSize(t, path) > maxSize/4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("log size = %d with 4 messages not taken, expected acknowledged segments to be deleted", logSize(t, path))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	for i := 1996; i < 2000; i++ {
+		if n := receiveMessage(t, q); n != i {
+			t.Fatalf("received %d, expected %d", n, i)
+		}
+	}
+}
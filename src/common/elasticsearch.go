@@ -32,7 +32,8 @@ type ElasticsearchProducer struct {
 	flushDur      time.Duration
 	maxRetries    int
 	retryDelay    time.Duration
-	stopChan      chan struct{} // Add stop channel for graceful shutdown
+	deadLetter    DeadLetterFunc // Optional, receives batches that failed to index
+	stopChan      chan struct{}  // Add stop channel for graceful shutdown
 }
 
 // replaceTimePatterns replaces time patterns in index name with actual values
@@ -67,7 +68,7 @@ func replaceTimePatterns(indexTemplate string) string {
 }
 
 // NewElasticsearchProducer creates a new Elasticsearch producer
-func NewElasticsearchProducer(hosts []string, index string, msgChan chan map[string]interface{}, batchSize int, flushDur time.Duration, auth *ElasticsearchAuthConfig) (*ElasticsearchProducer, error) {
+func NewElasticsearchProducer(hosts []string, index string, msgChan chan map[string]interface{}, batchSize int, flushDur time.Duration, auth *ElasticsearchAuthConfig, deadLetter DeadLetterFunc) (*ElasticsearchProducer, error) {
 	cfg := elasticsearch.Config{
 		Addresses:     hosts,
 		MaxRetries:    3,
@@ -117,6 +118,7 @@ func NewElasticsearchProducer(hosts []string, index string, msgChan chan map[str
 		flushDur:      flushDur,
 		maxRetries:    3,
 		retryDelay:    1 * time.Second,
+		deadLetter:    deadLetter,
 		stopChan:      make(chan struct{}),
 	}
 
@@ -141,6 +143,9 @@ func (p *ElasticsearchProducer) run() {
 			}
 			// Don't flush remaining batch during shutdown to avoid blocking
 			// Just return immediately to ensure fast shutdown
+			if len(batch) > 0 && p.deadLetter != nil {
+				p.deadLetter(batch, fmt.Errorf("producer stopped before batch was flushed"))
+			}
 			return
 		case msg, ok := <-p.MsgChan:
 			if !ok {
@@ -207,6 +212,7 @@ func (p *ElasticsearchProducer) sendBatch(batch []map[string]interface{}) {
 		if err != nil {
 			if i == p.maxRetries {
 				fmt.Printf("Failed to send batch to ES after %d retries: %v\n", p.maxRetries, err)
+				p.failBatch(batch, err)
 				return
 			}
 			time.Sleep(p.retryDelay)
@@ -217,6 +223,7 @@ func (p *ElasticsearchProducer) sendBatch(batch []map[string]interface{}) {
 		if res.IsError() {
 			if i == p.maxRetries {
 				fmt.Printf("ES returned error after %d retries: %s\n", p.maxRetries, res.String())
+				p.failBatch(batch, fmt.Errorf("elasticsearch returned error: %s", res.Status()))
 				return
 			}
 			time.Sleep(p.retryDelay)
@@ -228,6 +235,17 @@ func (p *ElasticsearchProducer) sendBatch(batch []map[string]interface{}) {
 	}
 }
 
+// failBatch hands a batch that could not be indexed to the dead letter handler
+func (p *ElasticsearchProducer) failBatch(batch []map[string]interface{}, err error) {
+	if p.deadLetter == nil {
+		return
+	}
+	// The batch slice is reused by run, pass a copy
+	failed := make([]map[string]interface{}, len(batch))
+	copy(failed, batch)
+	p.deadLetter(failed, err)
+}
+
 // flush batch writes to ES
 func (p *ElasticsearchProducer) flush(batch []map[string]interface{}) {
 	p.sendBatch(batch)
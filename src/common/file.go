@@ -0,0 +1,381 @@
+package common
+
+import (
+	"AgentSmith-HUB/logger"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+const (
+	FileFormatJSON = "json"
+	FileFormatRaw  = "raw"
+
+	FileStartBeginning = "beginning"
+	FileStartEnd       = "end"
+)
+
+// fileOffset is the persisted read position of a tailed file.
+// Inode is used to detect rotation: a path pointing to a new inode is read from the start.
+type fileOffset struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// tailedFile is a file currently being followed by the FileConsumer
+type tailedFile struct {
+	path   string
+	file   *os.File
+	reader *bufio.Reader
+	inode  uint64
+	offset int64
+}
+
+// FileConsumer tails files matching a set of glob patterns and forwards each line to MsgChan.
+// New files matching the patterns are picked up on every poll, rotated and truncated files are
+// detected, and read offsets are persisted so that a restart continues where it left off.
+type FileConsumer struct {
+	Paths         []string
+	Format        string
+	StartPosition string
+	OffsetFile    string
+	PollInterval  time.Duration
+	MsgChan       chan map[string]interface{}
+
+	files    map[string]*tailedFile
+	offsets  map[string]fileOffset
+	dirty    bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFileConsumer creates a file consumer and starts tailing immediately.
+func NewFileConsumer(paths []string, format, startPosition, offsetFile string, pollInterval time.Duration, msgChan chan map[string]interface{}) (*FileConsumer, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("at least one path is required")
+	}
+	for _, p := range paths {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %s: %w", p, err)
+		}
+	}
+
+	switch format {
+	case "":
+		format = FileFormatJSON
+	case FileFormatJSON, FileFormatRaw:
+	default:
+		return nil, fmt.Errorf("invalid format value: %s (valid values: json, raw)", format)
+	}
+
+	switch startPosition {
+	case "":
+		startPosition = FileStartEnd
+	case FileStartBeginning, FileStartEnd:
+	default:
+		return nil, fmt.Errorf("invalid start_position value: %s (valid values: beginning, end)", startPosition)
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	c := &FileConsumer{
+		Paths:         paths,
+		Format:        format,
+		StartPosition: startPosition,
+		OffsetFile:    offsetFile,
+		PollInterval:  pollInterval,
+		MsgChan:       msgChan,
+		files:         make(map[string]*tailedFile),
+		offsets:       make(map[string]fileOffset),
+		stopChan:      make(chan struct{}),
+	}
+
+	if err := c.loadOffsets(); err != nil {
+		return nil, err
+	}
+
+	c.wg.Add(1)
+	go c.run()
+	return c, nil
+}
+
+// run polls the configured paths and reads newly appended lines
+func (c *FileConsumer) run() {
+	defer c.wg.Done()
+	defer func() {
+		logger.Info("[FileConsumer] Consumer goroutine exiting")
+		for _, tf := range c.files {
+			c.closeFile(tf)
+		}
+		c.saveOffsets()
+		close(c.MsgChan)
+	}()
+
+	ticker := time.NewTicker(c.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		c.poll()
+		c.saveOffsets()
+
+		select {
+		case <-c.stopChan:
+			logger.Info("[FileConsumer] Stop signal received")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll discovers files, handles rotation and truncation, and reads all complete lines
+func (c *FileConsumer) poll() {
+	matched := make(map[string]bool)
+	for _, pattern := range c.Paths {
+		list, err := filepath.Glob(pattern)
+		if err != nil {
+			logger.Warn("[FileConsumer] glob error", "pattern", pattern, "error", err)
+			continue
+		}
+		for _, p := range list {
+			matched[p] = true
+		}
+	}
+
+	for path := range matched {
+		select {
+		case <-c.stopChan:
+			return
+		default:
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		inode := fileInode(fi)
+
+		tf, ok := c.files[path]
+		if ok && inode != 0 && tf.inode != inode {
+			// Rotated: finish the old file, then follow the new one from the start
+			c.readLines(tf)
+			c.closeFile(tf)
+			delete(c.files, path)
+			c.offsets[path] = fileOffset{Inode: inode, Offset: 0}
+			c.dirty = true
+			ok = false
+		}
+
+		if !ok {
+			tf, err = c.openFile(path, fi, inode)
+			if err != nil {
+				logger.Warn("[FileConsumer] failed to open file", "path", path, "error", err)
+				continue
+			}
+			c.files[path] = tf
+		} else if fi.Size() < tf.offset {
+			// Truncated in place (e.g. copytruncate), start over
+			logger.Info("[FileConsumer] file truncated, reading from start", "path", path)
+			if _, err := tf.file.Seek(0, io.SeekStart); err != nil {
+				logger.Warn("[FileConsumer] failed to seek file", "path", path, "error", err)
+				continue
+			}
+			tf.reader.Reset(tf.file)
+			tf.offset = 0
+		}
+
+		c.readLines(tf)
+	}
+
+	// Release files that no longer match (deleted or moved away)
+	for path, tf := range c.files {
+		if !matched[path] {
+			c.readLines(tf)
+			c.closeFile(tf)
+			delete(c.files, path)
+			delete(c.offsets, path)
+			c.dirty = true
+		}
+	}
+}
+
+// openFile opens path and positions it according to the persisted offset or start_position
+func (c *FileConsumer) openFile(path string, fi os.FileInfo, inode uint64) (*tailedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	if saved, ok := c.offsets[path]; ok && (saved.Inode == inode || inode == 0) && saved.Offset <= fi.Size() {
+		offset = saved.Offset
+	} else if !ok && c.StartPosition == FileStartEnd {
+		offset = fi.Size()
+	}
+
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	c.offsets[path] = fileOffset{Inode: inode, Offset: offset}
+	c.dirty = true
+
+	return &tailedFile{
+		path:   path,
+		file:   f,
+		reader: bufio.NewReaderSize(f, 64*1024),
+		inode:  inode,
+		offset: offset,
+	}, nil
+}
+
+// readLines reads every complete line available in tf. An unterminated trailing line is left
+// for the next poll so that half-written records are never emitted.
+func (c *FileConsumer) readLines(tf *tailedFile) {
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		default:
+		}
+
+		line, err := tf.reader.ReadBytes('\n')
+		if err != nil {
+			if len(line) > 0 {
+				// Rewind to the start of the partial line
+				if _, seekErr := tf.file.Seek(tf.offset, io.SeekStart); seekErr == nil {
+					tf.reader.Reset(tf.file)
+				}
+			}
+			if err != io.EOF {
+				logger.Warn("[FileConsumer] read error", "path", tf.path, "error", err)
+			}
+			return
+		}
+
+		size := int64(len(line))
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			c.advance(tf, size)
+			continue
+		}
+
+		var m map[string]interface{}
+		if c.Format == FileFormatRaw {
+			m = map[string]interface{}{
+				"message": string(line),
+				"path":    tf.path,
+			}
+		} else if err := sonic.Unmarshal(line, &m); err != nil {
+			logger.Error("[FileConsumer] failed to deserialize line", "path", tf.path, "error", err.Error())
+			c.advance(tf, size)
+			continue
+		}
+
+		// Blocking send to ensure no data loss
+		// If downstream is full, this will block and prevent further reading
+		select {
+		case c.MsgChan <- m:
+			// The offset only covers lines handed off, a line interrupted by stop is read again
+			c.advance(tf, size)
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// advance moves the offset of tf past a line that has been handled
+func (c *FileConsumer) advance(tf *tailedFile, size int64) {
+	tf.offset += size
+	c.offsets[tf.path] = fileOffset{Inode: tf.inode, Offset: tf.offset}
+	c.dirty = true
+}
+
+func (c *FileConsumer) closeFile(tf *tailedFile) {
+	if tf.file != nil {
+		_ = tf.file.Close()
+		tf.file = nil
+	}
+}
+
+// loadOffsets restores persisted offsets, a missing offset file is not an error
+func (c *FileConsumer) loadOffsets() error {
+	if c.OffsetFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.OffsetFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read offset file %s: %w", c.OffsetFile, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := sonic.Unmarshal(data, &c.offsets); err != nil {
+		logger.Warn("[FileConsumer] offset file is corrupted, ignoring", "path", c.OffsetFile, "error", err)
+		c.offsets = make(map[string]fileOffset)
+	}
+	return nil
+}
+
+// saveOffsets writes offsets atomically via a temporary file
+func (c *FileConsumer) saveOffsets() {
+	if c.OffsetFile == "" || !c.dirty {
+		return
+	}
+	data, err := sonic.Marshal(c.offsets)
+	if err != nil {
+		logger.Error("[FileConsumer] failed to marshal offsets", "error", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.OffsetFile), 0755); err != nil {
+		logger.Error("[FileConsumer] failed to create offset directory", "path", c.OffsetFile, "error", err)
+		return
+	}
+	tmp := c.OffsetFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.Error("[FileConsumer] failed to write offsets", "path", tmp, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, c.OffsetFile); err != nil {
+		logger.Error("[FileConsumer] failed to save offsets", "path", c.OffsetFile, "error", err)
+		return
+	}
+	c.dirty = false
+}
+
+// Close stops tailing and persists the final offsets
+func (c *FileConsumer) Close() {
+	close(c.stopChan)
+	c.wg.Wait()
+}
+
+// TestFilePaths checks that the patterns are valid and reports how many files currently match
+func TestFilePaths(paths []string) (int, error) {
+	total := 0
+	for _, p := range paths {
+		list, err := filepath.Glob(p)
+		if err != nil {
+			return 0, fmt.Errorf("invalid path pattern %s: %w", p, err)
+		}
+		for _, f := range list {
+			if fi, err := os.Stat(f); err == nil && !fi.IsDir() {
+				total++
+			}
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package common
+
+import "os"
+
+// fileInode is not available on windows, rotation falls back to truncation detection
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFileConsumer(t *testing.T, pattern, offsetFile string, capacity int) (*FileConsumer, chan map[string]interface{}) {
+	t.Helper()
+	msgChan := make(chan map[string]interface{}, capacity)
+	c, err := NewFileConsumer([]string{pattern}, FileFormatRaw, FileStartBeginning, offsetFile, 10*time.Millisecond, msgChan)
+	if err != nil {
+		t.Fatalf("NewFileConsumer failed: %v", err)
+	}
+	return c, msgChan
+}
+
+func expectLines(t *testing.T, msgChan chan map[string]interface{}, lines ...string) {
+	t.Helper()
+	for _, expected := range lines {
+		select {
+		case m := <-msgChan:
+			if m["message"] != expected {
+				t.Fatalf("received %q, expected %q", m["message"], expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no line received, expected %q", expected)
+		}
+	}
+}
+
+func expectNoLine(t *testing.T, msgChan chan map[string]interface{}) {
+	t.Helper()
+	select {
+	case m, ok := <-msgChan:
+		if ok {
+			t.Fatalf("unexpected line %q", m["message"])
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestFileConsumerResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	offsetFile := filepath.Join(dir, "offsets", "in.json")
+
+	appendFile(t, path, "a\nb\n")
+	c, msgChan := newTestFileConsumer(t, filepath.Join(dir, "*.log"), offsetFile, 16)
+	expectLines(t, msgChan, "a", "b")
+	c.Close()
+
+	appendFile(t, path, "c\n")
+	c, msgChan = newTestFileConsumer(t, filepath.Join(dir, "*.log"), offsetFile, 16)
+	defer c.Close()
+	expectLines(t, msgChan, "c")
+	expectNoLine(t, msgChan)
+}
+
+func TestFileConsumerResumeAfterStopDuringSend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	offsetFile := filepath.Join(dir, "offsets", "in.json")
+
+	appendFile(t, path, "l0\nl1\nl2\nl3\n")
+	c, msgChan := newTestFileConsumer(t, filepath.Join(dir, "*.log"), offsetFile, 1)
+	expectLines(t, msgChan, "l0")
+	// l1 is handed off to the channel, the consumer is blocked sending l2
+	deadline := time.Now().Add(5 * time.Second)
+	for len(msgChan) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.Close()
+	expectLines(t, msgChan, "l1")
+
+	c, msgChan = newTestFileConsumer(t, filepath.Join(dir, "*.log"), offsetFile, 16)
+	defer c.Close()
+	expectLines(t, msgChan, "l2", "l3")
+}
+
+func TestFileConsumerRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	appendFile(t, path, "old1\n")
+	c, msgChan := newTestFileConsumer(t, path, filepath.Join(dir, "offsets.json"), 16)
+	defer c.Close()
+	expectLines(t, msgChan, "old1")
+
+	// Lines written just before the rename are still read from the rotated file
+	appendFile(t, path, "old2\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	appendFile(t, path, "new1\n")
+	expectLines(t, msgChan, "old2", "new1")
+
+	appendFile(t, path, "new2\n")
+	expectLines(t, msgChan, "new2")
+	expectNoLine(t, msgChan)
+}
+
+func TestFileConsumerTruncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	appendFile(t, path, "first\nsecond\n")
+	c, msgChan := newTestFileConsumer(t, path, filepath.Join(dir, "offsets.json"), 16)
+	defer c.Close()
+	expectLines(t, msgChan, "first", "second")
+
+	// copytruncate keeps the inode
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+	appendFile(t, path, "third\n")
+	expectLines(t, msgChan, "third")
+	expectNoLine(t, msgChan)
+}
+
+func TestFileConsumerPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	appendFile(t, path, "complete\nhalf")
+	c, msgChan := newTestFileConsumer(t, path, filepath.Join(dir, "offsets.json"), 16)
+	defer c.Close()
+	expectLines(t, msgChan, "complete")
+	expectNoLine(t, msgChan)
+
+	appendFile(t, path, "-written\n")
+	expectLines(t, msgChan, "half-written")
+}
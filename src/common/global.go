@@ -11,6 +11,7 @@ var AllOutputsRawConfig map[string]string
 var AllRulesetsRawConfig map[string]string
 var AllProjectRawConfig map[string]string
 var AllPluginsRawConfig map[string]string
+var AllLookupsRawConfig map[string]string
 
 // Dedicated lock for AllRawConfig variables
 var RawConfigMu sync.RWMutex
@@ -59,6 +60,9 @@ func GetRawConfig(componentType, id string) (string, bool) {
 	case "plugin":
 		config, exists := AllPluginsRawConfig[id]
 		return config, exists
+	case "lookup":
+		config, exists := AllLookupsRawConfig[id]
+		return config, exists
 	default:
 		return "", false
 	}
@@ -95,6 +99,11 @@ func SetRawConfig(componentType, id, config string) {
 			AllPluginsRawConfig = make(map[string]string)
 		}
 		AllPluginsRawConfig[id] = config
+	case "lookup":
+		if AllLookupsRawConfig == nil {
+			AllLookupsRawConfig = make(map[string]string)
+		}
+		AllLookupsRawConfig[id] = config
 	}
 }
 
@@ -114,6 +123,8 @@ func DeleteRawConfig(componentType, id string) {
 		delete(AllProjectRawConfig, id)
 	case "plugin":
 		delete(AllPluginsRawConfig, id)
+	case "lookup":
+		delete(AllLookupsRawConfig, id)
 	}
 }
 
@@ -130,6 +141,8 @@ func DeleteRawConfigUnsafe(componentType, id string) {
 		delete(AllProjectRawConfig, id)
 	case "plugin":
 		delete(AllPluginsRawConfig, id)
+	case "lookup":
+		delete(AllLookupsRawConfig, id)
 	}
 }
 
@@ -143,6 +156,7 @@ func ClearAllRawConfigsForAllTypes() {
 	AllRulesetsRawConfig = make(map[string]string)
 	AllProjectRawConfig = make(map[string]string)
 	AllPluginsRawConfig = make(map[string]string)
+	AllLookupsRawConfig = make(map[string]string)
 }
 
 // ForEachRawConfig safely iterates over all raw configurations for a specific type
@@ -163,6 +177,8 @@ func ForEachRawConfig(componentType string, fn func(id, config string) bool) {
 		targetMap = AllProjectRawConfig
 	case "plugin":
 		targetMap = AllPluginsRawConfig
+	case "lookup":
+		targetMap = AllLookupsRawConfig
 	default:
 		return
 	}
@@ -238,4 +254,5 @@ func init() {
 	AllRulesetsRawConfig = make(map[string]string, 0)
 	AllProjectRawConfig = make(map[string]string, 0)
 	AllPluginsRawConfig = make(map[string]string, 0)
+	AllLookupsRawConfig = make(map[string]string, 0)
 }
@@ -0,0 +1,248 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	HTTPAuthToken = "token"
+	HTTPAuthHMAC  = "hmac"
+
+	defaultHTTPTokenHeader = "Authorization"
+	defaultHTTPHMACHeader  = "X-Signature-256"
+	defaultHTTPMaxBodySize = 10 * 1024 * 1024
+)
+
+var (
+	ErrHTTPReceiverUnauthorized = errors.New("authentication failed")
+	ErrHTTPReceiverClosed       = errors.New("input is not running")
+	ErrHTTPReceiverBadRequest   = errors.New("invalid request body")
+)
+
+// HTTPAuthConfig describes how webhook senders authenticate.
+// token: the header carries the shared token, optionally as "Bearer <token>".
+// hmac: the header carries the hex encoded HMAC of the raw body, optionally with a prefix such as "sha256=".
+type HTTPAuthConfig struct {
+	Type            string `yaml:"type"`
+	Header          string `yaml:"header,omitempty"`
+	Token           string `yaml:"token,omitempty"`
+	Secret          string `yaml:"secret,omitempty"`
+	Algorithm       string `yaml:"algorithm,omitempty"`        // sha256 (default) or sha1
+	SignaturePrefix string `yaml:"signature_prefix,omitempty"` // e.g. "sha256=" for GitHub
+}
+
+// HTTPReceiver accepts pushed JSON payloads for one input and forwards each object to MsgChan
+type HTTPReceiver struct {
+	InputID     string
+	Auth        HTTPAuthConfig
+	MaxBodySize int64
+	MsgChan     chan map[string]interface{}
+
+	mu       sync.RWMutex
+	closed   bool
+	stopChan chan struct{}
+}
+
+var (
+	httpReceivers   = make(map[string]*HTTPReceiver)
+	httpReceiversMu sync.RWMutex
+)
+
+// NewHTTPReceiver creates a receiver and registers it so that /ingest/<inputID> reaches it
+func NewHTTPReceiver(inputID string, auth HTTPAuthConfig, maxBodySize int64, msgChan chan map[string]interface{}) (*HTTPReceiver, error) {
+	if err := ValidateHTTPAuthConfig(&auth); err != nil {
+		return nil, err
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = defaultHTTPMaxBodySize
+	}
+
+	r := &HTTPReceiver{
+		InputID:     inputID,
+		Auth:        auth,
+		MaxBodySize: maxBodySize,
+		MsgChan:     msgChan,
+		stopChan:    make(chan struct{}),
+	}
+
+	httpReceiversMu.Lock()
+	defer httpReceiversMu.Unlock()
+	if _, exists := httpReceivers[inputID]; exists {
+		return nil, fmt.Errorf("http receiver already registered for input %s", inputID)
+	}
+	httpReceivers[inputID] = r
+	return r, nil
+}
+
+// GetHTTPReceiver returns the running receiver of an input
+func GetHTTPReceiver(inputID string) (*HTTPReceiver, bool) {
+	httpReceiversMu.RLock()
+	defer httpReceiversMu.RUnlock()
+	r, ok := httpReceivers[inputID]
+	return r, ok
+}
+
+// ValidateHTTPAuthConfig checks the auth settings and fills in defaults
+func ValidateHTTPAuthConfig(auth *HTTPAuthConfig) error {
+	switch auth.Type {
+	case HTTPAuthToken:
+		if auth.Token == "" {
+			return fmt.Errorf("auth.token is required for token authentication")
+		}
+		if auth.Header == "" {
+			auth.Header = defaultHTTPTokenHeader
+		}
+	case HTTPAuthHMAC:
+		if auth.Secret == "" {
+			return fmt.Errorf("auth.secret is required for hmac authentication")
+		}
+		if auth.Header == "" {
+			auth.Header = defaultHTTPHMACHeader
+		}
+		if auth.Algorithm == "" {
+			auth.Algorithm = "sha256"
+		}
+		if auth.Algorithm != "sha256" && auth.Algorithm != "sha1" {
+			return fmt.Errorf("invalid auth.algorithm value: %s (valid values: sha256, sha1)", auth.Algorithm)
+		}
+	case "":
+		return fmt.Errorf("auth.type is required")
+	default:
+		return fmt.Errorf("invalid auth.type value: %s (valid values: token, hmac)", auth.Type)
+	}
+	return nil
+}
+
+// Authenticate verifies the request headers against the raw body
+func (r *HTTPReceiver) Authenticate(header http.Header, body []byte) bool {
+	value := strings.TrimSpace(header.Get(r.Auth.Header))
+	if value == "" {
+		return false
+	}
+
+	switch r.Auth.Type {
+	case HTTPAuthToken:
+		if len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+			value = strings.TrimSpace(value[7:])
+		}
+		return subtle.ConstantTimeCompare([]byte(value), []byte(r.Auth.Token)) == 1
+	case HTTPAuthHMAC:
+		value = strings.TrimPrefix(value, r.Auth.SignaturePrefix)
+		got, err := hex.DecodeString(value)
+		if err != nil {
+			return false
+		}
+		var h func() hash.Hash
+		if r.Auth.Algorithm == "sha1" {
+			h = sha1.New
+		} else {
+			h = sha256.New
+		}
+		mac := hmac.New(h, []byte(r.Auth.Secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+	return false
+}
+
+// Push authenticates and decodes a request body, then forwards every object to MsgChan.
+// The body may be a single JSON object, a JSON array of objects, or newline delimited objects.
+// Returns the number of accepted messages.
+func (r *HTTPReceiver) Push(ctx context.Context, header http.Header, body []byte) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return 0, ErrHTTPReceiverClosed
+	}
+
+	if !r.Authenticate(header, body) {
+		return 0, ErrHTTPReceiverUnauthorized
+	}
+
+	msgs, err := DecodeJSONPayload(body)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrHTTPReceiverBadRequest, err)
+	}
+
+	for i, m := range msgs {
+		// Blocking send to ensure no data loss, the sender waits while downstream is busy
+		select {
+		case r.MsgChan <- m:
+		case <-ctx.Done():
+			return i, ctx.Err()
+		case <-r.stopChan:
+			return i, ErrHTTPReceiverClosed
+		}
+	}
+	return len(msgs), nil
+}
+
+// DecodeJSONPayload decodes a stream of JSON values, each being an object or an array of objects
+func DecodeJSONPayload(body []byte) ([]map[string]interface{}, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty body")
+	}
+
+	var msgs []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		raw = bytes.TrimSpace(raw)
+		switch raw[0] {
+		case '{':
+			var m map[string]interface{}
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, m)
+		case '[':
+			var list []map[string]interface{}
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("array elements must be JSON objects: %v", err)
+			}
+			msgs = append(msgs, list...)
+		default:
+			return nil, fmt.Errorf("payload must be JSON objects")
+		}
+	}
+	return msgs, nil
+}
+
+// Close unregisters the receiver, waits for in-flight requests and closes MsgChan
+func (r *HTTPReceiver) Close() {
+	httpReceiversMu.Lock()
+	if httpReceivers[r.InputID] == r {
+		delete(httpReceivers, r.InputID)
+	}
+	httpReceiversMu.Unlock()
+
+	// Unblock in-flight pushes, then wait for them to release the read lock
+	close(r.stopChan)
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.MsgChan)
+}
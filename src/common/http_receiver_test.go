@@ -0,0 +1,186 @@
+package common
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(h func() hash.Hash, secret, body string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestHTTPReceiver(t *testing.T, id string, auth HTTPAuthConfig, capacity int) *HTTPReceiver {
+	t.Helper()
+	r, err := NewHTTPReceiver(id, auth, 0, make(chan map[string]interface{}, capacity))
+	if err != nil {
+		t.Fatalf("NewHTTPReceiver failed: %v", err)
+	}
+	return r
+}
+
+func TestValidateHTTPAuthConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     HTTPAuthConfig
+		err      string
+		expected HTTPAuthConfig
+	}{
+		{"token defaults", HTTPAuthConfig{Type: HTTPAuthToken, Token: "t"}, "", HTTPAuthConfig{Type: HTTPAuthToken, Token: "t", Header: "Authorization"}},
+		{"hmac defaults", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s"}, "", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s", Header: "X-Signature-256", Algorithm: "sha256"}},
+		{"hmac custom", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s", Header: "X-Hub-Signature", Algorithm: "sha1"}, "", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s", Header: "X-Hub-Signature", Algorithm: "sha1"}},
+		{"token without token", HTTPAuthConfig{Type: HTTPAuthToken}, "auth.token is required", HTTPAuthConfig{}},
+		{"hmac without secret", HTTPAuthConfig{Type: HTTPAuthHMAC}, "auth.secret is required", HTTPAuthConfig{}},
+		{"hmac md5", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s", Algorithm: "md5"}, "invalid auth.algorithm", HTTPAuthConfig{}},
+		{"no type", HTTPAuthConfig{}, "auth.type is required", HTTPAuthConfig{}},
+		{"basic", HTTPAuthConfig{Type: "basic"}, "invalid auth.type", HTTPAuthConfig{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := test.auth
+			err := ValidateHTTPAuthConfig(&auth)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("ValidateHTTPAuthConfig() = %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || auth != test.expected {
+				t.Errorf("ValidateHTTPAuthConfig() = %v with %+v, expected %+v", err, auth, test.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPReceiverAuthenticate(t *testing.T) {
+	body := `{"action":"opened"}`
+	token := newTestHTTPReceiver(t, "token", HTTPAuthConfig{Type: HTTPAuthToken, Token: "secret-token"}, 1)
+	defer token.Close()
+	hmac256 := newTestHTTPReceiver(t, "hmac256", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s3cret", SignaturePrefix: "sha256="}, 1)
+	defer hmac256.Close()
+	hmac1 := newTestHTTPReceiver(t, "hmac1", HTTPAuthConfig{Type: HTTPAuthHMAC, Secret: "s3cret", Header: "X-Hub-Signature", Algorithm: "sha1"}, 1)
+	defer hmac1.Close()
+
+	tests := []struct {
+		name     string
+		receiver *HTTPReceiver
+		header   string
+		value    string
+		body     string
+		ok       bool
+	}{
+		{"token", token, "Authorization", "secret-token", body, true},
+		{"bearer token", token, "Authorization", "bearer secret-token", body, true},
+		{"wrong token", token, "Authorization", "Bearer secret", body, false},
+		{"missing token", token, "X-Other", "secret-token", body, false},
+		{"hmac sha256", hmac256, "X-Signature-256", "sha256=" + sign(sha256.New, "s3cret", body), body, true},
+		{"hmac other body", hmac256, "X-Signature-256", "sha256=" + sign(sha256.New, "s3cret", body), body + " ", false},
+		{"hmac other secret", hmac256, "X-Signature-256", "sha256=" + sign(sha256.New, "other", body), body, false},
+		{"hmac not hex", hmac256, "X-Signature-256", "sha256=zz", body, false},
+		{"hmac sha1", hmac1, "X-Hub-Signature", sign(sha1.New, "s3cret", body), body, true},
+		{"hmac sha1 with sha256", hmac1, "X-Hub-Signature", sign(sha256.New, "s3cret", body), body, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(test.header, test.value)
+			if ok := test.receiver.Authenticate(header, []byte(test.body)); ok != test.ok {
+				t.Errorf("Authenticate() = %v, expected %v", ok, test.ok)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []map[string]interface{}
+		err      bool
+	}{
+		{"object", `{"a":1}`, []map[string]interface{}{{"a": 1.0}}, false},
+		{"array", `[{"a":1},{"a":2}]`, []map[string]interface{}{{"a": 1.0}, {"a": 2.0}}, false},
+		{"ndjson", "{\"a\":1}\n{\"a\":2}\n", []map[string]interface{}{{"a": 1.0}, {"a": 2.0}}, false},
+		{"mixed", "[{\"a\":1}]\n{\"a\":2}", []map[string]interface{}{{"a": 1.0}, {"a": 2.0}}, false},
+		{"empty", "  \n", nil, true},
+		{"scalar", `"text"`, nil, true},
+		{"array of scalars", `[1,2]`, nil, true},
+		{"invalid", `{"a":`, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msgs, err := DecodeJSONPayload([]byte(test.body))
+			if (err != nil) != test.err {
+				t.Fatalf("DecodeJSONPayload(%q) error = %v, expected error %v", test.body, err, test.err)
+			}
+			if !reflect.DeepEqual(msgs, test.expected) {
+				t.Errorf("DecodeJSONPayload(%q) = %v, expected %v", test.body, msgs, test.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPReceiverPush(t *testing.T) {
+	r := newTestHTTPReceiver(t, "push", HTTPAuthConfig{Type: HTTPAuthToken, Token: "t"}, 2)
+	if _, err := NewHTTPReceiver(r.InputID, r.Auth, 0, make(chan map[string]interface{})); err == nil {
+		t.Error("second receiver for the same input registered")
+	}
+	if got, ok := GetHTTPReceiver(r.InputID); !ok || got != r {
+		t.Fatal("receiver is not registered")
+	}
+
+	header := http.Header{"Authorization": []string{"t"}}
+	if _, err := r.Push(context.Background(), http.Header{}, []byte(`{}`)); !errors.Is(err, ErrHTTPReceiverUnauthorized) {
+		t.Errorf("Push() without token = %v, expected unauthorized", err)
+	}
+	if _, err := r.Push(context.Background(), header, []byte(`nope`)); !errors.Is(err, ErrHTTPReceiverBadRequest) {
+		t.Errorf("Push() of an invalid body = %v, expected bad request", err)
+	}
+	if n, err := r.Push(context.Background(), header, []byte(`[{"a":1},{"a":2}]`)); n != 2 || err != nil {
+		t.Errorf("Push() = %d, %v, expected 2 accepted", n, err)
+	}
+
+	// The channel is full, the push waits for downstream until the request is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if n, err := r.Push(ctx, header, []byte(`{"a":3}`)); n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Push() to a full channel = %d, %v, expected the deadline", n, err)
+	}
+
+	// Close unblocks waiting pushes
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Push(context.Background(), header, []byte(`{"a":3}`))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrHTTPReceiverClosed) {
+			t.Errorf("blocked Push() = %v, expected closed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not unblock the push")
+	}
+	if _, ok := GetHTTPReceiver(r.InputID); ok {
+		t.Error("receiver is registered after Close")
+	}
+	if _, err := r.Push(context.Background(), header, []byte(`{}`)); !errors.Is(err, ErrHTTPReceiverClosed) {
+		t.Errorf("Push() after Close = %v, expected closed", err)
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// hllPrecision gives 4096 one-byte registers (4KB per counter, ~1.6% standard error)
+const hllPrecision = 12
+
+// HyperLogLog estimates the number of distinct values with a fixed memory footprint.
+// It is the local counterpart of Redis PFADD/PFCOUNT and is not safe for concurrent use.
+type HyperLogLog struct {
+	registers []uint8
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+// Add records a value
+func (h *HyperLogLog) Add(value string) {
+	hash := xxhash.Sum64String(value)
+	idx := hash >> (64 - hllPrecision)
+	// Rank of the first set bit in the remaining bits, the guard bit caps it at 64-p+1
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Count returns the estimated number of distinct values added
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1.0 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate for small cardinalities
+	if zeros > 0 {
+		if lc := m * math.Log(m/float64(zeros)); lc <= 3*m {
+			estimate = lc
+		}
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Size returns the memory used by the registers in bytes
+func (h *HyperLogLog) Size() int64 {
+	return int64(len(h.registers))
+}
@@ -74,7 +74,8 @@ type KafkaProducer struct {
 	KeyFieldList []string // List of fields to use as keys
 	BatchSize    int
 	BatchTimeout time.Duration
-	stopChan     chan struct{} // Add stop channel for graceful shutdown
+	DeadLetter   DeadLetterFunc // Optional, receives messages that failed to produce
+	stopChan     chan struct{}  // Add stop channel for graceful shutdown
 }
 
 func EnsureTopicExists(cl *kgo.Client, topic string) (bool, error) {
@@ -102,6 +103,7 @@ func NewKafkaProducer(
 	msgChan chan map[string]interface{},
 	keyField string,
 	tlsCfg *KafkaTLSConfig,
+	deadLetter DeadLetterFunc,
 ) (*KafkaProducer, error) {
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(brokers...),
@@ -149,6 +151,7 @@ func NewKafkaProducer(
 		KeyFieldList: StringToList(keyField),
 		BatchSize:    1000,
 		BatchTimeout: 100 * time.Millisecond,
+		DeadLetter:   deadLetter,
 		stopChan:     make(chan struct{}),
 	}
 
@@ -198,6 +201,9 @@ func (p *KafkaProducer) run() {
 			p.Client.Produce(context.Background(), rec, func(r *kgo.Record, err error) {
 				if err != nil {
 					logger.Error("[KafkaProducer] failed to produce message to topic", "topic", p.Topic, "error", err)
+					if p.DeadLetter != nil {
+						p.DeadLetter([]map[string]interface{}{msg}, err)
+					}
 				}
 			})
 		}
@@ -246,6 +252,9 @@ func (p *KafkaProducer) drainRemainingMessages() {
 			p.Client.Produce(context.Background(), rec, func(r *kgo.Record, err error) {
 				if err != nil {
 					logger.Error("[KafkaProducer] failed to produce message to topic during drain", "topic", p.Topic, "error", err)
+					if p.DeadLetter != nil {
+						p.DeadLetter([]map[string]interface{}{msg}, err)
+					}
 				}
 			})
 			drainCount++
@@ -263,9 +272,18 @@ func (p *KafkaProducer) Close() {
 type KafkaConsumer struct {
 	Client   *kgo.Client
 	MsgChan  chan map[string]interface{}
+	Group    string
 	stopChan chan struct{}
 }
 
+// KafkaPartitionLag is the lag of a consumer group on a partition
+type KafkaPartitionLag struct {
+	Group     string
+	Topic     string
+	Partition int32
+	Lag       int64
+}
+
 // getCompression returns the appropriate compression option based on the compression type
 // compression: The type of compression to use (Snappy, Gzip, Lz4, Zstd)
 func getCompression(compression KafkaCompressionType) kgo.CompressionCodec {
@@ -389,6 +407,7 @@ func NewKafkaConsumer(brokers []string, group, topic string, compression KafkaCo
 	cons := &KafkaConsumer{
 		Client:   cl,
 		MsgChan:  msgChan,
+		Group:    group,
 		stopChan: make(chan struct{}),
 	}
 	go cons.run()
@@ -433,6 +452,7 @@ func (c *KafkaConsumer) run() {
 					logger.Error("[KafkaConsumer] failed to deserialize message", "error", err.Error())
 					return
 				}
+				traceContextFromHeaders(m, rec)
 
 				// Blocking send to ensure no data loss
 				// If downstream is full, this will block and prevent further consumption
@@ -446,6 +466,22 @@ func (c *KafkaConsumer) run() {
 	}
 }
 
+// traceContextFromHeaders keeps the W3C trace context headers of a record in the message,
+// the input continues the trace when tracing is enabled and removes them otherwise
+func traceContextFromHeaders(m map[string]interface{}, rec *kgo.Record) {
+	if m == nil {
+		return
+	}
+	for _, h := range rec.Headers {
+		switch h.Key {
+		case "traceparent":
+			m[TraceParentField] = string(h.Value)
+		case "tracestate":
+			m[TraceStateField] = string(h.Value)
+		}
+	}
+}
+
 // drainRemainingMessages processes any remaining messages in the Kafka client
 func (c *KafkaConsumer) drainRemainingMessages() {
 	// Set a timeout for draining
@@ -477,6 +513,7 @@ func (c *KafkaConsumer) drainRemainingMessages() {
 					logger.Error("[KafkaConsumer] failed to deserialize message during drain", "error", err.Error())
 					return
 				}
+				traceContextFromHeaders(m, rec)
 
 				// Use non-blocking send during drain
 				select {
@@ -495,6 +532,30 @@ func (c *KafkaConsumer) drainRemainingMessages() {
 	}
 }
 
+// Lag returns the lag of the consumer group per partition, committed offsets against end offsets.
+// Partitions are included whichever group member consumes them.
+func (c *KafkaConsumer) Lag(ctx context.Context) ([]KafkaPartitionLag, error) {
+	lags, err := kadm.NewClient(c.Client).Lag(ctx, c.Group)
+	if err != nil {
+		return nil, err
+	}
+	group, ok := lags[c.Group]
+	if !ok {
+		return nil, fmt.Errorf("consumer group %s not found", c.Group)
+	}
+	if err := group.Error(); err != nil {
+		return nil, err
+	}
+	var res []KafkaPartitionLag
+	for _, l := range group.Lag.Sorted() {
+		if l.Err != nil {
+			continue
+		}
+		res = append(res, KafkaPartitionLag{Group: c.Group, Topic: l.Topic, Partition: l.Partition, Lag: l.Lag})
+	}
+	return res, nil
+}
+
 // Close gracefully shuts down the Kafka consumer
 func (c *KafkaConsumer) Close() {
 	close(c.stopChan)
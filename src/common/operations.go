@@ -11,7 +11,7 @@ import (
 )
 
 // RecordProjectOperation records a project operation to Redis only
-func RecordProjectOperation(operationType OperationType, projectID, status, errorMsg string, details map[string]interface{}) {
+func RecordProjectOperation(operationType OperationType, projectID, status, errorMsg, operator string, details map[string]interface{}) {
 	// Ensure details map exists and contains execution node information
 	if details == nil {
 		details = make(map[string]interface{})
@@ -35,6 +35,7 @@ func RecordProjectOperation(operationType OperationType, projectID, status, erro
 		Status:    status,
 		Error:     errorMsg,
 		Details:   details,
+		Operator:  operator,
 	}
 
 	// Serialize record to JSON
@@ -117,7 +118,7 @@ func RecordClusterInstruction(operationType OperationType, instructionType, comp
 }
 
 // RecordComponentAdd records a component addition operation
-func RecordComponentAdd(componentType, componentID, content, status, errorMsg string) {
+func RecordComponentAdd(componentType, componentID, content, status, errorMsg, operator string) {
 	details := map[string]interface{}{
 		"node_id":      Config.LocalIP,
 		"node_address": Config.LocalIP,
@@ -134,6 +135,7 @@ func RecordComponentAdd(componentType, componentID, content, status, errorMsg st
 		Status:        status,
 		Error:         errorMsg,
 		Details:       details,
+		Operator:      operator,
 	}
 
 	// Serialize record to JSON
@@ -158,7 +160,7 @@ func RecordComponentAdd(componentType, componentID, content, status, errorMsg st
 }
 
 // RecordComponentUpdate records a component update operation
-func RecordComponentUpdate(componentType, componentID, content, status, errorMsg string) {
+func RecordComponentUpdate(componentType, componentID, content, status, errorMsg, operator string) {
 	details := map[string]interface{}{
 		"node_id":      Config.LocalIP,
 		"node_address": Config.LocalIP,
@@ -175,6 +177,7 @@ func RecordComponentUpdate(componentType, componentID, content, status, errorMsg
 		Status:        status,
 		Error:         errorMsg,
 		Details:       details,
+		Operator:      operator,
 	}
 
 	// Serialize record to JSON
@@ -445,6 +448,7 @@ type OperationHistoryFilter struct {
 	Status        string        `json:"status"`
 	Keyword       string        `json:"keyword"`
 	NodeID        string        `json:"node_id"`
+	Operator      string        `json:"operator"`
 	Limit         int           `json:"limit"`
 	Offset        int           `json:"offset"`
 }
@@ -534,6 +538,11 @@ func matchesOperationFilter(record OperationRecord, filter OperationHistoryFilte
 		return false
 	}
 
+	// Operator filter
+	if filter.Operator != "" && record.Operator != filter.Operator {
+		return false
+	}
+
 	// Node ID filter
 	if filter.NodeID != "" && filter.NodeID != "all" {
 		nodeID := ""
@@ -567,7 +576,8 @@ func matchesOperationFilter(record OperationRecord, filter OperationHistoryFilte
 			!strings.Contains(strings.ToLower(record.ProjectID), keyword) &&
 			!strings.Contains(strings.ToLower(record.Error), keyword) &&
 			!strings.Contains(strings.ToLower(record.Diff), keyword) &&
-			!strings.Contains(strings.ToLower(nodeID), keyword) {
+			!strings.Contains(strings.ToLower(nodeID), keyword) &&
+			!strings.Contains(strings.ToLower(record.Operator), keyword) {
 			return false
 		}
 	}
@@ -81,6 +81,13 @@ func (cb *CircuitBreaker) IsOpen() bool {
 	return cb.state == CircuitOpen
 }
 
+// State returns the current state of the circuit breaker
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.RLock()
+	defer cb.mutex.RUnlock()
+	return cb.state
+}
+
 // RedisFailureHandler handles Redis operation failures with retry and circuit breaker
 type RedisFailureHandler struct {
 	circuitBreaker *CircuitBreaker
@@ -106,6 +113,14 @@ func NewRedisFailureHandler() *RedisFailureHandler {
 // Global Redis failure handler instance
 var redisFailureHandler *RedisFailureHandler
 
+// RedisCircuitState returns the state of the Redis circuit breaker, closed before Redis is initialized
+func RedisCircuitState() CircuitState {
+	if redisFailureHandler == nil {
+		return CircuitClosed
+	}
+	return redisFailureHandler.circuitBreaker.State()
+}
+
 var ctx = context.Background()
 var rdb *redis.Client
 
@@ -263,6 +278,11 @@ func RedisGet(key string) (string, error) {
 	return val, nil
 }
 
+// RedisGetDel returns the value of key and deletes it in one step, so only one caller can consume it
+func RedisGetDel(key string) (string, error) {
+	return rdb.GetDel(ctx, key).Result()
+}
+
 func RedisKeys(key string) ([]string, error) {
 	return rdb.Keys(ctx, key).Result()
 }
@@ -271,6 +291,11 @@ func RedisSet(key string, value interface{}, expiration int) (string, error) {
 	return rdb.Set(ctx, key, value, time.Duration(expiration)*time.Second).Result()
 }
 
+// RedisSetKeepTTL overwrites the value of key while retaining its current expiration
+func RedisSetKeepTTL(key string, value interface{}) (string, error) {
+	return rdb.Set(ctx, key, value, redis.KeepTTL).Result()
+}
+
 func RedisSetNX(key string, value interface{}, expiration int) (bool, error) {
 	return rdb.SetNX(ctx, key, value, time.Duration(expiration)*time.Second).Result()
 }
@@ -342,6 +367,91 @@ func RedisExpire(key string, expiration int) error {
 	return rdb.Expire(ctx, key, time.Duration(expiration)*time.Second).Err()
 }
 
+// ===================== Threshold Aggregation Helpers =====================
+
+// AggregateStats holds the running numeric aggregates of a threshold window
+type AggregateStats struct {
+	Sum   float64
+	Count int64
+	Max   float64
+	Min   float64
+}
+
+// Add records a value
+func (a *AggregateStats) Add(value float64) {
+	if a.Count == 0 || value > a.Max {
+		a.Max = value
+	}
+	if a.Count == 0 || value < a.Min {
+		a.Min = value
+	}
+	a.Sum += value
+	a.Count++
+}
+
+// Avg returns the mean of the recorded values
+func (a *AggregateStats) Avg() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// PFADD the member and set the expiration on a new key, returns PFCOUNT
+var redisPFAddScript = redis.NewScript(`
+redis.call("PFADD", KEYS[1], ARGV[1])
+if redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return redis.call("PFCOUNT", KEYS[1])
+`)
+
+// RedisPFAddCount adds member to the HyperLogLog at key and returns the estimated distinct count.
+// The expiration is only set when the key is created, so the window starts with the first member.
+func RedisPFAddCount(key string, member string, expiration int) (int64, error) {
+	return redisPFAddScript.Run(ctx, rdb, []string{key}, member, expiration).Int64()
+}
+
+// Update sum/count/max/min in a hash and set the expiration on a new key
+var redisAggregateScript = redis.NewScript(`
+local v = tonumber(ARGV[1])
+local count = redis.call("HINCRBY", KEYS[1], "count", 1)
+local sum = redis.call("HINCRBYFLOAT", KEYS[1], "sum", ARGV[1])
+local max = tonumber(redis.call("HGET", KEYS[1], "max"))
+local min = tonumber(redis.call("HGET", KEYS[1], "min"))
+if count == 1 or max == nil or v > max then
+	max = v
+	redis.call("HSET", KEYS[1], "max", ARGV[1])
+end
+if count == 1 or min == nil or v < min then
+	min = v
+	redis.call("HSET", KEYS[1], "min", ARGV[1])
+end
+if count == 1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return {tostring(sum), tostring(count), tostring(max), tostring(min)}
+`)
+
+// RedisAggregateAdd records value in the aggregate hash at key and returns the updated aggregates.
+// The expiration is only set when the key is created, so the window starts with the first value.
+func RedisAggregateAdd(key string, value float64, expiration int) (AggregateStats, error) {
+	res, err := redisAggregateScript.Run(ctx, rdb, []string{key}, strconv.FormatFloat(value, 'f', -1, 64), expiration).StringSlice()
+	if err != nil {
+		return AggregateStats{}, err
+	}
+	if len(res) != 4 {
+		return AggregateStats{}, fmt.Errorf("unexpected aggregate result: %v", res)
+	}
+
+	var stats AggregateStats
+	stats.Sum, _ = strconv.ParseFloat(res[0], 64)
+	stats.Count, _ = strconv.ParseInt(res[1], 10, 64)
+	stats.Max, _ = strconv.ParseFloat(res[2], 64)
+	stats.Min, _ = strconv.ParseFloat(res[3], 64)
+	return stats, nil
+}
+
 // ===================== Project Config Helpers =====================
 
 // StoreProjectConfig stores project configuration in Redis
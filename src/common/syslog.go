@@ -0,0 +1,613 @@
+package common
+
+import (
+	"AgentSmith-HUB/logger"
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SyslogProtocolUDP = "udp"
+	SyslogProtocolTCP = "tcp"
+	SyslogProtocolTLS = "tls"
+
+	SyslogFormatAuto    = "auto"
+	SyslogFormatRFC3164 = "rfc3164"
+	SyslogFormatRFC5424 = "rfc5424"
+
+	defaultSyslogMaxMessageSize = 64 * 1024
+	defaultSyslogMaxConnections = 1024
+	syslogIdleTimeout           = 5 * time.Minute
+	syslogMaxOctetCountDigits   = 10 // longest length prefix accepted in octet-counted framing
+)
+
+var syslogFacilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var syslogSeverityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// SyslogTLSConfig holds the server side TLS settings of a syslog listener.
+// When CAFilePath is set, clients must present a certificate signed by that CA.
+type SyslogTLSConfig struct {
+	CertPath   string `yaml:"cert_path"`
+	KeyPath    string `yaml:"key_path"`
+	CAFilePath string `yaml:"ca_file_path,omitempty"`
+}
+
+// SyslogServer listens for syslog messages over UDP, TCP or TLS and forwards parsed messages to MsgChan.
+// Sends to MsgChan are blocking: for TCP/TLS a slow downstream stops reading from the socket and pushes
+// back to the sender, for UDP the kernel receive buffer absorbs bursts.
+type SyslogServer struct {
+	Protocol       string
+	Address        string
+	Format         string
+	MaxConnections int
+	MaxMessageSize int
+	MsgChan        chan map[string]interface{}
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	connSem    chan struct{}
+	conns      map[net.Conn]struct{}
+	connsMu    sync.Mutex
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewSyslogServer binds the listener and starts serving immediately.
+func NewSyslogServer(protocol, address, format string, maxConnections, maxMessageSize int, tlsCfg *SyslogTLSConfig, msgChan chan map[string]interface{}) (*SyslogServer, error) {
+	if format == "" {
+		format = SyslogFormatAuto
+	}
+	if format != SyslogFormatAuto && format != SyslogFormatRFC3164 && format != SyslogFormatRFC5424 {
+		return nil, fmt.Errorf("invalid format value: %s (valid values: auto, rfc3164, rfc5424)", format)
+	}
+	if maxConnections <= 0 {
+		maxConnections = defaultSyslogMaxConnections
+	}
+	if maxMessageSize <= 0 {
+		maxMessageSize = defaultSyslogMaxMessageSize
+	}
+
+	s := &SyslogServer{
+		Protocol:       protocol,
+		Address:        address,
+		Format:         format,
+		MaxConnections: maxConnections,
+		MaxMessageSize: maxMessageSize,
+		MsgChan:        msgChan,
+		connSem:        make(chan struct{}, maxConnections),
+		conns:          make(map[net.Conn]struct{}),
+		stopChan:       make(chan struct{}),
+	}
+
+	switch protocol {
+	case SyslogProtocolUDP:
+		pc, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on udp %s: %w", address, err)
+		}
+		s.packetConn = pc
+		s.wg.Add(1)
+		go s.serveUDP()
+	case SyslogProtocolTCP, SyslogProtocolTLS:
+		var l net.Listener
+		var err error
+		if protocol == SyslogProtocolTLS {
+			cfg, tlsErr := buildSyslogTLSConfig(tlsCfg)
+			if tlsErr != nil {
+				return nil, tlsErr
+			}
+			l, err = tls.Listen("tcp", address, cfg)
+		} else {
+			l, err = net.Listen("tcp", address)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s %s: %w", protocol, address, err)
+		}
+		s.listener = l
+		s.wg.Add(1)
+		go s.serveStream()
+	default:
+		return nil, fmt.Errorf("invalid protocol value: %s (valid values: udp, tcp, tls)", protocol)
+	}
+
+	return s, nil
+}
+
+func buildSyslogTLSConfig(cfg *SyslogTLSConfig) (*tls.Config, error) {
+	if cfg == nil || cfg.CertPath == "" || cfg.KeyPath == "" {
+		return nil, fmt.Errorf("tls protocol requires cert_path and key_path")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server cert/key: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.CAFilePath != "" {
+		caCert, err := os.ReadFile(cfg.CAFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to append CA cert")
+		}
+		tlsCfg.ClientCAs = caPool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+// serveUDP reads one syslog message per datagram
+func (s *SyslogServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, s.MaxMessageSize)
+	for {
+		n, addr, err := s.packetConn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logger.Warn("[SyslogServer] udp read error", "error", err)
+			continue
+		}
+		s.handleMessage(buf[:n], addr)
+	}
+}
+
+// serveStream accepts TCP/TLS connections, rejecting those beyond MaxConnections
+func (s *SyslogServer) serveStream() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logger.Warn("[SyslogServer] accept error", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		select {
+		case s.connSem <- struct{}{}:
+		default:
+			logger.Warn("[SyslogServer] connection limit reached, rejecting", "remote", conn.RemoteAddr().String(), "max_connections", s.MaxConnections)
+			_ = conn.Close()
+			continue
+		}
+
+		s.connsMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads messages framed either by octet counting (RFC 6587) or by newlines
+func (s *SyslogServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		_ = conn.Close()
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+		<-s.connSem
+	}()
+
+	reader := bufio.NewReaderSize(conn, 64*1024)
+	addr := conn.RemoteAddr()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+
+		frame, err := s.readFrame(reader)
+		if len(frame) > 0 {
+			s.handleMessage(frame, addr)
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				select {
+				case <-s.stopChan:
+				default:
+					logger.Debug("[SyslogServer] connection closed", "remote", addr.String(), "error", err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// readFrame returns the next message from a stream connection
+func (s *SyslogServer) readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		// Octet counting: "<length> <message>", the length is read digit by digit so that a
+		// peer cannot make it unbounded
+		var lenBuf []byte
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if b == ' ' {
+				break
+			}
+			if b < '0' || b > '9' || len(lenBuf) == syslogMaxOctetCountDigits {
+				return nil, fmt.Errorf("invalid octet count %q", append(lenBuf, b))
+			}
+			lenBuf = append(lenBuf, b)
+		}
+		length, err := strconv.Atoi(string(lenBuf))
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("invalid octet count %q", lenBuf)
+		}
+		if length > s.MaxMessageSize {
+			return nil, fmt.Errorf("message size %d exceeds max_message_size %d", length, s.MaxMessageSize)
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	// Non-transparent framing: one message per line
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return line, err
+		}
+		if len(line)+len(chunk) > s.MaxMessageSize {
+			// Keep the first max_message_size bytes and drop the remainder of an oversized line
+			line = append(line, chunk[:s.MaxMessageSize-len(line)]...)
+			for isPrefix && err == nil {
+				_, isPrefix, err = reader.ReadLine()
+			}
+			logger.Warn("[SyslogServer] message exceeds max_message_size, truncated", "max_message_size", s.MaxMessageSize)
+			return line, err
+		}
+		line = append(line, chunk...)
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+func (s *SyslogServer) handleMessage(data []byte, addr net.Addr) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+	if len(data) == 0 {
+		return
+	}
+
+	msg, err := ParseSyslogMessage(data, s.Format)
+	if err != nil {
+		logger.Debug("[SyslogServer] failed to parse message, forwarding raw", "error", err)
+		msg = map[string]interface{}{
+			"message": string(data),
+		}
+	}
+	if addr != nil {
+		host, _, splitErr := net.SplitHostPort(addr.String())
+		if splitErr != nil {
+			host = addr.String()
+		}
+		msg["source_ip"] = host
+	}
+
+	// Blocking send to ensure no data loss
+	// If downstream is full, this will block and push back to the sender
+	select {
+	case s.MsgChan <- msg:
+	case <-s.stopChan:
+	}
+}
+
+// Close stops listening, closes active connections and waits for all readers to exit
+func (s *SyslogServer) Close() {
+	close(s.stopChan)
+
+	if s.packetConn != nil {
+		_ = s.packetConn.Close()
+	}
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMu.Unlock()
+
+	s.wg.Wait()
+	close(s.MsgChan)
+}
+
+// ActiveConnections returns the number of open TCP/TLS connections
+func (s *SyslogServer) ActiveConnections() int {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	return len(s.conns)
+}
+
+// TestSyslogAddress checks that the listen address is valid and not already in use
+func TestSyslogAddress(protocol, address string) error {
+	if protocol == SyslogProtocolUDP {
+		pc, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+		return pc.Close()
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// ParseSyslogMessage parses an RFC 3164 or RFC 5424 message into a flat map.
+// format may be auto, rfc3164 or rfc5424.
+func ParseSyslogMessage(data []byte, format string) (map[string]interface{}, error) {
+	pri, rest, err := parseSyslogPriority(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := map[string]interface{}{
+		"priority": pri,
+		"facility": pri / 8,
+		"severity": pri % 8,
+	}
+	if pri/8 < len(syslogFacilityNames) {
+		msg["facility_name"] = syslogFacilityNames[pri/8]
+	}
+	msg["severity_name"] = syslogSeverityNames[pri%8]
+
+	isRFC5424 := len(rest) >= 2 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' '
+	switch format {
+	case SyslogFormatRFC5424:
+		if !isRFC5424 {
+			return nil, fmt.Errorf("not an RFC 5424 message")
+		}
+	case SyslogFormatRFC3164:
+		isRFC5424 = false
+	}
+
+	if isRFC5424 {
+		err = parseRFC5424(rest, msg)
+	} else {
+		parseRFC3164(rest, msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func parseSyslogPriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, fmt.Errorf("missing priority")
+	}
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, fmt.Errorf("invalid priority %q", data[1:end])
+	}
+	return pri, data[end+1:], nil
+}
+
+// parseRFC5424 parses "VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP SD [SP MSG]"
+func parseRFC5424(data []byte, msg map[string]interface{}) error {
+	fields := make([]string, 0, 6)
+	rest := data
+	for i := 0; i < 6; i++ {
+		idx := bytes.IndexByte(rest, ' ')
+		if idx < 0 {
+			return fmt.Errorf("truncated RFC 5424 header")
+		}
+		fields = append(fields, string(rest[:idx]))
+		rest = rest[idx+1:]
+	}
+
+	msg["format"] = SyslogFormatRFC5424
+	msg["version"], _ = strconv.Atoi(fields[0])
+	if fields[1] != "-" {
+		if t, err := time.Parse(time.RFC3339Nano, fields[1]); err == nil {
+			msg["timestamp"] = t.Format(time.RFC3339Nano)
+		} else {
+			msg["timestamp"] = fields[1]
+		}
+	}
+	setSyslogField(msg, "hostname", fields[2])
+	setSyslogField(msg, "app_name", fields[3])
+	setSyslogField(msg, "proc_id", fields[4])
+	setSyslogField(msg, "msg_id", fields[5])
+
+	sd, rest, err := parseStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	if len(sd) > 0 {
+		msg["structured_data"] = sd
+	}
+
+	rest = bytes.TrimPrefix(rest, []byte(" "))
+	// Strip UTF-8 BOM
+	rest = bytes.TrimPrefix(rest, []byte("\xEF\xBB\xBF"))
+	msg["message"] = string(rest)
+	return nil
+}
+
+// parseStructuredData parses "-" or a list of "[id key="value" ...]" elements
+func parseStructuredData(data []byte) (map[string]interface{}, []byte, error) {
+	if len(data) > 0 && data[0] == '-' {
+		return nil, data[1:], nil
+	}
+
+	sd := make(map[string]interface{})
+	for len(data) > 0 && data[0] == '[' {
+		i := 1
+		for i < len(data) && data[i] != ' ' && data[i] != ']' {
+			i++
+		}
+		if i >= len(data) {
+			return nil, nil, fmt.Errorf("unterminated structured data")
+		}
+		id := string(data[1:i])
+		params := make(map[string]interface{})
+
+		for i < len(data) && data[i] == ' ' {
+			i++
+			eq := bytes.IndexByte(data[i:], '=')
+			if eq < 0 || i+eq+1 >= len(data) || data[i+eq+1] != '"' {
+				return nil, nil, fmt.Errorf("invalid structured data param in %s", id)
+			}
+			name := string(data[i : i+eq])
+			i += eq + 2
+
+			var value []byte
+			for i < len(data) && data[i] != '"' {
+				if data[i] == '\\' && i+1 < len(data) && (data[i+1] == '"' || data[i+1] == '\\' || data[i+1] == ']') {
+					i++
+				}
+				value = append(value, data[i])
+				i++
+			}
+			if i >= len(data) {
+				return nil, nil, fmt.Errorf("unterminated structured data value in %s", id)
+			}
+			params[name] = string(value)
+			i++ // closing quote
+		}
+
+		if i >= len(data) || data[i] != ']' {
+			return nil, nil, fmt.Errorf("unterminated structured data element %s", id)
+		}
+		sd[id] = params
+		data = data[i+1:]
+	}
+
+	if len(sd) == 0 {
+		return nil, nil, fmt.Errorf("invalid structured data")
+	}
+	return sd, data, nil
+}
+
+// parseRFC3164 parses "TIMESTAMP HOSTNAME TAG: MSG" leniently, falling back to the whole content as message
+func parseRFC3164(data []byte, msg map[string]interface{}) {
+	msg["format"] = SyslogFormatRFC3164
+	rest := string(data)
+
+	// Timestamp: "Jan _2 15:04:05", optionally followed by a year, or an RFC 3339 timestamp
+	if len(rest) >= len(time.Stamp) {
+		if t, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], time.Local); err == nil {
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			// Messages from late December received in January belong to the previous year
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			msg["timestamp"] = t.Format(time.RFC3339)
+			rest = strings.TrimPrefix(rest[len(time.Stamp):], " ")
+		}
+	}
+	if _, ok := msg["timestamp"]; !ok {
+		if idx := strings.IndexByte(rest, ' '); idx > 0 {
+			if t, err := time.Parse(time.RFC3339Nano, rest[:idx]); err == nil {
+				msg["timestamp"] = t.Format(time.RFC3339Nano)
+				rest = rest[idx+1:]
+			}
+		}
+	}
+
+	// Hostname is only reliable when a timestamp was present
+	if _, ok := msg["timestamp"]; ok {
+		if idx := strings.IndexByte(rest, ' '); idx > 0 && !strings.HasSuffix(rest[:idx], ":") {
+			msg["hostname"] = rest[:idx]
+			rest = rest[idx+1:]
+		}
+	}
+
+	// Tag: alphanumeric program name, optionally followed by [pid], terminated by ':'
+	tagEnd := 0
+	for tagEnd < len(rest) && tagEnd < 48 {
+		c := rest[tagEnd]
+		if c == '[' || c == ':' || c == ' ' {
+			break
+		}
+		tagEnd++
+	}
+	if tagEnd > 0 && tagEnd < len(rest) {
+		app := rest[:tagEnd]
+		after := rest[tagEnd:]
+		pid := ""
+		if after[0] == '[' {
+			if closeIdx := strings.IndexByte(after, ']'); closeIdx > 0 {
+				pid = after[1:closeIdx]
+				after = after[closeIdx+1:]
+			}
+		}
+		if strings.HasPrefix(after, ":") {
+			msg["app_name"] = app
+			if pid != "" {
+				msg["proc_id"] = pid
+			}
+			rest = strings.TrimPrefix(after[1:], " ")
+		}
+	}
+
+	msg["message"] = rest
+}
+
+func setSyslogField(msg map[string]interface{}, key, value string) {
+	if value != "-" && value != "" {
+		msg[key] = value
+	}
+}
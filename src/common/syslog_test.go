@@ -0,0 +1,275 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslogMessage(t *testing.T) {
+	year := time.Now().Year()
+	tests := []struct {
+		name     string
+		data     string
+		format   string
+		yearless bool // Timestamp without a year, checked below
+		expected map[string]interface{}
+	}{
+		{
+			name:   "rfc5424",
+			data:   `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event`,
+			format: SyslogFormatAuto,
+			expected: map[string]interface{}{
+				"priority": 165, "facility": 20, "severity": 5, "facility_name": "local4", "severity_name": "notice",
+				"format": SyslogFormatRFC5424, "version": 1, "timestamp": "2003-10-11T22:14:15.003Z",
+				"hostname": "mymachine.example.com", "app_name": "evntslog", "msg_id": "ID47",
+				"structured_data": map[string]interface{}{
+					"exampleSDID@32473": map[string]interface{}{"iut": "3", "eventSource": "Application"},
+				},
+				"message": "An application event",
+			},
+		},
+		{
+			name:   "rfc5424 nil values and BOM",
+			data:   "<34>1 - - su 123 - - \xEF\xBB\xBF'su root' failed",
+			format: SyslogFormatRFC5424,
+			expected: map[string]interface{}{
+				"priority": 34, "facility": 4, "severity": 2, "facility_name": "auth", "severity_name": "crit",
+				"format": SyslogFormatRFC5424, "version": 1, "app_name": "su", "proc_id": "123",
+				"message": "'su root' failed",
+			},
+		},
+		{
+			name:   "rfc5424 escaped structured data",
+			data:   `<13>1 - host app - - [a x="q\"b\]c\\"][b y=""]`,
+			format: SyslogFormatAuto,
+			expected: map[string]interface{}{
+				"priority": 13, "facility": 1, "severity": 5, "facility_name": "user", "severity_name": "notice",
+				"format": SyslogFormatRFC5424, "version": 1, "hostname": "host", "app_name": "app",
+				"structured_data": map[string]interface{}{
+					"a": map[string]interface{}{"x": `q"b]c\`},
+					"b": map[string]interface{}{"y": ""},
+				},
+				"message": "",
+			},
+		},
+		{
+			name:     "rfc3164",
+			data:     "<34>Oct  1 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8",
+			format:   SyslogFormatAuto,
+			yearless: true,
+			expected: map[string]interface{}{
+				"priority": 34, "facility": 4, "severity": 2, "facility_name": "auth", "severity_name": "crit",
+				"format": SyslogFormatRFC3164, "hostname": "mymachine", "app_name": "su", "proc_id": "230",
+				"message": "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name:   "rfc3164 rfc3339 timestamp",
+			data:   "<13>2024-05-01T10:00:00Z web01 nginx: GET /",
+			format: SyslogFormatRFC3164,
+			expected: map[string]interface{}{
+				"priority": 13, "facility": 1, "severity": 5, "facility_name": "user", "severity_name": "notice",
+				"format": SyslogFormatRFC3164, "timestamp": "2024-05-01T10:00:00Z", "hostname": "web01",
+				"app_name": "nginx", "message": "GET /",
+			},
+		},
+		{
+			name:   "rfc3164 without header",
+			data:   "<13>just some text",
+			format: SyslogFormatAuto,
+			expected: map[string]interface{}{
+				"priority": 13, "facility": 1, "severity": 5, "facility_name": "user", "severity_name": "notice",
+				"format": SyslogFormatRFC3164, "message": "just some text",
+			},
+		},
+		{
+			name:   "rfc5424 forced to rfc3164",
+			data:   "<13>1 - host app - - - hello",
+			format: SyslogFormatRFC3164,
+			expected: map[string]interface{}{
+				"priority": 13, "facility": 1, "severity": 5, "facility_name": "user", "severity_name": "notice",
+				"format": SyslogFormatRFC3164, "message": "1 - host app - - - hello",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := ParseSyslogMessage([]byte(test.data), test.format)
+			if err != nil {
+				t.Fatalf("ParseSyslogMessage(%q) failed: %v", test.data, err)
+			}
+			if test.yearless {
+				delete(msg, "timestamp")
+			}
+			if !reflect.DeepEqual(msg, test.expected) {
+				t.Errorf("ParseSyslogMessage(%q) = %v, expected %v", test.data, msg, test.expected)
+			}
+		})
+	}
+
+	// RFC 3164 timestamps have no year, they get the current one
+	msg, err := ParseSyslogMessage([]byte("<13>Jan  2 03:04:05 host app: x"), SyslogFormatAuto)
+	if err != nil {
+		t.Fatalf("ParseSyslogMessage failed: %v", err)
+	}
+	ts, _ := time.Parse(time.RFC3339, msg["timestamp"].(string))
+	if ts.Month() != time.January || ts.Day() != 2 || (ts.Year() != year && ts.Year() != year-1) {
+		t.Errorf("timestamp = %v, expected January 2 of this or last year", msg["timestamp"])
+	}
+}
+
+func TestParseSyslogMessageErrors(t *testing.T) {
+	tests := []struct {
+		data   string
+		format string
+		err    string
+	}{
+		{"no priority", SyslogFormatAuto, "missing priority"},
+		{"<>1 - - - - - -", SyslogFormatAuto, "invalid priority"},
+		{"<192>hello", SyslogFormatAuto, "invalid priority"},
+		{"<abc>hello", SyslogFormatAuto, "invalid priority"},
+		{"<13>hello", SyslogFormatRFC5424, "not an RFC 5424 message"},
+		{"<13>1 - host app", SyslogFormatAuto, "truncated RFC 5424 header"},
+		{"<13>1 - host app - - [id x=1] msg", SyslogFormatAuto, "invalid structured data param"},
+		{`<13>1 - host app - - [id x="1`, SyslogFormatAuto, "unterminated structured data value"},
+		{"<13>1 - host app - - [id", SyslogFormatAuto, "unterminated structured data"},
+		{"<13>1 - host app - - x", SyslogFormatAuto, "invalid structured data"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseSyslogMessage([]byte(test.data), test.format)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseSyslogMessage(%q, %s) = %v, expected %q", test.data, test.format, err, test.err)
+		}
+	}
+}
+
+func expectSyslogMessages(t *testing.T, msgChan chan map[string]interface{}, messages ...string) {
+	t.Helper()
+	for _, expected := range messages {
+		select {
+		case m := <-msgChan:
+			if m["message"] != expected {
+				t.Fatalf("received %q, expected %q", m["message"], expected)
+			}
+			if m["source_ip"] != "127.0.0.1" {
+				t.Errorf("source_ip = %v, expected 127.0.0.1", m["source_ip"])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no message received, expected %q", expected)
+		}
+	}
+}
+
+func TestSyslogServerUDP(t *testing.T) {
+	msgChan := make(chan map[string]interface{}, 10)
+	s, err := NewSyslogServer(SyslogProtocolUDP, "127.0.0.1:0", SyslogFormatAuto, 0, 0, nil, msgChan)
+	if err != nil {
+		t.Fatalf("NewSyslogServer failed: %v", err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("udp", s.packetConn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("<13>Oct  1 22:14:15 host app: first\n"))
+	conn.Write([]byte("not syslog"))
+	expectSyslogMessages(t, msgChan, "first", "not syslog")
+}
+
+func TestSyslogServerTCPFraming(t *testing.T) {
+	msgChan := make(chan map[string]interface{}, 10)
+	s, err := NewSyslogServer(SyslogProtocolTCP, "127.0.0.1:0", SyslogFormatAuto, 0, 32, nil, msgChan)
+	if err != nil {
+		t.Fatalf("NewSyslogServer failed: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	octet := "<13>1 - h a - - - octet\nline"
+	fmt.Fprintf(conn, "%d %s", len(octet), octet)
+	fmt.Fprint(conn, "<13>app: newline\r\n")
+	fmt.Fprint(conn, "<13>"+strings.Repeat("x", 40)+"\n")
+	fmt.Fprint(conn, "<13>app: after\n")
+	expectSyslogMessages(t, msgChan, "octet\nline", "newline", strings.Repeat("x", 28), "after")
+
+	// Frames longer than max_message_size close the connection
+	fmt.Fprint(conn, "100 <13>too long")
+	deadline := time.Now().Add(5 * time.Second)
+	for s.ActiveConnections() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := s.ActiveConnections(); n != 0 {
+		t.Errorf("%d connections open after an oversized frame, expected 0", n)
+	}
+
+	s.Close()
+	if _, ok := <-msgChan; ok {
+		t.Error("message channel is open after Close")
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		frame string
+		err   string
+	}{
+		{"octet counted", "5 hello6 world!", "hello", ""},
+		{"longest length prefix", "1000000000 abc", "", "exceeds max_message_size"},
+		{"length prefix too long", "12345678901 abc", "", "invalid octet count"},
+		{"unbounded length prefix", strings.Repeat("9", 1<<20), "", "invalid octet count"},
+		{"not a number", "12a hello", "", "invalid octet count"},
+		{"exceeds max_message_size", "100 hello", "", "exceeds max_message_size"},
+		{"line", "<13>app: line\nnext", "<13>app: line", ""},
+	}
+
+	s := &SyslogServer{MaxMessageSize: 32}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frame, err := s.readFrame(bufio.NewReader(strings.NewReader(test.input)))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("readFrame(%.20q) error = %v, expected %q", test.input, err, test.err)
+				}
+				return
+			}
+			if err != nil || string(frame) != test.frame {
+				t.Errorf("readFrame(%.20q) = %q, %v, expected %q", test.input, frame, err, test.frame)
+			}
+		})
+	}
+}
+
+func TestNewSyslogServerErrors(t *testing.T) {
+	tests := []struct {
+		protocol string
+		format   string
+		tls      *SyslogTLSConfig
+		err      string
+	}{
+		{"sctp", "", nil, "invalid protocol value"},
+		{SyslogProtocolUDP, "json", nil, "invalid format value"},
+		{SyslogProtocolTLS, "", nil, "requires cert_path and key_path"},
+	}
+
+	for _, test := range tests {
+		_, err := NewSyslogServer(test.protocol, "127.0.0.1:0", test.format, 0, 0, test.tls, make(chan map[string]interface{}))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("NewSyslogServer(%s, %q) = %v, expected %q", test.protocol, test.format, err, test.err)
+		}
+	}
+}
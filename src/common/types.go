@@ -23,17 +23,123 @@ type CheckCoreCache struct {
 }
 
 type HubConfig struct {
-	Redis         string `yaml:"redis"`
-	RedisPassword string `yaml:"redis_password,omitempty"`
-	PprofEnable   bool   `yaml:"pprof_enable"`
-	PprofPort     string `yaml:"pprof_port"`
-	SIMDEnabled   bool   `yaml:"simd_enabled"`
+	Redis         string                  `yaml:"redis"`
+	RedisPassword string                  `yaml:"redis_password,omitempty"`
+	PprofEnable   bool                    `yaml:"pprof_enable"`
+	PprofPort     string                  `yaml:"pprof_port"`
+	SIMDEnabled   bool                    `yaml:"simd_enabled"`
+	OIDC          OIDCConfig              `yaml:"oidc,omitempty"`
+	Plugins       map[string]PluginConfig `yaml:"plugins,omitempty"` // Keyed by plugin name, "default" applies to all plugins
+	ThreatIntel   ThreatIntelConfig       `yaml:"threat_intel,omitempty"`
+	GeoIP         GeoIPConfig             `yaml:"geoip,omitempty"`
+	Tracing       TracingConfig           `yaml:"tracing,omitempty"`
+	Cluster       ClusterConfig           `yaml:"cluster,omitempty"`
+	GitOps        GitOpsConfig            `yaml:"gitops,omitempty"`
+	DataDir       string                  `yaml:"data_dir,omitempty"` // Node-local state such as read offsets, see GetDataDir
 	ConfigRoot    string
-	Leader        string
 	LocalIP       string
 	Token         string
 }
 
+// PluginConfig holds runtime limits and settings of a plugin, zero values inherit from the "default" entry
+type PluginConfig struct {
+	Timeout          string      `yaml:"timeout,omitempty"`           // Max duration of one call, e.g. 500ms, "0" disables
+	MaxConcurrency   int         `yaml:"max_concurrency,omitempty"`   // Max calls in flight, timed out calls keep their slot until they return
+	FailureThreshold int         `yaml:"failure_threshold,omitempty"` // Consecutive failures/timeouts that open the circuit breaker
+	ResetTimeout     string      `yaml:"reset_timeout,omitempty"`     // How long the circuit stays open before a trial call
+	Fallback         interface{} `yaml:"fallback,omitempty"`          // Returned instead of an error on timeout, overload or open circuit
+
+	Config map[string]interface{} `yaml:"config,omitempty"` // Passed to the plugin Init function
+}
+
+// ThreatIntelConfig lists the threat intelligence feeds pulled by this node
+type ThreatIntelConfig struct {
+	Feeds []ThreatFeedConfig `yaml:"feeds,omitempty"`
+}
+
+// ThreatFeedConfig describes one threat intelligence feed
+type ThreatFeedConfig struct {
+	Name          string                 `yaml:"name"`
+	Type          string                 `yaml:"type"`                     // taxii, stix, misp or list
+	URL           string                 `yaml:"url"`                      // TAXII API root, STIX bundle, MISP export/restSearch or list URL
+	Collection    string                 `yaml:"collection,omitempty"`     // TAXII collection id
+	Interval      string                 `yaml:"interval,omitempty"`       // Pull interval, default: 1h
+	Timeout       string                 `yaml:"timeout,omitempty"`        // HTTP timeout of one request, default: 60s
+	TTL           string                 `yaml:"ttl,omitempty"`            // Expiry of indicators without their own, counted from the pull, default: 7d
+	Confidence    int                    `yaml:"confidence,omitempty"`     // Confidence (0-100) of indicators without their own, default: 50
+	MinConfidence int                    `yaml:"min_confidence,omitempty"` // Indicators below are dropped
+	Tags          []string               `yaml:"tags,omitempty"`           // Added to every indicator of the feed
+	Username      string                 `yaml:"username,omitempty"`       // HTTP basic auth
+	Password      string                 `yaml:"password,omitempty"`
+	APIKey        string                 `yaml:"api_key,omitempty"` // MISP auth key
+	Headers       map[string]string      `yaml:"headers,omitempty"`
+	Filters       map[string]interface{} `yaml:"filters,omitempty"`  // MISP restSearch filters, e.g. last: 30d
+	Format        string                 `yaml:"format,omitempty"`   // list: text (default) or csv
+	IOCType       string                 `yaml:"ioc_type,omitempty"` // list: ip, domain, url or hash, detected per value when empty
+	Column        string                 `yaml:"column,omitempty"`   // list csv: value column of the header, default: first column
+	TLSSkipVerify bool                   `yaml:"tls_skip_verify,omitempty"`
+}
+
+// GeoIPConfig configures the MaxMind databases of the geoInfo and geoMatch plugins
+type GeoIPConfig struct {
+	City          string `yaml:"city,omitempty"`           // City or Country MMDB file
+	ASN           string `yaml:"asn,omitempty"`            // ASN MMDB file
+	CheckInterval string `yaml:"check_interval,omitempty"` // How often files and the leader copy are checked, default: 1m
+	LocalOnly     bool   `yaml:"local_only,omitempty"`     // Do not share the leader databases with followers through Redis
+}
+
+// TracingConfig configures OpenTelemetry tracing of messages, exported over OTLP/HTTP
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint,omitempty"`     // Collector URL, default: http://localhost:4318
+	SampleRatio float64           `yaml:"sample_ratio,omitempty"` // Share of messages traced, default: 0.01, negative: only messages with a sampled parent
+	ServiceName string            `yaml:"service_name,omitempty"` // Default: agentsmith-hub
+	Headers     map[string]string `yaml:"headers,omitempty"`      // Sent with every export, e.g. collector auth
+}
+
+// ClusterConfig configures leader election between the nodes sharing the Redis
+type ClusterConfig struct {
+	Failover     bool   `yaml:"failover"`                // Followers take over when the leader lock expires, set on every node
+	LeaseTTL     string `yaml:"lease_ttl,omitempty"`     // Expiry of the leader lock, i.e. how long a dead leader goes unnoticed, default: 1m
+	AdvertiseAPI string `yaml:"advertise_api,omitempty"` // API URL the other nodes forward writes to, default: http://<local ip>:<api port>
+}
+
+// GitOpsConfig syncs the components of the leader from a git repository
+type GitOpsConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Repo          string `yaml:"repo"`                     // Clone URL or path of the repository, e.g. a local bare repository
+	Branch        string `yaml:"branch,omitempty"`         // Default: main
+	Path          string `yaml:"path,omitempty"`           // Directory of the component folders (input, ruleset, ...) in the repository, default: root
+	Interval      string `yaml:"interval,omitempty"`       // Pull interval, default: 1m, "0" pulls on webhook and API calls only
+	WorkDir       string `yaml:"work_dir,omitempty"`       // Local checkout of the repository, default: ./gitops
+	Prune         bool   `yaml:"prune,omitempty"`          // Delete components that are not in the repository
+	WebhookSecret string `yaml:"webhook_secret,omitempty"` // Secret of POST /gitops/webhook, the webhook is disabled without it
+	CommitBack    bool   `yaml:"commit_back,omitempty"`    // Commit and push the changes applied through the API and UI
+	AuthorDomain  string `yaml:"author_domain,omitempty"`  // Email domain of commit authors whose user name is no email, default: agentsmith-hub.local
+}
+
+// Trace context of a traced message, W3C traceparent and tracestate
+const (
+	TraceParentField = "_hub_traceparent"
+	TraceStateField  = "_hub_tracestate"
+)
+
+// OIDCConfig configures OpenID Connect single sign-on for the web UI and API
+type OIDCConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Issuer        string            `yaml:"issuer"`
+	ClientID      string            `yaml:"client_id"`
+	ClientSecret  string            `yaml:"client_secret,omitempty"`
+	RedirectURL   string            `yaml:"redirect_url"`             // Must point to /auth/oidc/callback of this hub
+	Scopes        []string          `yaml:"scopes,omitempty"`         // Default: openid, profile, email, groups
+	UsernameClaim string            `yaml:"username_claim,omitempty"` // Default: preferred_username, falls back to email and sub
+	GroupsClaim   string            `yaml:"groups_claim,omitempty"`   // Default: groups
+	RoleMapping   map[string]string `yaml:"role_mapping,omitempty"`   // IdP group -> hub role, the highest matched role wins
+	DefaultRole   string            `yaml:"default_role,omitempty"`   // Role for users without a mapped group, empty denies login
+	SessionTTL    string            `yaml:"session_ttl,omitempty"`    // Default: 12h
+	UIRedirect    string            `yaml:"ui_redirect,omitempty"`    // Where the browser lands after login, default: /
+}
+
 // Operation types for project operations
 type OperationType string
 
@@ -63,6 +169,7 @@ type OperationRecord struct {
 	Status        string                 `json:"status"`
 	Error         string                 `json:"error,omitempty"`
 	Details       map[string]interface{} `json:"details,omitempty"`
+	Operator      string                 `json:"operator,omitempty"` // User who triggered the operation, empty for system operations
 }
 
 // Project state Redis keys - IMPORTANT: Separate expected vs actual states
@@ -359,6 +359,18 @@ func GetConfigDir() string {
 	return "/etc/hub" // /etc/hub for Linux
 }
 
+// GetDataDir returns the directory holding node-local state such as file input offsets. It is kept
+// out of the config root, whose content is synced between nodes and replaced on leader changes.
+func GetDataDir() string {
+	if Config != nil && Config.DataDir != "" {
+		return Config.DataDir
+	}
+	if runtime.GOOS == "darwin" {
+		return "data" // Relative to the current directory for macOS
+	}
+	return "/var/lib/hub"
+}
+
 // GetConfigPath returns the full path for a specific config file
 // It ensures the directory exists and creates it if necessary
 func GetConfigPath(filename string) string {
@@ -0,0 +1,297 @@
+package common
+
+import (
+	"AgentSmith-HUB/logger"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+const maxWebhookBackoff = 30 * time.Second
+
+// WebhookTemplateFuncs are available in webhook body templates
+var WebhookTemplateFuncs = template.FuncMap{
+	// json renders any value as JSON, strings are quoted and escaped
+	"json": func(v interface{}) (string, error) {
+		b, err := sonic.Marshal(v)
+		return string(b), err
+	},
+	// default returns def when v is empty
+	"default": func(def interface{}, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+}
+
+// ParseWebhookTemplate parses a body template with the webhook helper functions
+func ParseWebhookTemplate(body string) (*template.Template, error) {
+	return template.New("webhook").Funcs(WebhookTemplateFuncs).Option("missingkey=zero").Parse(body)
+}
+
+// WebhookProducer delivers messages to an HTTP endpoint.
+// Messages are batched, rendered through an optional template, rate limited and retried with
+// exponential backoff. Deliveries that still fail are handed to DeadLetter if set.
+type WebhookProducer struct {
+	URL         string
+	Method      string
+	Headers     map[string]string
+	Template    *template.Template
+	BatchSize   int
+	FlushDur    time.Duration
+	MaxRetries  int
+	Backoff     time.Duration
+	RateLimit   float64 // requests per second, 0 means unlimited
+	DeadLetter  DeadLetterFunc
+	MsgChan     chan map[string]interface{}
+	client      *http.Client
+	nextRequest time.Time
+	stopChan    chan struct{}
+	doneChan    chan struct{}
+}
+
+// NewWebhookProducer creates a webhook producer and starts delivering from msgChan
+func NewWebhookProducer(url, method string, headers map[string]string, bodyTemplate string, batchSize int, flushDur, timeout time.Duration, maxRetries int, backoff time.Duration, rateLimit float64, skipVerify bool, deadLetter DeadLetterFunc, msgChan chan map[string]interface{}) (*WebhookProducer, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	if method == "" {
+		method = http.MethodPost
+	}
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if flushDur <= 0 {
+		flushDur = time.Second
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	var tmpl *template.Template
+	if bodyTemplate != "" {
+		var err error
+		tmpl, err = ParseWebhookTemplate(bodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse body template: %w", err)
+		}
+	}
+
+	p := &WebhookProducer{
+		URL:        url,
+		Method:     strings.ToUpper(method),
+		Headers:    headers,
+		Template:   tmpl,
+		BatchSize:  batchSize,
+		FlushDur:   flushDur,
+		MaxRetries: maxRetries,
+		Backoff:    backoff,
+		RateLimit:  rateLimit,
+		DeadLetter: deadLetter,
+		MsgChan:    msgChan,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+			},
+		},
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+
+	go p.run()
+	return p, nil
+}
+
+func (p *WebhookProducer) run() {
+	defer close(p.doneChan)
+
+	batch := make([]map[string]interface{}, 0, p.BatchSize)
+	ticker := time.NewTicker(p.FlushDur)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-p.MsgChan:
+			if !ok {
+				// Channel is closed by the owner, deliver what is left
+				if len(batch) > 0 {
+					p.deliver(batch)
+				}
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) >= p.BatchSize {
+				p.deliver(batch)
+				batch = make([]map[string]interface{}, 0, p.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.deliver(batch)
+				batch = make([]map[string]interface{}, 0, p.BatchSize)
+			}
+		case <-p.stopChan:
+			// Aborted by Close, the current batch gets a single attempt
+			if len(batch) > 0 {
+				p.deliver(batch)
+			}
+			return
+		}
+	}
+}
+
+// render builds the request body: the template receives the message itself when batch_size is 1,
+// otherwise the list of messages. Without a template the message or list is sent as JSON.
+func (p *WebhookProducer) render(batch []map[string]interface{}) ([]byte, error) {
+	var data interface{} = batch
+	if p.BatchSize == 1 && len(batch) == 1 {
+		data = batch[0]
+	}
+
+	if p.Template == nil {
+		return sonic.Marshal(data)
+	}
+
+	var buf bytes.Buffer
+	if err := p.Template.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver sends one batch with retries, failed batches go to the dead letter queue
+func (p *WebhookProducer) deliver(batch []map[string]interface{}) {
+	body, err := p.render(batch)
+	if err != nil {
+		logger.Error("[WebhookProducer] failed to render body", "url", p.URL, "error", err)
+		p.deadLetter(batch, fmt.Errorf("render body: %w", err))
+		return
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := p.Backoff << (attempt - 1)
+			if delay > maxWebhookBackoff || delay <= 0 {
+				delay = maxWebhookBackoff
+			}
+			select {
+			case <-time.After(delay):
+			case <-p.stopChan:
+				// Shutting down, do not keep retrying
+				p.deadLetter(batch, fmt.Errorf("shutdown before delivery: %w", lastErr))
+				return
+			}
+		}
+
+		p.waitRateLimit()
+
+		retryable, err := p.send(body)
+		if err == nil {
+			return
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+		logger.Warn("[WebhookProducer] delivery failed, retrying", "url", p.URL, "attempt", attempt+1, "error", err)
+	}
+
+	logger.Error("[WebhookProducer] delivery failed", "url", p.URL, "count", len(batch), "error", lastErr)
+	p.deadLetter(batch, lastErr)
+}
+
+// send performs a single request, 429 and 5xx responses are retryable
+func (p *WebhookProducer) send(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), p.Method, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// waitRateLimit spaces requests evenly according to RateLimit
+func (p *WebhookProducer) waitRateLimit() {
+	if p.RateLimit <= 0 {
+		return
+	}
+	now := time.Now()
+	if wait := p.nextRequest.Sub(now); wait > 0 {
+		time.Sleep(wait)
+		now = p.nextRequest
+	}
+	p.nextRequest = now.Add(time.Duration(float64(time.Second) / p.RateLimit))
+}
+
+func (p *WebhookProducer) deadLetter(batch []map[string]interface{}, err error) {
+	if p.DeadLetter != nil {
+		p.DeadLetter(batch, err)
+	}
+}
+
+// Close waits for the remaining messages to be delivered once MsgChan is closed by the caller.
+// Deliveries still retrying after the grace period are aborted and go to the dead letter queue.
+func (p *WebhookProducer) Close() {
+	select {
+	case <-p.doneChan:
+		return
+	case <-time.After(10 * time.Second):
+		logger.Warn("[WebhookProducer] timeout waiting for pending deliveries, aborting retries", "url", p.URL)
+	}
+	close(p.stopChan)
+	<-p.doneChan
+}
+
+// TestWebhookConnection checks that the webhook host is reachable without sending a payload
+func TestWebhookConnection(url string, skipVerify bool) error {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+		},
+	}
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWebhookServer answers with the given statuses in turn, then 200, and records request bodies
+type testWebhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func newTestWebhookServer(t *testing.T, statuses ...int) *testWebhookServer {
+	s := &testWebhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		s.headers = append(s.headers, r.Header.Clone())
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testWebhookServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+type deadLetters struct {
+	mu    sync.Mutex
+	msgs  []map[string]interface{}
+	cause error
+}
+
+func (d *deadLetters) write(msgs []map[string]interface{}, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.msgs = append(d.msgs, msgs...)
+	d.cause = err
+}
+
+// deliverAll sends messages through a webhook producer and waits for all deliveries
+func deliverAll(t *testing.T, url, tmpl string, batchSize, maxRetries int, dl *deadLetters, msgs ...map[string]interface{}) {
+	t.Helper()
+	msgChan := make(chan map[string]interface{}, len(msgs))
+	p, err := NewWebhookProducer(url, "", map[string]string{"X-Token": "t"}, tmpl, batchSize, time.Hour, time.Second, maxRetries, time.Millisecond, 0, false, dl.write, msgChan)
+	if err != nil {
+		t.Fatalf("NewWebhookProducer failed: %v", err)
+	}
+	for _, m := range msgs {
+		msgChan <- m
+	}
+	close(msgChan)
+	p.Close()
+}
+
+func TestWebhookProducerRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		tmpl      string
+		batchSize int
+		expected  []string
+	}{
+		{"json", "", 1, []string{`{"a":"x"}`, `{"a":"y\""}`}},
+		{"json batch", "", 2, []string{`[{"a":"x"},{"a":"y\""}]`}},
+		{"template", `{"text": {{json .a}}, "b": {{json (default "none" .b)}}}`, 1, []string{`{"text": "x", "b": "none"}`, `{"text": "y\"", "b": "none"}`}},
+		{"template batch", `{{range .}}{{.a}};{{end}}`, 5, []string{`x;y";`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestWebhookServer(t)
+			dl := &deadLetters{}
+			deliverAll(t, s.URL, test.tmpl, test.batchSize, 0, dl, map[string]interface{}{"a": "x"}, map[string]interface{}{"a": `y"`})
+
+			if got := s.requests(); strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("bodies = %q, expected %q", got, test.expected)
+			}
+			if s.headers[0].Get("X-Token") != "t" || s.headers[0].Get("Content-Type") != "application/json" {
+				t.Errorf("headers = %v", s.headers[0])
+			}
+			if len(dl.msgs) != 0 {
+				t.Errorf("%d messages in the dead letter queue", len(dl.msgs))
+			}
+		})
+	}
+}
+
+func TestWebhookProducerRetry(t *testing.T) {
+	msg := map[string]interface{}{"a": "x"}
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		requests   int
+		dead       string
+	}{
+		{"success", nil, 3, 1, ""},
+		{"retried 5xx and 429", []int{500, 503, 429}, 3, 4, ""},
+		{"retries exhausted", []int{500, 500, 500}, 2, 3, "unexpected status 500"},
+		{"client error not retried", []int{400}, 3, 1, "unexpected status 400"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestWebhookServer(t, test.statuses...)
+			dl := &deadLetters{}
+			deliverAll(t, s.URL, "", 1, test.maxRetries, dl, msg)
+
+			if n := len(s.requests()); n != test.requests {
+				t.Errorf("%d requests, expected %d", n, test.requests)
+			}
+			if test.dead == "" {
+				if len(dl.msgs) != 0 {
+					t.Errorf("dead letters = %v, expected none", dl.msgs)
+				}
+				return
+			}
+			if len(dl.msgs) != 1 || dl.cause == nil || !strings.Contains(dl.cause.Error(), test.dead) {
+				t.Errorf("dead letters = %v with %v, expected the message with %q", dl.msgs, dl.cause, test.dead)
+			}
+		})
+	}
+
+	// Unreachable endpoints are retried too
+	dl := &deadLetters{}
+	s := newTestWebhookServer(t)
+	url := s.URL
+	s.Close()
+	deliverAll(t, url, "", 1, 1, dl, msg)
+	if len(dl.msgs) != 1 {
+		t.Errorf("dead letters = %v, expected the undeliverable message", dl.msgs)
+	}
+
+	// A template error is not retried
+	dl = &deadLetters{}
+	deliverAll(t, url, `{{.a.b}}`, 1, 3, dl, msg)
+	if len(dl.msgs) != 1 || !strings.Contains(dl.cause.Error(), "render body") {
+		t.Errorf("dead letters = %v with %v, expected a render error", dl.msgs, dl.cause)
+	}
+}
+
+func TestWebhookProducerRateLimit(t *testing.T) {
+	s := newTestWebhookServer(t)
+	msgChan := make(chan map[string]interface{}, 3)
+	p, err := NewWebhookProducer(s.URL, "PUT", nil, "", 1, time.Hour, time.Second, 0, time.Millisecond, 20, false, nil, msgChan)
+	if err != nil {
+		t.Fatalf("NewWebhookProducer failed: %v", err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		msgChan <- map[string]interface{}{"i": i}
+	}
+	close(msgChan)
+	p.Close()
+
+	// 20 requests per second, the third request waits two intervals
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests took %v, expected at least 100ms", elapsed)
+	}
+	if n := len(s.requests()); n != 3 {
+		t.Errorf("%d requests, expected 3", n)
+	}
+}
+
+func TestNewWebhookProducerErrors(t *testing.T) {
+	if _, err := NewWebhookProducer("", "", nil, "", 1, 0, 0, 0, 0, 0, false, nil, nil); err == nil {
+		t.Error("producer without url created")
+	}
+	_, err := NewWebhookProducer("http://localhost", "", nil, "{{.a", 1, 0, 0, 0, 0, 0, false, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse body template") {
+		t.Errorf("NewWebhookProducer() with an invalid template = %v", err)
+	}
+}
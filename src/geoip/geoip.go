@@ -0,0 +1,355 @@
+package geoip
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP and ASN context from local MaxMind (MMDB) databases for the geoInfo and geoMatch plugins.
+// config.yaml:
+//
+//	geoip:
+//	  city: /data/GeoLite2-City.mmdb    # City or Country database
+//	  asn: /data/GeoLite2-ASN.mmdb
+//	  check_interval: 1m                # how often files and the leader copy are checked
+//	  local_only: false                 # true: databases are not shared through the cluster
+//
+// A database is reloaded when its file changes. The leader shares its databases with followers
+// through Redis (see sync.go), followers write them to their own configured path, or to
+// <config_root>/geoip/<name>.mmdb, and load them.
+
+// Database names
+const (
+	DBCity = "city"
+	DBASN  = "asn"
+)
+
+const defaultCheckInterval = time.Minute
+
+// Status describes a database on this node
+type Status struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Loaded   bool   `json:"loaded"`
+	Type     string `json:"database_type,omitempty"`
+	Build    string `json:"build_time,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	LoadedAt string `json:"loaded_at,omitempty"`
+	Source   string `json:"source,omitempty"` // file or leader
+	Error    string `json:"error,omitempty"`
+}
+
+type database struct {
+	name string
+	path string
+
+	reader atomic.Pointer[geoip2.Reader]
+
+	mu       sync.Mutex
+	sha      string
+	size     int64
+	loadedAt time.Time
+	source   string
+	err      string
+
+	// Last checked file, a file that fails to load is only retried once it changes again
+	fileModTime time.Time
+	fileSize    int64
+}
+
+var (
+	dbCity = &database{name: DBCity}
+	dbASN  = &database{name: DBASN}
+
+	localOnly bool
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+)
+
+// Start loads the configured databases and keeps checking them for changes
+func Start(cfg common.GeoIPConfig) error {
+	Stop()
+
+	interval := defaultCheckInterval
+	if cfg.CheckInterval != "" {
+		sec, err := common.ParseDurationToSecondsInt(cfg.CheckInterval)
+		if err != nil {
+			return fmt.Errorf("invalid geoip check_interval: %w", err)
+		}
+		interval = time.Duration(sec) * time.Second
+	}
+	localOnly = cfg.LocalOnly
+	dbCity.setPath(cfg.City)
+	dbASN.setPath(cfg.ASN)
+
+	check()
+	stopCh = make(chan struct{})
+	wg.Add(1)
+	go func(stop chan struct{}) {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	}(stopCh)
+	return nil
+}
+
+// Stop stops checking the databases, the loaded databases stay usable
+func Stop() {
+	if stopCh != nil {
+		close(stopCh)
+		wg.Wait()
+		stopCh = nil
+	}
+}
+
+// setPath sets the file of a database, followers without a configured path store the leader copy under config_root
+func (d *database) setPath(path string) {
+	if path == "" && common.Config != nil && common.Config.ConfigRoot != "" {
+		path = filepath.Join(common.Config.ConfigRoot, "geoip", d.name+".mmdb")
+	}
+	d.mu.Lock()
+	d.path = path
+	d.mu.Unlock()
+}
+
+func check() {
+	leader := common.IsCurrentNodeLeader()
+	for _, d := range []*database{dbCity, dbASN} {
+		d.checkFile()
+		if localOnly {
+			continue
+		}
+		if leader {
+			d.publish()
+		} else {
+			d.syncFromLeader()
+		}
+	}
+}
+
+// checkFile loads the database file when its size or modification time changed
+func (d *database) checkFile() {
+	d.mu.Lock()
+	path, modTime, size := d.path, d.fileModTime, d.fileSize
+	d.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.setErr(err)
+		}
+		return
+	}
+	if info.ModTime().Equal(modTime) && info.Size() == size {
+		return
+	}
+	d.setFileInfo(info)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		d.setErr(err)
+		return
+	}
+	if err := d.load(data, "file"); err != nil {
+		logger.Error("Failed to load geoip database", "database", d.name, "path", path, "error", err)
+	}
+}
+
+// load swaps in a new reader, lookups in flight keep using the old one
+func (d *database) load(data []byte, source string) error {
+	sum := sha256.Sum256(data)
+	sha := hex.EncodeToString(sum[:])
+
+	d.mu.Lock()
+	unchanged := sha == d.sha && d.reader.Load() != nil
+	d.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	reader, err := geoip2.FromBytes(data)
+	if err != nil {
+		d.setErr(err)
+		return err
+	}
+	if err := d.checkType(reader); err != nil {
+		d.setErr(err)
+		return err
+	}
+
+	d.reader.Store(reader)
+	d.mu.Lock()
+	d.sha = sha
+	d.size = int64(len(data))
+	d.loadedAt = time.Now()
+	d.source = source
+	d.err = ""
+	d.mu.Unlock()
+	logger.Info("GeoIP database loaded", "database", d.name, "type", reader.Metadata().DatabaseType, "source", source)
+	return nil
+}
+
+func (d *database) checkType(reader *geoip2.Reader) error {
+	ip := net.ParseIP("8.8.8.8")
+	var err error
+	if d.name == DBASN {
+		_, err = reader.ASN(ip)
+	} else {
+		_, err = reader.City(ip)
+	}
+	var invalid geoip2.InvalidMethodError
+	if errors.As(err, &invalid) {
+		return fmt.Errorf("%s is not a valid %s database", reader.Metadata().DatabaseType, d.name)
+	}
+	return nil
+}
+
+func (d *database) setFileInfo(info os.FileInfo) {
+	d.mu.Lock()
+	d.fileModTime = info.ModTime()
+	d.fileSize = info.Size()
+	d.mu.Unlock()
+}
+
+func (d *database) setErr(err error) {
+	d.mu.Lock()
+	d.err = err.Error()
+	d.mu.Unlock()
+}
+
+func (d *database) status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := Status{Name: d.name, Path: d.path, SHA256: d.sha, Size: d.size, Source: d.source, Error: d.err}
+	if r := d.reader.Load(); r != nil {
+		md := r.Metadata()
+		st.Loaded = true
+		st.Type = md.DatabaseType
+		st.Build = time.Unix(int64(md.BuildEpoch), 0).UTC().Format(time.RFC3339)
+		st.LoadedAt = d.loadedAt.UTC().Format(time.RFC3339)
+	}
+	return st
+}
+
+// Databases returns the status of the databases of this node
+func Databases() []Status {
+	return []Status{dbCity.status(), dbASN.status()}
+}
+
+// Loaded reports whether the city (or country) database is loaded
+func Loaded() bool {
+	return dbCity.reader.Load() != nil
+}
+
+// CountryISO returns the ISO country code of an IP
+func CountryISO(ipStr string) (string, error) {
+	reader := dbCity.reader.Load()
+	if reader == nil {
+		return "", errors.New("geoip city database not loaded")
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return "", nil
+	}
+	rec, err := reader.Country(ip)
+	if err != nil {
+		return "", err
+	}
+	return rec.Country.IsoCode, nil
+}
+
+// Lookup returns the geo and ASN context of an IP, ok is false if no database has data for it.
+// Names use the given language (e.g. en, de, zh-CN) and fall back to English.
+func Lookup(ipStr string, lang string) (map[string]interface{}, bool, error) {
+	cityReader := dbCity.reader.Load()
+	asnReader := dbASN.reader.Load()
+	if cityReader == nil && asnReader == nil {
+		return nil, false, errors.New("no geoip database loaded")
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, false, nil
+	}
+	if lang == "" {
+		lang = "en"
+	}
+
+	res := make(map[string]interface{})
+	if cityReader != nil {
+		rec, err := cityReader.City(ip)
+		if err != nil {
+			return nil, false, err
+		}
+		if rec.Country.IsoCode != "" {
+			res["country_code"] = rec.Country.IsoCode
+			setName(res, "country", rec.Country.Names, lang)
+			res["is_in_eu"] = rec.Country.IsInEuropeanUnion
+		}
+		if rec.Continent.Code != "" {
+			res["continent_code"] = rec.Continent.Code
+			setName(res, "continent", rec.Continent.Names, lang)
+		}
+		if len(rec.Subdivisions) > 0 {
+			res["region_code"] = rec.Subdivisions[0].IsoCode
+			setName(res, "region", rec.Subdivisions[0].Names, lang)
+		}
+		setName(res, "city", rec.City.Names, lang)
+		if rec.Postal.Code != "" {
+			res["postal_code"] = rec.Postal.Code
+		}
+		if rec.Location.Latitude != 0 || rec.Location.Longitude != 0 {
+			res["latitude"] = rec.Location.Latitude
+			res["longitude"] = rec.Location.Longitude
+			res["location"] = fmt.Sprintf("%g,%g", rec.Location.Latitude, rec.Location.Longitude)
+			if rec.Location.AccuracyRadius > 0 {
+				res["accuracy_radius"] = int(rec.Location.AccuracyRadius)
+			}
+		}
+		if rec.Location.TimeZone != "" {
+			res["timezone"] = rec.Location.TimeZone
+		}
+	}
+	if asnReader != nil {
+		rec, err := asnReader.ASN(ip)
+		if err != nil {
+			return nil, false, err
+		}
+		if rec.AutonomousSystemNumber != 0 {
+			res["asn"] = int(rec.AutonomousSystemNumber)
+			res["as_org"] = rec.AutonomousSystemOrganization
+		}
+	}
+	if len(res) == 0 {
+		return nil, false, nil
+	}
+	res["ip"] = ipStr
+	return res, true, nil
+}
+
+func setName(res map[string]interface{}, key string, names map[string]string, lang string) {
+	if name := names[lang]; name != "" {
+		res[key] = name
+	} else if name := names["en"]; name != "" {
+		res[key] = name
+	}
+}
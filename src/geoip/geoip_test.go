@@ -0,0 +1,323 @@
+package geoip
+
+import (
+	"AgentSmith-HUB/common"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNetwork is a network of a test database and its record
+type testNetwork struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// buildTestMMDB encodes an IPv4 MaxMind DB with 24 bit records, see the MaxMind DB format specification
+func buildTestMMDB(t *testing.T, dbType string, networks ...testNetwork) []byte {
+	t.Helper()
+
+	// Records are node indexes, or ^data index for a record pointing at data, 0 children are empty
+	type node struct{ records [2]int }
+	nodes := []*node{{}}
+	var data bytes.Buffer
+	var dataOffsets []int
+	for _, n := range networks {
+		prefix := netip.MustParsePrefix(n.cidr)
+		if !prefix.Addr().Is4() || prefix.Bits() == 0 {
+			t.Fatalf("test network %s must be an IPv4 network", n.cidr)
+		}
+		dataOffsets = append(dataOffsets, data.Len())
+		encodeTestMMDBValue(t, &data, n.record)
+
+		ip := prefix.Addr().As4()
+		current := 0
+		for i := 0; i < prefix.Bits(); i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == prefix.Bits()-1 {
+				nodes[current].records[bit] = ^(len(dataOffsets) - 1)
+				break
+			}
+			if nodes[current].records[bit] <= 0 {
+				nodes = append(nodes, &node{})
+				nodes[current].records[bit] = len(nodes) - 1
+			}
+			current = nodes[current].records[bit]
+		}
+	}
+
+	var db bytes.Buffer
+	nodeCount := len(nodes)
+	for _, n := range nodes {
+		for _, r := range n.records {
+			value := nodeCount
+			switch {
+			case r > 0:
+				value = r
+			case r < 0:
+				value = nodeCount + 16 + dataOffsets[^r]
+			}
+			db.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encodeTestMMDBValue(t, &db, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{"en", "de"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+	return db.Bytes()
+}
+
+func encodeTestMMDBValue(t *testing.T, buf *bytes.Buffer, v interface{}) {
+	t.Helper()
+	switch v := v.(type) {
+	case string:
+		writeTestMMDBControl(t, buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		writeTestMMDBControl(t, buf, 3, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		writeTestMMDBUint(t, buf, 5, uint64(v))
+	case uint32:
+		writeTestMMDBUint(t, buf, 6, uint64(v))
+	case uint64:
+		writeTestMMDBUint(t, buf, 9, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeTestMMDBControl(t, buf, 14, size)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeTestMMDBControl(t, buf, 7, len(v))
+		for _, k := range keys {
+			encodeTestMMDBValue(t, buf, k)
+			encodeTestMMDBValue(t, buf, v[k])
+		}
+	case []interface{}:
+		writeTestMMDBControl(t, buf, 11, len(v))
+		for _, item := range v {
+			encodeTestMMDBValue(t, buf, item)
+		}
+	default:
+		t.Fatalf("cannot encode %T in a test database", v)
+	}
+}
+
+func writeTestMMDBUint(t *testing.T, buf *bytes.Buffer, dataType int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	trimmed := bytes.TrimLeft(b[:], "\x00")
+	writeTestMMDBControl(t, buf, dataType, len(trimmed))
+	buf.Write(trimmed)
+}
+
+// writeTestMMDBControl writes the control byte of a value, types above 7 are extended types
+func writeTestMMDBControl(t *testing.T, buf *bytes.Buffer, dataType, size int) {
+	t.Helper()
+	var extra []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		extra, size = []byte{byte(size - 29)}, 29
+	default:
+		t.Fatalf("value of size %d is too large for a test database", size)
+	}
+	if dataType > 7 {
+		buf.WriteByte(byte(size))
+		buf.WriteByte(byte(dataType - 7))
+	} else {
+		buf.WriteByte(byte(dataType<<5 | size))
+	}
+	buf.Write(extra)
+}
+
+func cityNetwork(cidr, country string, names map[string]interface{}) testNetwork {
+	return testNetwork{cidr, map[string]interface{}{
+		"city":      map[string]interface{}{"names": names},
+		"continent": map[string]interface{}{"code": "EU", "names": map[string]interface{}{"en": "Europe", "de": "Europa"}},
+		"country": map[string]interface{}{
+			"iso_code":             country,
+			"is_in_european_union": true,
+			"names":                map[string]interface{}{"en": "Germany", "de": "Deutschland"},
+		},
+		"location": map[string]interface{}{
+			"accuracy_radius": uint16(20),
+			"latitude":        52.5,
+			"longitude":       13.25,
+			"time_zone":       "Europe/Berlin",
+		},
+		"postal":       map[string]interface{}{"code": "10115"},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "BE", "names": map[string]interface{}{"en": "Land Berlin"}}},
+	}}
+}
+
+func asnNetwork(cidr string, asn uint32, org string) testNetwork {
+	return testNetwork{cidr, map[string]interface{}{
+		"autonomous_system_number":       asn,
+		"autonomous_system_organization": org,
+	}}
+}
+
+// setupGeoIPTest resets the databases and points config_root at a temporary directory
+func setupGeoIPTest(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: root}
+	t.Cleanup(func() {
+		Stop()
+		common.Config = prevConfig
+		dbCity, dbASN, localOnly = &database{name: DBCity}, &database{name: DBASN}, false
+	})
+	dbCity, dbASN, localOnly = &database{name: DBCity}, &database{name: DBASN}, false
+	return root
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	// Make sure a rewrite within the same clock tick is seen as a change
+	modTime := time.Now().Add(time.Duration(len(data)) * time.Millisecond)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to touch %s: %v", path, err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	root := setupGeoIPTest(t)
+	if _, _, err := Lookup("81.2.69.160", ""); err == nil {
+		t.Errorf("Lookup() without databases succeeded")
+	}
+
+	cityPath, asnPath := filepath.Join(root, "city.mmdb"), filepath.Join(root, "asn.mmdb")
+	writeTestFile(t, cityPath, buildTestMMDB(t, "GeoLite2-City",
+		cityNetwork("81.2.69.0/24", "DE", map[string]interface{}{"en": "Berlin", "de": "Berlin-Mitte"})))
+	writeTestFile(t, asnPath, buildTestMMDB(t, "GeoLite2-ASN",
+		asnNetwork("81.2.0.0/16", 3320, "Deutsche Telekom AG"),
+		asnNetwork("1.128.0.0/11", 1221, "Telstra")))
+	if err := Start(common.GeoIPConfig{City: cityPath, ASN: asnPath, LocalOnly: true}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	full := map[string]interface{}{
+		"ip":              "81.2.69.160",
+		"country_code":    "DE",
+		"country":         "Germany",
+		"is_in_eu":        true,
+		"continent_code":  "EU",
+		"continent":       "Europe",
+		"region_code":     "BE",
+		"region":          "Land Berlin",
+		"city":            "Berlin",
+		"postal_code":     "10115",
+		"latitude":        52.5,
+		"longitude":       13.25,
+		"location":        "52.5,13.25",
+		"accuracy_radius": 20,
+		"timezone":        "Europe/Berlin",
+		"asn":             3320,
+		"as_org":          "Deutsche Telekom AG",
+	}
+	german := make(map[string]interface{}, len(full))
+	for k, v := range full {
+		german[k] = v
+	}
+	german["country"], german["continent"], german["city"] = "Deutschland", "Europa", "Berlin-Mitte"
+
+	tests := []struct {
+		name     string
+		ip       string
+		lang     string
+		expected map[string]interface{}
+	}{
+		{"city and asn", "81.2.69.160", "", full},
+		{"language", "81.2.69.160", "de", german},
+		{"unknown language falls back to english", "81.2.69.160", "fr", full},
+		{"asn only", "1.130.0.1", "", map[string]interface{}{"ip": "1.130.0.1", "asn": 1221, "as_org": "Telstra"}},
+		{"private ip", "10.0.0.1", "", nil},
+		{"not an ip", "host", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, ok, err := Lookup(test.ip, test.lang)
+			if err != nil {
+				t.Fatalf("Lookup(%s) failed: %v", test.ip, err)
+			}
+			if ok != (test.expected != nil) || (ok && !reflect.DeepEqual(res, test.expected)) {
+				t.Errorf("Lookup(%s, %q) = %v, %v, expected %v", test.ip, test.lang, res, ok, test.expected)
+			}
+		})
+	}
+
+	if iso, err := CountryISO("81.2.69.160"); err != nil || iso != "DE" {
+		t.Errorf("CountryISO() = %q, %v, expected DE", iso, err)
+	}
+	for _, st := range Databases() {
+		if !st.Loaded || st.Source != "file" || st.Error != "" || st.Build != "2026-01-01T00:00:00Z" {
+			t.Errorf("status of %s = %+v", st.Name, st)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	root := setupGeoIPTest(t)
+	cityPath, asnPath := filepath.Join(root, "city.mmdb"), filepath.Join(root, "asn.mmdb")
+	writeTestFile(t, cityPath, buildTestMMDB(t, "GeoLite2-City", cityNetwork("81.2.69.0/24", "DE", nil)))
+	if err := Start(common.GeoIPConfig{City: cityPath, ASN: asnPath, LocalOnly: true, CheckInterval: "1h"}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// A changed file is loaded on the next check
+	writeTestFile(t, cityPath, buildTestMMDB(t, "GeoLite2-City", cityNetwork("81.2.69.0/24", "AT", nil)))
+	check()
+	if iso, _ := CountryISO("81.2.69.160"); iso != "AT" {
+		t.Errorf("CountryISO() after the update = %q, expected AT", iso)
+	}
+
+	// A broken file keeps the loaded database
+	writeTestFile(t, cityPath, []byte("not a database"))
+	check()
+	if iso, _ := CountryISO("81.2.69.160"); iso != "AT" {
+		t.Errorf("CountryISO() after a broken update = %q, expected AT", iso)
+	}
+	if st := dbCity.status(); !st.Loaded || st.Error == "" {
+		t.Errorf("status after a broken update = %+v, expected the error", st)
+	}
+
+	// Databases of the wrong type are rejected
+	writeTestFile(t, asnPath, buildTestMMDB(t, "GeoLite2-City", cityNetwork("81.2.69.0/24", "DE", nil)))
+	check()
+	if st := dbASN.status(); st.Loaded || !strings.Contains(st.Error, "not a valid asn database") {
+		t.Errorf("status of a city database as asn = %+v", st)
+	}
+
+	if err := Start(common.GeoIPConfig{City: cityPath, CheckInterval: "soon"}); err == nil {
+		t.Errorf("Start() with an invalid check_interval succeeded")
+	}
+}
@@ -0,0 +1,166 @@
+package geoip
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The leader shares its databases through Redis: the file is split into chunks stored under
+// the sha256 of the content, then the meta key is switched to the new version and the chunks
+// of the old version are deleted. Followers compare the meta key with their loaded database,
+// download and verify a new version, write it atomically to their path and load it.
+
+const (
+	redisKeyPrefix = "hub:geoip:"
+	chunkSize      = 4 << 20
+)
+
+type dbMeta struct {
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+	Chunks  int    `json:"chunks"`
+	Updated string `json:"updated"`
+}
+
+func metaKey(name string) string {
+	return redisKeyPrefix + name + ":meta"
+}
+
+func chunkKey(name, sha string, i int) string {
+	return fmt.Sprintf("%s%s:%s:%d", redisKeyPrefix, name, sha, i)
+}
+
+func getMeta(name string) (*dbMeta, error) {
+	raw, err := common.RedisGet(metaKey(name))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var meta dbMeta
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// publish shares the loaded database with followers if it is not the published version
+func (d *database) publish() {
+	d.mu.Lock()
+	path, sha, loaded := d.path, d.sha, d.reader.Load() != nil && d.source == "file"
+	d.mu.Unlock()
+	if !loaded {
+		return
+	}
+
+	old, err := getMeta(d.name)
+	if err != nil {
+		logger.Warn("Failed to read geoip database meta", "database", d.name, "error", err)
+		return
+	}
+	if old != nil && old.SHA256 == sha {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Warn("Failed to read geoip database for cluster sync", "database", d.name, "error", err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != sha {
+		// Changed since it was loaded, published after the next load
+		return
+	}
+
+	meta := dbMeta{SHA256: sha, Size: int64(len(data)), Updated: time.Now().UTC().Format(time.RFC3339)}
+	for off := 0; off < len(data); off += chunkSize {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := common.RedisSet(chunkKey(d.name, sha, meta.Chunks), data[off:end], 0); err != nil {
+			logger.Warn("Failed to publish geoip database", "database", d.name, "error", err)
+			return
+		}
+		meta.Chunks++
+	}
+	raw, _ := json.Marshal(meta)
+	if _, err := common.RedisSet(metaKey(d.name), string(raw), 0); err != nil {
+		logger.Warn("Failed to publish geoip database meta", "database", d.name, "error", err)
+		return
+	}
+	if old != nil {
+		for i := 0; i < old.Chunks; i++ {
+			_ = common.RedisDel(chunkKey(d.name, old.SHA256, i))
+		}
+	}
+	logger.Info("GeoIP database published to cluster", "database", d.name, "sha256", sha, "size", meta.Size)
+}
+
+// syncFromLeader downloads the database published by the leader when it differs from the loaded one
+func (d *database) syncFromLeader() {
+	meta, err := getMeta(d.name)
+	if err != nil || meta == nil {
+		return
+	}
+	d.mu.Lock()
+	path, sha := d.path, d.sha
+	d.mu.Unlock()
+	if meta.SHA256 == sha || path == "" {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(int(meta.Size))
+	for i := 0; i < meta.Chunks; i++ {
+		chunk, err := common.RedisGet(chunkKey(d.name, meta.SHA256, i))
+		if err != nil {
+			// The leader may have published a newer version meanwhile, retried on the next check
+			d.setErr(fmt.Errorf("download from leader: %w", err))
+			return
+		}
+		buf.WriteString(chunk)
+	}
+	data := buf.Bytes()
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != meta.Size || hex.EncodeToString(sum[:]) != meta.SHA256 {
+		d.setErr(errors.New("download from leader: checksum mismatch"))
+		return
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		d.setErr(err)
+		logger.Error("Failed to write geoip database from leader", "database", d.name, "path", path, "error", err)
+		return
+	}
+	if info, err := os.Stat(path); err == nil {
+		d.setFileInfo(info)
+	}
+	if err := d.load(data, "leader"); err != nil {
+		logger.Error("Failed to load geoip database from leader", "database", d.name, "error", err)
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,81 @@
+package geoip
+
+import (
+	"AgentSmith-HUB/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestClusterSync(t *testing.T) {
+	root := setupGeoIPTest(t)
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+
+	leaderPath := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestFile(t, leaderPath, buildTestMMDB(t, "GeoLite2-City", cityNetwork("81.2.69.0/24", "DE", nil)))
+	leader := &database{name: DBCity}
+	leader.setPath(leaderPath)
+	leader.checkFile()
+	leader.publish()
+
+	meta, err := getMeta(DBCity)
+	if err != nil || meta == nil || meta.SHA256 != leader.status().SHA256 || meta.Chunks != 1 {
+		t.Fatalf("published meta = %+v, %v", meta, err)
+	}
+
+	// Followers without a configured path keep the leader copy under config_root
+	follower := &database{name: DBCity}
+	follower.setPath("")
+	follower.syncFromLeader()
+	st := follower.status()
+	if !st.Loaded || st.Source != "leader" || st.SHA256 != meta.SHA256 || st.Error != "" {
+		t.Fatalf("follower status = %+v", st)
+	}
+	if st.Path != filepath.Join(root, "geoip", "city.mmdb") {
+		t.Errorf("follower path = %s", st.Path)
+	}
+	if data, err := os.ReadFile(st.Path); err != nil || int64(len(data)) != meta.Size {
+		t.Errorf("follower file = %d bytes, %v, expected %d bytes", len(data), err, meta.Size)
+	}
+	// A restarted follower loads the stored copy without downloading it again
+	restarted := &database{name: DBCity}
+	restarted.setPath("")
+	restarted.checkFile()
+	if st := restarted.status(); !st.Loaded || st.Source != "file" {
+		t.Errorf("restarted follower status = %+v", st)
+	}
+
+	// A new version replaces the chunks of the old one
+	oldSHA := meta.SHA256
+	writeTestFile(t, leaderPath, buildTestMMDB(t, "GeoLite2-City", cityNetwork("81.2.69.0/24", "AT", nil)))
+	leader.checkFile()
+	leader.publish()
+	meta, _ = getMeta(DBCity)
+	if meta.SHA256 == oldSHA {
+		t.Fatalf("new version was not published")
+	}
+	for _, key := range mr.Keys() {
+		if strings.Contains(key, oldSHA) {
+			t.Errorf("chunk %s of the old version was not deleted", key)
+		}
+	}
+	follower.syncFromLeader()
+	if st := follower.status(); st.SHA256 != meta.SHA256 {
+		t.Errorf("follower did not load the new version: %+v", st)
+	}
+
+	// A corrupt download is not loaded
+	mr.Set(chunkKey(DBCity, meta.SHA256, 0), "garbage")
+	corrupt := &database{name: DBCity}
+	corrupt.setPath(filepath.Join(t.TempDir(), "city.mmdb"))
+	corrupt.syncFromLeader()
+	if st := corrupt.status(); st.Loaded || !strings.Contains(st.Error, "checksum mismatch") {
+		t.Errorf("status after a corrupt download = %+v", st)
+	}
+}
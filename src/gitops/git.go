@@ -0,0 +1,125 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// repo is the local checkout of the GitOps repository, driven through the git command
+type repo struct {
+	dir    string
+	url    string
+	branch string
+}
+
+func (r *repo) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	// Never wait for credentials on a terminal, use a credential helper or SSH key instead
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// open creates the checkout if needed and points its origin to the repository
+func (r *repo) open(ctx context.Context) error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create gitops work dir: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		if _, err := r.git(ctx, "init", "-q"); err != nil {
+			return err
+		}
+	}
+	if _, err := r.git(ctx, "remote", "get-url", "origin"); err != nil {
+		_, err = r.git(ctx, "remote", "add", "origin", r.url)
+		return err
+	}
+	_, err := r.git(ctx, "remote", "set-url", "origin", r.url)
+	return err
+}
+
+func (r *repo) remoteRef() string {
+	return "refs/remotes/origin/" + r.branch
+}
+
+// update fetches the branch and resets the checkout to it, local changes are dropped
+func (r *repo) update(ctx context.Context) error {
+	if _, err := r.git(ctx, "fetch", "-q", "--prune", "origin", "+refs/heads/"+r.branch+":"+r.remoteRef()); err != nil {
+		return err
+	}
+	if _, err := r.git(ctx, "checkout", "-q", "-f", "-B", r.branch, r.remoteRef()); err != nil {
+		return err
+	}
+	if _, err := r.git(ctx, "clean", "-q", "-fd"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// head returns the commit checked out, its author and subject
+func (r *repo) head(ctx context.Context) (commit, author, subject string, err error) {
+	out, err := r.git(ctx, "log", "-1", "--format=%H%x00%an <%ae>%x00%s")
+	if err != nil {
+		return "", "", "", err
+	}
+	parts := strings.SplitN(out, "\x00", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("unexpected git log output: %q", out)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// commit stages files (relative to the checkout, nil content deletes the file) and commits them,
+// false when the files already are as given
+func (r *repo) commit(ctx context.Context, files map[string][]byte, author, message string) (bool, error) {
+	for file, content := range files {
+		path := filepath.Join(r.dir, file)
+		if content == nil {
+			if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return false, err
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return false, err
+			}
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				return false, err
+			}
+		}
+		if _, err := r.git(ctx, "add", "-A", "--", file); err != nil {
+			return false, err
+		}
+	}
+
+	if out, err := r.git(ctx, "status", "--porcelain"); err != nil || out == "" {
+		return false, err
+	}
+	_, err := r.git(ctx, "-c", "user.name="+committerName, "-c", "user.email="+committerEmail,
+		"commit", "-q", "--author", author, "-m", message)
+	return err == nil, err
+}
+
+// push pushes the checked out branch
+func (r *repo) push(ctx context.Context) error {
+	_, err := r.git(ctx, "push", "-q", "origin", "HEAD:refs/heads/"+r.branch)
+	return err
+}
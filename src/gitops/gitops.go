@@ -0,0 +1,683 @@
+package gitops
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/rules_engine"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitOps mode: the leader pulls a git repository and applies the components in it like pushed changes,
+// i.e. every component is verified and published as an instruction to the followers. config.yaml:
+//
+//	gitops:
+//	  enabled: true
+//	  repo: git@github.com:example/hub-config.git   # or a path, e.g. /srv/git/hub-config.git
+//	  branch: main
+//	  path: hub                                      # holds input/, output/, ruleset/, plugin/, lookup/, project/
+//	  interval: 1m                                   # "0": pull on webhook and POST /gitops/sync only
+//	  prune: true                                    # delete components that are not in the repository
+//	  webhook_secret: xxx                            # enables POST /gitops/webhook
+//	  commit_back: true                              # commit changes applied through the API and UI
+//
+// The repository is the source of truth: each new commit is reconciled in full, a component changed
+// through the UI without commit_back is overwritten by the next commit. A commit with an invalid
+// component is rejected as a whole and retried only with the next commit or a manual sync.
+
+const (
+	defaultBranch       = "main"
+	defaultInterval     = time.Minute
+	defaultWorkDir      = "gitops"
+	defaultAuthorDomain = "agentsmith-hub.local"
+
+	committerName  = "AgentSmith-HUB"
+	committerEmail = "hub@" + defaultAuthorDomain
+
+	gitTimeout       = 2 * time.Minute
+	commitQueueSize  = 1024
+	commitPushTries  = 3
+	webhookDebounce  = time.Second
+	commitRetryDelay = time.Second
+)
+
+// componentSuffixes are the file suffixes of the components in the repository, by type
+var componentSuffixes = map[string]string{
+	"input":   ".yaml",
+	"output":  ".yaml",
+	"ruleset": ".xml",
+	"project": ".yaml",
+	"plugin":  ".go",
+	"lookup":  ".yaml",
+}
+
+var errNotStarted = errors.New("gitops is not enabled")
+
+// Revision is the component set of one commit of the repository
+type Revision struct {
+	Commit     string                       `json:"commit"`
+	Author     string                       `json:"author"`
+	Subject    string                       `json:"subject"`
+	Components map[string]map[string]string `json:"-"` // Type -> ID -> config
+	TestCases  map[string]string            `json:"-"` // Ruleset ID -> test case file
+}
+
+// Change is a component a sync added, updated or deleted
+type Change struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Change string `json:"change"` // added, modified or removed
+	Error  string `json:"error,omitempty"`
+}
+
+// Result of applying a revision
+type Result struct {
+	Commit   string   `json:"commit"`
+	Changes  []Change `json:"changes"`
+	Rejected bool     `json:"rejected"` // A component failed verification, nothing was applied
+}
+
+// ApplyFunc verifies a revision and applies the components that differ from the running ones
+type ApplyFunc func(rev *Revision, prune bool) (*Result, error)
+
+// Status describes the GitOps sync of this node
+type Status struct {
+	Enabled         bool    `json:"enabled"`
+	Repo            string  `json:"repo,omitempty"`
+	Branch          string  `json:"branch,omitempty"`
+	Path            string  `json:"path,omitempty"`
+	Interval        string  `json:"interval,omitempty"`
+	Prune           bool    `json:"prune"`
+	Webhook         bool    `json:"webhook"`
+	CommitBack      bool    `json:"commit_back"`
+	Commit          string  `json:"commit,omitempty"` // Last commit synced
+	Author          string  `json:"author,omitempty"`
+	Subject         string  `json:"subject,omitempty"`
+	AppliedCommit   string  `json:"applied_commit,omitempty"` // Last commit applied without rejection
+	Syncs           uint64  `json:"syncs"`
+	Failures        uint64  `json:"failures"`
+	LastSync        string  `json:"last_sync,omitempty"`
+	LastError       string  `json:"last_error,omitempty"`
+	LastResult      *Result `json:"last_result,omitempty"`
+	PendingCommits  int     `json:"pending_commits"`
+	LastCommitBack  string  `json:"last_commit_back,omitempty"`
+	CommitBackError string  `json:"commit_back_error,omitempty"`
+}
+
+type commitRequest struct {
+	files   map[string][]byte // Relative to the checkout, nil deletes the file
+	author  string
+	message string
+}
+
+type syncer struct {
+	cfg      common.GitOpsConfig
+	repo     *repo
+	path     string // Component folders, relative to the checkout
+	interval time.Duration
+	apply    ApplyFunc
+	trigger  chan struct{}
+	commits  chan commitRequest
+
+	repoMu sync.Mutex // serializes syncs and commit-backs on the checkout
+
+	statusMu sync.Mutex
+	status   Status
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *syncer
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+)
+
+// Start syncs the components of the leader from the repository of cfg, nothing is done when it is disabled
+func Start(cfg common.GitOpsConfig, apply ApplyFunc) error {
+	Stop()
+	if !cfg.Enabled {
+		return nil
+	}
+
+	s, err := newSyncer(cfg, apply)
+	if err != nil {
+		return err
+	}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	activeMu.Lock()
+	active = s
+	cancel = cancelFn
+	activeMu.Unlock()
+
+	wg.Add(1)
+	go s.run(ctx)
+	if cfg.CommitBack {
+		wg.Add(1)
+		go s.runCommitBack(ctx)
+	}
+	logger.Info("GitOps sync started", "repo", cfg.Repo, "branch", s.repo.branch, "path", s.path, "interval", s.interval, "commit_back", cfg.CommitBack)
+	return nil
+}
+
+// Stop stops syncing, commit-backs not pushed yet are dropped
+func Stop() {
+	activeMu.Lock()
+	s := active
+	cancelFn := cancel
+	active = nil
+	cancel = nil
+	activeMu.Unlock()
+
+	if cancelFn != nil {
+		cancelFn()
+		wg.Wait()
+	}
+	if s != nil && len(s.commits) > 0 {
+		logger.Warn("GitOps commit-backs dropped on stop", "count", len(s.commits))
+	}
+}
+
+func current() *syncer {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+func newSyncer(cfg common.GitOpsConfig, apply ApplyFunc) (*syncer, error) {
+	if cfg.Repo == "" {
+		return nil, fmt.Errorf("gitops repo is required")
+	}
+	if apply == nil {
+		return nil, fmt.Errorf("gitops apply function is required")
+	}
+
+	s := &syncer{
+		cfg:      cfg,
+		interval: defaultInterval,
+		apply:    apply,
+		trigger:  make(chan struct{}, 1),
+		commits:  make(chan commitRequest, commitQueueSize),
+	}
+	switch strings.TrimSpace(cfg.Interval) {
+	case "":
+	case "0":
+		s.interval = 0
+	default:
+		sec, err := common.ParseDurationToSecondsInt(cfg.Interval)
+		if err != nil || sec <= 0 {
+			return nil, fmt.Errorf("invalid gitops interval %q", cfg.Interval)
+		}
+		s.interval = time.Duration(sec) * time.Second
+	}
+
+	branch := cfg.Branch
+	if branch == "" {
+		branch = defaultBranch
+	}
+	dir := cfg.WorkDir
+	if dir == "" {
+		dir = defaultWorkDir
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gitops work dir: %w", err)
+	}
+	s.repo = &repo{dir: dir, url: cfg.Repo, branch: branch}
+
+	// Keep the path inside the checkout
+	s.path = strings.TrimPrefix(filepath.Clean("/"+cfg.Path), "/")
+
+	interval := "0"
+	if s.interval > 0 {
+		interval = s.interval.String()
+	}
+	s.status = Status{
+		Enabled:    true,
+		Repo:       cfg.Repo,
+		Branch:     branch,
+		Path:       s.path,
+		Interval:   interval,
+		Prune:      cfg.Prune,
+		Webhook:    cfg.WebhookSecret != "",
+		CommitBack: cfg.CommitBack,
+	}
+	return s, nil
+}
+
+func (s *syncer) run(ctx context.Context) {
+	defer wg.Done()
+
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	s.syncLogged(ctx)
+	for {
+		select {
+		case <-tick:
+		case <-s.trigger:
+			// Webhooks often come in bursts, e.g. one per pushed branch
+			select {
+			case <-time.After(webhookDebounce):
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		s.syncLogged(ctx)
+	}
+}
+
+func (s *syncer) syncLogged(ctx context.Context) {
+	if err := s.sync(ctx, false); err != nil {
+		logger.Error("GitOps sync failed", "repo", s.cfg.Repo, "error", err)
+	}
+}
+
+// sync applies the head of the branch when it is a new commit, or always with force
+func (s *syncer) sync(ctx context.Context, force bool) error {
+	s.repoMu.Lock()
+	defer s.repoMu.Unlock()
+
+	if err := common.RequireLeader(); err != nil {
+		return err
+	}
+	// Changes waiting to be committed would be reverted by the older repository content
+	if len(s.commits) > 0 {
+		if force {
+			return fmt.Errorf("%d changes are waiting to be committed to the repository, try again later", len(s.commits))
+		}
+		return nil
+	}
+
+	gitCtx, cancelFn := context.WithTimeout(ctx, gitTimeout)
+	defer cancelFn()
+
+	err := s.repo.open(gitCtx)
+	if err == nil {
+		err = s.repo.update(gitCtx)
+	}
+	var commit, author, subject string
+	if err == nil {
+		commit, author, subject, err = s.repo.head(gitCtx)
+	}
+	if err != nil {
+		s.recordSync(nil, err)
+		return err
+	}
+
+	s.statusMu.Lock()
+	synced := s.status.Commit == commit
+	s.statusMu.Unlock()
+	if synced && !force {
+		return nil
+	}
+
+	rev, err := s.readRevision()
+	if err != nil {
+		s.recordSync(nil, err)
+		return err
+	}
+	rev.Commit, rev.Author, rev.Subject = commit, author, subject
+
+	logger.Info("GitOps sync", "commit", commit, "author", author, "subject", subject)
+	res, err := s.apply(rev, s.cfg.Prune)
+	if res != nil {
+		res.Commit = commit
+	}
+	s.recordSync(res, err)
+	if err != nil {
+		return err
+	}
+
+	s.statusMu.Lock()
+	s.status.Commit, s.status.Author, s.status.Subject = commit, author, subject
+	if !res.Rejected {
+		s.status.AppliedCommit = commit
+	}
+	s.statusMu.Unlock()
+	if res.Rejected {
+		return fmt.Errorf("commit %s rejected, a component failed verification", shortCommit(commit))
+	}
+	return nil
+}
+
+func (s *syncer) recordSync(res *Result, err error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	s.status.Syncs++
+	s.status.LastSync = time.Now().Format(time.RFC3339)
+	s.status.LastError = ""
+	if err != nil {
+		s.status.Failures++
+		s.status.LastError = err.Error()
+		return
+	}
+	s.status.LastResult = res
+	if res.Rejected {
+		s.status.Failures++
+	}
+}
+
+// readRevision reads the components of the checkout
+func (s *syncer) readRevision() (*Revision, error) {
+	rev := &Revision{
+		Components: make(map[string]map[string]string, len(componentSuffixes)),
+		TestCases:  make(map[string]string),
+	}
+	root := filepath.Join(s.repo.dir, s.path)
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("path %q not found in the repository", s.path)
+	}
+
+	total := 0
+	for componentType, suffix := range componentSuffixes {
+		configs := make(map[string]string)
+		rev.Components[componentType] = configs
+
+		entries, err := os.ReadDir(filepath.Join(root, componentType))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") {
+				continue
+			}
+			var id string
+			testCases := false
+			switch {
+			case componentType == "ruleset" && strings.HasSuffix(name, rules_engine.TestCaseFileSuffix):
+				id = strings.TrimSuffix(name, rules_engine.TestCaseFileSuffix)
+				testCases = true
+			case strings.HasSuffix(name, suffix):
+				id = strings.TrimSuffix(name, suffix)
+			default:
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(root, componentType, name))
+			if err != nil {
+				return nil, err
+			}
+			if testCases {
+				rev.TestCases[id] = string(data)
+			} else {
+				configs[id] = string(data)
+				total++
+			}
+		}
+	}
+
+	// Test cases of rulesets that are not in the repository are ignored
+	for id := range rev.TestCases {
+		if _, ok := rev.Components["ruleset"][id]; !ok {
+			delete(rev.TestCases, id)
+		}
+	}
+	// Most likely a wrong path or branch, don't prune every component
+	if total == 0 {
+		return nil, fmt.Errorf("no components found under path %q of the repository", s.path)
+	}
+	return rev, nil
+}
+
+// Enabled reports whether GitOps sync runs on this node
+func Enabled() bool {
+	return current() != nil
+}
+
+// GetStatus returns the GitOps sync status of this node
+func GetStatus() Status {
+	s := current()
+	if s == nil {
+		return Status{}
+	}
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	st := s.status
+	st.PendingCommits = len(s.commits)
+	return st
+}
+
+// Sync pulls and applies the head of the branch now, also when it was synced already
+func Sync() (Status, error) {
+	s := current()
+	if s == nil {
+		return Status{}, errNotStarted
+	}
+	err := s.sync(context.Background(), true)
+	return GetStatus(), err
+}
+
+// Trigger requests a sync, e.g. on a push webhook, false when GitOps is not enabled
+func Trigger() bool {
+	s := current()
+	if s == nil {
+		return false
+	}
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// ===================== Commit-back =====================
+
+// CommitComponent commits the config of a component changed through the API, in the background
+func CommitComponent(componentType, id, content, operator string) {
+	suffix, ok := componentSuffixes[componentType]
+	if !ok {
+		return
+	}
+	enqueueCommit(map[string][]byte{componentType + "/" + id + suffix: []byte(content)},
+		operator, fmt.Sprintf("Update %s %s", componentType, id))
+}
+
+// CommitComponentDelete commits the deletion of a component, with the test cases of a ruleset
+func CommitComponentDelete(componentType, id, operator string) {
+	suffix, ok := componentSuffixes[componentType]
+	if !ok {
+		return
+	}
+	files := map[string][]byte{componentType + "/" + id + suffix: nil}
+	if componentType == "ruleset" {
+		files["ruleset/"+id+rules_engine.TestCaseFileSuffix] = nil
+	}
+	enqueueCommit(files, operator, fmt.Sprintf("Delete %s %s", componentType, id))
+}
+
+// CommitTestCases commits the test case file of a ruleset, empty content deletes it
+func CommitTestCases(rulesetID, content, operator string) {
+	var data []byte
+	if content != "" {
+		data = []byte(content)
+	}
+	enqueueCommit(map[string][]byte{"ruleset/" + rulesetID + rules_engine.TestCaseFileSuffix: data},
+		operator, fmt.Sprintf("Update test cases of ruleset %s", rulesetID))
+}
+
+func enqueueCommit(files map[string][]byte, operator, message string) {
+	s := current()
+	if s == nil || !s.cfg.CommitBack {
+		return
+	}
+	req := commitRequest{
+		files:   make(map[string][]byte, len(files)),
+		author:  s.authorIdentity(operator),
+		message: message,
+	}
+	for file, content := range files {
+		req.files[filepath.Join(s.path, filepath.FromSlash(file))] = content
+	}
+
+	select {
+	case s.commits <- req:
+	default:
+		logger.Error("GitOps commit-back queue is full, change not committed", "message", message)
+	}
+}
+
+// authorIdentity is the git author of a user, "name <email>"
+func (s *syncer) authorIdentity(operator string) string {
+	if operator == "" {
+		return committerName + " <" + committerEmail + ">"
+	}
+	if strings.Contains(operator, "@") {
+		return operator + " <" + operator + ">"
+	}
+	domain := s.cfg.AuthorDomain
+	if domain == "" {
+		domain = defaultAuthorDomain
+	}
+	return operator + " <" + operator + "@" + domain + ">"
+}
+
+func (s *syncer) runCommitBack(ctx context.Context) {
+	defer wg.Done()
+	for {
+		select {
+		case req := <-s.commits:
+			commit, err := s.commitBack(ctx, req)
+			s.statusMu.Lock()
+			if err != nil {
+				s.status.CommitBackError = err.Error()
+			} else if commit != "" {
+				s.status.LastCommitBack = commit
+				s.status.CommitBackError = ""
+			}
+			s.statusMu.Unlock()
+			if err != nil {
+				logger.Error("GitOps commit-back failed", "message", req.message, "author", req.author, "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// commitBack commits and pushes a change on top of the head of the branch, retrying when the push
+// was rejected because the branch moved on. Returns the pushed commit, empty when nothing changed.
+func (s *syncer) commitBack(ctx context.Context, req commitRequest) (string, error) {
+	s.repoMu.Lock()
+	defer s.repoMu.Unlock()
+
+	var err error
+	for try := 0; try < commitPushTries; try++ {
+		if try > 0 {
+			select {
+			case <-time.After(commitRetryDelay):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		gitCtx, cancelFn := context.WithTimeout(ctx, gitTimeout)
+		var commit string
+		commit, err = s.commitAndPush(gitCtx, req)
+		cancelFn()
+		if err == nil {
+			if commit != "" {
+				logger.Info("GitOps change committed", "commit", commit, "author", req.author, "message", req.message)
+			}
+			return commit, nil
+		}
+	}
+	return "", err
+}
+
+func (s *syncer) commitAndPush(ctx context.Context, req commitRequest) (string, error) {
+	if err := s.repo.open(ctx); err != nil {
+		return "", err
+	}
+	if err := s.repo.update(ctx); err != nil {
+		return "", err
+	}
+	base, _, _, err := s.repo.head(ctx)
+	if err != nil {
+		return "", err
+	}
+	changed, err := s.repo.commit(ctx, req.files, req.author, req.message)
+	if err != nil || !changed {
+		return "", err
+	}
+	if err := s.repo.push(ctx); err != nil {
+		return "", err
+	}
+	commit, _, _, err := s.repo.head(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The pushed commit holds what is running already, no need to apply it. When the branch moved on
+	// since the last sync it also holds commits of others, which the next sync applies.
+	s.statusMu.Lock()
+	if s.status.Commit == base && s.status.Commit == s.status.AppliedCommit {
+		s.status.Commit, s.status.AppliedCommit = commit, commit
+		s.status.Author, s.status.Subject = req.author, req.message
+	}
+	s.statusMu.Unlock()
+	return commit, nil
+}
+
+// ChangedComponents lists the components of a revision that differ from the running ones, in apply order
+func ChangedComponents(rev *Revision, running map[string]map[string]string, order []string, prune bool) []Change {
+	var changes []Change
+	for _, componentType := range order {
+		configs := rev.Components[componentType]
+		ids := make([]string, 0, len(configs))
+		for id := range configs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			old, exists := running[componentType][id]
+			switch {
+			case !exists:
+				changes = append(changes, Change{Type: componentType, ID: id, Change: "added"})
+			case strings.TrimSpace(old) != strings.TrimSpace(configs[id]):
+				changes = append(changes, Change{Type: componentType, ID: id, Change: "modified"})
+			}
+		}
+	}
+	if !prune {
+		return changes
+	}
+
+	// Removed in reverse order, projects before the components they use
+	for i := len(order) - 1; i >= 0; i-- {
+		componentType := order[i]
+		ids := make([]string, 0)
+		for id := range running[componentType] {
+			if _, ok := rev.Components[componentType][id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			changes = append(changes, Change{Type: componentType, ID: id, Change: "removed"})
+		}
+	}
+	return changes
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
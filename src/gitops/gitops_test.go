@@ -0,0 +1,325 @@
+package gitops
+
+import (
+	"AgentSmith-HUB/common"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRemote is a bare repository with a clone that pushes commits to it, like another user of the repository
+type testRemote struct {
+	t     *testing.T
+	bare  string
+	clone string
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func newTestRemote(t *testing.T) *testRemote {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &testRemote{t: t, bare: filepath.Join(t.TempDir(), "hub-config.git"), clone: t.TempDir()}
+	runGit(t, t.TempDir(), "init", "-q", "--bare", "-b", "main", r.bare)
+	runGit(t, r.clone, "init", "-q", "-b", "main")
+	runGit(t, r.clone, "remote", "add", "origin", r.bare)
+	return r
+}
+
+// push commits files under hub/ of the repository, an empty content deletes the file
+func (r *testRemote) push(message string, files map[string]string) string {
+	r.t.Helper()
+	if out := runGit(r.t, r.clone, "ls-remote", "origin", "main"); out != "" {
+		runGit(r.t, r.clone, "pull", "-q", "origin", "main")
+	}
+	for name, content := range files {
+		path := filepath.Join(r.clone, "hub", name)
+		if content == "" {
+			os.Remove(path)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	runGit(r.t, r.clone, "add", "-A")
+	runGit(r.t, r.clone, "commit", "-q", "--allow-empty", "-m", message)
+	runGit(r.t, r.clone, "push", "-q", "origin", "HEAD:refs/heads/main")
+	return runGit(r.t, r.clone, "rev-parse", "HEAD")
+}
+
+// file returns a file of hub/ at the head of the remote branch
+func (r *testRemote) file(name string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", "--git-dir", r.bare, "show", "main:hub/"+name)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// testApply records the revisions it is given and rejects them while reject is set
+type testApply struct {
+	revisions []*Revision
+	reject    bool
+}
+
+func (a *testApply) apply(rev *Revision, prune bool) (*Result, error) {
+	a.revisions = append(a.revisions, rev)
+	return &Result{Rejected: a.reject}, nil
+}
+
+func newTestSyncer(t *testing.T, remote *testRemote, apply ApplyFunc, prune bool) *syncer {
+	t.Helper()
+	common.SetClusterState(true, "leader")
+	s, err := newSyncer(common.GitOpsConfig{
+		Enabled:    true,
+		Repo:       remote.bare,
+		Path:       "hub",
+		Interval:   "0",
+		WorkDir:    filepath.Join(t.TempDir(), "checkout"),
+		Prune:      prune,
+		CommitBack: true,
+	}, apply)
+	if err != nil {
+		t.Fatalf("newSyncer failed: %v", err)
+	}
+	return s
+}
+
+func TestSync(t *testing.T) {
+	remote := newTestRemote(t)
+	first := remote.push("Add components", map[string]string{
+		"input/in.yaml":          "type: kafka",
+		"ruleset/rs.xml":         "<root/>",
+		"ruleset/rs.test.yaml":   "cases: []",
+		"ruleset/gone.test.yaml": "cases: []",
+		"README.md":              "not a component",
+	})
+	applier := &testApply{}
+	s := newTestSyncer(t, remote, applier.apply, false)
+	ctx := context.Background()
+
+	if err := s.sync(ctx, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(applier.revisions) != 1 {
+		t.Fatalf("applied %d revisions, expected 1", len(applier.revisions))
+	}
+	rev := applier.revisions[0]
+	if rev.Commit != first || rev.Author != "tester <tester@example.com>" || rev.Subject != "Add components" {
+		t.Errorf("revision = %s %q %q, expected the pushed commit", rev.Commit, rev.Author, rev.Subject)
+	}
+	if rev.Components["input"]["in"] != "type: kafka" || rev.Components["ruleset"]["rs"] != "<root/>" {
+		t.Errorf("components = %v", rev.Components)
+	}
+	if !reflect.DeepEqual(rev.TestCases, map[string]string{"rs": "cases: []"}) {
+		t.Errorf("test cases = %v, expected only those of rulesets in the repository", rev.TestCases)
+	}
+
+	// The same commit is applied again only when forced
+	if err := s.sync(ctx, false); err != nil || len(applier.revisions) != 1 {
+		t.Errorf("sync of the synced commit = %v with %d revisions, expected nothing applied", err, len(applier.revisions))
+	}
+	if err := s.sync(ctx, true); err != nil || len(applier.revisions) != 2 {
+		t.Errorf("forced sync = %v with %d revisions, expected the commit applied again", err, len(applier.revisions))
+	}
+
+	second := remote.push("Update input", map[string]string{"input/in.yaml": "type: file"})
+	if err := s.sync(ctx, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if rev := applier.revisions[len(applier.revisions)-1]; rev.Commit != second || rev.Components["input"]["in"] != "type: file" {
+		t.Errorf("revision %s = %v, expected the new commit", rev.Commit, rev.Components["input"])
+	}
+	if st := s.status; st.Commit != second || st.AppliedCommit != second || st.Failures != 0 {
+		t.Errorf("status = %+v, expected the new commit applied", st)
+	}
+}
+
+func TestSyncPrune(t *testing.T) {
+	remote := newTestRemote(t)
+	remote.push("Add inputs", map[string]string{"input/a.yaml": "a", "input/b.yaml": "b"})
+	running := map[string]map[string]string{"input": {"a": "a", "b": "b"}, "project": {"old": "x"}}
+	order := []string{"input", "project"}
+
+	for _, prune := range []bool{false, true} {
+		applier := &testApply{}
+		s := newTestSyncer(t, remote, applier.apply, prune)
+		remote.push("Remove input b", map[string]string{"input/b.yaml": "", "input/a.yaml": "a2"})
+		if err := s.sync(context.Background(), false); err != nil {
+			t.Fatalf("sync failed: %v", err)
+		}
+
+		changes := ChangedComponents(applier.revisions[0], running, order, prune)
+		expected := []Change{{Type: "input", ID: "a", Change: "modified"}}
+		if prune {
+			expected = append(expected, Change{Type: "project", ID: "old", Change: "removed"}, Change{Type: "input", ID: "b", Change: "removed"})
+		}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("prune %v: changes = %+v, expected %+v", prune, changes, expected)
+		}
+		remote.push("Restore input b", map[string]string{"input/b.yaml": "b"})
+	}
+
+	// A commit without components is most likely a wrong path, it must not prune everything
+	applier := &testApply{}
+	s := newTestSyncer(t, remote, applier.apply, true)
+	remote.push("Remove all", map[string]string{"input/a.yaml": "", "input/b.yaml": ""})
+	if err := s.sync(context.Background(), false); err == nil || len(applier.revisions) != 0 {
+		t.Errorf("sync of an empty revision = %v with %d revisions, expected an error and nothing applied", err, len(applier.revisions))
+	}
+}
+
+func TestSyncRejectedCommit(t *testing.T) {
+	remote := newTestRemote(t)
+	good := remote.push("Good", map[string]string{"ruleset/rs.xml": "<root/>"})
+	applier := &testApply{}
+	s := newTestSyncer(t, remote, applier.apply, false)
+	ctx := context.Background()
+	if err := s.sync(ctx, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	bad := remote.push("Broken ruleset", map[string]string{"ruleset/rs.xml": "<root"})
+	applier.reject = true
+	if err := s.sync(ctx, false); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("sync of a rejected commit = %v, expected a rejection", err)
+	}
+	if st := s.status; st.Commit != bad || st.AppliedCommit != good || st.Failures != 1 || !st.LastResult.Rejected {
+		t.Errorf("status = %+v, expected %s synced but %s still applied", st, bad, good)
+	}
+
+	// Not retried until the next commit or a forced sync
+	if err := s.sync(ctx, false); err != nil || len(applier.revisions) != 2 {
+		t.Errorf("sync = %v with %d revisions, expected the rejected commit not to be retried", err, len(applier.revisions))
+	}
+
+	applier.reject = false
+	fixed := remote.push("Fix ruleset", map[string]string{"ruleset/rs.xml": "<root/>"})
+	if err := s.sync(ctx, false); err != nil {
+		t.Fatalf("sync of the fix failed: %v", err)
+	}
+	if st := s.status; st.AppliedCommit != fixed {
+		t.Errorf("applied commit = %s, expected %s", st.AppliedCommit, fixed)
+	}
+}
+
+func TestCommitBackPushRace(t *testing.T) {
+	remote := newTestRemote(t)
+	remote.push("Seed", map[string]string{"input/in.yaml": "type: kafka"})
+	applier := &testApply{}
+	s := newTestSyncer(t, remote, applier.apply, false)
+	ctx := context.Background()
+	if err := s.sync(ctx, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	req := commitRequest{
+		files:   map[string][]byte{filepath.Join("hub", "ruleset", "rs.xml"): []byte("<root/>")},
+		author:  s.authorIdentity("alice"),
+		message: "Update ruleset rs",
+	}
+
+	// Another user pushes between the fetch and the push of the hub
+	if err := s.repo.update(ctx); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	other := remote.push("Update input", map[string]string{"input/in.yaml": "type: file"})
+	if changed, err := s.repo.commit(ctx, req.files, req.author, req.message); err != nil || !changed {
+		t.Fatalf("commit = %v, %v", changed, err)
+	}
+	if err := s.repo.push(ctx); err == nil {
+		t.Fatal("push on top of a stale branch succeeded")
+	}
+
+	commit, err := s.commitBack(ctx, req)
+	if err != nil || commit == "" {
+		t.Fatalf("commitBack = %q, %v", commit, err)
+	}
+	if parent := runGit(t, s.repo.dir, "rev-parse", commit+"^"); parent != other {
+		t.Errorf("commit-back parent = %s, expected the commit of the other user %s", parent, other)
+	}
+	if remote.file("ruleset/rs.xml") != "<root/>" || remote.file("input/in.yaml") != "type: file" {
+		t.Error("the remote branch lost one of the changes")
+	}
+	if author := runGit(t, s.repo.dir, "log", "-1", "--format=%an <%ae>", commit); author != "alice <alice@agentsmith-hub.local>" {
+		t.Errorf("author = %q", author)
+	}
+
+	// The commit of the other user is not running yet, the next sync must apply it
+	if err := s.sync(ctx, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(applier.revisions) != 2 || applier.revisions[1].Components["input"]["in"] != "type: file" {
+		t.Errorf("applied %d revisions, expected the branch with the other user's change", len(applier.revisions))
+	}
+
+	// Without others, the pushed commit is what runs and is not applied again
+	if commit, err = s.commitBack(ctx, commitRequest{
+		files:   map[string][]byte{filepath.Join("hub", "ruleset", "rs.xml"): nil},
+		author:  s.authorIdentity(""),
+		message: "Delete ruleset rs",
+	}); err != nil || commit == "" {
+		t.Fatalf("commitBack = %q, %v", commit, err)
+	}
+	if err := s.sync(ctx, false); err != nil || len(applier.revisions) != 2 {
+		t.Errorf("sync after an own commit-back = %v with %d revisions, expected nothing applied", err, len(applier.revisions))
+	}
+	if remote.file("ruleset/rs.xml") != "" {
+		t.Error("deleted ruleset is still in the repository")
+	}
+}
+
+func TestCommitBackRetriesRejectedPush(t *testing.T) {
+	remote := newTestRemote(t)
+	remote.push("Seed", map[string]string{"input/in.yaml": "type: kafka"})
+	s := newTestSyncer(t, remote, (&testApply{}).apply, false)
+
+	// The first push is rejected, e.g. the branch moved on
+	marker := filepath.Join(t.TempDir(), "rejected")
+	hook := "#!/bin/sh\nif [ ! -f " + marker + " ]; then touch " + marker + "; echo 'rejected once' >&2; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(remote.bare, "hooks", "pre-receive"), []byte(hook), 0755); err != nil {
+		t.Fatalf("failed to install hook: %v", err)
+	}
+
+	commit, err := s.commitBack(context.Background(), commitRequest{
+		files:   map[string][]byte{filepath.Join("hub", "input", "in.yaml"): []byte("type: file")},
+		author:  s.authorIdentity("bob@example.com"),
+		message: "Update input in",
+	})
+	if err != nil || commit == "" {
+		t.Fatalf("commitBack = %q, %v, expected the retry to push", commit, err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("the first push was not rejected")
+	}
+	if remote.file("input/in.yaml") != "type: file" {
+		t.Error("change is not in the repository")
+	}
+
+	// Nothing to commit
+	if commit, err := s.commitBack(context.Background(), commitRequest{
+		files:   map[string][]byte{filepath.Join("hub", "input", "in.yaml"): []byte("type: file")},
+		author:  s.authorIdentity("bob@example.com"),
+		message: "Update input in",
+	}); err != nil || commit != "" {
+		t.Errorf("commitBack without changes = %q, %v, expected no commit", commit, err)
+	}
+}
@@ -3,14 +3,20 @@ package input
 import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/tracing"
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,6 +28,9 @@ const (
 	InputTypeKafkaAzure InputType = "kafka_azure"
 	InputTypeKafkaAWS   InputType = "kafka_aws"
 	InputTypeAliyunSLS  InputType = "aliyun_sls"
+	InputTypeFile       InputType = "file"
+	InputTypeSyslog     InputType = "syslog"
+	InputTypeHTTP       InputType = "http"
 )
 
 // InputConfig is the YAML config for an input.
@@ -30,6 +39,9 @@ type InputConfig struct {
 	Type      InputType             `yaml:"type"`
 	Kafka     *KafkaInputConfig     `yaml:"kafka,omitempty"`
 	AliyunSLS *AliyunSLSInputConfig `yaml:"aliyun_sls,omitempty"`
+	File      *FileInputConfig      `yaml:"file,omitempty"`
+	Syslog    *SyslogInputConfig    `yaml:"syslog,omitempty"`
+	HTTP      *HTTPInputConfig      `yaml:"http,omitempty"`
 	RawConfig string
 }
 
@@ -58,6 +70,31 @@ type AliyunSLSInputConfig struct {
 	Query             string `yaml:"query,omitempty"`             // Optional query for filtering logs
 }
 
+// FileInputConfig holds file tailing config.
+type FileInputConfig struct {
+	Paths         []string `yaml:"paths"`                    // file paths or glob patterns
+	Format        string   `yaml:"format,omitempty"`         // json (default) or raw
+	StartPosition string   `yaml:"start_position,omitempty"` // beginning or end (default), only for files without a saved offset
+	OffsetFile    string   `yaml:"offset_file,omitempty"`    // where read offsets are persisted
+	PollInterval  string   `yaml:"poll_interval,omitempty"`  // e.g. 1s (default), 500ms
+}
+
+// SyslogInputConfig holds syslog listener config.
+type SyslogInputConfig struct {
+	Protocol       string                  `yaml:"protocol"`                   // udp, tcp or tls
+	Address        string                  `yaml:"address"`                    // listen address, e.g. 0.0.0.0:514
+	Format         string                  `yaml:"format,omitempty"`           // auto (default), rfc3164 or rfc5424
+	MaxConnections int                     `yaml:"max_connections,omitempty"`  // tcp/tls only, default 1024
+	MaxMessageSize int                     `yaml:"max_message_size,omitempty"` // bytes, default 65536
+	TLS            *common.SyslogTLSConfig `yaml:"tls,omitempty"`
+}
+
+// HTTPInputConfig holds webhook push config, data is accepted at POST /ingest/<input id>.
+type HTTPInputConfig struct {
+	Auth        common.HTTPAuthConfig `yaml:"auth"`
+	MaxBodySize int64                 `yaml:"max_body_size,omitempty"` // bytes, default 10MB
+}
+
 // Input represents an input component that consumes data from external sources
 type Input struct {
 	Status              common.Status
@@ -72,6 +109,9 @@ type Input struct {
 	// runtime
 	kafkaConsumer *common.KafkaConsumer
 	slsConsumer   *common.AliyunSLSConsumer
+	fileConsumer  *common.FileConsumer
+	syslogServer  *common.SyslogServer
+	httpReceiver  *common.HTTPReceiver
 
 	// internal message channel for monitoring during shutdown
 	internalMsgChan chan map[string]interface{}
@@ -79,6 +119,9 @@ type Input struct {
 	// config cache
 	kafkaCfg     *KafkaInputConfig
 	aliyunSLSCfg *AliyunSLSInputConfig
+	fileCfg      *FileInputConfig
+	syslogCfg    *SyslogInputConfig
+	httpCfg      *HTTPInputConfig
 
 	consumeTotal      uint64
 	lastReportedTotal uint64 // For calculating increments in 10-second intervals
@@ -154,6 +197,66 @@ func Verify(path string, raw string) error {
 			return fmt.Errorf("missing required field 'aliyun_sls' for aliyunSLS input (line: unknown)")
 		}
 		// Add more AliyunSLS specific field validation
+	case InputTypeFile:
+		if cfg.File == nil {
+			return fmt.Errorf("missing required field 'file' for file input (line: unknown)")
+		}
+		if len(cfg.File.Paths) == 0 {
+			return fmt.Errorf("missing required field 'file.paths' for file input (line: unknown)")
+		}
+		for _, p := range cfg.File.Paths {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid path pattern '%s' for file input: %v (line: unknown)", p, err)
+			}
+		}
+		if cfg.File.Format != "" && cfg.File.Format != common.FileFormatJSON && cfg.File.Format != common.FileFormatRaw {
+			return fmt.Errorf("invalid value for 'file.format': %s (valid values: json, raw) (line: unknown)", cfg.File.Format)
+		}
+		if cfg.File.StartPosition != "" && cfg.File.StartPosition != common.FileStartBeginning && cfg.File.StartPosition != common.FileStartEnd {
+			return fmt.Errorf("invalid value for 'file.start_position': %s (valid values: beginning, end) (line: unknown)", cfg.File.StartPosition)
+		}
+		if cfg.File.PollInterval != "" {
+			if _, err := time.ParseDuration(cfg.File.PollInterval); err != nil {
+				return fmt.Errorf("invalid value for 'file.poll_interval': %s (line: unknown)", cfg.File.PollInterval)
+			}
+		}
+	case InputTypeSyslog:
+		if cfg.Syslog == nil {
+			return fmt.Errorf("missing required field 'syslog' for syslog input (line: unknown)")
+		}
+		if cfg.Syslog.Address == "" {
+			return fmt.Errorf("missing required field 'syslog.address' for syslog input (line: unknown)")
+		}
+		if _, _, err := net.SplitHostPort(cfg.Syslog.Address); err != nil {
+			return fmt.Errorf("invalid value for 'syslog.address': %s (line: unknown)", cfg.Syslog.Address)
+		}
+		switch cfg.Syslog.Protocol {
+		case common.SyslogProtocolUDP, common.SyslogProtocolTCP:
+		case common.SyslogProtocolTLS:
+			if cfg.Syslog.TLS == nil || cfg.Syslog.TLS.CertPath == "" || cfg.Syslog.TLS.KeyPath == "" {
+				return fmt.Errorf("missing required field 'syslog.tls.cert_path' or 'syslog.tls.key_path' for tls protocol (line: unknown)")
+			}
+		case "":
+			return fmt.Errorf("missing required field 'syslog.protocol' for syslog input (line: unknown)")
+		default:
+			return fmt.Errorf("invalid value for 'syslog.protocol': %s (valid values: udp, tcp, tls) (line: unknown)", cfg.Syslog.Protocol)
+		}
+		if cfg.Syslog.Format != "" && cfg.Syslog.Format != common.SyslogFormatAuto && cfg.Syslog.Format != common.SyslogFormatRFC3164 && cfg.Syslog.Format != common.SyslogFormatRFC5424 {
+			return fmt.Errorf("invalid value for 'syslog.format': %s (valid values: auto, rfc3164, rfc5424) (line: unknown)", cfg.Syslog.Format)
+		}
+		if cfg.Syslog.MaxConnections < 0 || cfg.Syslog.MaxMessageSize < 0 {
+			return fmt.Errorf("'syslog.max_connections' and 'syslog.max_message_size' must not be negative (line: unknown)")
+		}
+	case InputTypeHTTP:
+		if cfg.HTTP == nil {
+			return fmt.Errorf("missing required field 'http' for http input (line: unknown)")
+		}
+		if err := common.ValidateHTTPAuthConfig(&cfg.HTTP.Auth); err != nil {
+			return fmt.Errorf("invalid 'http.auth' for http input: %v (line: unknown)", err)
+		}
+		if cfg.HTTP.MaxBodySize < 0 {
+			return fmt.Errorf("'http.max_body_size' must not be negative (line: unknown)")
+		}
 	default:
 		return fmt.Errorf("unsupported input type: %s (line: unknown)", cfg.Type)
 	}
@@ -187,6 +290,9 @@ func NewInput(path string, raw string, id string) (*Input, error) {
 		kafkaCfg:            cfg.Kafka,
 		ProjectNodeSequence: "INPUT." + id,
 		aliyunSLSCfg:        cfg.AliyunSLS,
+		fileCfg:             cfg.File,
+		syslogCfg:           cfg.Syslog,
+		httpCfg:             cfg.HTTP,
 		Config:              &cfg,
 		sampler:             nil, // Will be set below based on cluster role
 		Status:              common.StatusStopped,
@@ -236,6 +342,21 @@ func (in *Input) cleanup() {
 		in.slsConsumer = nil
 	}
 
+	if in.fileConsumer != nil {
+		in.fileConsumer.Close()
+		in.fileConsumer = nil
+	}
+
+	if in.syslogServer != nil {
+		in.syslogServer.Close()
+		in.syslogServer = nil
+	}
+
+	if in.httpReceiver != nil {
+		in.httpReceiver.Close()
+		in.httpReceiver = nil
+	}
+
 	// Clear internal message channel reference
 	in.internalMsgChan = nil
 
@@ -309,50 +430,7 @@ func (in *Input) Start() error {
 		in.kafkaConsumer = cons
 		in.internalMsgChan = msgChan // Store reference for monitoring during shutdown only after successful creation
 
-		// Start consumer goroutine with proper management
-		in.wg.Add(1)
-		go func() {
-			defer in.wg.Done()
-			defer func() {
-				if r := recover(); r != nil {
-					logger.Error("Panic in kafka consumer goroutine", "input", in.Id, "panic", r)
-					// Set input status to error on panic
-					in.SetStatus(common.StatusError, fmt.Errorf("kafka consumer goroutine panic: %v", r))
-				}
-			}()
-
-			for {
-				select {
-				case <-in.stopChan:
-					logger.Info("Kafka consumer goroutine stopping", "input", in.Id)
-					return
-				case msg, ok := <-msgChan:
-					if !ok {
-						logger.Info("Kafka message channel closed", "input", in.Id)
-						return
-					}
-					// Only increment total count - QPS calculation removed
-					atomic.AddUint64(&in.consumeTotal, 1)
-
-					// Sample the message
-					if in.sampler != nil {
-						in.sampler.Sample(msg, in.ProjectNodeSequence)
-					}
-
-					// Add input ID to message data
-					if msg == nil {
-						msg = make(map[string]interface{})
-					}
-					msg["_hub_input"] = in.Id
-
-					// Forward to downstream with blocking sends to ensure no data loss
-					// If any downstream channel is full, this will block and prevent further consumption
-					for _, ch := range in.DownStream {
-						*ch <- msg
-					}
-				}
-			}
-		}()
+		in.consume("kafka", msgChan)
 
 	case InputTypeAliyunSLS:
 		if in.slsConsumer != nil {
@@ -387,50 +465,94 @@ func (in *Input) Start() error {
 
 		cons.Start()
 
-		// Start consumer goroutine with proper management
-		in.wg.Add(1)
-		go func() {
-			defer in.wg.Done()
-			defer func() {
-				if r := recover(); r != nil {
-					logger.Error("Panic in sls consumer goroutine", "input", in.Id, "panic", r)
-					// Set input status to error on panic
-					in.SetStatus(common.StatusError, fmt.Errorf("sls consumer goroutine panic: %v", r))
-				}
-			}()
+		in.consume("sls", msgChan)
 
-			for {
-				select {
-				case <-in.stopChan:
-					logger.Info("SLS consumer goroutine stopping", "input", in.Id)
-					return
-				case msg, ok := <-msgChan:
-					if !ok {
-						logger.Info("SLS message channel closed", "input", in.Id)
-						return
-					}
+	case InputTypeFile:
+		if in.fileConsumer != nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("file consumer already running for input %s", in.Id))
+			return fmt.Errorf("file consumer already running for input %s", in.Id)
+		}
+		if in.fileCfg == nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("file configuration missing for input %s", in.Id))
+			return fmt.Errorf("file configuration missing for input %s", in.Id)
+		}
 
-					atomic.AddUint64(&in.consumeTotal, 1)
+		pollInterval := time.Second
+		if in.fileCfg.PollInterval != "" {
+			if d, err := time.ParseDuration(in.fileCfg.PollInterval); err == nil {
+				pollInterval = d
+			}
+		}
 
-					// Sample the message
-					if in.sampler != nil {
-						in.sampler.Sample(msg, in.ProjectNodeSequence)
-					}
+		offsetPath := in.fileOffsetPath()
 
-					// Add input ID to message data
-					if msg == nil {
-						msg = make(map[string]interface{})
-					}
-					msg["_hub_input"] = in.Id
+		msgChan := make(chan map[string]interface{}, 512)
+		cons, err := common.NewFileConsumer(
+			in.fileCfg.Paths,
+			in.fileCfg.Format,
+			in.fileCfg.StartPosition,
+			offsetPath,
+			pollInterval,
+			msgChan,
+		)
+		if err != nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("failed to create file consumer for input %s: %v", in.Id, err))
+			return fmt.Errorf("failed to create file consumer for input %s: %v", in.Id, err)
+		}
+		in.fileConsumer = cons
+		in.internalMsgChan = msgChan // Store reference for monitoring during shutdown only after successful creation
 
-					// Forward to downstream with blocking sends to ensure no data loss
-					// If any downstream channel is full, this will block and prevent further consumption
-					for _, ch := range in.DownStream {
-						*ch <- msg
-					}
-				}
-			}
-		}()
+		in.consume("file", msgChan)
+
+	case InputTypeSyslog:
+		if in.syslogServer != nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("syslog server already running for input %s", in.Id))
+			return fmt.Errorf("syslog server already running for input %s", in.Id)
+		}
+		if in.syslogCfg == nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("syslog configuration missing for input %s", in.Id))
+			return fmt.Errorf("syslog configuration missing for input %s", in.Id)
+		}
+
+		msgChan := make(chan map[string]interface{}, 512)
+		srv, err := common.NewSyslogServer(
+			in.syslogCfg.Protocol,
+			in.syslogCfg.Address,
+			in.syslogCfg.Format,
+			in.syslogCfg.MaxConnections,
+			in.syslogCfg.MaxMessageSize,
+			in.syslogCfg.TLS,
+			msgChan,
+		)
+		if err != nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("failed to create syslog server for input %s: %v", in.Id, err))
+			return fmt.Errorf("failed to create syslog server for input %s: %v", in.Id, err)
+		}
+		in.syslogServer = srv
+		in.internalMsgChan = msgChan // Store reference for monitoring during shutdown only after successful creation
+
+		in.consume("syslog", msgChan)
+
+	case InputTypeHTTP:
+		if in.httpReceiver != nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("http receiver already running for input %s", in.Id))
+			return fmt.Errorf("http receiver already running for input %s", in.Id)
+		}
+		if in.httpCfg == nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("http configuration missing for input %s", in.Id))
+			return fmt.Errorf("http configuration missing for input %s", in.Id)
+		}
+
+		msgChan := make(chan map[string]interface{}, 512)
+		recv, err := common.NewHTTPReceiver(in.Id, in.httpCfg.Auth, in.httpCfg.MaxBodySize, msgChan)
+		if err != nil {
+			in.SetStatus(common.StatusError, fmt.Errorf("failed to create http receiver for input %s: %v", in.Id, err))
+			return fmt.Errorf("failed to create http receiver for input %s: %v", in.Id, err)
+		}
+		in.httpReceiver = recv
+		in.internalMsgChan = msgChan // Store reference for monitoring during shutdown only after successful creation
+
+		in.consume("http", msgChan)
 
 	default:
 		in.SetStatus(common.StatusError, fmt.Errorf("unsupported input type %s", in.Type))
@@ -441,6 +563,71 @@ func (in *Input) Start() error {
 	return nil
 }
 
+// consume starts the goroutine that forwards the messages the consumer writes to msgChan downstream,
+// kind names the consumer in logs
+func (in *Input) consume(kind string, msgChan chan map[string]interface{}) {
+	stopChan := in.stopChan
+	in.wg.Add(1)
+	go func() {
+		defer in.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Panic in input consumer goroutine", "input", in.Id, "type", kind, "panic", r)
+				// Set input status to error on panic
+				in.SetStatus(common.StatusError, fmt.Errorf("%s consumer goroutine panic: %v", kind, r))
+			}
+		}()
+
+		for {
+			select {
+			case <-stopChan:
+				logger.Info("Input consumer goroutine stopping", "input", in.Id, "type", kind)
+				return
+			case msg, ok := <-msgChan:
+				if !ok {
+					logger.Info("Input message channel closed", "input", in.Id, "type", kind)
+					return
+				}
+
+				atomic.AddUint64(&in.consumeTotal, 1)
+
+				// Sample the message
+				if in.sampler != nil {
+					in.sampler.Sample(msg, in.ProjectNodeSequence)
+				}
+
+				// Add input ID to message data
+				if msg == nil {
+					msg = make(map[string]interface{})
+				}
+				msg["_hub_input"] = in.Id
+				span := in.startTrace(msg)
+
+				// Forward to downstream with blocking sends to ensure no data loss
+				// If any downstream channel is full, this will block and push back to the source
+				for _, ch := range in.DownStream {
+					*ch <- msg
+				}
+				span.End()
+			}
+		}
+	}()
+}
+
+// startTrace starts the receive span of a message before it is forwarded, see tracing.StartInput
+func (in *Input) startTrace(msg map[string]interface{}) trace.Span {
+	span := tracing.StartInput(msg, "input")
+	if span.IsRecording() {
+		span.SetName("input " + in.Id)
+		span.SetAttributes(
+			attribute.String("hub.input.id", in.Id),
+			attribute.String("hub.input.type", string(in.Type)),
+			attribute.String("hub.project_node_sequence", in.ProjectNodeSequence),
+		)
+	}
+	return span
+}
+
 // StartForTesting starts the input component in testing mode
 // This version initializes basic infrastructure but doesn't connect to external data sources
 func (in *Input) StartForTesting() error {
@@ -532,6 +719,18 @@ func (in *Input) Stop() error {
 		}
 		in.slsConsumer = nil
 	}
+	if in.fileConsumer != nil {
+		in.fileConsumer.Close()
+		in.fileConsumer = nil
+	}
+	if in.syslogServer != nil {
+		in.syslogServer.Close()
+		in.syslogServer = nil
+	}
+	if in.httpReceiver != nil {
+		in.httpReceiver.Close()
+		in.httpReceiver = nil
+	}
 
 	// Step 2: Signal goroutines to stop consuming from internal channel
 	// This prevents them from processing more messages while we wait for drain
@@ -650,6 +849,15 @@ func (in *Input) GetIncrementAndUpdate() uint64 {
 	return 0
 }
 
+// KafkaLag returns the consumer group lag of a running kafka input, nil for other inputs
+func (in *Input) KafkaLag(ctx context.Context) ([]common.KafkaPartitionLag, error) {
+	cons := in.kafkaConsumer
+	if cons == nil {
+		return nil, nil
+	}
+	return cons.Lag(ctx)
+}
+
 // CheckConnectivity performs a real connectivity test for the input component
 // This method tests actual connection to external systems (Kafka, SLS, etc.)
 func (in *Input) CheckConnectivity() map[string]interface{} {
@@ -837,6 +1045,125 @@ func (in *Input) CheckConnectivity() map[string]interface{} {
 			}
 		}
 
+	case InputTypeFile:
+		if in.fileCfg == nil {
+			result["status"] = "error"
+			result["message"] = "File configuration missing"
+			result["details"].(map[string]interface{})["connection_status"] = "not_configured"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": "File configuration is incomplete or missing", "severity": "error"},
+			}
+			return result
+		}
+
+		result["details"].(map[string]interface{})["connection_info"] = map[string]interface{}{
+			"paths":       in.fileCfg.Paths,
+			"offset_file": in.fileOffsetPath(),
+		}
+
+		matched, err := common.TestFilePaths(in.fileCfg.Paths)
+		if err != nil {
+			result["status"] = "error"
+			result["message"] = "Invalid file path pattern"
+			result["details"].(map[string]interface{})["connection_status"] = "connection_failed"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": err.Error(), "severity": "error"},
+			}
+			return result
+		}
+
+		if matched == 0 {
+			// Files may be created later, the consumer picks them up on the next poll
+			result["status"] = "warning"
+			result["message"] = "No files currently match the configured paths"
+			result["details"].(map[string]interface{})["connection_status"] = "no_files"
+			result["details"].(map[string]interface{})["connection_warnings"] = []map[string]interface{}{
+				{"message": "No files currently match, they will be picked up once created", "severity": "warning"},
+			}
+		} else {
+			result["details"].(map[string]interface{})["connection_status"] = "connected"
+			result["message"] = fmt.Sprintf("Found %d matching file(s)", matched)
+		}
+
+		result["details"].(map[string]interface{})["metrics"] = map[string]interface{}{
+			"matched_files":   matched,
+			"consume_total":   in.GetConsumeTotal(),
+			"consumer_active": in.fileConsumer != nil,
+		}
+
+	case InputTypeSyslog:
+		if in.syslogCfg == nil {
+			result["status"] = "error"
+			result["message"] = "Syslog configuration missing"
+			result["details"].(map[string]interface{})["connection_status"] = "not_configured"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": "Syslog configuration is incomplete or missing", "severity": "error"},
+			}
+			return result
+		}
+
+		result["details"].(map[string]interface{})["connection_info"] = map[string]interface{}{
+			"protocol": in.syslogCfg.Protocol,
+			"address":  in.syslogCfg.Address,
+			"format":   in.syslogCfg.Format,
+		}
+
+		if in.syslogServer != nil {
+			// Already listening, the address is ours
+			result["details"].(map[string]interface{})["connection_status"] = "listening"
+			result["message"] = "Syslog server is listening"
+			result["details"].(map[string]interface{})["metrics"] = map[string]interface{}{
+				"consume_total":      in.GetConsumeTotal(),
+				"active_connections": in.syslogServer.ActiveConnections(),
+				"consumer_active":    true,
+			}
+			return result
+		}
+
+		if err := common.TestSyslogAddress(in.syslogCfg.Protocol, in.syslogCfg.Address); err != nil {
+			result["status"] = "error"
+			result["message"] = "Failed to bind syslog listen address"
+			result["details"].(map[string]interface{})["connection_status"] = "bind_failed"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": err.Error(), "severity": "error"},
+			}
+			return result
+		}
+
+		result["details"].(map[string]interface{})["connection_status"] = "available"
+		result["message"] = "Syslog listen address is available"
+		result["details"].(map[string]interface{})["metrics"] = map[string]interface{}{
+			"consumer_active": false,
+		}
+
+	case InputTypeHTTP:
+		if in.httpCfg == nil {
+			result["status"] = "error"
+			result["message"] = "HTTP configuration missing"
+			result["details"].(map[string]interface{})["connection_status"] = "not_configured"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": "HTTP configuration is incomplete or missing", "severity": "error"},
+			}
+			return result
+		}
+
+		// Nothing to dial, data is pushed to the hub API
+		result["details"].(map[string]interface{})["connection_info"] = map[string]interface{}{
+			"endpoint":  "/ingest/" + in.Id,
+			"auth_type": in.httpCfg.Auth.Type,
+		}
+		if in.httpReceiver != nil {
+			result["details"].(map[string]interface{})["connection_status"] = "listening"
+			result["message"] = "HTTP endpoint is accepting data"
+		} else {
+			result["details"].(map[string]interface{})["connection_status"] = "available"
+			result["message"] = "HTTP endpoint will accept data once the input is running"
+		}
+		result["details"].(map[string]interface{})["metrics"] = map[string]interface{}{
+			"consume_total":   in.GetConsumeTotal(),
+			"consumer_active": in.httpReceiver != nil,
+		}
+
 	default:
 		result["status"] = "error"
 		result["message"] = "Unsupported input type"
@@ -868,9 +1195,12 @@ func NewFromExisting(existing *Input, newProjectNodeSequence string) (*Input, er
 		DownStream:          make(map[string]*chan map[string]interface{}, 0),
 		kafkaCfg:            existing.kafkaCfg,
 		aliyunSLSCfg:        existing.aliyunSLSCfg,
+		fileCfg:             existing.fileCfg,
+		syslogCfg:           existing.syslogCfg,
+		httpCfg:             existing.httpCfg,
 		Config:              existing.Config,
 		Status:              common.StatusStopped,
-		// Note: Runtime fields (kafkaConsumer, slsConsumer, wg, stopChan) are intentionally not copied
+		// Note: Runtime fields (kafkaConsumer, slsConsumer, fileConsumer, syslogServer, httpReceiver, wg, stopChan) are intentionally not copied
 		// as they will be initialized when the input starts
 		// Metrics fields (consumeTotal) are also not copied as they are instance-specific
 	}
@@ -883,6 +1213,15 @@ func NewFromExisting(existing *Input, newProjectNodeSequence string) (*Input, er
 	return newInput, nil
 }
 
+// fileOffsetPath returns where the file input persists its read offsets
+// Defaults to <data_dir>/offsets/<input id>.json when offset_file is not set
+func (in *Input) fileOffsetPath() string {
+	if in.fileCfg != nil && in.fileCfg.OffsetFile != "" {
+		return in.fileCfg.OffsetFile
+	}
+	return filepath.Join(common.GetDataDir(), "offsets", in.Id+".json")
+}
+
 // SetTestMode configures the input for test mode by disabling sampling and other global state interactions
 func (in *Input) SetTestMode() {
 	in.sampler = nil // Disable sampling for test instances
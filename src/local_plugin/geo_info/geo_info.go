@@ -0,0 +1,30 @@
+package geo_info
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/geoip"
+	"errors"
+)
+
+// Eval returns the geo and ASN context of an IP from the databases configured under geoip in config.yaml:
+// country, country_code, continent, region, city, latitude, longitude, location, timezone, asn and as_org.
+// Args: ip string, language (optional, default en). Nothing is appended for IPs without data (e.g. private IPs).
+func Eval(args ...interface{}) (interface{}, bool, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, false, errors.New("geoInfo requires 1 or 2 arguments: ip, language (optional)")
+	}
+	ipStr, ok := args[0].(string)
+	if !ok {
+		return nil, false, errors.New("ip must be a string")
+	}
+	lang := ""
+	if len(args) == 2 {
+		lang = common.AnyToString(args[1])
+	}
+	res, ok, err := geoip.Lookup(ipStr, lang)
+	if err != nil || !ok {
+		// A nil map would not be a nil interface{}
+		return nil, false, err
+	}
+	return res, true, nil
+}
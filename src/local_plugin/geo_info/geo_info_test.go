@@ -0,0 +1,29 @@
+package geo_info
+
+import (
+	"AgentSmith-HUB/geoip"
+	"testing"
+)
+
+func TestEvalArguments(t *testing.T) {
+	if geoip.Loaded() {
+		t.Skip("a geoip database is loaded")
+	}
+
+	tests := []struct {
+		args []interface{}
+		err  string
+	}{
+		{[]interface{}{}, "geoInfo requires 1 or 2 arguments: ip, language (optional)"},
+		{[]interface{}{"1.1.1.1", "en", "x"}, "geoInfo requires 1 or 2 arguments: ip, language (optional)"},
+		{[]interface{}{42}, "ip must be a string"},
+		{[]interface{}{"1.1.1.1", "de"}, "no geoip database loaded"},
+	}
+
+	for _, test := range tests {
+		result, ok, err := Eval(test.args...)
+		if err == nil || err.Error() != test.err || ok || result != nil {
+			t.Errorf("Eval(%v) = %v, %v, %v, expected error %q", test.args, result, ok, err, test.err)
+		}
+	}
+}
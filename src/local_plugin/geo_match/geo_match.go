@@ -1,6 +1,7 @@
 package geo_match
 
 import (
+	"AgentSmith-HUB/geoip"
 	"errors"
 	"net"
 	"os"
@@ -39,6 +40,15 @@ func Eval(args ...interface{}) (bool, error) {
 		return false, nil
 	}
 
+	// Prefer the database configured under geoip in config.yaml (hot reloaded, shared by the leader)
+	if geoip.Loaded() {
+		iso, err := geoip.CountryISO(ipStr)
+		if err != nil {
+			return false, err
+		}
+		return iso != "" && strings.EqualFold(iso, want), nil
+	}
+
 	if dbErr != nil {
 		return false, dbErr
 	}
@@ -0,0 +1,15 @@
+package ioc_info
+
+import (
+	"AgentSmith-HUB/threat_intel"
+)
+
+// Eval returns the matching threat intel indicator as a map: value, type, feed, confidence, expires and tags.
+// Args: same as isIOC. Nothing is appended when the value is not an indicator.
+func Eval(args ...interface{}) (interface{}, bool, error) {
+	ind, err := threat_intel.MatchArgs("iocInfo", args...)
+	if err != nil || ind == nil {
+		return nil, false, err
+	}
+	return ind.Info(), true, nil
+}
@@ -0,0 +1,63 @@
+package ioc_info
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/threat_intel"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	hash := strings.Repeat("0f", 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s\n", strings.ToUpper(hash))
+	}))
+	defer srv.Close()
+	if err := threat_intel.Start([]common.ThreatFeedConfig{{Name: "hashes", Type: threat_intel.FeedList, URL: srv.URL, IOCType: "md5", Confidence: 85, TTL: "1d", Tags: []string{"ransomware"}}}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer threat_intel.Stop()
+	if _, err := threat_intel.Refresh("hashes"); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	result, ok, err := Eval(hash)
+	if err != nil || !ok {
+		t.Fatalf("Eval(%q) = %v, %v, %v, expected an indicator", hash, result, ok, err)
+	}
+	info := result.(map[string]interface{})
+	for field, expected := range map[string]interface{}{
+		"value":      hash,
+		"type":       threat_intel.TypeHash,
+		"feed":       "hashes",
+		"confidence": 85,
+		"tags":       []interface{}{"ransomware"},
+	} {
+		if !reflect.DeepEqual(info[field], expected) {
+			t.Errorf("%s = %#v, expected %#v", field, info[field], expected)
+		}
+	}
+	if _, ok := info["expires"].(string); !ok {
+		t.Errorf("expires = %#v, expected an RFC 3339 time", info["expires"])
+	}
+
+	tests := []struct {
+		args    []interface{}
+		wantErr bool
+	}{
+		{[]interface{}{strings.Repeat("1", 32)}, false},
+		{[]interface{}{hash, "hash", 90}, false},
+		{[]interface{}{"not a hash", "hash"}, false},
+		{[]interface{}{hash, "registry-key"}, true},
+	}
+	for _, test := range tests {
+		result, ok, err := Eval(test.args...)
+		if (err != nil) != test.wantErr || ok || result != nil {
+			t.Errorf("Eval(%v) = %v, %v, %v, expected nothing appended (error %v)", test.args, result, ok, err, test.wantErr)
+		}
+	}
+}
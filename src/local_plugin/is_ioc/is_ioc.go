@@ -0,0 +1,16 @@
+package is_ioc
+
+import (
+	"AgentSmith-HUB/threat_intel"
+)
+
+// Eval returns true if the value is an indicator of a configured threat intel feed.
+// Args: value, type (ip/domain/url/hash, detected from the value when empty), min confidence (optional).
+// Domains also match indicators of their parent domains, IPs match network indicators.
+func Eval(args ...interface{}) (bool, error) {
+	ind, err := threat_intel.MatchArgs("isIOC", args...)
+	if err != nil {
+		return false, err
+	}
+	return ind != nil, nil
+}
@@ -0,0 +1,56 @@
+package is_ioc
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/threat_intel"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "203.0.113.0/24\nevil.example\n")
+	}))
+	defer srv.Close()
+	if err := threat_intel.Start([]common.ThreatFeedConfig{{Name: "blocklist", Type: threat_intel.FeedList, URL: srv.URL, Confidence: 70}}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer threat_intel.Stop()
+	if _, err := threat_intel.Refresh("blocklist"); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	tests := []struct {
+		args     []interface{}
+		expected bool
+		wantErr  bool
+	}{
+		{[]interface{}{"203.0.113.50"}, true, false},
+		{[]interface{}{"203.0.114.50"}, false, false},
+		{[]interface{}{"cdn.evil.example", "domain"}, true, false},
+		{[]interface{}{"evil.example", "domain", 70}, true, false},
+		{[]interface{}{"evil.example", "domain", 71}, false, false},
+		{[]interface{}{"evil.example", "ip"}, false, false},
+		{[]interface{}{nil}, false, false},
+		{[]interface{}{"evil.example", "mutex"}, false, true},
+		{[]interface{}{}, false, true},
+	}
+
+	for _, test := range tests {
+		result, err := Eval(test.args...)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Eval(%v) error = %v, expected error %v", test.args, err, test.wantErr)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Eval(%v) = %v, expected %v", test.args, result, test.expected)
+		}
+	}
+
+	hits, misses := threat_intel.Stats()
+	if hits == 0 || misses == 0 {
+		t.Errorf("Stats() = %d hits, %d misses, expected both to be counted", hits, misses)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	etld "AgentSmith-HUB/local_plugin/url/extract_tld"
 
 	// geo
+	"AgentSmith-HUB/local_plugin/geo_info"
 	"AgentSmith-HUB/local_plugin/geo_match"
 
 	// user agent
@@ -40,6 +41,8 @@ import (
 	suppressonce "AgentSmith-HUB/local_plugin/suppress_once"
 
 	// threat intelligence
+	iocinfo "AgentSmith-HUB/local_plugin/ioc_info"
+	isioc "AgentSmith-HUB/local_plugin/is_ioc"
 	shodan "AgentSmith-HUB/local_plugin/shodan"
 	threatbook "AgentSmith-HUB/local_plugin/threatbook"
 	virustotal "AgentSmith-HUB/local_plugin/virustotal"
@@ -51,6 +54,7 @@ var LocalPluginBoolRes = map[string]func(...interface{}) (bool, error){
 	"cidrMatch":    cidr_match.Eval,
 	"geoMatch":     geo_match.Eval,
 	"suppressOnce": suppressonce.Eval,
+	"isIOC":        isioc.Eval,
 }
 
 // for append or other usage
@@ -75,6 +79,9 @@ var LocalPluginInterfaceAndBoolRes = map[string]func(...interface{}) (interface{
 	"extractTLD":       etld.Eval,
 	"extractSubdomain": esub.Eval,
 
+	// geo
+	"geoInfo": geo_info.Eval,
+
 	// user agent
 	"parseUA": pua.Eval,
 
@@ -89,13 +96,15 @@ var LocalPluginInterfaceAndBoolRes = map[string]func(...interface{}) (interface{
 	"virusTotal": virustotal.Eval,
 	"shodan":     shodan.Eval,
 	"threatBook": threatbook.Eval,
+	"iocInfo":    iocinfo.Eval,
 }
 
 var LocalPluginDesc = map[string]string{
 	// check node
 	"isPrivateIP":  "Check node: true if IP is private RFC1918/loopback/link-local. Args: ip string.",
 	"cidrMatch":    "Check node: true if IP within CIDR. Args: ip string, cidr string.",
-	"geoMatch":     "Check node: true if IP country ISO matches expected. Args: ip, countryISO.",
+	"geoMatch":     "Check node: true if IP country ISO matches expected (geoip city database of config.yaml, or GEOIP_DB). Args: ip, countryISO.",
+	"isIOC":        "Check node: true if value is an indicator of a configured threat intel feed (see threat_intel in config.yaml). Args: value, type (ip/domain/url/hash, optional - detected from value), minConfidence int (optional).",
 	"suppressOnce": "Check node: alert suppression. Args: key(any), windowSec, ruleid(optional). Returns true only first time within window. Use ruleid to isolate different rules.",
 
 	// time append
@@ -116,6 +125,9 @@ var LocalPluginDesc = map[string]string{
 	"extractTLD":       "Append: extract TLD from domain. Args: domain string.",
 	"extractSubdomain": "Append: extract subdomain from host. Args: host string.",
 
+	// geo append
+	"geoInfo": "Append: GeoIP/ASN context of IP as map (country, country_code, region, city, latitude, longitude, timezone, asn, as_org) from geoip databases in config.yaml. Args: ip string, language (optional, default en).",
+
 	// ua
 	"parseUA": "Append: parse user agent to map. Args: ua string.",
 
@@ -133,4 +145,5 @@ var LocalPluginDesc = map[string]string{
 	"virusTotal": "Append: query VirusTotal for file hash reputation. Returns detection info with caching. Args: hash string (MD5/SHA1/SHA256), apiKey string (optional - fallback to VIRUSTOTAL_API_KEY env var).",
 	"shodan":     "Append: query Shodan for IP address infrastructure info. Returns host details with caching. Args: ip string (IPv4/IPv6), apiKey string (optional - fallback to SHODAN_API_KEY env var).",
 	"threatBook": "Append: query ThreatBook (微步在线) for threat intelligence. Returns comprehensive threat info with caching. Args: queryValue string, queryType string (ip/domain/file/url), apiKey string (optional - fallback to THREATBOOK_API_KEY env var).",
+	"iocInfo":    "Append: matching threat intel feed indicator as map (value, type, feed, confidence, expires, tags), nothing appended if not an IOC. Args: value, type (optional), minConfidence int (optional).",
 }
@@ -0,0 +1,530 @@
+package lookup
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A lookup is a table (watchlist) that rulesets match event fields against:
+//
+//	format: csv                # csv or json
+//	key: ip                    # key column, defaults to the first csv column
+//	match: cidr                # exact (default) or cidr
+//	case_insensitive: false    # exact matching ignores case
+//	content: |
+//	  ip,owner,env
+//	  10.0.0.0/8,netops,prod
+//
+// json content is an array of objects, or an array of strings for a plain watchlist.
+// Rulesets resolve lookups by name on every event, so applying a new version of a lookup
+// takes effect immediately and projects do not need to be restarted.
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+
+	MatchExact = "exact"
+	MatchCIDR  = "cidr"
+)
+
+// LookupConfig is the YAML config of a lookup
+type LookupConfig struct {
+	Format          string `yaml:"format"`
+	Key             string `yaml:"key,omitempty"`
+	Match           string `yaml:"match,omitempty"`
+	CaseInsensitive bool   `yaml:"case_insensitive,omitempty"`
+	Content         string `yaml:"content"`
+	RawConfig       string `yaml:"-"`
+}
+
+type Lookup struct {
+	Id     string
+	Path   string
+	Config *LookupConfig
+
+	// Status and error handling (consistent with other components)
+	Status common.Status `json:"status"`
+	Err    error         `json:"-"`
+
+	table *table
+
+	hitTotal  uint64
+	missTotal uint64
+}
+
+// table is the parsed content of a lookup, read only once built
+type table struct {
+	columns         []string
+	caseInsensitive bool
+
+	exact map[string]map[string]interface{}
+
+	// cidr rows grouped by prefix length, longest first, so the most specific network wins
+	prefixBits []int
+	prefixes   map[int]map[netip.Prefix]map[string]interface{}
+
+	rows int
+}
+
+var Lookups = make(map[string]*Lookup)
+var LookupsNew = make(map[string]string)
+var LookupsMu sync.RWMutex
+
+func parseConfig(data []byte) (*LookupConfig, error) {
+	var cfg LookupConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse lookup configuration: %w", err)
+	}
+	cfg.Format = strings.ToLower(strings.TrimSpace(cfg.Format))
+	cfg.Match = strings.ToLower(strings.TrimSpace(cfg.Match))
+	cfg.Key = strings.TrimSpace(cfg.Key)
+	if cfg.Match == "" {
+		cfg.Match = MatchExact
+	}
+	cfg.RawConfig = string(data)
+	return &cfg, nil
+}
+
+// Verify checks a lookup config and its content
+func Verify(path string, raw string) error {
+	data, err := common.ReadContentFromPathOrRaw(path, raw)
+	if err != nil {
+		return fmt.Errorf("failed to read lookup configuration: %w", err)
+	}
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return err
+	}
+	_, err = buildTable(cfg)
+	return err
+}
+
+// NewLookup builds a lookup and registers it, replacing the previous version of the same id
+func NewLookup(path string, raw string, id string) (*Lookup, error) {
+	var data []byte
+	var err error
+	if path != "" {
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("lookup read error: %s %w", id, err)
+		}
+	} else {
+		data = []byte(raw)
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("lookup verify error: %s %s", id, err.Error())
+	}
+	t, err := buildTable(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("lookup verify error: %s %s", id, err.Error())
+	}
+
+	l := &Lookup{
+		Id:     id,
+		Path:   path,
+		Config: cfg,
+		Status: common.StatusRunning,
+		table:  t,
+	}
+
+	LookupsMu.Lock()
+	Lookups[id] = l
+	LookupsMu.Unlock()
+
+	logger.Info("Lookup loaded", "id", id, "rows", t.rows, "match", cfg.Match)
+	return l, nil
+}
+
+func buildTable(cfg *LookupConfig) (*table, error) {
+	if cfg.Match != MatchExact && cfg.Match != MatchCIDR {
+		return nil, fmt.Errorf("lookup match must be 'exact' or 'cidr', got '%s'", cfg.Match)
+	}
+	if cfg.Match == MatchCIDR && cfg.CaseInsensitive {
+		return nil, fmt.Errorf("lookup case_insensitive cannot be used with match 'cidr'")
+	}
+
+	var columns []string
+	var rows []map[string]interface{}
+	var err error
+	switch cfg.Format {
+	case FormatCSV:
+		columns, rows, err = parseCSV(cfg.Content, cfg.Key)
+	case FormatJSON:
+		columns, rows, err = parseJSON(cfg.Content, cfg.Key)
+	case "":
+		return nil, fmt.Errorf("missing required field 'format'")
+	default:
+		return nil, fmt.Errorf("lookup format must be 'csv' or 'json', got '%s'", cfg.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := cfg.Key
+	if key == "" {
+		key = columns[0]
+	}
+
+	t := &table{
+		columns:         columns,
+		caseInsensitive: cfg.CaseInsensitive,
+		exact:           make(map[string]map[string]interface{}, len(rows)),
+		prefixes:        make(map[int]map[netip.Prefix]map[string]interface{}),
+	}
+
+	for i, row := range rows {
+		keyValue := strings.TrimSpace(common.AnyToString(row[key]))
+		if row[key] == nil || keyValue == "" {
+			return nil, fmt.Errorf("lookup row %d has an empty key '%s'", i+1, key)
+		}
+
+		if cfg.Match == MatchCIDR {
+			prefix, err := parsePrefix(keyValue)
+			if err != nil {
+				return nil, fmt.Errorf("lookup row %d: %w", i+1, err)
+			}
+			bits := prefix.Bits()
+			if _, ok := t.prefixes[bits]; !ok {
+				t.prefixes[bits] = make(map[netip.Prefix]map[string]interface{})
+				t.prefixBits = append(t.prefixBits, bits)
+			}
+			t.prefixes[bits][prefix] = row
+		} else {
+			if cfg.CaseInsensitive {
+				keyValue = strings.ToLower(keyValue)
+			}
+			t.exact[keyValue] = row
+		}
+		t.rows++
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(t.prefixBits)))
+
+	return t, nil
+}
+
+func parseCSV(content, key string) ([]string, []map[string]interface{}, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimSpace(content)))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = 0
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("lookup csv content is empty, the first line must be the header")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookup csv parse error: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, h := range header {
+		columns[i] = strings.TrimSpace(h)
+		if columns[i] == "" {
+			return nil, nil, fmt.Errorf("lookup csv header column %d is empty", i+1)
+		}
+	}
+	if key != "" && !containsColumn(columns, key) {
+		return nil, nil, fmt.Errorf("lookup key column '%s' is not in the csv header", key)
+	}
+
+	var rows []map[string]interface{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("lookup csv parse error: %w", err)
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[col] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+func parseJSON(content, key string) ([]string, []map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.UseNumber()
+
+	var items []interface{}
+	if err := decoder.Decode(&items); err != nil {
+		return nil, nil, fmt.Errorf("lookup json content must be an array: %w", err)
+	}
+
+	var rows []map[string]interface{}
+	columnSet := make(map[string]bool)
+	var columns []string
+	for i, item := range items {
+		switch v := item.(type) {
+		case map[string]interface{}:
+			if key == "" {
+				return nil, nil, fmt.Errorf("lookup 'key' is required for json objects")
+			}
+			rows = append(rows, v)
+			for col := range v {
+				if !columnSet[col] {
+					columnSet[col] = true
+					columns = append(columns, col)
+				}
+			}
+		case string, json.Number, bool:
+			// Plain watchlist entry, the key column holds the value itself
+			col := key
+			if col == "" {
+				col = "value"
+			}
+			if !columnSet[col] {
+				columnSet[col] = true
+				columns = append(columns, col)
+			}
+			rows = append(rows, map[string]interface{}{col: v})
+		default:
+			return nil, nil, fmt.Errorf("lookup json item %d must be an object or a string", i+1)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("lookup json content is empty")
+	}
+	sort.Strings(columns)
+	if key != "" && !columnSet[key] {
+		return nil, nil, fmt.Errorf("lookup key column '%s' is not in any json item", key)
+	}
+	if key == "" {
+		key = columns[0]
+	}
+	// Keep the key column first, like in csv
+	for i, col := range columns {
+		if col == key {
+			columns = append([]string{key}, append(columns[:i:i], columns[i+1:]...)...)
+			break
+		}
+	}
+	return columns, rows, nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network '%s'", value)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid ip '%s'", value)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func containsColumn(columns []string, col string) bool {
+	for _, c := range columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the row matching value
+func (t *table) find(value string) (map[string]interface{}, bool) {
+	value = strings.TrimSpace(value)
+	if len(t.prefixBits) == 0 {
+		if t.caseInsensitive {
+			value = strings.ToLower(value)
+		}
+		row, ok := t.exact[value]
+		return row, ok
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil, false
+	}
+	addr = addr.Unmap()
+	for _, bits := range t.prefixBits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if row, ok := t.prefixes[bits][prefix]; ok {
+			return row, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns a registered lookup
+func Get(id string) (*Lookup, bool) {
+	LookupsMu.RLock()
+	defer LookupsMu.RUnlock()
+	l, ok := Lookups[id]
+	return l, ok
+}
+
+// Exists reports whether a lookup is registered, for ruleset validation
+func Exists(id string) bool {
+	_, ok := Get(id)
+	return ok
+}
+
+// IsTemporary reports whether a lookup only exists as a pending change
+func IsTemporary(id string) bool {
+	common.GlobalMu.RLock()
+	defer common.GlobalMu.RUnlock()
+	_, ok := LookupsNew[id]
+	return ok
+}
+
+// Contains reports whether value is in the lookup
+func (l *Lookup) Contains(value string) bool {
+	_, ok := l.Row(value)
+	return ok
+}
+
+// Row returns the row matching value, the row is shared and must not be modified
+func (l *Lookup) Row(value string) (map[string]interface{}, bool) {
+	if l.table == nil {
+		return nil, false
+	}
+	row, ok := l.table.find(value)
+	if ok {
+		atomic.AddUint64(&l.hitTotal, 1)
+	} else {
+		atomic.AddUint64(&l.missTotal, 1)
+	}
+	return row, ok
+}
+
+// Columns returns the columns of the lookup, the key column first
+func (l *Lookup) Columns() []string {
+	if l.table == nil {
+		return nil
+	}
+	return l.table.columns
+}
+
+// RowCount returns the number of rows in the lookup
+func (l *Lookup) RowCount() int {
+	if l.table == nil {
+		return 0
+	}
+	return l.table.rows
+}
+
+// Stats returns the hit and miss counters of the lookup on this node
+func (l *Lookup) Stats() (hits uint64, misses uint64) {
+	return atomic.LoadUint64(&l.hitTotal), atomic.LoadUint64(&l.missTotal)
+}
+
+// ContainsAny reports whether value is in the lookup, for arrays any element may match
+func ContainsAny(id string, value interface{}) (bool, string) {
+	l, ok := Get(id)
+	if !ok {
+		return false, ""
+	}
+	if list, isList := value.([]interface{}); isList {
+		for _, v := range list {
+			s := common.AnyToString(v)
+			if l.Contains(s) {
+				return true, s
+			}
+		}
+		return false, ""
+	}
+	s := common.AnyToString(value)
+	if l.Contains(s) {
+		return true, s
+	}
+	return false, ""
+}
+
+// SafeDeleteLookup deletes a lookup, rules referencing it stop matching until it is added again
+func SafeDeleteLookup(id string) ([]string, error) {
+	common.GlobalMu.Lock()
+	defer common.GlobalMu.Unlock()
+
+	LookupsMu.Lock()
+	_, exists := Lookups[id]
+	delete(Lookups, id)
+	LookupsMu.Unlock()
+
+	if !exists {
+		if _, tempExists := LookupsNew[id]; !tempExists {
+			return nil, fmt.Errorf("lookup not found: %s", id)
+		}
+	}
+
+	delete(LookupsNew, id)
+	common.DeleteRawConfigUnsafe("lookup", id)
+
+	// Lookups are resolved by name on every event, no project needs to restart
+	return []string{}, nil
+}
+
+// Safe accessor functions for LookupsNew map
+func SetLookupNew(id, content string) {
+	common.GlobalMu.Lock()
+	defer common.GlobalMu.Unlock()
+	LookupsNew[id] = content
+}
+
+func GetLookupNew(id string) (string, bool) {
+	common.GlobalMu.RLock()
+	defer common.GlobalMu.RUnlock()
+	content, ok := LookupsNew[id]
+	return content, ok
+}
+
+func DeleteLookupNew(id string) {
+	common.GlobalMu.Lock()
+	defer common.GlobalMu.Unlock()
+	delete(LookupsNew, id)
+}
+
+func GetAllLookupsNew() map[string]string {
+	common.GlobalMu.RLock()
+	defer common.GlobalMu.RUnlock()
+
+	result := make(map[string]string)
+	for id, content := range LookupsNew {
+		result[id] = content
+	}
+	return result
+}
+
+// ForEachLookup iterates over registered lookups
+func ForEachLookup(fn func(id string, l *Lookup) bool) {
+	LookupsMu.RLock()
+	list := make([]*Lookup, 0, len(Lookups))
+	for _, l := range Lookups {
+		list = append(list, l)
+	}
+	LookupsMu.RUnlock()
+
+	for _, l := range list {
+		if !fn(l.Id, l) {
+			return
+		}
+	}
+}
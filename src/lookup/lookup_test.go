@@ -0,0 +1,188 @@
+package lookup
+
+import (
+	"AgentSmith-HUB/common"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		value    string
+		expected map[string]string
+	}{
+		{
+			name:     "csv exact",
+			config:   "format: csv\ncontent: |\n  user,team\n  alice,secops\n  bob,netops\n",
+			value:    " bob ",
+			expected: map[string]string{"user": "bob", "team": "netops"},
+		},
+		{
+			name:   "csv exact is case sensitive",
+			config: "format: csv\ncontent: |\n  user,team\n  alice,secops\n",
+			value:  "Alice",
+		},
+		{
+			name:     "csv case insensitive",
+			config:   "format: csv\ncase_insensitive: true\ncontent: |\n  user,team\n  Alice,secops\n",
+			value:    "ALICE",
+			expected: map[string]string{"user": "Alice", "team": "secops"},
+		},
+		{
+			name:     "csv key column",
+			config:   "format: csv\nkey: host\ncontent: |\n  owner,host\n  ops,web-1\n",
+			value:    "web-1",
+			expected: map[string]string{"owner": "ops", "host": "web-1"},
+		},
+		{
+			name:     "cidr most specific network wins",
+			config:   "format: csv\nmatch: cidr\ncontent: |\n  net,zone\n  10.0.0.0/8,internal\n  10.1.0.0/16,dmz\n",
+			value:    "10.1.2.3",
+			expected: map[string]string{"net": "10.1.0.0/16", "zone": "dmz"},
+		},
+		{
+			name:     "cidr mapped ipv4",
+			config:   "format: csv\nmatch: cidr\ncontent: |\n  net,zone\n  10.0.0.0/8,internal\n",
+			value:    "::ffff:10.9.9.9",
+			expected: map[string]string{"net": "10.0.0.0/8", "zone": "internal"},
+		},
+		{
+			name:     "cidr single ip and ipv6",
+			config:   "format: csv\nmatch: cidr\ncontent: |\n  net,zone\n  192.168.1.10,printer\n  2001:db8::/32,lab\n",
+			value:    "2001:db8::5",
+			expected: map[string]string{"net": "2001:db8::/32", "zone": "lab"},
+		},
+		{
+			name:   "cidr miss",
+			config: "format: csv\nmatch: cidr\ncontent: |\n  net,zone\n  192.168.1.10,printer\n",
+			value:  "192.168.1.11",
+		},
+		{
+			name:   "cidr not an ip",
+			config: "format: csv\nmatch: cidr\ncontent: |\n  net,zone\n  10.0.0.0/8,internal\n",
+			value:  "host",
+		},
+		{
+			name:     "json objects",
+			config:   "format: json\nkey: uid\ncontent: '[{\"uid\": 1000, \"name\": \"alice\"}]'\n",
+			value:    "1000",
+			expected: map[string]string{"uid": "1000", "name": "alice"},
+		},
+		{
+			name:     "json watchlist",
+			config:   "format: json\ncontent: '[\"evil.com\", \"bad.org\"]'\n",
+			value:    "bad.org",
+			expected: map[string]string{"value": "bad.org"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Verify("", test.config); err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			l, err := NewLookup("", test.config, "find_test")
+			if err != nil {
+				t.Fatalf("NewLookup() failed: %v", err)
+			}
+			row, ok := l.Row(test.value)
+			if ok != (test.expected != nil) {
+				t.Fatalf("Row(%q) found = %v, expected %v", test.value, ok, test.expected != nil)
+			}
+			// json numbers stay json.Number, compare the values as text
+			got := make(map[string]string, len(row))
+			for k, v := range row {
+				got[k] = common.AnyToString(v)
+			}
+			if ok && !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Row(%q) = %v, expected %v", test.value, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestLookupVerifyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"missing format", "content: a\n", "missing required field 'format'"},
+		{"unknown format", "format: xml\ncontent: a\n", "must be 'csv' or 'json'"},
+		{"unknown match", "format: csv\nmatch: prefix\ncontent: a\n", "must be 'exact' or 'cidr'"},
+		{"cidr case insensitive", "format: csv\nmatch: cidr\ncase_insensitive: true\ncontent: a\n", "cannot be used with match 'cidr'"},
+		{"empty csv", "format: csv\ncontent: ''\n", "csv content is empty"},
+		{"empty header column", "format: csv\ncontent: |\n  a,,c\n", "header column 2 is empty"},
+		{"unknown key", "format: csv\nkey: ip\ncontent: |\n  host\n  a\n", "'ip' is not in the csv header"},
+		{"short row", "format: csv\ncontent: |\n  a,b\n  1\n", "csv parse error"},
+		{"empty key", "format: csv\ncontent: |\n  a,b\n  ,1\n", "row 1 has an empty key"},
+		{"invalid network", "format: csv\nmatch: cidr\ncontent: |\n  net\n  10.0.0.0/40\n", "invalid network"},
+		{"invalid ip", "format: csv\nmatch: cidr\ncontent: |\n  net\n  host\n", "invalid ip"},
+		{"json not an array", "format: json\ncontent: '{}'\n", "must be an array"},
+		{"json objects without key", "format: json\ncontent: '[{\"a\": 1}]'\n", "'key' is required"},
+		{"json unknown key", "format: json\nkey: b\ncontent: '[{\"a\": 1}]'\n", "'b' is not in any json item"},
+		{"json nested array", "format: json\ncontent: '[[1]]'\n", "must be an object or a string"},
+		{"json empty", "format: json\ncontent: '[]'\n", "json content is empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify("", test.config)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Verify() = %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestLookupRegistry(t *testing.T) {
+	config := "format: json\ncontent: '[\"evil.com\"]'\n"
+	if _, err := NewLookup("", config, "registry_test"); err != nil {
+		t.Fatalf("NewLookup() failed: %v", err)
+	}
+	defer SafeDeleteLookup("registry_test")
+
+	tests := []struct {
+		value    interface{}
+		expected bool
+		hit      string
+	}{
+		{"evil.com", true, "evil.com"},
+		{"good.com", false, ""},
+		{[]interface{}{"good.com", "evil.com"}, true, "evil.com"},
+		{[]interface{}{"good.com"}, false, ""},
+	}
+	for _, test := range tests {
+		if ok, hit := ContainsAny("registry_test", test.value); ok != test.expected || hit != test.hit {
+			t.Errorf("ContainsAny(%v) = %v, %q, expected %v, %q", test.value, ok, hit, test.expected, test.hit)
+		}
+	}
+	l, _ := Get("registry_test")
+	if hits, misses := l.Stats(); hits != 2 || misses != 3 {
+		t.Errorf("Stats() = %d, %d, expected 2 hits and 3 misses", hits, misses)
+	}
+
+	// A new version replaces the old one
+	if _, err := NewLookup("", "format: json\ncontent: '[\"good.com\"]'\n", "registry_test"); err != nil {
+		t.Fatalf("NewLookup() failed: %v", err)
+	}
+	if ok, _ := ContainsAny("registry_test", "evil.com"); ok {
+		t.Errorf("ContainsAny(evil.com) matched the replaced version")
+	}
+
+	if _, err := SafeDeleteLookup("registry_test"); err != nil {
+		t.Fatalf("SafeDeleteLookup() failed: %v", err)
+	}
+	if Exists("registry_test") {
+		t.Errorf("lookup exists after deleting it")
+	}
+	if ok, _ := ContainsAny("registry_test", "good.com"); ok {
+		t.Errorf("ContainsAny() matched a deleted lookup")
+	}
+	if _, err := SafeDeleteLookup("registry_test"); err == nil {
+		t.Errorf("SafeDeleteLookup() of a missing lookup succeeded")
+	}
+}
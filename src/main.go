@@ -4,15 +4,21 @@ import (
 	"AgentSmith-HUB/api"
 	"AgentSmith-HUB/cluster"
 	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/geoip"
+	"AgentSmith-HUB/gitops"
 	"AgentSmith-HUB/input"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/lookup"
 	"AgentSmith-HUB/output"
 	"AgentSmith-HUB/plugin"
 	"AgentSmith-HUB/project"
 	"AgentSmith-HUB/rules_engine"
+	"AgentSmith-HUB/threat_intel"
+	"AgentSmith-HUB/tracing"
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -23,6 +29,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,6 +39,8 @@ func main() {
 		isLeader  = flag.Bool("leader", false, "run as cluster leader")
 		apiListen = flag.String("api_listen", "0.0.0.0:8080", "API server listen address")
 		showVer   = flag.Bool("version", false, "show version")
+		runTests  = flag.Bool("test", false, "run the ruleset test cases under config_root and exit, for CI")
+		testOnly  = flag.String("test_ruleset", "", "only run the test cases of this ruleset (with -test)")
 		buildVers = "v0.1.7"
 	)
 	flag.Parse()
@@ -53,9 +62,12 @@ func main() {
 		return
 	}
 
+	// Test mode runs without the configured Redis and the cluster, exit code 1 on failed test cases
+	if *runTests {
+		os.Exit(runRulesetTestCases(*testOnly))
+	}
+
 	if *isLeader {
-		// Initialize Redis-based sample manager (stores component data samples)
-		common.InitRedisSampleManager()
 		logger.Info("Starting in leader mode", "config_root", *cfgRoot)
 	} else {
 		logger.Info("Starting in follower mode", "config_root", *cfgRoot)
@@ -110,14 +122,34 @@ func main() {
 	// Initialize daily statistics manager (tracks real message counts)
 	common.InitDailyStatsManager()
 
+	// The leader holds the leader lock. With cluster failover a node started with -leader joins as
+	// follower while another node leads, and like any follower takes over when that node is gone.
+	leader := *isLeader
+	var leaderLocker *cluster.LeaderLocker
+	if leader && common.Config.Cluster.Failover {
+		var err error
+		if leaderLocker, err = cluster.ObtainLeaderLocker(ip); err != nil {
+			logger.Warn("Leader lock is held by another node, starting in follower mode", "error", err)
+			leader = false
+		}
+	} else if leader {
+		// Without failover no other node takes over, wait for a lock left behind to expire
+		var err error
+		if leaderLocker, err = cluster.WaitLeaderLocker(ip); err != nil {
+			logger.Error("Failed to obtain leader locker", "error", err)
+			return
+		}
+	}
+	cluster.SetAdvertiseAPI(advertiseAPI(ip, *apiListen))
+
 	// Initialize new cluster system
-	cluster.InitCluster(ip, *isLeader)
+	cluster.InitCluster(ip, leader, leaderLocker)
 
 	// IMPORTANT: Set centralized cluster state
-	common.SetClusterState(*isLeader, ip)
+	common.SetClusterState(leader, ip)
 
 	// IMPORTANT: Also set the legacy global IsLeader variable for component compatibility
-	common.SetLeaderState(*isLeader, ip)
+	common.SetLeaderState(leader, ip)
 
 	// Register project command handler with cluster package
 	cluster.SetProjectCommandHandler(project.GetProjectCommandHandler().(cluster.ProjectCommandHandler))
@@ -136,15 +168,26 @@ func main() {
 	// Start pprof server if enabled
 	startPprofServer()
 
-	if *isLeader {
+	// Start threat intel feeds, every node pulls the feeds of its own config.yaml
+	if err := threat_intel.Start(common.Config.ThreatIntel.Feeds); err != nil {
+		logger.Error("Invalid threat intel feed config", "error", err)
+	}
+
+	// Load GeoIP databases, followers also receive the databases of the leader
+	if err := geoip.Start(common.Config.GeoIP); err != nil {
+		logger.Error("Invalid geoip config", "error", err)
+	}
+
+	// Trace sampled messages through the pipeline to an OTLP collector
+	if err := tracing.Start(common.Config.Tracing); err != nil {
+		logger.Error("Invalid tracing config", "error", err)
+	}
+
+	if leader {
 		// Leader mode
-		err := cluster.GlobalClusterManager.ObtainLeaderLocker()
-		if err != nil {
-			logger.Error("Failed to obtain leader locker", "error", err)
-			return
-		}
+		// Initialize Redis-based sample manager (stores component data samples)
+		common.InitRedisSampleManager()
 
-		common.Config.Leader = ip
 		token, err := readToken(true)
 		if err != nil {
 			logger.Error("Failed to read or create leader token", "error", err)
@@ -167,9 +210,38 @@ func main() {
 			return
 		}
 
+		// Sync components from a git repository, only the leader applies changes
+		if err := gitops.Start(common.Config.GitOps, api.ApplyGitRevision); err != nil {
+			logger.Error("Invalid gitops config", "error", err)
+		}
+
 		go api.ServerStart(*apiListen) // start Echo API on specified address
 		logger.Info("Leader API server starting", "address", *apiListen)
 	} else {
+		// A follower taking over as leader replaces its API server with the leader one
+		cluster.OnPromote(func() {
+			token, err := api.ReadTokenFromRedis()
+			if err != nil || token == "" {
+				if token, err = readToken(true); err != nil {
+					logger.Error("Failed to read or create leader token", "error", err)
+				} else if err := api.WriteTokenToRedis(token); err != nil {
+					logger.Warn("Failed to store leader token in Redis", "error", err)
+				}
+			}
+			common.Config.Token = token
+
+			common.InitRedisSampleManager()
+			common.InitClusterSystemManager()
+
+			api.StopFollowerServer()
+			go api.ServerStart(*apiListen)
+			logger.Info("Leader API server starting", "address", *apiListen)
+
+			if err := gitops.Start(common.Config.GitOps, api.ApplyGitRevision); err != nil {
+				logger.Error("Invalid gitops config", "error", err)
+			}
+		})
+
 		// Token will be read by follower API server at startup
 		cluster.GlobalClusterManager.Start()
 
@@ -183,8 +255,15 @@ func main() {
 	defer stopSignal()
 
 	go func() {
-		<-shutdownCtx.Done()
-		logger.Info("shutdown signal received, starting graceful shutdown process...")
+		exitCode := 0
+		select {
+		case <-shutdownCtx.Done():
+			logger.Info("shutdown signal received, starting graceful shutdown process...")
+		case <-cluster.GlobalClusterManager.LeadershipLost():
+			// Another node may lead already, exit so that the node is restarted and joins as follower
+			logger.Error("Leadership lost, starting graceful shutdown process...")
+			exitCode = 1
+		}
 
 		// Create a timeout context for the entire shutdown process
 		shutdownTimeout := 60 * time.Second
@@ -233,6 +312,10 @@ func main() {
 				}
 			}
 
+			gitops.Stop()
+			threat_intel.Stop()
+			geoip.Stop()
+			tracing.Stop()
 			common.StopClusterSystemManager()
 			common.StopDailyStatsManager()
 			if rsm := common.GetRedisSampleManager(); rsm != nil {
@@ -259,7 +342,7 @@ func main() {
 		}
 
 		logger.Info("Hub shutdown complete — bye")
-		os.Exit(0)
+		os.Exit(exitCode)
 	}()
 
 	select {}
@@ -277,49 +360,12 @@ func traverseComponents(dir, suffix string) []string {
 }
 
 func loadLocalComponents() {
-	var err error
 	// Only leader loads local components
 	root := common.Config.ConfigRoot
 
-	// plugins
-	for _, f := range traverseComponents(path.Join(root, "plugin"), ".go") {
-		name := common.GetFileNameWithoutExt(f)
-		if content, err := os.ReadFile(f); err == nil {
-			// Update global config map
-			common.SetRawConfig("plugin", name, string(content))
-		}
-		err = plugin.NewPlugin(f, "", name, plugin.YAEGI_PLUGIN)
-		if err != nil {
-			logger.Error("Failed to load plugin", "file", f, "error", err)
-			// Create an error placeholder plugin to show in list
-			errorPlugin := &plugin.Plugin{
-				Name:   name,
-				Path:   f,
-				Type:   plugin.YAEGI_PLUGIN,
-				Status: common.StatusError,
-				Err:    err,
-			}
-			// Read raw config for display purposes
-			if content, readErr := os.ReadFile(f); readErr == nil {
-				errorPlugin.Payload = content
-			}
-			// Add to global plugin map with mutex protection
-			common.GlobalMu.Lock()
-			plugin.Plugins[name] = errorPlugin
-			common.GlobalMu.Unlock()
-		}
-	}
-	// Load plugin .new files
-	for _, f := range traverseComponents(path.Join(root, "plugin"), ".go.new") {
-		common.GlobalMu.Lock()
-		name := strings.TrimSuffix(common.GetFileNameWithoutExt(f), ".go")
-		if content, err := os.ReadFile(f); err != nil {
-			logger.Error("Failed to load new plugin", "file", f, "error", err)
-		} else {
-			plugin.PluginsNew[name] = string(content)
-		}
-		common.GlobalMu.Unlock()
-	}
+	loadLocalPlugins(root)
+	// lookups (rulesets reference lookups)
+	loadLocalLookups(root)
 
 	// inputs
 	for _, f := range traverseComponents(path.Join(root, "input"), ".yaml") {
@@ -431,6 +477,159 @@ func loadLocalComponents() {
 	logger.Info("Leader finished loading local components")
 }
 
+// loadLocalPlugins loads the plugins under config_root
+func loadLocalPlugins(root string) {
+	for _, f := range traverseComponents(path.Join(root, "plugin"), ".go") {
+		name := common.GetFileNameWithoutExt(f)
+		if content, err := os.ReadFile(f); err == nil {
+			// Update global config map
+			common.SetRawConfig("plugin", name, string(content))
+		}
+		if err := plugin.NewPlugin(f, "", name, plugin.YAEGI_PLUGIN); err != nil {
+			logger.Error("Failed to load plugin", "file", f, "error", err)
+			// Create an error placeholder plugin to show in list
+			errorPlugin := &plugin.Plugin{
+				Name:   name,
+				Path:   f,
+				Type:   plugin.YAEGI_PLUGIN,
+				Status: common.StatusError,
+				Err:    err,
+			}
+			// Read raw config for display purposes
+			if content, readErr := os.ReadFile(f); readErr == nil {
+				errorPlugin.Payload = content
+			}
+			// Add to global plugin map with mutex protection
+			common.GlobalMu.Lock()
+			plugin.Plugins[name] = errorPlugin
+			common.GlobalMu.Unlock()
+		}
+	}
+	// Load plugin .new files
+	for _, f := range traverseComponents(path.Join(root, "plugin"), ".go.new") {
+		common.GlobalMu.Lock()
+		name := strings.TrimSuffix(common.GetFileNameWithoutExt(f), ".go")
+		if content, err := os.ReadFile(f); err != nil {
+			logger.Error("Failed to load new plugin", "file", f, "error", err)
+		} else {
+			plugin.PluginsNew[name] = string(content)
+		}
+		common.GlobalMu.Unlock()
+	}
+}
+
+// loadLocalLookups loads the lookups under config_root
+func loadLocalLookups(root string) {
+	for _, f := range traverseComponents(path.Join(root, "lookup"), ".yaml") {
+		id := common.GetFileNameWithoutExt(f)
+		if content, err := os.ReadFile(f); err == nil {
+			// Update global config map
+			common.SetRawConfig("lookup", id, string(content))
+		}
+		if _, err := lookup.NewLookup(f, "", id); err != nil {
+			logger.Error("Failed to load lookup", "file", f, "error", err)
+			// Create an error placeholder lookup to show in list
+			errorLookup := &lookup.Lookup{
+				Id:     id,
+				Path:   f,
+				Status: common.StatusError,
+				Err:    err,
+			}
+			// Read raw config for display purposes
+			if content, readErr := os.ReadFile(f); readErr == nil {
+				errorLookup.Config = &lookup.LookupConfig{RawConfig: string(content)}
+			}
+			lookup.LookupsMu.Lock()
+			lookup.Lookups[id] = errorLookup
+			lookup.LookupsMu.Unlock()
+		}
+	}
+	// Load lookup .new files
+	for _, f := range traverseComponents(path.Join(root, "lookup"), ".yaml.new") {
+		id := strings.TrimSuffix(common.GetFileNameWithoutExt(f), ".yaml")
+		if content, err := os.ReadFile(f); err != nil {
+			logger.Error("Failed to load new lookup", "file", f, "error", err)
+		} else {
+			lookup.SetLookupNew(id, string(content))
+		}
+	}
+}
+
+// runRulesetTestCases runs the test cases of the rulesets under config_root and returns the exit code.
+// Like on apply, thresholds, sequences and suppressions count in a local cache per test case. Plugins
+// that keep state in Redis, such as suppressOnce, get an empty in-memory Redis for the run instead of
+// the configured one, so CI never touches the hub's state.
+func runRulesetTestCases(only string) int {
+	mr, err := miniredis.Run()
+	if err != nil {
+		fmt.Printf("failed to start in-memory redis: %v\n", err)
+		return 1
+	}
+	defer mr.Close()
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		fmt.Printf("failed to connect in-memory redis: %v\n", err)
+		return 1
+	}
+
+	root := common.Config.ConfigRoot
+	loadLocalPlugins(root)
+	loadLocalLookups(root)
+
+	total, failed := 0, 0
+	for _, f := range traverseComponents(path.Join(root, "ruleset"), ".xml") {
+		id := common.GetFileNameWithoutExt(f)
+		if only != "" && id != only {
+			continue
+		}
+		cases, err := rules_engine.LoadTestCases(id)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", id, err)
+			total++
+			failed++
+			continue
+		}
+		if len(cases) == 0 {
+			continue
+		}
+		content, err := os.ReadFile(f)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", id, err)
+			total++
+			failed++
+			continue
+		}
+		report, err := rules_engine.RunTestCases(id, string(content), cases)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", id, err)
+			total += len(cases)
+			failed += len(cases)
+			continue
+		}
+		for _, res := range report.Results {
+			total++
+			if res.Passed {
+				fmt.Printf("ok   %s/%s\n", id, res.Name)
+				continue
+			}
+			failed++
+			fmt.Printf("FAIL %s/%s\n", id, res.Name)
+			for _, failure := range res.Failures {
+				fmt.Printf("     %s\n", failure)
+			}
+		}
+	}
+
+	if only != "" && total == 0 && failed == 0 {
+		fmt.Printf("no test cases found for ruleset %s\n", only)
+		return 1
+	}
+	fmt.Printf("%d test cases, %d passed, %d failed\n", total, total-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
 func loadLocalProjects() {
 	root := common.Config.ConfigRoot
 	for _, f := range traverseComponents(path.Join(root, "project"), ".yaml") {
@@ -451,14 +650,14 @@ func loadLocalProjects() {
 				if err := p.Start(true); err != nil {
 					logger.Error("Failed to start project during restore", "project", p.Id, "error", err)
 					// Record failed restore operation
-					common.RecordProjectOperation(common.OpTypeProjectStart, p.Id, "failed", err.Error(), map[string]interface{}{
+					common.RecordProjectOperation(common.OpTypeProjectStart, p.Id, "failed", err.Error(), "", map[string]interface{}{
 						"triggered_by": "system_restore",
 						"node_id":      common.Config.LocalIP,
 					})
 				} else {
 					logger.Info("Successfully restored project to running state", "id", p.Id)
 					// Record successful restore operation
-					common.RecordProjectOperation(common.OpTypeProjectStart, p.Id, "success", "", map[string]interface{}{
+					common.RecordProjectOperation(common.OpTypeProjectStart, p.Id, "success", "", "", map[string]interface{}{
 						"triggered_by": "system_restore",
 						"node_id":      common.Config.LocalIP,
 					})
@@ -521,6 +720,21 @@ func readToken(create bool) (string, error) {
 	return "", fmt.Errorf("token file not found")
 }
 
+// advertiseAPI returns the API URL other nodes forward writes to while this node leads
+func advertiseAPI(ip, listen string) string {
+	if common.Config.Cluster.AdvertiseAPI != "" {
+		return common.Config.Cluster.AdvertiseAPI
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return ""
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = ip
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
 // loadHubConfig loads config.yaml inside given root directory into common.Config.
 func loadHubConfig(root string) error {
 	// Initialize config
@@ -561,6 +775,11 @@ func loadHubConfig(root string) error {
 		logger.Info("Using Redis password from environment variable")
 	}
 
+	if envOIDCSecret := os.Getenv("OIDC_CLIENT_SECRET"); envOIDCSecret != "" {
+		common.Config.OIDC.ClientSecret = envOIDCSecret
+		logger.Info("Using OIDC client secret from environment variable")
+	}
+
 	// Override SIMD configuration with environment variable if set
 	if envSIMDEnabled := os.Getenv("SIMD_ENABLED"); envSIMDEnabled != "" {
 		simdEnabled := strings.ToLower(envSIMDEnabled) == "true" || envSIMDEnabled == "1"
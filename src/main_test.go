@@ -0,0 +1,77 @@
+package main
+
+import (
+	"AgentSmith-HUB/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const cliTestRuleset = `<root type="DETECTION">
+    <rule id="brute_force">
+        <check type="EQU" field="result">failed</check>
+        <threshold group_by="user" range="5m">1</threshold>
+    </rule>
+    <rule id="first_admin_login">
+        <check type="EQU" field="user">admin</check>
+        <check type="PLUGIN">suppressOnce(user, 60, "first_admin_login")</check>
+    </rule>
+</root>`
+
+const cliTestCases = `tests:
+  - name: two failures
+    events:
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+    hits: [brute_force]
+  - name: one failure
+    data: {user: alice, result: failed}
+    hits: []
+  - name: admin login
+    data: {user: admin, result: success}
+    hits: [first_admin_login]
+`
+
+func setupCLITest(t *testing.T, rulesets map[string]string) {
+	t.Helper()
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir()}
+	t.Cleanup(func() { common.Config = prevConfig })
+
+	dir := filepath.Join(common.Config.ConfigRoot, "ruleset")
+	os.MkdirAll(dir, 0755)
+	for name, content := range rulesets {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestRunRulesetTestCases(t *testing.T) {
+	regression := strings.Replace(cliTestRuleset, `range="5m">1<`, `range="5m">2<`, 1)
+
+	tests := []struct {
+		name     string
+		rulesets map[string]string
+		only     string
+		code     int
+	}{
+		{"passing", map[string]string{"auth.xml": cliTestRuleset, "auth.test.yaml": cliTestCases}, "", 0},
+		{"regression", map[string]string{"auth.xml": regression, "auth.test.yaml": cliTestCases}, "", 1},
+		{"invalid ruleset", map[string]string{"auth.xml": "<root", "auth.test.yaml": cliTestCases}, "", 1},
+		{"invalid test cases", map[string]string{"auth.xml": cliTestRuleset, "auth.test.yaml": "tests: ["}, "", 1},
+		{"without test cases", map[string]string{"auth.xml": cliTestRuleset}, "", 0},
+		{"only passing ruleset", map[string]string{"auth.xml": cliTestRuleset, "auth.test.yaml": cliTestCases, "other.xml": regression, "other.test.yaml": cliTestCases}, "auth", 0},
+		{"only unknown ruleset", map[string]string{"auth.xml": cliTestRuleset, "auth.test.yaml": cliTestCases}, "missing", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupCLITest(t, test.rulesets)
+			if code := runRulesetTestCases(test.only); code != test.code {
+				t.Errorf("runRulesetTestCases(%q) = %d, expected %d", test.only, code, test.code)
+			}
+		})
+	}
+}
@@ -62,6 +62,16 @@ func NewAPIMapper(baseURL, token string) *APIMapper {
 	}
 }
 
+// withAuth returns a mapper that calls baseURL with token, sharing the
+// pooled HTTP client of m.
+func (m *APIMapper) withAuth(baseURL, token string) *APIMapper {
+	return &APIMapper{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: m.httpClient,
+	}
+}
+
 // GetAllAPITools returns all MCP tools that map to existing API endpoints
 func (m *APIMapper) GetAllAPITools() []common.MCPTool {
 	return []common.MCPTool{
@@ -399,6 +409,18 @@ func (m *APIMapper) GetAllAPITools() []common.MCPTool {
 			},
 			Annotations: createAnnotations("Delete Rule", boolPtr(false), boolPtr(true), boolPtr(true), boolPtr(false)),
 		},
+		{
+			Name:        "sigma_import",
+			Description: "IMPORT SIGMA RULES: Convert Sigma YAML detections (selections, contains/startswith/endswith/re/all/cased modifiers, condition expressions) into HUB ruleset XML, validate it and report unsupported constructs. Rules with unsupported parts are skipped. With 'ruleset_id' the converted ruleset is created as a pending change if validation passes.",
+			InputSchema: map[string]common.MCPToolArg{
+				"sigma":         {Type: "string", Description: "Sigma rule YAML, several rules may be separated by '---'", Required: true},
+				"ruleset_id":    {Type: "string", Description: "Create a new ruleset with this ID from the result (optional, conversion only when omitted)", Required: false},
+				"name":          {Type: "string", Description: "Ruleset name attribute", Required: false},
+				"author":        {Type: "string", Description: "Ruleset author attribute", Required: false},
+				"field_mapping": {Type: "string", Description: "JSON object mapping Sigma fields to HUB field paths, e.g. {\"Image\":\"process.exe\"}", Required: false},
+			},
+			Annotations: createAnnotations("Import Sigma", boolPtr(false), boolPtr(false), boolPtr(false), boolPtr(false)),
+		},
 
 		// Component Viewing
 		{
@@ -579,6 +601,8 @@ func (m *APIMapper) CallAPITool(toolName string, args map[string]interface{}) (c
 		return m.handleRuleAIGenerator(args)
 	case "batch_operation_manager":
 		return m.handleBatchOperationManager(args)
+	case "sigma_import":
+		return m.handleSigmaImport(args)
 
 	// Legacy compatibility handlers
 	case "get_metrics":
@@ -2031,6 +2055,111 @@ func (m *APIMapper) handleCreateRuleset(args map[string]interface{}) (common.MCP
 	}, nil
 }
 
+// handleSigmaImport converts Sigma rules through the API and optionally creates a ruleset from the result
+func (m *APIMapper) handleSigmaImport(args map[string]interface{}) (common.MCPToolResult, error) {
+	sigma, _ := args["sigma"].(string)
+	if strings.TrimSpace(sigma) == "" {
+		return errors.NewValidationErrorWithSuggestions(
+			"sigma parameter (Sigma rule YAML) is required",
+			[]string{
+				"Provide one or more Sigma rules as YAML, separated by '---'",
+				"Example: sigma_import sigma='title: ...\ndetection:\n  sel:\n    Image|endswith: \\cmd.exe\n  condition: sel'",
+			},
+		).ToMCPResult(), nil
+	}
+
+	body := map[string]interface{}{
+		"sigma":  sigma,
+		"name":   args["name"],
+		"author": args["author"],
+	}
+	switch fm := args["field_mapping"].(type) {
+	case string:
+		if strings.TrimSpace(fm) != "" {
+			var mapping map[string]string
+			if err := json.Unmarshal([]byte(fm), &mapping); err != nil {
+				return errors.NewValidationError(fmt.Sprintf("field_mapping must be a JSON object of strings: %v", err)).ToMCPResult(), nil
+			}
+			body["field_mapping"] = mapping
+		}
+	case map[string]interface{}:
+		body["field_mapping"] = fm
+	}
+
+	response, err := m.makeHTTPRequest("POST", "/sigma/convert", body, true)
+	if err != nil {
+		return common.MCPToolResult{
+			Content: []common.MCPToolContent{{Type: "text", Text: fmt.Sprintf("Sigma conversion failed: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var result struct {
+		XML       string `json:"xml"`
+		Converted int    `json:"converted"`
+		Skipped   int    `json:"skipped"`
+		Valid     bool   `json:"valid"`
+		Rules     []struct {
+			Index       int      `json:"index"`
+			Title       string   `json:"title"`
+			RuleID      string   `json:"rule_id"`
+			Converted   bool     `json:"converted"`
+			Unsupported []string `json:"unsupported"`
+		} `json:"rules"`
+		Errors []struct {
+			Line    int    `json:"line"`
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return common.MCPToolResult{
+			Content: []common.MCPToolContent{{Type: "text", Text: fmt.Sprintf("Failed to parse conversion result: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("=== SIGMA IMPORT ===\n")
+	sb.WriteString(fmt.Sprintf("Converted: %d, skipped: %d\n\n", result.Converted, result.Skipped))
+	for _, r := range result.Rules {
+		if r.Converted {
+			sb.WriteString(fmt.Sprintf("✓ [%d] %s -> rule '%s'\n", r.Index, r.Title, r.RuleID))
+		} else {
+			sb.WriteString(fmt.Sprintf("✗ [%d] %s skipped: %s\n", r.Index, r.Title, strings.Join(r.Unsupported, "; ")))
+		}
+	}
+
+	if result.Valid {
+		sb.WriteString("\n✓ Validation passed\n")
+	} else {
+		sb.WriteString("\n✗ Validation failed:\n")
+		for _, e := range result.Errors {
+			sb.WriteString(fmt.Sprintf("  line %d: %s %s\n", e.Line, e.Message, e.Detail))
+		}
+	}
+	sb.WriteString("\n```xml\n" + result.XML + "```\n")
+
+	rulesetID, _ := args["ruleset_id"].(string)
+	if rulesetID == "" {
+		return common.MCPToolResult{Content: []common.MCPToolContent{{Type: "text", Text: sb.String()}}}, nil
+	}
+	if !result.Valid {
+		sb.WriteString(fmt.Sprintf("\nRuleset '%s' was not created because validation failed\n", rulesetID))
+		return common.MCPToolResult{Content: []common.MCPToolContent{{Type: "text", Text: sb.String()}}, IsError: true}, nil
+	}
+
+	createResponse, err := m.makeHTTPRequest("POST", "/rulesets", map[string]interface{}{"id": rulesetID, "raw": result.XML}, true)
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("\n✗ Ruleset creation failed: %v\n", err))
+		return common.MCPToolResult{Content: []common.MCPToolContent{{Type: "text", Text: sb.String()}}, IsError: true}, nil
+	}
+	sb.WriteString(fmt.Sprintf("\n✓ Ruleset created: %s\n", string(createResponse)))
+	sb.WriteString("⚠️  Ruleset is in a TEMPORARY file - review with `get_pending_changes` and deploy with `apply_changes`\n")
+
+	return common.MCPToolResult{Content: []common.MCPToolContent{{Type: "text", Text: sb.String()}}}, nil
+}
+
 // handleAddRulesetRule adds a single rule to an existing ruleset
 func (m *APIMapper) handleAddRulesetRule(args map[string]interface{}) (common.MCPToolResult, error) {
 	rulesetId, ok := args["id"].(string)
@@ -6094,6 +6223,14 @@ func (m *APIMapper) handleRuleSyntaxHelp(args map[string]interface{}) (common.MC
 	results = append(results, "**Numeric Comparison:**")
 	results = append(results, "- MT: Greater than - `<check type=\"MT\" field=\"score\">80</check>`")
 	results = append(results, "- LT: Less than - `<check type=\"LT\" field=\"age\">18</check>`")
+	results = append(results, "- GTE / LTE: Greater / less than or equal - `<check type=\"GTE\" field=\"score\">80</check>`")
+	results = append(results, "- BETWEEN: Inclusive range - `<check type=\"BETWEEN\" field=\"port\">1024,65535</check>`")
+	results = append(results, "")
+	results = append(results, "**Typed Checks (compare the original value, not its string form):**")
+	results = append(results, "- IN / NOT_IN: Exact match in a list - `<check type=\"IN\" field=\"action\">login,logout</check>`")
+	results = append(results, "- LEN_MT / LEN_LT: String, array or object length - `<check type=\"LEN_MT\" field=\"cmdline\">1000</check>`")
+	results = append(results, "- CIDR: IP in network - `<check type=\"CIDR\" field=\"src_ip\">10.0.0.0/8</check>`")
+	results = append(results, "- TYPE: Field exists with type string/number/bool/array/object - `<check type=\"TYPE\" field=\"tags\">array</check>`")
 	results = append(results, "")
 	results = append(results, "**Null Checks:**")
 	results = append(results, "- ISNULL: Field is null - `<check type=\"ISNULL\" field=\"optional\"></check>`")
@@ -6136,6 +6273,17 @@ func (m *APIMapper) handleRuleSyntaxHelp(args map[string]interface{}) (common.MC
 	results = append(results, "<threshold group_by=\"user_id\" range=\"30m\" count_type=\"CLASSIFY\" count_field=\"accessed_file\" value=\"25\"/>")
 	results = append(results, "```")
 	results = append(results, "")
+	results = append(results, "**DISTINCT Mode - Estimated Unique Values (HyperLogLog, fixed memory for high cardinality):**")
+	results = append(results, "```xml")
+	results = append(results, "<threshold group_by=\"src_ip\" range=\"1h\" count_type=\"DISTINCT\" count_field=\"dst_port\" value=\"1000\"/>")
+	results = append(results, "```")
+	results = append(results, "")
+	results = append(results, "**AVG / MAX / MIN Modes - Numeric Aggregation (MIN triggers below value):**")
+	results = append(results, "```xml")
+	results = append(results, "<threshold group_by=\"client_ip\" range=\"10m\" count_type=\"AVG\" count_field=\"response_bytes\" value=\"5242880\"/>")
+	results = append(results, "```")
+	results = append(results, "The computed value of a hit threshold is added to the alert as `_hub_threshold_value`.")
+	results = append(results, "")
 	results = append(results, "**Performance Optimization:**")
 	results = append(results, "```xml")
 	results = append(results, "<threshold group_by=\"user_id\" range=\"5m\" value=\"10\" local_cache=\"true\"/>")
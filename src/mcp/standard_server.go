@@ -70,6 +70,23 @@ func (s *StandardMCPServer) GetAPIMapper() *APIMapper {
 	return s.apiMapper
 }
 
+// HandleJSONRPCRequestAs handles a JSON-RPC request on behalf of the caller
+// identified by token. The shared server is left untouched: API calls made
+// while serving the request go through a mapper bound to this caller only,
+// so concurrent sessions never see each other's credentials.
+func (s *StandardMCPServer) HandleJSONRPCRequestAs(requestData []byte, baseURL, token string) ([]byte, error) {
+	if baseURL == "" {
+		baseURL = s.baseURL
+	}
+	call := &StandardMCPServer{
+		server:    s.server,
+		apiMapper: s.apiMapper.withAuth(baseURL, token),
+		baseURL:   baseURL,
+		token:     token,
+	}
+	return call.HandleJSONRPCRequest(requestData)
+}
+
 // HandleJSONRPCRequest handles a JSON-RPC request using simplified logic
 func (s *StandardMCPServer) HandleJSONRPCRequest(requestData []byte) ([]byte, error) {
 	// Parse the JSON-RPC request
@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/input"
+	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/plugin"
+	"AgentSmith-HUB/project"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus/OpenMetrics exporter of this node. Values are read from the counters the components
+// already keep when the endpoint is scraped, nothing is recorded on the message path except the
+// plugin latency histogram (plugin.InvocationDuration).
+//
+// Components shared by several projects are reported under each running project that uses them.
+
+const namespace = "agentsmith_hub"
+
+// kafkaLagTimeout bounds the consumer group lag requests of a scrape
+const kafkaLagTimeout = 5 * time.Second
+
+var (
+	registry     *prometheus.Registry
+	registryOnce sync.Once
+)
+
+// Handler serves the metrics of this node, in OpenMetrics format when the scraper asks for it
+func Handler() http.Handler {
+	registryOnce.Do(func() {
+		registry = prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			plugin.InvocationDuration,
+			newHubCollector(),
+		)
+	})
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+		ErrorHandling:     promhttp.ContinueOnError,
+	})
+}
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+}
+
+type hubCollector struct {
+	clusterLeader      *prometheus.Desc
+	redisCircuitState  *prometheus.Desc
+	projectRunning     *prometheus.Desc
+	inputMessages      *prometheus.Desc
+	rulesetMessages    *prometheus.Desc
+	rulesetTasks       *prometheus.Desc
+	outputMessages     *prometheus.Desc
+	outputPending      *prometheus.Desc
+	channelLength      *prometheus.Desc
+	channelCapacity    *prometheus.Desc
+	bufferPending      *prometheus.Desc
+	pluginInvocations  *prometheus.Desc
+	pluginInFlight     *prometheus.Desc
+	pluginTimeouts     *prometheus.Desc
+	pluginRejected     *prometheus.Desc
+	pluginCircuitOpen  *prometheus.Desc
+	pluginCircuitTrips *prometheus.Desc
+	pluginCacheHits    *prometheus.Desc
+	pluginCacheMisses  *prometheus.Desc
+	kafkaConsumerLag   *prometheus.Desc
+	kafkaLagErrors     *prometheus.Desc
+}
+
+func newHubCollector() *hubCollector {
+	return &hubCollector{
+		clusterLeader:      desc("cluster_leader", "1 if this node is the cluster leader."),
+		redisCircuitState:  desc("redis_circuit_state", "State of the Redis circuit breaker: 0 closed, 1 open, 2 half-open."),
+		projectRunning:     desc("project_running", "1 if the project is running on this node.", "project"),
+		inputMessages:      desc("input_messages_total", "Messages consumed by the input.", "project", "input", "sequence"),
+		rulesetMessages:    desc("ruleset_messages_total", "Messages processed by the ruleset.", "project", "ruleset", "sequence"),
+		rulesetTasks:       desc("ruleset_running_tasks", "Messages the ruleset is processing right now.", "project", "ruleset", "sequence"),
+		outputMessages:     desc("output_messages_total", "Messages sent by the output.", "project", "output", "sequence"),
+		outputPending:      desc("output_pending_messages", "Messages waiting in the channels of the output.", "project", "output", "sequence"),
+		channelLength:      desc("channel_length", "Messages waiting in the channel into a project node.", "project", "sequence"),
+		channelCapacity:    desc("channel_capacity", "Capacity of the channel into a project node.", "project", "sequence"),
+		bufferPending:      desc("buffer_pending_messages", "Messages waiting in the disk buffer of a project edge.", "project", "sequence"),
+		pluginInvocations:  desc("plugin_invocations_total", "Plugin invocations by result.", "plugin", "result"),
+		pluginInFlight:     desc("plugin_in_flight", "Plugin calls running right now, for plugins with runtime limits.", "plugin"),
+		pluginTimeouts:     desc("plugin_timeouts_total", "Plugin calls that timed out.", "plugin"),
+		pluginRejected:     desc("plugin_rejected_total", "Plugin calls rejected by the concurrency limit or an open circuit.", "plugin"),
+		pluginCircuitOpen:  desc("plugin_circuit_open", "1 if the circuit breaker of the plugin is open.", "plugin"),
+		pluginCircuitTrips: desc("plugin_circuit_trips_total", "Times the circuit breaker of the plugin opened.", "plugin"),
+		pluginCacheHits:    desc("plugin_cache_hits_total", "Plugin result cache hits by tier.", "plugin", "tier"),
+		pluginCacheMisses:  desc("plugin_cache_misses_total", "Plugin result cache misses.", "plugin"),
+		kafkaConsumerLag:   desc("kafka_consumer_lag", "Lag of the consumer group of a kafka input.", "input", "group", "topic", "partition"),
+		kafkaLagErrors:     desc("kafka_consumer_lag_errors_total", "Failed consumer group lag requests of a kafka input since start.", "input"),
+	}
+}
+
+// Describe sends the descriptors of all metrics of the collector
+func (c *hubCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.clusterLeader, c.redisCircuitState, c.projectRunning,
+		c.inputMessages, c.rulesetMessages, c.rulesetTasks, c.outputMessages, c.outputPending,
+		c.channelLength, c.channelCapacity, c.bufferPending,
+		c.pluginInvocations, c.pluginInFlight, c.pluginTimeouts, c.pluginRejected,
+		c.pluginCircuitOpen, c.pluginCircuitTrips, c.pluginCacheHits, c.pluginCacheMisses,
+		c.kafkaConsumerLag, c.kafkaLagErrors,
+	} {
+		ch <- d
+	}
+}
+
+// Collect reads the current values of all metrics
+func (c *hubCollector) Collect(ch chan<- prometheus.Metric) {
+	leader := 0.0
+	if common.IsCurrentNodeLeader() {
+		leader = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.clusterLeader, prometheus.GaugeValue, leader)
+	ch <- prometheus.MustNewConstMetric(c.redisCircuitState, prometheus.GaugeValue, float64(common.RedisCircuitState()))
+
+	kafkaInputs := c.collectProjects(ch)
+	c.collectPlugins(ch)
+	c.collectKafkaLag(ch, kafkaInputs)
+}
+
+// collectProjects reports the components of the running projects and returns their inputs
+func (c *hubCollector) collectProjects(ch chan<- prometheus.Metric) []*input.Input {
+	// Snapshot the projects first so the global lock is not held while reading components
+	var running []*project.Project
+	project.ForEachProject(func(id string, proj *project.Project) bool {
+		value := 0.0
+		if proj.Status == common.StatusRunning {
+			value = 1
+			running = append(running, proj)
+		}
+		ch <- prometheus.MustNewConstMetric(c.projectRunning, prometheus.GaugeValue, value, id)
+		return true
+	})
+
+	seenInputs := make(map[*input.Input]bool)
+	var inputs []*input.Input
+	for _, proj := range running {
+		for _, in := range proj.Inputs {
+			ch <- prometheus.MustNewConstMetric(c.inputMessages, prometheus.CounterValue, float64(in.GetConsumeTotal()), proj.Id, in.Id, in.ProjectNodeSequence)
+			if !seenInputs[in] {
+				seenInputs[in] = true
+				inputs = append(inputs, in)
+			}
+		}
+		for _, rs := range proj.Rulesets {
+			ch <- prometheus.MustNewConstMetric(c.rulesetMessages, prometheus.CounterValue, float64(rs.GetProcessTotal()), proj.Id, rs.RulesetID, rs.ProjectNodeSequence)
+			ch <- prometheus.MustNewConstMetric(c.rulesetTasks, prometheus.GaugeValue, float64(rs.GetRunningTaskCount()), proj.Id, rs.RulesetID, rs.ProjectNodeSequence)
+		}
+		for _, out := range proj.Outputs {
+			ch <- prometheus.MustNewConstMetric(c.outputMessages, prometheus.CounterValue, float64(out.GetProduceTotal()), proj.Id, out.Id, out.ProjectNodeSequence)
+			ch <- prometheus.MustNewConstMetric(c.outputPending, prometheus.GaugeValue, float64(out.GetPendingMessageCount()), proj.Id, out.Id, out.ProjectNodeSequence)
+		}
+		for pns, msgCh := range proj.MsgChannels {
+			if msgCh == nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.channelLength, prometheus.GaugeValue, float64(len(*msgCh)), proj.Id, pns)
+			ch <- prometheus.MustNewConstMetric(c.channelCapacity, prometheus.GaugeValue, float64(cap(*msgCh)), proj.Id, pns)
+		}
+		for pns, q := range proj.DiskQueues {
+			ch <- prometheus.MustNewConstMetric(c.bufferPending, prometheus.GaugeValue, float64(q.Pending()), proj.Id, pns)
+		}
+	}
+	return inputs
+}
+
+func (c *hubCollector) collectPlugins(ch chan<- prometheus.Metric) {
+	plugin.PluginsMu.RLock()
+	defer plugin.PluginsMu.RUnlock()
+
+	for name, p := range plugin.Plugins {
+		ch <- prometheus.MustNewConstMetric(c.pluginInvocations, prometheus.CounterValue, float64(p.GetSuccessTotal()), name, "success")
+		ch <- prometheus.MustNewConstMetric(c.pluginInvocations, prometheus.CounterValue, float64(p.GetFailureTotal()), name, "failure")
+
+		if s := p.RuntimeStats(); s != nil {
+			circuitOpen := 0.0
+			if s.CircuitState == plugin.CircuitOpen {
+				circuitOpen = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.pluginInFlight, prometheus.GaugeValue, float64(s.InFlight), name)
+			ch <- prometheus.MustNewConstMetric(c.pluginTimeouts, prometheus.CounterValue, float64(s.Timeouts), name)
+			ch <- prometheus.MustNewConstMetric(c.pluginRejected, prometheus.CounterValue, float64(s.Rejected), name)
+			ch <- prometheus.MustNewConstMetric(c.pluginCircuitOpen, prometheus.GaugeValue, circuitOpen, name)
+			ch <- prometheus.MustNewConstMetric(c.pluginCircuitTrips, prometheus.CounterValue, float64(s.CircuitTrips), name)
+		}
+		if s := p.CacheStats(); s != nil {
+			ch <- prometheus.MustNewConstMetric(c.pluginCacheHits, prometheus.CounterValue, float64(s.LocalHits), name, "local")
+			ch <- prometheus.MustNewConstMetric(c.pluginCacheHits, prometheus.CounterValue, float64(s.RedisHits), name, "redis")
+			ch <- prometheus.MustNewConstMetric(c.pluginCacheMisses, prometheus.CounterValue, float64(s.Misses), name)
+		}
+	}
+}
+
+var (
+	kafkaLagErrorsMu sync.Mutex
+	kafkaLagErrors   = make(map[string]uint64)
+)
+
+// collectKafkaLag asks the brokers for the consumer group lag of the running kafka inputs
+func (c *hubCollector) collectKafkaLag(ch chan<- prometheus.Metric, inputs []*input.Input) {
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].Id < inputs[j].Id })
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaLagTimeout)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	for _, in := range inputs {
+		if in.Type != input.InputTypeKafka && in.Type != input.InputTypeKafkaAzure && in.Type != input.InputTypeKafkaAWS {
+			continue
+		}
+		// Instances of the same input share the consumer group
+		if seen[in.Id] {
+			continue
+		}
+		seen[in.Id] = true
+
+		lags, err := in.KafkaLag(ctx)
+		kafkaLagErrorsMu.Lock()
+		if err != nil {
+			kafkaLagErrors[in.Id]++
+			logger.Warn("Failed to get kafka consumer lag", "input", in.Id, "error", err)
+		}
+		errCount := kafkaLagErrors[in.Id]
+		kafkaLagErrorsMu.Unlock()
+		ch <- prometheus.MustNewConstMetric(c.kafkaLagErrors, prometheus.CounterValue, float64(errCount), in.Id)
+
+		for _, l := range lags {
+			ch <- prometheus.MustNewConstMetric(c.kafkaConsumerLag, prometheus.GaugeValue, float64(l.Lag), in.Id, l.Group, l.Topic, strconv.Itoa(int(l.Partition)))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/input"
+	"AgentSmith-HUB/local_plugin"
+	"AgentSmith-HUB/output"
+	"AgentSmith-HUB/plugin"
+	"AgentSmith-HUB/project"
+	"AgentSmith-HUB/rules_engine"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// addTestProjects registers a running and a stopped project with one component of each kind
+func addTestProjects(t *testing.T) {
+	t.Helper()
+	msgCh := make(chan map[string]interface{}, 8)
+	msgCh <- map[string]interface{}{"n": 1}
+	msgCh <- map[string]interface{}{"n": 2}
+
+	running := &project.Project{
+		Id:          "metrics_running",
+		Status:      common.StatusRunning,
+		Inputs:      map[string]*input.Input{"in": {Id: "in", Type: input.InputTypeHTTP, ProjectNodeSequence: "INPUT.in"}},
+		Rulesets:    map[string]*rules_engine.Ruleset{"rs": {RulesetID: "rs", ProjectNodeSequence: "INPUT.in.RULESET.rs"}},
+		Outputs:     map[string]*output.Output{"out": {Id: "out", Type: output.OutputTypePrint, ProjectNodeSequence: "INPUT.in.RULESET.rs.OUTPUT.out"}},
+		MsgChannels: map[string]*chan map[string]interface{}{"INPUT.in.RULESET.rs": &msgCh},
+	}
+	stopped := &project.Project{Id: "metrics_stopped", Status: common.StatusStopped}
+
+	common.GlobalMu.Lock()
+	project.GlobalProject.Projects[running.Id] = running
+	project.GlobalProject.Projects[stopped.Id] = stopped
+	common.GlobalMu.Unlock()
+	t.Cleanup(func() {
+		common.GlobalMu.Lock()
+		delete(project.GlobalProject.Projects, running.Id)
+		delete(project.GlobalProject.Projects, stopped.Id)
+		common.GlobalMu.Unlock()
+	})
+}
+
+// addTestPlugin registers a local plugin that fails when called with "fail"
+func addTestPlugin(t *testing.T, name string) *plugin.Plugin {
+	t.Helper()
+	local_plugin.LocalPluginInterfaceAndBoolRes[name] = func(args ...interface{}) (interface{}, bool, error) {
+		if len(args) > 0 && args[0] == "fail" {
+			return nil, false, errors.New("failed")
+		}
+		return "ok", true, nil
+	}
+	p := &plugin.Plugin{Name: name, Type: plugin.LOCAL_PLUGIN}
+	plugin.PluginsMu.Lock()
+	plugin.Plugins[name] = p
+	plugin.PluginsMu.Unlock()
+	t.Cleanup(func() {
+		plugin.PluginsMu.Lock()
+		delete(plugin.Plugins, name)
+		plugin.PluginsMu.Unlock()
+		delete(local_plugin.LocalPluginInterfaceAndBoolRes, name)
+	})
+	return p
+}
+
+func scrape(t *testing.T, accept string) (string, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d: %s", rec.Code, rec.Body.String())
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return string(body), rec.Header().Get("Content-Type")
+}
+
+func TestHandler(t *testing.T) {
+	common.SetClusterState(true, "metrics-node")
+	t.Cleanup(func() { common.SetClusterState(false, "") })
+	addTestProjects(t)
+	p := addTestPlugin(t, "metrics_test_plugin")
+	for _, arg := range []string{"a", "b", "fail"} {
+		p.FuncEvalOther(arg)
+	}
+
+	body, contentType := scrape(t, "")
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Content-Type = %q, expected the text format", contentType)
+	}
+	for _, line := range []string{
+		`agentsmith_hub_cluster_leader 1`,
+		`agentsmith_hub_redis_circuit_state 0`,
+		`agentsmith_hub_project_running{project="metrics_running"} 1`,
+		`agentsmith_hub_project_running{project="metrics_stopped"} 0`,
+		`agentsmith_hub_input_messages_total{input="in",project="metrics_running",sequence="INPUT.in"} 0`,
+		`agentsmith_hub_ruleset_messages_total{project="metrics_running",ruleset="rs",sequence="INPUT.in.RULESET.rs"} 0`,
+		`agentsmith_hub_ruleset_running_tasks{project="metrics_running",ruleset="rs",sequence="INPUT.in.RULESET.rs"} 0`,
+		`agentsmith_hub_output_messages_total{output="out",project="metrics_running",sequence="INPUT.in.RULESET.rs.OUTPUT.out"} 0`,
+		`agentsmith_hub_channel_length{project="metrics_running",sequence="INPUT.in.RULESET.rs"} 2`,
+		`agentsmith_hub_channel_capacity{project="metrics_running",sequence="INPUT.in.RULESET.rs"} 8`,
+		`agentsmith_hub_plugin_invocations_total{plugin="metrics_test_plugin",result="success"} 2`,
+		`agentsmith_hub_plugin_invocations_total{plugin="metrics_test_plugin",result="failure"} 1`,
+		`agentsmith_hub_plugin_invocation_duration_seconds_count{plugin="metrics_test_plugin"} 3`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics do not contain %q", line)
+		}
+	}
+	// Stopped projects only report their state, plugins without runtime limits or cache have no such metrics
+	for _, absent := range []string{
+		`project="metrics_stopped",`,
+		`agentsmith_hub_plugin_in_flight{plugin="metrics_test_plugin"}`,
+		`agentsmith_hub_plugin_cache_misses_total{plugin="metrics_test_plugin"}`,
+		`agentsmith_hub_kafka_consumer_lag`,
+	} {
+		if strings.Contains(body, absent) {
+			t.Errorf("metrics contain %q", absent)
+		}
+	}
+
+	body, contentType = scrape(t, "application/openmetrics-text; version=1.0.0")
+	if !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, expected OpenMetrics", contentType)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("OpenMetrics body does not end with # EOF")
+	}
+}
@@ -0,0 +1,199 @@
+package output
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const deadLetterReplayUpstream = "_dead_letter_replay"
+
+// deadLetterPath returns the spool file for undeliverable messages
+func (out *Output) deadLetterPath() string {
+	if out.Config != nil && out.Config.DeadLetter != nil && out.Config.DeadLetter.Path != "" {
+		return out.Config.DeadLetter.Path
+	}
+	return filepath.Join(common.GetDataDir(), "dead_letter", out.Id+".jsonl")
+}
+
+// DeadLetterQueue returns the spool of this output, it does not need the output to be running
+func (out *Output) DeadLetterQueue() (*common.DeadLetterQueue, error) {
+	if out.Config == nil || out.Config.DeadLetter == nil {
+		return nil, fmt.Errorf("dead letter is not configured for output %s", out.Id)
+	}
+	return common.GetDeadLetterQueue(out.Id, out.deadLetterPath())
+}
+
+// deadLetterFunc returns the handler passed to producers, nil when dead letter is not configured
+func (out *Output) deadLetterFunc() common.DeadLetterFunc {
+	if out.Config == nil || out.Config.DeadLetter == nil {
+		return nil
+	}
+	return out.writeDeadLetter
+}
+
+// startDeadLetter opens the spool, starts the secondary output if configured and
+// registers the replay channel as an extra upstream. Must be called before the output goroutine starts.
+func (out *Output) startDeadLetter() error {
+	if out.Config == nil || out.Config.DeadLetter == nil {
+		return nil
+	}
+
+	dlq, err := out.DeadLetterQueue()
+	if err != nil {
+		return err
+	}
+
+	out.deadLetterMu.Lock()
+	defer out.deadLetterMu.Unlock()
+	out.deadLetter = dlq
+
+	// A secondary output never forwards to another one, it only spools
+	if out.isDeadLetterSink {
+		return nil
+	}
+
+	replayChan := make(chan map[string]interface{}, 1024)
+	out.replayChan = replayChan
+	out.UpStream[deadLetterReplayUpstream] = &replayChan
+
+	targetID := out.Config.DeadLetter.Output
+	if targetID == "" {
+		return nil
+	}
+	if targetID == out.Id {
+		logger.Warn("Dead letter output references itself, using spool only", "output", out.Id)
+		return nil
+	}
+	if outputResolver == nil {
+		logger.Warn("Dead letter output cannot be resolved, using spool only", "output", out.Id, "dead_letter_output", targetID)
+		return nil
+	}
+	target, ok := outputResolver(targetID)
+	if !ok {
+		logger.Warn("Dead letter output not found, using spool only", "output", out.Id, "dead_letter_output", targetID)
+		return nil
+	}
+
+	sink, err := NewFromExisting(target, out.ProjectNodeSequence+".DEAD_LETTER."+targetID)
+	if err != nil {
+		logger.Warn("Failed to create dead letter output, using spool only", "output", out.Id, "dead_letter_output", targetID, "error", err)
+		return nil
+	}
+	sink.isDeadLetterSink = true
+	sink.SetTestMode() // no sampling for the private instance
+	sinkChan := make(chan map[string]interface{}, 1024)
+	sink.UpStream["_dead_letter."+out.Id] = &sinkChan
+	if err := sink.Start(); err != nil {
+		logger.Warn("Failed to start dead letter output, using spool only", "output", out.Id, "dead_letter_output", targetID, "error", err)
+		return nil
+	}
+
+	out.deadLetterSink = sink
+	out.deadLetterSinkChan = sinkChan
+	logger.Info("Dead letter output started", "output", out.Id, "dead_letter_output", targetID)
+	return nil
+}
+
+// stopDeadLetter stops the secondary output and spools whatever it or the replay channel did not consume.
+// The spool stays open so that producers finishing asynchronously can still write to it.
+func (out *Output) stopDeadLetter() {
+	out.deadLetterMu.Lock()
+	sink, sinkChan, replayChan := out.deadLetterSink, out.deadLetterSinkChan, out.replayChan
+	out.deadLetterSink, out.deadLetterSinkChan, out.replayChan = nil, nil, nil
+	out.deadLetterMu.Unlock()
+
+	if sink != nil {
+		if err := sink.Stop(); err != nil {
+			logger.Warn("Failed to stop dead letter output", "output", out.Id, "dead_letter_output", sink.Id, "error", err)
+		}
+	}
+
+	var leftover []map[string]interface{}
+	for _, ch := range []chan map[string]interface{}{sinkChan, replayChan} {
+		if ch == nil {
+			continue
+		}
+	drain:
+		for {
+			select {
+			case msg := <-ch:
+				leftover = append(leftover, msg)
+			default:
+				break drain
+			}
+		}
+	}
+	if len(leftover) > 0 && out.deadLetter != nil {
+		out.deadLetter.Write(leftover, fmt.Errorf("output stopped before delivery"))
+	}
+}
+
+// writeDeadLetter forwards failed messages to the secondary output, spooling those it cannot take
+func (out *Output) writeDeadLetter(msgs []map[string]interface{}, cause error) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	out.deadLetterMu.RLock()
+	defer out.deadLetterMu.RUnlock()
+
+	forwarded := 0
+	if out.deadLetterSinkChan != nil {
+	forward:
+		for _, m := range msgs {
+			select {
+			case out.deadLetterSinkChan <- m:
+				forwarded++
+			default:
+				break forward
+			}
+		}
+	}
+	if forwarded == len(msgs) {
+		return
+	}
+
+	if out.deadLetter == nil {
+		logger.Warn("Output delivery failed, dropping messages", "id", out.Id, "count", len(msgs)-forwarded, "error", cause)
+		return
+	}
+	out.deadLetter.Write(msgs[forwarded:], cause)
+}
+
+// ReplayDeadLetters takes up to limit spooled records (0 means all) and feeds them through the
+// running output again. Records that cannot be queued in time are put back.
+func (out *Output) ReplayDeadLetters(limit int) (int, error) {
+	if out.Status != common.StatusRunning {
+		return 0, fmt.Errorf("output %s is not running", out.Id)
+	}
+
+	out.deadLetterMu.RLock()
+	replayChan, dlq := out.replayChan, out.deadLetter
+	out.deadLetterMu.RUnlock()
+	if replayChan == nil || dlq == nil {
+		return 0, fmt.Errorf("dead letter is not enabled on output %s", out.Id)
+	}
+
+	records, err := dlq.Take(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := time.NewTimer(10 * time.Second)
+	defer timeout.Stop()
+	for i, rec := range records {
+		select {
+		case replayChan <- rec.Message:
+		case <-timeout.C:
+			dlq.Requeue(records[i:])
+			return i, fmt.Errorf("timeout while replaying, %d records put back", len(records)-i)
+		}
+	}
+	if len(records) > 0 {
+		logger.Info("Dead letter records replayed", "output", out.Id, "count", len(records))
+	}
+	return len(records), nil
+}
@@ -3,8 +3,10 @@ package output
 import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/tracing"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -12,6 +14,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
@@ -24,6 +28,7 @@ const (
 	OutputTypeKafkaAWS      OutputType = "kafka_aws"
 	OutputTypeElasticsearch OutputType = "elasticsearch"
 	OutputTypeAliyunSLS     OutputType = "aliyun_sls"
+	OutputTypeWebhook       OutputType = "webhook"
 	OutputTypePrint         OutputType = "print"
 )
 
@@ -34,6 +39,8 @@ type OutputConfig struct {
 	Kafka         *KafkaOutputConfig         `yaml:"kafka,omitempty"`
 	Elasticsearch *ElasticsearchOutputConfig `yaml:"elasticsearch,omitempty"`
 	AliyunSLS     *AliyunSLSOutputConfig     `yaml:"aliyun_sls,omitempty"`
+	Webhook       *WebhookOutputConfig       `yaml:"webhook,omitempty"`
+	DeadLetter    *DeadLetterConfig          `yaml:"dead_letter,omitempty"`
 	RawConfig     string
 }
 
@@ -65,6 +72,41 @@ type AliyunSLSOutputConfig struct {
 	Logstore        string `yaml:"logstore"`
 }
 
+// WebhookOutputConfig holds webhook-specific config.
+// BodyTemplate is a Go text/template; it receives the message when batch_size is 1, otherwise the list of messages.
+type WebhookOutputConfig struct {
+	URL          string            `yaml:"url"`
+	Method       string            `yaml:"method,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+	BodyTemplate string            `yaml:"body_template,omitempty"`
+	BatchSize    int               `yaml:"batch_size,omitempty"`
+	FlushDur     string            `yaml:"flush_dur,omitempty"`
+	Timeout      string            `yaml:"timeout,omitempty"`
+	MaxRetries   *int              `yaml:"max_retries,omitempty"`
+	RetryBackoff string            `yaml:"retry_backoff,omitempty"`
+	RateLimit    float64           `yaml:"rate_limit,omitempty"` // requests per second
+	SkipVerify   bool              `yaml:"skip_verify,omitempty"`
+}
+
+// DeadLetterConfig holds where undeliverable messages go.
+// Messages are forwarded to Output when set and reachable, otherwise spooled to Path,
+// which defaults to <data_dir>/dead_letter/<output id>.jsonl.
+type DeadLetterConfig struct {
+	Path   string `yaml:"path,omitempty"`
+	Output string `yaml:"output,omitempty"` // secondary output id
+}
+
+// OutputResolverFunc looks up an output component by id
+type OutputResolverFunc func(id string) (*Output, bool)
+
+// outputResolver is a global callback set by the project package, used to start the secondary dead letter output
+var outputResolver OutputResolverFunc
+
+// SetOutputResolver sets the callback used to look up output components by id
+func SetOutputResolver(resolver OutputResolverFunc) {
+	outputResolver = resolver
+}
+
 // Output is the runtime output instance.
 type Output struct {
 	Status              common.Status
@@ -79,12 +121,22 @@ type Output struct {
 	// runtime
 	kafkaProducer         *common.KafkaProducer
 	elasticsearchProducer *common.ElasticsearchProducer
+	webhookProducer       *common.WebhookProducer
 	wg                    sync.WaitGroup
 
+	// dead letter
+	deadLetter         *common.DeadLetterQueue
+	deadLetterSink     *Output // private instance of the secondary output
+	deadLetterSinkChan chan map[string]interface{}
+	deadLetterMu       sync.RWMutex
+	isDeadLetterSink   bool
+	replayChan         chan map[string]interface{}
+
 	// config cache
 	kafkaCfg         *KafkaOutputConfig
 	elasticsearchCfg *ElasticsearchOutputConfig
 	aliyunSLSCfg     *AliyunSLSOutputConfig
+	webhookCfg       *WebhookOutputConfig
 
 	// metrics - only total count is needed now
 	produceTotal      uint64 // cumulative production total
@@ -173,6 +225,38 @@ func Verify(path string, raw string) error {
 			return fmt.Errorf("missing required field 'aliyun_sls' for aliyunSLS output (line: unknown)")
 		}
 		// Add more AliyunSLS specific field validation
+	case OutputTypeWebhook:
+		if cfg.Webhook == nil {
+			return fmt.Errorf("missing required field 'webhook' for webhook output (line: unknown)")
+		}
+		if cfg.Webhook.URL == "" {
+			return fmt.Errorf("missing required field 'webhook.url' for webhook output (line: unknown)")
+		}
+		if u, err := url.Parse(cfg.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook.url value: %s (must be an http or https URL) (line: unknown)", cfg.Webhook.URL)
+		}
+		if cfg.Webhook.BodyTemplate != "" {
+			if _, err := common.ParseWebhookTemplate(cfg.Webhook.BodyTemplate); err != nil {
+				return fmt.Errorf("invalid webhook.body_template: %v (line: unknown)", err)
+			}
+		}
+		for field, v := range map[string]string{"flush_dur": cfg.Webhook.FlushDur, "timeout": cfg.Webhook.Timeout, "retry_backoff": cfg.Webhook.RetryBackoff} {
+			if v == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid webhook.%s value: %s (line: unknown)", field, v)
+			}
+		}
+		if cfg.Webhook.BatchSize < 0 {
+			return fmt.Errorf("webhook.batch_size must not be negative (line: unknown)")
+		}
+		if cfg.Webhook.MaxRetries != nil && *cfg.Webhook.MaxRetries < 0 {
+			return fmt.Errorf("webhook.max_retries must not be negative (line: unknown)")
+		}
+		if cfg.Webhook.RateLimit < 0 {
+			return fmt.Errorf("webhook.rate_limit must not be negative (line: unknown)")
+		}
 	case OutputTypePrint:
 		// Print output doesn't require external connectivity
 	default:
@@ -208,6 +292,7 @@ func NewOutput(path string, raw string, id string) (*Output, error) {
 		kafkaCfg:         cfg.Kafka,
 		elasticsearchCfg: cfg.Elasticsearch,
 		aliyunSLSCfg:     cfg.AliyunSLS,
+		webhookCfg:       cfg.Webhook,
 		Config:           &cfg,
 		sampler:          nil, // Will be set below based on cluster role
 		Status:           common.StatusStopped,
@@ -246,6 +331,13 @@ func (out *Output) cleanup() {
 		out.elasticsearchProducer = nil
 	}
 
+	if out.webhookProducer != nil {
+		out.webhookProducer.Close()
+		out.webhookProducer = nil
+	}
+
+	out.stopDeadLetter()
+
 	// Reset atomic counter
 	atomic.StoreUint64(&out.produceTotal, 0)
 	atomic.StoreUint64(&out.lastReportedTotal, 0)
@@ -269,9 +361,117 @@ func (out *Output) enhanceMessageWithProjectNodeSequence(msg map[string]interfac
 	enhancedMsg["_hub_project_node_sequence"] = out.ProjectNodeSequence
 	enhancedMsg["_hub_output_timestamp"] = time.Now().UTC().Format(time.RFC3339)
 
+	// The trace context stays inside the hub, the trace ID lets the message be looked up in the tracing backend
+	if traceID, ok := tracing.TraceID(msg); ok {
+		enhancedMsg["_hub_trace_id"] = traceID
+	}
+	delete(enhancedMsg, common.TraceParentField)
+	delete(enhancedMsg, common.TraceStateField)
+
 	return enhancedMsg
 }
 
+// startTrace starts the send span of a traced message, it ends once the message is handed to the producer
+func (out *Output) startTrace(msg map[string]interface{}) trace.Span {
+	_, span := tracing.StartMessage(msg, "output")
+	if span.IsRecording() {
+		span.SetName("output " + out.Id)
+		span.SetAttributes(
+			attribute.String("hub.output.id", out.Id),
+			attribute.String("hub.output.type", string(out.Type)),
+			attribute.String("hub.project_node_sequence", out.ProjectNodeSequence),
+		)
+		if hits, ok := msg["_hub_hit_rule_id"].(string); ok {
+			span.SetAttributes(attribute.String("hub.hit_rule_id", hits))
+		}
+	}
+	return span
+}
+
+// forwardToProducer starts the goroutine that reads the upstream channels and hands each enhanced
+// message to the producer reading msgChan, which is closed when the goroutine stops. A full producer
+// channel sends the message to the dead letter. kind names the producer in logs.
+func (out *Output) forwardToProducer(kind string, msgChan chan map[string]interface{}, hasTestCollector bool) {
+	stopChan := out.stopChan
+	out.wg.Add(1)
+	go func() {
+		defer out.wg.Done()
+		defer close(msgChan) // Close msgChan when UpStream processing is done
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Panic in output goroutine", "output", out.Id, "type", kind, "panic", r)
+				// Don't change status here as it may conflict with stop process
+			}
+		}()
+
+		// Use ticker for more predictable exit timing
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopChan:
+				logger.Debug("Output goroutine received stop signal", "id", out.Id, "type", kind)
+				return
+			case <-ticker.C:
+				// Non-blocking check for messages from any upstream channel
+				for _, up := range out.UpStream {
+					// Check stop signal again during loop iteration
+					select {
+					case <-stopChan:
+						logger.Debug("Output goroutine received stop signal during upstream processing", "id", out.Id, "type", kind)
+						return
+					default:
+					}
+
+					select {
+					case msg, ok := <-*up:
+						if !ok {
+							// Channel is closed, skip this channel
+							continue
+						}
+
+						// Count immediately at upstream read to ensure all messages are counted
+						atomic.AddUint64(&out.produceTotal, 1)
+
+						// Sample the message
+						if out.sampler != nil {
+							out.sampler.Sample(msg, out.ProjectNodeSequence)
+						}
+
+						// Enhance message with ProjectNodeSequence information before sending
+						span := out.startTrace(msg)
+						enhancedMsg := out.enhanceMessageWithProjectNodeSequence(msg)
+
+						// Duplicate to TestCollectionChan if present (non-blocking)
+						if hasTestCollector {
+							select {
+							case *out.TestCollectionChan <- enhancedMsg:
+							default:
+								logger.Warn("Test collection channel full, dropping message", "id", out.Id, "type", kind)
+							}
+						}
+
+						// Send enhanced message to msgChan for the producer (non-blocking during shutdown)
+						var sendErr error
+						select {
+						case msgChan <- enhancedMsg:
+							// Message sent successfully
+						default:
+							// Channel is full, hand over to dead letter (dropped if not configured)
+							sendErr = fmt.Errorf("%s producer channel full", kind)
+							out.writeDeadLetter([]map[string]interface{}{enhancedMsg}, sendErr)
+						}
+						tracing.End(span, sendErr)
+					default:
+						// No message available from this channel, continue to next
+					}
+				}
+			}
+		}
+	}()
+}
+
 // StartForTesting starts the output component in testing mode
 // In testing mode, completely ignore output type and only send data to TestCollectionChan
 func (out *Output) StartForTesting() error {
@@ -401,6 +601,20 @@ func (out *Output) Start() error {
 		logger.Info("Output connectivity verified", "output", out.Id, "type", out.Type)
 	}
 
+	// Set up dead letter handling before producers so that early failures are kept
+	if out.Type != OutputTypePrint {
+		if err := out.startDeadLetter(); err != nil {
+			out.SetStatus(common.StatusError, fmt.Errorf("failed to set up dead letter for output %s: %v", out.Id, err))
+			return fmt.Errorf("failed to set up dead letter for output %s: %v", out.Id, err)
+		}
+	}
+	started := false
+	defer func() {
+		if !started {
+			out.stopDeadLetter()
+		}
+	}()
+
 	// Determine if we need to duplicate data for testing
 	hasTestCollector := out.TestCollectionChan != nil
 
@@ -426,6 +640,7 @@ func (out *Output) Start() error {
 			msgChan,
 			out.kafkaCfg.Key,
 			out.kafkaCfg.TLS,
+			out.deadLetterFunc(),
 		)
 		if err != nil {
 			out.SetStatus(common.StatusError, fmt.Errorf("failed to create kafka producer for output %s: %v", out.Id, err))
@@ -436,97 +651,7 @@ func (out *Output) Start() error {
 		// Initialize stop channel for this output
 		out.stopChan = make(chan struct{})
 
-		// Start goroutine to read from UpStream and send enhanced messages to msgChan for Kafka producer
-		out.wg.Add(1)
-		go func() {
-			defer out.wg.Done()
-			defer close(msgChan) // Close msgChan when UpStream processing is done
-			defer func() {
-				if r := recover(); r != nil {
-					logger.Error("Panic in kafka output goroutine", "output", out.Id, "panic", r)
-					// Don't change status here as it may conflict with stop process
-				}
-			}()
-
-			// Use ticker for more predictable exit timing
-			ticker := time.NewTicker(10 * time.Millisecond)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-out.stopChan:
-					logger.Debug("Kafka output goroutine received stop signal", "id", out.Id)
-					return
-				case <-ticker.C:
-					// Check for stop signal before processing
-					select {
-					case <-out.stopChan:
-						logger.Debug("Kafka output goroutine received stop signal before processing", "id", out.Id)
-						return
-					default:
-					}
-
-					// Non-blocking check for messages from any upstream channel
-					for _, up := range out.UpStream {
-						// Check stop signal again during loop iteration
-						select {
-						case <-out.stopChan:
-							logger.Debug("Kafka output goroutine received stop signal during upstream processing", "id", out.Id)
-							return
-						default:
-						}
-
-						select {
-						case msg, ok := <-*up:
-							if !ok {
-								// Channel is closed, skip this channel
-								continue
-							}
-
-							// Always count/sample; duplication handled below
-							// Count immediately at upstream read to ensure all messages are counted
-							atomic.AddUint64(&out.produceTotal, 1)
-
-							// Sample the message
-							if out.sampler != nil {
-								out.sampler.Sample(msg, out.ProjectNodeSequence)
-							}
-
-							// Enhance message with ProjectNodeSequence information before sending
-							enhancedMsg := out.enhanceMessageWithProjectNodeSequence(msg)
-
-							// Duplicate to TestCollectionChan if present (non-blocking)
-							if hasTestCollector {
-								select {
-								case *out.TestCollectionChan <- enhancedMsg:
-								default:
-									logger.Warn("Test collection channel full, dropping message", "id", out.Id, "type", "kafka")
-								}
-							}
-
-							// Send enhanced message to msgChan for Kafka producer (non-blocking during shutdown)
-							select {
-							case msgChan <- enhancedMsg:
-								// Message sent successfully
-							default:
-								// Channel is full, log warning and continue
-								logger.Warn("Kafka producer channel full, dropping message", "id", out.Id)
-							}
-						default:
-							// No message available from this channel, continue to next
-						}
-					}
-
-					// Final check for stop signal after processing
-					select {
-					case <-out.stopChan:
-						logger.Debug("Kafka output goroutine received stop signal after processing", "id", out.Id)
-						return
-					default:
-					}
-				}
-			}
-		}()
+		out.forwardToProducer("kafka", msgChan, hasTestCollector)
 
 	case OutputTypeElasticsearch:
 		if out.elasticsearchProducer != nil {
@@ -556,6 +681,7 @@ func (out *Output) Start() error {
 			batchSize,
 			flushDur,
 			out.elasticsearchCfg.Auth,
+			out.deadLetterFunc(),
 		)
 		if err != nil {
 			out.SetStatus(common.StatusError, fmt.Errorf("failed to create elasticsearch producer for output %s: %v", out.Id, err))
@@ -568,96 +694,68 @@ func (out *Output) Start() error {
 			out.stopChan = make(chan struct{})
 		}
 
-		// Start goroutine to read from UpStream and send enhanced messages to msgChan for Elasticsearch producer
-		out.wg.Add(1)
-		go func() {
-			defer out.wg.Done()
-			defer close(msgChan) // Close msgChan when UpStream processing is done
-			defer func() {
-				if r := recover(); r != nil {
-					logger.Error("Panic in elasticsearch output goroutine", "output", out.Id, "panic", r)
-					// Don't change status here as it may conflict with stop process
-				}
-			}()
-
-			// Use ticker for more predictable exit timing
-			ticker := time.NewTicker(10 * time.Millisecond)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-out.stopChan:
-					logger.Debug("Elasticsearch output goroutine received stop signal", "id", out.Id)
-					return
-				case <-ticker.C:
-					// Check for stop signal before processing
-					select {
-					case <-out.stopChan:
-						logger.Debug("Elasticsearch output goroutine received stop signal before processing", "id", out.Id)
-						return
-					default:
-					}
-
-					// Non-blocking check for messages from any upstream channel
-					for _, up := range out.UpStream {
-						// Check stop signal again during loop iteration
-						select {
-						case <-out.stopChan:
-							logger.Debug("Elasticsearch output goroutine received stop signal during upstream processing", "id", out.Id)
-							return
-						default:
-						}
+		out.forwardToProducer("elasticsearch", msgChan, hasTestCollector)
 
-						select {
-						case msg, ok := <-*up:
-							if !ok {
-								// Channel is closed, skip this channel
-								continue
-							}
-
-							// Always count/sample; duplication handled separately
-							// Count immediately at upstream read to ensure all messages are counted
-							atomic.AddUint64(&out.produceTotal, 1)
-
-							// Sample the message
-							if out.sampler != nil {
-								out.sampler.Sample(msg, out.ProjectNodeSequence)
-							}
-
-							// Enhance message with ProjectNodeSequence information before sending
-							enhancedMsg := out.enhanceMessageWithProjectNodeSequence(msg)
+	case OutputTypeWebhook:
+		if out.webhookProducer != nil {
+			out.SetStatus(common.StatusError, fmt.Errorf("webhook producer already running for output %s", out.Id))
+			return fmt.Errorf("webhook producer already running for output %s", out.Id)
+		}
+		if out.webhookCfg == nil {
+			out.SetStatus(common.StatusError, fmt.Errorf("webhook configuration missing for output %s", out.Id))
+			return fmt.Errorf("webhook configuration missing for output %s", out.Id)
+		}
 
-							if hasTestCollector {
-								select {
-								case *out.TestCollectionChan <- enhancedMsg:
-								default:
-									logger.Warn("Test collection channel full, dropping message", "id", out.Id, "type", "elasticsearch")
-								}
-							}
+		msgChan := make(chan map[string]interface{}, 1024)
+		flushDur := time.Second
+		if out.webhookCfg.FlushDur != "" {
+			if d, err := time.ParseDuration(out.webhookCfg.FlushDur); err == nil {
+				flushDur = d
+			}
+		}
+		timeout := 10 * time.Second
+		if out.webhookCfg.Timeout != "" {
+			if d, err := time.ParseDuration(out.webhookCfg.Timeout); err == nil {
+				timeout = d
+			}
+		}
+		backoff := time.Second
+		if out.webhookCfg.RetryBackoff != "" {
+			if d, err := time.ParseDuration(out.webhookCfg.RetryBackoff); err == nil {
+				backoff = d
+			}
+		}
+		maxRetries := 3
+		if out.webhookCfg.MaxRetries != nil {
+			maxRetries = *out.webhookCfg.MaxRetries
+		}
+		producer, err := common.NewWebhookProducer(
+			out.webhookCfg.URL,
+			out.webhookCfg.Method,
+			out.webhookCfg.Headers,
+			out.webhookCfg.BodyTemplate,
+			out.webhookCfg.BatchSize,
+			flushDur,
+			timeout,
+			maxRetries,
+			backoff,
+			out.webhookCfg.RateLimit,
+			out.webhookCfg.SkipVerify,
+			out.deadLetterFunc(),
+			msgChan,
+		)
+		if err != nil {
+			out.SetStatus(common.StatusError, fmt.Errorf("failed to create webhook producer for output %s: %v", out.Id, err))
+			return fmt.Errorf("failed to create webhook producer for output %s: %v", out.Id, err)
+		}
+		out.webhookProducer = producer
 
-							// Send enhanced message to msgChan for Elasticsearch producer (non-blocking during shutdown)
-							select {
-							case msgChan <- enhancedMsg:
-								// Message sent successfully
-							default:
-								// Channel is full, log warning and continue
-								logger.Warn("Elasticsearch producer channel full, dropping message", "id", out.Id)
-							}
-						default:
-							// No message available from this channel, continue to next
-						}
-					}
+		// Initialize stop channel for this output (if not already initialized)
+		if out.stopChan == nil {
+			out.stopChan = make(chan struct{})
+		}
 
-					// Final check for stop signal after processing
-					select {
-					case <-out.stopChan:
-						logger.Debug("Elasticsearch output goroutine received stop signal after processing", "id", out.Id)
-						return
-					default:
-					}
-				}
-			}
-		}()
+		out.forwardToProducer("webhook", msgChan, hasTestCollector)
 
 	case OutputTypePrint:
 		// Initialize stop channel for this output (if not already initialized)
@@ -728,9 +826,11 @@ func (out *Output) Start() error {
 							}
 
 							// Enhance message with ProjectNodeSequence information for actual output
+							span := out.startTrace(msg)
 							enhancedMsg := out.enhanceMessageWithProjectNodeSequence(msg)
 							data, _ := json.Marshal(enhancedMsg)
 							logger.Info("[Print Output]", "data", string(data))
+							span.End()
 						default:
 							// No message available from this channel, continue to next
 						}
@@ -752,6 +852,7 @@ func (out *Output) Start() error {
 		return fmt.Errorf("aliyun SLS output not implemented yet")
 	}
 
+	started = true
 	out.SetStatus(common.StatusRunning, nil)
 	return nil
 }
@@ -791,6 +892,14 @@ func (out *Output) Stop() error {
 		out.elasticsearchProducer.Close()
 		out.elasticsearchProducer = nil
 	}
+	if out.webhookProducer != nil {
+		logger.Debug("Closing webhook producer", "id", out.Id)
+		out.webhookProducer.Close()
+		out.webhookProducer = nil
+	}
+
+	// Stop the dead letter output after producers so their last failures are still forwarded
+	out.stopDeadLetter()
 
 	// Step 3: Wait for goroutines to finish with timeout and force cleanup if needed
 	logger.Info("Waiting for output goroutines to finish", "id", out.Id)
@@ -1043,6 +1152,59 @@ func (out *Output) CheckConnectivity() map[string]interface{} {
 			}
 		}
 
+	case OutputTypeWebhook:
+		if out.webhookCfg == nil {
+			result["status"] = "error"
+			result["message"] = "Webhook configuration missing"
+			result["details"].(map[string]interface{})["connection_status"] = "not_configured"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": "Webhook configuration is incomplete or missing", "severity": "error"},
+			}
+			return result
+		}
+
+		// Set connection info (headers may carry credentials, only report their names)
+		headerNames := make([]string, 0, len(out.webhookCfg.Headers))
+		for k := range out.webhookCfg.Headers {
+			headerNames = append(headerNames, k)
+		}
+		connectionInfo := map[string]interface{}{
+			"url":     out.webhookCfg.URL,
+			"method":  out.webhookCfg.Method,
+			"headers": headerNames,
+		}
+		if out.Config != nil && out.Config.DeadLetter != nil {
+			connectionInfo["dead_letter"] = out.deadLetterPath()
+		}
+		result["details"].(map[string]interface{})["connection_info"] = connectionInfo
+
+		// Any HTTP response means the endpoint is reachable; the payload is not sent during the check
+		err := common.TestWebhookConnection(out.webhookCfg.URL, out.webhookCfg.SkipVerify)
+		if err != nil {
+			result["status"] = "error"
+			result["message"] = "Failed to connect to webhook endpoint"
+			result["details"].(map[string]interface{})["connection_status"] = "connection_failed"
+			result["details"].(map[string]interface{})["connection_errors"] = []map[string]interface{}{
+				{"message": err.Error(), "severity": "error"},
+			}
+			return result
+		}
+		result["details"].(map[string]interface{})["connection_status"] = "connected"
+		result["message"] = "Webhook endpoint is reachable"
+
+		// Add producer metrics if available
+		if out.webhookProducer != nil {
+			result["details"].(map[string]interface{})["metrics"] = map[string]interface{}{
+				"produce_total":   out.GetProduceTotal(),
+				"producer_active": true,
+				"batch_size":      out.webhookProducer.BatchSize,
+			}
+		} else {
+			result["details"].(map[string]interface{})["metrics"] = map[string]interface{}{
+				"producer_active": false,
+			}
+		}
+
 	case OutputTypePrint:
 		// Print output doesn't require external connectivity testing
 		result["status"] = "success"
@@ -1173,6 +1335,7 @@ func NewFromExisting(existing *Output, newProjectNodeSequence string) (*Output,
 		kafkaCfg:            existing.kafkaCfg,
 		elasticsearchCfg:    existing.elasticsearchCfg,
 		aliyunSLSCfg:        existing.aliyunSLSCfg,
+		webhookCfg:          existing.webhookCfg,
 		Config:              existing.Config,
 		Status:              common.StatusStopped, // Initialize status to stopped
 		TestCollectionChan:  nil,                  // Reset for new instance
@@ -1213,7 +1376,17 @@ func (out *Output) GetPendingMessageCount() int {
 		if out.elasticsearchProducer != nil && out.elasticsearchProducer.MsgChan != nil {
 			pendingCount += len(out.elasticsearchProducer.MsgChan)
 		}
+	case OutputTypeWebhook:
+		if out.webhookProducer != nil && out.webhookProducer.MsgChan != nil {
+			pendingCount += len(out.webhookProducer.MsgChan)
+		}
+	}
+
+	out.deadLetterMu.RLock()
+	if out.deadLetterSinkChan != nil {
+		pendingCount += len(out.deadLetterSinkChan)
 	}
+	out.deadLetterMu.RUnlock()
 
 	return pendingCount
 }
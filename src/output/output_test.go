@@ -0,0 +1,48 @@
+package output
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/tracing"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnhanceMessageTraceFields(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+	if err := tracing.Start(common.TracingConfig{Enabled: true, Endpoint: collector.URL, SampleRatio: 1}); err != nil {
+		t.Fatalf("tracing.Start failed: %v", err)
+	}
+	defer tracing.Stop()
+
+	out := &Output{Id: "alerts", ProjectNodeSequence: "INPUT.in.RULESET.rs.OUTPUT.alerts"}
+	msg := map[string]interface{}{"data": "x"}
+	span := tracing.StartInput(msg, "input")
+	defer span.End()
+
+	enhanced := out.enhanceMessageWithProjectNodeSequence(msg)
+	if enhanced["_hub_trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("_hub_trace_id = %v, expected %s", enhanced["_hub_trace_id"], span.SpanContext().TraceID())
+	}
+	for _, field := range []string{common.TraceParentField, common.TraceStateField} {
+		if _, ok := enhanced[field]; ok {
+			t.Errorf("enhanced message keeps %s, the trace context must not leave the hub", field)
+		}
+	}
+	if enhanced["_hub_project_node_sequence"] != out.ProjectNodeSequence || enhanced["data"] != "x" {
+		t.Errorf("enhanced message = %v, expected the message with the project node sequence", enhanced)
+	}
+	if _, ok := msg[common.TraceParentField]; !ok {
+		t.Error("the original message lost its trace context")
+	}
+
+	// Messages that are not traced get no trace ID
+	enhanced = out.enhanceMessageWithProjectNodeSequence(map[string]interface{}{"data": "y"})
+	for k := range enhanced {
+		if strings.HasPrefix(k, "_hub_trace") {
+			t.Errorf("untraced message has %s = %v", k, enhanced[k])
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/ristretto/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Plugin result cache for rules like <append type="PLUGIN" field="vt" cache_ttl="1h">virustotal(_$hash)</append>.
+// Results are keyed on the plugin, its code version and the resolved arguments. Lookups go through a
+// local ristretto tier first and then a Redis tier shared by the cluster (skipped with local_cache="true").
+// Only real results are cached: errors, failed calls (ok == false) and fallback values never are.
+// Cached values are stored as JSON, so only results made of JSON types (nil, bool, float64, string,
+// []interface{} and map[string]interface{}) are cached: they decode to the same value, a hit returns
+// exactly what the call returned. Other results (int, structs, typed maps...) are not cached.
+
+const (
+	pluginCacheRedisPrefix = "hub:plugin_cache:"
+
+	// pluginCacheMaxCost bounds the local tier, cost is the size of the encoded result in bytes
+	pluginCacheMaxCost = 128 * 1024 * 1024
+)
+
+var (
+	pluginResultCache     *ristretto.Cache[string, []byte]
+	pluginResultCacheOnce sync.Once
+)
+
+// pluginCacheCounters are the cache counters of one plugin on this node
+type pluginCacheCounters struct {
+	localHits uint64
+	redisHits uint64
+	misses    uint64
+	errors    uint64
+}
+
+// CacheStats describes the result cache usage of a plugin on this node
+type CacheStats struct {
+	LocalHits uint64  `json:"local_hits"`
+	RedisHits uint64  `json:"redis_hits"`
+	Misses    uint64  `json:"misses"`
+	Errors    uint64  `json:"errors"`
+	HitRatio  float64 `json:"hit_ratio"`
+}
+
+type cachedResult struct {
+	Result interface{} `json:"r"`
+}
+
+func getPluginResultCache() *ristretto.Cache[string, []byte] {
+	pluginResultCacheOnce.Do(func() {
+		cache, err := ristretto.NewCache(&ristretto.Config[string, []byte]{
+			NumCounters: 1_000_000,          // number of keys to track frequency of.
+			MaxCost:     pluginCacheMaxCost, // maximum cost of cache (bytes of encoded results).
+			BufferItems: 64,                 // number of keys per Get buffer.
+		})
+		if err != nil {
+			logger.PluginError("failed to create plugin result cache, only redis is used", "error", err)
+			return
+		}
+		pluginResultCache = cache
+	})
+	return pluginResultCache
+}
+
+// cacheKey returns the cache key of a call, changing the plugin code invalidates its entries
+func (p *Plugin) cacheKey(funcArgs []interface{}) (string, error) {
+	p.cacheKeyOnce.Do(func() {
+		prefix := p.Name
+		if len(p.Payload) > 0 {
+			sum := sha256.Sum256(p.Payload)
+			prefix += ":" + hex.EncodeToString(sum[:6])
+		}
+		p.cacheKeyPrefix = prefix
+	})
+
+	args, err := json.Marshal(funcArgs)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(args)
+	return p.cacheKeyPrefix + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// cacheGet looks a call up in the local tier and then in Redis, Redis hits are copied to the local tier
+func (p *Plugin) cacheGet(key string, ttl time.Duration, localOnly bool) (*cachedResult, bool) {
+	local := getPluginResultCache()
+	var raw []byte
+	found := false
+
+	if local != nil {
+		if v, ok := local.Get(key); ok {
+			raw = v
+			found = true
+			atomic.AddUint64(&p.cacheStats.localHits, 1)
+		}
+	}
+
+	if !found && !localOnly {
+		val, err := common.RedisGet(pluginCacheRedisPrefix + key)
+		if err == nil {
+			raw = []byte(val)
+			found = true
+			atomic.AddUint64(&p.cacheStats.redisHits, 1)
+			if local != nil {
+				local.SetWithTTL(key, raw, int64(len(raw)), ttl)
+			}
+		} else if !errors.Is(err, redis.Nil) {
+			atomic.AddUint64(&p.cacheStats.errors, 1)
+			logger.PluginError("plugin cache redis get failed", "plugin", p.Name, "error", err)
+		}
+	}
+
+	if !found {
+		atomic.AddUint64(&p.cacheStats.misses, 1)
+		return nil, false
+	}
+
+	var res cachedResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		atomic.AddUint64(&p.cacheStats.errors, 1)
+		return nil, false
+	}
+	return &res, true
+}
+
+// isJSONNative reports whether v is made only of the types json.Unmarshal produces, so that it survives
+// the cache unchanged. NaN and infinities cannot be encoded.
+func isJSONNative(v interface{}) bool {
+	switch x := v.(type) {
+	case nil, bool, string:
+		return true
+	case float64:
+		return !math.IsNaN(x) && !math.IsInf(x, 0)
+	case []interface{}:
+		for _, e := range x {
+			if !isJSONNative(e) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, e := range x {
+			if !isJSONNative(e) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// cacheSet stores a result in the local tier and, unless localOnly, in Redis
+func (p *Plugin) cacheSet(key string, res cachedResult, ttl time.Duration, localOnly bool) {
+	if !isJSONNative(res.Result) {
+		p.uncachedOnce.Do(func() {
+			logger.PluginWarn("plugin result is not cached, only results made of JSON types (bool, float64, string, []interface{}, map[string]interface{}) are", "plugin", p.Name, "type", fmt.Sprintf("%T", res.Result))
+		})
+		return
+	}
+	raw, err := json.Marshal(res)
+	if err != nil {
+		atomic.AddUint64(&p.cacheStats.errors, 1)
+		logger.PluginError("plugin result cannot be cached", "plugin", p.Name, "error", err)
+		return
+	}
+
+	if local := getPluginResultCache(); local != nil {
+		local.SetWithTTL(key, raw, int64(len(raw)), ttl)
+	}
+
+	if !localOnly {
+		if _, err := common.RedisSet(pluginCacheRedisPrefix+key, raw, int(ttl.Seconds())); err != nil {
+			atomic.AddUint64(&p.cacheStats.errors, 1)
+			logger.PluginError("plugin cache redis set failed", "plugin", p.Name, "error", err)
+		}
+	}
+}
+
+// FuncEvalCheckNodeCached is FuncEvalCheckNode with a result cache of ttlSeconds
+func (p *Plugin) FuncEvalCheckNodeCached(ttlSeconds int, localOnly bool, funcArgs ...interface{}) (bool, error) {
+	if ttlSeconds <= 0 {
+		return p.FuncEvalCheckNode(funcArgs...)
+	}
+	key, err := p.cacheKey(funcArgs)
+	if err != nil {
+		atomic.AddUint64(&p.cacheStats.errors, 1)
+		return p.FuncEvalCheckNode(funcArgs...)
+	}
+
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if cached, ok := p.cacheGet(key, ttl, localOnly); ok {
+		if result, isBool := cached.Result.(bool); isBool {
+			return result, nil
+		}
+	}
+
+	result, fromFallback, err := p.guardedEvalCheckNode(funcArgs...)
+	if err == nil && !fromFallback {
+		p.cacheSet(key, cachedResult{Result: result}, ttl, localOnly)
+	}
+	return result, err
+}
+
+// FuncEvalOtherCached is FuncEvalOther with a result cache of ttlSeconds
+func (p *Plugin) FuncEvalOtherCached(ttlSeconds int, localOnly bool, funcArgs ...interface{}) (interface{}, bool, error) {
+	if ttlSeconds <= 0 {
+		return p.FuncEvalOther(funcArgs...)
+	}
+	key, err := p.cacheKey(funcArgs)
+	if err != nil {
+		atomic.AddUint64(&p.cacheStats.errors, 1)
+		return p.FuncEvalOther(funcArgs...)
+	}
+
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if cached, ok := p.cacheGet(key, ttl, localOnly); ok {
+		return cached.Result, true, nil
+	}
+
+	result, success, fromFallback, err := p.guardedEvalOther(funcArgs...)
+	if err == nil && success && !fromFallback {
+		p.cacheSet(key, cachedResult{Result: result}, ttl, localOnly)
+	}
+	return result, success, err
+}
+
+// CacheStats returns the result cache counters of the plugin, nil when it was never called with a cache
+func (p *Plugin) CacheStats() *CacheStats {
+	s := CacheStats{
+		LocalHits: atomic.LoadUint64(&p.cacheStats.localHits),
+		RedisHits: atomic.LoadUint64(&p.cacheStats.redisHits),
+		Misses:    atomic.LoadUint64(&p.cacheStats.misses),
+		Errors:    atomic.LoadUint64(&p.cacheStats.errors),
+	}
+	lookups := s.LocalHits + s.RedisHits + s.Misses
+	if lookups == 0 && s.Errors == 0 {
+		return nil
+	}
+	if lookups > 0 {
+		s.HitRatio = float64(s.LocalHits+s.RedisHits) / float64(lookups)
+	}
+	return &s
+}
+
+// GetAllCacheStats returns result cache stats of all plugins that use the cache on this node
+func GetAllCacheStats() map[string]CacheStats {
+	PluginsMu.RLock()
+	defer PluginsMu.RUnlock()
+
+	res := make(map[string]CacheStats)
+	for name, p := range Plugins {
+		if s := p.CacheStats(); s != nil {
+			res[name] = *s
+		}
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/local_plugin"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestIsJSONNative(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		native bool
+	}{
+		{"nil", nil, true},
+		{"bool", true, true},
+		{"string", "x", true},
+		{"float64", 1.5, true},
+		{"nested", map[string]interface{}{"a": []interface{}{1.0, "b", nil}}, true},
+		{"int", 1, false},
+		{"NaN", math.NaN(), false},
+		{"typed map", map[string]string{"a": "b"}, false},
+		{"int in slice", []interface{}{1.0, 2}, false},
+		{"int in map", map[string]interface{}{"n": int64(1)}, false},
+		{"struct", struct{ A string }{"a"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if native := isJSONNative(test.value); native != test.native {
+				t.Errorf("isJSONNative(%#v) = %v, expected %v", test.value, native, test.native)
+			}
+		})
+	}
+}
+
+// TestPluginCacheHitEqualsMiss checks that a cached result is the value the call returned, from both tiers
+func TestPluginCacheHitEqualsMiss(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	withPluginConfig(t, nil)
+
+	tests := []struct {
+		name   string
+		result interface{}
+		cached bool
+	}{
+		{"string", "malicious", true},
+		{"float", 42.5, true},
+		{"map", map[string]interface{}{"score": 90.0, "tags": []interface{}{"c2", "tor"}, "asn": nil}, true},
+		{"int", 42, false},
+		{"int64 in map", map[string]interface{}{"score": int64(90)}, false},
+		{"typed map", map[string]string{"owner": "ops"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := "cache_test_" + test.name
+			calls := 0
+			local_plugin.LocalPluginInterfaceAndBoolRes[name] = func(args ...interface{}) (interface{}, bool, error) {
+				calls++
+				return test.result, true, nil
+			}
+			defer delete(local_plugin.LocalPluginInterfaceAndBoolRes, name)
+			p := &Plugin{Name: name, Type: LOCAL_PLUGIN, IsTestMode: true}
+
+			miss, ok, err := p.FuncEvalOtherCached(60, false, "1.2.3.4")
+			if err != nil || !ok {
+				t.Fatalf("FuncEvalOtherCached() = %v, %v, %v", miss, ok, err)
+			}
+			getPluginResultCache().Wait()
+			localHit, _, _ := p.FuncEvalOtherCached(60, false, "1.2.3.4")
+
+			getPluginResultCache().Clear()
+			redisHit, _, _ := p.FuncEvalOtherCached(60, false, "1.2.3.4")
+
+			for tier, got := range map[string]interface{}{"miss": miss, "local hit": localHit, "redis hit": redisHit} {
+				if !reflect.DeepEqual(got, test.result) {
+					t.Errorf("%s = %#v, expected %#v", tier, got, test.result)
+				}
+			}
+			expectedCalls := 1
+			if !test.cached {
+				expectedCalls = 3
+			}
+			if calls != expectedCalls {
+				t.Errorf("plugin called %d times, expected %d", calls, expectedCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package plugin
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InvocationDuration is the latency of plugin calls, including time spent waiting for a concurrency
+// slot and calls cut short by their timeout. Cached results are not calls and are not observed.
+var InvocationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "agentsmith_hub",
+	Subsystem: "plugin",
+	Name:      "invocation_duration_seconds",
+	Help:      "Latency of plugin invocations.",
+	Buckets:   []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+}, []string{"plugin"})
+
+// observeDuration records the latency of a call that started at start
+func (p *Plugin) observeDuration(start time.Time) {
+	if p.IsTestMode {
+		return
+	}
+	InvocationDuration.WithLabelValues(p.Name).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/local_plugin"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func invocationCount(t *testing.T, name string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := InvocationDuration.WithLabelValues(name).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestObserveDuration(t *testing.T) {
+	withPluginConfig(t, nil)
+	name := "duration_test"
+	local_plugin.LocalPluginInterfaceAndBoolRes[name] = func(args ...interface{}) (interface{}, bool, error) {
+		return "ok", true, nil
+	}
+	defer delete(local_plugin.LocalPluginInterfaceAndBoolRes, name)
+	defer InvocationDuration.DeleteLabelValues(name)
+
+	p := &Plugin{Name: name, Type: LOCAL_PLUGIN}
+	p.FuncEvalOther()
+	p.FuncEvalOther()
+	if n := invocationCount(t, name); n != 2 {
+		t.Errorf("observed %d calls, expected 2", n)
+	}
+
+	// Test mode calls are not observed
+	testPlugin := &Plugin{Name: name, Type: LOCAL_PLUGIN, IsTestMode: true}
+	testPlugin.FuncEvalOther()
+	if n := invocationCount(t, name); n != 2 {
+		t.Errorf("observed %d calls after a test mode call, expected 2", n)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/traefik/yaegi/interp"
 	"github.com/traefik/yaegi/stdlib"
@@ -59,6 +60,22 @@ type Plugin struct {
 	// Statistics difference counters for increment calculation (like other components)
 	lastReportedSuccessTotal uint64 // Last reported success total for increment calculation
 	lastReportedFailureTotal uint64 // Last reported failure total for increment calculation
+
+	// Runtime limits (timeout, concurrency, circuit breaker), see runtime.go
+	guardOnce    sync.Once
+	runtimeGuard *pluginGuard
+
+	// Optional lifecycle and plugin-scoped state, see state.go
+	initFunc  reflect.Value
+	closeFunc reflect.Value
+	closeOnce sync.Once
+	state     *pluginState
+
+	// Result cache counters and key prefix, see cache.go
+	cacheStats     pluginCacheCounters
+	cacheKeyOnce   sync.Once
+	cacheKeyPrefix string
+	uncachedOnce   sync.Once
 }
 
 // PluginParameter represents a function parameter
@@ -149,9 +166,20 @@ func NewPlugin(path string, raw string, name string, pluginType int) error {
 		return fmt.Errorf("plugin yaegi load err %s: %w", name, err)
 	}
 
+	err = p.start()
+	if err != nil {
+		p.Close()
+		return fmt.Errorf("plugin init err %s: %w", name, err)
+	}
+
 	PluginsMu.Lock()
+	old := Plugins[p.Name]
 	Plugins[p.Name] = p
 	PluginsMu.Unlock()
+
+	if old != nil && old != p {
+		old.retire()
+	}
 	return nil
 }
 
@@ -178,7 +206,13 @@ func NewTestPlugin(path string, raw string, name string, pluginType int) (*Plugi
 		return nil, fmt.Errorf("plugin yaegi load err %s: %w", name, err)
 	}
 
-	// For test plugins, do NOT add to global registry
+	err = p.start()
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin init err %s: %w", name, err)
+	}
+
+	// For test plugins, do NOT add to global registry, the caller must Close them
 	return p, nil
 }
 
@@ -190,6 +224,13 @@ func (p *Plugin) yaegiLoad() error {
 		return err
 	}
 
+	// Plugin-scoped state store, a reload starts with an empty local store
+	p.state = newPluginState()
+	err = p.yaegiIntp.Use(p.stateExports())
+	if err != nil {
+		return err
+	}
+
 	_, err = p.yaegiIntp.Eval(string(p.Payload))
 	if err != nil {
 		return err
@@ -208,6 +249,12 @@ func (p *Plugin) yaegiLoad() error {
 		return err
 	}
 
+	// Resolve optional Init and Close functions
+	err = p.loadLifecycleFuncs()
+	if err != nil {
+		return err
+	}
+
 	// Parse plugin parameters for autocomplete
 	p.parsePluginParameters()
 
@@ -387,6 +434,41 @@ func (p *Plugin) formatTypeString(t reflect.Type) string {
 }
 
 func (p *Plugin) FuncEvalCheckNode(funcArgs ...interface{}) (bool, error) {
+	result, _, err := p.guardedEvalCheckNode(funcArgs...)
+	return result, err
+}
+
+// guardedEvalCheckNode runs evalCheckNode within the runtime limits, the second result reports
+// that the configured fallback was returned instead of a real result
+func (p *Plugin) guardedEvalCheckNode(funcArgs ...interface{}) (bool, bool, error) {
+	defer p.observeDuration(time.Now())
+
+	g := p.guard()
+	if g == nil {
+		result, err := p.evalCheckNode(funcArgs...)
+		p.RecordInvocation(err == nil)
+		return result, false, err
+	}
+
+	// The call keeps running after a timeout, so it writes to locals that are only read on success
+	var result bool
+	var err error
+	if guardErr := g.run(p.Name, func() error {
+		result, err = p.evalCheckNode(funcArgs...)
+		return err
+	}); guardErr != nil {
+		p.RecordInvocation(false)
+		if g.hasFallback {
+			return g.fallback.(bool), true, nil
+		}
+		return false, false, guardErr
+	}
+	p.RecordInvocation(err == nil)
+	return result, false, err
+}
+
+// evalCheckNode calls a (bool, error) plugin function with panic recovery
+func (p *Plugin) evalCheckNode(funcArgs ...interface{}) (bool, error) {
 	var realArgs []reflect.Value
 
 	switch p.Type {
@@ -401,7 +483,7 @@ func (p *Plugin) FuncEvalCheckNode(funcArgs ...interface{}) (bool, error) {
 					if r := recover(); r != nil {
 						logger.PluginError("local plugin execution panicked", "plugin", p.Name, "panic", r)
 						result = false
-						err = fmt.Errorf("local plugin execution panicked: %v", r)
+						err = fmt.Errorf("local %w: %v", ErrPluginPanicked, r)
 					}
 				}()
 
@@ -411,12 +493,10 @@ func (p *Plugin) FuncEvalCheckNode(funcArgs ...interface{}) (bool, error) {
 				}
 			}()
 
-			p.RecordInvocation(err == nil)
 			return result, err
 		} else {
 			err := fmt.Errorf("local plugin not found: %s", p.Name)
 			logger.PluginError("local plugin not found", "plugin", p.Name)
-			p.RecordInvocation(false)
 			return false, err
 		}
 	case 1: // yaegi plugin
@@ -429,7 +509,7 @@ func (p *Plugin) FuncEvalCheckNode(funcArgs ...interface{}) (bool, error) {
 				if r := recover(); r != nil {
 					logger.PluginError("plugin execution panicked", "plugin", p.Name, "panic", r)
 					result = false
-					err = fmt.Errorf("plugin execution panicked: %v", r)
+					err = fmt.Errorf("%w: %v", ErrPluginPanicked, r)
 				}
 			}()
 
@@ -473,14 +553,48 @@ func (p *Plugin) FuncEvalCheckNode(funcArgs ...interface{}) (bool, error) {
 			err = nil
 		}()
 
-		p.RecordInvocation(err == nil)
 		return result, err
 	}
-	p.RecordInvocation(false)
 	return false, fmt.Errorf("unknown plugin type")
 }
 
 func (p *Plugin) FuncEvalOther(funcArgs ...interface{}) (interface{}, bool, error) {
+	result, success, _, err := p.guardedEvalOther(funcArgs...)
+	return result, success, err
+}
+
+// guardedEvalOther runs evalOther within the runtime limits, the third result reports
+// that the configured fallback was returned instead of a real result
+func (p *Plugin) guardedEvalOther(funcArgs ...interface{}) (interface{}, bool, bool, error) {
+	defer p.observeDuration(time.Now())
+
+	g := p.guard()
+	if g == nil {
+		result, success, err := p.evalOther(funcArgs...)
+		p.RecordInvocation(err == nil)
+		return result, success, false, err
+	}
+
+	// The call keeps running after a timeout, so it writes to locals that are only read on success
+	var result interface{}
+	var success bool
+	var err error
+	if guardErr := g.run(p.Name, func() error {
+		result, success, err = p.evalOther(funcArgs...)
+		return err
+	}); guardErr != nil {
+		p.RecordInvocation(false)
+		if g.hasFallback {
+			return g.fallback, true, true, nil
+		}
+		return nil, false, false, guardErr
+	}
+	p.RecordInvocation(err == nil)
+	return result, success, false, err
+}
+
+// evalOther calls an (interface{}, bool, error) plugin function with panic recovery
+func (p *Plugin) evalOther(funcArgs ...interface{}) (interface{}, bool, error) {
 	var realArgs []reflect.Value
 
 	switch p.Type {
@@ -497,7 +611,7 @@ func (p *Plugin) FuncEvalOther(funcArgs ...interface{}) (interface{}, bool, erro
 						logger.PluginError("local plugin execution panicked", "plugin", p.Name, "panic", r)
 						result = nil
 						success = false
-						err = fmt.Errorf("local plugin execution panicked: %v", r)
+						err = fmt.Errorf("local %w: %v", ErrPluginPanicked, r)
 					}
 				}()
 
@@ -507,12 +621,10 @@ func (p *Plugin) FuncEvalOther(funcArgs ...interface{}) (interface{}, bool, erro
 				}
 			}()
 
-			p.RecordInvocation(err == nil)
 			return result, success, err
 		} else {
 			err := fmt.Errorf("local plugin not found: %s", p.Name)
 			logger.PluginError("local plugin not found", "plugin", p.Name)
-			p.RecordInvocation(false)
 			return nil, false, err
 		}
 	case 1: // yaegi plugin
@@ -527,7 +639,7 @@ func (p *Plugin) FuncEvalOther(funcArgs ...interface{}) (interface{}, bool, erro
 					logger.PluginError("plugin execution panicked", "plugin", p.Name, "panic", r)
 					result = nil
 					success = false
-					err = fmt.Errorf("plugin execution panicked: %v", r)
+					err = fmt.Errorf("%w: %v", ErrPluginPanicked, r)
 				}
 			}()
 
@@ -575,10 +687,8 @@ func (p *Plugin) FuncEvalOther(funcArgs ...interface{}) (interface{}, bool, erro
 			err = nil
 		}()
 
-		p.RecordInvocation(err == nil)
 		return result, success, err
 	}
-	p.RecordInvocation(false)
 	return nil, false, fmt.Errorf("unknown plugin type")
 }
 
@@ -619,6 +729,9 @@ func validatePluginCode(source string) error {
 	for _, importSpec := range file.Imports {
 		if importSpec.Path != nil {
 			importPath := strings.Trim(importSpec.Path.Value, `"`)
+			if importPath == statePackagePath {
+				continue
+			}
 			if !isStandardLibraryPackage(importPath) {
 				return fmt.Errorf("plugin can only import Go standard library packages, found external package: %s", importPath)
 			}
@@ -783,6 +896,16 @@ func (p *Plugin) RecordInvocation(success bool) {
 	}
 }
 
+// GetSuccessTotal returns the total successful invocations
+func (p *Plugin) GetSuccessTotal() uint64 {
+	return atomic.LoadUint64(&p.successTotal)
+}
+
+// GetFailureTotal returns the total failed invocations
+func (p *Plugin) GetFailureTotal() uint64 {
+	return atomic.LoadUint64(&p.failureTotal)
+}
+
 // GetSuccessIncrementAndUpdate returns the increment in success count since last call and updates the baseline
 // This method is thread-safe and designed for statistics collection.
 // Uses CAS operation to ensure atomicity.
@@ -865,9 +988,14 @@ func SafeDeletePlugin(id string) ([]string, error) {
 	}
 
 	// Remove from global mappings
+	pluginInstance := Plugins[id]
 	delete(Plugins, id)
 	delete(PluginsNew, id)
 	common.DeleteRawConfigUnsafe("plugin", id)
+	InvocationDuration.DeleteLabelValues(id)
+
+	// Run the plugin Close hook once rulesets stop using it
+	pluginInstance.retire()
 
 	return affectedProjects, nil
 }
@@ -0,0 +1,402 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runtime limits protect the ruleset workers from slow or stuck plugins. A call that exceeds its
+// timeout is abandoned (Go cannot stop the goroutine, it keeps its concurrency slot until it returns),
+// repeated timeouts, overloads and panics open a circuit breaker that rejects calls for reset_timeout,
+// and a configured fallback value is returned instead of an error so detection keeps flowing. Errors
+// returned by the plugin itself are results, they do not trip the breaker.
+//
+// Limits are opt-in, a plugin without a "default" or own entry runs directly in the ruleset worker.
+// config.yaml:
+//
+//	plugins:
+//	  default:              # applies to all plugins
+//	    timeout: 5s
+//	  addAssetInfo:
+//	    timeout: 500ms
+//	    max_concurrency: 16
+//	    failure_threshold: 5  # -1 disables the circuit breaker
+//	    reset_timeout: 30s
+//	    fallback: {}
+
+const (
+	defaultPluginConfigKey = "default"
+
+	defaultPluginTimeout      = 10 * time.Second // used when the configured timeout is invalid
+	defaultPluginResetTimeout = 30 * time.Second
+
+	// Guard workers waiting longer than this for a call exit
+	guardWorkerIdleTimeout = 30 * time.Second
+)
+
+var (
+	ErrPluginTimeout     = errors.New("plugin execution timed out")
+	ErrPluginOverloaded  = errors.New("plugin concurrency limit reached")
+	ErrPluginCircuitOpen = errors.New("plugin circuit breaker is open")
+	ErrPluginPanicked    = errors.New("plugin execution panicked")
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// pluginGuard enforces the runtime limits of one plugin
+type pluginGuard struct {
+	timeout     time.Duration
+	sem         chan struct{} // nil means unlimited
+	fallback    interface{}
+	hasFallback bool
+
+	// Circuit breaker, disabled when threshold <= 0
+	threshold    int
+	resetTimeout time.Duration
+	mu           sync.Mutex
+	state        string
+	failures     int
+	openedAt     time.Time
+	trialRunning bool
+
+	// Calls with a timeout run on idle workers, a new worker is only started when none is waiting
+	jobs chan *guardJob
+
+	timeouts   uint64
+	rejected   uint64
+	trips      uint64
+	inFlight   int64
+	lastErrMsg atomic.Value // string
+}
+
+// guardJob is one call handed to a guard worker. Jobs and timers are pooled, a job abandoned after
+// a timeout is left to the garbage collector since its worker still writes to it.
+type guardJob struct {
+	call func() error
+	err  error
+	done chan struct{}
+}
+
+var (
+	guardJobPool = sync.Pool{New: func() interface{} {
+		return &guardJob{done: make(chan struct{}, 1)}
+	}}
+	guardTimerPool = sync.Pool{New: func() interface{} {
+		t := time.NewTimer(time.Hour)
+		t.Stop()
+		return t
+	}}
+)
+
+// RuntimeStats describes the limits and runtime counters of a plugin on this node
+type RuntimeStats struct {
+	Timeout          string `json:"timeout"`
+	MaxConcurrency   int    `json:"max_concurrency"`
+	FailureThreshold int    `json:"failure_threshold"`
+	HasFallback      bool   `json:"has_fallback"`
+	InFlight         int64  `json:"in_flight"`
+	Timeouts         uint64 `json:"timeouts"`
+	Rejected         uint64 `json:"rejected"`
+	CircuitState     string `json:"circuit_state"`
+	CircuitTrips     uint64 `json:"circuit_trips"`
+	LastError        string `json:"last_error,omitempty"`
+}
+
+// resolvePluginConfig merges the "default" entry and the plugin entry
+func resolvePluginConfig(name string) (cfg common.PluginConfig, configured bool) {
+	if common.Config == nil {
+		return cfg, configured
+	}
+	for _, key := range []string{defaultPluginConfigKey, name} {
+		entry, ok := common.Config.Plugins[key]
+		if !ok {
+			continue
+		}
+		configured = true
+		if entry.Timeout != "" {
+			cfg.Timeout = entry.Timeout
+		}
+		if entry.MaxConcurrency != 0 {
+			cfg.MaxConcurrency = entry.MaxConcurrency
+		}
+		if entry.FailureThreshold != 0 {
+			cfg.FailureThreshold = entry.FailureThreshold
+		}
+		if entry.ResetTimeout != "" {
+			cfg.ResetTimeout = entry.ResetTimeout
+		}
+		if entry.Fallback != nil {
+			cfg.Fallback = entry.Fallback
+		}
+	}
+	return cfg, configured
+}
+
+// newPluginGuard builds the guard of a plugin, nil when the plugin runs without limits
+func newPluginGuard(name string, returnType string) *pluginGuard {
+	cfg, configured := resolvePluginConfig(name)
+	if !configured {
+		return nil
+	}
+
+	g := &pluginGuard{
+		threshold:    cfg.FailureThreshold,
+		resetTimeout: defaultPluginResetTimeout,
+		state:        CircuitClosed,
+		jobs:         make(chan *guardJob),
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d < 0 {
+			logger.PluginError("invalid plugin timeout, using default", "plugin", name, "timeout", cfg.Timeout)
+			d = defaultPluginTimeout
+		}
+		g.timeout = d
+	}
+	if cfg.ResetTimeout != "" {
+		d, err := time.ParseDuration(cfg.ResetTimeout)
+		if err != nil || d <= 0 {
+			logger.PluginError("invalid plugin reset_timeout, using default", "plugin", name, "reset_timeout", cfg.ResetTimeout)
+		} else {
+			g.resetTimeout = d
+		}
+	}
+	if cfg.MaxConcurrency > 0 {
+		g.sem = make(chan struct{}, cfg.MaxConcurrency)
+	}
+	if cfg.Fallback != nil {
+		if _, isBool := cfg.Fallback.(bool); returnType == "bool" && !isBool {
+			logger.PluginError("plugin fallback must be a bool for check plugins, ignoring it", "plugin", name, "fallback", cfg.Fallback)
+		} else {
+			g.fallback = cfg.Fallback
+			g.hasFallback = true
+		}
+	}
+
+	if g.timeout == 0 && g.sem == nil && g.threshold <= 0 {
+		return nil
+	}
+	return g
+}
+
+// guard returns the runtime guard of the plugin, built on first use because local plugins
+// are registered before config.yaml is loaded
+func (p *Plugin) guard() *pluginGuard {
+	p.guardOnce.Do(func() {
+		p.runtimeGuard = newPluginGuard(p.Name, p.ReturnType)
+	})
+	return p.runtimeGuard
+}
+
+// run executes call within the limits and feeds its error to the circuit breaker.
+// A non-nil return means the call did not complete and its results must not be used.
+func (g *pluginGuard) run(name string, call func() error) error {
+	trial, err := g.allow()
+	if err != nil {
+		atomic.AddUint64(&g.rejected, 1)
+		return err
+	}
+
+	var timer *time.Timer
+	var deadline <-chan time.Time
+	if g.timeout > 0 {
+		timer = guardTimerPool.Get().(*time.Timer)
+		timer.Reset(g.timeout)
+		deadline = timer.C
+		// Since Go 1.23 a stopped or reset timer never delivers a stale value, so it can be reused
+		defer guardTimerPool.Put(timer)
+		defer timer.Stop()
+	}
+
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-deadline:
+			atomic.AddUint64(&g.rejected, 1)
+			g.record(name, trial, ErrPluginOverloaded)
+			return ErrPluginOverloaded
+		}
+	}
+	atomic.AddInt64(&g.inFlight, 1)
+
+	if deadline == nil {
+		callErr := g.execute(call)
+		g.record(name, trial, callErr)
+		return nil
+	}
+
+	job := guardJobPool.Get().(*guardJob)
+	job.call = call
+	select {
+	case g.jobs <- job:
+	default:
+		go g.worker(job)
+	}
+
+	select {
+	case <-job.done:
+		callErr := job.err
+		job.call, job.err = nil, nil
+		guardJobPool.Put(job)
+		g.record(name, trial, callErr)
+		return nil
+	case <-deadline:
+		atomic.AddUint64(&g.timeouts, 1)
+		logger.PluginError("plugin execution timed out", "plugin", name, "timeout", g.timeout)
+		g.record(name, trial, ErrPluginTimeout)
+		return ErrPluginTimeout
+	}
+}
+
+// execute runs one call and releases its concurrency slot when it returns
+func (g *pluginGuard) execute(call func() error) error {
+	defer func() {
+		atomic.AddInt64(&g.inFlight, -1)
+		if g.sem != nil {
+			<-g.sem
+		}
+	}()
+	return call()
+}
+
+// worker runs jobs until it has been idle for guardWorkerIdleTimeout
+func (g *pluginGuard) worker(job *guardJob) {
+	idle := time.NewTimer(guardWorkerIdleTimeout)
+	defer idle.Stop()
+	for {
+		job.err = g.execute(job.call)
+		job.done <- struct{}{}
+
+		idle.Reset(guardWorkerIdleTimeout)
+		select {
+		case job = <-g.jobs:
+		case <-idle.C:
+			return
+		}
+	}
+}
+
+// allow checks the circuit breaker, trial reports a half-open trial call
+func (g *pluginGuard) allow() (trial bool, err error) {
+	if g.threshold <= 0 {
+		return false, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch g.state {
+	case CircuitOpen:
+		if time.Since(g.openedAt) < g.resetTimeout {
+			return false, ErrPluginCircuitOpen
+		}
+		g.state = CircuitHalfOpen
+		fallthrough
+	case CircuitHalfOpen:
+		// Only one trial call at a time while half-open
+		if g.trialRunning {
+			return false, ErrPluginCircuitOpen
+		}
+		g.trialRunning = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record feeds a call result to the circuit breaker, only timeouts, overloads and panics are
+// failures; an error returned by the plugin means it is responsive
+func (g *pluginGuard) record(name string, trial bool, err error) {
+	if err != nil {
+		g.lastErrMsg.Store(err.Error())
+	}
+	if g.threshold <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if trial {
+		g.trialRunning = false
+	}
+	if !isGuardFailure(err) {
+		g.failures = 0
+		g.state = CircuitClosed
+		return
+	}
+
+	g.failures++
+	if g.state == CircuitHalfOpen || g.failures >= g.threshold {
+		if g.state != CircuitOpen {
+			atomic.AddUint64(&g.trips, 1)
+			logger.PluginError("plugin circuit breaker opened", "plugin", name, "consecutive_failures", g.failures, "reset_timeout", g.resetTimeout, "last_error", err.Error())
+		}
+		g.state = CircuitOpen
+		g.openedAt = time.Now()
+	}
+}
+
+func isGuardFailure(err error) bool {
+	return errors.Is(err, ErrPluginTimeout) || errors.Is(err, ErrPluginOverloaded) || errors.Is(err, ErrPluginPanicked)
+}
+
+func (g *pluginGuard) stats() RuntimeStats {
+	g.mu.Lock()
+	state := g.state
+	if state == CircuitOpen && time.Since(g.openedAt) >= g.resetTimeout {
+		state = CircuitHalfOpen
+	}
+	g.mu.Unlock()
+	if g.threshold <= 0 {
+		state = ""
+	}
+
+	maxConcurrency := 0
+	if g.sem != nil {
+		maxConcurrency = cap(g.sem)
+	}
+	lastErr, _ := g.lastErrMsg.Load().(string)
+	return RuntimeStats{
+		Timeout:          g.timeout.String(),
+		MaxConcurrency:   maxConcurrency,
+		FailureThreshold: g.threshold,
+		HasFallback:      g.hasFallback,
+		InFlight:         atomic.LoadInt64(&g.inFlight),
+		Timeouts:         atomic.LoadUint64(&g.timeouts),
+		Rejected:         atomic.LoadUint64(&g.rejected),
+		CircuitState:     state,
+		CircuitTrips:     atomic.LoadUint64(&g.trips),
+		LastError:        lastErr,
+	}
+}
+
+// RuntimeStats returns the limits and counters of the plugin, nil when it runs without limits
+func (p *Plugin) RuntimeStats() *RuntimeStats {
+	g := p.guard()
+	if g == nil {
+		return nil
+	}
+	s := g.stats()
+	return &s
+}
+
+// GetAllRuntimeStats returns runtime stats of all limited plugins on this node
+func GetAllRuntimeStats() map[string]RuntimeStats {
+	PluginsMu.RLock()
+	defer PluginsMu.RUnlock()
+
+	res := make(map[string]RuntimeStats, len(Plugins))
+	for name, p := range Plugins {
+		if s := p.RuntimeStats(); s != nil {
+			res[name] = *s
+		}
+	}
+	return res
+}
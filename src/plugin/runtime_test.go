@@ -0,0 +1,199 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/common"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func withPluginConfig(t testing.TB, plugins map[string]common.PluginConfig) {
+	t.Helper()
+	prev := common.Config
+	common.Config = &common.HubConfig{Plugins: plugins}
+	t.Cleanup(func() { common.Config = prev })
+}
+
+func TestPluginGuardIsOptIn(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins map[string]common.PluginConfig
+		guarded bool
+	}{
+		{"not configured", nil, false},
+		{"other plugin configured", map[string]common.PluginConfig{"other": {Timeout: "1s"}}, false},
+		{"own entry", map[string]common.PluginConfig{"p": {Timeout: "1s"}}, true},
+		{"default entry", map[string]common.PluginConfig{"default": {FailureThreshold: 3}}, true},
+		{"timeout disabled", map[string]common.PluginConfig{"p": {Timeout: "0"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withPluginConfig(t, test.plugins)
+			if g := newPluginGuard("p", ""); (g != nil) != test.guarded {
+				t.Errorf("newPluginGuard() = %v, expected guarded %v", g, test.guarded)
+			}
+		})
+	}
+}
+
+func TestPluginGuardTimeoutOpensCircuit(t *testing.T) {
+	withPluginConfig(t, map[string]common.PluginConfig{"p": {Timeout: "20ms", FailureThreshold: 2, ResetTimeout: "1h"}})
+	g := newPluginGuard("p", "")
+
+	release := make(chan struct{})
+	defer close(release)
+	slow := func() error { <-release; return nil }
+
+	for i := 0; i < 2; i++ {
+		if err := g.run("p", slow); !errors.Is(err, ErrPluginTimeout) {
+			t.Fatalf("call %d: run() = %v, expected %v", i, err, ErrPluginTimeout)
+		}
+	}
+	if err := g.run("p", func() error { return nil }); !errors.Is(err, ErrPluginCircuitOpen) {
+		t.Errorf("run() after 2 timeouts = %v, expected %v", err, ErrPluginCircuitOpen)
+	}
+	if s := g.stats(); s.Timeouts != 2 || s.CircuitTrips != 1 || s.CircuitState != CircuitOpen {
+		t.Errorf("stats = %+v, expected 2 timeouts and an open circuit", s)
+	}
+}
+
+func TestPluginGuardFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		callErr error
+		opens   bool
+	}{
+		{"plugin error", errors.New("invalid input"), false},
+		{"wrapped plugin error", fmt.Errorf("lookup: %w", errors.New("not found")), false},
+		{"panic", fmt.Errorf("%w: boom", ErrPluginPanicked), true},
+		{"local plugin panic", fmt.Errorf("local %w: boom", ErrPluginPanicked), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withPluginConfig(t, map[string]common.PluginConfig{"p": {Timeout: "0", FailureThreshold: 3, ResetTimeout: "1h"}})
+			g := newPluginGuard("p", "")
+			for i := 0; i < 3; i++ {
+				if err := g.run("p", func() error { return test.callErr }); err != nil {
+					t.Fatalf("run() = %v, a completed call must not fail", err)
+				}
+			}
+			if opened := g.stats().CircuitState == CircuitOpen; opened != test.opens {
+				t.Errorf("after 3 calls returning %v circuit open = %v, expected %v", test.callErr, opened, test.opens)
+			}
+		})
+	}
+}
+
+func TestPluginGuardHalfOpenTrial(t *testing.T) {
+	withPluginConfig(t, map[string]common.PluginConfig{"p": {Timeout: "0", FailureThreshold: 1, ResetTimeout: "20ms"}})
+	g := newPluginGuard("p", "")
+
+	g.run("p", func() error { return ErrPluginPanicked })
+	if err := g.run("p", func() error { return nil }); !errors.Is(err, ErrPluginCircuitOpen) {
+		t.Fatalf("run() = %v, expected %v", err, ErrPluginCircuitOpen)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := g.run("p", func() error { return nil }); err != nil {
+		t.Fatalf("trial run() = %v, expected success", err)
+	}
+	if s := g.stats(); s.CircuitState != CircuitClosed {
+		t.Errorf("circuit state after successful trial = %q, expected %q", s.CircuitState, CircuitClosed)
+	}
+}
+
+func TestPluginGuardConcurrencyLimit(t *testing.T) {
+	withPluginConfig(t, map[string]common.PluginConfig{"p": {Timeout: "20ms", MaxConcurrency: 1}})
+	g := newPluginGuard("p", "")
+
+	release := make(chan struct{})
+	if err := g.run("p", func() error { <-release; return nil }); !errors.Is(err, ErrPluginTimeout) {
+		t.Fatalf("run() = %v, expected %v", err, ErrPluginTimeout)
+	}
+	// The timed out call keeps its slot until it returns
+	if err := g.run("p", func() error { return nil }); !errors.Is(err, ErrPluginOverloaded) {
+		t.Fatalf("run() with a stuck call = %v, expected %v", err, ErrPluginOverloaded)
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for g.stats().InFlight != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := g.run("p", func() error { return nil }); err != nil {
+		t.Errorf("run() after the stuck call returned = %v, expected success", err)
+	}
+}
+
+func TestPluginGuardReusesWorkers(t *testing.T) {
+	withPluginConfig(t, map[string]common.PluginConfig{"p": {Timeout: "1s"}})
+	g := newPluginGuard("p", "")
+
+	g.run("p", func() error { return nil })
+	before := runtime.NumGoroutine()
+	for i := 0; i < 1000; i++ {
+		if err := g.run("p", func() error { return nil }); err != nil {
+			t.Fatalf("run() = %v", err)
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before+1 {
+		t.Errorf("goroutines grew from %d to %d, sequential calls should reuse the idle worker", before, after)
+	}
+}
+
+func TestPluginGuardFallback(t *testing.T) {
+	withPluginConfig(t, map[string]common.PluginConfig{"p": {Fallback: "x"}, "check": {Fallback: "x"}})
+	if g := newPluginGuard("p", "interface{}"); g != nil {
+		t.Errorf("newPluginGuard() with only a fallback = %+v, expected no guard", g)
+	}
+
+	withPluginConfig(t, map[string]common.PluginConfig{"check": {Timeout: "1s", Fallback: "x"}})
+	if g := newPluginGuard("check", "bool"); g == nil || g.hasFallback {
+		t.Errorf("check plugins must ignore a non-bool fallback, got %+v", g)
+	}
+}
+
+// BenchmarkPluginGuard measures the overhead the guard adds to a plugin call
+func BenchmarkPluginGuard(b *testing.B) {
+	call := func() error { return nil }
+	benchmarks := []struct {
+		name   string
+		config common.PluginConfig
+	}{
+		{"breaker", common.PluginConfig{Timeout: "0", FailureThreshold: 10}},
+		{"concurrency", common.PluginConfig{Timeout: "0", MaxConcurrency: 64}},
+		{"timeout", common.PluginConfig{Timeout: "10s"}},
+		{"all", common.PluginConfig{Timeout: "10s", MaxConcurrency: 64, FailureThreshold: 10}},
+	}
+
+	b.Run("unguarded", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = call()
+		}
+	})
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			withPluginConfig(b, map[string]common.PluginConfig{"p": bm.config})
+			g := newPluginGuard("p", "")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = g.run("p", call)
+			}
+		})
+		b.Run(bm.name+"/parallel", func(b *testing.B) {
+			withPluginConfig(b, map[string]common.PluginConfig{"p": bm.config})
+			g := newPluginGuard("p", "")
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_ = g.run("p", call)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/traefik/yaegi/interp"
+)
+
+// Yaegi plugins may declare an optional lifecycle next to Eval:
+//
+//	func Init(config map[string]interface{}) error  // called once after load, before the first Eval
+//	func Close() error                              // called when the plugin is replaced or deleted
+//
+// config is the `config` block of the plugin in config.yaml (merged over the "default" entry):
+//
+//	plugins:
+//	  addAssetInfo:
+//	    config:
+//	      cmdb_url: http://cmdb.internal/api
+//
+// Plugins can keep state across calls with the plugin-scoped store, imported as "agentsmith/state":
+//
+//	state.Get(key string) (interface{}, bool)                   // local, per node
+//	state.Set(key string, value interface{}, ttlSeconds int) error
+//	state.Delete(key string)
+//	state.RedisGet(key string) (string, bool)                    // shared by the cluster
+//	state.RedisSet(key string, value string, ttlSeconds int) error
+//	state.RedisDelete(key string) error
+//
+// ttlSeconds <= 0 means no expiration. Keys are scoped to the plugin name, so plugins cannot read
+// each other's state. The local store starts empty on every load, the Redis store survives reloads.
+
+const (
+	// statePackagePath is the import path of the state store inside yaegi plugins
+	statePackagePath = "agentsmith/state"
+
+	pluginStateRedisPrefix = "hub:plugin_state:"
+
+	// maxLocalStateEntries bounds the local store of one plugin
+	maxLocalStateEntries = 100000
+
+	// retiredPluginCloseDelay gives rulesets still holding a replaced plugin time to switch before it is closed
+	retiredPluginCloseDelay = 30 * time.Second
+)
+
+var ErrPluginStateFull = errors.New("plugin state store is full")
+
+var (
+	initFuncType  = reflect.TypeOf((func(map[string]interface{}) error)(nil))
+	closeFuncType = reflect.TypeOf((func() error)(nil))
+)
+
+type stateEntry struct {
+	value    interface{}
+	expireAt time.Time // zero means no expiration
+}
+
+// pluginState is the local key/value store of one plugin instance
+type pluginState struct {
+	mu      sync.RWMutex
+	entries map[string]stateEntry
+}
+
+func newPluginState() *pluginState {
+	return &pluginState{entries: make(map[string]stateEntry)}
+}
+
+func (s *pluginState) get(key string) (interface{}, bool) {
+	s.mu.RLock()
+	e, ok := s.entries[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if !e.expireAt.IsZero() && time.Now().After(e.expireAt) {
+		s.mu.Lock()
+		if cur, ok := s.entries[key]; ok && cur.expireAt.Equal(e.expireAt) {
+			delete(s.entries, key)
+		}
+		s.mu.Unlock()
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (s *pluginState) set(key string, value interface{}, ttlSeconds int) error {
+	e := stateEntry{value: value}
+	if ttlSeconds > 0 {
+		e.expireAt = time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.entries[key]; !exists && len(s.entries) >= maxLocalStateEntries {
+		s.removeExpiredLocked()
+		if len(s.entries) >= maxLocalStateEntries {
+			return ErrPluginStateFull
+		}
+	}
+	s.entries[key] = e
+	return nil
+}
+
+func (s *pluginState) delete(key string) {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+}
+
+func (s *pluginState) removeExpiredLocked() {
+	now := time.Now()
+	for k, e := range s.entries {
+		if !e.expireAt.IsZero() && now.After(e.expireAt) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+func (s *pluginState) clear() {
+	s.mu.Lock()
+	s.entries = make(map[string]stateEntry)
+	s.mu.Unlock()
+}
+
+func (s *pluginState) size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+func pluginStateRedisKey(pluginName, key string) string {
+	return pluginStateRedisPrefix + pluginName + ":" + key
+}
+
+// stateExports builds the "agentsmith/state" package bound to one plugin
+func (p *Plugin) stateExports() interp.Exports {
+	name := p.Name
+	local := p.state
+
+	return interp.Exports{
+		statePackagePath + "/state": map[string]reflect.Value{
+			"Get": reflect.ValueOf(func(key string) (interface{}, bool) {
+				return local.get(key)
+			}),
+			"Set": reflect.ValueOf(func(key string, value interface{}, ttlSeconds int) error {
+				return local.set(key, value, ttlSeconds)
+			}),
+			"Delete": reflect.ValueOf(func(key string) {
+				local.delete(key)
+			}),
+			"RedisGet": reflect.ValueOf(func(key string) (string, bool) {
+				val, err := common.RedisGet(pluginStateRedisKey(name, key))
+				if err != nil {
+					if !errors.Is(err, redis.Nil) {
+						logger.PluginError("plugin state redis get failed", "plugin", name, "key", key, "error", err)
+					}
+					return "", false
+				}
+				return val, true
+			}),
+			"RedisSet": reflect.ValueOf(func(key string, value string, ttlSeconds int) error {
+				if ttlSeconds < 0 {
+					ttlSeconds = 0
+				}
+				_, err := common.RedisSet(pluginStateRedisKey(name, key), value, ttlSeconds)
+				return err
+			}),
+			"RedisDelete": reflect.ValueOf(func(key string) error {
+				return common.RedisDel(pluginStateRedisKey(name, key))
+			}),
+		},
+	}
+}
+
+// declaredLifecycleFuncs reports which of the optional Init and Close functions the source declares
+func declaredLifecycleFuncs(source []byte) (hasInit, hasClose bool) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", source, 0)
+	if err != nil {
+		return false, false
+	}
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Recv != nil {
+			continue
+		}
+		switch funcDecl.Name.Name {
+		case "Init":
+			hasInit = true
+		case "Close":
+			hasClose = true
+		}
+	}
+	return hasInit, hasClose
+}
+
+// loadLifecycleFuncs resolves and validates Init and Close after the plugin source is evaluated
+func (p *Plugin) loadLifecycleFuncs() error {
+	hasInit, hasClose := declaredLifecycleFuncs(p.Payload)
+	if hasInit {
+		v, err := p.yaegiIntp.Eval("plugin.Init")
+		if err != nil {
+			return err
+		}
+		f := reflect.ValueOf(v.Interface())
+		if !f.IsValid() || f.Type() != initFuncType {
+			return fmt.Errorf("plugin Init function must have signature func(config map[string]interface{}) error")
+		}
+		p.initFunc = f
+	}
+	if hasClose {
+		v, err := p.yaegiIntp.Eval("plugin.Close")
+		if err != nil {
+			return err
+		}
+		f := reflect.ValueOf(v.Interface())
+		if !f.IsValid() || f.Type() != closeFuncType {
+			return fmt.Errorf("plugin Close function must have signature func() error")
+		}
+		p.closeFunc = f
+	}
+	return nil
+}
+
+// pluginUserConfig returns the config block of a plugin, plugin keys override "default" keys
+func pluginUserConfig(name string) map[string]interface{} {
+	cfg := make(map[string]interface{})
+	if common.Config == nil {
+		return cfg
+	}
+	for _, key := range []string{defaultPluginConfigKey, name} {
+		for k, v := range common.Config.Plugins[key].Config {
+			cfg[k] = v
+		}
+	}
+	return cfg
+}
+
+// start runs the plugin Init function with its config
+func (p *Plugin) start() (err error) {
+	if !p.initFunc.IsValid() {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.PluginError("plugin Init panicked", "plugin", p.Name, "panic", r)
+			err = fmt.Errorf("plugin Init panicked: %v", r)
+		}
+	}()
+
+	out := p.initFunc.Call([]reflect.Value{reflect.ValueOf(pluginUserConfig(p.Name))})
+	if initErr, _ := out[0].Interface().(error); initErr != nil {
+		return fmt.Errorf("plugin Init failed: %w", initErr)
+	}
+	return nil
+}
+
+// Close runs the plugin Close function once and drops its local state
+func (p *Plugin) Close() {
+	p.closeOnce.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.PluginError("plugin Close panicked", "plugin", p.Name, "panic", r)
+			}
+			if p.state != nil {
+				p.state.clear()
+			}
+		}()
+
+		if !p.closeFunc.IsValid() {
+			return
+		}
+		out := p.closeFunc.Call(nil)
+		if closeErr, _ := out[0].Interface().(error); closeErr != nil {
+			logger.PluginError("plugin Close returned error", "plugin", p.Name, "error", closeErr)
+		}
+	})
+}
+
+// retire closes a plugin instance that was replaced or deleted, after rulesets had time to switch
+func (p *Plugin) retire() {
+	if p == nil || (!p.closeFunc.IsValid() && p.state == nil) {
+		return
+	}
+	time.AfterFunc(retiredPluginCloseDelay, p.Close)
+}
+
+// StateSize returns the number of entries in the local state store
+func (p *Plugin) StateSize() int {
+	if p.state == nil {
+		return 0
+	}
+	return p.state.size()
+}
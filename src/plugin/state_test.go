@@ -0,0 +1,208 @@
+package plugin
+
+import (
+	"AgentSmith-HUB/common"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// lifecyclePlugin reads its config in Init, keeps a counter in the local store and records Close calls in Redis
+const lifecyclePlugin = `package plugin
+
+import (
+	"agentsmith/state"
+	"errors"
+	"fmt"
+)
+
+var prefix string
+
+func Init(config map[string]interface{}) error {
+	p, ok := config["prefix"].(string)
+	if !ok {
+		return errors.New("prefix is required")
+	}
+	prefix = p
+	return nil
+}
+
+func Eval(key string) (interface{}, bool, error) {
+	n, _ := state.Get(key)
+	count, _ := n.(int)
+	count++
+	if err := state.Set(key, count, 0); err != nil {
+		return nil, false, err
+	}
+	return fmt.Sprintf("%s%d", prefix, count), true, nil
+}
+
+func Close() error {
+	v, _ := state.RedisGet("closed")
+	return state.RedisSet("closed", v+"x", 0)
+}
+`
+
+func TestPluginState(t *testing.T) {
+	s := newPluginState()
+
+	if _, ok := s.get("missing"); ok {
+		t.Errorf("get(missing) found a value")
+	}
+	if err := s.set("a", 1, 0); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if v, ok := s.get("a"); !ok || v != 1 {
+		t.Errorf("get(a) = %v, %v, expected 1", v, ok)
+	}
+	s.delete("a")
+	if _, ok := s.get("a"); ok {
+		t.Errorf("get(a) found a deleted value")
+	}
+
+	if err := s.set("ttl", "v", 60); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if v, ok := s.get("ttl"); !ok || v != "v" {
+		t.Errorf("get(ttl) = %v, %v before expiry", v, ok)
+	}
+	s.entries["ttl"] = stateEntry{value: "v", expireAt: time.Now().Add(-time.Second)}
+	if _, ok := s.get("ttl"); ok {
+		t.Errorf("get(ttl) found an expired value")
+	}
+	if s.size() != 0 {
+		t.Errorf("size() = %d after reading the expired entry, expected 0", s.size())
+	}
+
+	// A full store takes no new keys, expired entries make room and existing keys can be updated
+	for i := 0; i < maxLocalStateEntries; i++ {
+		s.entries[fmt.Sprint(i)] = stateEntry{value: i}
+	}
+	if err := s.set("new", 1, 0); err != ErrPluginStateFull {
+		t.Errorf("set on a full store = %v, expected %v", err, ErrPluginStateFull)
+	}
+	if err := s.set("0", "updated", 0); err != nil {
+		t.Errorf("set of an existing key on a full store = %v", err)
+	}
+	s.entries["1"] = stateEntry{value: 1, expireAt: time.Now().Add(-time.Second)}
+	if err := s.set("new", 1, 0); err != nil {
+		t.Errorf("set after an entry expired = %v", err)
+	}
+
+	s.clear()
+	if s.size() != 0 {
+		t.Errorf("size() = %d after clear, expected 0", s.size())
+	}
+}
+
+func TestDeclaredLifecycleFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		hasInit  bool
+		hasClose bool
+	}{
+		{"none", "package plugin\nfunc Eval() (bool, error) { return true, nil }", false, false},
+		{"both", lifecyclePlugin, true, true},
+		{"methods", "package plugin\ntype T struct{}\nfunc (T) Init(map[string]interface{}) error { return nil }\nfunc (T) Close() error { return nil }", false, false},
+		{"invalid source", "package plugin\nfunc Init(", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasInit, hasClose := declaredLifecycleFuncs([]byte(test.source))
+			if hasInit != test.hasInit || hasClose != test.hasClose {
+				t.Errorf("declaredLifecycleFuncs() = %v, %v, expected %v, %v", hasInit, hasClose, test.hasInit, test.hasClose)
+			}
+		})
+	}
+}
+
+func TestPluginLifecycle(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+
+	// The plugin entry overrides the default entry
+	withPluginConfig(t, map[string]common.PluginConfig{
+		"default":   {Config: map[string]interface{}{"prefix": "default-"}},
+		"lifecycle": {Config: map[string]interface{}{"prefix": "own-"}},
+	})
+
+	p, err := NewTestPlugin("", lifecyclePlugin, "lifecycle", YAEGI_PLUGIN)
+	if err != nil {
+		t.Fatalf("NewTestPlugin failed: %v", err)
+	}
+	for i, expected := range []string{"own-1", "own-2"} {
+		if res, ok, err := p.FuncEvalOther("k"); err != nil || !ok || res != expected {
+			t.Errorf("call %d = %v, %v, %v, expected %s", i, res, ok, err, expected)
+		}
+	}
+	if p.StateSize() != 1 {
+		t.Errorf("StateSize() = %d, expected 1", p.StateSize())
+	}
+
+	// Close runs once and drops the local store
+	p.Close()
+	p.Close()
+	if v, _ := mr.Get(pluginStateRedisKey("lifecycle", "closed")); v != "x" {
+		t.Errorf("Close ran %d times, expected once", len(v))
+	}
+	if p.StateSize() != 0 {
+		t.Errorf("StateSize() = %d after Close, expected 0", p.StateSize())
+	}
+
+	// The default entry applies to plugins without their own, the Redis store is scoped to the plugin
+	other, err := NewTestPlugin("", lifecyclePlugin, "other", YAEGI_PLUGIN)
+	if err != nil {
+		t.Fatalf("NewTestPlugin failed: %v", err)
+	}
+	if res, _, _ := other.FuncEvalOther("k"); res != "default-1" {
+		t.Errorf("other plugin = %v, expected default-1", res)
+	}
+	other.Close()
+	if v, _ := mr.Get(pluginStateRedisKey("other", "closed")); v != "x" {
+		t.Errorf("other plugin closed %d times, expected once", len(v))
+	}
+}
+
+func TestPluginLifecycleErrors(t *testing.T) {
+	withPluginConfig(t, nil)
+
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{"init fails", lifecyclePlugin, "prefix is required"},
+		{"init panics", `package plugin
+func Init(config map[string]interface{}) error { panic("boom") }
+func Eval() (bool, error) { return true, nil }`, "Init panicked"},
+		{"init signature", `package plugin
+func Init() error { return nil }
+func Eval() (bool, error) { return true, nil }`, "Init function must have signature"},
+		{"close signature", `package plugin
+func Close() {}
+func Eval() (bool, error) { return true, nil }`, "Close function must have signature"},
+		{"external import", `package plugin
+import "agentsmith/other"
+func Eval() (bool, error) { return other.X(), nil }`, "external package"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewTestPlugin("", test.source, "broken", YAEGI_PLUGIN)
+			if err == nil {
+				p.Close()
+				t.Fatalf("NewTestPlugin succeeded, expected %q", test.err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("NewTestPlugin() = %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
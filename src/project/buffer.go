@@ -0,0 +1,120 @@
+package project
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const defaultBufferMaxSizeMB = 1024
+
+var bufferFileNameRegex = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// PNS components have a single input channel shared by every project using them, so whether it is
+// buffered is decided by the project creating it. Projects sharing it later must agree.
+var (
+	bufferOwnersMu sync.Mutex
+	bufferOwners   = make(map[string]string) // ToPNS -> project owning the disk queue
+)
+
+// parseBufferedEdges validates the buffer section and returns its edges keyed the same way as in parseContent
+func (p *Project) parseBufferedEdges() (map[string]bool, error) {
+	edges := make(map[string]bool)
+	if p.Config == nil || p.Config.Buffer == nil {
+		return edges, nil
+	}
+
+	if p.Config.Buffer.MaxSizeMB < 0 {
+		return nil, fmt.Errorf("buffer max_size_mb cannot be negative")
+	}
+
+	for _, edge := range p.Config.Buffer.Edges {
+		parts := strings.Split(edge, "->")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid buffer edge %q (expected format: TYPE.ID -> TYPE.ID)", edge)
+		}
+		from := strings.TrimSpace(parts[0])
+		to := strings.TrimSpace(parts[1])
+		if fromType, _ := parseNode(from); fromType == "" {
+			return nil, fmt.Errorf("invalid buffer edge %q (expected format: TYPE.ID -> TYPE.ID)", edge)
+		}
+		if toType, _ := parseNode(to); toType == "" {
+			return nil, fmt.Errorf("invalid buffer edge %q (expected format: TYPE.ID -> TYPE.ID)", edge)
+		}
+		edges[from+"->"+to] = true
+	}
+	return edges, nil
+}
+
+// bufferDir returns the directory holding the write-ahead logs of this project
+func (p *Project) bufferDir() string {
+	if p.Config.Buffer.Dir != "" {
+		return p.Config.Buffer.Dir
+	}
+	return filepath.Join(common.GetDataDir(), "buffer", p.Id)
+}
+
+// newEdgeChannel creates the channel of a new TO node. Senders write to the first channel and the
+// component reads from the second; they are the same channel unless the edge is buffered.
+func (p *Project) newEdgeChannel(node *FlowNode, capacity int) (chan map[string]interface{}, chan map[string]interface{}, error) {
+	if !node.Buffered || p.Testing {
+		c := make(chan map[string]interface{}, capacity)
+		return c, c, nil
+	}
+
+	maxSizeMB := p.Config.Buffer.MaxSizeMB
+	if maxSizeMB == 0 {
+		maxSizeMB = defaultBufferMaxSizeMB
+	}
+	path := filepath.Join(p.bufferDir(), bufferFileNameRegex.ReplaceAllString(node.ToPNS, "_")+".wal")
+
+	q, err := common.NewDiskQueue(node.ToPNS, path, int64(maxSizeMB)*1024*1024, capacity)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open buffer for edge %s: %w", node.Content, err)
+	}
+	if p.DiskQueues == nil {
+		p.DiskQueues = make(map[string]*common.DiskQueue)
+	}
+	p.DiskQueues[node.ToPNS] = q
+	bufferOwnersMu.Lock()
+	bufferOwners[node.ToPNS] = p.Id
+	bufferOwnersMu.Unlock()
+	logger.Info("Buffered edge enabled", "project", p.Id, "edge", node.Content, "path", path, "pending", q.Pending())
+	return q.In, q.Out, nil
+}
+
+// closeDiskQueues persists messages still in flight on buffered edges, call it after the receiving components stopped
+func (p *Project) closeDiskQueues() {
+	for pns, q := range p.DiskQueues {
+		q.Close()
+		delete(p.DiskQueues, pns)
+		bufferOwnersMu.Lock()
+		if bufferOwners[pns] == p.Id {
+			delete(bufferOwners, pns)
+		}
+		bufferOwnersMu.Unlock()
+	}
+}
+
+// checkSharedBuffer rejects an edge into a PNS component created by another project when only one
+// of the two projects buffers it
+func (p *Project) checkSharedBuffer(node *FlowNode) error {
+	if p.Testing {
+		return nil
+	}
+	bufferOwnersMu.Lock()
+	owner, buffered := bufferOwners[node.ToPNS]
+	bufferOwnersMu.Unlock()
+
+	switch {
+	case node.Buffered && !buffered:
+		return fmt.Errorf("buffer edge %q: %s is shared with a running project that does not buffer it", node.Content, node.ToPNS)
+	case !node.Buffered && buffered:
+		return fmt.Errorf("edge %q: %s is shared with project %s, which buffers it; add the edge to the buffer edges of this project too", node.Content, node.ToPNS, owner)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package project
+
+import (
+	"testing"
+)
+
+func TestParseBufferedEdges(t *testing.T) {
+	tests := []struct {
+		name     string
+		edges    []string
+		expected []string
+		wantErr  bool
+	}{
+		{"none", nil, nil, false},
+		{"spaces are ignored", []string{" RULESET.r  ->  OUTPUT.es "}, []string{"RULESET.r->OUTPUT.es"}, false},
+		{"missing arrow", []string{"RULESET.r OUTPUT.es"}, nil, true},
+		{"invalid node", []string{"r -> OUTPUT.es"}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Project{Id: "p", Config: &ProjectConfig{Buffer: &ProjectBufferConfig{Edges: test.edges}}}
+			edges, err := p.parseBufferedEdges()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseBufferedEdges(%q) error = %v, wantErr %v", test.edges, err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if len(edges) != len(test.expected) {
+				t.Fatalf("parseBufferedEdges(%q) = %v, expected %v", test.edges, edges, test.expected)
+			}
+			for _, edge := range test.expected {
+				if !edges[edge] {
+					t.Errorf("parseBufferedEdges(%q) = %v, missing %q", test.edges, edges, edge)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSharedBuffer(t *testing.T) {
+	bufferOwnersMu.Lock()
+	bufferOwners["INPUT.in.RULESET.r"] = "owner"
+	bufferOwnersMu.Unlock()
+	defer func() {
+		bufferOwnersMu.Lock()
+		delete(bufferOwners, "INPUT.in.RULESET.r")
+		bufferOwnersMu.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		toPNS    string
+		buffered bool
+		testing  bool
+		wantErr  bool
+	}{
+		{"both buffer", "INPUT.in.RULESET.r", true, false, false},
+		{"shared node is buffered", "INPUT.in.RULESET.r", false, false, true},
+		{"shared node is not buffered", "INPUT.in.RULESET.other", true, false, true},
+		{"neither buffers", "INPUT.in.RULESET.other", false, false, false},
+		{"test projects do not buffer", "INPUT.in.RULESET.r", false, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &Project{Id: "p", Testing: test.testing}
+			node := &FlowNode{ToPNS: test.toPNS, Content: "INPUT.in -> RULESET.r", Buffered: test.buffered}
+			err := p.checkSharedBuffer(node)
+			if (err != nil) != test.wantErr {
+				t.Errorf("checkSharedBuffer(%s, buffered %v) error = %v, wantErr %v", test.toPNS, test.buffered, err, test.wantErr)
+			}
+		})
+	}
+}
@@ -185,7 +185,7 @@ func (h *projectCommandHandler) ExecuteCommandWithOptions(projectID, action stri
 		if err != nil {
 			// Record operation failure only if requested
 			if recordOperation {
-				common.RecordProjectOperation(common.OpTypeProjectStart, projectID, "failed", err.Error(), map[string]interface{}{
+				common.RecordProjectOperation(common.OpTypeProjectStart, projectID, "failed", err.Error(), "", map[string]interface{}{
 					"triggered_by": "cluster_command",
 					"node_id":      nodeID,
 				})
@@ -194,7 +194,7 @@ func (h *projectCommandHandler) ExecuteCommandWithOptions(projectID, action stri
 		}
 		// Record operation success only if requested
 		if recordOperation {
-			common.RecordProjectOperation(common.OpTypeProjectStart, projectID, "success", "", map[string]interface{}{
+			common.RecordProjectOperation(common.OpTypeProjectStart, projectID, "success", "", "", map[string]interface{}{
 				"triggered_by": "cluster_command",
 				"node_id":      nodeID,
 			})
@@ -207,7 +207,7 @@ func (h *projectCommandHandler) ExecuteCommandWithOptions(projectID, action stri
 		if err != nil {
 			// Record operation failure only if requested
 			if recordOperation {
-				common.RecordProjectOperation(common.OpTypeProjectStop, projectID, "failed", err.Error(), map[string]interface{}{
+				common.RecordProjectOperation(common.OpTypeProjectStop, projectID, "failed", err.Error(), "", map[string]interface{}{
 					"triggered_by": "cluster_command",
 					"node_id":      nodeID,
 				})
@@ -216,7 +216,7 @@ func (h *projectCommandHandler) ExecuteCommandWithOptions(projectID, action stri
 		}
 		// Record operation success only if requested
 		if recordOperation {
-			common.RecordProjectOperation(common.OpTypeProjectStop, projectID, "success", "", map[string]interface{}{
+			common.RecordProjectOperation(common.OpTypeProjectStop, projectID, "success", "", "", map[string]interface{}{
 				"triggered_by": "cluster_command",
 				"node_id":      nodeID,
 			})
@@ -345,6 +345,9 @@ func init() {
 	// AllProjectRawConfig is now managed through common.SetRawConfig functions
 	common.SetStatsCollector(collectAllComponentStats)
 
+	// Let outputs look up their secondary dead letter output
+	output.SetOutputResolver(GetOutput)
+
 	// Register the component checker function
 	common.SetProjectComponentChecker(checkAllProjectComponentsImpl)
 
@@ -514,6 +517,11 @@ func (p *Project) parseContent() error {
 	p.FlowNodes = []FlowNode{}
 	p.BackUpFlowNodes = []FlowNode{}
 
+	bufferedEdges, err := p.parseBufferedEdges()
+	if err != nil {
+		return err
+	}
+
 	for lineNum, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
@@ -581,12 +589,19 @@ func (p *Project) parseContent() error {
 			ToID:     toID,
 			ToType:   toType,
 			Content:  line,
+			Buffered: bufferedEdges[edgeKey],
 		}
 
 		p.FlowNodes = append(p.FlowNodes, tmpNode)
 		p.BackUpFlowNodes = append(p.BackUpFlowNodes, tmpNode)
 	}
 
+	for edgeKey := range bufferedEdges {
+		if _, exists := edgeSet[edgeKey]; !exists {
+			return fmt.Errorf("buffer edge %q not found in project content", edgeKey)
+		}
+	}
+
 	// check loop
 	if err := p.detectCycle(); err != nil {
 		return err
@@ -1065,7 +1080,7 @@ func (p *Project) Restart(recordOperation bool, triggeredBy string) (err error)
 			if triggeredBy != "" {
 				details["triggered_by"] = triggeredBy
 			}
-			common.RecordProjectOperation(common.OpTypeProjectRestart, p.Id, status, errMsg, details)
+			common.RecordProjectOperation(common.OpTypeProjectRestart, p.Id, status, errMsg, "", details)
 		}
 	}()
 
@@ -1270,6 +1285,9 @@ func (p *Project) cleanup() {
 	p.cleanupInputChannel()
 	p.cleanupRulesetChannel()
 
+	// Receivers are stopped at this point, persist what is left on buffered edges before closing channels
+	p.closeDiskQueues()
+
 	for pns, ch := range p.MsgChannels {
 		if ch != nil {
 			// Safely close channel, ignore if already closed
@@ -1492,6 +1510,8 @@ func (p *Project) initComponents() error {
 			}
 		}
 
+		p.closeDiskQueues()
+
 		// Clear project maps
 		p.Inputs = make(map[string]*input.Input)
 		p.Outputs = make(map[string]*output.Output)
@@ -1514,6 +1534,10 @@ func (p *Project) initComponents() error {
 			rs, exists := GetPNSRuleset(node.ToPNS)
 
 			if exists {
+				if err := p.checkSharedBuffer(node); err != nil {
+					cleanup()
+					return err
+				}
 				p.Rulesets[node.ToPNS] = rs
 				nodeChannelStatus[node.ToPNS] = false
 			} else {
@@ -1541,9 +1565,13 @@ func (p *Project) initComponents() error {
 				p.Rulesets[node.ToPNS] = rs
 
 				nodeChannelStatus[node.ToPNS] = true
-				c := make(chan map[string]interface{}, 512)
-				p.MsgChannels[node.ToPNS] = &c
-				rs.UpStream[node.ToPNS] = &c
+				send, recv, err := p.newEdgeChannel(node, 512)
+				if err != nil {
+					cleanup()
+					return err
+				}
+				p.MsgChannels[node.ToPNS] = &send
+				rs.UpStream[node.ToPNS] = &recv
 			}
 		case "OUTPUT":
 			if p.Testing {
@@ -1581,6 +1609,10 @@ func (p *Project) initComponents() error {
 				o, exists := GetPNSOutput(node.ToPNS)
 
 				if exists {
+					if err := p.checkSharedBuffer(node); err != nil {
+						cleanup()
+						return err
+					}
 					p.Outputs[node.ToPNS] = o
 					nodeChannelStatus[node.ToPNS] = false
 				} else {
@@ -1608,9 +1640,13 @@ func (p *Project) initComponents() error {
 					p.Outputs[node.ToPNS] = o
 
 					nodeChannelStatus[node.ToPNS] = true
-					c := make(chan map[string]interface{}, 1024)
-					p.MsgChannels[node.ToPNS] = &c
-					o.UpStream[node.ToPNS] = &c
+					send, recv, err := p.newEdgeChannel(node, 1024)
+					if err != nil {
+						cleanup()
+						return err
+					}
+					p.MsgChannels[node.ToPNS] = &send
+					o.UpStream[node.ToPNS] = &recv
 				}
 			}
 		}
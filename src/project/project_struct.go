@@ -21,6 +21,7 @@ type FlowNode struct {
 	ToID     string
 	FromInit bool
 	ToInit   bool
+	Buffered bool // edge is backed by a disk queue, see ProjectBufferConfig
 }
 
 type GlobalProjectInfo struct {
@@ -85,11 +86,20 @@ func GetRefCount(id string) int {
 // ProjectConfig holds the configuration for a project
 type ProjectConfig struct {
 	Id        string
-	Content   string `yaml:"content"`
+	Content   string               `yaml:"content"`
+	Buffer    *ProjectBufferConfig `yaml:"buffer,omitempty"`
 	RawConfig string
 	Path      string
 }
 
+// ProjectBufferConfig lists the edges whose channel spills to a local write-ahead log when full,
+// so that messages in flight survive a slow consumer or a restart (at-least-once delivery)
+type ProjectBufferConfig struct {
+	Edges     []string `yaml:"edges"`                 // edges as written in content, e.g. "RULESET.r -> OUTPUT.es"
+	Dir       string   `yaml:"dir,omitempty"`         // default: <data_dir>/buffer/<project_id>
+	MaxSizeMB int      `yaml:"max_size_mb,omitempty"` // per edge, senders block when reached; default 1024
+}
+
 // Project represents a project
 type Project struct {
 	Id              string        `json:"id"`
@@ -112,6 +122,7 @@ type Project struct {
 
 	// Data flow
 	MsgChannels map[string]*chan map[string]interface{} `json:"-"` // Channels for message passing between components
+	DiskQueues  map[string]*common.DiskQueue            `json:"-"` // Disk queues of buffered edges, keyed by ToPNS
 
 	// Restart cooldown
 	lastRestartTime time.Time
@@ -471,6 +482,19 @@ func DeletePNSOutput(pns string) {
 	delete(GlobalProject.PNSOutputs, pns)
 }
 
+// GetPNSOutputsByID returns all PNS instances of an output component
+func GetPNSOutputsByID(id string) []*output.Output {
+	common.GlobalMu.RLock()
+	defer common.GlobalMu.RUnlock()
+	var outputs []*output.Output
+	for _, out := range GlobalProject.PNSOutputs {
+		if out.Id == id {
+			outputs = append(outputs, out)
+		}
+	}
+	return outputs
+}
+
 // PNS Ruleset accessors
 func GetPNSRuleset(pns string) (*rules_engine.Ruleset, bool) {
 	common.GlobalMu.RLock()
@@ -656,6 +680,17 @@ func ForEachRuleset(fn func(id string, rs *rules_engine.Ruleset) bool) {
 	}
 }
 
+func ForEachPNSRuleset(fn func(pns string, rs *rules_engine.Ruleset) bool) {
+	common.GlobalMu.RLock()
+	defer common.GlobalMu.RUnlock()
+
+	for pns, rs := range GlobalProject.PNSRulesets {
+		if !fn(pns, rs) {
+			break
+		}
+	}
+}
+
 // Helper function to safely access input downstream
 func SafeDeleteInputDownstream(inputID, downstreamID string) {
 	common.GlobalMu.Lock()
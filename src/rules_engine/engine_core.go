@@ -3,6 +3,8 @@ package rules_engine
 import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"AgentSmith-HUB/tracing"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -15,6 +17,9 @@ import (
 
 const HitRuleIdFieldName = "_hub_hit_rule_id"
 
+// HitThresholdValueFieldName holds the computed value (count, sum, distinct count, avg, max or min) of the threshold that hit
+const HitThresholdValueFieldName = "_hub_threshold_value"
+
 // SIMD statistics variables
 var (
 	simdEnabled bool = false // SIMD enable flag, will be set from config
@@ -153,6 +158,11 @@ func (r *Ruleset) Start() error {
 		}
 	}()
 
+	// Emit the rollups of ended suppression windows
+	if r.hasSuppression() {
+		go r.runSuppressionRollups(r.stopChan)
+	}
+
 	for upID, upCh := range r.UpStream {
 		go func(id string, ch *chan map[string]interface{}) {
 			defer func() {
@@ -383,6 +393,17 @@ func (r *Ruleset) Stop() error {
 
 // EngineCheck executes all rules in the ruleset on the provided data using the new flexible syntax.
 func (r *Ruleset) EngineCheck(data map[string]interface{}) []map[string]interface{} {
+	ctx, span := tracing.StartMessage(data, "ruleset")
+	if !span.IsRecording() {
+		return r.engineCheck(ctx, data)
+	}
+	span.SetName("ruleset " + r.RulesetID)
+	results := r.engineCheck(ctx, data)
+	r.endCheckSpan(span, results)
+	return results
+}
+
+func (r *Ruleset) engineCheck(ctx context.Context, data map[string]interface{}) []map[string]interface{} {
 	// Pre-allocate result slice with better capacity estimation
 	var initialCap int
 	if r.IsDetection {
@@ -432,7 +453,7 @@ func (r *Ruleset) EngineCheck(data map[string]interface{}) []map[string]interfac
 		}
 
 		// Execute all operations in the order specified by the Queue
-		ruleCheckRes := r.executeRuleOperations(rule, dataCopy, ruleCache)
+		ruleCheckRes := r.executeRuleOperations(ctx, rule, dataCopy, ruleCache)
 
 		// Handle rule result based on ruleset type
 		if r.IsDetection {
@@ -447,6 +468,10 @@ func (r *Ruleset) EngineCheck(data map[string]interface{}) []map[string]interfac
 				sb.WriteString(rule.ID)
 				addHitRuleID(dataCopy, sb.String())
 				stringBuilderPool.Put(sb)
+				// Duplicate alerts are counted and rolled up when the suppression window ends
+				if rule.SuppressWindowInt > 0 && !r.applySuppression(rule, dataCopy, ruleCache) {
+					continue
+				}
 				// Add to final result
 				finalRes = append(finalRes, dataCopy)
 			}
@@ -479,7 +504,7 @@ func (r *Ruleset) EngineCheck(data map[string]interface{}) []map[string]interfac
 }
 
 // executeRuleOperations executes all operations in a rule according to the Queue order
-func (r *Ruleset) executeRuleOperations(rule *Rule, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
+func (r *Ruleset) executeRuleOperations(ctx context.Context, rule *Rule, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
 	if rule.Queue == nil || len(*rule.Queue) == 0 {
 		// No operations to execute
 		// For detection rules, empty rule means no match (false)
@@ -492,7 +517,7 @@ func (r *Ruleset) executeRuleOperations(rule *Rule, data map[string]interface{},
 	for _, op := range *rule.Queue {
 		switch op.Type {
 		case T_CheckList:
-			checkResult := r.executeCheckList(rule, op.ID, data, ruleCache)
+			checkResult := r.executeCheckList(ctx, rule, op.ID, data, ruleCache)
 			if !checkResult {
 				ruleResult = false
 				// For detection rules, if check fails, stop execution
@@ -502,7 +527,7 @@ func (r *Ruleset) executeRuleOperations(rule *Rule, data map[string]interface{},
 				// For exclude rules, continue executing other operations
 			}
 		case T_Check:
-			checkResult := r.executeCheck(rule, op.ID, data, ruleCache)
+			checkResult := r.executeCheck(ctx, rule, op.ID, data, ruleCache)
 			if !checkResult {
 				ruleResult = false
 				// For detection rules, if check fails, stop execution
@@ -521,15 +546,25 @@ func (r *Ruleset) executeRuleOperations(rule *Rule, data map[string]interface{},
 				}
 				// For exclude rules, continue executing other operations
 			}
+		case T_Sequence:
+			sequenceResult := r.executeSequence(ctx, rule, op.ID, data, ruleCache)
+			if !sequenceResult {
+				ruleResult = false
+				// For detection rules, if sequence is not completed, stop execution
+				if r.IsDetection {
+					return false
+				}
+				// For exclude rules, continue executing other operations
+			}
 		case T_Append:
 			// Execute append operation according to user-defined order
-			r.executeAppend(rule, op.ID, data, ruleCache)
+			r.executeAppend(ctx, rule, op.ID, data, ruleCache)
 		case T_Del:
 			// Execute del operation according to user-defined order
 			r.executeDel(rule, op.ID, data)
 		case T_Plugin:
 			// Execute plugin operation according to user-defined order
-			r.executePlugin(rule, op.ID, data, ruleCache)
+			r.executePlugin(ctx, rule, op.ID, data, ruleCache)
 		}
 	}
 
@@ -537,12 +572,17 @@ func (r *Ruleset) executeRuleOperations(rule *Rule, data map[string]interface{},
 }
 
 // executeCheckList executes a checklist operation
-func (r *Ruleset) executeCheckList(rule *Rule, operationID int, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
+func (r *Ruleset) executeCheckList(ctx context.Context, rule *Rule, operationID int, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
 	checklist, exists := rule.ChecklistMap[operationID]
 	if !exists {
 		return true
 	}
 
+	return r.evalChecklist(ctx, &checklist, data, ruleCache)
+}
+
+// evalChecklist evaluates the check nodes of a checklist, honoring its condition expression
+func (r *Ruleset) evalChecklist(ctx context.Context, checklist *Checklist, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
 	// Pre-allocate conditionMap only if needed
 	var conditionMap map[string]bool
 	if checklist.ConditionFlag {
@@ -551,7 +591,7 @@ func (r *Ruleset) executeCheckList(rule *Rule, operationID int, data map[string]
 
 	// Execute each check node in the checklist
 	for _, checkNode := range checklist.CheckNodes {
-		checkResult := r.executeCheckNode(&checkNode, data, ruleCache)
+		checkResult := r.executeCheckNode(ctx, &checkNode, data, ruleCache)
 
 		if checklist.ConditionFlag {
 			conditionMap[checkNode.ID] = checkResult
@@ -572,17 +612,17 @@ func (r *Ruleset) executeCheckList(rule *Rule, operationID int, data map[string]
 }
 
 // executeCheck executes a standalone check operation
-func (r *Ruleset) executeCheck(rule *Rule, operationID int, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
+func (r *Ruleset) executeCheck(ctx context.Context, rule *Rule, operationID int, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
 	checkNode, exists := rule.CheckMap[operationID]
 	if !exists {
 		return true
 	}
 
-	return r.executeCheckNode(&checkNode, data, ruleCache)
+	return r.executeCheckNode(ctx, &checkNode, data, ruleCache)
 }
 
 // executeCheckNode executes a single check node
-func (r *Ruleset) executeCheckNode(checkNode *CheckNodes, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
+func (r *Ruleset) executeCheckNode(ctx context.Context, checkNode *CheckNodes, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
 	var checkNodeValue string
 	var checkNodeValueFromRaw bool
 
@@ -594,7 +634,7 @@ func (r *Ruleset) executeCheckNode(checkNode *CheckNodes, data map[string]interf
 		} else {
 			checkNodeValue = checkNode.Value
 		}
-		return checkNodeLogic(checkNode, data, checkNodeValue, checkNodeValueFromRaw, ruleCache, r.RegexResultCache)
+		return checkNodeLogic(ctx, checkNode, data, checkNodeValue, checkNodeValueFromRaw, ruleCache, r.RegexResultCache)
 	case "AND":
 		for _, v := range checkNode.DelimiterFieldList {
 			if hasFromRawPrefix(v) {
@@ -604,7 +644,7 @@ func (r *Ruleset) executeCheckNode(checkNode *CheckNodes, data map[string]interf
 				checkNodeValue = v
 				checkNodeValueFromRaw = false
 			}
-			if !checkNodeLogic(checkNode, data, checkNodeValue, checkNodeValueFromRaw, ruleCache, r.RegexResultCache) {
+			if !checkNodeLogic(ctx, checkNode, data, checkNodeValue, checkNodeValueFromRaw, ruleCache, r.RegexResultCache) {
 				return false
 			}
 		}
@@ -618,7 +658,7 @@ func (r *Ruleset) executeCheckNode(checkNode *CheckNodes, data map[string]interf
 				checkNodeValue = v
 				checkNodeValueFromRaw = false
 			}
-			if checkNodeLogic(checkNode, data, checkNodeValue, checkNodeValueFromRaw, ruleCache, r.RegexResultCache) {
+			if checkNodeLogic(ctx, checkNode, data, checkNodeValue, checkNodeValueFromRaw, ruleCache, r.RegexResultCache) {
 				return true
 			}
 		}
@@ -650,6 +690,7 @@ func (r *Ruleset) executeThreshold(rule *Rule, operationID int, data map[string]
 
 	var ruleCheckRes bool
 	var err error
+	var hitValue interface{}
 
 	switch threshold.CountType {
 	case "":
@@ -661,11 +702,13 @@ func (r *Ruleset) executeThreshold(rule *Rule, operationID int, data map[string]
 		prefixedKey := sb.String()
 		stringBuilderPool.Put(sb)
 
+		var count int
 		if threshold.LocalCache {
-			ruleCheckRes, err = r.LocalCacheFRQSum(prefixedKey, 1, threshold.RangeInt, threshold.Value)
+			ruleCheckRes, count, err = r.LocalCacheFRQSum(prefixedKey, 1, threshold.RangeInt, threshold.Value)
 		} else {
-			ruleCheckRes, err = RedisFRQSum(prefixedKey, 1, threshold.RangeInt, threshold.Value)
+			ruleCheckRes, count, err = RedisFRQSum(prefixedKey, 1, threshold.RangeInt, threshold.Value)
 		}
+		hitValue = count
 
 	case "SUM":
 		// Use builder pool for prefix concatenation
@@ -681,16 +724,18 @@ func (r *Ruleset) executeThreshold(rule *Rule, operationID int, data map[string]
 			return false
 		}
 
-		sumData, err := strconv.Atoi(sumDataStr)
-		if err != nil {
+		sumData, convErr := strconv.Atoi(sumDataStr)
+		if convErr != nil {
 			return false
 		}
 
+		var sum int
 		if threshold.LocalCache {
-			ruleCheckRes, err = r.LocalCacheFRQSum(prefixedKey, sumData, threshold.RangeInt, threshold.Value)
+			ruleCheckRes, sum, err = r.LocalCacheFRQSum(prefixedKey, sumData, threshold.RangeInt, threshold.Value)
 		} else {
-			ruleCheckRes, err = RedisFRQSum(prefixedKey, sumData, threshold.RangeInt, threshold.Value)
+			ruleCheckRes, sum, err = RedisFRQSum(prefixedKey, sumData, threshold.RangeInt, threshold.Value)
 		}
+		hitValue = sum
 
 	case "CLASSIFY":
 		// Use builder pool for prefix concatenation
@@ -712,11 +757,48 @@ func (r *Ruleset) executeThreshold(rule *Rule, operationID int, data map[string]
 		tmpKey := sb.String()
 		stringBuilderPool.Put(sb)
 
+		var count int
+		if threshold.LocalCache {
+			ruleCheckRes, count, err = r.LocalCacheFRQClassify(tmpKey, prefixedKey, threshold.RangeInt, threshold.Value)
+		} else {
+			ruleCheckRes, count, err = RedisFRQClassify(tmpKey, prefixedKey, threshold.RangeInt, threshold.Value)
+		}
+		hitValue = count
+
+	case "DISTINCT":
+		// HyperLogLog estimate, memory per group does not grow with the number of distinct values
+		distinctData, ok := GetCheckDataFromCache(ruleCache, threshold.CountField, data, threshold.CountFieldList)
+		if !ok {
+			return false
+		}
+
+		prefixedKey := "FD_" + groupByKey
+		var count int
+		if threshold.LocalCache {
+			ruleCheckRes, count, err = r.LocalCacheFRQDistinct(prefixedKey, distinctData, threshold.RangeInt, threshold.Value)
+		} else {
+			ruleCheckRes, count, err = RedisFRQDistinct(prefixedKey, distinctData, threshold.RangeInt, threshold.Value)
+		}
+		hitValue = count
+
+	case "AVG", "MAX", "MIN":
+		typedData, ok := GetCheckDataWithTypeFromCache(ruleCache, threshold.CountField, data, threshold.CountFieldList)
+		if !ok {
+			return false
+		}
+		value, ok := typedToFloat(typedData)
+		if !ok {
+			return false
+		}
+
+		prefixedKey := "FA_" + threshold.CountType + "_" + groupByKey
+		var aggregate float64
 		if threshold.LocalCache {
-			ruleCheckRes, err = r.LocalCacheFRQClassify(tmpKey, prefixedKey, threshold.RangeInt, threshold.Value)
+			ruleCheckRes, aggregate, err = r.LocalCacheFRQAggregate(prefixedKey, threshold.CountType, value, threshold.RangeInt, threshold.Value)
 		} else {
-			ruleCheckRes, err = RedisFRQClassify(tmpKey, prefixedKey, threshold.RangeInt, threshold.Value)
+			ruleCheckRes, aggregate, err = RedisFRQAggregate(prefixedKey, threshold.CountType, value, threshold.RangeInt, threshold.Value)
 		}
+		hitValue = aggregate
 	}
 
 	if err != nil {
@@ -724,11 +806,55 @@ func (r *Ruleset) executeThreshold(rule *Rule, operationID int, data map[string]
 		return false
 	}
 
+	// Excluded events are dropped, only alerts carry the value
+	if ruleCheckRes && r.IsDetection {
+		data[HitThresholdValueFieldName] = hitValue
+	}
+
+	return ruleCheckRes
+}
+
+// executeSequence executes a sequence operation
+// It returns true only on the event that completes the last step of the sequence
+func (r *Ruleset) executeSequence(ctx context.Context, rule *Rule, operationID int, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
+	sequence, exists := rule.SequenceMap[operationID]
+	if !exists {
+		return true
+	}
+
+	sb := stringBuilderPool.Get().(*strings.Builder)
+	sb.Reset()
+	sb.WriteString(sequence.GroupByID)
+
+	for k, v := range sequence.GroupByList {
+		tmpData, _ := GetCheckDataFromCache(ruleCache, k, data, v)
+		sb.WriteString(tmpData)
+	}
+	groupByKey := "SQ_" + common.XXHash64(sb.String())
+	stringBuilderPool.Put(sb)
+
+	match := func(step *SequenceStep) bool {
+		return r.evalChecklist(ctx, &step.Checklist, data, ruleCache)
+	}
+
+	var ruleCheckRes bool
+	var err error
+	if sequence.LocalCache {
+		ruleCheckRes, err = r.LocalCacheSequence(groupByKey, sequence.Steps, sequence.RangeInt, match)
+	} else {
+		ruleCheckRes, err = RedisSequence(groupByKey, sequence.Steps, sequence.RangeInt, match)
+	}
+
+	if err != nil {
+		logger.Error("Sequence check error:", err, "GroupByKey:", groupByKey, "RuleID:", rule.ID, "RuleSetID:", r.RulesetID)
+		return false
+	}
+
 	return ruleCheckRes
 }
 
 // executeAppend executes an append operation
-func (r *Ruleset) executeAppend(rule *Rule, operationID int, dataCopy map[string]interface{}, ruleCache map[string]common.CheckCoreCache) {
+func (r *Ruleset) executeAppend(ctx context.Context, rule *Rule, operationID int, dataCopy map[string]interface{}, ruleCache map[string]common.CheckCoreCache) {
 	appendOp, exists := rule.AppendsMap[operationID]
 	if !exists {
 		return
@@ -741,6 +867,11 @@ func (r *Ruleset) executeAppend(rule *Rule, operationID int, dataCopy map[string
 		}
 
 		dataCopy[appendOp.FieldName] = appendData
+	} else if appendOp.Type == "LOOKUP" {
+		key := GetRuleValueFromRawFromCache(ruleCache, appendOp.Value, dataCopy)
+		if row, ok := lookupAppendValue(&appendOp, key); ok {
+			dataCopy[appendOp.FieldName] = row
+		}
 	} else {
 		// Plugin
 		args := GetPluginRealArgs(appendOp.PluginArgs, dataCopy, ruleCache)
@@ -748,7 +879,9 @@ func (r *Ruleset) executeAppend(rule *Rule, operationID int, dataCopy map[string
 		// Check plugin return type to determine which evaluation method to use
 		if appendOp.Plugin.ReturnType == "bool" {
 			// For check-type plugins (bool return type), use FuncEvalCheckNode and get the boolean result
-			boolResult, err := appendOp.Plugin.FuncEvalCheckNode(args...)
+			span := startPluginSpan(ctx, appendOp.Plugin, "append", rule.ID)
+			boolResult, err := appendOp.Plugin.FuncEvalCheckNodeCached(appendOp.CacheTTLInt, appendOp.LocalCache, args...)
+			endPluginSpan(span, boolResult, err)
 			if err == nil {
 				dataCopy[appendOp.FieldName] = boolResult
 			} else {
@@ -756,7 +889,9 @@ func (r *Ruleset) executeAppend(rule *Rule, operationID int, dataCopy map[string
 			}
 		} else {
 			// For interface{} type plugins, use the original FuncEvalOther logic
-			res, ok, err := appendOp.Plugin.FuncEvalOther(args...)
+			span := startPluginSpan(ctx, appendOp.Plugin, "append", rule.ID)
+			res, ok, err := appendOp.Plugin.FuncEvalOtherCached(appendOp.CacheTTLInt, appendOp.LocalCache, args...)
+			endPluginSpan(span, res, err)
 			if err == nil && ok {
 				if appendOp.FieldName == PluginArgFromRawSymbol {
 					if r, ok := res.(map[string]interface{}); ok {
@@ -788,7 +923,7 @@ func (r *Ruleset) executeDel(rule *Rule, operationID int, dataCopy map[string]in
 }
 
 // executePlugin executes a plugin operation
-func (r *Ruleset) executePlugin(rule *Rule, operationID int, dataCopy map[string]interface{}, ruleCache map[string]common.CheckCoreCache) {
+func (r *Ruleset) executePlugin(ctx context.Context, rule *Rule, operationID int, dataCopy map[string]interface{}, ruleCache map[string]common.CheckCoreCache) {
 	pluginOp, exists := rule.PluginMap[operationID]
 	if !exists {
 		return
@@ -799,7 +934,9 @@ func (r *Ruleset) executePlugin(rule *Rule, operationID int, dataCopy map[string
 	// Check plugin return type to determine which evaluation method to use
 	if pluginOp.Plugin.ReturnType == "bool" {
 		// For check-type plugins (bool return type), use FuncEvalCheckNode
-		ok, err := pluginOp.Plugin.FuncEvalCheckNode(args...)
+		span := startPluginSpan(ctx, pluginOp.Plugin, "plugin", rule.ID)
+		ok, err := pluginOp.Plugin.FuncEvalCheckNodeCached(pluginOp.CacheTTLInt, pluginOp.LocalCache, args...)
+		endPluginSpan(span, ok, err)
 		if err != nil {
 			logger.PluginError("Check-type plugin evaluation error", "plugin", pluginOp.Plugin.Name, "error", err)
 		}
@@ -809,7 +946,9 @@ func (r *Ruleset) executePlugin(rule *Rule, operationID int, dataCopy map[string
 		}
 	} else {
 		// For interface{} type plugins, use FuncEvalOther (for side effects, result is ignored)
-		_, ok, err := pluginOp.Plugin.FuncEvalOther(args...)
+		span := startPluginSpan(ctx, pluginOp.Plugin, "plugin", rule.ID)
+		res, ok, err := pluginOp.Plugin.FuncEvalOtherCached(pluginOp.CacheTTLInt, pluginOp.LocalCache, args...)
+		endPluginSpan(span, res, err)
 		if err != nil {
 			logger.PluginError("Interface-type plugin evaluation error", "plugin", pluginOp.Plugin.Name, "error", err)
 		}
@@ -821,9 +960,19 @@ func (r *Ruleset) executePlugin(rule *Rule, operationID int, dataCopy map[string
 }
 
 // checkNodeLogic executes the check logic for a single check node.
-func checkNodeLogic(checkNode *CheckNodes, data map[string]interface{}, checkNodeValue string, checkNodeValueFromRaw bool, ruleCache map[string]common.CheckCoreCache, regexResultCache *RegexResultCache) bool {
+func checkNodeLogic(ctx context.Context, checkNode *CheckNodes, data map[string]interface{}, checkNodeValue string, checkNodeValueFromRaw bool, ruleCache map[string]common.CheckCoreCache, regexResultCache *RegexResultCache) bool {
 	var checkListFlag = false
 
+	// Typed checks compare the original value, a missing field never matches
+	if checkNode.TypedCheckFunc != nil {
+		typedData, exist := GetCheckDataWithTypeFromCache(ruleCache, checkNode.Field, data, checkNode.FieldList)
+		if !exist {
+			return false
+		}
+		checkListFlag, _ = checkNode.TypedCheckFunc(typedData, checkNodeValue)
+		return checkListFlag
+	}
+
 	needCheckData, exist := common.GetCheckData(data, checkNode.FieldList)
 
 	// CRITICAL FIX: Handle field existence properly for ISNULL and NOTNULL checks
@@ -867,7 +1016,9 @@ func checkNodeLogic(checkNode *CheckNodes, data map[string]interface{}, checkNod
 		}
 	case "PLUGIN":
 		args := GetPluginRealArgs(checkNode.PluginArgs, data, ruleCache)
+		span := startPluginSpan(ctx, checkNode.Plugin, "check", "")
 		result, err := checkNode.Plugin.FuncEvalCheckNode(args...)
+		endPluginSpan(span, result, err)
 		if err != nil {
 			return false
 		}
@@ -958,11 +1109,30 @@ func (r *Ruleset) ruleModifiesData(rule *Rule) bool {
 	if rule.Queue == nil {
 		return false
 	}
+	if rule.SuppressWindowInt > 0 {
+		return true // Suppressed alerts are kept for the rollup
+	}
 
-	for _, op := range *rule.Queue {
+	for i, op := range *rule.Queue {
 		switch op.Type {
 		case T_Append, T_Del, T_Plugin:
 			return true // These operations modify data
+		case T_Threshold:
+			// A hit adds its computed value to the alert, which must not stay in the event when the rule fails afterwards
+			if r.IsDetection && canFailRule((*rule.Queue)[i+1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canFailRule reports whether one of the operations can still fail the rule
+func canFailRule(ops []EngineOperator) bool {
+	for _, op := range ops {
+		switch op.Type {
+		case T_CheckList, T_Check, T_Threshold, T_Sequence:
+			return true
 		}
 	}
 	return false
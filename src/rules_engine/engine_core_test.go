@@ -0,0 +1,38 @@
+package rules_engine
+
+import "testing"
+
+func TestRuleModifiesData(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection bool
+		ops       []OperatorType
+		suppress  int
+		modifies  bool
+	}{
+		{"checks only", true, []OperatorType{T_CheckList, T_Check}, 0, false},
+		{"threshold last", true, []OperatorType{T_Check, T_Threshold}, 0, false},
+		{"check after threshold", true, []OperatorType{T_Threshold, T_Check}, 0, true},
+		{"sequence after threshold", true, []OperatorType{T_Threshold, T_Sequence}, 0, true},
+		{"two thresholds", true, []OperatorType{T_Threshold, T_Threshold}, 0, true},
+		{"threshold in exclude", false, []OperatorType{T_Threshold, T_Check}, 0, false},
+		{"append", false, []OperatorType{T_Check, T_Append}, 0, true},
+		{"plugin", true, []OperatorType{T_Plugin}, 0, true},
+		{"del", true, []OperatorType{T_Del}, 0, true},
+		{"suppression", true, []OperatorType{T_Check}, 60, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := make([]EngineOperator, len(test.ops))
+			for i, op := range test.ops {
+				queue[i] = EngineOperator{Type: op}
+			}
+			r := &Ruleset{IsDetection: test.detection}
+			rule := &Rule{Queue: &queue, Suppression: Suppression{SuppressWindowInt: test.suppress}}
+			if modifies := r.ruleModifiesData(rule); modifies != test.modifies {
+				t.Errorf("ruleModifiesData() = %v, expected %v", modifies, test.modifies)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/lookup"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Lookup tables (see lookup/lookup.go) are used by checks and appends:
+//
+//	<check type="IN_LOOKUP" field="src_ip">bad_ips</check>        field value is in lookup bad_ips
+//	<check type="NOT_IN_LOOKUP" field="user">admins</check>       field value is not in lookup admins
+//	<append type="LOOKUP" field="asset" lookup="assets" columns="owner,env">_$src_ip</append>
+//
+// The append adds the row matching the referenced field as an object (only the listed columns
+// if columns is set), nothing is appended when no row matches. Lookups are resolved by name
+// on every event, so a new version of a lookup is used without restarting projects.
+// When the lookup is deleted, both checks fail until it is added again.
+
+var lookupCheckTypes = map[string]bool{
+	"IN_LOOKUP": true, "NOT_IN_LOOKUP": true,
+}
+
+// checkLookupRef returns an error if the lookup cannot be referenced by a ruleset
+func checkLookupRef(name string) error {
+	if name == "" {
+		return errors.New("lookup name cannot be empty")
+	}
+	if lookup.Exists(name) {
+		return nil
+	}
+	if lookup.IsTemporary(name) {
+		return fmt.Errorf("cannot reference temporary lookup '%s', please save it first", name)
+	}
+	return fmt.Errorf("lookup not found: %s", name)
+}
+
+// buildLookupCheckFunc returns the check function of IN_LOOKUP and NOT_IN_LOOKUP
+func buildLookupCheckFunc(node *CheckNodes) (func(interface{}, string) (bool, string), error) {
+	checkType := strings.TrimSpace(node.Type)
+	name := strings.TrimSpace(node.Value)
+
+	if node.Logic != "" || node.Delimiter != "" {
+		return nil, fmt.Errorf("%s check does not support logic and delimiter", checkType)
+	}
+	if hasFromRawPrefix(name) {
+		return nil, fmt.Errorf("%s check value must be a lookup name, it cannot reference raw data", checkType)
+	}
+	if err := checkLookupRef(name); err != nil {
+		return nil, err
+	}
+
+	negate := checkType == "NOT_IN_LOOKUP"
+	return func(data interface{}, _ string) (bool, string) {
+		if !lookup.Exists(name) {
+			return false, ""
+		}
+		hit, hitData := lookup.ContainsAny(name, data)
+		if negate {
+			return !hit, ""
+		}
+		return hit, hitData
+	}, nil
+}
+
+// parseLookupColumns splits the columns attribute of a LOOKUP append
+func parseLookupColumns(columns string) []string {
+	if strings.TrimSpace(columns) == "" {
+		return nil
+	}
+	list := make([]string, 0)
+	for _, c := range strings.Split(columns, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// validateLookupAppend checks the lookup, columns and key reference of a LOOKUP append
+func validateLookupAppend(appendElem *Append) error {
+	if err := checkLookupRef(appendElem.Lookup); err != nil {
+		return err
+	}
+	if !hasFromRawPrefix(appendElem.Value) || len(appendElem.Value) <= len(FromRawSymbol) {
+		return fmt.Errorf("append LOOKUP value must reference the key field, e.g. _$src_ip, got '%s'", appendElem.Value)
+	}
+	if appendElem.Value == PluginArgFromRawSymbol {
+		return errors.New("append LOOKUP value cannot be _$ORIDATA")
+	}
+
+	l, _ := lookup.Get(appendElem.Lookup)
+	known := make(map[string]bool)
+	for _, c := range l.Columns() {
+		known[c] = true
+	}
+	for _, c := range parseLookupColumns(appendElem.Columns) {
+		if !known[c] {
+			return fmt.Errorf("lookup '%s' has no column '%s'", appendElem.Lookup, c)
+		}
+	}
+	return nil
+}
+
+// lookupAppendValue returns the matched row of a LOOKUP append as a new object
+func lookupAppendValue(appendOp *Append, key string) (map[string]interface{}, bool) {
+	l, ok := lookup.Get(appendOp.Lookup)
+	if !ok || key == "" {
+		return nil, false
+	}
+	row, ok := l.Row(key)
+	if !ok {
+		return nil, false
+	}
+
+	res := make(map[string]interface{}, len(row))
+	if len(appendOp.ColumnList) == 0 {
+		for k, v := range row {
+			res[k] = v
+		}
+		return res, true
+	}
+	for _, c := range appendOp.ColumnList {
+		if v, exists := row[c]; exists {
+			res[c] = v
+		}
+	}
+	return res, true
+}
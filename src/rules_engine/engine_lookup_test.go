@@ -0,0 +1,140 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/lookup"
+	"strings"
+	"testing"
+)
+
+// addTestLookup registers a lookup for the duration of the test
+func addTestLookup(t *testing.T, id, config string) {
+	t.Helper()
+	if _, err := lookup.NewLookup("", config, id); err != nil {
+		t.Fatalf("NewLookup(%s) failed: %v", id, err)
+	}
+	t.Cleanup(func() { lookup.SafeDeleteLookup(id) })
+}
+
+func TestBuildLookupCheckFunc(t *testing.T) {
+	addTestLookup(t, "lookup_check_ips", "format: csv\nmatch: cidr\ncontent: |\n  net\n  10.0.0.0/8\n")
+	lookup.SetLookupNew("lookup_check_draft", "format: csv\ncontent: a\n")
+	t.Cleanup(func() { lookup.DeleteLookupNew("lookup_check_draft") })
+
+	errTests := []struct {
+		node CheckNodes
+		err  string
+	}{
+		{CheckNodes{Type: "IN_LOOKUP", Value: ""}, "cannot be empty"},
+		{CheckNodes{Type: "IN_LOOKUP", Value: "missing"}, "lookup not found: missing"},
+		{CheckNodes{Type: "IN_LOOKUP", Value: "lookup_check_draft"}, "temporary lookup"},
+		{CheckNodes{Type: "IN_LOOKUP", Value: "_$name"}, "cannot reference raw data"},
+		{CheckNodes{Type: "NOT_IN_LOOKUP", Value: "lookup_check_ips", Logic: "OR", Delimiter: "|"}, "does not support logic"},
+	}
+	for _, test := range errTests {
+		if _, err := buildLookupCheckFunc(&test.node); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("buildLookupCheckFunc(%s %q) = %v, expected %q", test.node.Type, test.node.Value, err, test.err)
+		}
+	}
+
+	in, err := buildLookupCheckFunc(&CheckNodes{Type: "IN_LOOKUP", Value: "lookup_check_ips"})
+	if err != nil {
+		t.Fatalf("buildLookupCheckFunc(IN_LOOKUP) failed: %v", err)
+	}
+	notIn, err := buildLookupCheckFunc(&CheckNodes{Type: "NOT_IN_LOOKUP", Value: "lookup_check_ips"})
+	if err != nil {
+		t.Fatalf("buildLookupCheckFunc(NOT_IN_LOOKUP) failed: %v", err)
+	}
+
+	tests := []struct {
+		data  interface{}
+		in    bool
+		hit   string
+		notIn bool
+	}{
+		{"10.1.2.3", true, "10.1.2.3", false},
+		{"192.168.0.1", false, "", true},
+		{[]interface{}{"192.168.0.1", "10.0.0.1"}, true, "10.0.0.1", false},
+	}
+	for _, test := range tests {
+		if res, hit := in(test.data, ""); res != test.in || hit != test.hit {
+			t.Errorf("IN_LOOKUP(%v) = %v, %q, expected %v, %q", test.data, res, hit, test.in, test.hit)
+		}
+		if res, _ := notIn(test.data, ""); res != test.notIn {
+			t.Errorf("NOT_IN_LOOKUP(%v) = %v, expected %v", test.data, res, test.notIn)
+		}
+	}
+
+	// Checks use the current version of the lookup, and fail while it is deleted
+	addTestLookup(t, "lookup_check_ips", "format: csv\nmatch: cidr\ncontent: |\n  net\n  192.168.0.0/16\n")
+	if res, _ := in("192.168.0.1", ""); !res {
+		t.Errorf("IN_LOOKUP did not use the new version of the lookup")
+	}
+	lookup.SafeDeleteLookup("lookup_check_ips")
+	if res, _ := in("192.168.0.1", ""); res {
+		t.Errorf("IN_LOOKUP matched a deleted lookup")
+	}
+	if res, _ := notIn("192.168.0.1", ""); res {
+		t.Errorf("NOT_IN_LOOKUP matched a deleted lookup")
+	}
+}
+
+func TestLookupInRuleset(t *testing.T) {
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir()}
+	defer func() { common.Config = prevConfig }()
+
+	addTestLookup(t, "lookup_rs_assets", "format: csv\nmatch: cidr\ncontent: |\n  net,owner,env\n  10.0.0.0/8,netops,prod\n  10.9.0.0/16,lab,test\n")
+	addTestLookup(t, "lookup_rs_admins", "format: json\ncase_insensitive: true\ncontent: '[\"root\", \"Admin\"]'\n")
+
+	ruleset := `<root type="DETECTION">
+    <rule id="internal_login">
+        <check type="IN_LOOKUP" field="src_ip">lookup_rs_assets</check>
+        <check type="NOT_IN_LOOKUP" field="user">lookup_rs_admins</check>
+        <append type="LOOKUP" field="asset" lookup="lookup_rs_assets" columns="owner,env">_$src_ip</append>
+    </rule>
+</root>`
+	cases, err := ParseTestCases([]byte(`tests:
+  - name: most specific network
+    data: {src_ip: 10.9.1.1, user: alice}
+    hits: [internal_login]
+    fields: {asset: {owner: lab, env: test}}
+  - name: admin excluded case insensitive
+    data: {src_ip: 10.1.1.1, user: ADMIN}
+    hits: []
+  - name: external address
+    data: {src_ip: 8.8.8.8, user: alice}
+    hits: []
+`))
+	if err != nil {
+		t.Fatalf("ParseTestCases failed: %v", err)
+	}
+	report, err := RunTestCases("lookups", ruleset, cases)
+	if err != nil {
+		t.Fatalf("RunTestCases failed: %v", err)
+	}
+	if err := report.Error(); err != nil {
+		t.Error(err)
+	}
+
+	errTests := []struct {
+		name   string
+		append string
+		err    string
+	}{
+		{"unknown column", `<append type="LOOKUP" field="a" lookup="lookup_rs_assets" columns="owner,site">_$src_ip</append>`, "has no column 'site'"},
+		{"unknown lookup", `<append type="LOOKUP" field="a" lookup="missing">_$src_ip</append>`, "lookup not found"},
+		{"static key", `<append type="LOOKUP" field="a" lookup="lookup_rs_assets">10.0.0.1</append>`, "must reference the key field"},
+		{"whole event", `<append type="LOOKUP" field="a" lookup="lookup_rs_assets">_$ORIDATA</append>`, "cannot be _$ORIDATA"},
+		{"lookup on another type", `<append field="a" lookup="lookup_rs_assets">x</append>`, "only supported with type 'LOOKUP'"},
+	}
+	for _, test := range errTests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := `<root type="DETECTION"><rule id="r"><check type="NOTNULL" field="src_ip"></check>` + test.append + `</rule></root>`
+			_, err := ParseRuleset([]byte(raw))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseRuleset() = %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
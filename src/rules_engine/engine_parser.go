@@ -51,6 +51,8 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 	var currentRule *Rule
 	var currentChecklist *Checklist
 	var inChecklist bool
+	var currentSequence *Sequence
+	var currentStep *SequenceStep
 	var operatorIDCounter int
 	var currentLine int = 1
 
@@ -99,6 +101,7 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 					AppendsMap:   make(map[int]Append),
 					PluginMap:    make(map[int]Plugin),
 					DelMap:       make(map[int][][]string),
+					SequenceMap:  make(map[int]Sequence),
 				}
 
 				// Parse rule attributes
@@ -111,15 +114,42 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 						currentRule.ID = attr.Value
 					case "name":
 						currentRule.Name = attr.Value
+					case "suppress_by":
+						suppressBy := strings.TrimSpace(attr.Value)
+						if suppressBy == "" {
+							return nil, fmt.Errorf("rule suppress_by cannot be empty at line %d", elementLine)
+						}
+						currentRule.SuppressBy = suppressBy
+					case "suppress_window":
+						window := strings.TrimSpace(attr.Value)
+						if _, err := common.ParseDurationToSecondsInt(window); err != nil {
+							return nil, fmt.Errorf("rule suppress_window '%s' is invalid: %v at line %d", attr.Value, err, elementLine)
+						}
+						currentRule.SuppressWindow = window
+					case "suppress_local_cache":
+						localCache := strings.TrimSpace(attr.Value)
+						if localCache != "" && localCache != "true" && localCache != "false" {
+							return nil, fmt.Errorf("rule suppress_local_cache must be 'true' or 'false', got '%s' at line %d", localCache, elementLine)
+						}
+						currentRule.SuppressLocalCache = localCache == "true"
 					}
 				}
 
 				if currentRule.ID == "" {
 					return nil, fmt.Errorf("rule id is required at line %d", elementLine)
 				}
+				if (currentRule.SuppressBy == "") != (currentRule.SuppressWindow == "") {
+					return nil, fmt.Errorf("rule suppress_by and suppress_window must be set together in rule '%s' at line %d", currentRule.ID, elementLine)
+				}
+				if currentRule.SuppressBy != "" && ruleset.Type == "EXCLUDE" {
+					return nil, fmt.Errorf("rule suppression is only supported in DETECTION rulesets, rule '%s' at line %d", currentRule.ID, elementLine)
+				}
 
 			case "checklist":
 				if currentRule != nil {
+					if currentSequence != nil {
+						return nil, fmt.Errorf("checklist is not allowed inside sequence in rule '%s' at line %d, use step instead", currentRule.ID, elementLine)
+					}
 					inChecklist = true
 					currentChecklist = &Checklist{
 						CheckNodes: []CheckNodes{},
@@ -154,6 +184,11 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 					if inChecklist && currentChecklist != nil {
 						// Add to current checklist
 						currentChecklist.CheckNodes = append(currentChecklist.CheckNodes, checkNode)
+					} else if currentStep != nil {
+						// Add to current sequence step
+						currentStep.CheckNodes = append(currentStep.CheckNodes, checkNode)
+					} else if currentSequence != nil {
+						return nil, fmt.Errorf("check inside sequence must be wrapped in a step in rule '%s' at line %d", currentRule.ID, elementLine)
 					} else {
 						// Standalone check node
 						operatorIDCounter++
@@ -180,6 +215,33 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 					})
 				}
 
+			case "sequence":
+				if currentRule != nil {
+					if inChecklist || currentSequence != nil {
+						return nil, fmt.Errorf("sequence cannot be nested in rule '%s' at line %d", currentRule.ID, elementLine)
+					}
+					sequence, err := parseSequence(element, elementLine)
+					if err != nil {
+						return nil, err
+					}
+					currentSequence = &sequence
+				}
+
+			case "step":
+				if currentRule != nil {
+					if currentSequence == nil {
+						return nil, fmt.Errorf("step must be inside sequence in rule '%s' at line %d", currentRule.ID, elementLine)
+					}
+					if currentStep != nil {
+						return nil, fmt.Errorf("step cannot be nested in rule '%s' at line %d", currentRule.ID, elementLine)
+					}
+					step, err := parseSequenceStep(element, elementLine)
+					if err != nil {
+						return nil, err
+					}
+					currentStep = &step
+				}
+
 			case "append":
 				if currentRule != nil {
 					appendOp, err := parseAppend(element, decoder, elementLine)
@@ -235,6 +297,8 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 						return nil, fmt.Errorf("unsupported element '<%s>' in rule '%s' at line %d. The 'filter' tag has been removed in the new syntax", element.Name.Local, currentRule.ID, elementLine)
 					} else if inChecklist {
 						return nil, fmt.Errorf("unsupported element '<%s>' inside checklist in rule '%s' at line %d", element.Name.Local, currentRule.ID, elementLine)
+					} else if currentSequence != nil {
+						return nil, fmt.Errorf("unsupported element '<%s>' inside sequence in rule '%s' at line %d", element.Name.Local, currentRule.ID, elementLine)
 					} else {
 						return nil, fmt.Errorf("unsupported element '<%s>' in rule '%s' at line %d", element.Name.Local, currentRule.ID, elementLine)
 					}
@@ -258,6 +322,29 @@ func ParseRuleset(rawRuleset []byte) (*Ruleset, error) {
 					currentChecklist = nil
 				}
 
+			case "step":
+				if currentSequence != nil && currentStep != nil {
+					if len(currentStep.CheckNodes) == 0 {
+						return nil, fmt.Errorf("sequence step must have at least one check node at line %d", currentLine)
+					}
+					currentSequence.Steps = append(currentSequence.Steps, *currentStep)
+					currentStep = nil
+				}
+
+			case "sequence":
+				if currentRule != nil && currentSequence != nil {
+					if len(currentSequence.Steps) == 0 {
+						return nil, fmt.Errorf("sequence must have at least one step in rule '%s' at line %d", currentRule.ID, currentLine)
+					}
+					operatorIDCounter++
+					currentRule.SequenceMap[operatorIDCounter] = *currentSequence
+					*currentRule.Queue = append(*currentRule.Queue, EngineOperator{
+						Type: T_Sequence,
+						ID:   operatorIDCounter,
+					})
+					currentSequence = nil
+				}
+
 			case "rule":
 				if currentRule != nil {
 					// Convert to final rule structure
@@ -403,8 +490,8 @@ func parseThreshold(element xml.StartElement, decoder *XMLDecoder, elementLine i
 			}
 		case "count_type":
 			countType := strings.TrimSpace(attr.Value)
-			if countType != "" && countType != "SUM" && countType != "CLASSIFY" {
-				return threshold, fmt.Errorf("threshold count_type must be empty (default count mode), 'SUM', or 'CLASSIFY', got '%s' at line %d", countType, elementLine)
+			if !thresholdCountTypes[countType] {
+				return threshold, fmt.Errorf("threshold count_type must be %s, got '%s' at line %d", thresholdCountTypesDesc, countType, elementLine)
 			}
 			threshold.CountType = countType
 		case "count_field":
@@ -452,7 +539,7 @@ func parseThreshold(element xml.StartElement, decoder *XMLDecoder, elementLine i
 				}
 
 				// Validate count_field requirement
-				if (threshold.CountType == "SUM" || threshold.CountType == "CLASSIFY") && threshold.CountField == "" {
+				if thresholdNeedsCountField(threshold.CountType) && threshold.CountField == "" {
 					return threshold, fmt.Errorf("threshold count_field cannot be empty when count_type is '%s' at line %d", threshold.CountType, elementLine)
 				}
 
@@ -462,6 +549,80 @@ func parseThreshold(element xml.StartElement, decoder *XMLDecoder, elementLine i
 	}
 }
 
+func parseSequence(element xml.StartElement, elementLine int) (Sequence, error) {
+	var sequence Sequence
+
+	for _, attr := range element.Attr {
+		switch attr.Name.Local {
+		case "group_by":
+			groupBy := strings.TrimSpace(attr.Value)
+			if groupBy == "" {
+				return sequence, fmt.Errorf("sequence group_by cannot be empty at line %d", elementLine)
+			}
+			sequence.GroupBy = groupBy
+		case "range":
+			rangeValue := strings.TrimSpace(attr.Value)
+			if rangeValue == "" {
+				return sequence, fmt.Errorf("sequence range cannot be empty at line %d", elementLine)
+			}
+			sequence.Range = rangeValue
+		case "local_cache":
+			localCache := strings.TrimSpace(attr.Value)
+			if localCache != "" && localCache != "true" && localCache != "false" {
+				return sequence, fmt.Errorf("sequence local_cache must be 'true' or 'false', got '%s' at line %d", localCache, elementLine)
+			}
+			sequence.LocalCache = localCache == "true"
+		}
+	}
+
+	if sequence.GroupBy == "" {
+		return sequence, fmt.Errorf("sequence group_by is required at line %d", elementLine)
+	}
+	if sequence.Range == "" {
+		return sequence, fmt.Errorf("sequence range is required at line %d", elementLine)
+	}
+
+	return sequence, nil
+}
+
+func parseSequenceStep(element xml.StartElement, elementLine int) (SequenceStep, error) {
+	step := SequenceStep{
+		Count: 1,
+		Checklist: Checklist{
+			CheckNodes: []CheckNodes{},
+		},
+	}
+
+	for _, attr := range element.Attr {
+		switch attr.Name.Local {
+		case "id":
+			step.ID = strings.TrimSpace(attr.Value)
+		case "count":
+			if val, err := strconv.Atoi(strings.TrimSpace(attr.Value)); err != nil {
+				return step, fmt.Errorf("step count must be a positive integer, got '%s' at line %d", attr.Value, elementLine)
+			} else if val <= 0 {
+				return step, fmt.Errorf("step count must be greater than 0, got %d at line %d", val, elementLine)
+			} else {
+				step.Count = val
+			}
+		case "condition":
+			condition := strings.TrimSpace(attr.Value)
+			if condition == "" {
+				return step, fmt.Errorf("step condition cannot be empty at line %d", elementLine)
+			}
+			if _, _, ok := ConditionRegex.Find(condition); !ok {
+				return step, fmt.Errorf("step condition is not a valid expression: %s at line %d", condition, elementLine)
+			}
+			step.Condition = condition
+			step.ConditionFlag = true
+			step.ConditionAST = GetAST(condition)
+			step.ConditionMap = make(map[string]bool)
+		}
+	}
+
+	return step, nil
+}
+
 func parseAppend(element xml.StartElement, decoder *XMLDecoder, elementLine int) (Append, error) {
 	var appendElem Append
 
@@ -470,8 +631,8 @@ func parseAppend(element xml.StartElement, decoder *XMLDecoder, elementLine int)
 		switch attr.Name.Local {
 		case "type":
 			appendType := strings.TrimSpace(attr.Value)
-			if appendType != "" && appendType != "PLUGIN" {
-				return appendElem, fmt.Errorf("append type must be empty or 'PLUGIN', got '%s' at line %d", appendType, elementLine)
+			if appendType != "" && appendType != "PLUGIN" && appendType != "LOOKUP" {
+				return appendElem, fmt.Errorf("append type must be empty, 'PLUGIN' or 'LOOKUP', got '%s' at line %d", appendType, elementLine)
 			}
 			appendElem.Type = appendType
 		case "field":
@@ -480,9 +641,28 @@ func parseAppend(element xml.StartElement, decoder *XMLDecoder, elementLine int)
 				return appendElem, fmt.Errorf("append field cannot be empty at line %d", elementLine)
 			}
 			appendElem.FieldName = field
+		case "cache_ttl", "local_cache":
+			if err := parsePluginCacheAttr(attr, &appendElem.PluginCache, "append", elementLine); err != nil {
+				return appendElem, err
+			}
+		case "lookup":
+			appendElem.Lookup = strings.TrimSpace(attr.Value)
+		case "columns":
+			appendElem.Columns = attr.Value
 		}
 	}
 
+	if appendElem.Type != "LOOKUP" && (appendElem.Lookup != "" || appendElem.Columns != "") {
+		return appendElem, fmt.Errorf("append lookup and columns are only supported with type 'LOOKUP' at line %d", elementLine)
+	}
+
+	if appendElem.Type != "PLUGIN" && (appendElem.CacheTTL != "" || appendElem.LocalCache) {
+		return appendElem, fmt.Errorf("append cache_ttl and local_cache are only supported with type 'PLUGIN' at line %d", elementLine)
+	}
+	if err := validatePluginCache(&appendElem.PluginCache, "append", elementLine); err != nil {
+		return appendElem, err
+	}
+
 	// Parse content
 	for {
 		token, err := decoder.Token()
@@ -496,6 +676,9 @@ func parseAppend(element xml.StartElement, decoder *XMLDecoder, elementLine int)
 			if appendElem.Type == "PLUGIN" && value == "" {
 				return appendElem, fmt.Errorf("append plugin value cannot be empty at line %d", elementLine)
 			}
+			if appendElem.Type == "LOOKUP" && value == "" {
+				return appendElem, fmt.Errorf("append lookup key cannot be empty at line %d", elementLine)
+			}
 			appendElem.Value = value
 		case xml.EndElement:
 			if t.Name.Local == "append" {
@@ -524,6 +707,13 @@ func parseAppend(element xml.StartElement, decoder *XMLDecoder, elementLine int)
 					appendElem.PluginArgs = args
 				}
 
+				if appendElem.Type == "LOOKUP" {
+					if err := validateLookupAppend(&appendElem); err != nil {
+						return appendElem, fmt.Errorf("%v at line %d", err, elementLine)
+					}
+					appendElem.ColumnList = parseLookupColumns(appendElem.Columns)
+				}
+
 				return appendElem, nil
 			}
 		}
@@ -533,6 +723,19 @@ func parseAppend(element xml.StartElement, decoder *XMLDecoder, elementLine int)
 func parsePlugin(element xml.StartElement, decoder *XMLDecoder, elementLine int) (Plugin, error) {
 	var pluginElem Plugin
 
+	// Parse attributes with validation
+	for _, attr := range element.Attr {
+		switch attr.Name.Local {
+		case "cache_ttl", "local_cache":
+			if err := parsePluginCacheAttr(attr, &pluginElem.PluginCache, "plugin", elementLine); err != nil {
+				return pluginElem, err
+			}
+		}
+	}
+	if err := validatePluginCache(&pluginElem.PluginCache, "plugin", elementLine); err != nil {
+		return pluginElem, err
+	}
+
 	// Parse content
 	for {
 		token, err := decoder.Token()
@@ -573,6 +776,38 @@ func parsePlugin(element xml.StartElement, decoder *XMLDecoder, elementLine int)
 	}
 }
 
+// parsePluginCacheAttr parses the cache_ttl and local_cache attributes of plugin calls
+func parsePluginCacheAttr(attr xml.Attr, cache *PluginCache, elementName string, elementLine int) error {
+	switch attr.Name.Local {
+	case "cache_ttl":
+		cacheTTL := strings.TrimSpace(attr.Value)
+		if cacheTTL == "" {
+			return fmt.Errorf("%s cache_ttl cannot be empty at line %d", elementName, elementLine)
+		}
+		ttl, err := common.ParseDurationToSecondsInt(cacheTTL)
+		if err != nil {
+			return fmt.Errorf("%s cache_ttl '%s' is invalid at line %d: %v", elementName, cacheTTL, elementLine, err)
+		}
+		cache.CacheTTL = cacheTTL
+		cache.CacheTTLInt = ttl
+	case "local_cache":
+		localCache := strings.TrimSpace(attr.Value)
+		if localCache != "" && localCache != "true" && localCache != "false" {
+			return fmt.Errorf("%s local_cache must be 'true' or 'false', got '%s' at line %d", elementName, localCache, elementLine)
+		}
+		cache.LocalCache = localCache == "true"
+	}
+	return nil
+}
+
+// validatePluginCache checks that local_cache is only used together with cache_ttl
+func validatePluginCache(cache *PluginCache, elementName string, elementLine int) error {
+	if cache.LocalCache && cache.CacheTTL == "" {
+		return fmt.Errorf("%s local_cache requires cache_ttl at line %d", elementName, elementLine)
+	}
+	return nil
+}
+
 func parseDel(element xml.StartElement, decoder *XMLDecoder, elementLine int) ([][]string, error) {
 	var delFields [][]string
 
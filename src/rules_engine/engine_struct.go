@@ -60,6 +60,7 @@ const (
 	T_Append                        // Append = 3
 	T_Del                           // Del = 4
 	T_Plugin                        // Plugin = 5
+	T_Sequence                      // Sequence = 6
 )
 
 type EngineOperator struct {
@@ -71,6 +72,8 @@ type Rule struct {
 	ID   string `xml:"id,attr"`
 	Name string `xml:"name,attr"`
 
+	Suppression
+
 	Queue *[]EngineOperator
 
 	ChecklistMap map[int]Checklist
@@ -79,6 +82,17 @@ type Rule struct {
 	AppendsMap   map[int]Append
 	PluginMap    map[int]Plugin
 	DelMap       map[int][][]string
+	SequenceMap  map[int]Sequence
+}
+
+// Suppression deduplicates the alerts of a rule by its suppress_by fields, see engine_suppress.go
+type Suppression struct {
+	SuppressBy         string     `xml:"suppress_by,attr"` // Fields identifying duplicate alerts
+	SuppressByFields   []string   // Parsed suppress_by fields
+	SuppressByList     [][]string // Parsed field paths, in suppress_by order
+	SuppressWindow     string     `xml:"suppress_window,attr"` // How long duplicates are suppressed
+	SuppressWindowInt  int        // Parsed suppress_window in seconds
+	SuppressLocalCache bool       `xml:"suppress_local_cache,attr"` // Keep suppressions on this node instead of Redis
 }
 
 type Ruleset struct {
@@ -105,9 +119,20 @@ type Ruleset struct {
 
 	Cache            *ristretto.Cache[string, int]
 	CacheForClassify *ristretto.Cache[string, map[string]bool]
-	// only for classify local cache
+	// only for DISTINCT and AVG/MAX/MIN local cache, entries are updated in place under CacheMu
+	CacheForDistinct  *ristretto.Cache[string, *common.HyperLogLog]
+	CacheForAggregate *ristretto.Cache[string, *common.AggregateStats]
+	// only for classify, distinct and aggregate local cache
 	CacheMu sync.RWMutex
 
+	// only for sequence local cache
+	CacheForSequence *ristretto.Cache[string, SequenceState]
+	SequenceMu       sync.Mutex
+
+	// only for rule suppression local cache, see engine_suppress.go
+	suppressions map[string]*localSuppression
+	SuppressMu   sync.Mutex
+
 	// Regex result cache for this ruleset instance
 	RegexResultCache *RegexResultCache
 
@@ -139,10 +164,12 @@ type CheckNodes struct {
 	ID        string                              `xml:"id,attr"`
 	Type      string                              `xml:"type,attr"`
 	CheckFunc func(string, string) (bool, string) // function pointer for check logic
-	Field     string                              `xml:"field,attr"`
-	FieldList []string                            // parsed field path
-	Logic     string                              `xml:"logic,attr"`
-	Delimiter string                              `xml:"delimiter,attr"`
+	// TypedCheckFunc is set for typed check types, it receives the original field value instead of its string form
+	TypedCheckFunc func(interface{}, string) (bool, string)
+	Field          string   `xml:"field,attr"`
+	FieldList      []string // parsed field path
+	Logic          string   `xml:"logic,attr"`
+	Delimiter      string   `xml:"delimiter,attr"`
 
 	DelimiterFieldList []string
 	Value              string `xml:",chardata"`
@@ -171,22 +198,94 @@ type Threshold struct {
 	Range          string              `xml:"range,attr"` // Time range for aggregation
 	RangeInt       int                 // Parsed range in seconds
 	LocalCache     bool                `xml:"local_cache,attr"` // Whether to use local cache
-	CountType      string              `xml:"count_type,attr"`  // Type of counting (SUM/CLASSIFY)
+	CountType      string              `xml:"count_type,attr"`  // Type of counting (SUM/CLASSIFY/DISTINCT/AVG/MAX/MIN)
 	CountField     string              `xml:"count_field,attr"` // Field to count
 	CountFieldList []string            // Parsed count field path
 	Value          int                 `xml:",chardata"` // Threshold value
 	GroupByID      string              // Unique identifier for grouping
 }
 
+// thresholdCountTypes lists the supported threshold count types, "" is the default count mode
+var thresholdCountTypes = map[string]bool{
+	"": true, "SUM": true, "CLASSIFY": true, "DISTINCT": true, "AVG": true, "MAX": true, "MIN": true,
+}
+
+// thresholdCountTypesDesc is used in count_type error messages
+const thresholdCountTypesDesc = "empty (default count mode), 'SUM', 'CLASSIFY', 'DISTINCT', 'AVG', 'MAX' or 'MIN'"
+
+// thresholdNeedsCountField reports whether countType aggregates count_field
+func thresholdNeedsCountField(countType string) bool {
+	return countType != ""
+}
+
+// newDistinctCache creates the local cache of DISTINCT thresholds, cost is the HyperLogLog size in bytes
+func newDistinctCache() (*ristretto.Cache[string, *common.HyperLogLog], error) {
+	cache, err := ristretto.NewCache(&ristretto.Config[string, *common.HyperLogLog]{
+		NumCounters: 1_000_000,         // number of keys to track frequency of.
+		MaxCost:     1024 * 1024 * 256, // maximum cost of cache (bytes of registers).
+		BufferItems: 32,                // number of keys per Get buffer.
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create distinct cache: %w", err)
+	}
+	return cache, nil
+}
+
+// newAggregateCache creates the local cache of AVG/MAX/MIN thresholds
+func newAggregateCache() (*ristretto.Cache[string, *common.AggregateStats], error) {
+	cache, err := ristretto.NewCache(&ristretto.Config[string, *common.AggregateStats]{
+		NumCounters: 10_000_000,       // number of keys to track frequency of.
+		MaxCost:     1024 * 1024 * 64, // maximum cost of cache.
+		BufferItems: 32,               // number of keys per Get buffer.
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aggregate cache: %w", err)
+	}
+	return cache, nil
+}
+
+// Sequence defines an ordered list of event patterns that must be observed for the
+// same group_by key within a time window. The sequence passes only on the event
+// that completes the last step.
+type Sequence struct {
+	GroupBy     string              `xml:"group_by,attr"` // Fields to group by
+	GroupByList map[string][]string // Parsed group by fields
+	Range       string              `xml:"range,attr"` // Time window for the whole sequence
+	RangeInt    int                 // Parsed range in seconds
+	LocalCache  bool                `xml:"local_cache,attr"` // Whether to use local cache
+	Steps       []SequenceStep      `xml:"step"`
+	GroupByID   string              // Unique identifier for grouping
+}
+
+// SequenceStep is a single stage of a sequence. It is evaluated like a checklist
+// and must match Count events before the sequence advances to the next step.
+type SequenceStep struct {
+	ID    string `xml:"id,attr"`
+	Count int    `xml:"count,attr"` // Number of matching events required, default 1
+	Checklist
+}
+
+// SequenceState is the per group_by progress of a sequence.
+type SequenceState struct {
+	Step  int // Index of the step currently waiting for events
+	Count int // Matching events already seen for the current step
+}
+
 // Append defines additional fields to append after rule matching.
 // It supports both static values and plugin-based dynamic values.
 type Append struct {
-	Type      string `xml:"type,attr"`  // Type of append (PLUGIN)
+	Type      string `xml:"type,attr"`  // Type of append (PLUGIN, LOOKUP)
 	FieldName string `xml:"field,attr"` // Name of field to append
 	Value     string `xml:",chardata"`  // Value to append
 
 	Plugin     *plugin.Plugin // Plugin instance if type is PLUGIN
 	PluginArgs []*PluginArg   // Arguments for plugin execution
+
+	PluginCache
+
+	Lookup     string   `xml:"lookup,attr"`  // Lookup name if type is LOOKUP, Value references the key field
+	Columns    string   `xml:"columns,attr"` // Columns of the matched row to append, empty appends the whole row
+	ColumnList []string // Parsed columns
 }
 
 // Plugin represents a plugin configuration with its execution parameters
@@ -194,6 +293,15 @@ type Plugin struct {
 	Value      string         `xml:",chardata"` // Plugin value/configuration
 	Plugin     *plugin.Plugin // Plugin instance
 	PluginArgs []*PluginArg   // Arguments for plugin execution
+
+	PluginCache
+}
+
+// PluginCache caches plugin results by their resolved arguments, see plugin/cache.go
+type PluginCache struct {
+	CacheTTL    string `xml:"cache_ttl,attr"` // How long a result is reused, empty disables the cache
+	CacheTTLInt int    // Parsed cache_ttl in seconds
+	LocalCache  bool   `xml:"local_cache,attr"` // Only cache on this node, skip the shared Redis tier
 }
 
 // ValidationError represents a validation error with line number
@@ -512,6 +620,13 @@ func validateRule(rule *Rule, xmlContent string, ruleIndex int, result *Validati
 		validateThreshold(&threshold, xmlContent, ruleID, ruleIndex, result)
 	}
 
+	// Validate sequences in SequenceMap
+	sequenceCount := 0
+	for _, sequence := range rule.SequenceMap {
+		validateSequence(&sequence, xmlContent, ruleID, ruleIndex, sequenceCount, result)
+		sequenceCount++
+	}
+
 	// Validate appends in AppendsMap
 	appendCount := 0
 	for _, appendElem := range rule.AppendsMap {
@@ -553,6 +668,8 @@ func validateStandaloneCheck(checkNode *CheckNodes, xmlContent, ruleID string, r
 			"PLUGIN", "END", "START", "NEND", "NSTART", "INCL", "NI",
 			"NCS_END", "NCS_START", "NCS_NEND", "NCS_NSTART", "NCS_INCL", "NCS_NI",
 			"MT", "LT", "REGEX", "ISNULL", "NOTNULL", "EQU", "NEQ", "NCS_EQU", "NCS_NEQ",
+			"GTE", "LTE", "BETWEEN", "IN", "NOT_IN", "LEN_MT", "LEN_LT", "CIDR", "TYPE",
+			"IN_LOOKUP", "NOT_IN_LOOKUP",
 		}
 
 		isValid := false
@@ -567,7 +684,7 @@ func validateStandaloneCheck(checkNode *CheckNodes, xmlContent, ruleID string, r
 			result.IsValid = false
 			result.Errors = append(result.Errors, ValidationError{
 				Line:    checkLine,
-				Message: "Check type must be one of: PLUGIN, END, START, NEND, NSTART, INCL, NI, NCS_END, NCS_START, NCS_NEND, NCS_NSTART, NCS_INCL, NCS_NI, MT, LT, REGEX, ISNULL, NOTNULL, EQU, NEQ, NCS_EQU, NCS_NEQ",
+				Message: "Check type must be one of: PLUGIN, END, START, NEND, NSTART, INCL, NI, NCS_END, NCS_START, NCS_NEND, NCS_NSTART, NCS_INCL, NCS_NI, MT, LT, REGEX, ISNULL, NOTNULL, EQU, NEQ, NCS_EQU, NCS_NEQ, GTE, LTE, BETWEEN, IN, NOT_IN, LEN_MT, LEN_LT, CIDR, TYPE, IN_LOOKUP, NOT_IN_LOOKUP",
 				Detail:  fmt.Sprintf("Rule ID: %s, Current value: '%s'", ruleID, checkNode.Type),
 			})
 		}
@@ -622,6 +739,10 @@ func validateStandaloneCheck(checkNode *CheckNodes, xmlContent, ruleID string, r
 		}
 	}
 
+	if isTypedCheckType(checkNode.Type) {
+		validateTypedCheckNode(checkNode, checkLine, ruleID, result)
+	}
+
 	// Validate logic and delimiter combination
 	if checkNode.Logic != "" && checkNode.Delimiter == "" {
 		result.IsValid = false
@@ -631,7 +752,7 @@ func validateStandaloneCheck(checkNode *CheckNodes, xmlContent, ruleID string, r
 			Detail:  fmt.Sprintf("Rule ID: %s", ruleID),
 		})
 	}
-	if checkNode.Logic == "" && checkNode.Delimiter != "" {
+	if checkNode.Logic == "" && checkNode.Delimiter != "" && !typedListCheckTypes[checkNode.Type] {
 		result.IsValid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Line:    checkLine,
@@ -674,6 +795,8 @@ func validateChecklist(checklist *Checklist, xmlContent, ruleID string, ruleInde
 				"PLUGIN", "END", "START", "NEND", "NSTART", "INCL", "NI",
 				"NCS_END", "NCS_START", "NCS_NEND", "NCS_NSTART", "NCS_INCL", "NCS_NI",
 				"MT", "LT", "REGEX", "ISNULL", "NOTNULL", "EQU", "NEQ", "NCS_EQU", "NCS_NEQ",
+				"GTE", "LTE", "BETWEEN", "IN", "NOT_IN", "LEN_MT", "LEN_LT", "CIDR", "TYPE",
+				"IN_LOOKUP", "NOT_IN_LOOKUP",
 			}
 
 			isValid := false
@@ -688,7 +811,7 @@ func validateChecklist(checklist *Checklist, xmlContent, ruleID string, ruleInde
 				result.IsValid = false
 				result.Errors = append(result.Errors, ValidationError{
 					Line:    nodeLine,
-					Message: "Check node type must be one of: PLUGIN, END, START, NEND, NSTART, INCL, NI, NCS_END, NCS_START, NCS_NEND, NCS_NSTART, NCS_INCL, NCS_NI, MT, LT, REGEX, ISNULL, NOTNULL, EQU, NEQ, NCS_EQU, NCS_NEQ",
+					Message: "Check node type must be one of: PLUGIN, END, START, NEND, NSTART, INCL, NI, NCS_END, NCS_START, NCS_NEND, NCS_NSTART, NCS_INCL, NCS_NI, MT, LT, REGEX, ISNULL, NOTNULL, EQU, NEQ, NCS_EQU, NCS_NEQ, GTE, LTE, BETWEEN, IN, NOT_IN, LEN_MT, LEN_LT, CIDR, TYPE, IN_LOOKUP, NOT_IN_LOOKUP",
 					Detail:  fmt.Sprintf("Rule ID: %s, Current value: '%s'", ruleID, node.Type),
 				})
 			}
@@ -763,6 +886,10 @@ func validateChecklist(checklist *Checklist, xmlContent, ruleID string, ruleInde
 				validateCheckNodePluginCall(nodeValue, nodeLine, ruleID, result)
 			}
 		}
+
+		if isTypedCheckType(node.Type) {
+			validateTypedCheckNode(&node, nodeLine, ruleID, result)
+		}
 	}
 }
 
@@ -804,23 +931,23 @@ func validateThreshold(threshold *Threshold, xmlContent, ruleID string, ruleInde
 		})
 	}
 
-	// Validate count_type - must be empty (default count mode), "SUM", or "CLASSIFY"
-	if threshold.CountType != "" && threshold.CountType != "SUM" && threshold.CountType != "CLASSIFY" {
+	// Validate count_type
+	if !thresholdCountTypes[threshold.CountType] {
 		result.IsValid = false
 		result.Errors = append(result.Errors, ValidationError{
 			Line:    thresholdLine,
-			Message: "Threshold count_type must be empty (default count mode), 'SUM', or 'CLASSIFY'",
+			Message: "Threshold count_type must be " + thresholdCountTypesDesc,
 			Detail:  fmt.Sprintf("Rule ID: %s, Current value: '%s'", ruleID, threshold.CountType),
 		})
 	}
 
-	// Validate count_field - only required when count_type is "SUM" or "CLASSIFY"
-	if threshold.CountType == "SUM" || threshold.CountType == "CLASSIFY" {
+	// Validate count_field - required by every count_type except the default count mode
+	if thresholdNeedsCountField(threshold.CountType) {
 		if threshold.CountField == "" || strings.TrimSpace(threshold.CountField) == "" {
 			result.IsValid = false
 			result.Errors = append(result.Errors, ValidationError{
 				Line:    thresholdLine,
-				Message: "Threshold count_field cannot be empty when count_type is 'SUM' or 'CLASSIFY'",
+				Message: fmt.Sprintf("Threshold count_field cannot be empty when count_type is '%s'", threshold.CountType),
 				Detail:  fmt.Sprintf("Rule ID: %s, count_type: '%s'", ruleID, threshold.CountType),
 			})
 		}
@@ -836,6 +963,55 @@ func validateThreshold(threshold *Threshold, xmlContent, ruleID string, ruleInde
 	}
 }
 
+// validateSequence validates sequence elements
+func validateSequence(sequence *Sequence, xmlContent, ruleID string, ruleIndex, sequenceIndex int, result *ValidationResult) {
+	sequenceLine := findElementInRule(xmlContent, ruleID, "<sequence", ruleIndex, sequenceIndex)
+
+	if strings.TrimSpace(sequence.GroupBy) == "" {
+		result.IsValid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Line:    sequenceLine,
+			Message: "Sequence group_by cannot be empty",
+			Detail:  fmt.Sprintf("Rule ID: %s", ruleID),
+		})
+	}
+
+	if strings.TrimSpace(sequence.Range) == "" {
+		result.IsValid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Line:    sequenceLine,
+			Message: "Sequence range cannot be empty",
+			Detail:  fmt.Sprintf("Rule ID: %s", ruleID),
+		})
+	} else if _, err := common.ParseDurationToSecondsInt(sequence.Range); err != nil {
+		result.IsValid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Line:    sequenceLine,
+			Message: "Sequence range is not a valid duration",
+			Detail:  fmt.Sprintf("Rule ID: %s, Current value: '%s'", ruleID, sequence.Range),
+		})
+	}
+
+	if len(sequence.Steps) == 0 {
+		result.IsValid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Line:    sequenceLine,
+			Message: "Sequence must have at least one step",
+			Detail:  fmt.Sprintf("Rule ID: %s", ruleID),
+		})
+	} else if len(sequence.Steps) == 1 && sequence.Steps[0].Count <= 1 {
+		result.Warnings = append(result.Warnings, ValidationWarning{
+			Line:    sequenceLine,
+			Message: "Sequence with a single step matching once behaves like a checklist",
+			Detail:  fmt.Sprintf("Rule ID: %s, consider using checklist or threshold instead", ruleID),
+		})
+	}
+
+	for i := range sequence.Steps {
+		validateChecklist(&sequence.Steps[i].Checklist, xmlContent, ruleID, ruleIndex, result)
+	}
+}
+
 // validateAppend validates append elements
 func validateAppend(appendElem *Append, xmlContent, ruleID string, ruleIndex, appendIndex int, result *ValidationResult) {
 	appendLine := findElementInRule(xmlContent, ruleID, "<append", ruleIndex, appendIndex)
@@ -849,6 +1025,17 @@ func validateAppend(appendElem *Append, xmlContent, ruleID string, ruleIndex, ap
 		})
 	}
 
+	if appendElem.Type == "LOOKUP" {
+		if err := validateLookupAppend(appendElem); err != nil {
+			result.IsValid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Line:    appendLine,
+				Message: err.Error(),
+				Detail:  fmt.Sprintf("Rule ID: %s", ruleID),
+			})
+		}
+	}
+
 	if appendElem.Type == "PLUGIN" {
 		value := strings.TrimSpace(appendElem.Value)
 		if value == "" {
@@ -899,6 +1086,7 @@ func validateAppend(appendElem *Append, xmlContent, ruleID string, ruleIndex, ap
 
 			// Validate plugin parameters
 			validatePluginParameters(pluginInstance, args, value, appendLine, ruleID, result)
+			validatePluginCacheUsage(&appendElem.PluginCache, args, appendLine, ruleID, result)
 
 			// Add info about supported plugin types for user awareness
 			if pluginInstance.ReturnType == "bool" {
@@ -971,6 +1159,14 @@ func validatePlugin(pluginElem *Plugin, xmlContent, ruleID string, ruleIndex, pl
 
 		// Validate plugin parameters
 		validatePluginParameters(pluginInstance, args, value, pluginLine, ruleID, result)
+		validatePluginCacheUsage(&pluginElem.PluginCache, args, pluginLine, ruleID, result)
+		if pluginElem.CacheTTL != "" {
+			result.Warnings = append(result.Warnings, ValidationWarning{
+				Line:    pluginLine,
+				Message: "Plugin with cache_ttl is not called again for the same arguments until the cache expires",
+				Detail:  fmt.Sprintf("Rule ID: %s, cache_ttl: %s", ruleID, pluginElem.CacheTTL),
+			})
+		}
 
 		// Add info about supported plugin types for user awareness
 		if pluginInstance.ReturnType == "bool" {
@@ -989,6 +1185,23 @@ func validatePlugin(pluginElem *Plugin, xmlContent, ruleID string, ruleIndex, pl
 	}
 }
 
+// validatePluginCacheUsage warns about cached plugin calls that can never hit the cache
+func validatePluginCacheUsage(cache *PluginCache, args []*PluginArg, line int, ruleID string, result *ValidationResult) {
+	if cache.CacheTTL == "" {
+		return
+	}
+	for _, arg := range args {
+		if arg.Type == 2 {
+			result.Warnings = append(result.Warnings, ValidationWarning{
+				Line:    line,
+				Message: "cache_ttl has no effect when the whole message (_$ORIDATA) is a plugin argument",
+				Detail:  fmt.Sprintf("Rule ID: %s, every message produces a different cache key", ruleID),
+			})
+			return
+		}
+	}
+}
+
 // validateCheckNodePluginCall validates plugin function call for checknode (must return bool)
 func validateCheckNodePluginCall(pluginCall string, line int, ruleID string, result *ValidationResult) {
 	// Parse the plugin function call
@@ -1462,6 +1675,21 @@ func (r *Ruleset) cleanup() {
 		r.CacheForClassify = nil
 	}
 
+	if r.CacheForSequence != nil {
+		r.CacheForSequence.Close()
+		r.CacheForSequence = nil
+	}
+
+	if r.CacheForDistinct != nil {
+		r.CacheForDistinct.Close()
+		r.CacheForDistinct = nil
+	}
+
+	if r.CacheForAggregate != nil {
+		r.CacheForAggregate.Close()
+		r.CacheForAggregate = nil
+	}
+
 	// Clear regex result cache
 	if r.RegexResultCache != nil {
 		r.RegexResultCache.Clear()
@@ -1526,21 +1754,31 @@ func NewFromExisting(existing *Ruleset, newProjectNodeSequence string) (*Ruleset
 	// Check if any rules have threshold operations that require cache initialization
 	var needsCache bool
 	var needsClassifyCache bool
+	var needsSequenceCache bool
+	var needsDistinctCache bool
+	var needsAggregateCache bool
 
 	for _, rule := range newRuleset.Rules {
+		for _, sequence := range rule.SequenceMap {
+			if sequence.LocalCache {
+				needsSequenceCache = true
+				break
+			}
+		}
 		if len(rule.ThresholdMap) > 0 {
 			needsCache = true
-			// Check if any threshold uses CLASSIFY mode
+			// Check which threshold modes need their own cache
 			for _, threshold := range rule.ThresholdMap {
-				if threshold.CountType == "CLASSIFY" {
+				switch threshold.CountType {
+				case "CLASSIFY":
 					needsClassifyCache = true
-					break
+				case "DISTINCT":
+					needsDistinctCache = needsDistinctCache || threshold.LocalCache
+				case "AVG", "MAX", "MIN":
+					needsAggregateCache = needsAggregateCache || threshold.LocalCache
 				}
 			}
 		}
-		if needsCache && needsClassifyCache {
-			break
-		}
 	}
 
 	// Initialize caches if needed
@@ -1568,6 +1806,34 @@ func NewFromExisting(existing *Ruleset, newProjectNodeSequence string) (*Ruleset
 		}
 	}
 
+	if needsSequenceCache {
+		var err error
+		newRuleset.CacheForSequence, err = ristretto.NewCache(&ristretto.Config[string, SequenceState]{
+			NumCounters: 10_000_000,       // number of keys to track frequency of.
+			MaxCost:     1024 * 1024 * 64, // maximum cost of cache.
+			BufferItems: 32,               // number of keys per Get buffer.
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sequence cache: %w", err)
+		}
+	}
+
+	if needsDistinctCache {
+		var err error
+		newRuleset.CacheForDistinct, err = newDistinctCache()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if needsAggregateCache {
+		var err error
+		newRuleset.CacheForAggregate, err = newAggregateCache()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize regex result cache
 	newRuleset.RegexResultCache = NewRegexResultCache(1000) // Default capacity: 1000 entries
 
@@ -1816,8 +2082,8 @@ func RulesetBuild(ruleset *Ruleset) error {
 			appendType := strings.TrimSpace(appendNode.Type)
 			appendValue := strings.TrimSpace(appendNode.Value)
 
-			if appendType != "" && appendType != "PLUGIN" {
-				return errors.New("append type must be empty or 'PLUGIN': " + rule.ID)
+			if appendType != "" && appendType != "PLUGIN" && appendType != "LOOKUP" {
+				return errors.New("append type must be empty, 'PLUGIN' or 'LOOKUP': " + rule.ID)
 			}
 
 			if appendNode.FieldName == "" {
@@ -1841,6 +2107,21 @@ func RulesetBuild(ruleset *Ruleset) error {
 				}
 
 				appendNode.PluginArgs = args
+
+				if appendNode.CacheTTL != "" {
+					appendNode.CacheTTLInt, err = common.ParseDurationToSecondsInt(appendNode.CacheTTL)
+					if err != nil {
+						return errors.New("append parse cache_ttl err: " + err.Error() + ", rule id: " + rule.ID)
+					}
+				}
+			}
+
+			if appendNode.Type == "LOOKUP" {
+				appendNode.Value = appendValue
+				if err := validateLookupAppend(&appendNode); err != nil {
+					return errors.New(err.Error() + ", rule id: " + rule.ID)
+				}
+				appendNode.ColumnList = parseLookupColumns(appendNode.Columns)
 			}
 			// Update the append node in the map
 			rule.AppendsMap[id] = appendNode
@@ -1870,6 +2151,13 @@ func RulesetBuild(ruleset *Ruleset) error {
 			}
 
 			pluginNode.PluginArgs = args
+
+			if pluginNode.CacheTTL != "" {
+				pluginNode.CacheTTLInt, err = common.ParseDurationToSecondsInt(pluginNode.CacheTTL)
+				if err != nil {
+					return errors.New("plugin parse cache_ttl err: " + err.Error() + ", rule id: " + rule.ID)
+				}
+			}
 			// Update the plugin node in the map
 			rule.PluginMap[id] = pluginNode
 		}
@@ -1891,13 +2179,13 @@ func RulesetBuild(ruleset *Ruleset) error {
 				return errors.New("threshold value must be a positive integer (greater than 0): " + rule.ID)
 			}
 
-			if !(threshold.CountType == "" || threshold.CountType == "SUM" || threshold.CountType == "CLASSIFY") {
-				return errors.New("threshold count_type must be empty (default count mode), 'SUM', or 'CLASSIFY': " + rule.ID)
+			if !thresholdCountTypes[threshold.CountType] {
+				return errors.New("threshold count_type must be " + thresholdCountTypesDesc + ": " + rule.ID)
 			}
 
-			if threshold.CountType == "SUM" || threshold.CountType == "CLASSIFY" {
+			if thresholdNeedsCountField(threshold.CountType) {
 				if threshold.CountField == "" {
-					return errors.New("threshold count_field cannot be empty when count_type is 'SUM' or 'CLASSIFY': " + rule.ID)
+					return errors.New("threshold count_field cannot be empty when count_type is '" + threshold.CountType + "': " + rule.ID)
 				} else {
 					// Parse threshold count field path
 					threshold.CountFieldList = common.StringToList(strings.TrimSpace(threshold.CountField))
@@ -1939,6 +2227,20 @@ func RulesetBuild(ruleset *Ruleset) error {
 				}
 			}
 
+			if threshold.CountType == "DISTINCT" && threshold.LocalCache && ruleset.CacheForDistinct == nil {
+				ruleset.CacheForDistinct, err = newDistinctCache()
+				if err != nil {
+					return err
+				}
+			}
+
+			if (threshold.CountType == "AVG" || threshold.CountType == "MAX" || threshold.CountType == "MIN") && threshold.LocalCache && ruleset.CacheForAggregate == nil {
+				ruleset.CacheForAggregate, err = newAggregateCache()
+				if err != nil {
+					return err
+				}
+			}
+
 			// Parse threshold group by fields
 			thresholdGroupBYList := strings.Split(strings.TrimSpace(threshold.group_by), ",")
 			threshold.GroupByList = make(map[string][]string, len(thresholdGroupBYList))
@@ -1951,6 +2253,90 @@ func RulesetBuild(ruleset *Ruleset) error {
 			rule.ThresholdMap[id] = threshold
 		}
 
+		if rule.SuppressBy != "" {
+			if !ruleset.IsDetection {
+				return errors.New("rule suppression is only supported in detection rulesets: " + rule.ID)
+			}
+			rule.SuppressWindowInt, err = common.ParseDurationToSecondsInt(rule.SuppressWindow)
+			if err != nil {
+				return errors.New("rule parse suppress_window err: " + err.Error() + ", rule id: " + rule.ID)
+			}
+
+			// Parse suppress_by fields, the order is kept so the suppression key is stable
+			rule.SuppressByFields = rule.SuppressByFields[:0]
+			rule.SuppressByList = rule.SuppressByList[:0]
+			for _, field := range strings.Split(rule.SuppressBy, ",") {
+				field = strings.TrimSpace(field)
+				if field == "" {
+					continue
+				}
+				rule.SuppressByFields = append(rule.SuppressByFields, field)
+				rule.SuppressByList = append(rule.SuppressByList, common.StringToList(field))
+			}
+			if len(rule.SuppressByFields) == 0 {
+				return errors.New("rule suppress_by cannot be empty: " + rule.ID)
+			}
+		}
+
+		// Process sequences in SequenceMap
+		for id, sequence := range rule.SequenceMap {
+			if strings.TrimSpace(sequence.GroupBy) == "" {
+				return errors.New("sequence group_by cannot be empty: " + rule.ID)
+			}
+			if strings.TrimSpace(sequence.Range) == "" {
+				return errors.New("sequence range cannot be empty: " + rule.ID)
+			}
+			if len(sequence.Steps) == 0 {
+				return errors.New("sequence must have at least one step: " + rule.ID)
+			}
+
+			sequence.RangeInt, err = common.ParseDurationToSecondsInt(sequence.Range)
+			if err != nil {
+				return errors.New("sequence parse range err: " + err.Error() + ", rule id: " + rule.ID)
+			}
+
+			// Each sequence keeps its own state, isolate by operator ID as well
+			sequence.GroupByID = ruleset.RulesetID + rule.ID + strconv.Itoa(id)
+
+			for j := range sequence.Steps {
+				step := &sequence.Steps[j]
+				if len(step.CheckNodes) == 0 {
+					return errors.New("sequence step must have at least one check node: " + rule.ID)
+				}
+				if step.Count <= 0 {
+					step.Count = 1
+				}
+
+				for k := range step.CheckNodes {
+					err := processCheckNode(&step.CheckNodes[k], &step.Checklist, rule.ID)
+					if err != nil {
+						return err
+					}
+				}
+				step.CheckNodes = sortCheckNodes(step.CheckNodes)
+			}
+
+			if sequence.LocalCache && ruleset.CacheForSequence == nil {
+				ruleset.CacheForSequence, err = ristretto.NewCache(&ristretto.Config[string, SequenceState]{
+					NumCounters: 10_000_000,       // number of keys to track frequency of.
+					MaxCost:     1024 * 1024 * 64, // maximum cost of cache.
+					BufferItems: 32,               // number of keys per Get buffer.
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create sequence cache: %w", err)
+				}
+			}
+
+			// Parse sequence group by fields
+			sequenceGroupByList := strings.Split(strings.TrimSpace(sequence.GroupBy), ",")
+			sequence.GroupByList = make(map[string][]string, len(sequenceGroupByList))
+			for i := range sequenceGroupByList {
+				sequence.GroupByList[sequenceGroupByList[i]] = common.StringToList(sequenceGroupByList[i])
+			}
+			// Update the sequence in the map
+			rule.SequenceMap[id] = sequence
+		}
+
 		// Process del operations in DelMap (no additional processing needed as DelMap already contains parsed field paths)
 	}
 
@@ -2045,6 +2431,12 @@ func processCheckNode(node *CheckNodes, checklist *Checklist, ruleID string) err
 		node.CheckFunc = NCS_EQU
 	case "NCS_NEQ":
 		node.CheckFunc = NCS_NEQ
+	case "GTE", "LTE", "BETWEEN", "IN", "NOT_IN", "LEN_MT", "LEN_LT", "CIDR", "TYPE", "IN_LOOKUP", "NOT_IN_LOOKUP":
+		typedFunc, err := buildTypedCheckFunc(node)
+		if err != nil {
+			return errors.New(err.Error() + ", rule id: " + ruleID)
+		}
+		node.TypedCheckFunc = typedFunc
 	default:
 		return errors.New("unknown check node type: " + node.Type + ", rule id: " + ruleID)
 	}
@@ -2058,7 +2450,8 @@ func processCheckNode(node *CheckNodes, checklist *Checklist, ruleID string) err
 		}
 	}
 
-	if node.Logic != "" || node.Delimiter != "" {
+	// IN, NOT_IN and BETWEEN use delimiter for their own value list
+	if (node.Logic != "" || node.Delimiter != "") && !typedListCheckTypes[node.Type] {
 		if node.Logic == "" {
 			return errors.New("logic cannot be empty: " + ruleID)
 		}
@@ -0,0 +1,443 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rule suppression: the first alert of a suppress_by key passes, further alerts of the key within
+// suppress_window are suppressed and counted. When the window ends with suppressed alerts, the last
+// of them is emitted as a rollup carrying SuppressedCountFieldName and a new window starts, a window
+// without suppressed alerts is dropped. Suppressions are kept in Redis and shared by the cluster, or
+// on this node with suppress_local_cache="true".
+
+// SuppressedCountFieldName holds the number of alerts suppressed since SuppressedSinceFieldName
+const SuppressedCountFieldName = "_hub_suppressed_count"
+
+// SuppressedSinceFieldName holds the start of the window the suppressed alerts were counted in
+const SuppressedSinceFieldName = "_hub_suppressed_since"
+
+const (
+	suppressRedisPrefix    = "hub:suppress:"
+	suppressIndexPrefix    = "hub:suppress_index:" // per ruleset instance, suppression keys scored by window end
+	suppressRollupInterval = 5 * time.Second
+	suppressRollupBatch    = 1000
+)
+
+// SuppressionInfo describes an active suppression
+type SuppressionInfo struct {
+	ID                  string            `json:"id"`
+	RulesetID           string            `json:"ruleset_id"`
+	RuleID              string            `json:"rule_id"`
+	ProjectNodeSequence string            `json:"project_node_sequence"`
+	Values              map[string]string `json:"values"` // suppress_by field values
+	WindowStart         time.Time         `json:"window_start"`
+	WindowEnd           time.Time         `json:"window_end"`
+	Suppressed          int               `json:"suppressed_count"`
+	LastSeen            *time.Time        `json:"last_seen,omitempty"`
+	LocalCache          bool              `json:"local_cache"`
+	NodeID              string            `json:"node_id,omitempty"` // node holding a local suppression
+}
+
+type localSuppression struct {
+	info   SuppressionInfo
+	window time.Duration
+	last   map[string]interface{} // last suppressed alert, emitted as the rollup
+}
+
+// reset starts a new window
+func (s *localSuppression) reset(now time.Time) {
+	s.info.WindowStart = now
+	s.info.WindowEnd = now.Add(s.window)
+	s.info.Suppressed = 0
+	s.info.LastSeen = nil
+	s.last = nil
+}
+
+// redisSuppressionMeta is stored with a Redis suppression for listing
+type redisSuppressionMeta struct {
+	RulesetID           string            `json:"ruleset_id"`
+	RuleID              string            `json:"rule_id"`
+	ProjectNodeSequence string            `json:"project_node_sequence"`
+	Values              map[string]string `json:"values"`
+}
+
+// Suppress an alert inside the window, otherwise start a new window and return the count of the ended one.
+// KEYS: suppression hash, index. ARGV: now, window, alert json, meta json.
+// Returns {emit, suppressed count, window start}
+var redisSuppressScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local wend = tonumber(redis.call("HGET", KEYS[1], "end"))
+if wend and now < wend then
+	local count = redis.call("HINCRBY", KEYS[1], "count", 1)
+	redis.call("HSET", KEYS[1], "last", ARGV[3], "last_seen", now)
+	return {0, count, 0}
+end
+local count = 0
+local start = 0
+if wend then
+	count = tonumber(redis.call("HGET", KEYS[1], "count")) or 0
+	start = tonumber(redis.call("HGET", KEYS[1], "start")) or 0
+	redis.call("HDEL", KEYS[1], "last", "last_seen")
+end
+redis.call("HSET", KEYS[1], "meta", ARGV[4], "window", window, "start", now, "end", now + window, "count", 0)
+redis.call("EXPIRE", KEYS[1], window * 2 + 60)
+redis.call("ZADD", KEYS[2], now + window, KEYS[1])
+return {1, count, start}
+`)
+
+// Roll up an ended window: nothing if it is still running (another node rolled it up) or gone,
+// dropped without suppressed alerts, otherwise a new window starts.
+// KEYS: suppression hash, index. ARGV: now.
+// Returns {suppressed count, window start, last alert json} or nil
+var redisSuppressRollupScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local wend = tonumber(redis.call("HGET", KEYS[1], "end"))
+if not wend then
+	redis.call("ZREM", KEYS[2], KEYS[1])
+	return false
+end
+if now < wend then
+	redis.call("ZADD", KEYS[2], wend, KEYS[1])
+	return false
+end
+local count = tonumber(redis.call("HGET", KEYS[1], "count")) or 0
+local last = redis.call("HGET", KEYS[1], "last")
+if count == 0 or not last then
+	redis.call("DEL", KEYS[1])
+	redis.call("ZREM", KEYS[2], KEYS[1])
+	return false
+end
+local start = redis.call("HGET", KEYS[1], "start")
+local window = tonumber(redis.call("HGET", KEYS[1], "window")) or 0
+redis.call("HSET", KEYS[1], "start", now, "end", now + window, "count", 0)
+redis.call("HDEL", KEYS[1], "last", "last_seen")
+redis.call("EXPIRE", KEYS[1], window * 2 + 60)
+redis.call("ZADD", KEYS[2], now + window, KEYS[1])
+return {tostring(count), start, last}
+`)
+
+// suppressionKey returns the suppression ID of an alert and its suppress_by values
+func (r *Ruleset) suppressionKey(rule *Rule, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) (string, map[string]string) {
+	values := make(map[string]string, len(rule.SuppressByFields))
+	sb := stringBuilderPool.Get().(*strings.Builder)
+	sb.Reset()
+	sb.WriteString(r.ProjectNodeSequence)
+	sb.WriteByte(0)
+	sb.WriteString(rule.ID)
+	for i, field := range rule.SuppressByFields {
+		value, _ := GetCheckDataFromCache(ruleCache, field, data, rule.SuppressByList[i])
+		values[field] = value
+		sb.WriteByte(0)
+		sb.WriteString(value)
+	}
+	id := common.XXHash64(sb.String())
+	stringBuilderPool.Put(sb)
+	return id, values
+}
+
+// applySuppression reports whether an alert of rule is emitted, an alert starting a new window
+// after suppressed alerts carries their count
+func (r *Ruleset) applySuppression(rule *Rule, data map[string]interface{}, ruleCache map[string]common.CheckCoreCache) bool {
+	id, values := r.suppressionKey(rule, data, ruleCache)
+	now := time.Now()
+
+	var emit bool
+	var suppressed int
+	var since time.Time
+	if rule.SuppressLocalCache {
+		emit, suppressed, since = r.localSuppress(rule, id, values, data, now)
+	} else {
+		var err error
+		emit, suppressed, since, err = r.redisSuppress(rule, id, values, data, now)
+		if err != nil {
+			// Better a duplicate alert than a lost one
+			logger.Error("Suppression check error:", err, "RuleID:", rule.ID, "RuleSetID:", r.RulesetID)
+			return true
+		}
+	}
+
+	if emit && suppressed > 0 {
+		data[SuppressedCountFieldName] = suppressed
+		data[SuppressedSinceFieldName] = since.UTC().Format(time.RFC3339)
+	}
+	return emit
+}
+
+func (r *Ruleset) localSuppress(rule *Rule, id string, values map[string]string, data map[string]interface{}, now time.Time) (bool, int, time.Time) {
+	r.SuppressMu.Lock()
+	defer r.SuppressMu.Unlock()
+
+	if r.suppressions == nil {
+		r.suppressions = make(map[string]*localSuppression)
+	}
+	s, exist := r.suppressions[id]
+	if !exist {
+		s = &localSuppression{
+			info: SuppressionInfo{
+				ID:                  id,
+				RulesetID:           r.RulesetID,
+				RuleID:              rule.ID,
+				ProjectNodeSequence: r.ProjectNodeSequence,
+				Values:              values,
+				LocalCache:          true,
+			},
+			window: time.Duration(rule.SuppressWindowInt) * time.Second,
+		}
+		s.reset(now)
+		r.suppressions[id] = s
+		return true, 0, time.Time{}
+	}
+
+	if now.Before(s.info.WindowEnd) {
+		s.info.Suppressed++
+		s.info.LastSeen = &now
+		s.last = data
+		return false, 0, time.Time{}
+	}
+
+	// The window ended before its rollup ran, this alert carries the suppressed count instead
+	suppressed, since := s.info.Suppressed, s.info.WindowStart
+	s.reset(now)
+	return true, suppressed, since
+}
+
+func (r *Ruleset) redisSuppress(rule *Rule, id string, values map[string]string, data map[string]interface{}, now time.Time) (bool, int, time.Time, error) {
+	alert, err := json.Marshal(data)
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	meta, _ := json.Marshal(redisSuppressionMeta{
+		RulesetID:           r.RulesetID,
+		RuleID:              rule.ID,
+		ProjectNodeSequence: r.ProjectNodeSequence,
+		Values:              values,
+	})
+
+	res, err := redisSuppressScript.Run(context.Background(), common.GetRedisClient(),
+		[]string{suppressRedisPrefix + id, suppressIndexPrefix + r.ProjectNodeSequence},
+		now.Unix(), rule.SuppressWindowInt, alert, meta).Int64Slice()
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	if len(res) != 3 {
+		return false, 0, time.Time{}, errors.New("unexpected suppression result")
+	}
+	return res[0] == 1, int(res[1]), time.Unix(res[2], 0), nil
+}
+
+// hasSuppression reports whether a rule of the ruleset suppresses alerts
+func (r *Ruleset) hasSuppression() bool {
+	for i := range r.Rules {
+		if r.Rules[i].SuppressWindowInt > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runSuppressionRollups periodically sends the rollups of ended windows downstream until stop is closed
+func (r *Ruleset) runSuppressionRollups(stop chan struct{}) {
+	ticker := time.NewTicker(suppressRollupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, res := range r.suppressionRollups(time.Now()) {
+				for _, downCh := range r.DownStream {
+					select {
+					case *downCh <- res:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// suppressionRollups returns the rollups of the windows ended at now and starts their next windows
+func (r *Ruleset) suppressionRollups(now time.Time) []map[string]interface{} {
+	var rollups []map[string]interface{}
+
+	r.SuppressMu.Lock()
+	for id, s := range r.suppressions {
+		if now.Before(s.info.WindowEnd) {
+			continue
+		}
+		if s.info.Suppressed == 0 || s.last == nil {
+			delete(r.suppressions, id)
+			continue
+		}
+		rollup := s.last
+		rollup[SuppressedCountFieldName] = s.info.Suppressed
+		rollup[SuppressedSinceFieldName] = s.info.WindowStart.UTC().Format(time.RFC3339)
+		rollups = append(rollups, rollup)
+		s.reset(now)
+	}
+	r.SuppressMu.Unlock()
+
+	if !r.hasRedisSuppression() {
+		return rollups
+	}
+
+	client := common.GetRedisClient()
+	index := suppressIndexPrefix + r.ProjectNodeSequence
+	keys, err := client.ZRangeByScore(context.Background(), index, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: suppressRollupBatch,
+	}).Result()
+	if err != nil {
+		logger.Error("Failed to read suppression index", "ruleset", r.RulesetID, "error", err)
+		return rollups
+	}
+	for _, key := range keys {
+		res, err := redisSuppressRollupScript.Run(context.Background(), client, []string{key, index}, now.Unix()).StringSlice()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				logger.Error("Failed to roll up suppression", "ruleset", r.RulesetID, "key", key, "error", err)
+			}
+			continue
+		}
+		if len(res) != 3 {
+			continue
+		}
+		var rollup map[string]interface{}
+		if err := json.Unmarshal([]byte(res[2]), &rollup); err != nil {
+			logger.Error("Invalid suppressed alert", "ruleset", r.RulesetID, "key", key, "error", err)
+			continue
+		}
+		count, _ := strconv.Atoi(res[0])
+		start, _ := strconv.ParseInt(res[1], 10, 64)
+		rollup[SuppressedCountFieldName] = count
+		rollup[SuppressedSinceFieldName] = time.Unix(start, 0).UTC().Format(time.RFC3339)
+		rollups = append(rollups, rollup)
+	}
+	return rollups
+}
+
+func (r *Ruleset) hasRedisSuppression() bool {
+	for i := range r.Rules {
+		if r.Rules[i].SuppressWindowInt > 0 && !r.Rules[i].SuppressLocalCache {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalSuppressions returns the active suppressions kept on this node by the ruleset
+func (r *Ruleset) LocalSuppressions() []SuppressionInfo {
+	r.SuppressMu.Lock()
+	defer r.SuppressMu.Unlock()
+
+	nodeID := common.GetNodeID()
+	res := make([]SuppressionInfo, 0, len(r.suppressions))
+	for _, s := range r.suppressions {
+		info := s.info
+		info.NodeID = nodeID
+		res = append(res, info)
+	}
+	return res
+}
+
+// ClearLocalSuppressions removes the local suppressions matching match, their suppressed alerts are dropped
+func (r *Ruleset) ClearLocalSuppressions(match func(info *SuppressionInfo) bool) int {
+	r.SuppressMu.Lock()
+	defer r.SuppressMu.Unlock()
+
+	cleared := 0
+	for id, s := range r.suppressions {
+		if match(&s.info) {
+			delete(r.suppressions, id)
+			cleared++
+		}
+	}
+	return cleared
+}
+
+// RedisSuppressions returns the active suppressions shared through Redis
+func RedisSuppressions() ([]SuppressionInfo, error) {
+	client := common.GetRedisClient()
+	ctx := context.Background()
+
+	indexes, err := common.RedisKeys(suppressIndexPrefix + "*")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]SuppressionInfo, 0)
+	for _, index := range indexes {
+		keys, err := client.ZRange(ctx, index, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			fields, err := common.RedisHGetAll(key)
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) == 0 {
+				// Expired, e.g. its ruleset is no longer running
+				client.ZRem(ctx, index, key)
+				continue
+			}
+			var meta redisSuppressionMeta
+			_ = json.Unmarshal([]byte(fields["meta"]), &meta)
+			start, _ := strconv.ParseInt(fields["start"], 10, 64)
+			end, _ := strconv.ParseInt(fields["end"], 10, 64)
+			count, _ := strconv.Atoi(fields["count"])
+			info := SuppressionInfo{
+				ID:                  strings.TrimPrefix(key, suppressRedisPrefix),
+				RulesetID:           meta.RulesetID,
+				RuleID:              meta.RuleID,
+				ProjectNodeSequence: meta.ProjectNodeSequence,
+				Values:              meta.Values,
+				WindowStart:         time.Unix(start, 0),
+				WindowEnd:           time.Unix(end, 0),
+				Suppressed:          count,
+			}
+			if lastSeen, err := strconv.ParseInt(fields["last_seen"], 10, 64); err == nil {
+				t := time.Unix(lastSeen, 0)
+				info.LastSeen = &t
+			}
+			res = append(res, info)
+		}
+	}
+	return res, nil
+}
+
+// ClearRedisSuppressions removes the Redis suppressions matching match, their suppressed alerts are dropped
+func ClearRedisSuppressions(match func(info *SuppressionInfo) bool) (int, error) {
+	list, err := RedisSuppressions()
+	if err != nil {
+		return 0, err
+	}
+	client := common.GetRedisClient()
+	ctx := context.Background()
+
+	cleared := 0
+	for i := range list {
+		info := &list[i]
+		if !match(info) {
+			continue
+		}
+		key := suppressRedisPrefix + info.ID
+		if err := common.RedisDel(key); err != nil {
+			return cleared, err
+		}
+		client.ZRem(ctx, suppressIndexPrefix+info.ProjectNodeSequence, key)
+		cleared++
+	}
+	return cleared, nil
+}
@@ -0,0 +1,235 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newSuppressionTestRuleset builds a ruleset with one rule suppressing alerts by user for a minute
+func newSuppressionTestRuleset(t *testing.T, pns string, localCache bool) (*Ruleset, *Rule) {
+	t.Helper()
+	local := "false"
+	if localCache {
+		local = "true"
+	}
+	raw := `<root type="DETECTION">
+    <rule id="login" suppress_by="user, host" suppress_window="1m" suppress_local_cache="` + local + `">
+        <check type="NOTNULL" field="user"></check>
+    </rule>
+</root>`
+	rs, err := NewRuleset("", raw, "suppress_test")
+	if err != nil {
+		t.Fatalf("NewRuleset failed: %v", err)
+	}
+	rs.ProjectNodeSequence = pns
+	return rs, &rs.Rules[0]
+}
+
+// suppress runs the suppression of the rule for an alert at now
+func suppress(t *testing.T, rs *Ruleset, rule *Rule, data map[string]interface{}, now time.Time) (bool, int, time.Time) {
+	t.Helper()
+	id, values := rs.suppressionKey(rule, data, map[string]common.CheckCoreCache{})
+	if rule.SuppressLocalCache {
+		return rs.localSuppress(rule, id, values, data, now)
+	}
+	emit, suppressed, since, err := rs.redisSuppress(rule, id, values, data, now)
+	if err != nil {
+		t.Fatalf("redisSuppress failed: %v", err)
+	}
+	return emit, suppressed, since
+}
+
+func TestSuppressionKey(t *testing.T) {
+	rs, rule := newSuppressionTestRuleset(t, "p.suppress_test", true)
+	other, _ := newSuppressionTestRuleset(t, "q.suppress_test", true)
+	// The check cache holds the field values of one event
+	key := func(rs *Ruleset, data map[string]interface{}) (string, map[string]string) {
+		return rs.suppressionKey(rule, data, map[string]common.CheckCoreCache{})
+	}
+
+	id, values := key(rs, map[string]interface{}{"user": "alice", "host": "web-1"})
+	if values["user"] != "alice" || values["host"] != "web-1" || len(values) != 2 {
+		t.Errorf("suppressionKey values = %v", values)
+	}
+	if again, _ := key(rs, map[string]interface{}{"host": "web-1", "user": "alice", "x": 1}); again != id {
+		t.Errorf("suppressionKey depends on unrelated fields")
+	}
+	for _, data := range []map[string]interface{}{
+		{"user": "alice", "host": "web-2"},
+		{"user": "alice"},
+		{"user": "web-1", "host": "alice"},
+	} {
+		if got, _ := key(rs, data); got == id {
+			t.Errorf("suppressionKey(%v) = suppressionKey of alice on web-1", data)
+		}
+	}
+	if otherID, _ := key(other, map[string]interface{}{"user": "alice", "host": "web-1"}); otherID == id {
+		t.Errorf("suppressionKey is shared by ruleset instances of different projects")
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	for _, localCache := range []bool{true, false} {
+		name := "redis"
+		if localCache {
+			name = "local"
+		}
+		t.Run(name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			if err := common.RedisInit(mr.Addr(), ""); err != nil {
+				t.Fatalf("RedisInit failed: %v", err)
+			}
+			rs, rule := newSuppressionTestRuleset(t, "p."+name, localCache)
+			start := time.Unix(1700000000, 0)
+			alert := func(n int) map[string]interface{} {
+				return map[string]interface{}{"user": "alice", "host": "web-1", "n": n}
+			}
+
+			if emit, _, _ := suppress(t, rs, rule, alert(1), start); !emit {
+				t.Fatalf("first alert was suppressed")
+			}
+			for i := 2; i <= 4; i++ {
+				if emit, _, _ := suppress(t, rs, rule, alert(i), start.Add(time.Duration(i)*time.Second)); emit {
+					t.Fatalf("alert %d was not suppressed", i)
+				}
+			}
+			if emit, _, _ := suppress(t, rs, rule, map[string]interface{}{"user": "bob", "host": "web-1"}, start.Add(5*time.Second)); !emit {
+				t.Errorf("alert of another key was suppressed")
+			}
+
+			if rollups := rs.suppressionRollups(start.Add(30 * time.Second)); len(rollups) != 0 {
+				t.Errorf("rollups before the window ended = %v", rollups)
+			}
+
+			// The last suppressed alert is emitted with the count when the window ends, the key of bob had none
+			rollups := rs.suppressionRollups(start.Add(time.Minute))
+			if len(rollups) != 1 {
+				t.Fatalf("rollups = %v, expected one", rollups)
+			}
+			if common.AnyToString(rollups[0]["n"]) != "4" || rollups[0][SuppressedCountFieldName] != 3 ||
+				rollups[0][SuppressedSinceFieldName] != "2023-11-14T22:13:20Z" {
+				t.Errorf("rollup = %v, expected alert 4 with 3 suppressed since the window start", rollups[0])
+			}
+
+			// The rollup started a new window
+			next := start.Add(time.Minute + 10*time.Second)
+			if emit, _, _ := suppress(t, rs, rule, alert(5), next); emit {
+				t.Errorf("alert in the window after the rollup was not suppressed")
+			}
+
+			// An alert after a window whose rollup did not run yet carries the count itself
+			emit, suppressed, since := suppress(t, rs, rule, alert(6), start.Add(3*time.Minute))
+			if !emit || suppressed != 1 || !since.Equal(start.Add(time.Minute)) {
+				t.Errorf("alert after the window = %v, %d, %v, expected the count of the window started by the rollup", emit, suppressed, since)
+			}
+			if rollups := rs.suppressionRollups(start.Add(4 * time.Minute)); len(rollups) != 0 {
+				t.Errorf("rollups of a window without suppressed alerts = %v", rollups)
+			}
+			if emit, _, _ := suppress(t, rs, rule, alert(7), start.Add(5*time.Minute)); !emit {
+				t.Errorf("alert after a dropped window was suppressed")
+			}
+		})
+	}
+}
+
+// TestRedisSuppressionRollupOnce checks that two nodes running the ruleset emit a rollup once
+func TestRedisSuppressionRollupOnce(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	nodeA, rule := newSuppressionTestRuleset(t, "p.shared", false)
+	nodeB, ruleB := newSuppressionTestRuleset(t, "p.shared", false)
+	start := time.Unix(1700000000, 0)
+
+	alert := map[string]interface{}{"user": "alice", "host": "web-1"}
+	if emit, _, _ := suppress(t, nodeA, rule, alert, start); !emit {
+		t.Fatalf("first alert was suppressed")
+	}
+	if emit, _, _ := suppress(t, nodeB, ruleB, alert, start.Add(time.Second)); emit {
+		t.Fatalf("alert on the other node was not suppressed")
+	}
+
+	end := start.Add(time.Minute)
+	if rollups := nodeA.suppressionRollups(end); len(rollups) != 1 {
+		t.Errorf("rollups on node a = %v, expected one", rollups)
+	}
+	if rollups := nodeB.suppressionRollups(end); len(rollups) != 0 {
+		t.Errorf("rollups on node b = %v, expected none", rollups)
+	}
+
+	list, err := RedisSuppressions()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("RedisSuppressions() = %v, %v", list, err)
+	}
+	info := list[0]
+	if info.RuleID != "login" || info.RulesetID != "suppress_test" || info.ProjectNodeSequence != "p.shared" ||
+		info.Values["user"] != "alice" || !info.WindowStart.Equal(end) || !info.WindowEnd.Equal(end.Add(time.Minute)) {
+		t.Errorf("RedisSuppressions()[0] = %+v", info)
+	}
+
+	// Cleared suppressions let the next alert through
+	cleared, err := ClearRedisSuppressions(func(info *SuppressionInfo) bool { return info.RuleID == "login" })
+	if err != nil || cleared != 1 {
+		t.Fatalf("ClearRedisSuppressions() = %d, %v", cleared, err)
+	}
+	if emit, _, _ := suppress(t, nodeA, rule, alert, end.Add(time.Second)); !emit {
+		t.Errorf("alert after clearing the suppression was suppressed")
+	}
+}
+
+func TestLocalSuppressionsList(t *testing.T) {
+	rs, rule := newSuppressionTestRuleset(t, "p.local", true)
+	now := time.Now()
+	suppress(t, rs, rule, map[string]interface{}{"user": "alice", "host": "web-1"}, now)
+	suppress(t, rs, rule, map[string]interface{}{"user": "alice", "host": "web-1"}, now)
+	suppress(t, rs, rule, map[string]interface{}{"user": "bob", "host": "web-1"}, now)
+
+	list := rs.LocalSuppressions()
+	if len(list) != 2 {
+		t.Fatalf("LocalSuppressions() = %v, expected 2", list)
+	}
+	for _, info := range list {
+		expected := 0
+		if info.Values["user"] == "alice" {
+			expected = 1
+		}
+		if info.Suppressed != expected || !info.LocalCache || info.RuleID != "login" {
+			t.Errorf("LocalSuppressions() entry = %+v", info)
+		}
+	}
+
+	if cleared := rs.ClearLocalSuppressions(func(info *SuppressionInfo) bool { return info.Values["user"] == "bob" }); cleared != 1 {
+		t.Errorf("ClearLocalSuppressions() = %d, expected 1", cleared)
+	}
+	if list := rs.LocalSuppressions(); len(list) != 1 || list[0].Values["user"] != "alice" {
+		t.Errorf("LocalSuppressions() after clearing = %v", list)
+	}
+}
+
+func TestSuppressionParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs string
+		root  string
+	}{
+		{"window without fields", `suppress_window="1m"`, "DETECTION"},
+		{"fields without window", `suppress_by="user"`, "DETECTION"},
+		{"invalid window", `suppress_by="user" suppress_window="soon"`, "DETECTION"},
+		{"invalid local cache", `suppress_by="user" suppress_window="1m" suppress_local_cache="yes"`, "DETECTION"},
+		{"exclude ruleset", `suppress_by="user" suppress_window="1m"`, "EXCLUDE"},
+		{"empty fields", `suppress_by=" , " suppress_window="1m"`, "DETECTION"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw := `<root type="` + test.root + `"><rule id="r" ` + test.attrs + `><check type="NOTNULL" field="user"></check></rule></root>`
+			if _, err := NewRuleset("", raw, "suppress_parse_test"); err == nil {
+				t.Errorf("NewRuleset() with %s succeeded", test.attrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ruleset test cases are kept next to the ruleset as <config_root>/ruleset/<id>.test.yaml:
+//
+//	tests:
+//	  - name: ssh brute force from office
+//	    data: {src_ip: 10.0.0.1, event: ssh_login_failed}   # one event, or
+//	    events: [...]                                       # several, checked after the last one
+//	    hits: [ssh_brute_force]                             # expected hit rule IDs, [] for no alert
+//	    excluded: false                                     # EXCLUDE rulesets: whether the event is filtered
+//	    fields: {geo.country_code: CN}                      # expected fields of every output
+//	    deleted: [password]                                 # fields every output must not have
+//
+// Each case runs on its own instance of the ruleset. Thresholds, sequences and suppressions use
+// the local cache and plugin results are not cached, so cases never share state with each other
+// or with running projects.
+
+// TestCaseFileSuffix is the suffix of ruleset test case files
+const TestCaseFileSuffix = ".test.yaml"
+
+// TestCase is a single ruleset test case
+type TestCase struct {
+	Name     string                   `yaml:"name" json:"name"`
+	Data     map[string]interface{}   `yaml:"data,omitempty" json:"data,omitempty"`
+	Events   []map[string]interface{} `yaml:"events,omitempty" json:"events,omitempty"`
+	Hits     *[]string                `yaml:"hits,omitempty" json:"hits,omitempty"`
+	Excluded *bool                    `yaml:"excluded,omitempty" json:"excluded,omitempty"`
+	Fields   map[string]interface{}   `yaml:"fields,omitempty" json:"fields,omitempty"`
+	Deleted  []string                 `yaml:"deleted,omitempty" json:"deleted,omitempty"`
+}
+
+// TestCaseFile is the content of a ruleset test case file
+type TestCaseFile struct {
+	Tests []TestCase `yaml:"tests" json:"tests"`
+}
+
+// TestCaseResult is the result of a single test case
+type TestCaseResult struct {
+	Name     string                   `json:"name"`
+	Passed   bool                     `json:"passed"`
+	Failures []string                 `json:"failures,omitempty"`
+	Hits     []string                 `json:"hits"`
+	Output   []map[string]interface{} `json:"output"`
+}
+
+// TestReport is the result of running the test cases of a ruleset
+type TestReport struct {
+	RulesetID string           `json:"ruleset_id"`
+	Total     int              `json:"total"`
+	Passed    int              `json:"passed"`
+	Failed    int              `json:"failed"`
+	Results   []TestCaseResult `json:"results"`
+}
+
+// TestCasePath returns the test case file of a ruleset
+func TestCasePath(rulesetID string) string {
+	return filepath.Join(common.Config.ConfigRoot, "ruleset", rulesetID+TestCaseFileSuffix)
+}
+
+// LoadTestCases reads the test cases of a ruleset, a ruleset without test case file has none
+func LoadTestCases(rulesetID string) ([]TestCase, error) {
+	raw, err := os.ReadFile(TestCasePath(rulesetID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read test cases of ruleset %s: %w", rulesetID, err)
+	}
+	return ParseTestCases(raw)
+}
+
+// ParseTestCases parses and validates the content of a test case file
+func ParseTestCases(raw []byte) ([]TestCase, error) {
+	var file TestCaseFile
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse test cases: %w", err)
+	}
+
+	names := make(map[string]bool, len(file.Tests))
+	for i, tc := range file.Tests {
+		if tc.Name == "" {
+			return nil, fmt.Errorf("test case %d: name is required", i+1)
+		}
+		if names[tc.Name] {
+			return nil, fmt.Errorf("test case %s: duplicate name", tc.Name)
+		}
+		names[tc.Name] = true
+		if (tc.Data == nil) == (len(tc.Events) == 0) {
+			return nil, fmt.Errorf("test case %s: exactly one of data and events is required", tc.Name)
+		}
+		if tc.Hits == nil && tc.Excluded == nil && len(tc.Fields) == 0 && len(tc.Deleted) == 0 {
+			return nil, fmt.Errorf("test case %s: nothing to check, set hits, excluded, fields or deleted", tc.Name)
+		}
+	}
+	return file.Tests, nil
+}
+
+// RunTestCases runs test cases against the given ruleset content
+func RunTestCases(rulesetID string, raw string, cases []TestCase) (*TestReport, error) {
+	if err := Verify("", raw); err != nil {
+		return nil, err
+	}
+
+	report := &TestReport{RulesetID: rulesetID, Total: len(cases), Results: make([]TestCaseResult, 0, len(cases))}
+	for i := range cases {
+		res := runTestCase(rulesetID, []byte(raw), &cases[i])
+		if res.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
+
+// Error summarizes the failed test cases, nil if all passed
+func (r *TestReport) Error() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	var failed []string
+	for _, res := range r.Results {
+		if !res.Passed {
+			failed = append(failed, res.Name+": "+strings.Join(res.Failures, "; "))
+		}
+	}
+	return fmt.Errorf("%d of %d test cases failed: %s", r.Failed, r.Total, strings.Join(failed, " | "))
+}
+
+func runTestCase(rulesetID string, raw []byte, tc *TestCase) (res TestCaseResult) {
+	res = TestCaseResult{Name: tc.Name, Hits: []string{}, Output: []map[string]interface{}{}}
+	defer func() {
+		if r := recover(); r != nil {
+			res.Passed = false
+			res.Failures = append(res.Failures, fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
+	rs, err := newTestCaseRuleset(rulesetID, raw)
+	if err != nil {
+		res.Failures = append(res.Failures, err.Error())
+		return res
+	}
+
+	events := tc.Events
+	if tc.Data != nil {
+		events = []map[string]interface{}{tc.Data}
+	}
+	var output []map[string]interface{}
+	for _, event := range events {
+		output = rs.EngineCheck(common.MapDeepCopy(event))
+		rs.waitLocalCaches()
+	}
+	res.Output = output
+
+	prefix := rulesetID + "."
+	for _, out := range output {
+		if ids, ok := out[HitRuleIdFieldName].(string); ok && rs.IsDetection {
+			// Upstream rulesets may have added their hits before, this ruleset's hit is the last one
+			id := ids[strings.LastIndex(ids, ",")+1:]
+			res.Hits = append(res.Hits, strings.TrimPrefix(id, prefix))
+		}
+	}
+	sort.Strings(res.Hits)
+
+	res.Failures = append(res.Failures, checkTestCase(tc, rs, res.Hits, output)...)
+	res.Passed = len(res.Failures) == 0
+	return res
+}
+
+// newTestCaseRuleset builds an isolated instance of the ruleset for a test case
+func newTestCaseRuleset(rulesetID string, raw []byte) (*Ruleset, error) {
+	rs, err := ParseRuleset(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ruleset: %w", err)
+	}
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		for id, threshold := range rule.ThresholdMap {
+			threshold.LocalCache = true
+			rule.ThresholdMap[id] = threshold
+		}
+		for id, sequence := range rule.SequenceMap {
+			sequence.LocalCache = true
+			rule.SequenceMap[id] = sequence
+		}
+		rule.SuppressLocalCache = true
+	}
+	rs.RulesetID = rulesetID
+	if err := RulesetBuild(rs); err != nil {
+		return nil, fmt.Errorf("ruleset build error: %w", err)
+	}
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		for id, appendNode := range rule.AppendsMap {
+			appendNode.CacheTTLInt = 0
+			rule.AppendsMap[id] = appendNode
+		}
+		for id, pluginNode := range rule.PluginMap {
+			pluginNode.CacheTTLInt = 0
+			rule.PluginMap[id] = pluginNode
+		}
+	}
+	rs.isTestMode = true
+	return rs, nil
+}
+
+// waitLocalCaches makes the local cache writes of an event visible to the next event
+func (r *Ruleset) waitLocalCaches() {
+	if r.Cache != nil {
+		r.Cache.Wait()
+	}
+	if r.CacheForClassify != nil {
+		r.CacheForClassify.Wait()
+	}
+	if r.CacheForDistinct != nil {
+		r.CacheForDistinct.Wait()
+	}
+	if r.CacheForAggregate != nil {
+		r.CacheForAggregate.Wait()
+	}
+	if r.CacheForSequence != nil {
+		r.CacheForSequence.Wait()
+	}
+}
+
+func checkTestCase(tc *TestCase, rs *Ruleset, hits []string, output []map[string]interface{}) []string {
+	var failures []string
+
+	if tc.Hits != nil {
+		if !rs.IsDetection {
+			failures = append(failures, "hits can only be checked on DETECTION rulesets")
+		} else {
+			expected := append([]string(nil), *tc.Hits...)
+			sort.Strings(expected)
+			if strings.Join(expected, ",") != strings.Join(hits, ",") {
+				failures = append(failures, fmt.Sprintf("expected hits %v, got %v", expected, hits))
+			}
+		}
+	}
+
+	if tc.Excluded != nil {
+		if rs.IsDetection {
+			failures = append(failures, "excluded can only be checked on EXCLUDE rulesets")
+		} else if excluded := len(output) == 0; excluded != *tc.Excluded {
+			failures = append(failures, fmt.Sprintf("expected excluded %v, got %v", *tc.Excluded, excluded))
+		}
+	}
+
+	if (len(tc.Fields) > 0 || len(tc.Deleted) > 0) && len(output) == 0 {
+		return append(failures, "no output to check fields on")
+	}
+	fields := make([]string, 0, len(tc.Fields))
+	for field := range tc.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for i, out := range output {
+		for _, field := range fields {
+			actual, exist := common.GetCheckDataWithType(out, common.StringToList(field))
+			if !exist {
+				failures = append(failures, fmt.Sprintf("output %d: field %s is missing", i+1, field))
+			} else if want, got := testCaseValue(tc.Fields[field]), testCaseValue(actual); want != got {
+				failures = append(failures, fmt.Sprintf("output %d: field %s expected %s, got %s", i+1, field, want, got))
+			}
+		}
+		for _, field := range tc.Deleted {
+			if _, exist := common.GetCheckData(out, common.StringToList(field)); exist {
+				failures = append(failures, fmt.Sprintf("output %d: field %s should be deleted", i+1, field))
+			}
+		}
+	}
+	return failures
+}
+
+// testCaseValue converts an expected or actual value to a comparable string,
+// maps and lists are compared by their JSON form
+func testCaseValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return common.AnyToString(v)
+}
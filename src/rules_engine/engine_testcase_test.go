@@ -0,0 +1,134 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"strings"
+	"testing"
+)
+
+// Test cases keep thresholds, sequences and suppressions in a local cache, they give the same
+// results on apply and in the -test CLI mode, which runs without Redis
+const testCaseRuleset = `<root type="DETECTION">
+    <rule id="brute_force" suppress_by="user" suppress_window="10m">
+        <check type="EQU" field="result">failed</check>
+        <threshold group_by="user" range="5m">2</threshold>
+        <append field="alert_type">brute_force</append>
+    </rule>
+    <rule id="takeover">
+        <sequence group_by="user" range="10m">
+            <step id="failed" count="2">
+                <check type="EQU" field="result">failed</check>
+            </step>
+            <step id="success">
+                <check type="EQU" field="result">success</check>
+            </step>
+        </sequence>
+    </rule>
+</root>`
+
+const testCaseFile = `tests:
+  - name: three failures
+    events:
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+    hits: [brute_force]
+    fields: {alert_type: brute_force}
+  - name: two failures
+    events:
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+    hits: []
+  - name: suppressed after the first alert
+    events:
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+      - {user: alice, result: failed}
+    hits: []
+  - name: failures then success
+    events:
+      - {user: bob, result: failed}
+      - {user: bob, result: failed}
+      - {user: bob, result: success}
+    hits: [takeover]
+`
+
+func TestRunTestCasesLocalState(t *testing.T) {
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir()}
+	defer func() { common.Config = prevConfig }()
+
+	cases, err := ParseTestCases([]byte(testCaseFile))
+	if err != nil {
+		t.Fatalf("ParseTestCases failed: %v", err)
+	}
+	// Run twice, cases must not share state with each other or with an earlier run
+	for run := 0; run < 2; run++ {
+		report, err := RunTestCases("auth", testCaseRuleset, cases)
+		if err != nil {
+			t.Fatalf("RunTestCases failed: %v", err)
+		}
+		for _, res := range report.Results {
+			if !res.Passed {
+				t.Errorf("run %d: %s failed: %v (hits %v)", run, res.Name, res.Failures, res.Hits)
+			}
+		}
+	}
+}
+
+func TestRunTestCasesRegression(t *testing.T) {
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir()}
+	defer func() { common.Config = prevConfig }()
+
+	cases, err := ParseTestCases([]byte(testCaseFile))
+	if err != nil {
+		t.Fatalf("ParseTestCases failed: %v", err)
+	}
+	changed := strings.Replace(testCaseRuleset, `range="5m">2<`, `range="5m">3<`, 1)
+	report, err := RunTestCases("auth", changed, cases)
+	if err != nil {
+		t.Fatalf("RunTestCases failed: %v", err)
+	}
+	if report.Failed != 1 || report.Results[0].Passed {
+		t.Fatalf("report = %+v, expected only the three failures case to fail", report)
+	}
+	if err := report.Error(); err == nil || !strings.Contains(err.Error(), "three failures: expected hits [brute_force], got []") {
+		t.Errorf("Error() = %v", err)
+	}
+
+	if _, err := RunTestCases("auth", "<root", cases); err == nil {
+		t.Error("RunTestCases of an invalid ruleset succeeded")
+	}
+}
+
+func TestParseTestCases(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		err  string
+	}{
+		{"valid", "tests:\n  - {name: a, data: {x: 1}, hits: []}\n", ""},
+		{"no name", "tests:\n  - {data: {x: 1}, hits: []}\n", "name is required"},
+		{"duplicate", "tests:\n  - {name: a, data: {x: 1}, hits: []}\n  - {name: a, data: {x: 1}, hits: []}\n", "duplicate name"},
+		{"no data", "tests:\n  - {name: a, hits: []}\n", "exactly one of data and events"},
+		{"data and events", "tests:\n  - {name: a, data: {x: 1}, events: [{x: 1}], hits: []}\n", "exactly one of data and events"},
+		{"nothing to check", "tests:\n  - {name: a, data: {x: 1}}\n", "nothing to check"},
+		{"invalid yaml", "tests: [", "failed to parse"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseTestCases([]byte(test.raw))
+			if test.err == "" && err != nil {
+				t.Errorf("ParseTestCases() = %v, expected no error", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("ParseTestCases() = %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/plugin"
+	"AgentSmith-HUB/tracing"
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans of traced messages: one per EngineCheck and one per plugin call below it.
+// Messages that are not traced get no-op spans and nothing is allocated for them.
+
+// maxSpanResultLen bounds the plugin result kept on a span
+const maxSpanResultLen = 256
+
+// endCheckSpan records the outcome of a ruleset check and ends its span
+func (r *Ruleset) endCheckSpan(span trace.Span, results []map[string]interface{}) {
+	rulesetType := "EXCLUDE"
+	if r.IsDetection {
+		rulesetType = "DETECTION"
+	}
+	span.SetAttributes(
+		attribute.String("hub.ruleset.id", r.RulesetID),
+		attribute.String("hub.ruleset.type", rulesetType),
+		attribute.String("hub.project_node_sequence", r.ProjectNodeSequence),
+		attribute.Int("hub.ruleset.results", len(results)),
+	)
+
+	if !r.IsDetection {
+		span.SetAttributes(attribute.Bool("hub.ruleset.excluded", len(results) == 0))
+		span.End()
+		return
+	}
+	hits := make([]string, 0, len(results))
+	for _, res := range results {
+		if ids, ok := res[HitRuleIdFieldName].(string); ok {
+			// Upstream rulesets may have added their hits before, this ruleset's hit is the last one
+			hits = append(hits, ids[strings.LastIndex(ids, ",")+1:])
+		}
+	}
+	span.SetAttributes(attribute.StringSlice("hub.ruleset.hits", hits))
+	span.End()
+}
+
+// startPluginSpan starts the span of a plugin call, ruleID is empty for plugins in checks
+func startPluginSpan(ctx context.Context, p *plugin.Plugin, usage string, ruleID string) trace.Span {
+	_, span := tracing.StartSpan(ctx, "plugin")
+	if span.IsRecording() {
+		span.SetName("plugin " + p.Name)
+		span.SetAttributes(
+			attribute.String("hub.plugin.name", p.Name),
+			attribute.String("hub.plugin.usage", usage),
+		)
+		if ruleID != "" {
+			span.SetAttributes(attribute.String("hub.rule.id", ruleID))
+		}
+	}
+	return span
+}
+
+// endPluginSpan records the result of a plugin call and ends its span
+func endPluginSpan(span trace.Span, result interface{}, err error) {
+	if span.IsRecording() && err == nil {
+		res := common.AnyToString(result)
+		if len(res) > maxSpanResultLen {
+			res = res[:maxSpanResultLen] + "..."
+		}
+		span.SetAttributes(attribute.String("hub.plugin.result", res))
+	}
+	tracing.End(span, err)
+}
@@ -0,0 +1,380 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Typed check types are evaluated against the original field value (number, bool, array...)
+// instead of its string form, so numbers are compared as numbers and lengths count elements.
+//
+//	GTE / LTE        field >= value / field <= value (numeric)
+//	BETWEEN          min <= field <= max, value is "min,max" (inclusive)
+//	IN / NOT_IN      exact match against a value list, for arrays any element may match
+//	LEN_MT / LEN_LT  length of a string (characters), array or object is more / less than value
+//	CIDR             field is an IP inside the value network (a single IP matches itself)
+//	TYPE             field exists and has the given type: string, number, bool, array, object
+//	IN_LOOKUP        field is in the lookup table named by value, NOT_IN_LOOKUP is the opposite (see engine_lookup.go)
+//
+// IN, NOT_IN and BETWEEN take their list from the value, split by delimiter ("," by default),
+// and do not accept logic. The other types support logic/delimiter and _$ values like MT/LT.
+
+// defaultTypedListDelimiter separates the value list of IN, NOT_IN and BETWEEN
+const defaultTypedListDelimiter = ","
+
+// typedCheckTypes lists all typed check types
+var typedCheckTypes = map[string]bool{
+	"GTE": true, "LTE": true, "BETWEEN": true, "IN": true, "NOT_IN": true,
+	"LEN_MT": true, "LEN_LT": true, "CIDR": true, "TYPE": true,
+	"IN_LOOKUP": true, "NOT_IN_LOOKUP": true,
+}
+
+// typedListCheckTypes use delimiter to split their own value list instead of logic
+var typedListCheckTypes = map[string]bool{
+	"BETWEEN": true, "IN": true, "NOT_IN": true,
+}
+
+var typedValueTypes = map[string]bool{
+	"string": true, "number": true, "bool": true, "array": true, "object": true,
+}
+
+// isTypedCheckType reports whether checkType is evaluated by a typed check function
+func isTypedCheckType(checkType string) bool {
+	return typedCheckTypes[checkType]
+}
+
+// buildTypedCheckFunc returns the check function of a typed check node and validates its static value
+func buildTypedCheckFunc(node *CheckNodes) (func(interface{}, string) (bool, string), error) {
+	checkType := strings.TrimSpace(node.Type)
+	value := strings.TrimSpace(node.Value)
+
+	if lookupCheckTypes[checkType] {
+		return buildLookupCheckFunc(node)
+	}
+
+	if typedListCheckTypes[checkType] {
+		if node.Logic != "" {
+			return nil, fmt.Errorf("%s check does not support logic, list its values with delimiter", checkType)
+		}
+		if hasFromRawPrefix(value) {
+			return nil, fmt.Errorf("%s check value cannot reference raw data", checkType)
+		}
+		delimiter := node.Delimiter
+		if delimiter == "" {
+			delimiter = defaultTypedListDelimiter
+		}
+		items := splitTypedList(value, delimiter)
+
+		switch checkType {
+		case "BETWEEN":
+			return buildBetweenFunc(items)
+		case "IN":
+			return buildInFunc(items, false)
+		case "NOT_IN":
+			return buildInFunc(items, true)
+		}
+	}
+
+	// Validate static values up front, _$ values are resolved per event
+	values := []string{value}
+	if node.Logic != "" && node.Delimiter != "" {
+		values = strings.Split(value, node.Delimiter)
+	}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if hasFromRawPrefix(v) {
+			continue
+		}
+		if err := validateTypedCheckValue(checkType, v); err != nil {
+			return nil, err
+		}
+	}
+
+	switch checkType {
+	case "GTE":
+		return GTE, nil
+	case "LTE":
+		return LTE, nil
+	case "LEN_MT":
+		return LEN_MT, nil
+	case "LEN_LT":
+		return LEN_LT, nil
+	case "CIDR":
+		return CIDR, nil
+	case "TYPE":
+		return TYPE, nil
+	}
+	return nil, errors.New("unknown typed check type: " + checkType)
+}
+
+// validateTypedCheckValue checks a single static value of GTE, LTE, LEN_MT, LEN_LT, CIDR and TYPE
+func validateTypedCheckValue(checkType, value string) error {
+	switch checkType {
+	case "GTE", "LTE":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s check value must be a number: %q", checkType, value)
+		}
+	case "LEN_MT", "LEN_LT":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("%s check value must be a non-negative integer: %q", checkType, value)
+		}
+	case "CIDR":
+		if _, ok := parseCIDRValue(value); !ok {
+			return fmt.Errorf("CIDR check value must be a network (e.g. 10.0.0.0/8) or an IP: %q", value)
+		}
+	case "TYPE":
+		if !typedValueTypes[value] {
+			return fmt.Errorf("TYPE check value must be one of string, number, bool, array, object: %q", value)
+		}
+	}
+	return nil
+}
+
+// validateTypedCheckNode reports typed check configuration errors during ruleset validation
+func validateTypedCheckNode(node *CheckNodes, line int, ruleID string, result *ValidationResult) {
+	if _, err := buildTypedCheckFunc(node); err != nil {
+		result.IsValid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Line:    line,
+			Message: err.Error(),
+			Detail:  fmt.Sprintf("Rule ID: %s", ruleID),
+		})
+	}
+}
+
+func splitTypedList(value, delimiter string) []string {
+	parts := strings.Split(value, delimiter)
+	items := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			items = append(items, p)
+		}
+	}
+	return items
+}
+
+func buildBetweenFunc(items []string) (func(interface{}, string) (bool, string), error) {
+	if len(items) != 2 {
+		return nil, errors.New("BETWEEN check value must be 'min,max'")
+	}
+	lower, err1 := strconv.ParseFloat(items[0], 64)
+	upper, err2 := strconv.ParseFloat(items[1], 64)
+	if err1 != nil || err2 != nil {
+		return nil, errors.New("BETWEEN check bounds must be numbers")
+	}
+	if lower > upper {
+		return nil, errors.New("BETWEEN check min cannot be greater than max")
+	}
+	return func(data interface{}, _ string) (bool, string) {
+		n, ok := typedToFloat(data)
+		if !ok || n < lower || n > upper {
+			return false, ""
+		}
+		return true, common.AnyToString(data)
+	}, nil
+}
+
+func buildInFunc(items []string, negate bool) (func(interface{}, string) (bool, string), error) {
+	if len(items) == 0 {
+		return nil, errors.New("IN/NOT_IN check value list cannot be empty")
+	}
+	strSet := make(map[string]struct{}, len(items))
+	numSet := make(map[float64]struct{})
+	for _, item := range items {
+		strSet[item] = struct{}{}
+		if n, err := strconv.ParseFloat(item, 64); err == nil {
+			numSet[n] = struct{}{}
+		}
+	}
+
+	match := func(v interface{}) (bool, string) {
+		if s, ok := v.(string); ok {
+			_, hit := strSet[s]
+			return hit, s
+		}
+		if n, ok := typedToFloat(v); ok {
+			_, hit := numSet[n]
+			return hit, common.AnyToString(v)
+		}
+		s := common.AnyToString(v)
+		_, hit := strSet[s]
+		return hit, s
+	}
+
+	return func(data interface{}, _ string) (bool, string) {
+		hit, hitData := false, ""
+		if list, ok := data.([]interface{}); ok {
+			for _, v := range list {
+				if hit, hitData = match(v); hit {
+					break
+				}
+			}
+		} else {
+			hit, hitData = match(data)
+		}
+		if negate {
+			return !hit, ""
+		}
+		if !hit {
+			return false, ""
+		}
+		return true, hitData
+	}, nil
+}
+
+// typedToFloat converts numeric values (and numeric strings) to float64
+func typedToFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil || math.IsNaN(f) {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// typedLength returns the character count of strings and the element count of arrays and objects
+func typedLength(v interface{}) int {
+	switch t := v.(type) {
+	case string:
+		return utf8.RuneCountInString(t)
+	case []interface{}:
+		return len(t)
+	case map[string]interface{}:
+		return len(t)
+	}
+	return utf8.RuneCountInString(common.AnyToString(v))
+}
+
+// typedTypeName returns the JSON type name of a value
+func typedTypeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := typedToFloat(v); ok {
+		return "number"
+	}
+	return ""
+}
+
+func parseCIDRValue(value string) (netip.Prefix, bool) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		return prefix.Masked(), true
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+func GTE(data interface{}, ruleData string) (res bool, hitData string) {
+	n, ok := typedToFloat(data)
+	if !ok {
+		return false, ""
+	}
+	check, err := strconv.ParseFloat(strings.TrimSpace(ruleData), 64)
+	if err != nil || n < check {
+		return false, ""
+	}
+	return true, ruleData
+}
+
+func LTE(data interface{}, ruleData string) (res bool, hitData string) {
+	n, ok := typedToFloat(data)
+	if !ok {
+		return false, ""
+	}
+	check, err := strconv.ParseFloat(strings.TrimSpace(ruleData), 64)
+	if err != nil || n > check {
+		return false, ""
+	}
+	return true, ruleData
+}
+
+func LEN_MT(data interface{}, ruleData string) (res bool, hitData string) {
+	check, err := strconv.Atoi(strings.TrimSpace(ruleData))
+	if err != nil {
+		return false, ""
+	}
+	return typedLength(data) > check, ruleData
+}
+
+func LEN_LT(data interface{}, ruleData string) (res bool, hitData string) {
+	check, err := strconv.Atoi(strings.TrimSpace(ruleData))
+	if err != nil {
+		return false, ""
+	}
+	return typedLength(data) < check, ruleData
+}
+
+func CIDR(data interface{}, ruleData string) (res bool, hitData string) {
+	s, ok := data.(string)
+	if !ok {
+		return false, ""
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return false, ""
+	}
+	prefix, ok := parseCIDRValue(strings.TrimSpace(ruleData))
+	if !ok {
+		return false, ""
+	}
+	if prefix.Contains(addr.Unmap()) {
+		return true, s
+	}
+	return false, ""
+}
+
+func TYPE(data interface{}, ruleData string) (res bool, hitData string) {
+	return typedTypeName(data) == strings.TrimSpace(ruleData), ruleData
+}
@@ -0,0 +1,151 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTypedCheckFuncs(t *testing.T) {
+	tests := []struct {
+		checkType string
+		value     string
+		delimiter string
+		data      interface{}
+		expected  bool
+	}{
+		{"GTE", "10", "", 10.0, true},
+		{"GTE", "10", "", 9.5, false},
+		{"GTE", "10", "", "11", true},
+		{"GTE", "10", "", int64(100), true},
+		{"GTE", "10", "", json.Number("10"), true},
+		{"GTE", "10", "", "ten", false},
+		{"GTE", "10", "", true, false},
+		{"GTE", "-1.5", "", -1.5, true},
+		{"LTE", "10", "", 10.0, true},
+		{"LTE", "10", "", 10.01, false},
+		{"BETWEEN", "1,5", "", 1.0, true},
+		{"BETWEEN", "1,5", "", 5.0, true},
+		{"BETWEEN", "1,5", "", 5.5, false},
+		{"BETWEEN", "1|5", "|", "3", true},
+		{"IN", "admin, root", "", "root", true},
+		{"IN", "admin,root", "", "Root", false},
+		{"IN", "admin,root", "", []interface{}{"guest", "admin"}, true},
+		{"IN", "admin,root", "", []interface{}{"guest"}, false},
+		{"IN", "22,443", "", 22.0, true},
+		{"IN", "22,443", "", "443", true},
+		{"IN", "1", "", 1.0, true},
+		{"IN", "true", "", true, true},
+		{"NOT_IN", "admin,root", "", "guest", true},
+		{"NOT_IN", "admin,root", "", []interface{}{"guest", "root"}, false},
+		{"LEN_MT", "3", "", "abcd", true},
+		{"LEN_MT", "3", "", "日本語", false},
+		{"LEN_MT", "1", "", []interface{}{1.0, 2.0}, true},
+		{"LEN_LT", "2", "", map[string]interface{}{"a": 1.0}, true},
+		{"LEN_LT", "2", "", 123.0, false},
+		{"CIDR", "10.0.0.0/8", "", "10.1.2.3", true},
+		{"CIDR", "10.0.0.0/8", "", "11.1.2.3", false},
+		{"CIDR", "10.0.0.0/8", "", "::ffff:10.1.2.3", true},
+		{"CIDR", "2001:db8::/32", "", "2001:db8::1", true},
+		{"CIDR", "192.168.1.1", "", "192.168.1.1", true},
+		{"CIDR", "10.0.0.0/8", "", "not an ip", false},
+		{"CIDR", "10.0.0.0/8", "", 10.0, false},
+		{"TYPE", "number", "", 1.0, true},
+		{"TYPE", "number", "", "1", false},
+		{"TYPE", "string", "", "1", true},
+		{"TYPE", "bool", "", false, true},
+		{"TYPE", "array", "", []interface{}{}, true},
+		{"TYPE", "object", "", map[string]interface{}{}, true},
+		{"TYPE", "object", "", nil, false},
+	}
+
+	for _, test := range tests {
+		node := &CheckNodes{Type: test.checkType, Value: test.value, Delimiter: test.delimiter}
+		f, err := buildTypedCheckFunc(node)
+		if err != nil {
+			t.Errorf("buildTypedCheckFunc(%s %q) failed: %v", test.checkType, test.value, err)
+			continue
+		}
+		if res, _ := f(test.data, test.value); res != test.expected {
+			t.Errorf("%s %q on %#v = %v, expected %v", test.checkType, test.value, test.data, res, test.expected)
+		}
+	}
+}
+
+func TestBuildTypedCheckFuncErrors(t *testing.T) {
+	tests := []struct {
+		node CheckNodes
+		err  string
+	}{
+		{CheckNodes{Type: "GTE", Value: "ten"}, "must be a number"},
+		{CheckNodes{Type: "GTE", Value: "1|x", Logic: "OR", Delimiter: "|"}, "must be a number"},
+		{CheckNodes{Type: "LEN_MT", Value: "-1"}, "non-negative integer"},
+		{CheckNodes{Type: "CIDR", Value: "10.0.0.0/33"}, "must be a network"},
+		{CheckNodes{Type: "TYPE", Value: "int"}, "must be one of"},
+		{CheckNodes{Type: "BETWEEN", Value: "1"}, "must be 'min,max'"},
+		{CheckNodes{Type: "BETWEEN", Value: "5,1"}, "min cannot be greater than max"},
+		{CheckNodes{Type: "BETWEEN", Value: "a,b"}, "bounds must be numbers"},
+		{CheckNodes{Type: "IN", Value: " , "}, "cannot be empty"},
+		{CheckNodes{Type: "IN", Value: "a|b", Logic: "OR", Delimiter: "|"}, "does not support logic"},
+		{CheckNodes{Type: "NOT_IN", Value: "_$field"}, "cannot reference raw data"},
+	}
+
+	for _, test := range tests {
+		_, err := buildTypedCheckFunc(&test.node)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("buildTypedCheckFunc(%s %q) = %v, expected %q", test.node.Type, test.node.Value, err, test.err)
+		}
+	}
+
+	// Raw data references are resolved per event
+	if _, err := buildTypedCheckFunc(&CheckNodes{Type: "GTE", Value: "_$limit"}); err != nil {
+		t.Errorf("buildTypedCheckFunc(GTE _$limit) = %v", err)
+	}
+}
+
+// TestTypedChecksInRuleset runs typed checks on the original values of events
+func TestTypedChecksInRuleset(t *testing.T) {
+	prevConfig := common.Config
+	common.Config = &common.HubConfig{ConfigRoot: t.TempDir()}
+	defer func() { common.Config = prevConfig }()
+
+	ruleset := `<root type="DETECTION">
+    <rule id="large_transfer">
+        <check type="GTE" field="bytes">_$limit</check>
+        <check type="CIDR" field="dst_ip">10.0.0.0/8</check>
+        <check type="TYPE" field="tags">array</check>
+        <check type="NOT_IN" field="user">backup,svc</check>
+    </rule>
+</root>`
+	cases, err := ParseTestCases([]byte(`tests:
+  - name: match
+    data: {bytes: 2000, limit: 1000, dst_ip: 10.0.0.5, tags: [a], user: alice}
+    hits: [large_transfer]
+  - name: number compared as number
+    data: {bytes: 900, limit: 1000, dst_ip: 10.0.0.5, tags: [a], user: alice}
+    hits: []
+  - name: outside network
+    data: {bytes: 2000, limit: 1000, dst_ip: 192.168.0.5, tags: [a], user: alice}
+    hits: []
+  - name: tags not an array
+    data: {bytes: 2000, limit: 1000, dst_ip: 10.0.0.5, tags: a, user: alice}
+    hits: []
+  - name: excluded user
+    data: {bytes: 2000, limit: 1000, dst_ip: 10.0.0.5, tags: [a], user: svc}
+    hits: []
+  - name: missing field never matches
+    data: {bytes: 2000, limit: 1000, dst_ip: 10.0.0.5, tags: [a]}
+    hits: []
+`))
+	if err != nil {
+		t.Fatalf("ParseTestCases failed: %v", err)
+	}
+	report, err := RunTestCases("transfer", ruleset, cases)
+	if err != nil {
+		t.Fatalf("RunTestCases failed: %v", err)
+	}
+	if err := report.Error(); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,12 +3,14 @@ package rules_engine
 import (
 	"AgentSmith-HUB/common"
 	"AgentSmith-HUB/logger"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	regexp "github.com/BurntSushi/rure-go"
+	"github.com/redis/go-redis/v9"
 )
 
 // RedisFRQSum performs frequency sum aggregation using Redis
@@ -16,19 +18,21 @@ import (
 // sumData: Value to add to the sum
 // rangeInt: Time range in seconds
 // threshold: Threshold value to trigger
-// Returns: true if threshold is exceeded, false otherwise
-func RedisFRQSum(groupByKey string, sumData int, rangeInt int, threshold int) (bool, error) {
+// Returns: true if threshold is exceeded, false otherwise, and the current sum
+func RedisFRQSum(groupByKey string, sumData int, rangeInt int, threshold int) (bool, int, error) {
 	var res = false
 	redisSetNXRes, err := common.RedisSetNX(groupByKey, sumData, rangeInt)
 	if err != nil {
-		return false, fmt.Errorf("failed to set Redis key %s: %w", groupByKey, err)
+		return false, 0, fmt.Errorf("failed to set Redis key %s: %w", groupByKey, err)
 	}
 
+	total := sumData
 	if !redisSetNXRes {
 		groupByValue, err := common.RedisIncrby(groupByKey, int64(sumData))
 		if err != nil {
-			return false, fmt.Errorf("failed to increment Redis key %s: %w", groupByKey, err)
+			return false, 0, fmt.Errorf("failed to increment Redis key %s: %w", groupByKey, err)
 		} else {
+			total = int(groupByValue)
 			if groupByValue > int64(threshold) {
 				res = true
 				if err := common.RedisDel(groupByKey); err != nil {
@@ -37,7 +41,7 @@ func RedisFRQSum(groupByKey string, sumData int, rangeInt int, threshold int) (b
 			}
 		}
 	}
-	return res, nil
+	return res, total, nil
 }
 
 // LocalCacheFRQSum performs frequency sum aggregation using local cache
@@ -46,21 +50,21 @@ func RedisFRQSum(groupByKey string, sumData int, rangeInt int, threshold int) (b
 // sumData: Value to add to the sum
 // rangeInt: Time range in seconds
 // threshold: Threshold value to trigger
-// Returns: true if threshold is exceeded, false otherwise
-func (r *Ruleset) LocalCacheFRQSum(groupByKey string, sumData int, rangeInt int, threshold int) (bool, error) {
+// Returns: true if threshold is exceeded, false otherwise, and the current sum
+func (r *Ruleset) LocalCacheFRQSum(groupByKey string, sumData int, rangeInt int, threshold int) (bool, int, error) {
 	if v, ok := r.Cache.Get(groupByKey); ok {
 		if v+sumData > threshold {
 			r.Cache.Del(groupByKey)
-			return true, nil
+			return true, v + sumData, nil
 		} else {
 			if tmpTtl, exist := r.Cache.GetTTL(groupByKey); exist {
 				r.Cache.SetWithTTL(groupByKey, v+sumData, 0, tmpTtl)
 			}
-			return false, nil
+			return false, v + sumData, nil
 		}
 	} else {
 		r.Cache.SetWithTTL(groupByKey, sumData, 0, time.Duration(rangeInt)*time.Second)
-		return false, nil
+		return false, sumData, nil
 	}
 }
 
@@ -69,17 +73,17 @@ func (r *Ruleset) LocalCacheFRQSum(groupByKey string, sumData int, rangeInt int,
 // groupByKey: Base key for grouping
 // rangeInt: Time range in seconds
 // threshold: Threshold value to trigger
-// Returns: true if threshold is exceeded, false otherwise
-func RedisFRQClassify(tmpKey string, groupByKey string, rangeInt int, threshold int) (bool, error) {
+// Returns: true if threshold is exceeded, false otherwise, and the number of distinct values
+func RedisFRQClassify(tmpKey string, groupByKey string, rangeInt int, threshold int) (bool, int, error) {
 	var res = false
 	_, err := common.RedisSet(tmpKey, 1, rangeInt)
 	if err != nil {
-		return false, fmt.Errorf("failed to set Redis key %s: %w", tmpKey, err)
+		return false, 0, fmt.Errorf("failed to set Redis key %s: %w", tmpKey, err)
 	}
 
 	tmpRes, err := common.RedisKeys(groupByKey + "*")
 	if err != nil {
-		return false, fmt.Errorf("failed to get Redis keys matching %s*: %w", groupByKey, err)
+		return false, 0, fmt.Errorf("failed to get Redis keys matching %s*: %w", groupByKey, err)
 	}
 
 	if len(tmpRes) > threshold {
@@ -90,10 +94,10 @@ func RedisFRQClassify(tmpKey string, groupByKey string, rangeInt int, threshold
 			}
 		}
 	}
-	return res, nil
+	return res, len(tmpRes), nil
 }
 
-func (r *Ruleset) LocalCacheFRQClassify(tmpKey string, groupByKey string, rangeInt int, threshold int) (bool, error) {
+func (r *Ruleset) LocalCacheFRQClassify(tmpKey string, groupByKey string, rangeInt int, threshold int) (bool, int, error) {
 	r.CacheMu.Lock()
 	defer r.CacheMu.Unlock()
 
@@ -111,12 +115,12 @@ func (r *Ruleset) LocalCacheFRQClassify(tmpKey string, groupByKey string, rangeI
 				r.Cache.Del(key)
 			}
 			r.CacheForClassify.Del(groupByKey)
-			return true, nil
+			return true, count, nil
 		} else {
 			keys[tmpKey] = true
 			r.CacheForClassify.SetWithTTL(groupByKey, keys, 0, time.Duration(rangeInt*2)*time.Second)
 			r.Cache.SetWithTTL(tmpKey, 1, 0, time.Duration(rangeInt)*time.Second)
-			return false, nil
+			return false, count, nil
 		}
 	} else {
 		keys := map[string]bool{
@@ -124,8 +128,227 @@ func (r *Ruleset) LocalCacheFRQClassify(tmpKey string, groupByKey string, rangeI
 		}
 		r.Cache.SetWithTTL(tmpKey, 1, 0, time.Duration(rangeInt)*time.Second)
 		r.CacheForClassify.SetWithTTL(groupByKey, keys, 0, time.Duration(rangeInt*2)*time.Second)
+		return false, 1, nil
+	}
+}
+
+// RedisFRQDistinct estimates distinct values with a Redis HyperLogLog (PFADD/PFCOUNT)
+// groupByKey: Redis key for grouping
+// member: Value to count
+// rangeInt: Time range in seconds
+// threshold: Threshold value to trigger
+// Returns: true if the estimated distinct count exceeds threshold, and the estimate
+func RedisFRQDistinct(groupByKey string, member string, rangeInt int, threshold int) (bool, int, error) {
+	count, err := common.RedisPFAddCount(groupByKey, member, rangeInt)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to add to Redis HyperLogLog %s: %w", groupByKey, err)
+	}
+
+	if count > int64(threshold) {
+		if err := common.RedisDel(groupByKey); err != nil {
+			logger.Error("failed to delete Redis key %s: %v", groupByKey, err)
+		}
+		return true, int(count), nil
+	}
+	return false, int(count), nil
+}
+
+// LocalCacheFRQDistinct estimates distinct values with a local HyperLogLog
+// Memory per group is fixed (4KB) no matter how many distinct values are seen
+func (r *Ruleset) LocalCacheFRQDistinct(groupByKey string, member string, rangeInt int, threshold int) (bool, int, error) {
+	r.CacheMu.Lock()
+	defer r.CacheMu.Unlock()
+
+	hll, ok := r.CacheForDistinct.Get(groupByKey)
+	if !ok {
+		hll = common.NewHyperLogLog()
+		r.CacheForDistinct.SetWithTTL(groupByKey, hll, hll.Size(), time.Duration(rangeInt)*time.Second)
+		// Make the new counter visible to the next event, it is updated in place afterwards
+		r.CacheForDistinct.Wait()
+	}
+
+	hll.Add(member)
+	count := int(hll.Count())
+	if count > threshold {
+		r.CacheForDistinct.Del(groupByKey)
+		return true, count, nil
+	}
+	return false, count, nil
+}
+
+// aggregateThresholdHit compares the aggregate selected by countType with threshold.
+// AVG and MAX trigger when above threshold, MIN triggers when below it.
+func aggregateThresholdHit(countType string, stats common.AggregateStats, threshold int) (bool, float64) {
+	switch countType {
+	case "AVG":
+		avg := stats.Avg()
+		return avg > float64(threshold), avg
+	case "MAX":
+		return stats.Max > float64(threshold), stats.Max
+	case "MIN":
+		return stats.Min < float64(threshold), stats.Min
+	}
+	return false, 0
+}
+
+// RedisFRQAggregate maintains AVG/MAX/MIN of a numeric field using Redis
+// groupByKey: Redis key for grouping
+// countType: AVG, MAX or MIN
+// value: Value of the current event
+// rangeInt: Time range in seconds
+// threshold: Threshold value to trigger
+// Returns: true if the aggregate crosses threshold, and the aggregate
+func RedisFRQAggregate(groupByKey string, countType string, value float64, rangeInt int, threshold int) (bool, float64, error) {
+	stats, err := common.RedisAggregateAdd(groupByKey, value, rangeInt)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to update Redis aggregate %s: %w", groupByKey, err)
+	}
+
+	hit, aggregate := aggregateThresholdHit(countType, stats, threshold)
+	if hit {
+		if err := common.RedisDel(groupByKey); err != nil {
+			logger.Error("failed to delete Redis key %s: %v", groupByKey, err)
+		}
+	}
+	return hit, aggregate, nil
+}
+
+// LocalCacheFRQAggregate maintains AVG/MAX/MIN of a numeric field using local cache
+func (r *Ruleset) LocalCacheFRQAggregate(groupByKey string, countType string, value float64, rangeInt int, threshold int) (bool, float64, error) {
+	r.CacheMu.Lock()
+	defer r.CacheMu.Unlock()
+
+	stats, ok := r.CacheForAggregate.Get(groupByKey)
+	if !ok {
+		stats = &common.AggregateStats{}
+		r.CacheForAggregate.SetWithTTL(groupByKey, stats, 1, time.Duration(rangeInt)*time.Second)
+		// Make the new aggregate visible to the next event, it is updated in place afterwards
+		r.CacheForAggregate.Wait()
+	}
+
+	stats.Add(value)
+	hit, aggregate := aggregateThresholdHit(countType, *stats, threshold)
+	if hit {
+		r.CacheForAggregate.Del(groupByKey)
+	}
+	return hit, aggregate, nil
+}
+
+// nextSequenceState records one matching event against the current step of a sequence
+// Returns the updated state and whether the last step has been completed
+func nextSequenceState(state SequenceState, steps []SequenceStep) (SequenceState, bool) {
+	state.Count++
+	if state.Count >= steps[state.Step].Count {
+		state.Step++
+		state.Count = 0
+	}
+	return state, state.Step >= len(steps)
+}
+
+// LocalCacheSequence advances a sequence using local cache
+// groupByKey: Cache key for grouping
+// steps: Ordered sequence steps
+// rangeInt: Time window in seconds, starting from the first matched step
+// match: Evaluates the current event against a step
+// Returns: true if the event completes the sequence, false otherwise
+func (r *Ruleset) LocalCacheSequence(groupByKey string, steps []SequenceStep, rangeInt int, match func(step *SequenceStep) bool) (bool, error) {
+	r.SequenceMu.Lock()
+	defer r.SequenceMu.Unlock()
+
+	state, exist := r.CacheForSequence.Get(groupByKey)
+	if !exist || state.Step >= len(steps) {
+		state = SequenceState{}
+		exist = false
+	}
+
+	if !match(&steps[state.Step]) {
 		return false, nil
 	}
+
+	state, completed := nextSequenceState(state, steps)
+	if completed {
+		r.CacheForSequence.Del(groupByKey)
+		return true, nil
+	}
+
+	if exist {
+		if tmpTtl, ok := r.CacheForSequence.GetTTL(groupByKey); ok {
+			r.CacheForSequence.SetWithTTL(groupByKey, state, 0, tmpTtl)
+		}
+	} else {
+		r.CacheForSequence.SetWithTTL(groupByKey, state, 0, time.Duration(rangeInt)*time.Second)
+	}
+	// The state is a value, the next event must see this one
+	r.CacheForSequence.Wait()
+	return false, nil
+}
+
+// Advance a sequence by one event. Every step is passed with the events it requires and whether this event
+// matches it, so that the state is read and written in one step and concurrent events can't overwrite each other.
+// KEYS: sequence key. ARGV: window, then per step: count, matched (1/0).
+// Returns 1 when the event completes the sequence
+var redisSequenceScript = redis.NewScript(`
+local steps = (#ARGV - 1) / 2
+local step, count, exists = 0, 0, false
+local raw = redis.call("GET", KEYS[1])
+if raw then
+	local s, c = string.match(raw, "^(%d+):(%d+)$")
+	s, c = tonumber(s), tonumber(c)
+	if s and c and s < steps then
+		step, count, exists = s, c, true
+	end
+end
+if ARGV[3 + step * 2] ~= "1" then
+	return 0
+end
+count = count + 1
+if count >= tonumber(ARGV[2 + step * 2]) then
+	step, count = step + 1, 0
+end
+if step >= steps then
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+local state = step .. ":" .. count
+local window = tonumber(ARGV[1])
+if exists then
+	redis.call("SET", KEYS[1], state, "KEEPTTL")
+elseif window > 0 then
+	redis.call("SET", KEYS[1], state, "EX", window)
+else
+	redis.call("SET", KEYS[1], state)
+end
+return 0
+`)
+
+// RedisSequence advances a sequence using Redis, state is stored as "step:count"
+// The event is evaluated against every step, the script picks the one the sequence is waiting for
+// groupByKey: Redis key for grouping
+// steps: Ordered sequence steps
+// rangeInt: Time window in seconds, starting from the first matched step
+// match: Evaluates the current event against a step
+// Returns: true if the event completes the sequence, false otherwise
+func RedisSequence(groupByKey string, steps []SequenceStep, rangeInt int, match func(step *SequenceStep) bool) (bool, error) {
+	args := make([]interface{}, 0, 1+len(steps)*2)
+	args = append(args, rangeInt)
+	matchAny := false
+	for i := range steps {
+		matched := 0
+		if match(&steps[i]) {
+			matched = 1
+			matchAny = true
+		}
+		args = append(args, steps[i].Count, matched)
+	}
+	if !matchAny {
+		return false, nil
+	}
+
+	completed, err := redisSequenceScript.Run(context.Background(), common.GetRedisClient(), []string{groupByKey}, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to advance Redis sequence %s: %w", groupByKey, err)
+	}
+	return completed == 1, nil
 }
 
 // convertPluginArgument preserves all types for plugin consumption
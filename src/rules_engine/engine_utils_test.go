@@ -0,0 +1,110 @@
+package rules_engine
+
+import (
+	"AgentSmith-HUB/common"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+func TestLocalCacheSequence(t *testing.T) {
+	cache, err := ristretto.NewCache(&ristretto.Config[string, SequenceState]{NumCounters: 1000, MaxCost: 1000, BufferItems: 64})
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	defer cache.Close()
+	r := &Ruleset{CacheForSequence: cache}
+
+	steps := []SequenceStep{{ID: "fail", Count: 3}, {ID: "success", Count: 1}}
+	events := []string{"fail", "success", "fail", "fail", "fail", "success", "fail", "fail", "fail", "success"}
+	expected := []bool{false, false, false, false, false, true, false, false, false, true}
+
+	for i, event := range events {
+		completed, err := r.LocalCacheSequence("key", steps, 60, func(step *SequenceStep) bool { return step.ID == event })
+		if err != nil {
+			t.Fatalf("event %d: LocalCacheSequence failed: %v", i, err)
+		}
+		if completed != expected[i] {
+			t.Errorf("event %d (%s): completed = %v, expected %v", i, event, completed, expected[i])
+		}
+	}
+}
+
+func TestRedisSequence(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	steps := []SequenceStep{{ID: "fail", Count: 2}, {ID: "success", Count: 1}}
+	match := func(event string) func(step *SequenceStep) bool {
+		return func(step *SequenceStep) bool { return step.ID == event }
+	}
+
+	for i, event := range []string{"fail", "fail"} {
+		if completed, err := RedisSequence("seq", steps, 60, match(event)); err != nil || completed {
+			t.Fatalf("event %d: RedisSequence() = %v, %v, expected not completed", i, completed, err)
+		}
+	}
+	if state, _ := mr.Get("seq"); state != "1:0" {
+		t.Errorf("state = %q, expected 1:0", state)
+	}
+
+	// The window starts with the first step, later events keep it
+	mr.FastForward(30 * time.Second)
+	if ttl := mr.TTL("seq"); ttl != 30*time.Second {
+		t.Errorf("TTL = %v, expected 30s", ttl)
+	}
+	if completed, err := RedisSequence("seq", steps, 60, match("success")); err != nil || !completed {
+		t.Fatalf("RedisSequence() = %v, %v, expected completed", completed, err)
+	}
+	if mr.Exists("seq") {
+		t.Error("completed sequence is still stored")
+	}
+
+	mr.Set("seq", "corrupted")
+	if completed, err := RedisSequence("seq", steps, 60, match("fail")); err != nil || completed {
+		t.Fatalf("RedisSequence() on a corrupted state = %v, %v", completed, err)
+	}
+	if state, _ := mr.Get("seq"); state != "0:1" || mr.TTL("seq") != time.Minute {
+		t.Errorf("state = %q with TTL %v, expected a new sequence", state, mr.TTL("seq"))
+	}
+}
+
+func TestRedisSequenceConcurrentEvents(t *testing.T) {
+	mr := miniredis.RunT(t)
+	if err := common.RedisInit(mr.Addr(), ""); err != nil {
+		t.Fatalf("RedisInit failed: %v", err)
+	}
+	steps := []SequenceStep{{ID: "a", Count: 1}, {ID: "b", Count: 1}}
+	matchAll := func(step *SequenceStep) bool { return true }
+
+	// Every second event completes the sequence, lost updates would change the count
+	const workers, events = 8, 50
+	var completions atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				completed, err := RedisSequence("concurrent", steps, 60, matchAll)
+				if err != nil {
+					t.Errorf("RedisSequence failed: %v", err)
+					return
+				}
+				if completed {
+					completions.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := completions.Load(); n != workers*events/2 {
+		t.Errorf("%d completions for %d events, expected %d", n, workers*events, workers*events/2)
+	}
+}
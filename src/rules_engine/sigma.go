@@ -0,0 +1,757 @@
+package rules_engine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SigmaConvertOptions controls how Sigma rules are converted to a HUB ruleset
+type SigmaConvertOptions struct {
+	Name         string            `json:"name"`          // ruleset name attribute
+	Author       string            `json:"author"`        // ruleset author attribute
+	FieldMapping map[string]string `json:"field_mapping"` // Sigma field name -> HUB field path
+}
+
+// SigmaRuleReport describes the conversion of one Sigma rule
+type SigmaRuleReport struct {
+	Index       int      `json:"index"` // position of the YAML document in the input, from 0
+	SigmaID     string   `json:"sigma_id,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	RuleID      string   `json:"rule_id,omitempty"` // id of the generated <rule>
+	Converted   bool     `json:"converted"`
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// SigmaConversionResult holds the generated ruleset and a report per Sigma rule.
+// Rules using unsupported constructs are skipped as a whole rather than partially converted.
+type SigmaConversionResult struct {
+	XML       string            `json:"xml"`
+	Rules     []SigmaRuleReport `json:"rules"`
+	Converted int               `json:"converted"`
+	Skipped   int               `json:"skipped"`
+}
+
+type sigmaCheck struct {
+	ID        string
+	Type      string
+	Field     string
+	Logic     string
+	Delimiter string
+	Value     string
+}
+
+type sigmaRuleConverter struct {
+	fieldMapping map[string]string
+	detection    map[string]interface{}
+	checks       []sigmaCheck
+	selections   map[string]string // selection name -> condition expression, converted on first use
+	unsupported  []string
+}
+
+var (
+	sigmaRuleIDRegex       = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+	sigmaConditionTokRegex = regexp.MustCompile(`\(|\)|[^\s()]+`)
+	sigmaDelimiters        = []string{"|", ",", ";", "~", "^", "#"}
+)
+
+// ConvertSigma converts one or more Sigma rules (multi-document YAML) into ruleset XML
+func ConvertSigma(data string, opts SigmaConvertOptions) (*SigmaConversionResult, error) {
+	var docs []map[string]interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Sigma YAML: %w", err)
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no Sigma rule found in input")
+	}
+
+	result := &SigmaConversionResult{Rules: []SigmaRuleReport{}}
+	var rulesXML bytes.Buffer
+	usedIDs := make(map[string]bool)
+
+	for i, doc := range docs {
+		report := SigmaRuleReport{Index: i}
+		report.SigmaID, _ = doc["id"].(string)
+		report.Title, _ = doc["title"].(string)
+
+		conv := &sigmaRuleConverter{
+			fieldMapping: opts.FieldMapping,
+			selections:   make(map[string]string),
+		}
+		condition := conv.convertRule(doc)
+		if len(conv.unsupported) > 0 {
+			report.Unsupported = conv.unsupported
+			result.Rules = append(result.Rules, report)
+			result.Skipped++
+			continue
+		}
+
+		report.RuleID = uniqueSigmaRuleID(doc, i, usedIDs)
+		report.Converted = true
+		result.Rules = append(result.Rules, report)
+		result.Converted++
+
+		level, _ := doc["level"].(string)
+		writeSigmaRule(&rulesXML, report, level, condition, conv.checks)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(`<root type="DETECTION"`)
+	if opts.Name != "" {
+		fmt.Fprintf(&out, ` name="%s"`, sigmaXMLEscape(opts.Name))
+	}
+	if opts.Author != "" {
+		fmt.Fprintf(&out, ` author="%s"`, sigmaXMLEscape(opts.Author))
+	}
+	out.WriteString(">\n")
+	out.Write(rulesXML.Bytes())
+	out.WriteString("</root>\n")
+	result.XML = out.String()
+
+	return result, nil
+}
+
+// convertRule converts the detection section and returns the checklist condition
+func (c *sigmaRuleConverter) convertRule(doc map[string]interface{}) string {
+	if _, ok := doc["correlation"]; ok {
+		c.unsupport("correlation rules")
+		return ""
+	}
+	if action, ok := doc["action"].(string); ok && action != "" {
+		c.unsupport(fmt.Sprintf("rule collection action '%s'", action))
+		return ""
+	}
+
+	detection, ok := doc["detection"].(map[string]interface{})
+	if !ok {
+		c.unsupport("missing or invalid detection section")
+		return ""
+	}
+	c.detection = detection
+
+	if _, ok := detection["timeframe"]; ok {
+		c.unsupport("timeframe")
+	}
+
+	var conditions []string
+	switch cond := detection["condition"].(type) {
+	case string:
+		conditions = []string{cond}
+	case []interface{}:
+		for _, item := range cond {
+			s, ok := item.(string)
+			if !ok {
+				c.unsupport("non-string condition")
+				return ""
+			}
+			conditions = append(conditions, s)
+		}
+	default:
+		c.unsupport("missing or invalid condition")
+		return ""
+	}
+
+	var exprs []string
+	for _, cond := range conditions {
+		if strings.Contains(cond, "|") {
+			c.unsupport(fmt.Sprintf("aggregation in condition '%s'", cond))
+			continue
+		}
+		p := &sigmaConditionParser{conv: c, tokens: sigmaConditionTokRegex.FindAllString(cond, -1)}
+		expr := p.parseOr()
+		if p.err == nil && p.pos < len(p.tokens) {
+			p.err = fmt.Errorf("unexpected '%s'", p.tokens[p.pos])
+		}
+		if p.err != nil {
+			c.unsupport(fmt.Sprintf("condition '%s': %v", cond, p.err))
+			continue
+		}
+		exprs = append(exprs, expr)
+	}
+	return joinSigmaExpr("or", exprs)
+}
+
+func (c *sigmaRuleConverter) unsupport(what string) {
+	c.unsupported = append(c.unsupported, what)
+}
+
+// selectionExpr returns the expression of a detection identifier, converting its checks on first use
+func (c *sigmaRuleConverter) selectionExpr(name string) (string, bool) {
+	if expr, ok := c.selections[name]; ok {
+		return expr, true
+	}
+	value, ok := c.detection[name]
+	if !ok || name == "condition" || name == "timeframe" {
+		return "", false
+	}
+
+	var expr string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		expr = c.mapExpr(name, v)
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				c.unsupport(fmt.Sprintf("keyword search in '%s' (values without a field)", name))
+				break
+			}
+			parts = append(parts, c.mapExpr(name, m))
+		}
+		expr = joinSigmaExpr("or", parts)
+	default:
+		c.unsupport(fmt.Sprintf("invalid detection '%s'", name))
+	}
+
+	c.selections[name] = expr
+	return expr, true
+}
+
+// mapExpr converts a field map, all fields must match
+func (c *sigmaRuleConverter) mapExpr(name string, m map[string]interface{}) string {
+	if len(m) == 0 {
+		c.unsupport(fmt.Sprintf("empty detection '%s'", name))
+		return ""
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		if expr := c.fieldExpr(k, m[k]); expr != "" {
+			parts = append(parts, expr)
+		}
+	}
+	return joinSigmaExpr("and", parts)
+}
+
+// fieldExpr converts "field|modifiers: values" into one or more checks
+func (c *sigmaRuleConverter) fieldExpr(key string, value interface{}) string {
+	parts := strings.Split(key, "|")
+	field := parts[0]
+	if field == "" {
+		c.unsupport(fmt.Sprintf("keyword search '%s' (no field)", key))
+		return ""
+	}
+	if mapped, ok := c.fieldMapping[field]; ok && mapped != "" {
+		field = mapped
+	}
+
+	var op, reFlags string
+	var all, cased bool
+	for _, mod := range parts[1:] {
+		switch mod {
+		case "contains", "startswith", "endswith", "re", "gt", "gte", "lt", "lte", "exists":
+			if op != "" {
+				c.unsupport(fmt.Sprintf("modifier combination '%s'", key))
+				return ""
+			}
+			op = mod
+		case "all":
+			all = true
+		case "cased":
+			cased = true
+		case "i", "m", "s":
+			if op != "re" {
+				c.unsupport(fmt.Sprintf("modifier '%s' without re in '%s'", mod, key))
+				return ""
+			}
+			reFlags += mod
+		default:
+			c.unsupport(fmt.Sprintf("modifier '%s'", mod))
+			return ""
+		}
+	}
+
+	var values []interface{}
+	if list, ok := value.([]interface{}); ok {
+		values = list
+	} else {
+		values = []interface{}{value}
+	}
+	if len(values) == 0 {
+		c.unsupport(fmt.Sprintf("empty value list for '%s'", key))
+		return ""
+	}
+
+	if op == "exists" {
+		exists, ok := values[0].(bool)
+		if !ok || len(values) != 1 {
+			c.unsupport(fmt.Sprintf("non-boolean exists value for '%s'", key))
+			return ""
+		}
+		if exists {
+			return c.addCheck("NOTNULL", field, "", "", "")
+		}
+		return c.addCheck("ISNULL", field, "", "", "")
+	}
+
+	// Group values by check type so that each group becomes a single multi-value check
+	groups := make(map[string][]string)
+	var order []string
+	var exprs []string
+	for _, v := range values {
+		if v == nil {
+			if op != "" {
+				c.unsupport(fmt.Sprintf("null value with modifier in '%s'", key))
+				return ""
+			}
+			exprs = append(exprs, c.addCheck("ISNULL", field, "", "", ""))
+			continue
+		}
+		s, ok := sigmaScalar(v)
+		if !ok {
+			c.unsupport(fmt.Sprintf("non-scalar value in '%s'", key))
+			return ""
+		}
+
+		checkType, pattern, err := sigmaValueCheck(op, s, cased, reFlags)
+		if err != nil {
+			c.unsupport(fmt.Sprintf("'%s': %v", key, err))
+			return ""
+		}
+		if checkType == "" {
+			// gte/lte: greater/less than or equal
+			cmp := "MT"
+			if op == "lte" {
+				cmp = "LT"
+			}
+			exprs = append(exprs, joinSigmaExpr("or", []string{
+				c.addCheck(cmp, field, "", "", pattern),
+				c.addCheck("EQU", field, "", "", pattern),
+			}))
+			continue
+		}
+		if _, ok := groups[checkType]; !ok {
+			order = append(order, checkType)
+		}
+		groups[checkType] = append(groups[checkType], pattern)
+	}
+
+	joinOp := "or"
+	if all {
+		joinOp = "and"
+	}
+	for _, checkType := range order {
+		exprs = append(exprs, c.groupExpr(checkType, field, joinOp, groups[checkType]))
+	}
+	return joinSigmaExpr(joinOp, exprs)
+}
+
+// groupExpr emits the checks for values of the same type
+func (c *sigmaRuleConverter) groupExpr(checkType, field, joinOp string, patterns []string) string {
+	if len(patterns) == 1 {
+		return c.addCheck(checkType, field, "", "", patterns[0])
+	}
+
+	if checkType == "REGEX" {
+		if joinOp == "or" {
+			alts := make([]string, len(patterns))
+			for i, p := range patterns {
+				alts[i] = "(?:" + p + ")"
+			}
+			return c.addCheck("REGEX", field, "", "", strings.Join(alts, "|"))
+		}
+	} else if checkType != "NOTNULL" {
+		for _, d := range sigmaDelimiters {
+			if !sigmaAnyContains(patterns, d) {
+				return c.addCheck(checkType, field, strings.ToUpper(joinOp), d, strings.Join(patterns, d))
+			}
+		}
+	}
+
+	var parts []string
+	for _, p := range patterns {
+		parts = append(parts, c.addCheck(checkType, field, "", "", p))
+	}
+	return joinSigmaExpr(joinOp, parts)
+}
+
+func (c *sigmaRuleConverter) addCheck(checkType, field, logic, delimiter, value string) string {
+	id := fmt.Sprintf("c%d", len(c.checks)+1)
+	c.checks = append(c.checks, sigmaCheck{
+		ID:        id,
+		Type:      checkType,
+		Field:     field,
+		Logic:     logic,
+		Delimiter: delimiter,
+		Value:     value,
+	})
+	return id
+}
+
+// sigmaValueCheck maps a value and its modifier to a check type and value.
+// An empty check type with no error means a gte/lte comparison the caller expands.
+func sigmaValueCheck(op, value string, cased bool, reFlags string) (string, string, error) {
+	switch op {
+	case "re":
+		if value == "" {
+			return "", "", fmt.Errorf("empty regular expression")
+		}
+		if reFlags != "" {
+			value = "(?" + reFlags + ")" + value
+		}
+		return "REGEX", value, nil
+	case "gt", "lt", "gte", "lte":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", "", fmt.Errorf("non-numeric value '%s' for %s", value, op)
+		}
+		switch op {
+		case "gt":
+			return "MT", value, nil
+		case "lt":
+			return "LT", value, nil
+		}
+		return "", value, nil
+	case "contains":
+		value = "*" + value + "*"
+	case "startswith":
+		value = value + "*"
+	case "endswith":
+		value = "*" + value
+	}
+	return sigmaWildcardCheck(value, cased)
+}
+
+// sigmaWildcardCheck converts a Sigma wildcard pattern (* and ?, backslash escapes)
+func sigmaWildcardCheck(pattern string, cased bool) (string, string, error) {
+	type segment struct {
+		wildcard rune // 0 for literal text
+		text     string
+	}
+	var segs []segment
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			segs = append(segs, segment{text: lit.String()})
+			lit.Reset()
+		}
+	}
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && (runes[i+1] == '*' || runes[i+1] == '?' || runes[i+1] == '\\'):
+			lit.WriteRune(runes[i+1])
+			i++
+		case r == '*' || r == '?':
+			flush()
+			// Consecutive * collapse into one
+			if r == '*' && len(segs) > 0 && segs[len(segs)-1].wildcard == '*' {
+				continue
+			}
+			segs = append(segs, segment{wildcard: r})
+		default:
+			lit.WriteRune(r)
+		}
+	}
+	flush()
+
+	if len(segs) == 0 {
+		return "ISNULL", "", nil
+	}
+	if len(segs) == 1 && segs[0].wildcard == '*' {
+		return "NOTNULL", "", nil
+	}
+
+	// Simple forms map to string checks: text, *text, text*, *text*
+	var text string
+	var leading, trailing, simple = false, false, true
+	for i, s := range segs {
+		switch {
+		case s.wildcard == 0:
+			if text != "" {
+				simple = false
+			}
+			text = s.text
+		case s.wildcard == '*' && i == 0:
+			leading = true
+		case s.wildcard == '*' && i == len(segs)-1:
+			trailing = true
+		default:
+			simple = false
+		}
+	}
+	// Check values are trimmed and "_$" marks a field reference, such literals go through REGEX
+	if simple && text != "" && strings.TrimSpace(text) == text && !strings.HasPrefix(text, "_$") {
+		prefix := "NCS_"
+		if cased {
+			prefix = ""
+		}
+		switch {
+		case leading && trailing:
+			return prefix + "INCL", text, nil
+		case leading:
+			return prefix + "END", text, nil
+		case trailing:
+			return prefix + "START", text, nil
+		case !cased:
+			// EQU compares case-insensitively as well, cased exact values go through REGEX below
+			return "NCS_EQU", text, nil
+		}
+	}
+
+	var re strings.Builder
+	if cased {
+		re.WriteString("(?s)^")
+	} else {
+		re.WriteString("(?is)^")
+	}
+	for _, s := range segs {
+		switch s.wildcard {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(s.text))
+		}
+	}
+	re.WriteString("$")
+	return "REGEX", re.String(), nil
+}
+
+// sigmaConditionParser parses Sigma conditions into HUB checklist expressions.
+// HUB conditions evaluate left to right without precedence, so every sub-expression is parenthesized.
+type sigmaConditionParser struct {
+	conv   *sigmaRuleConverter
+	tokens []string
+	pos    int
+	err    error
+}
+
+func (p *sigmaConditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *sigmaConditionParser) parseOr() string {
+	parts := []string{p.parseAnd()}
+	for p.err == nil && p.peek() == "or" {
+		p.pos++
+		parts = append(parts, p.parseAnd())
+	}
+	return joinSigmaExpr("or", parts)
+}
+
+func (p *sigmaConditionParser) parseAnd() string {
+	parts := []string{p.parseNot()}
+	for p.err == nil && p.peek() == "and" {
+		p.pos++
+		parts = append(parts, p.parseNot())
+	}
+	return joinSigmaExpr("and", parts)
+}
+
+func (p *sigmaConditionParser) parseNot() string {
+	if p.peek() == "not" {
+		p.pos++
+		operand := p.parseNot()
+		if p.err != nil {
+			return ""
+		}
+		return "not " + operand
+	}
+	return p.parsePrimary()
+}
+
+func (p *sigmaConditionParser) parsePrimary() string {
+	if p.err != nil {
+		return ""
+	}
+	tok := p.peek()
+	switch tok {
+	case "":
+		p.err = fmt.Errorf("unexpected end of condition")
+		return ""
+	case "(":
+		p.pos++
+		expr := p.parseOr()
+		if p.err == nil && p.peek() != ")" {
+			p.err = fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return expr
+	case ")", "and", "or", "of":
+		p.err = fmt.Errorf("unexpected '%s'", tok)
+		return ""
+	case "1", "any", "all":
+		if p.pos+1 < len(p.tokens) && strings.ToLower(p.tokens[p.pos+1]) == "of" {
+			return p.parseQuantifier(tok)
+		}
+	}
+
+	if n, err := strconv.Atoi(tok); err == nil && n > 1 && p.pos+1 < len(p.tokens) && strings.ToLower(p.tokens[p.pos+1]) == "of" {
+		p.err = fmt.Errorf("'%d of' is not supported", n)
+		return ""
+	}
+
+	name := p.tokens[p.pos]
+	p.pos++
+	expr, ok := p.conv.selectionExpr(name)
+	if !ok {
+		p.err = fmt.Errorf("unknown identifier '%s'", name)
+		return ""
+	}
+	return expr
+}
+
+// parseQuantifier handles "1 of x*", "any of them" and "all of x*"
+func (p *sigmaConditionParser) parseQuantifier(quantifier string) string {
+	p.pos += 2 // quantifier and "of"
+	if p.pos >= len(p.tokens) {
+		p.err = fmt.Errorf("missing target after '%s of'", quantifier)
+		return ""
+	}
+	target := p.tokens[p.pos]
+	p.pos++
+
+	var names []string
+	for name := range p.conv.detection {
+		if name == "condition" || name == "timeframe" {
+			continue
+		}
+		if strings.EqualFold(target, "them") {
+			if !strings.HasPrefix(name, "_") {
+				names = append(names, name)
+			}
+		} else if sigmaGlobMatch(target, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		p.err = fmt.Errorf("'%s of %s' matches no detection", quantifier, target)
+		return ""
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		expr, _ := p.conv.selectionExpr(name)
+		parts = append(parts, expr)
+	}
+	if quantifier == "all" {
+		return joinSigmaExpr("and", parts)
+	}
+	return joinSigmaExpr("or", parts)
+}
+
+// sigmaGlobMatch matches detection names against a pattern where * is the only wildcard
+func sigmaGlobMatch(pattern, name string) bool {
+	re := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(re, name)
+	return matched
+}
+
+func joinSigmaExpr(op string, parts []string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return ""
+	case 1:
+		return nonEmpty[0]
+	}
+	return "(" + strings.Join(nonEmpty, " "+op+" ") + ")"
+}
+
+func sigmaScalar(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(val), true
+	}
+	return "", false
+}
+
+func sigmaAnyContains(values []string, sub string) bool {
+	for _, v := range values {
+		if strings.Contains(v, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueSigmaRuleID uses the Sigma id, then the title, making it unique within the ruleset
+func uniqueSigmaRuleID(doc map[string]interface{}, index int, used map[string]bool) string {
+	base, _ := doc["id"].(string)
+	if base == "" {
+		title, _ := doc["title"].(string)
+		base = strings.Trim(sigmaRuleIDRegex.ReplaceAllString(strings.ToLower(title), "_"), "_")
+	}
+	if base == "" {
+		base = fmt.Sprintf("sigma_rule_%d", index+1)
+	}
+	id := base
+	for n := 2; used[id]; n++ {
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+	used[id] = true
+	return id
+}
+
+func writeSigmaRule(buf *bytes.Buffer, report SigmaRuleReport, level, condition string, checks []sigmaCheck) {
+	fmt.Fprintf(buf, "    <rule id=\"%s\"", sigmaXMLEscape(report.RuleID))
+	if report.Title != "" {
+		fmt.Fprintf(buf, " name=\"%s\"", sigmaXMLEscape(report.Title))
+	}
+	buf.WriteString(">\n")
+
+	fmt.Fprintf(buf, "        <checklist condition=\"%s\">\n", sigmaXMLEscape(condition))
+	for _, ch := range checks {
+		fmt.Fprintf(buf, "            <check id=\"%s\" type=\"%s\" field=\"%s\"", ch.ID, ch.Type, sigmaXMLEscape(ch.Field))
+		if ch.Logic != "" {
+			fmt.Fprintf(buf, " logic=\"%s\" delimiter=\"%s\"", ch.Logic, sigmaXMLEscape(ch.Delimiter))
+		}
+		fmt.Fprintf(buf, ">%s</check>\n", sigmaXMLEscape(ch.Value))
+	}
+	buf.WriteString("        </checklist>\n")
+
+	if report.SigmaID != "" {
+		fmt.Fprintf(buf, "        <append field=\"sigma_id\">%s</append>\n", sigmaXMLEscape(report.SigmaID))
+	}
+	if report.Title != "" {
+		fmt.Fprintf(buf, "        <append field=\"sigma_title\">%s</append>\n", sigmaXMLEscape(report.Title))
+	}
+	if level != "" {
+		fmt.Fprintf(buf, "        <append field=\"sigma_level\">%s</append>\n", sigmaXMLEscape(level))
+	}
+	buf.WriteString("    </rule>\n")
+}
+
+func sigmaXMLEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
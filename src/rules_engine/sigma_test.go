@@ -0,0 +1,296 @@
+package rules_engine
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSigmaValueCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		op        string
+		value     string
+		cased     bool
+		reFlags   string
+		checkType string
+		pattern   string
+	}{
+		{"exact is case-insensitive", "", `C:\Windows\System32\cmd.exe`, false, "", "NCS_EQU", `C:\Windows\System32\cmd.exe`},
+		{"exact cased", "", "cmd.exe", true, "", "REGEX", `(?s)^cmd\.exe$`},
+		{"contains", "contains", "powershell", false, "", "NCS_INCL", "powershell"},
+		{"contains cased", "contains", "powershell", true, "", "INCL", "powershell"},
+		{"startswith", "startswith", "/tmp/", false, "", "NCS_START", "/tmp/"},
+		{"startswith cased", "startswith", "/tmp/", true, "", "START", "/tmp/"},
+		{"endswith", "endswith", ".exe", false, "", "NCS_END", ".exe"},
+		{"endswith cased", "endswith", ".exe", true, "", "END", ".exe"},
+		{"re", "re", `^a.*b$`, false, "", "REGEX", `^a.*b$`},
+		{"re with flags", "re", `^a.*b$`, false, "i", "REGEX", `(?i)^a.*b$`},
+		{"gt", "gt", "10", false, "", "MT", "10"},
+		{"lt", "lt", "10", false, "", "LT", "10"},
+		{"gte expanded by the caller", "gte", "10", false, "", "", "10"},
+		{"inner wildcard", "", "a*b", false, "", "REGEX", `(?is)^a.*b$`},
+		{"inner wildcard cased", "", "a?b", true, "", "REGEX", `(?s)^a.b$`},
+		{"escaped wildcard", "", `a\*b`, false, "", "NCS_EQU", "a*b"},
+		{"only wildcard", "", "*", false, "", "NOTNULL", ""},
+		{"empty", "", "", false, "", "ISNULL", ""},
+		{"field reference literal", "", "_$x", false, "", "REGEX", `(?is)^_\$x$`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkType, pattern, err := sigmaValueCheck(test.op, test.value, test.cased, test.reFlags)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if checkType != test.checkType || pattern != test.pattern {
+				t.Errorf("sigmaValueCheck(%q, %q, %v) = %q, %q, expected %q, %q",
+					test.op, test.value, test.cased, checkType, pattern, test.checkType, test.pattern)
+			}
+		})
+	}
+}
+
+func TestSigmaValueCheckErrors(t *testing.T) {
+	if _, _, err := sigmaValueCheck("re", "", false, ""); err == nil {
+		t.Error("expected an error for an empty regular expression")
+	}
+	if _, _, err := sigmaValueCheck("gt", "abc", false, ""); err == nil {
+		t.Error("expected an error for a non-numeric gt value")
+	}
+}
+
+func TestConvertSigmaModifiers(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection string
+		condition string
+		checks    []string // type field logic delimiter value
+	}{
+		{
+			name:      "value list is or",
+			detection: "sel:\n    Image|endswith:\n      - '\\cmd.exe'\n      - '\\powershell.exe'",
+			condition: "c1",
+			checks:    []string{`NCS_END Image OR | \cmd.exe|\powershell.exe`},
+		},
+		{
+			name:      "all is and",
+			detection: "sel:\n    CommandLine|contains|all:\n      - '-enc'\n      - 'bypass'",
+			condition: "c1",
+			checks:    []string{`NCS_INCL CommandLine AND | -enc|bypass`},
+		},
+		{
+			name:      "cased exact",
+			detection: "sel:\n    User|cased: SYSTEM",
+			condition: "c1",
+			checks:    []string{"REGEX User   (?s)^SYSTEM$"},
+		},
+		{
+			name:      "re",
+			detection: "sel:\n    Url|re: '^https?://[0-9.]+/'",
+			condition: "c1",
+			checks:    []string{"REGEX Url   ^https?://[0-9.]+/"},
+		},
+		{
+			name:      "several fields are and",
+			detection: "sel:\n    a: x\n    b|startswith: y",
+			condition: "(c1 and c2)",
+			checks:    []string{"NCS_EQU a   x", "NCS_START b   y"},
+		},
+		{
+			name:      "gte",
+			detection: "sel:\n    count|gte: 5",
+			condition: "(c1 or c2)",
+			checks:    []string{"MT count   5", "EQU count   5"},
+		},
+		{
+			name:      "exists",
+			detection: "sel:\n    a|exists: true",
+			condition: "c1",
+			checks:    []string{"NOTNULL a   "},
+		},
+		{
+			name:      "null",
+			detection: "sel:\n    a: null",
+			condition: "c1",
+			checks:    []string{"ISNULL a   "},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conv := convertTestRule(t, test.detection+"\n  condition: sel")
+			if len(conv.unsupported) > 0 {
+				t.Fatalf("unexpected unsupported: %v", conv.unsupported)
+			}
+			if got := conv.condition; got != test.condition {
+				t.Errorf("condition = %q, expected %q", got, test.condition)
+			}
+			var checks []string
+			for _, ch := range conv.checks {
+				checks = append(checks, strings.Join([]string{ch.Type, ch.Field, ch.Logic, ch.Delimiter, ch.Value}, " "))
+			}
+			if strings.Join(checks, "\n") != strings.Join(test.checks, "\n") {
+				t.Errorf("checks =\n%s\nexpected\n%s", strings.Join(checks, "\n"), strings.Join(test.checks, "\n"))
+			}
+		})
+	}
+}
+
+func TestConvertSigmaConditions(t *testing.T) {
+	detection := "sel_a:\n    a: 1\n  sel_b:\n    b: 2\n  filter:\n    c: 3\n  _hidden:\n    d: 4\n"
+	tests := []struct {
+		condition string
+		expected  string
+	}{
+		{"sel_a", "c1"},
+		{"sel_a and sel_b", "(c1 and c2)"},
+		{"sel_a or sel_b and filter", "(c1 or (c2 and c3))"},
+		{"(sel_a or sel_b) and filter", "((c1 or c2) and c3)"},
+		{"sel_a and not filter", "(c1 and not c2)"},
+		{"NOT sel_a", "not c1"},
+		{"1 of sel_*", "(c1 or c2)"},
+		{"any of sel_*", "(c1 or c2)"},
+		{"all of sel_*", "(c1 and c2)"},
+		{"1 of them", "(c1 or c2 or c3)"},
+		{"all of sel_* and not 1 of filter*", "((c1 and c2) and not c3)"},
+		{"sel_a and sel_a", "(c1 and c1)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			conv := convertTestRule(t, detection+"  condition: "+test.condition)
+			if len(conv.unsupported) > 0 {
+				t.Fatalf("unexpected unsupported: %v", conv.unsupported)
+			}
+			if conv.condition != test.expected {
+				t.Errorf("condition %q = %q, expected %q", test.condition, conv.condition, test.expected)
+			}
+		})
+	}
+}
+
+func TestConvertSigmaUnsupported(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection string
+	}{
+		{"unknown identifier", "sel:\n    a: 1\n  condition: other"},
+		{"missing paren", "sel:\n    a: 1\n  condition: (sel"},
+		{"trailing token", "sel:\n    a: 1\n  condition: sel sel"},
+		{"aggregation", "sel:\n    a: 1\n  condition: sel | count() > 5"},
+		{"n of", "sel:\n    a: 1\n  condition: 2 of sel*"},
+		{"quantifier without match", "sel:\n    a: 1\n  condition: 1 of x*"},
+		{"unknown modifier", "sel:\n    a|base64: x\n  condition: sel"},
+		{"modifier combination", "sel:\n    a|contains|startswith: x\n  condition: sel"},
+		{"keyword search", "sel:\n    - foo\n  condition: sel"},
+		{"timeframe", "sel:\n    a: 1\n  timeframe: 5m\n  condition: sel"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conv := convertTestRule(t, test.detection)
+			if len(conv.unsupported) == 0 {
+				t.Errorf("expected the rule to be unsupported, condition %q", conv.condition)
+			}
+		})
+	}
+}
+
+// TestConvertSigmaMatching runs converted rules on events, Sigma values match case-insensitively
+func TestConvertSigmaMatching(t *testing.T) {
+	rules := `title: Cmd
+id: cmd
+detection:
+  sel:
+    Image:
+      - 'C:\Windows\System32\cmd.exe'
+      - 'C:\Windows\SysWOW64\cmd.exe'
+  condition: sel
+---
+title: Cased
+id: cased
+detection:
+  sel:
+    User|cased: SYSTEM
+  condition: sel
+---
+title: Encoded
+id: encoded
+detection:
+  sel:
+    CommandLine|contains|all:
+      - '-enc'
+      - 'bypass'
+  filter:
+    ParentImage|endswith: '\explorer.exe'
+  condition: sel and not filter
+`
+	result, err := ConvertSigma(rules, SigmaConvertOptions{Name: "sigma"})
+	if err != nil {
+		t.Fatalf("ConvertSigma failed: %v", err)
+	}
+	if result.Converted != 3 || result.Skipped != 0 {
+		t.Fatalf("converted %d, skipped %d, expected 3, 0: %+v", result.Converted, result.Skipped, result.Rules)
+	}
+	if err := Verify("", result.XML); err != nil {
+		t.Fatalf("generated ruleset does not verify: %v\n%s", err, result.XML)
+	}
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		hits []string
+	}{
+		{"exact value, other case", map[string]interface{}{"Image": `c:\windows\system32\CMD.EXE`}, []string{"cmd"}},
+		{"exact value list, other case", map[string]interface{}{"Image": `C:\WINDOWS\syswow64\cmd.exe`}, []string{"cmd"}},
+		{"exact value, other file", map[string]interface{}{"Image": `c:\windows\system32\cmd.exe.bak`}, []string{}},
+		{"cased value", map[string]interface{}{"User": "SYSTEM"}, []string{"cased"}},
+		{"cased value, other case", map[string]interface{}{"User": "system"}, []string{}},
+		{"all values", map[string]interface{}{"CommandLine": "powershell -ENC xx -ep BYPASS", "ParentImage": "cmd.exe"}, []string{"encoded"}},
+		{"one value only", map[string]interface{}{"CommandLine": "powershell -enc xx", "ParentImage": "cmd.exe"}, []string{}},
+		{"filtered", map[string]interface{}{"CommandLine": "powershell -enc xx bypass", "ParentImage": `C:\Windows\Explorer.EXE`}, []string{}},
+	}
+
+	var cases []TestCase
+	for _, test := range tests {
+		hits := test.hits
+		cases = append(cases, TestCase{Name: test.name, Data: test.data, Hits: &hits})
+	}
+	report, err := RunTestCases("sigma", result.XML, cases)
+	if err != nil {
+		t.Fatalf("RunTestCases failed: %v", err)
+	}
+	for _, r := range report.Results {
+		if !r.Passed {
+			t.Errorf("%s: %v", r.Name, r.Failures)
+		}
+	}
+}
+
+type convertedTestRule struct {
+	condition   string
+	checks      []sigmaCheck
+	unsupported []string
+}
+
+func convertTestRule(t *testing.T, detection string) convertedTestRule {
+	t.Helper()
+	result, err := ConvertSigma("title: test\ndetection:\n  "+detection+"\n", SigmaConvertOptions{})
+	if err != nil {
+		t.Fatalf("ConvertSigma failed: %v", err)
+	}
+
+	// Convert again to look at the checks, ConvertSigma only returns XML
+	conv := &sigmaRuleConverter{selections: make(map[string]string)}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte("title: test\ndetection:\n  "+detection+"\n"), &doc); err != nil {
+		t.Fatalf("invalid test YAML: %v", err)
+	}
+	condition := conv.convertRule(doc)
+	if (len(conv.unsupported) > 0) != (result.Skipped == 1) {
+		t.Fatalf("converter state differs from ConvertSigma result: %v, %+v", conv.unsupported, result.Rules)
+	}
+	return convertedTestRule{condition: condition, checks: conv.checks, unsupported: conv.unsupported}
+}
@@ -2,6 +2,7 @@ package rules_engine
 
 import (
 	"AgentSmith-HUB/common"
+	"context"
 	"sync"
 )
 
@@ -110,7 +111,7 @@ func (r *Ruleset) SIMDEnhancedExecuteCheckNode(checkNode *CheckNodes, data map[s
 	}
 
 	// Fallback to original implementation for single checks
-	return r.executeCheckNode(checkNode, data, ruleCache)
+	return r.executeCheckNode(context.Background(), checkNode, data, ruleCache)
 }
 
 // simdExecuteORLogic uses SIMD to process OR logic efficiently
@@ -0,0 +1,490 @@
+package threat_intel
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Threat intelligence feeds are pulled by every node into memory and matched by the isIOC and
+// iocInfo plugins. config.yaml:
+//
+//	threat_intel:
+//	  feeds:
+//	    - name: taxii_osint
+//	      type: taxii                 # TAXII 2.1 collection of STIX 2.1 indicators
+//	      url: https://taxii.example.com/api1/
+//	      collection: 91a7b528-80eb-42ed-a74d-c6fbd5a26116
+//	      username: user
+//	      password: pass
+//	      interval: 1h
+//	    - name: misp
+//	      type: misp                  # MISP event/attribute export or restSearch
+//	      url: https://misp.example.com/events/restSearch
+//	      api_key: xxx
+//	      filters: {last: 30d}
+//	    - name: blocklist
+//	      type: list                  # one IOC per line, or csv with format: csv
+//	      url: https://example.com/ips.txt
+//	      ioc_type: ip
+//	      confidence: 80
+//	      ttl: 2d
+//
+// A failed pull keeps the indicators of the last successful pull until they expire.
+
+// Feed types
+const (
+	FeedTAXII = "taxii"
+	FeedSTIX  = "stix"
+	FeedMISP  = "misp"
+	FeedList  = "list"
+)
+
+const (
+	defaultFeedInterval   = time.Hour
+	defaultFeedTimeout    = 60 * time.Second
+	defaultIndicatorTTL   = 7 * 24 * time.Hour
+	defaultFeedConfidence = 50
+
+	// Largest response body read from a feed
+	maxFeedBodySize = 256 << 20
+)
+
+// FeedStatus describes a feed and its pulls on this node
+type FeedStatus struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	URL         string         `json:"url"`
+	Interval    string         `json:"interval"`
+	Indicators  int            `json:"indicators"`
+	ByType      map[string]int `json:"by_type"`
+	Skipped     int            `json:"skipped"`
+	Pulls       uint64         `json:"pulls"`
+	Failures    uint64         `json:"failures"`
+	LastPull    string         `json:"last_pull,omitempty"`
+	LastSuccess string         `json:"last_success,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	Duration    string         `json:"last_duration,omitempty"`
+}
+
+type feed struct {
+	cfg      common.ThreatFeedConfig
+	interval time.Duration
+	ttl      time.Duration
+	iocType  string // list feeds only
+	client   *http.Client
+
+	data   atomic.Pointer[feedData]
+	pullMu sync.Mutex // serializes scheduled and manual pulls
+
+	// TAXII incremental pulls
+	cursor   string
+	lastFull time.Time
+
+	statusMu sync.Mutex
+	status   FeedStatus
+}
+
+var (
+	feedsMu sync.RWMutex
+	feeds   []*feed
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	hitTotal  uint64
+	missTotal uint64
+)
+
+// Start validates the configured feeds and starts pulling the valid ones, invalid feeds are returned as an error
+func Start(cfgs []common.ThreatFeedConfig) error {
+	Stop()
+
+	var errs []error
+	names := make(map[string]bool)
+	started := make([]*feed, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if names[cfg.Name] {
+			errs = append(errs, fmt.Errorf("threat intel feed '%s' is defined more than once", cfg.Name))
+			continue
+		}
+		f, err := newFeed(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		names[cfg.Name] = true
+		started = append(started, f)
+	}
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	feedsMu.Lock()
+	feeds = started
+	cancel = cancelFn
+	feedsMu.Unlock()
+
+	for _, f := range started {
+		wg.Add(1)
+		go f.run(ctx)
+	}
+	if len(started) > 0 {
+		logger.Info("Threat intel feeds started", "count", len(started))
+	}
+	return errors.Join(errs...)
+}
+
+// Stop stops pulling, the loaded indicators are dropped
+func Stop() {
+	feedsMu.Lock()
+	cancelFn := cancel
+	cancel = nil
+	feeds = nil
+	feedsMu.Unlock()
+
+	if cancelFn != nil {
+		cancelFn()
+		wg.Wait()
+	}
+}
+
+func newFeed(cfg common.ThreatFeedConfig) (*feed, error) {
+	if strings.TrimSpace(cfg.Name) == "" {
+		return nil, errors.New("threat intel feed name cannot be empty")
+	}
+	fail := func(format string, args ...interface{}) (*feed, error) {
+		return nil, fmt.Errorf("threat intel feed '%s': %s", cfg.Name, fmt.Sprintf(format, args...))
+	}
+
+	switch cfg.Type {
+	case FeedTAXII:
+		if cfg.Collection == "" {
+			return fail("collection is required for taxii feeds")
+		}
+	case FeedSTIX, FeedMISP:
+	case FeedList:
+		if cfg.Format != "" && cfg.Format != "text" && cfg.Format != "csv" {
+			return fail("format must be 'text' or 'csv', got '%s'", cfg.Format)
+		}
+	default:
+		return fail("type must be taxii, stix, misp or list, got '%s'", cfg.Type)
+	}
+	if cfg.URL == "" {
+		return fail("url is required")
+	}
+	if cfg.Confidence < 0 || cfg.Confidence > 100 || cfg.MinConfidence < 0 || cfg.MinConfidence > 100 {
+		return fail("confidence and min_confidence must be between 0 and 100")
+	}
+	if cfg.Confidence == 0 {
+		cfg.Confidence = defaultFeedConfidence
+	}
+
+	f := &feed{cfg: cfg, interval: defaultFeedInterval, ttl: defaultIndicatorTTL}
+	if cfg.Interval != "" {
+		sec, err := common.ParseDurationToSecondsInt(cfg.Interval)
+		if err != nil {
+			return fail("invalid interval: %v", err)
+		}
+		f.interval = time.Duration(sec) * time.Second
+	}
+	if cfg.TTL != "" {
+		sec, err := common.ParseDurationToSecondsInt(cfg.TTL)
+		if err != nil {
+			return fail("invalid ttl: %v", err)
+		}
+		f.ttl = time.Duration(sec) * time.Second
+	}
+	timeout := defaultFeedTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return fail("invalid timeout '%s'", cfg.Timeout)
+		}
+		timeout = d
+	}
+	if cfg.Type == FeedList {
+		t, err := NormalizeType(cfg.IOCType)
+		if err != nil {
+			return fail("%v", err)
+		}
+		f.iocType = t
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLSSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	f.client = &http.Client{Timeout: timeout, Transport: transport}
+	f.status = FeedStatus{Name: cfg.Name, Type: cfg.Type, URL: cfg.URL, Interval: f.interval.String()}
+	return f, nil
+}
+
+func (f *feed) run(ctx context.Context) {
+	defer wg.Done()
+
+	_ = f.pull(ctx)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = f.pull(ctx)
+		}
+	}
+}
+
+// pull fetches the feed and swaps in the new indicator set, the old set is kept on error
+func (f *feed) pull(ctx context.Context) error {
+	f.pullMu.Lock()
+	defer f.pullMu.Unlock()
+
+	start := time.Now()
+	var data *feedData
+	var skipped int
+	var err error
+
+	switch f.cfg.Type {
+	case FeedTAXII:
+		data, skipped, err = f.pullTAXII(ctx, start)
+	case FeedSTIX:
+		var body []byte
+		if body, _, err = f.fetch(ctx, http.MethodGet, f.cfg.URL, nil, "application/json"); err == nil {
+			data = newFeedData()
+			skipped, err = parseSTIXBundle(body, f, start, data)
+		}
+	case FeedMISP:
+		data, skipped, err = f.pullMISP(ctx, start)
+	case FeedList:
+		var body []byte
+		if body, _, err = f.fetch(ctx, http.MethodGet, f.cfg.URL, nil, "*/*"); err == nil {
+			data = newFeedData()
+			skipped, err = parseList(body, f, start, data)
+		}
+	}
+
+	if err == nil {
+		f.data.Store(data)
+	} else if ctx.Err() == nil {
+		logger.Warn("Threat intel feed pull failed", "feed", f.cfg.Name, "error", err)
+	}
+
+	f.statusMu.Lock()
+	f.status.Pulls++
+	f.status.LastPull = start.UTC().Format(time.RFC3339)
+	f.status.Duration = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		f.status.Failures++
+		f.status.LastError = err.Error()
+	} else {
+		f.status.LastError = ""
+		f.status.LastSuccess = f.status.LastPull
+		f.status.Skipped = skipped
+	}
+	f.statusMu.Unlock()
+	return err
+}
+
+// newIndicator normalizes a value and applies the feed defaults, ok is false if it is invalid or below min_confidence
+func (f *feed) newIndicator(value, iocType string, confidence int, expires time.Time, tags []string, now time.Time) (*Indicator, bool) {
+	if iocType == "" {
+		iocType = DetectType(value)
+	}
+	norm, ok := Normalize(value, iocType)
+	if !ok {
+		return nil, false
+	}
+	if confidence < 0 {
+		confidence = f.cfg.Confidence
+	}
+	if confidence < f.cfg.MinConfidence {
+		return nil, false
+	}
+	if expires.IsZero() {
+		expires = now.Add(f.ttl)
+	}
+	if !expires.After(now) {
+		return nil, false
+	}
+	return &Indicator{
+		Value:      norm,
+		Type:       iocType,
+		Feed:       f.cfg.Name,
+		Confidence: confidence,
+		Expires:    expires,
+		Tags:       mergeTags(append([]string(nil), f.cfg.Tags...), tags),
+	}, true
+}
+
+// fetch sends a request with the feed credentials and returns the body of a 2xx response
+func (f *feed) fetch(ctx context.Context, method, url string, body []byte, accept string) ([]byte, http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if f.cfg.Username != "" {
+		req.SetBasicAuth(f.cfg.Username, f.cfg.Password)
+	}
+	if f.cfg.APIKey != "" {
+		req.Header.Set("Authorization", f.cfg.APIKey)
+	}
+	for k, v := range f.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBodySize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet := string(data)
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		return nil, nil, fmt.Errorf("%s %s returned %d: %s", method, url, resp.StatusCode, strings.TrimSpace(snippet))
+	}
+	if len(data) > maxFeedBodySize {
+		return nil, nil, fmt.Errorf("response of %s is larger than %d bytes", url, maxFeedBodySize)
+	}
+	return data, resp.Header, nil
+}
+
+func (f *feed) getStatus(now time.Time) FeedStatus {
+	f.statusMu.Lock()
+	st := f.status
+	f.statusMu.Unlock()
+
+	st.ByType = map[string]int{}
+	if d := f.data.Load(); d != nil {
+		st.Indicators, st.ByType = d.counts(now)
+	}
+	return st
+}
+
+// Feeds returns the status of all feeds of this node
+func Feeds() []FeedStatus {
+	feedsMu.RLock()
+	defer feedsMu.RUnlock()
+
+	now := time.Now()
+	res := make([]FeedStatus, 0, len(feeds))
+	for _, f := range feeds {
+		res = append(res, f.getStatus(now))
+	}
+	return res
+}
+
+// Refresh pulls a feed now and returns its status
+func Refresh(name string) (FeedStatus, error) {
+	feedsMu.RLock()
+	var target *feed
+	for _, f := range feeds {
+		if f.cfg.Name == name {
+			target = f
+			break
+		}
+	}
+	feedsMu.RUnlock()
+
+	if target == nil {
+		return FeedStatus{}, fmt.Errorf("threat intel feed not found: %s", name)
+	}
+	err := target.pull(context.Background())
+	return target.getStatus(time.Now()), err
+}
+
+// Match returns the matching unexpired indicator with the highest confidence of all feeds.
+// iocType is ip, domain, url or hash, the type is detected from the value when empty.
+func Match(value, iocType string) (*Indicator, error) {
+	t, err := NormalizeType(iocType)
+	if err != nil {
+		return nil, err
+	}
+	value = strings.TrimSpace(value)
+	if t == "" {
+		t = DetectType(value)
+	}
+	norm, ok := Normalize(value, t)
+	if !ok {
+		atomic.AddUint64(&missTotal, 1)
+		return nil, nil
+	}
+
+	now := time.Now()
+	var best *Indicator
+	feedsMu.RLock()
+	for _, f := range feeds {
+		d := f.data.Load()
+		if d == nil {
+			continue
+		}
+		if ind := d.match(t, norm, now); ind != nil && (best == nil || ind.Confidence > best.Confidence) {
+			best = ind
+		}
+	}
+	feedsMu.RUnlock()
+
+	if best == nil {
+		atomic.AddUint64(&missTotal, 1)
+	} else {
+		atomic.AddUint64(&hitTotal, 1)
+	}
+	return best, nil
+}
+
+// Stats returns the match counters of this node
+func Stats() (hits uint64, misses uint64) {
+	return atomic.LoadUint64(&hitTotal), atomic.LoadUint64(&missTotal)
+}
+
+// MatchArgs implements the arguments of the isIOC and iocInfo plugins: value, type (optional), min confidence (optional)
+func MatchArgs(name string, args ...interface{}) (*Indicator, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("%s requires 1 to 3 arguments: value, type (ip/domain/url/hash, optional), min confidence (optional)", name)
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+	iocType := ""
+	if len(args) >= 2 {
+		iocType = common.AnyToString(args[1])
+	}
+	minConfidence := 0
+	if len(args) == 3 {
+		n, err := strconv.ParseFloat(common.AnyToString(args[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s min confidence must be a number, got %v", name, args[2])
+		}
+		minConfidence = int(n)
+	}
+
+	ind, err := Match(common.AnyToString(args[0]), iocType)
+	if err != nil || ind == nil || ind.Confidence < minConfidence {
+		return nil, err
+	}
+	return ind, nil
+}
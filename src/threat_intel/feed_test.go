@@ -0,0 +1,303 @@
+package threat_intel
+
+import (
+	"AgentSmith-HUB/common"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startFeeds starts the feeds and waits for their first pull
+func startFeeds(t *testing.T, cfgs ...common.ThreatFeedConfig) {
+	t.Helper()
+	if err := Start(cfgs); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(Stop)
+	deadline := time.Now().Add(5 * time.Second)
+	for !allPulled() {
+		if time.Now().After(deadline) {
+			t.Fatalf("feeds were not pulled: %+v", Feeds())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func allPulled() bool {
+	for _, status := range Feeds() {
+		if status.Pulls == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func expectMatch(t *testing.T, value, iocType, feed string, confidence int) *Indicator {
+	t.Helper()
+	ind, err := Match(value, iocType)
+	if err != nil {
+		t.Fatalf("Match(%q, %q) failed: %v", value, iocType, err)
+	}
+	if ind == nil {
+		t.Fatalf("Match(%q, %q) = nil, expected an indicator of %s", value, iocType, feed)
+	}
+	if ind.Feed != feed || ind.Confidence != confidence {
+		t.Errorf("Match(%q, %q) = %+v, expected feed %s with confidence %d", value, iocType, ind, feed, confidence)
+	}
+	return ind
+}
+
+func expectNoMatch(t *testing.T, value, iocType string) {
+	t.Helper()
+	if ind, err := Match(value, iocType); err != nil || ind != nil {
+		t.Errorf("Match(%q, %q) = %+v, %v, expected no indicator", value, iocType, ind, err)
+	}
+}
+
+func TestTAXIIFeed(t *testing.T) {
+	validUntil := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	hash := strings.Repeat("ab", 32)
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api1/collections/coll-1/objects/" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "pass" || r.Header.Get("Accept") != taxiiAccept {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		requests = append(requests, q.Get("added_after")+"|"+q.Get("next"))
+
+		w.Header().Set("Content-Type", taxiiAccept)
+		switch {
+		case q.Get("added_after") != "":
+			// Incremental pull: the IP indicator was revoked
+			w.Header().Set("X-TAXII-Date-Added-Last", "2026-01-03T00:00:00Z")
+			fmt.Fprint(w, `{"more":false,"objects":[{"type":"indicator","id":"indicator--ip","revoked":true}]}`)
+		case q.Get("next") == "":
+			w.Header().Set("X-TAXII-Date-Added-Last", "2026-01-01T00:00:00Z")
+			fmt.Fprintf(w, `{"more":true,"next":"page-2","objects":[
+				{"type":"indicator","id":"indicator--ip","pattern":"[ipv4-addr:value = '203.0.113.7']","confidence":80,"labels":["c2"]},
+				{"type":"indicator","id":"indicator--domain","pattern":"[domain-name:value = 'Evil.Example']","indicator_types":["malicious-activity"]},
+				{"type":"indicator","id":"indicator--composite","pattern":"[ipv4-addr:value = '198.51.100.1'] AND [domain-name:value = 'a.example']"},
+				{"type":"malware","id":"malware--1"}
+			]}`)
+		default:
+			w.Header().Set("X-TAXII-Date-Added-Last", "2026-01-02T00:00:00Z")
+			fmt.Fprintf(w, `{"more":false,"objects":[
+				{"type":"indicator","id":"indicator--hash","pattern":"[file:hashes.'SHA-256' = '%s']","valid_until":"%s","confidence":60},
+				{"type":"indicator","id":"indicator--expired","pattern":"[url:value = 'http://old.example/x']","valid_until":"%s"},
+				{"type":"indicator","id":"indicator--sigma","pattern":"title: x","pattern_type":"sigma"}
+			]}`, hash, validUntil, expired)
+		}
+	}))
+	defer srv.Close()
+
+	startFeeds(t, common.ThreatFeedConfig{
+		Name: "taxii", Type: FeedTAXII, URL: srv.URL + "/api1/", Collection: "coll-1",
+		Username: "user", Password: "pass", Confidence: 40, Interval: "1h",
+	})
+
+	ind := expectMatch(t, "203.0.113.7", "ip", "taxii", 80)
+	if !reflect.DeepEqual(ind.Tags, []string{"c2"}) {
+		t.Errorf("tags = %v, expected [c2]", ind.Tags)
+	}
+	expectMatch(t, "www.evil.example", "", "taxii", 40)
+	expectMatch(t, strings.ToUpper(hash), "hash", "taxii", 60)
+	expectNoMatch(t, "198.51.100.1", "ip")
+	expectNoMatch(t, "http://old.example/x", "url")
+
+	status := Feeds()[0]
+	if status.Indicators != 3 || status.Skipped != 3 {
+		t.Errorf("status = %+v, expected 3 indicators and 3 skipped", status)
+	}
+
+	if _, err := Refresh("taxii"); err != nil {
+		t.Fatalf("incremental Refresh failed: %v", err)
+	}
+	expectNoMatch(t, "203.0.113.7", "ip")
+	expectMatch(t, "evil.example", "domain", "taxii", 40)
+
+	last := requests[len(requests)-1]
+	if last != "2026-01-02T00:00:00Z|" {
+		t.Errorf("incremental pull requested %q, expected added_after of the last page", last)
+	}
+}
+
+func TestMISPFeed(t *testing.T) {
+	hash := strings.Repeat("1", 64)
+	var filters map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "misp-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &filters)
+		fmt.Fprintf(w, `{"response":[{"Event":{"threat_level_id":"1","Tag":[{"name":"tlp:white"}],
+			"Attribute":[
+				{"type":"ip-dst","value":"203.0.113.8","to_ids":true,"Tag":[{"name":"botnet"}]},
+				{"type":"domain|ip","value":"bad.example|198.51.100.9"},
+				{"type":"ip-src","value":"192.0.2.1","to_ids":false},
+				{"type":"url","value":"http://deleted.example/","deleted":true},
+				{"type":"comment","value":"not an ioc"}
+			],
+			"Object":[{"Attribute":[{"type":"filename|sha256","value":"x.exe|%s"}]}]
+		}}]}`, hash)
+	}))
+	defer srv.Close()
+
+	startFeeds(t, common.ThreatFeedConfig{
+		Name: "misp", Type: FeedMISP, URL: srv.URL + "/events/restSearch", APIKey: "misp-key",
+		Filters: map[string]interface{}{"last": "30d"},
+	})
+
+	if filters["returnFormat"] != "json" || filters["last"] != "30d" {
+		t.Errorf("restSearch filters = %v, expected returnFormat json and last 30d", filters)
+	}
+	ind := expectMatch(t, "203.0.113.8", "ip", "misp", 90)
+	if !reflect.DeepEqual(ind.Tags, []string{"tlp:white", "botnet"}) {
+		t.Errorf("tags = %v, expected the event and attribute tags", ind.Tags)
+	}
+	expectMatch(t, "bad.example", "domain", "misp", 90)
+	expectMatch(t, "198.51.100.9", "ip", "misp", 90)
+	expectMatch(t, hash, "hash", "misp", 90)
+	expectNoMatch(t, "192.0.2.1", "ip")
+	expectNoMatch(t, "http://deleted.example/", "url")
+	if skipped := Feeds()[0].Skipped; skipped != 1 {
+		t.Errorf("skipped = %d, expected 1", skipped)
+	}
+}
+
+func TestParseMISP(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		values  []string
+		wantErr bool
+	}{
+		{"event list", `[{"Event":{"Attribute":[{"type":"ip-dst","value":"203.0.113.1"}]}}]`, []string{"ip:203.0.113.1"}, false},
+		{"single event", `{"Event":{"Attribute":[{"type":"hostname","value":"a.example"}]}}`, []string{"domain:a.example"}, false},
+		{"attribute search", `{"response":{"Attribute":[{"type":"url","value":"http://b.example"}]}}`, []string{"url:http://b.example/"}, false},
+		{"no event", `{"foo":1}`, nil, true},
+		{"invalid", `not json`, nil, true},
+	}
+
+	f, err := newFeed(common.ThreatFeedConfig{Name: "misp", Type: FeedMISP, URL: "http://misp"})
+	if err != nil {
+		t.Fatalf("newFeed failed: %v", err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := newFeedData()
+			_, err := parseMISP([]byte(test.body), f, time.Now(), data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseMISP() error = %v, expected error %v", err, test.wantErr)
+			}
+			for _, key := range test.values {
+				if _, ok := data.exact[key]; !ok {
+					t.Errorf("indicator %s missing, got %v", key, data.exact)
+				}
+			}
+		})
+	}
+}
+
+func TestListFeed(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/ips.txt":
+			fmt.Fprint(w, "# blocklist\n; generated daily\n203.0.113.9  first seen 2026-01-01\n198.51.100.0/24\n\nnot-an-ip\n")
+		case "/domains.csv":
+			fmt.Fprint(w, "score,domain\n90,phish.example\n70,\"Mal.Example\"\n10\n")
+		}
+	}))
+	defer srv.Close()
+
+	startFeeds(t,
+		common.ThreatFeedConfig{Name: "ips", Type: FeedList, URL: srv.URL + "/ips.txt", IOCType: "ip", Confidence: 80, Tags: []string{"blocklist"}},
+		common.ThreatFeedConfig{Name: "domains", Type: FeedList, URL: srv.URL + "/domains.csv", Format: "csv", Column: "domain"},
+	)
+
+	ind := expectMatch(t, "203.0.113.9", "", "ips", 80)
+	if !reflect.DeepEqual(ind.Tags, []string{"blocklist"}) {
+		t.Errorf("tags = %v, expected the feed tags", ind.Tags)
+	}
+	expectMatch(t, "198.51.100.77", "ip", "ips", 80)
+	expectMatch(t, "login.phish.example", "domain", "domains", defaultFeedConfidence)
+	expectMatch(t, "mal.example", "domain", "domains", defaultFeedConfidence)
+	expectNoMatch(t, "score", "domain")
+
+	statuses := map[string]FeedStatus{}
+	for _, s := range Feeds() {
+		statuses[s.Name] = s
+	}
+	if s := statuses["ips"]; s.Indicators != 2 || s.Skipped != 1 {
+		t.Errorf("ips status = %+v, expected 2 indicators and 1 skipped", s)
+	}
+	if s := statuses["domains"]; s.Indicators != 2 || s.Skipped != 1 {
+		t.Errorf("domains status = %+v, expected 2 indicators and 1 skipped", s)
+	}
+
+	// A failed pull keeps the indicators of the last successful one
+	fail.Store(true)
+	if status, err := Refresh("ips"); err == nil || status.Failures != 1 || status.LastError == "" {
+		t.Errorf("Refresh() = %+v, %v, expected a failed pull", status, err)
+	}
+	expectMatch(t, "203.0.113.9", "ip", "ips", 80)
+}
+
+func TestMatchArgs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "evil.example\n")
+	}))
+	defer srv.Close()
+	startFeeds(t, common.ThreatFeedConfig{Name: "list", Type: FeedList, URL: srv.URL, Confidence: 60})
+
+	tests := []struct {
+		name    string
+		args    []interface{}
+		matched bool
+		wantErr bool
+	}{
+		{"detected type", []interface{}{"evil.example"}, true, false},
+		{"explicit type", []interface{}{"sub.evil.example", "domain"}, true, false},
+		{"other type", []interface{}{"evil.example", "url"}, false, false},
+		{"min confidence met", []interface{}{"evil.example", "", 60}, true, false},
+		{"min confidence as string", []interface{}{"evil.example", "", "61"}, false, false},
+		{"nil value", []interface{}{nil}, false, false},
+		{"not an ioc", []interface{}{"good.example"}, false, false},
+		{"unknown type", []interface{}{"evil.example", "email"}, false, true},
+		{"invalid min confidence", []interface{}{"evil.example", "", "high"}, false, true},
+		{"no args", nil, false, true},
+		{"too many args", []interface{}{"a", "b", 1, 2}, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ind, err := MatchArgs("isIOC", test.args...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("MatchArgs(%v) error = %v, expected error %v", test.args, err, test.wantErr)
+			}
+			if (ind != nil) != test.matched {
+				t.Errorf("MatchArgs(%v) = %+v, expected matched %v", test.args, ind, test.matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package threat_intel
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Indicator types
+const (
+	TypeIP     = "ip"
+	TypeDomain = "domain"
+	TypeURL    = "url"
+	TypeHash   = "hash"
+)
+
+var domainRegex = regexp.MustCompile(`^(?:[\p{L}\p{N}_-]+\.)+[\p{L}\p{N}-]+$`)
+
+// Indicator is a normalized IOC of a feed
+type Indicator struct {
+	Value      string
+	Type       string
+	Feed       string
+	Confidence int
+	Expires    time.Time // zero means it never expires
+	Tags       []string
+}
+
+func (i *Indicator) expired(now time.Time) bool {
+	return !i.Expires.IsZero() && now.After(i.Expires)
+}
+
+// Info returns the indicator as the result of the iocInfo plugin
+func (i *Indicator) Info() map[string]interface{} {
+	info := map[string]interface{}{
+		"value":      i.Value,
+		"type":       i.Type,
+		"feed":       i.Feed,
+		"confidence": i.Confidence,
+	}
+	if !i.Expires.IsZero() {
+		info["expires"] = i.Expires.UTC().Format(time.RFC3339)
+	}
+	if len(i.Tags) > 0 {
+		tags := make([]interface{}, len(i.Tags))
+		for n, t := range i.Tags {
+			tags[n] = t
+		}
+		info["tags"] = tags
+	}
+	return info
+}
+
+// NormalizeType maps a type argument to an indicator type, "" means the type is detected from the value
+func NormalizeType(t string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(t)) {
+	case "", "any", "auto":
+		return "", nil
+	case "ip", "ipv4", "ipv6", "cidr":
+		return TypeIP, nil
+	case "domain", "hostname", "host":
+		return TypeDomain, nil
+	case "url":
+		return TypeURL, nil
+	case "hash", "md5", "sha1", "sha256", "sha512":
+		return TypeHash, nil
+	}
+	return "", fmt.Errorf("unknown ioc type '%s', expected ip, domain, url or hash", t)
+}
+
+// DetectType guesses the indicator type of a value, "" if it is none of them
+func DetectType(value string) string {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return ""
+	case isIPValue(value):
+		return TypeIP
+	case isHash(value):
+		return TypeHash
+	case strings.Contains(value, "://"):
+		return TypeURL
+	}
+	if host, _, found := strings.Cut(value, "/"); found {
+		if _, ok := normalizeDomain(host); ok {
+			return TypeURL
+		}
+		return ""
+	}
+	if _, ok := normalizeDomain(value); ok {
+		return TypeDomain
+	}
+	return ""
+}
+
+// Normalize returns the canonical form of a value, ok is false if it is not a valid indicator of the type
+func Normalize(value, iocType string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", false
+	}
+	switch iocType {
+	case TypeIP:
+		return normalizeIP(value)
+	case TypeDomain:
+		return normalizeDomain(value)
+	case TypeURL:
+		return normalizeURL(value)
+	case TypeHash:
+		v := strings.ToLower(value)
+		return v, isHash(v)
+	}
+	return "", false
+}
+
+func isIPValue(value string) bool {
+	_, ok := normalizeIP(value)
+	return ok
+}
+
+// normalizeIP accepts addresses and networks, a network of a single address becomes the address
+func normalizeIP(value string) (string, bool) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return "", false
+		}
+		addr := prefix.Addr().Unmap()
+		bits := prefix.Bits()
+		if prefix.Addr().Is4In6() {
+			bits -= 96
+		}
+		if bits < 0 {
+			return "", false
+		}
+		if bits == addr.BitLen() {
+			return addr.String(), true
+		}
+		return netip.PrefixFrom(addr, bits).Masked().String(), true
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return "", false
+	}
+	return addr.Unmap().WithZone("").String(), true
+}
+
+func normalizeDomain(value string) (string, bool) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	v = strings.TrimPrefix(v, "*.")
+	v = strings.Trim(v, ".")
+	if !domainRegex.MatchString(v) || isIPValue(v) {
+		return "", false
+	}
+	return v, true
+}
+
+// normalizeURL lower-cases scheme and host and drops user info and fragment, "http://" is assumed without scheme
+func normalizeURL(value string) (string, bool) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+	return u.String(), true
+}
+
+func isHash(value string) bool {
+	switch len(value) {
+	case 32, 40, 64, 128:
+	default:
+		return false
+	}
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
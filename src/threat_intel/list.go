@@ -0,0 +1,80 @@
+package threat_intel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// parseList adds the IOCs of a plain list: one value per line (comments start with # or ;, text after
+// the first space is ignored), or with format csv the configured column of every row
+func parseList(body []byte, f *feed, now time.Time, data *feedData) (int, error) {
+	skipped := 0
+	add := func(value string) {
+		ind, ok := f.newIndicator(value, f.iocType, -1, time.Time{}, nil, now)
+		if !ok {
+			skipped++
+			return
+		}
+		data.add(ind, "")
+	}
+
+	if f.cfg.Format == "csv" {
+		r := csv.NewReader(bytes.NewReader(body))
+		r.Comment = '#'
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		r.TrimLeadingSpace = true
+
+		col := 0
+		if f.cfg.Column != "" {
+			header, err := r.Read()
+			if err != nil {
+				return 0, fmt.Errorf("list csv header: %w", err)
+			}
+			col = -1
+			for i, h := range header {
+				if strings.TrimSpace(h) == f.cfg.Column {
+					col = i
+					break
+				}
+			}
+			if col < 0 {
+				return 0, fmt.Errorf("list csv has no column '%s'", f.cfg.Column)
+			}
+		}
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, fmt.Errorf("list csv: %w", err)
+			}
+			if col >= len(record) {
+				skipped++
+				continue
+			}
+			add(record[col])
+		}
+		return skipped, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		add(strings.Fields(line)[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("list: %w", err)
+	}
+	return skipped, nil
+}
@@ -0,0 +1,187 @@
+package threat_intel
+
+import (
+	"AgentSmith-HUB/common"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+type mispAttribute struct {
+	Type    string    `json:"type"`
+	Value   string    `json:"value"`
+	ToIDs   *bool     `json:"to_ids"`
+	Deleted bool      `json:"deleted"`
+	Tag     []mispTag `json:"Tag"`
+}
+
+type mispEvent struct {
+	ThreatLevelID interface{}     `json:"threat_level_id"`
+	Attribute     []mispAttribute `json:"Attribute"`
+	Object        []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+	Tag []mispTag `json:"Tag"`
+}
+
+type mispEventWrapper struct {
+	Event *mispEvent `json:"Event"`
+}
+
+// pullMISP reads a MISP export, restSearch URLs are queried with a POST of the configured filters
+func (f *feed) pullMISP(ctx context.Context, now time.Time) (*feedData, int, error) {
+	method := http.MethodGet
+	var reqBody []byte
+	if u, err := url.Parse(f.cfg.URL); err == nil && strings.HasSuffix(u.Path, "/restSearch") {
+		filters := map[string]interface{}{"returnFormat": "json", "to_ids": true}
+		for k, v := range f.cfg.Filters {
+			filters[k] = v
+		}
+		b, err := json.Marshal(filters)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid MISP filters: %w", err)
+		}
+		method = http.MethodPost
+		reqBody = b
+	}
+
+	body, _, err := f.fetch(ctx, method, f.cfg.URL, reqBody, "application/json")
+	if err != nil {
+		return nil, 0, err
+	}
+	data := newFeedData()
+	skipped, err := parseMISP(body, f, now, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, skipped, nil
+}
+
+// parseMISP adds the attributes of an event list, a single event or an attribute restSearch result
+func parseMISP(body []byte, f *feed, now time.Time, data *feedData) (int, error) {
+	body = bytes.TrimSpace(body)
+	var events []mispEventWrapper
+	var attributes []mispAttribute
+
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &events); err != nil {
+			return 0, fmt.Errorf("invalid MISP export: %w", err)
+		}
+	} else {
+		var doc struct {
+			Response json.RawMessage `json:"response"`
+			Event    *mispEvent      `json:"Event"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return 0, fmt.Errorf("invalid MISP export: %w", err)
+		}
+		resp := bytes.TrimSpace(doc.Response)
+		switch {
+		case doc.Event != nil:
+			events = []mispEventWrapper{{Event: doc.Event}}
+		case len(resp) > 0 && resp[0] == '[':
+			if err := json.Unmarshal(resp, &events); err != nil {
+				return 0, fmt.Errorf("invalid MISP response: %w", err)
+			}
+		case len(resp) > 0:
+			var attrResp struct {
+				Attribute []mispAttribute `json:"Attribute"`
+			}
+			if err := json.Unmarshal(resp, &attrResp); err != nil {
+				return 0, fmt.Errorf("invalid MISP response: %w", err)
+			}
+			attributes = attrResp.Attribute
+		default:
+			return 0, fmt.Errorf("invalid MISP export: no Event or response")
+		}
+	}
+
+	skipped := 0
+	add := func(attrs []mispAttribute, confidence int, eventTags []string) {
+		for _, attr := range attrs {
+			if attr.Deleted || (attr.ToIDs != nil && !*attr.ToIDs) {
+				continue
+			}
+			values := mispValues(attr.Type, attr.Value)
+			if len(values) == 0 {
+				skipped++
+				continue
+			}
+			tags := eventTags
+			for _, t := range attr.Tag {
+				tags = mergeTags(tags, []string{t.Name})
+			}
+			for _, v := range values {
+				ind, ok := f.newIndicator(v.value, v.iocType, confidence, time.Time{}, tags, now)
+				if !ok {
+					skipped++
+					continue
+				}
+				data.add(ind, "")
+			}
+		}
+	}
+
+	for _, w := range events {
+		if w.Event == nil {
+			continue
+		}
+		var tags []string
+		for _, t := range w.Event.Tag {
+			tags = append(tags, t.Name)
+		}
+		confidence := mispConfidence(w.Event.ThreatLevelID)
+		add(w.Event.Attribute, confidence, tags)
+		for _, obj := range w.Event.Object {
+			add(obj.Attribute, confidence, tags)
+		}
+	}
+	add(attributes, -1, nil)
+	return skipped, nil
+}
+
+// mispConfidence maps the event threat level (1 high, 2 medium, 3 low, 4 undefined) to a confidence
+func mispConfidence(threatLevel interface{}) int {
+	switch common.AnyToString(threatLevel) {
+	case "1":
+		return 90
+	case "2":
+		return 70
+	case "3":
+		return 50
+	}
+	return -1
+}
+
+// mispValues returns the IOCs of an attribute, composite types like domain|ip carry two values
+func mispValues(attrType, value string) []stixValue {
+	first, second, _ := strings.Cut(value, "|")
+	switch attrType {
+	case "ip-src", "ip-dst":
+		return []stixValue{{TypeIP, value}}
+	case "ip-src|port", "ip-dst|port":
+		return []stixValue{{TypeIP, first}}
+	case "domain", "hostname":
+		return []stixValue{{TypeDomain, value}}
+	case "hostname|port":
+		return []stixValue{{TypeDomain, first}}
+	case "domain|ip":
+		return []stixValue{{TypeDomain, first}, {TypeIP, second}}
+	case "url":
+		return []stixValue{{TypeURL, value}}
+	case "md5", "sha1", "sha256", "sha512":
+		return []stixValue{{TypeHash, value}}
+	case "filename|md5", "filename|sha1", "filename|sha256", "filename|sha512":
+		return []stixValue{{TypeHash, second}}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package threat_intel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	taxiiAccept = "application/taxii+json;version=2.1"
+
+	// Pages read in one TAXII pull
+	maxTAXIIPages = 1000
+
+	// TAXII pulls are incremental (added_after), a full pull is done at least this often so
+	// indicators without valid_until are refreshed before their ttl
+	maxTAXIIFullSyncInterval = 24 * time.Hour
+)
+
+var (
+	// Comparison of a STIX pattern, e.g. [ipv4-addr:value = '1.2.3.4'] or [file:hashes.'SHA-256' = '...']
+	stixComparisonRegex = regexp.MustCompile(`([a-z0-9-]+):([A-Za-z0-9_.'-]+)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+	stixQuotedRegex     = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	// Observations that only match together cannot be used as single IOCs
+	stixCompositeRegex = regexp.MustCompile(`\b(AND|FOLLOWEDBY)\b`)
+)
+
+type stixObject struct {
+	Type           string   `json:"type"`
+	ID             string   `json:"id"`
+	Pattern        string   `json:"pattern"`
+	PatternType    string   `json:"pattern_type"`
+	ValidUntil     string   `json:"valid_until"`
+	Confidence     *int     `json:"confidence"`
+	Labels         []string `json:"labels"`
+	IndicatorTypes []string `json:"indicator_types"`
+	Revoked        bool     `json:"revoked"`
+}
+
+type stixValue struct {
+	iocType string
+	value   string
+}
+
+// parseSTIXBundle adds the indicators of a STIX 2.x bundle
+func parseSTIXBundle(body []byte, f *feed, now time.Time, data *feedData) (int, error) {
+	var bundle struct {
+		Type    string       `json:"type"`
+		Objects []stixObject `json:"objects"`
+	}
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		return 0, fmt.Errorf("invalid STIX bundle: %w", err)
+	}
+	if bundle.Type != "bundle" {
+		return 0, fmt.Errorf("invalid STIX bundle: type is '%s'", bundle.Type)
+	}
+	return addSTIXObjects(bundle.Objects, f, now, data), nil
+}
+
+// addSTIXObjects adds the indicators of STIX objects, a revoked or newer version replaces the previous one
+func addSTIXObjects(objects []stixObject, f *feed, now time.Time, data *feedData) int {
+	skipped := 0
+	for _, obj := range objects {
+		if obj.Type != "indicator" {
+			continue
+		}
+		data.remove(obj.ID)
+		if obj.Revoked {
+			continue
+		}
+		if obj.PatternType != "" && obj.PatternType != "stix" {
+			skipped++
+			continue
+		}
+
+		var expires time.Time
+		if obj.ValidUntil != "" {
+			t, err := time.Parse(time.RFC3339, obj.ValidUntil)
+			if err != nil {
+				skipped++
+				continue
+			}
+			expires = t
+		}
+		confidence := -1
+		if obj.Confidence != nil {
+			confidence = *obj.Confidence
+		}
+		tags := mergeTags(obj.Labels, obj.IndicatorTypes)
+
+		values := parseSTIXPattern(obj.Pattern)
+		if len(values) == 0 {
+			skipped++
+			continue
+		}
+		for _, v := range values {
+			ind, ok := f.newIndicator(v.value, v.iocType, confidence, expires, tags, now)
+			if !ok {
+				skipped++
+				continue
+			}
+			data.add(ind, obj.ID)
+		}
+	}
+	return skipped
+}
+
+// parseSTIXPattern returns the IOCs of a pattern made of equality comparisons joined by OR
+func parseSTIXPattern(pattern string) []stixValue {
+	if stixCompositeRegex.MatchString(stixQuotedRegex.ReplaceAllString(pattern, "''")) {
+		return nil
+	}
+
+	var res []stixValue
+	for _, m := range stixComparisonRegex.FindAllStringSubmatch(pattern, -1) {
+		object, path := m[1], m[2]
+		value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(m[3])
+
+		var iocType string
+		switch {
+		case (object == "ipv4-addr" || object == "ipv6-addr") && path == "value":
+			iocType = TypeIP
+		case object == "domain-name" && path == "value":
+			iocType = TypeDomain
+		case object == "url" && path == "value":
+			iocType = TypeURL
+		case object == "file" && strings.HasPrefix(path, "hashes."):
+			iocType = TypeHash
+		default:
+			continue
+		}
+		res = append(res, stixValue{iocType: iocType, value: value})
+	}
+	return res
+}
+
+// pullTAXII reads the indicators of a TAXII 2.1 collection, only objects added since the last pull
+// are requested between full pulls
+func (f *feed) pullTAXII(ctx context.Context, now time.Time) (*feedData, int, error) {
+	fullSync := maxTAXIIFullSyncInterval
+	if f.ttl/2 < fullSync {
+		fullSync = f.ttl / 2
+	}
+	full := f.cursor == "" || f.data.Load() == nil || now.Sub(f.lastFull) > fullSync
+
+	data := newFeedData()
+	addedAfter := ""
+	if !full {
+		data = f.data.Load().clone(now)
+		addedAfter = f.cursor
+	}
+
+	objectsURL := strings.TrimSuffix(f.cfg.URL, "/") + "/collections/" + url.PathEscape(f.cfg.Collection) + "/objects/"
+	skipped := 0
+	next := ""
+	lastAdded := f.cursor
+	for page := 0; ; page++ {
+		if page >= maxTAXIIPages {
+			return nil, 0, fmt.Errorf("taxii collection has more than %d pages", maxTAXIIPages)
+		}
+
+		q := url.Values{}
+		q.Set("match[type]", "indicator")
+		if addedAfter != "" {
+			q.Set("added_after", addedAfter)
+		}
+		if next != "" {
+			q.Set("next", next)
+		}
+		body, header, err := f.fetch(ctx, http.MethodGet, objectsURL+"?"+q.Encode(), nil, taxiiAccept)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var envelope struct {
+			More    bool         `json:"more"`
+			Next    string       `json:"next"`
+			Objects []stixObject `json:"objects"`
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &envelope); err != nil {
+				return nil, 0, fmt.Errorf("invalid TAXII envelope: %w", err)
+			}
+		}
+		skipped += addSTIXObjects(envelope.Objects, f, now, data)
+
+		pageLast := header.Get("X-TAXII-Date-Added-Last")
+		if pageLast != "" {
+			lastAdded = pageLast
+		}
+		if !envelope.More || len(envelope.Objects) == 0 {
+			break
+		}
+		if envelope.Next != "" {
+			next = envelope.Next
+		} else if pageLast != "" && pageLast != addedAfter {
+			// Servers without next paginate with added_after
+			addedAfter = pageLast
+		} else {
+			break
+		}
+	}
+
+	f.cursor = lastAdded
+	if full {
+		f.lastFull = now
+	}
+	return data, skipped, nil
+}
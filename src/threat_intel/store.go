@@ -0,0 +1,199 @@
+package threat_intel
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+// feedData is the indicator set of a feed. A pull builds a new set and swaps it in,
+// a stored set is never modified so matching needs no lock.
+type feedData struct {
+	exact map[string]*Indicator // type:value
+
+	// ip networks grouped by prefix length, longest first, so the most specific network wins
+	prefixBits []int
+	prefixes   map[int]map[netip.Prefix]*Indicator
+
+	// STIX indicator id -> keys, so a new version or a revocation replaces the old values
+	byID map[string][]string
+}
+
+func newFeedData() *feedData {
+	return &feedData{
+		exact:    make(map[string]*Indicator),
+		prefixes: make(map[int]map[netip.Prefix]*Indicator),
+		byID:     make(map[string][]string),
+	}
+}
+
+func indicatorKey(iocType, value string) string {
+	return iocType + ":" + value
+}
+
+// add stores an indicator, a duplicate value keeps the higher confidence and the later expiry
+func (d *feedData) add(ind *Indicator, id string) {
+	key := indicatorKey(ind.Type, ind.Value)
+	if id != "" {
+		d.byID[id] = append(d.byID[id], key)
+	}
+
+	if ind.Type == TypeIP && strings.Contains(ind.Value, "/") {
+		prefix, err := netip.ParsePrefix(ind.Value)
+		if err != nil {
+			return
+		}
+		bits := prefix.Bits()
+		if _, ok := d.prefixes[bits]; !ok {
+			d.prefixes[bits] = make(map[netip.Prefix]*Indicator)
+			d.prefixBits = append(d.prefixBits, bits)
+			sort.Sort(sort.Reverse(sort.IntSlice(d.prefixBits)))
+		}
+		d.prefixes[bits][prefix] = mergeIndicator(d.prefixes[bits][prefix], ind)
+		return
+	}
+	d.exact[key] = mergeIndicator(d.exact[key], ind)
+}
+
+func mergeIndicator(old, ind *Indicator) *Indicator {
+	if old == nil {
+		return ind
+	}
+	merged := *old
+	if ind.Confidence > merged.Confidence {
+		merged.Confidence = ind.Confidence
+	}
+	if merged.Expires.IsZero() || ind.Expires.IsZero() {
+		merged.Expires = time.Time{}
+	} else if ind.Expires.After(merged.Expires) {
+		merged.Expires = ind.Expires
+	}
+	merged.Tags = mergeTags(merged.Tags, ind.Tags)
+	return &merged
+}
+
+func mergeTags(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	res := append([]string(nil), a...)
+	for _, t := range b {
+		found := false
+		for _, e := range res {
+			if e == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// remove drops the values of a STIX indicator id
+func (d *feedData) remove(id string) {
+	for _, key := range d.byID[id] {
+		iocType, value, _ := strings.Cut(key, ":")
+		if iocType == TypeIP && strings.Contains(value, "/") {
+			if prefix, err := netip.ParsePrefix(value); err == nil {
+				delete(d.prefixes[prefix.Bits()], prefix)
+			}
+			continue
+		}
+		delete(d.exact, key)
+	}
+	delete(d.byID, id)
+}
+
+// clone copies the set without expired indicators, used by incremental pulls
+func (d *feedData) clone(now time.Time) *feedData {
+	c := newFeedData()
+	for key, ind := range d.exact {
+		if !ind.expired(now) {
+			c.exact[key] = ind
+		}
+	}
+	for _, bits := range d.prefixBits {
+		for prefix, ind := range d.prefixes[bits] {
+			if ind.expired(now) {
+				continue
+			}
+			if _, ok := c.prefixes[bits]; !ok {
+				c.prefixes[bits] = make(map[netip.Prefix]*Indicator)
+				c.prefixBits = append(c.prefixBits, bits)
+			}
+			c.prefixes[bits][prefix] = ind
+		}
+	}
+	for id, keys := range d.byID {
+		c.byID[id] = append([]string(nil), keys...)
+	}
+	return c
+}
+
+// counts returns the number of unexpired indicators by type
+func (d *feedData) counts(now time.Time) (int, map[string]int) {
+	byType := make(map[string]int)
+	total := 0
+	for _, ind := range d.exact {
+		if !ind.expired(now) {
+			byType[ind.Type]++
+			total++
+		}
+	}
+	for _, bits := range d.prefixBits {
+		for _, ind := range d.prefixes[bits] {
+			if !ind.expired(now) {
+				byType[TypeIP]++
+				total++
+			}
+		}
+	}
+	return total, byType
+}
+
+// match looks up a normalized value, domains also match indicators of their parent domains
+func (d *feedData) match(iocType, value string, now time.Time) *Indicator {
+	if ind, ok := d.exact[indicatorKey(iocType, value)]; ok && !ind.expired(now) {
+		return ind
+	}
+
+	switch iocType {
+	case TypeIP:
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil
+		}
+		for _, bits := range d.prefixBits {
+			if bits > addr.BitLen() {
+				continue
+			}
+			prefix, err := addr.Prefix(bits)
+			if err != nil {
+				continue
+			}
+			if ind, ok := d.prefixes[bits][prefix]; ok && !ind.expired(now) {
+				return ind
+			}
+		}
+	case TypeDomain:
+		for parent := value; ; {
+			i := strings.IndexByte(parent, '.')
+			if i < 0 {
+				break
+			}
+			parent = parent[i+1:]
+			// Never match a bare TLD
+			if !strings.Contains(parent, ".") {
+				break
+			}
+			if ind, ok := d.exact[indicatorKey(TypeDomain, parent)]; ok && !ind.expired(now) {
+				return ind
+			}
+		}
+	}
+	return nil
+}
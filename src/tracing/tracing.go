@@ -0,0 +1,280 @@
+package tracing
+
+import (
+	"AgentSmith-HUB/common"
+	"AgentSmith-HUB/logger"
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// OpenTelemetry tracing of messages through the pipeline, exported to an OTLP/HTTP collector.
+// config.yaml:
+//
+//	tracing:
+//	  enabled: true
+//	  endpoint: http://localhost:4318     # OTLP/HTTP collector, /v1/traces is used when no path is given
+//	  sample_ratio: 0.01                  # share of messages traced
+//	  service_name: agentsmith-hub
+//	  headers: {Authorization: "Bearer ..."}
+//
+// An input starts the trace of a sampled message and stores its span context in the message
+// (common.TraceParentField), rulesets, plugins and outputs add their spans below it. A message
+// that arrives with a trace context, e.g. Kafka traceparent headers, continues that trace and is
+// traced whenever its parent was sampled. Messages that are not sampled carry no trace context and
+// the later stages skip them. Span names are generic, callers name and annotate the span once
+// IsRecording reports it is traced, so that nothing is allocated for other messages.
+
+const (
+	defaultEndpoint    = "http://localhost:4318"
+	defaultURLPath     = "/v1/traces"
+	defaultSampleRatio = 0.01
+	defaultServiceName = "agentsmith-hub"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// tracerBox lets tracers of different types share an atomic pointer
+type tracerBox struct {
+	trace.Tracer
+}
+
+var (
+	enabled    atomic.Bool
+	tracer     atomic.Pointer[tracerBox]
+	provider   *sdktrace.TracerProvider
+	providerMu sync.Mutex
+
+	propagator = propagation.TraceContext{}
+	noopSpan   = trace.Span(noop.Span{})
+)
+
+// Start sets up the exporter of the config, tracing stays off when it is not enabled
+func Start(cfg common.TracingConfig) error {
+	Stop()
+	if !cfg.Enabled {
+		return nil
+	}
+
+	opts, err := exporterOptions(cfg)
+	if err != nil {
+		return err
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create otlp trace exporter: %w", err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = defaultSampleRatio
+	} else if ratio < 0 {
+		ratio = 0
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	attrs := []attribute.KeyValue{semconv.ServiceName(serviceName)}
+	if common.Config != nil && common.Config.LocalIP != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(common.Config.LocalIP))
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("Tracing export error", "error", err)
+	}))
+
+	install(tp)
+
+	logger.Info("Tracing enabled", "endpoint", cfg.Endpoint, "sample_ratio", ratio, "service", serviceName)
+	return nil
+}
+
+// install makes tp the provider of the message spans and turns tracing on
+func install(tp *sdktrace.TracerProvider) {
+	providerMu.Lock()
+	provider = tp
+	providerMu.Unlock()
+	tracer.Store(&tracerBox{tp.Tracer("AgentSmith-HUB")})
+	enabled.Store(true)
+}
+
+// Stop flushes the pending spans and turns tracing off
+func Stop() {
+	enabled.Store(false)
+
+	providerMu.Lock()
+	tp := provider
+	provider = nil
+	providerMu.Unlock()
+
+	if tp == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := tp.Shutdown(ctx); err != nil {
+		logger.Warn("Failed to flush traces", "error", err)
+	}
+}
+
+// Enabled reports whether messages are traced
+func Enabled() bool {
+	return enabled.Load()
+}
+
+func exporterOptions(cfg common.TracingConfig) ([]otlptracehttp.Option, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid tracing endpoint %q, expected http(s)://host:port[/path]", endpoint)
+	}
+
+	urlPath := u.Path
+	if urlPath == "" || urlPath == "/" {
+		urlPath = defaultURLPath
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(urlPath),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	return opts, nil
+}
+
+func currentTracer() trace.Tracer {
+	if t := tracer.Load(); t != nil {
+		return t.Tracer
+	}
+	return noop.NewTracerProvider().Tracer("")
+}
+
+// messageCarrier reads and writes the W3C trace context of a message
+type messageCarrier map[string]interface{}
+
+func (c messageCarrier) Get(key string) string {
+	var field string
+	switch key {
+	case "traceparent":
+		field = common.TraceParentField
+	case "tracestate":
+		field = common.TraceStateField
+	default:
+		return ""
+	}
+	v, _ := c[field].(string)
+	return v
+}
+
+func (c messageCarrier) Set(key string, value string) {
+	switch key {
+	case "traceparent":
+		c[common.TraceParentField] = value
+	case "tracestate":
+		c[common.TraceStateField] = value
+	}
+}
+
+func (c messageCarrier) Keys() []string {
+	return []string{"traceparent", "tracestate"}
+}
+
+// StartInput starts the receive span of a message at its input. The trace context the message
+// arrived with becomes the parent and is replaced by the span context when the message is sampled,
+// otherwise it is removed. The input must still own the message, i.e. not have forwarded it yet.
+func StartInput(msg map[string]interface{}, name string) trace.Span {
+	if !enabled.Load() {
+		if _, ok := msg[common.TraceParentField]; ok {
+			delete(msg, common.TraceParentField)
+			delete(msg, common.TraceStateField)
+		}
+		return noopSpan
+	}
+
+	ctx := propagator.Extract(context.Background(), messageCarrier(msg))
+	delete(msg, common.TraceParentField)
+	delete(msg, common.TraceStateField)
+
+	ctx, span := currentTracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindConsumer))
+	if !span.SpanContext().IsSampled() {
+		span.End()
+		return noopSpan
+	}
+	propagator.Inject(ctx, messageCarrier(msg))
+	return span
+}
+
+// StartMessage starts a span below the trace context of a message, messages that are not sampled
+// get a no-op span. The message is only read.
+func StartMessage(msg map[string]interface{}, name string) (context.Context, trace.Span) {
+	if !enabled.Load() {
+		return context.Background(), noopSpan
+	}
+	if _, ok := msg[common.TraceParentField].(string); !ok {
+		return context.Background(), noopSpan
+	}
+	ctx := propagator.Extract(context.Background(), messageCarrier(msg))
+	if !trace.SpanContextFromContext(ctx).IsSampled() {
+		return context.Background(), noopSpan
+	}
+	return currentTracer().Start(ctx, name)
+}
+
+// StartSpan starts a child span of the span in ctx, a no-op span when ctx has no sampled span
+func StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if !enabled.Load() || !trace.SpanContextFromContext(ctx).IsSampled() {
+		return ctx, noopSpan
+	}
+	return currentTracer().Start(ctx, name)
+}
+
+// End ends a span and marks it failed when err is set
+func End(span trace.Span, err error) {
+	if err != nil && span.IsRecording() {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID returns the trace ID of a traced message
+func TraceID(msg map[string]interface{}) (string, bool) {
+	if !enabled.Load() {
+		return "", false
+	}
+	if _, ok := msg[common.TraceParentField].(string); !ok {
+		return "", false
+	}
+	sc := trace.SpanContextFromContext(propagator.Extract(context.Background(), messageCarrier(msg)))
+	if !sc.IsValid() {
+		return "", false
+	}
+	return sc.TraceID().String(), true
+}
@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"AgentSmith-HUB/common"
+	"context"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID  = "00f067aa0ba902b7"
+)
+
+// setupTracingTest turns tracing on with spans recorded in memory, ratio is the share of new traces sampled
+func setupTracingTest(t *testing.T, ratio float64) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	install(sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	))
+	t.Cleanup(Stop)
+	return exporter
+}
+
+// kafkaMessage is a message as the Kafka consumer builds it from a record with trace headers
+func kafkaMessage(flags string) map[string]interface{} {
+	return map[string]interface{}{
+		"data":                  "x",
+		common.TraceParentField: "00-" + parentTraceID + "-" + parentSpanID + "-" + flags,
+		common.TraceStateField:  "vendor=value",
+	}
+}
+
+func TestStartInputContinuesTrace(t *testing.T) {
+	// No new traces are sampled, the message is traced because its parent was
+	exporter := setupTracingTest(t, 0)
+
+	msg := kafkaMessage("01")
+	span := StartInput(msg, "input")
+	if !span.IsRecording() {
+		t.Fatal("StartInput() returned a span that is not recording for a sampled parent")
+	}
+	sc := span.SpanContext()
+	span.End()
+
+	if sc.TraceID().String() != parentTraceID {
+		t.Errorf("trace ID = %s, expected the trace of the Kafka headers %s", sc.TraceID(), parentTraceID)
+	}
+	expected := "00-" + parentTraceID + "-" + sc.SpanID().String() + "-01"
+	if msg[common.TraceParentField] != expected {
+		t.Errorf("%s = %v, expected the input span %s", common.TraceParentField, msg[common.TraceParentField], expected)
+	}
+	if msg[common.TraceStateField] != "vendor=value" {
+		t.Errorf("%s = %v, expected the trace state of the headers", common.TraceStateField, msg[common.TraceStateField])
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, expected 1", len(spans))
+	}
+	if spans[0].Parent.SpanID().String() != parentSpanID || spans[0].SpanKind != trace.SpanKindConsumer {
+		t.Errorf("input span parent = %s kind = %s, expected parent %s and kind consumer", spans[0].Parent.SpanID(), spans[0].SpanKind, parentSpanID)
+	}
+}
+
+func TestStartInputUnsampled(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		msg     map[string]interface{}
+	}{
+		{"parent not sampled", true, kafkaMessage("00")},
+		{"no parent", true, map[string]interface{}{"data": "x"}},
+		{"tracing disabled", false, kafkaMessage("01")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := setupTracingTest(t, 0)
+			if !test.enabled {
+				Stop()
+			}
+
+			span := StartInput(test.msg, "input")
+			if span.IsRecording() {
+				t.Error("StartInput() returned a recording span for a message that is not sampled")
+			}
+			span.End()
+			for k := range test.msg {
+				if strings.HasPrefix(k, "_hub_trace") {
+					t.Errorf("message keeps %s = %v, expected no trace fields", k, test.msg[k])
+				}
+			}
+			if spans := exporter.GetSpans(); len(spans) != 0 {
+				t.Errorf("exported %d spans, expected none", len(spans))
+			}
+		})
+	}
+}
+
+func TestChildSpans(t *testing.T) {
+	exporter := setupTracingTest(t, 1)
+
+	msg := map[string]interface{}{"data": "x"}
+	input := StartInput(msg, "input")
+	input.End()
+
+	ctx, ruleset := StartMessage(msg, "ruleset")
+	_, plugin := StartSpan(ctx, "plugin")
+	if !ruleset.IsRecording() || !plugin.IsRecording() {
+		t.Fatal("spans below a sampled message are not recording")
+	}
+	End(plugin, nil)
+	End(ruleset, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("exported %d spans, expected 3", len(spans))
+	}
+	parents := make(map[string]trace.SpanID)
+	ids := make(map[string]trace.SpanID)
+	for _, span := range spans {
+		parents[span.Name] = span.Parent.SpanID()
+		ids[span.Name] = span.SpanContext.SpanID()
+		if span.SpanContext.TraceID() != input.SpanContext().TraceID() {
+			t.Errorf("span %s is in trace %s, expected %s", span.Name, span.SpanContext.TraceID(), input.SpanContext().TraceID())
+		}
+	}
+	if parents["ruleset"] != ids["input"] {
+		t.Errorf("ruleset span parent = %s, expected the input span %s", parents["ruleset"], ids["input"])
+	}
+	if parents["plugin"] != ids["ruleset"] {
+		t.Errorf("plugin span parent = %s, expected the ruleset span %s", parents["plugin"], ids["ruleset"])
+	}
+
+	traceID, ok := TraceID(msg)
+	if !ok || traceID != input.SpanContext().TraceID().String() {
+		t.Errorf("TraceID() = %q, %v, expected %s", traceID, ok, input.SpanContext().TraceID())
+	}
+
+	// Messages without a trace context and contexts without a span get no-op spans
+	if _, span := StartMessage(map[string]interface{}{"data": "x"}, "ruleset"); span.IsRecording() {
+		t.Error("StartMessage() returned a recording span for a message without trace context")
+	}
+	if _, span := StartSpan(context.Background(), "plugin"); span.IsRecording() {
+		t.Error("StartSpan() returned a recording span without a parent")
+	}
+}
@@ -114,6 +114,28 @@ export const hubApi = {
     delete api.defaults.headers.token;
   },
 
+  async getOIDCConfig() {
+    try {
+      const response = await publicApi.get('/auth/oidc/config');
+      return response.data;
+    } catch (error) {
+      return { enabled: false };
+    }
+  },
+
+  getOIDCLoginUrl() {
+    return `${config.apiBaseUrl}/auth/oidc/login`;
+  },
+
+  async logout() {
+    try {
+      await api.post('/auth/logout');
+    } catch (error) {
+      // Ignore, the local token is cleared anyway
+    }
+    this.clearToken();
+  },
+
   async verifyToken() {
     try {
       const response = await api.get('/token-check');
@@ -1353,6 +1375,78 @@ export const hubApi = {
     }
   },
 
+  // Config snapshot API functions
+  async getSnapshots() {
+    try {
+      const response = await api.get('/snapshots');
+      return response.data;
+    } catch (error) {
+      console.error('Error fetching config snapshots:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to fetch config snapshots');
+    }
+  },
+
+  async createSnapshot(name, comment = '') {
+    try {
+      const response = await api.post('/snapshots', { name, comment });
+      return response.data;
+    } catch (error) {
+      console.error('Error creating config snapshot:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to create config snapshot');
+    }
+  },
+
+  async deleteSnapshot(name) {
+    try {
+      const response = await api.delete(`/snapshots/${encodeURIComponent(name)}`);
+      return response.data;
+    } catch (error) {
+      console.error('Error deleting config snapshot:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to delete config snapshot');
+    }
+  },
+
+  // "current" stands for the live component set
+  async diffSnapshots(from, to) {
+    try {
+      const response = await api.get('/snapshots/diff', { params: { from, to } });
+      return response.data;
+    } catch (error) {
+      console.error('Error comparing config snapshots:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to compare config snapshots');
+    }
+  },
+
+  async rollbackToSnapshot(name) {
+    try {
+      const response = await api.post(`/snapshots/${encodeURIComponent(name)}/rollback`);
+      return response.data;
+    } catch (error) {
+      console.error('Error rolling back to config snapshot:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to roll back to config snapshot');
+    }
+  },
+
+  async getGitOpsStatus() {
+    try {
+      const response = await api.get('/gitops/status');
+      return response.data;
+    } catch (error) {
+      console.error('Error fetching GitOps status:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to fetch GitOps status');
+    }
+  },
+
+  async syncGitOps() {
+    try {
+      const response = await api.post('/gitops/sync');
+      return response.data;
+    } catch (error) {
+      console.error('Error syncing GitOps repository:', error);
+      throw new Error(error.response?.data?.error || error.message || 'Failed to sync GitOps repository');
+    }
+  },
+
   async getPluginStats(params = {}) {
     try {
       const response = await api.get('/plugin-stats', { params });
@@ -111,6 +111,18 @@ const routes = [
         component: () => import('../components/OperationsHistory.vue'),
         meta: { requiresAuth: true, componentType: 'operations-history' }
       },
+      {
+        path: 'config-snapshots',
+        name: 'ConfigSnapshots',
+        component: () => import('../components/ConfigSnapshots.vue'),
+        meta: { requiresAuth: true, componentType: 'config-snapshots' }
+      },
+      {
+        path: 'gitops',
+        name: 'GitOps',
+        component: () => import('../components/GitOps.vue'),
+        meta: { requiresAuth: true, componentType: 'gitops' }
+      },
       {
         path: 'error-logs',
         name: 'ErrorLogs',
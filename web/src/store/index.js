@@ -27,6 +27,17 @@ const store = createStore({
       { value: 'NCS_NEQ', detail: 'Case-insensitive not equal check' },
       { value: 'MT', detail: 'More than check' },
       { value: 'LT', detail: 'Less than check' },
+      { value: 'GTE', detail: 'Greater than or equal check (numeric)' },
+      { value: 'LTE', detail: 'Less than or equal check (numeric)' },
+      { value: 'BETWEEN', detail: 'Numeric range check, value is min,max (inclusive)' },
+      { value: 'IN', detail: 'Exact match against a value list' },
+      { value: 'NOT_IN', detail: 'Not in a value list check' },
+      { value: 'LEN_MT', detail: 'Length more than check' },
+      { value: 'LEN_LT', detail: 'Length less than check' },
+      { value: 'CIDR', detail: 'IP in network check' },
+      { value: 'TYPE', detail: 'Field type check (string/number/bool/array/object)' },
+      { value: 'IN_LOOKUP', detail: 'Lookup table key check' },
+      { value: 'NOT_IN_LOOKUP', detail: 'Not a lookup table key check' },
       { value: 'PLUGIN', detail: 'Plugin check' }
     ],
     logicTypes: [
@@ -35,7 +46,11 @@ const store = createStore({
     ],
     countTypes: [
       { value: 'SUM', detail: 'Sum values' },
-      { value: 'CLASSIFY', detail: 'Count unique values' }
+      { value: 'CLASSIFY', detail: 'Count unique values' },
+      { value: 'DISTINCT', detail: 'Estimated unique count (HyperLogLog)' },
+      { value: 'AVG', detail: 'Average of values' },
+      { value: 'MAX', detail: 'Maximum of values' },
+      { value: 'MIN', detail: 'Minimum of values (triggers below value)' }
     ],
     rootTypes: [
       { value: 'DETECTION', detail: 'Detection rule type' },
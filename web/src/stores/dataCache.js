@@ -337,7 +337,7 @@ export const useDataCacheStore = defineStore('dataCache', {
       // If cache doesn't exist, check if it's a special UI type
       if (!cache) {
         // Special UI types that are not actual component types
-        const uiTypes = ['settings', 'cluster', 'pending-changes', 'load-local-components', 'operations-history', 'error-logs', 'tutorial', 'home']
+        const uiTypes = ['settings', 'cluster', 'pending-changes', 'load-local-components', 'operations-history', 'config-snapshots', 'gitops', 'error-logs', 'tutorial', 'home']
         if (uiTypes.includes(type)) {
           console.warn(`Attempted to fetch '${type}' as component type, but it's a UI type. Returning empty array.`)
           return []